@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+// runBudgetAllow grants a time-limited override on budgets whose over-cap
+// action is override, letting requests past their caps until it expires.
+// Without --scope every such budget is overridden.
+func runBudgetAllow(home string, args []string) error {
+	flags := flag.NewFlagSet("budget allow", flag.ContinueOnError)
+	duration := flags.Duration("for", 30*time.Minute, "how long the override lasts, e.g. 30m or 2h")
+	scopeFlag := flags.String("scope", "", "only override budgets of this scope")
+	targetFlag := flags.String("target", "", "scope target")
+	periodFlag := flags.String("period", "", "only the budget with this period")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *duration <= 0 {
+		return errors.New("--for must be positive")
+	}
+
+	db, err := sqlite.Open(filepath.Join(home, "usage.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	tracker := budget.NewTracker(db)
+
+	var budgets []*budget.Budget
+	if *scopeFlag == "" {
+		if *targetFlag != "" || *periodFlag != "" {
+			return errors.New("--target and --period need --scope")
+		}
+		budgets, err = tracker.GetAllBudgets()
+	} else {
+		var scope, target string
+		scope, target, _, err = resolveBudgetScope(*scopeFlag, *targetFlag)
+		if err != nil {
+			return err
+		}
+		budgets, err = tracker.Budgets(scope, target)
+	}
+	if err != nil {
+		return err
+	}
+
+	var period *budget.PeriodSpec
+	if *periodFlag != "" {
+		spec, err := budget.ParsePeriod(*periodFlag)
+		if err != nil {
+			return err
+		}
+		period = &spec
+	}
+
+	granted := 0
+	for _, b := range budgets {
+		if b.Action() != budget.ActionOverride || (period != nil && b.Spec().String() != period.String()) {
+			continue
+		}
+		until, err := tracker.Allow(b, *duration)
+		if err != nil {
+			return fmt.Errorf("failed to grant override: %w", err)
+		}
+		fmt.Printf("%s %s (%s) budget: requests over the cap allowed until %s\n",
+			statusOK, budgetScopeName(b.Scope, b.Target), b.Spec().Label(), until.Format("15:04"))
+		granted++
+	}
+	if granted == 0 {
+		return errors.New("no budget uses the override action; set one with 'boba budget --on-cap override'")
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+
+	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/domain/currency"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+// runBudgetHistory lists past periods of a scope's budgets and what was spent
+// in each, so spending can be compared across months, weeks or windows
+func runBudgetHistory(home string, args []string) error {
+	flags := flag.NewFlagSet("budget history", flag.ContinueOnError)
+	scopeFlag := flags.String("scope", "auto", "scope: auto|global|project|profile|tool|provider|binding|model, combined with +")
+	targetFlag := flags.String("target", "", "scope target, e.g. a project name, or claude-code+claude-opus-* for tool+model")
+	periodFlag := flags.String("period", "", "only the budget with this period")
+	limit := flags.Int("limit", 12, "periods to show per budget")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := sqlite.Open(filepath.Join(home, "usage.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	tracker := budget.NewTracker(db)
+	scope, target, _, err := resolveBudgetScope(*scopeFlag, *targetFlag)
+	if err != nil {
+		return err
+	}
+
+	var budgets []*budget.Budget
+	if *periodFlag != "" {
+		spec, err := budget.ParsePeriod(*periodFlag)
+		if err != nil {
+			return err
+		}
+		b, err := tracker.FindBudget(scope, target, spec)
+		if err != nil {
+			return fmt.Errorf("no %s budget for %s", spec.Label(), budgetScopeName(scope, target))
+		}
+		budgets = append(budgets, b)
+	} else {
+		budgets, err = tracker.Budgets(scope, target)
+		if err != nil {
+			return err
+		}
+	}
+	if len(budgets) == 0 {
+		return fmt.Errorf("no budget for %s; set one with 'boba budget --cap'", budgetScopeName(scope, target))
+	}
+
+	var (
+		headerStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("99")).
+				Padding(0, 1)
+
+		cellStyle = lipgloss.NewStyle().
+				Padding(0, 1)
+	)
+
+	fmt.Printf("Budget History: %s\n", budgetScopeName(scope, target))
+	fmt.Println("==================================")
+	for _, b := range budgets {
+		records, err := tracker.History(b, *limit)
+		if err != nil {
+			return err
+		}
+
+		rows := make([][]string, 0, len(records))
+		for _, record := range records {
+			used := "-"
+			if record.HardCap > 0 {
+				used = fmt.Sprintf("%.1f%%", record.Spent/record.HardCap*100)
+			}
+			start := record.Start.Format("2006-01-02")
+			if record.Current {
+				start += " (current)"
+			}
+			rows = append(rows, []string{
+				start,
+				record.End.Format("2006-01-02"),
+				currency.Format(record.Spent, record.Currency, 4),
+				currency.Format(record.HardCap, record.Currency, 2),
+				used,
+			})
+		}
+
+		t := table.New().
+			Border(lipgloss.HiddenBorder()).
+			Headers("START", "END", "SPENT", "CAP", "USED").
+			Rows(rows...).
+			StyleFunc(func(row, col int) lipgloss.Style {
+				if row == 0 {
+					return headerStyle
+				}
+				return cellStyle
+			})
+
+		fmt.Println()
+		fmt.Printf("%s budget\n", capitalize(b.Spec().Label()))
+		if len(rows) == 0 {
+			fmt.Println("No periods recorded yet.")
+			continue
+		}
+		fmt.Println(t)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+
+	"github.com/royisme/bobamixer/internal/domain/stats"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+// runCompare reports shadow traffic results side by side with the primary provider
+func runCompare(home string, args []string) error {
+	flags := flag.NewFlagSet("compare", flag.ContinueOnError)
+	days := flags.Int("days", 7, "number of days to include")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *days < 1 {
+		return fmt.Errorf("--days must be at least 1")
+	}
+
+	db, err := sqlite.Open(filepath.Join(home, "usage.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -(*days - 1))
+	comparisons, err := stats.ShadowComparisons(context.Background(), db, from, to)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Shadow Traffic Comparison (last %d days)\n", *days)
+	fmt.Println("=========================================")
+	if len(comparisons) == 0 {
+		fmt.Println("No shadow results recorded. Enable proxy.shadow in settings.yaml and run boba proxy serve.")
+		return nil
+	}
+
+	var (
+		headerStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("99")).
+				Padding(0, 1)
+
+		cellStyle = lipgloss.NewStyle().
+				Padding(0, 1)
+	)
+
+	rows := make([][]string, 0, len(comparisons))
+	for _, c := range comparisons {
+		shadowModel := c.ShadowModel
+		if shadowModel == "" {
+			shadowModel = "-"
+		}
+		rows = append(rows, []string{
+			c.PrimaryModel,
+			c.ShadowProvider + "/" + shadowModel,
+			fmt.Sprintf("%d", c.Requests),
+			fmt.Sprintf("%d", c.Errors),
+			fmt.Sprintf("%.0fms / %.0fms", c.PrimaryLatencyMS, c.ShadowLatencyMS),
+			fmt.Sprintf("$%.4f / $%.4f", c.PrimaryCost, c.ShadowCost),
+			fmt.Sprintf("%+.0f%%", c.CostDeltaPercent()),
+			fmt.Sprintf("%.0f%%", c.Similarity*100),
+		})
+	}
+
+	t := table.New().
+		Border(lipgloss.HiddenBorder()).
+		Headers("PRIMARY", "SHADOW", "REQS", "ERRORS", "LATENCY (P/S)", "COST (P/S)", "COST Δ", "SIMILARITY").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return headerStyle
+			}
+			return cellStyle
+		})
+
+	fmt.Println(t)
+	fmt.Println()
+	fmt.Println("Latency, cost and similarity cover successful shadows; similarity is word overlap with the primary output.")
+	return nil
+}
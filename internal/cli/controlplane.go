@@ -2,8 +2,11 @@ package cli
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -12,17 +15,26 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
 
+	"github.com/royisme/bobamixer/internal/domain/catalog"
 	"github.com/royisme/bobamixer/internal/domain/core"
 	"github.com/royisme/bobamixer/internal/domain/pricing"
+	"github.com/royisme/bobamixer/internal/domain/routing"
 	"github.com/royisme/bobamixer/internal/logging"
 	"github.com/royisme/bobamixer/internal/proxy"
 	"github.com/royisme/bobamixer/internal/runner"
+	"github.com/royisme/bobamixer/internal/settings"
 	"github.com/royisme/bobamixer/internal/store/config"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+	"github.com/royisme/bobamixer/internal/transcript"
 	"github.com/royisme/bobamixer/internal/ui/keys"
 )
 
 // runProviders lists all configured providers
-func runProviders(home string, _ []string) error {
+func runProviders(home string, args []string) error {
+	if len(args) > 0 && args[0] == "models" {
+		return runProviderModels(home, args[1:])
+	}
+
 	logging.Info("Running providers command")
 
 	providers, err := core.LoadProviders(home)
@@ -408,6 +420,16 @@ func runDoctorV2(home string, args []string) error {
 	}
 	fmt.Println()
 
+	// Check that binding model overrides exist upstream
+	if providers != nil && bindings != nil && len(bindings.Bindings) > 0 {
+		fmt.Println("🧠 Models")
+		fmt.Println("─────────")
+		warn, fail := checkBindingModels(home, providers, bindings)
+		hasWarnings = hasWarnings || warn
+		hasErrors = hasErrors || fail
+		fmt.Println()
+	}
+
 	// Summary
 	fmt.Println("Summary")
 	fmt.Println("───────")
@@ -458,6 +480,14 @@ func runRun(home string, args []string) error {
 		return fmt.Errorf("provider %s not found\nRun 'boba providers' to list available providers", binding.ProviderID)
 	}
 
+	// Attribute the tool's proxied requests to the working directory's project
+	project := ""
+	if cwd, err := os.Getwd(); err == nil {
+		if projectCfg, _, err := config.FindProjectConfig(cwd); err == nil && projectCfg != nil {
+			project = projectCfg.Project.Name
+		}
+	}
+
 	// Create run context
 	ctx := &runner.RunContext{
 		Home:     home,
@@ -465,6 +495,7 @@ func runRun(home string, args []string) error {
 		Binding:  binding,
 		Provider: provider,
 		Secrets:  secrets,
+		Project:  project,
 		Args:     toolArgs,
 	}
 
@@ -479,7 +510,7 @@ func runRun(home string, args []string) error {
 // runProxy handles proxy subcommands
 func runProxy(home string, args []string) error {
 	if len(args) == 0 {
-		return fmt.Errorf("proxy subcommand required: serve, status, stop")
+		return fmt.Errorf("proxy subcommand required: serve, status, stop, cache, audit, ca")
 	}
 
 	switch args[0] {
@@ -489,11 +520,129 @@ func runProxy(home string, args []string) error {
 		return runProxyStatus(home, args[1:])
 	case "stop":
 		return runProxyStop(home, args[1:])
+	case "cache":
+		return runProxyCache(home, args[1:])
+	case "audit":
+		return runProxyAudit(home, args[1:])
+	case "ca":
+		return runProxyCA(home, args[1:])
 	default:
 		return fmt.Errorf("unknown proxy subcommand: %s", args[0])
 	}
 }
 
+// configureProxyCache enables the response cache when proxy.cache.enabled is set in settings.yaml
+func configureProxyCache(home string, handler *proxy.Handler) (bool, error) {
+	userSettings, err := settings.Load(context.Background(), home)
+	if err != nil {
+		return false, fmt.Errorf("failed to load settings: %w", err)
+	}
+	cacheCfg := userSettings.Proxy.Cache
+	if !cacheCfg.Enabled {
+		return false, nil
+	}
+
+	cache, err := proxy.NewResponseCache(filepath.Join(home, "cache"), proxy.CacheOptions{
+		TTL:        time.Duration(cacheCfg.TTLSeconds) * time.Second,
+		MaxEntries: cacheCfg.MaxEntries,
+		MaxBytes:   int64(cacheCfg.MaxSizeMB) << 20,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to open response cache: %w", err)
+	}
+	handler.SetResponseCache(cache)
+	return true, nil
+}
+
+// configureProxyTranscripts records proxied exchanges when proxy.transcripts.enabled is set
+func configureProxyTranscripts(home string, handler *proxy.Handler) (bool, error) {
+	userSettings, err := settings.Load(context.Background(), home)
+	if err != nil {
+		return false, fmt.Errorf("failed to load settings: %w", err)
+	}
+	if !userSettings.Proxy.Transcripts.Enabled {
+		return false, nil
+	}
+	recorder, err := transcript.NewRecorder(filepath.Join(home, "transcripts"))
+	if err != nil {
+		return false, err
+	}
+	handler.SetTranscriptRecorder(recorder)
+	return true, nil
+}
+
+// configureProxyShadow mirrors sampled requests when proxy.shadow.enabled is set,
+// returning the number of shadow targets
+func configureProxyShadow(home string, handler *proxy.Handler) (int, error) {
+	userSettings, err := settings.Load(context.Background(), home)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load settings: %w", err)
+	}
+	shadow := userSettings.Proxy.Shadow
+	if !shadow.Enabled || shadow.SampleRate <= 0 || len(shadow.Targets) == 0 {
+		return 0, nil
+	}
+	if shadow.SampleRate > 1 {
+		return 0, fmt.Errorf("proxy.shadow.sample_rate must be between 0 and 1")
+	}
+
+	config := &proxy.ShadowConfig{SampleRate: shadow.SampleRate}
+	for _, t := range shadow.Targets {
+		if t.Provider == "" {
+			return 0, fmt.Errorf("proxy.shadow.targets: provider is required")
+		}
+		config.Targets = append(config.Targets, proxy.ShadowTarget{ProviderID: t.Provider, Model: t.Model, Tools: t.Tools})
+	}
+	handler.SetShadow(config)
+	return len(config.Targets), nil
+}
+
+// configureProxyRouting loads routes.yaml into the proxy's routing engine, returning
+// the number of sub-agents and rules. Invalid routes are logged and leave routing off.
+func configureProxyRouting(home string, handler *proxy.Handler) (subAgents, rules int) {
+	routes, err := config.LoadRoutes(home)
+	if err != nil {
+		logging.Warn("Failed to load routes for proxy", logging.Err(err))
+		return 0, 0
+	}
+	if len(routes.SubAgents)+len(routes.Rules) == 0 {
+		return 0, 0
+	}
+	engine, err := routing.CompileRoutes(routes)
+	if err != nil {
+		logging.Warn("Invalid routes.yaml, proxy routing disabled (run 'boba route lint')", logging.Err(err))
+		return 0, 0
+	}
+	handler.SetRoutingEngine(engine)
+	// The bandit learns from requests served by the profile routing chose
+	if profiles, err := config.LoadProfiles(home); err != nil {
+		logging.Warn("Failed to load profiles for proxy routing", logging.Err(err))
+	} else {
+		handler.SetProfiles(profiles)
+	}
+	if userSettings, err := settings.Load(context.Background(), home); err == nil {
+		handler.SetRouteRecording(userSettings.Routing.RecordFeatures)
+	}
+	return len(routes.SubAgents), len(routes.Rules)
+}
+
+// runProxyCache manages the on-disk response cache
+func runProxyCache(home string, args []string) error {
+	if len(args) == 0 || args[0] != "clear" {
+		return fmt.Errorf("usage: boba proxy cache clear")
+	}
+
+	cache, err := proxy.NewResponseCache(filepath.Join(home, "cache"), proxy.CacheOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to open response cache: %w", err)
+	}
+	if err := cache.Clear(); err != nil {
+		return fmt.Errorf("failed to clear response cache: %w", err)
+	}
+	fmt.Println("✓ Response cache cleared")
+	return nil
+}
+
 // runProxyServe starts the proxy server
 func runProxyServe(home string, _ []string) error {
 	logging.Info("Starting proxy server")
@@ -504,17 +653,191 @@ func runProxyServe(home string, _ []string) error {
 		return fmt.Errorf("failed to create proxy server: %w", err)
 	}
 
+	// Let the proxy resolve each tool's provider and rotate through its key pool
+	providers, _, bindings, secrets, err := core.LoadAll(home)
+	if err != nil {
+		logging.Warn("Failed to load control plane config for proxy", logging.Err(err))
+	} else {
+		server.Handler().SetControlPlane(providers, bindings, secrets)
+	}
+
+	// Answer /v1/models with the models each bound or enabled provider offers
+	server.Handler().SetModelCatalog(catalog.New(home, 0))
+
+	// Price proxied requests so costs and cache savings are recorded
+	if table, err := pricing.Load(home); err != nil {
+		logging.Warn("Failed to load pricing for proxy", logging.Err(err))
+	} else {
+		server.Handler().SetPricingTable(table)
+	}
+
+	// Grade budgets for routing and downgrade ladders at the configured thresholds
+	server.Handler().SetAlertConfig(loadAlertConfig(home))
+
+	cacheEnabled, err := configureProxyCache(home, server.Handler())
+	if err != nil {
+		return err
+	}
+
+	transcriptsEnabled, err := configureProxyTranscripts(home, server.Handler())
+	if err != nil {
+		return err
+	}
+
+	shadowTargets, err := configureProxyShadow(home, server.Handler())
+	if err != nil {
+		return err
+	}
+
+	routingSubAgents, routingRules := configureProxyRouting(home, server.Handler())
+
+	policies, err := proxy.LoadPolicies(home)
+	if err != nil {
+		return err
+	}
+	server.Handler().SetPolicies(policies)
+
+	dlpConfig, dlpScanner, err := loadDLPScanner(home)
+	if err != nil {
+		return err
+	}
+	if dlpConfig.IsEnabled() {
+		server.Handler().SetDLPScanner(dlpScanner)
+	}
+
+	notificationSinks, err := startProxyNotifier(home)
+	if err != nil {
+		return err
+	}
+
+	// Intercept HTTPS to known AI hosts for tools that use HTTPS_PROXY
+	ca, err := proxy.LoadOrCreateCA(proxy.CADir(home))
+	if err != nil {
+		return fmt.Errorf("failed to load proxy CA: %w", err)
+	}
+	server.Handler().SetCertificateAuthority(ca)
+
 	if err := server.Start(); err != nil {
 		return fmt.Errorf("failed to start proxy server: %w", err)
 	}
 
 	fmt.Printf("✓ Proxy server started on %s\n", server.Addr())
+	if cacheEnabled {
+		fmt.Printf("  Response cache: %s\n", filepath.Join(home, "cache"))
+	}
+	if transcriptsEnabled {
+		fmt.Printf("  Transcripts:    %s\n", filepath.Join(home, "transcripts"))
+	}
+	if shadowTargets > 0 {
+		fmt.Printf("  Shadow traffic: %d targets (compare with boba compare)\n", shadowTargets)
+	}
+	if dlpConfig.IsEnabled() {
+		fmt.Printf("  DLP scanning:   %d rules active\n", len(dlpScanner.Rules()))
+	}
+	if len(policies.Policies) > 0 {
+		fmt.Printf("  Policies:       %d loaded from policies.yaml\n", len(policies.Policies))
+	}
+	if routingSubAgents+routingRules > 0 {
+		fmt.Printf("  Routing:        %d sub-agents, %d rules from routes.yaml\n", routingSubAgents, routingRules)
+	}
+	if notificationSinks > 0 {
+		fmt.Printf("  Notifications:  %d sinks\n", notificationSinks)
+	}
+	fmt.Printf("  HTTPS_PROXY:    http://%s (trust %s)\n", server.Addr(), filepath.Join(proxy.CADir(home), proxy.CACertFile))
 	fmt.Printf("\nPress %s to stop...\n", keys.CtrlC)
 
 	// Wait for interrupt signal
 	select {}
 }
 
+// runProxyCA manages the local CA used to intercept HTTPS traffic to AI hosts
+func runProxyCA(home string, args []string) error {
+	if len(args) == 0 || args[0] != "install" {
+		return fmt.Errorf("usage: boba proxy ca install [--out FILE]")
+	}
+
+	flags := flag.NewFlagSet("proxy ca install", flag.ContinueOnError)
+	out := flags.String("out", "", "also write the CA certificate to this file")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	ca, err := proxy.LoadOrCreateCA(proxy.CADir(home))
+	if err != nil {
+		return fmt.Errorf("failed to load proxy CA: %w", err)
+	}
+	certPath := filepath.Join(proxy.CADir(home), proxy.CACertFile)
+	if *out != "" {
+		if err := os.WriteFile(*out, ca.CertPEM(), 0o644); err != nil { // #nosec G306 -- CA certificates are public
+			return fmt.Errorf("failed to export CA certificate: %w", err)
+		}
+		certPath = *out
+	}
+
+	fmt.Printf("✓ BobaMixer CA certificate: %s\n", certPath)
+	fmt.Println("\nTrust it so tools accept intercepted HTTPS connections:")
+	fmt.Println("  macOS:   sudo security add-trusted-cert -d -r trustRoot -k /Library/Keychains/System.keychain " + certPath)
+	fmt.Println("  Linux:   sudo cp " + certPath + " /usr/local/share/ca-certificates/boba-ca.crt && sudo update-ca-certificates")
+	fmt.Println("  Node:    export NODE_EXTRA_CA_CERTS=" + certPath)
+	fmt.Println("  Python:  export REQUESTS_CA_BUNDLE=" + certPath + " SSL_CERT_FILE=" + certPath)
+	fmt.Printf("\nThen route tools through the proxy:\n  export HTTPS_PROXY=http://%s\n", proxy.DefaultAddr)
+	fmt.Println("\nOnly api.openai.com, api.anthropic.com and configured provider hosts are decrypted;")
+	fmt.Println("all other HTTPS traffic is tunnelled untouched.")
+	return nil
+}
+
+// runProxyAudit lists policy rewrites applied by the proxy, newest first
+func runProxyAudit(home string, args []string) error {
+	flags := flag.NewFlagSet("proxy audit", flag.ContinueOnError)
+	sessionID := flags.String("session", "", "only show rewrites for this session")
+	limit := flags.Int("limit", 50, "maximum number of rewrites to show")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := sqlite.Open(filepath.Join(home, "usage.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	where := ""
+	if *sessionID != "" {
+		where = fmt.Sprintf("WHERE session_id = '%s'", strings.ReplaceAll(*sessionID, "'", "''"))
+	}
+	rows, err := db.QueryRows(fmt.Sprintf(`SELECT ts, session_id, COALESCE(tool, ''), policy_id, action, COALESCE(detail, '')
+		FROM policy_rewrites %s ORDER BY ts DESC, id DESC LIMIT %d;`, where, *limit))
+	if err != nil {
+		return fmt.Errorf("failed to query policy rewrites: %w", err)
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No policy rewrites recorded.")
+		return nil
+	}
+
+	fmt.Println("Policy Rewrites")
+	fmt.Println("===============")
+	for _, row := range rows {
+		parts := strings.SplitN(row, "|", 6)
+		if len(parts) < 6 {
+			continue
+		}
+		var ts int64
+		if _, err := fmt.Sscanf(parts[0], "%d", &ts); err != nil {
+			continue
+		}
+		tool := parts[2]
+		if tool == "" {
+			tool = "unknown"
+		}
+		fmt.Printf("%s  %-24s %-10s %-16s %-20s %s\n",
+			time.Unix(ts, 0).Format("2006-01-02 15:04:05"), parts[1], tool, parts[3], parts[4], parts[5])
+	}
+	return nil
+}
+
 // runProxyStatus shows the proxy server status
 func runProxyStatus(_ string, _ []string) error {
 	logging.Info("Checking proxy status")
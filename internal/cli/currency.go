@@ -0,0 +1,279 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+
+	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/domain/currency"
+	"github.com/royisme/bobamixer/internal/logging"
+	"github.com/royisme/bobamixer/internal/settings"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+const currencyUsage = "usage: boba currency [list] | set <code> <per-usd> [--from YYYY-MM-DD] | remove <code> <YYYY-MM-DD> | display [code]"
+
+// runCurrency manages the exchange rates prices and budgets in other
+// currencies convert at, and the currency stats are shown in
+func runCurrency(home string, args []string) error {
+	sub := "list"
+	if len(args) > 0 {
+		sub, args = args[0], args[1:]
+	}
+
+	db, err := sqlite.Open(filepath.Join(home, "usage.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	rates := currency.NewRates(db)
+
+	switch sub {
+	case "list":
+		if len(args) > 0 {
+			return errors.New(currencyUsage)
+		}
+		return listRates(home, rates)
+	case "set":
+		return runCurrencySet(rates, args)
+	case "remove":
+		if len(args) != 2 {
+			return errors.New(currencyUsage)
+		}
+		from, err := time.ParseInLocation(time.DateOnly, args[1], time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid date %q: want YYYY-MM-DD", args[1])
+		}
+		if err := checkRateInUse(db, rates, args[0]); err != nil {
+			return err
+		}
+		if err := rates.Remove(args[0], from); err != nil {
+			return err
+		}
+		fmt.Printf("%s Removed the %s rate from %s\n", statusOK, args[0], args[1])
+		return nil
+	case "display":
+		return runCurrencyDisplay(home, rates, args)
+	default:
+		return fmt.Errorf("unknown currency subcommand: %s\n%s", sub, currencyUsage)
+	}
+}
+
+// checkRateInUse refuses to remove the last rate of a currency a budget is
+// kept in, since the budget could no longer be checked
+func checkRateInUse(db *sqlite.DB, rates *currency.Rates, code string) error {
+	code, err := currency.Normalize(code)
+	if err != nil {
+		return err
+	}
+	list, err := rates.List()
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, r := range list {
+		if r.Currency == code {
+			n++
+		}
+	}
+	if n != 1 {
+		return nil
+	}
+	budgets, err := budget.NewTracker(db).GetAllBudgets()
+	if err != nil {
+		return err
+	}
+	for _, b := range budgets {
+		if b.Currency == code {
+			return fmt.Errorf("budget %s is kept in %s and needs a rate; move it with 'boba budget --currency' first", b.ID, code)
+		}
+	}
+	return nil
+}
+
+// runCurrencySet stores how many units of a currency one US dollar buys, from
+// today or the day given with --from
+func runCurrencySet(rates *currency.Rates, args []string) error {
+	flags := flag.NewFlagSet("currency set", flag.ContinueOnError)
+	fromFlag := flags.String("from", "", "first day the rate applies, YYYY-MM-DD (default today)")
+	flags.SetOutput(io.Discard)
+
+	// The code and rate may come before or after the flags
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(positional) != 2 {
+		return errors.New(currencyUsage)
+	}
+	code, err := currency.Normalize(positional[0])
+	if err != nil {
+		return err
+	}
+	perUSD, err := strconv.ParseFloat(positional[1], 64)
+	if err != nil {
+		return fmt.Errorf("invalid rate %q: want units of %s per US dollar, e.g. 7.12", positional[1], code)
+	}
+
+	from := time.Now()
+	if *fromFlag != "" {
+		from, err = time.ParseInLocation(time.DateOnly, *fromFlag, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --from %q: want YYYY-MM-DD", *fromFlag)
+		}
+	}
+	if err := rates.Set(code, from, perUSD); err != nil {
+		return err
+	}
+	fmt.Printf("%s 1 USD = %s %s from %s\n", statusOK, strconv.FormatFloat(perUSD, 'f', -1, 64), code, from.Format(time.DateOnly))
+	return nil
+}
+
+// runCurrencyDisplay shows or sets the currency stats and the dashboard show
+// costs in
+func runCurrencyDisplay(home string, rates *currency.Rates, args []string) error {
+	ctx := context.Background()
+	current, err := settings.Load(ctx, home)
+	if err != nil {
+		return err
+	}
+	switch len(args) {
+	case 0:
+		code, _ := currency.Normalize(current.DisplayCurrency) //nolint:errcheck // validated by settings.Save
+		fmt.Printf("Display currency: %s\n", code)
+		return nil
+	case 1:
+	default:
+		return errors.New(currencyUsage)
+	}
+
+	code, err := currency.Normalize(args[0])
+	if err != nil {
+		return err
+	}
+	if _, err := rates.PerUSD(code, time.Now()); err != nil {
+		return err
+	}
+	current.DisplayCurrency = code
+	if err := settings.Save(ctx, home, current); err != nil {
+		return err
+	}
+	fmt.Printf("%s Stats and the dashboard show costs in %s; reports stay in USD\n", statusOK, code)
+	return nil
+}
+
+// listRates prints the stored rates, the one in effect today marked
+func listRates(home string, rates *currency.Rates) error {
+	list, err := rates.List()
+	if err != nil {
+		return err
+	}
+	display := currency.USD
+	if s, err := settings.Load(context.Background(), home); err == nil && s.DisplayCurrency != "" {
+		display = s.DisplayCurrency
+	}
+	fmt.Printf("Display currency: %s\n", display)
+	unpriced, err := rates.UnpricedUsage()
+	if err != nil {
+		return err
+	}
+	codes := make([]string, 0, len(unpriced))
+	for code := range unpriced {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Printf("%s %d request(s) priced in %s were recorded at no cost for want of a rate; set one with 'boba currency set %s <per-usd>'\n",
+			statusWarning, unpriced[code], code, code)
+	}
+	if len(list) == 0 {
+		fmt.Println("No exchange rates. Add one with 'boba currency set CNY 7.12'.")
+		return nil
+	}
+
+	var (
+		headerStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("99")).
+				Padding(0, 1)
+
+		cellStyle = lipgloss.NewStyle().
+				Padding(0, 1)
+	)
+
+	now := time.Now()
+	rows := make([][]string, 0, len(list))
+	inEffect := make(map[string]bool)
+	for _, rate := range list {
+		status := ""
+		switch {
+		case rate.EffectiveFrom.After(now):
+			status = "upcoming"
+		case !inEffect[rate.Currency]:
+			status = "in effect"
+			inEffect[rate.Currency] = true
+		}
+		rows = append(rows, []string{
+			rate.Currency,
+			rate.EffectiveFrom.Format(time.DateOnly),
+			strconv.FormatFloat(rate.PerUSD, 'f', -1, 64),
+			rate.UpdatedAt.Format("2006-01-02 15:04"),
+			status,
+		})
+	}
+
+	t := table.New().
+		Border(lipgloss.HiddenBorder()).
+		Headers("CURRENCY", "FROM", "PER USD", "UPDATED", "STATUS").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return headerStyle
+			}
+			return cellStyle
+		})
+	fmt.Println()
+	fmt.Println(t)
+	return nil
+}
+
+// loadDisplayCurrency returns how stats show costs: in the currency given, or
+// else the display currency of settings.yaml, at today's rate. A display
+// currency without a rate falls back to USD with a warning.
+func loadDisplayCurrency(home string, db *sqlite.DB, code string) (currency.Display, error) {
+	rates := currency.NewRates(db)
+	if code != "" {
+		return rates.Display(code, time.Now())
+	}
+	s, err := settings.Load(context.Background(), home)
+	if err != nil {
+		logging.Warn("Settings not loaded, showing costs in USD", logging.Err(err))
+		return currency.Display{}, nil
+	}
+	if currency.IsUSD(s.DisplayCurrency) {
+		return currency.Display{}, nil
+	}
+	display, err := rates.Display(s.DisplayCurrency, time.Now())
+	if err != nil {
+		logging.Warn("Showing costs in USD", logging.String("display_currency", s.DisplayCurrency), logging.Err(err))
+		fmt.Printf("%s %v; showing USD\n", statusWarning, err)
+		return currency.Display{}, nil
+	}
+	return display, nil
+}
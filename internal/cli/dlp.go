@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/dlp"
+	"github.com/royisme/bobamixer/internal/logging"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+// runDLP handles outbound secret/PII scanning commands
+func runDLP(home string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("dlp subcommand required: scan, findings, rules")
+	}
+
+	switch args[0] {
+	case "scan":
+		return runDLPScan(home, args[1:])
+	case "findings":
+		return runDLPFindings(home, args[1:])
+	case "rules":
+		return runDLPRules(home)
+	default:
+		return fmt.Errorf("unknown dlp subcommand: %s", args[0])
+	}
+}
+
+// loadDLPScanner builds a scanner from dlp.yaml
+func loadDLPScanner(home string) (*dlp.Config, *dlp.Scanner, error) {
+	cfg, err := dlp.Load(home)
+	if err != nil {
+		return nil, nil, err
+	}
+	scanner, err := dlp.NewScanner(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid dlp.yaml: %w", err)
+	}
+	return cfg, scanner, nil
+}
+
+// runDLPScan scans a file (or stdin) with the configured rules, as the proxy would
+func runDLPScan(home string, args []string) error {
+	_, scanner, err := loadDLPScanner(home)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if len(args) > 0 && args[0] != "-" {
+		data, err = os.ReadFile(args[0]) // #nosec G304 -- user-specified file to scan
+	} else {
+		data, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return fmt.Errorf("read input: %w", err)
+	}
+
+	result := scanner.ScanBody(data)
+	if len(result.Findings) == 0 {
+		fmt.Printf("%s No secrets or PII found\n", statusOK)
+		return nil
+	}
+
+	for _, f := range result.Findings {
+		symbol := statusWarning
+		if f.Action == dlp.ActionBlock {
+			symbol = statusError
+		}
+		fmt.Printf("%s %-20s %-6s %-5s %s  %s\n", symbol, f.RuleID, f.Kind, f.Action, f.Location, f.Excerpt)
+	}
+	if result.Blocked != nil {
+		return fmt.Errorf("the proxy would block this request (rule %s)", result.Blocked.RuleID)
+	}
+	return nil
+}
+
+// runDLPFindings lists findings recorded by the proxy, newest first
+func runDLPFindings(home string, args []string) error {
+	flags := flag.NewFlagSet("dlp findings", flag.ContinueOnError)
+	sessionID := flags.String("session", "", "only show findings for this session")
+	limit := flags.Int("limit", 50, "maximum number of findings to show")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := sqlite.Open(filepath.Join(home, "usage.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	where := ""
+	if *sessionID != "" {
+		where = fmt.Sprintf("WHERE session_id = '%s'", strings.ReplaceAll(*sessionID, "'", "''"))
+	}
+	rows, err := db.QueryRows(fmt.Sprintf(`SELECT ts, session_id, COALESCE(tool, ''), rule_id, action, COALESCE(location, ''), COALESCE(excerpt, '')
+		FROM dlp_findings %s ORDER BY ts DESC, id DESC LIMIT %d;`, where, *limit))
+	if err != nil {
+		return fmt.Errorf("failed to query dlp findings: %w", err)
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No DLP findings recorded.")
+		return nil
+	}
+
+	fmt.Println("DLP Findings")
+	fmt.Println("============")
+	for _, row := range rows {
+		parts := strings.SplitN(row, "|", 7)
+		if len(parts) < 7 {
+			continue
+		}
+		var ts int64
+		if _, err := fmt.Sscanf(parts[0], "%d", &ts); err != nil {
+			logging.Warn("Skipping malformed dlp finding row", logging.Err(err))
+			continue
+		}
+		tool := parts[2]
+		if tool == "" {
+			tool = "unknown"
+		}
+		fmt.Printf("%s  %-24s %-10s %-20s %-5s %s  %s\n",
+			time.Unix(ts, 0).Format("2006-01-02 15:04:05"), parts[1], tool, parts[3], parts[4], parts[5], parts[6])
+	}
+	return nil
+}
+
+// runDLPRules prints the effective rule set after merging dlp.yaml over the built-ins
+func runDLPRules(home string) error {
+	cfg, scanner, err := loadDLPScanner(home)
+	if err != nil {
+		return err
+	}
+
+	if !cfg.IsEnabled() {
+		fmt.Println("DLP scanning is disabled in dlp.yaml")
+	}
+	fmt.Println("Active DLP Rules")
+	fmt.Println("================")
+	for _, rule := range scanner.Rules() {
+		fmt.Printf("  %-20s %-6s %s\n", rule.ID, rule.Kind, rule.Action)
+	}
+	if cfg.Entropy.Enabled == nil || *cfg.Entropy.Enabled {
+		action := cfg.Entropy.Action
+		if action == "" {
+			action = dlp.ActionWarn
+		}
+		fmt.Printf("  %-20s %-6s %s\n", dlp.EntropyRuleID, dlp.KindSecret, action)
+	}
+	return nil
+}
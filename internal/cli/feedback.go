@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/royisme/bobamixer/internal/domain/bandit"
+	"github.com/royisme/bobamixer/internal/domain/session"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+const feedbackUsage = "usage: boba feedback [last|<session-id>] good|bad [--note text]"
+
+// runFeedback rates a session good or bad. The session defaults to the last one
+// and may be given by a unique ID prefix; --note may come before or after.
+func runFeedback(home string, args []string) error {
+	flags := flag.NewFlagSet("feedback", flag.ContinueOnError)
+	note := flags.String("note", "", "why the result was good or bad")
+	flags.SetOutput(io.Discard)
+
+	// Collect positionals around the flags
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	ref := session.LastRef
+	switch len(positional) {
+	case 1:
+	case 2:
+		ref = positional[0]
+	default:
+		return errors.New(feedbackUsage)
+	}
+	rating, err := session.ParseRating(positional[len(positional)-1])
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, feedbackUsage)
+	}
+
+	db, err := sqlite.Open(filepath.Join(home, "usage.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	id, err := session.ResolveID(db, ref)
+	if err != nil {
+		return err
+	}
+	if err := session.Rate(db, session.Feedback{
+		SessionID: id,
+		Rating:    rating,
+		Note:      *note,
+		Source:    session.FeedbackSourceCLI,
+	}); err != nil {
+		return fmt.Errorf("save feedback: %w", err)
+	}
+
+	// Routed sessions feed the rating straight back into the bandit
+	if _, err := bandit.NewStore(db).Learn(bandit.DefaultWeights); err != nil {
+		fmt.Printf("%s bandit not updated: %v\n", statusWarning, err)
+	}
+
+	fmt.Printf("%s Rated session %s %s\n", statusOK, shortID(id), session.RatingLabel(rating))
+	return nil
+}
+
+// shortID abbreviates a session ID for display; any unique prefix resolves back
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/core"
+	"github.com/royisme/bobamixer/internal/mock"
+	"github.com/royisme/bobamixer/internal/transcript"
+	"github.com/royisme/bobamixer/internal/ui/keys"
+)
+
+// mockAPIKey is the placeholder credential stored for registered mock providers
+const mockAPIKey = "mock-key"
+
+// runMock handles the offline mock provider server
+func runMock(home string, args []string) error {
+	if len(args) == 0 || args[0] != "serve" {
+		return fmt.Errorf("usage: boba mock serve [--addr ADDR] [--fixtures FILE] [--transcripts PATH] [--fault 429|529|timeout --fault-rate P] [--register]")
+	}
+	return runMockServe(home, args[1:])
+}
+
+// runMockServe starts a local server speaking the OpenAI, Anthropic and Gemini APIs
+func runMockServe(home string, args []string) error {
+	flags := flag.NewFlagSet("mock serve", flag.ContinueOnError)
+	addr := flags.String("addr", mock.DefaultAddr, "listen address")
+	fixtures := flags.String("fixtures", filepath.Join(home, "mock.yaml"), "YAML fixtures file")
+	transcripts := flags.String("transcripts", "", "recorded proxy transcripts to replay (file or directory)")
+	fault := flags.String("fault", "", "inject an error: an HTTP status such as 429 or 529, or timeout")
+	faultRate := flags.Float64("fault-rate", 0, "probability (0-1) of injecting --fault")
+	seed := flags.Int64("seed", 0, "seed for fault injection (0 = random)")
+	register := flags.Bool("register", false, "add mock-openai, mock-anthropic and mock-gemini to providers.yaml")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := mock.Load(*fixtures)
+	if err != nil {
+		return err
+	}
+	if *fault != "" {
+		extra, err := parseMockFault(*fault, *faultRate)
+		if err != nil {
+			return err
+		}
+		config.Faults = append(config.Faults, extra)
+		if err := config.Compile(); err != nil {
+			return err
+		}
+	}
+
+	var recorded []transcript.Entry
+	if *transcripts != "" {
+		recorded, err = transcript.Read(*transcripts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *register {
+		if err := registerMockProviders(home, *addr); err != nil {
+			return err
+		}
+	}
+
+	server := mock.NewServer(mock.Options{Config: config, Transcripts: recorded, Seed: *seed})
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Printf("✓ Mock provider server listening on http://%s\n", *addr)
+	fmt.Printf("  Fixtures:    %s (%d rules)\n", *fixtures, len(config.Rules))
+	if *transcripts != "" {
+		fmt.Printf("  Transcripts: %d recorded prompts\n", server.TranscriptCount())
+	}
+	for _, f := range config.Faults {
+		if f.Timeout > 0 {
+			fmt.Printf("  Fault:       timeout %s at %.0f%%\n", f.Timeout, f.Probability*100)
+		} else {
+			fmt.Printf("  Fault:       HTTP %d at %.0f%%\n", f.Status, f.Probability*100)
+		}
+	}
+	fmt.Println("\nEndpoints:")
+	fmt.Println("  POST /v1/chat/completions, /v1/responses     (OpenAI)")
+	fmt.Println("  POST /v1/messages                            (Anthropic)")
+	fmt.Println("  POST /v1beta/models/{model}:generateContent  (Gemini)")
+	if *register {
+		fmt.Println("\nRegistered providers: mock-openai, mock-anthropic, mock-gemini")
+		fmt.Println("  Bind a tool with: boba bind <tool> mock-anthropic")
+	}
+	fmt.Printf("\nPress %s to stop...\n", keys.CtrlC)
+
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("mock server: %w", err)
+	}
+	return nil
+}
+
+// parseMockFault turns --fault/--fault-rate into a fault definition
+func parseMockFault(spec string, rate float64) (mock.Fault, error) {
+	if rate <= 0 {
+		return mock.Fault{}, fmt.Errorf("--fault requires --fault-rate between 0 and 1")
+	}
+	if spec == "timeout" {
+		return mock.Fault{Timeout: 2 * time.Minute, Probability: rate}, nil
+	}
+	var status int
+	if _, err := fmt.Sscanf(spec, "%d", &status); err != nil {
+		return mock.Fault{}, fmt.Errorf("invalid --fault %q (use an HTTP status or timeout)", spec)
+	}
+	return mock.Fault{Status: status, Probability: rate}, nil
+}
+
+// registerMockProviders adds or updates providers pointing at the mock server,
+// so bindings, failover and budgets can be exercised end to end
+func registerMockProviders(home, addr string) error {
+	providers, err := core.LoadProviders(home)
+	if err != nil {
+		return err
+	}
+	secrets, err := core.LoadSecrets(home)
+	if err != nil {
+		return err
+	}
+
+	base := "http://" + addr
+	entries := []core.Provider{
+		{ID: "mock-openai", Kind: core.ProviderKindOpenAICompatible, DisplayName: "Mock (OpenAI)", BaseURL: base + "/v1"},
+		{ID: "mock-anthropic", Kind: core.ProviderKindAnthropicCompatible, DisplayName: "Mock (Anthropic)", BaseURL: base},
+		{ID: "mock-gemini", Kind: core.ProviderKindGemini, DisplayName: "Mock (Gemini)", BaseURL: base},
+	}
+
+	for _, entry := range entries {
+		entry.APIKey = core.APIKeyConfig{Source: core.APIKeySourceSecrets}
+		entry.DefaultModel = "mock-model"
+		entry.Enabled = true
+
+		replaced := false
+		for i := range providers.Providers {
+			if providers.Providers[i].ID == entry.ID {
+				providers.Providers[i] = entry
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			providers.Providers = append(providers.Providers, entry)
+		}
+
+		if secrets.Secrets == nil {
+			secrets.Secrets = make(map[string]core.Secret)
+		}
+		secret := secrets.Secrets[entry.ID]
+		if secret.APIKey == "" {
+			secret.APIKey = mockAPIKey
+		}
+		secrets.Secrets[entry.ID] = secret
+	}
+
+	if err := core.SaveProviders(home, providers); err != nil {
+		return err
+	}
+	return core.SaveSecrets(home, secrets)
+}
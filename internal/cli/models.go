@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+
+	"github.com/royisme/bobamixer/internal/domain/catalog"
+	"github.com/royisme/bobamixer/internal/domain/core"
+	"github.com/royisme/bobamixer/internal/logging"
+)
+
+// discoveryTimeout bounds model discovery for a single provider
+const discoveryTimeout = 30 * time.Second
+
+// runProviderModels lists the models a provider offers upstream
+func runProviderModels(home string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: boba providers models <provider> [--refresh]")
+	}
+	providerID := args[0]
+
+	flags := flag.NewFlagSet("providers models", flag.ContinueOnError)
+	refresh := flags.Bool("refresh", false, "ignore the cached model list")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	logging.Info("Running providers models command", logging.String("provider", providerID))
+
+	providers, err := core.LoadProviders(home)
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+	provider, err := providers.FindProvider(providerID)
+	if err != nil {
+		return fmt.Errorf("provider not found: %s\nRun 'boba providers' to list available providers", providerID)
+	}
+	secrets, err := core.LoadSecrets(home)
+	if err != nil {
+		return fmt.Errorf("failed to load secrets: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+	listing, err := catalog.New(home, 0).List(ctx, provider, secrets, *refresh)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Models offered by %s\n", provider.ID)
+	if len(listing.Models) == 0 {
+		fmt.Println("  The provider returned no models.")
+		return nil
+	}
+
+	var (
+		headerStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("99")).
+				Padding(0, 1)
+
+		cellStyle = lipgloss.NewStyle().
+				Padding(0, 1)
+	)
+
+	rows := make([][]string, 0, len(listing.Models))
+	for _, m := range listing.Models {
+		name := m.DisplayName
+		if name == "" {
+			name = "-"
+		}
+		marker := ""
+		if m.ID == provider.DefaultModel {
+			marker = "default"
+		}
+		rows = append(rows, []string{m.ID, name, marker})
+	}
+
+	t := table.New().
+		Border(lipgloss.HiddenBorder()).
+		Headers("MODEL", "NAME", "").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return headerStyle
+			}
+			return cellStyle
+		})
+
+	fmt.Println(t)
+	fmt.Println()
+	fetched := fmt.Sprintf("%d models, fetched %s", len(listing.Models), listing.FetchedAt.Format("2006-01-02 15:04"))
+	if listing.Stale {
+		fetched += " (cached; refresh failed)"
+	}
+	fmt.Println(fetched)
+	return nil
+}
+
+// checkBindingModels reports binding model overrides and mapping targets that the bound
+// provider does not list upstream. It returns whether any warnings or errors were found.
+func checkBindingModels(home string, providers *core.ProvidersConfig, bindings *core.BindingsConfig) (hasWarnings, hasErrors bool) {
+	secrets, err := core.LoadSecrets(home)
+	if err != nil {
+		secrets = &core.SecretsConfig{}
+	}
+	models := catalog.New(home, 0)
+
+	checked := 0
+	for _, binding := range bindings.Bindings {
+		targets := bindingModelTargets(binding)
+		if len(targets) == 0 {
+			continue
+		}
+		provider, err := providers.FindProvider(binding.ProviderID)
+		if err != nil {
+			continue // Already reported as an unknown provider
+		}
+		checked++
+
+		ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+		listing, err := models.List(ctx, provider, secrets, false)
+		cancel()
+		if err != nil {
+			fmt.Printf("  %s %s: could not list models for %s: %v\n", statusWarning, binding.ToolID, provider.ID, err)
+			hasWarnings = true
+			continue
+		}
+
+		missing := 0
+		for _, target := range targets {
+			if !listing.Has(target.model) {
+				fmt.Printf("  %s %s: %s %q is not offered by %s\n", statusError, binding.ToolID, target.source, target.model, provider.ID)
+				hasErrors = true
+				missing++
+			}
+		}
+		if missing == 0 {
+			fmt.Printf("  %s %s: %d model(s) available on %s\n", statusOK, binding.ToolID, len(targets), provider.ID)
+		}
+	}
+
+	if checked == 0 {
+		fmt.Printf("  %s No binding model overrides to check\n", statusOK)
+	}
+	return hasWarnings, hasErrors
+}
+
+// bindingModelTarget is a model a binding sends upstream, with where it was configured
+type bindingModelTarget struct {
+	source string
+	model  string
+}
+
+// bindingModelTargets returns the binding's model override and mapping targets in a stable order
+func bindingModelTargets(binding core.Binding) []bindingModelTarget {
+	var targets []bindingModelTarget
+	if binding.Options.Model != "" {
+		targets = append(targets, bindingModelTarget{source: "model", model: binding.Options.Model})
+	}
+	tiers := make([]string, 0, len(binding.Options.ModelMapping))
+	for tier := range binding.Options.ModelMapping {
+		tiers = append(tiers, tier)
+	}
+	sort.Strings(tiers)
+	for _, tier := range tiers {
+		if model := binding.Options.ModelMapping[tier]; model != "" {
+			targets = append(targets, bindingModelTarget{source: "model_mapping." + tier, model: model})
+		}
+	}
+	return targets
+}
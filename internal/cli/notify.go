@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/domain/suggestions"
+	"github.com/royisme/bobamixer/internal/notifications"
+	"github.com/royisme/bobamixer/internal/settings"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+	"github.com/royisme/bobamixer/internal/ui/keys"
+)
+
+const notifyUsage = "usage: boba notify run [--once] | boba notify test [--sink NAME]"
+
+// runNotify delivers budget alerts and suggestions to the sinks configured
+// under notifications in settings.yaml
+func runNotify(home string, args []string) error {
+	if len(args) == 0 {
+		return errors.New(notifyUsage)
+	}
+	switch args[0] {
+	case "run":
+		return runNotifyRun(home, args[1:])
+	case "test":
+		return runNotifyTest(home, args[1:])
+	default:
+		return fmt.Errorf("unknown notify subcommand: %s\n%s", args[0], notifyUsage)
+	}
+}
+
+// loadNotificationRoutes reads the notification settings and builds their sinks
+func loadNotificationRoutes(home string) (settings.NotificationSettings, []*notifications.Route, error) {
+	userSettings, err := settings.Load(context.Background(), home)
+	if err != nil {
+		return settings.NotificationSettings{}, nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	cfg := userSettings.Notifications
+	routes, err := notifications.NewRoutes(cfg.Sinks)
+	if err != nil {
+		return cfg, nil, err
+	}
+	return cfg, routes, nil
+}
+
+// newNotifier opens the usage database for a background notifier
+func newNotifier(home string) (*notifications.Notifier, error) {
+	db, err := sqlite.Open(filepath.Join(home, "usage.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return notifications.NewNotifier(db, suggestions.NewEngine(db), loadAlertConfig(home)), nil
+}
+
+// runNotifyRun polls in the foreground until interrupted, or once with --once
+// for running from cron
+func runNotifyRun(home string, args []string) error {
+	flags := flag.NewFlagSet("notify run", flag.ContinueOnError)
+	once := flags.Bool("once", false, "deliver pending notifications once and exit")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, routes, err := loadNotificationRoutes(home)
+	if err != nil {
+		return err
+	}
+	if len(routes) == 0 {
+		return fmt.Errorf("no notification sinks configured; add notifications.sinks to %s", filepath.Join(home, "settings.yaml"))
+	}
+	notifier, err := newNotifier(home)
+	if err != nil {
+		return err
+	}
+
+	if *once {
+		sent, err := notifier.Dispatch(context.Background(), routes, time.Now())
+		fmt.Printf("%s Delivered %d notification(s)\n", statusOK, sent)
+		return err
+	}
+
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = notifications.DefaultInterval
+	}
+	fmt.Printf("%s Delivering notifications to %d sink(s) every %s\n", statusOK, len(routes), interval)
+	fmt.Printf("\nPress %s to stop...\n", keys.CtrlC)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	notifier.Run(ctx, routes, interval)
+	return nil
+}
+
+// runNotifyTest sends a test notification to every sink, or just one,
+// regardless of levels, quiet hours and earlier deliveries
+func runNotifyTest(home string, args []string) error {
+	flags := flag.NewFlagSet("notify test", flag.ContinueOnError)
+	sinkName := flags.String("sink", "", "only test this sink")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	_, routes, err := loadNotificationRoutes(home)
+	if err != nil {
+		return err
+	}
+	if *sinkName != "" {
+		var selected []*notifications.Route
+		for _, route := range routes {
+			if route.Name == *sinkName {
+				selected = append(selected, route)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("no notification sink named %q", *sinkName)
+		}
+		routes = selected
+	}
+	if len(routes) == 0 {
+		return fmt.Errorf("no notification sinks configured; add notifications.sinks to %s", filepath.Join(home, "settings.yaml"))
+	}
+
+	event := notifications.Event{
+		Key:       "test",
+		Type:      "test",
+		Level:     budget.AlertLevelInfo.String(),
+		Title:     "Test notification",
+		Message:   "BobaMixer notifications reach this sink.",
+		Timestamp: time.Now(),
+	}
+	failed := 0
+	for _, route := range routes {
+		if err := route.Sink.Send(context.Background(), event); err != nil {
+			fmt.Printf("%s %s (%s): %v\n", statusError, route.Name, route.Type, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s %s (%s)\n", statusOK, route.Name, route.Type)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d sink(s) failed", failed, len(routes))
+	}
+	return nil
+}
+
+// startProxyNotifier delivers notifications in the background while the proxy
+// runs, when notifications.enabled is set, returning the number of sinks
+func startProxyNotifier(home string) (int, error) {
+	cfg, routes, err := loadNotificationRoutes(home)
+	if err != nil {
+		return 0, err
+	}
+	if !cfg.Enabled || len(routes) == 0 {
+		return 0, nil
+	}
+	notifier, err := newNotifier(home)
+	if err != nil {
+		return 0, err
+	}
+	go notifier.Run(context.Background(), routes, time.Duration(cfg.IntervalSeconds)*time.Second)
+	return len(routes), nil
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+
+	"github.com/royisme/bobamixer/internal/domain/core"
+	"github.com/royisme/bobamixer/internal/domain/reconcile"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+const reconcileUsage = "usage: boba reconcile import <file> [--provider ID] [--tolerance PCT] [--all] [--store]"
+
+// runReconcile compares tracked spending with provider billing exports
+func runReconcile(home string, args []string) error {
+	if len(args) == 0 {
+		return errors.New(reconcileUsage)
+	}
+	switch args[0] {
+	case "import":
+		return runReconcileImport(home, args[1:])
+	default:
+		return fmt.Errorf("unknown reconcile subcommand: %s\n%s", args[0], reconcileUsage)
+	}
+}
+
+// runReconcileImport reads an OpenAI, Anthropic or OpenRouter export, reports
+// where it disagrees with the usage records by day and model, and with
+// --store keeps its figures as the billed layer
+func runReconcileImport(home string, args []string) error {
+	flags := flag.NewFlagSet("reconcile import", flag.ContinueOnError)
+	provider := flags.String("provider", "", "only compare usage recorded for this provider ID (default: the providers the export's source bills for)")
+	tolerance := flags.Float64("tolerance", reconcile.DefaultTolerance*100, "difference in percent still counted as a match")
+	all := flags.Bool("all", false, "list matching days and models too")
+	store := flags.Bool("store", false, "save the export as billed usage for boba stats --source billed")
+	flags.SetOutput(io.Discard)
+
+	// The file may come before or after the flags
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(positional) != 1 {
+		return errors.New(reconcileUsage)
+	}
+	if *tolerance < 0 {
+		return errors.New("--tolerance must not be negative")
+	}
+	path := positional[0]
+
+	f, err := os.Open(path) //nolint:gosec // the user names the export to import
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck // read-only
+	export, err := reconcile.Parse(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if len(export.Lines) == 0 {
+		return fmt.Errorf("%s: no usage rows", filepath.Base(path))
+	}
+
+	db, err := sqlite.Open(filepath.Join(home, "usage.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	// Without --provider, compare with the providers the export's source bills
+	// for; usage of other providers would otherwise show up as unbilled
+	providerIDs := []string{*provider}
+	if *provider == "" {
+		var configured []core.Provider
+		if providers, err := core.LoadProviders(home); err == nil {
+			configured = providers.Providers
+		}
+		providerIDs = reconcile.SourceProviders(export.Source, configured)
+	}
+	tracked, err := reconcile.Tracked(db, export.From(), export.To(), providerIDs)
+	if err != nil {
+		return err
+	}
+	report := reconcile.Compare(export, tracked, *tolerance/100)
+
+	title := fmt.Sprintf("Reconciliation: %s %s export, %s to %s", export.Source, export.Format, report.From, report.To)
+	fmt.Println(title)
+	fmt.Println(strings.Repeat("=", len(title)))
+	fmt.Printf("Compared with usage recorded for provider %s\n", strings.Join(providerIDs, ", "))
+	printReconcileItems(report, *all)
+
+	fmt.Println()
+	if report.ByCost {
+		diff := report.Billed.Cost - report.Tracked.Cost
+		fmt.Printf("Cost:     tracked $%.4f, billed $%.4f (%s", report.Tracked.Cost, report.Billed.Cost, signedCost(diff))
+		if report.Billed.Cost > 0 {
+			fmt.Printf(", %+.1f%% of billed", diff/report.Billed.Cost*100)
+		}
+		fmt.Println(")")
+	} else {
+		fmt.Printf("Tokens:   tracked %d, billed %d (%+d)\n",
+			report.Tracked.Tokens(), report.Billed.Tokens(), report.Billed.Tokens()-report.Tracked.Tokens())
+		fmt.Println("This export has no costs; days and models are compared by tokens.")
+	}
+
+	counts := report.Counts()
+	fmt.Printf("%s %d of %d day/model pair(s) match within %.0f%%\n", statusOK, counts[reconcile.StatusMatch], len(report.Items), *tolerance)
+	if n := counts[reconcile.StatusUntracked]; n > 0 {
+		fmt.Printf("%s %d billed but never tracked: usage outside the proxy or another provider ID\n", statusWarning, n)
+	}
+	if n := counts[reconcile.StatusUnder]; n > 0 {
+		fmt.Printf("%s %d tracked below billing: estimated prices or missed streams\n", statusWarning, n)
+	}
+	if n := counts[reconcile.StatusOver]; n > 0 {
+		fmt.Printf("%s %d tracked above billing: stale prices or requests that failed upstream\n", statusWarning, n)
+	}
+	if n := counts[reconcile.StatusUnbilled]; n > 0 {
+		fmt.Printf("%s %d tracked but not in this export: another account, or pick the provider with --provider\n", statusWarning, n)
+	}
+
+	if *store {
+		if err := reconcile.Store(db, export); err != nil {
+			return err
+		}
+		fmt.Printf("%s Stored %d billed line(s); see 'boba stats --source billed'\n", statusOK, len(export.Lines))
+	}
+	return nil
+}
+
+// printReconcileItems lists the days and models that do not match, or all of them
+func printReconcileItems(report *reconcile.Report, all bool) {
+	var (
+		headerStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("99")).
+				Padding(0, 1)
+
+		cellStyle = lipgloss.NewStyle().
+				Padding(0, 1)
+	)
+
+	var rows [][]string
+	for _, item := range report.Items {
+		if item.Status == reconcile.StatusMatch && !all {
+			continue
+		}
+		model := item.Model
+		if model == "" {
+			model = "(unknown)"
+		}
+		tracked, billed := fmt.Sprintf("%d", item.Tracked.Tokens()), fmt.Sprintf("%d", item.Billed.Tokens())
+		diff := fmt.Sprintf("%+.0f", item.Diff(false))
+		if report.ByCost {
+			tracked, billed = fmt.Sprintf("$%.4f", item.Tracked.Cost), fmt.Sprintf("$%.4f", item.Billed.Cost)
+			diff = signedCost(item.Diff(true))
+		}
+		rows = append(rows, []string{item.Day, model, tracked, billed, diff, item.Status})
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	t := table.New().
+		Border(lipgloss.HiddenBorder()).
+		Headers("DAY", "MODEL", "TRACKED", "BILLED", "DIFF", "STATUS").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return headerStyle
+			}
+			return cellStyle
+		})
+	fmt.Println()
+	fmt.Println(t)
+}
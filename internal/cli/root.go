@@ -17,12 +17,17 @@ import (
 	"time"
 
 	"github.com/royisme/bobamixer/internal/adapters"
+	"github.com/royisme/bobamixer/internal/domain/bandit"
 	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/domain/catalog"
 	"github.com/royisme/bobamixer/internal/domain/core"
+	"github.com/royisme/bobamixer/internal/domain/currency"
+	"github.com/royisme/bobamixer/internal/domain/forecast"
 	"github.com/royisme/bobamixer/internal/domain/hooks"
 	"github.com/royisme/bobamixer/internal/domain/routing"
 	"github.com/royisme/bobamixer/internal/domain/stats"
 	"github.com/royisme/bobamixer/internal/domain/suggestions"
+	"github.com/royisme/bobamixer/internal/domain/tokenizer"
 	"github.com/royisme/bobamixer/internal/logging"
 	"github.com/royisme/bobamixer/internal/settings"
 	"github.com/royisme/bobamixer/internal/store/config"
@@ -87,6 +92,12 @@ func Run(args []string) error {
 		return err
 	}
 
+	// Tokenizer vocabularies not compiled into the binary can be dropped in here
+	tokenizer.SetVocabDir(filepath.Join(home, "tokenizers"))
+	if _, err := tokenizer.LoadCalibration(filepath.Join(home, tokenizer.CalibrationFile)); err != nil {
+		logging.Warn("Ignoring tokenizer calibration", logging.Err(err))
+	}
+
 	// Handle help flag
 	if len(args) > 0 && (args[0] == "--help" || args[0] == "-h" || args[0] == "help") {
 		printUsage()
@@ -113,6 +124,14 @@ func Run(args []string) error {
 		return runSecrets(home, args[1:])
 	case "proxy":
 		return runProxy(home, args[1:])
+	case "dlp":
+		return runDLP(home, args[1:])
+	case "mock":
+		return runMock(home, args[1:])
+	case "compare":
+		return runCompare(home, args[1:])
+	case "tokenizer":
+		return runTokenizer(home, args[1:])
 
 	// Legacy Profile Commands
 	case "ls":
@@ -139,6 +158,14 @@ func Run(args []string) error {
 		return runInit(home, args[1:])
 	case "route":
 		return runRoute(home, args[1:])
+	case "feedback":
+		return runFeedback(home, args[1:])
+	case "notify":
+		return runNotify(home, args[1:])
+	case "reconcile":
+		return runReconcile(home, args[1:])
+	case "currency":
+		return runCurrency(home, args[1:])
 	case "completions":
 		return runCompletions(args[1:])
 	case "suggest":
@@ -165,9 +192,14 @@ func printUsage() {
 	fmt.Println("Non-Interactive Commands:")
 	fmt.Println("  boba run <tool> [args]               Run a bound CLI tool")
 	fmt.Println("  boba call --profile <p> --data @file Execute an API call")
+	fmt.Println("  boba call --auto --data @file        Route an API call through routes.yaml")
 	fmt.Println()
 	fmt.Println("Quick Stats:")
 	fmt.Println("  boba stats [--today|--7d|--30d]     Show usage statistics")
+	fmt.Println("  boba feedback [last|<id>] good|bad  Rate a session's result")
+	fmt.Println("  boba notify run|test                Deliver alerts to notification sinks")
+	fmt.Println("  boba reconcile import <file>        Compare spending with a billing export")
+	fmt.Println("  boba currency set <code> <per-usd>  Store an exchange rate for prices and budgets")
 	fmt.Println()
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println()
@@ -253,6 +285,8 @@ func runStats(home string, args []string) error {
 	days7 := flags.Bool("7d", false, "show last 7 days")
 	days30 := flags.Bool("30d", false, "show last 30 days")
 	byProfile := flags.Bool("by-profile", false, "breakdown by profile")
+	source := flags.String("source", "tracked", "tracked usage, or billed usage imported with boba reconcile")
+	currencyFlag := flags.String("currency", "", "show costs in this currency instead of the display currency")
 	flags.SetOutput(io.Discard)
 	if err := flags.Parse(args); err != nil {
 		return err
@@ -264,19 +298,42 @@ func runStats(home string, args []string) error {
 	if err != nil {
 		return err
 	}
+	display, err := loadDisplayCurrency(home, db, *currencyFlag)
+	if err != nil {
+		return err
+	}
+
+	switch *source {
+	case "tracked":
+	case "billed":
+		days := 0
+		switch {
+		case *days7:
+			days = 7
+		case *days30:
+			days = 30
+		}
+		return showBilledStats(ctx, db, days, display)
+	default:
+		return fmt.Errorf("unknown --source %q: want tracked or billed", *source)
+	}
 
 	if *today {
 		summary, err := stats.Today(ctx, db)
 		if err != nil {
 			return err
 		}
-		printTodaySummary(summary)
-		return nil
+		printTodaySummary(summary, display)
+		now := time.Now()
+		if err := showCacheSavings(ctx, db, now, now, display); err != nil {
+			return err
+		}
+		return showSatisfaction(ctx, db, now, now)
 	}
 
 	if *days7 {
 		start := time.Now()
-		err := showWindowStats(ctx, db, 7, *byProfile)
+		err := showWindowStats(ctx, db, 7, *byProfile, display)
 		logCommandDuration("stats", start, statsSlowThreshold,
 			logging.String("window", "7d"),
 			logging.Bool("by_profile", *byProfile),
@@ -285,10 +342,10 @@ func runStats(home string, args []string) error {
 	}
 
 	if *days30 {
-		return showWindowStats(ctx, db, 30, *byProfile)
+		return showWindowStats(ctx, db, 30, *byProfile, display)
 	}
 
-	return runStats(home, []string{"--today"})
+	return runStats(home, append(args, "--today"))
 }
 
 func logCommandDuration(command string, start time.Time, threshold time.Duration, extra ...zap.Field) {
@@ -308,14 +365,14 @@ func logCommandDuration(command string, start time.Time, threshold time.Duration
 	}
 }
 
-func showWindowStats(ctx context.Context, db *sqlite.DB, days int, byProfile bool) error {
+func showWindowStats(ctx context.Context, db *sqlite.DB, days int, byProfile bool, display currency.Display) error {
 	to := time.Now()
 	from := to.AddDate(0, 0, -days)
 	summary, err := stats.Window(ctx, db, from, to)
 	if err != nil {
 		return err
 	}
-	printWindowSummary(days, summary)
+	printWindowSummary(days, summary, display)
 
 	latencies, err := stats.P95Latency(ctx, db, time.Duration(days)*24*time.Hour, byProfile)
 	if err != nil {
@@ -329,6 +386,13 @@ func showWindowStats(ctx context.Context, db *sqlite.DB, days int, byProfile boo
 		printP95Latency(latencies)
 	}
 
+	if err := showCacheSavings(ctx, db, from, to, display); err != nil {
+		return err
+	}
+	if err := showSatisfaction(ctx, db, from, to); err != nil {
+		return err
+	}
+
 	if !byProfile {
 		return nil
 	}
@@ -337,27 +401,113 @@ func showWindowStats(ctx context.Context, db *sqlite.DB, days int, byProfile boo
 	if err != nil {
 		return err
 	}
-	printProfileBreakdown(profiles)
+	printProfileBreakdown(profiles, display)
+	return nil
+}
+
+// showCacheSavings prints proxy response cache savings when there were any hits
+func showCacheSavings(ctx context.Context, db *sqlite.DB, from, to time.Time, display currency.Display) error {
+	savings, err := stats.CacheSavingsWindow(ctx, db, from, to)
+	if err != nil {
+		if errors.Is(err, stats.ErrSchemaTooOld) {
+			return nil
+		}
+		return err
+	}
+	if savings.Hits == 0 {
+		return nil
+	}
+	fmt.Println()
+	fmt.Println("Response Cache:")
+	fmt.Println("---------------")
+	fmt.Printf("Hits:         %d\n", savings.Hits)
+	fmt.Printf("Tokens Saved: %d\n", savings.TokensSaved)
+	fmt.Printf("Cost Saved:   %s\n", display.Format(savings.CostSaved, 4))
+	return nil
+}
+
+// showSatisfaction prints how rated sessions were rated, by profile, model and
+// routing context, when any were rated
+func showSatisfaction(ctx context.Context, db *sqlite.DB, from, to time.Time) error {
+	var lines []string
+	for _, by := range []string{stats.SatisfactionByProfile, stats.SatisfactionByModel, stats.SatisfactionByRoute} {
+		rows, err := stats.SatisfactionWindow(ctx, db, from, to, by)
+		if err != nil {
+			if errors.Is(err, stats.ErrSchemaTooOld) {
+				return nil
+			}
+			return err
+		}
+		for _, row := range rows {
+			lines = append(lines, fmt.Sprintf("%-8s %-28s %4d rated  %3.0f%% good", by, row.Key, row.Rated, row.Rate()*100))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	fmt.Println()
+	fmt.Println("Satisfaction:")
+	fmt.Println("-------------")
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// showBilledStats prints what providers billed today, or over the last days
+// including today. Days are UTC, as providers bill.
+func showBilledStats(ctx context.Context, db *sqlite.DB, days int, display currency.Display) error {
+	to := time.Now().UTC()
+	from := to
+	if days > 0 {
+		from = to.AddDate(0, 0, 1-days)
+	}
+	billed, err := stats.Billed(ctx, db, from, to)
+	if err != nil {
+		return err
+	}
+
+	title := "Today's Billed Usage"
+	if days > 0 {
+		title = fmt.Sprintf("Last %d Days Billed Usage", days)
+	}
+	fmt.Println(title)
+	fmt.Println(strings.Repeat("=", len(title)))
+	if billed.Through == "" {
+		fmt.Println("No billed usage imported. Import a provider export with 'boba reconcile import <file> --store'.")
+		return nil
+	}
+	if billed.TotalTokens > 0 {
+		fmt.Printf("Tokens:   %d\n", billed.TotalTokens)
+	}
+	fmt.Printf("Cost:     %s\n", display.Format(billed.TotalCost, 4))
+	if days > 0 {
+		fmt.Printf("Avg Daily Cost: %s\n", display.Format(billed.AvgDailyCost, 4))
+	}
+	if len(billed.Sources) > 0 {
+		fmt.Printf("Sources:  %s\n", strings.Join(billed.Sources, ", "))
+	}
+	fmt.Printf("Billed through %s (UTC); later usage shows up once its export is imported.\n", billed.Through)
 	return nil
 }
 
-func printTodaySummary(summary stats.Summary) {
+func printTodaySummary(summary stats.Summary, display currency.Display) {
 	title := "Today's Usage"
 	fmt.Println(title)
 	fmt.Println(strings.Repeat("=", len(title)))
 	fmt.Printf("Tokens:   %d\n", summary.TotalTokens)
-	fmt.Printf("Cost:     $%.4f\n", summary.TotalCost)
+	fmt.Printf("Cost:     %s\n", display.Format(summary.TotalCost, 4))
 	fmt.Printf("Sessions: %d\n", summary.TotalSessions)
 }
 
-func printWindowSummary(days int, summary stats.Summary) {
+func printWindowSummary(days int, summary stats.Summary, display currency.Display) {
 	fmt.Printf("Last %d Days Usage\n", days)
 	fmt.Println(strings.Repeat("=", 20))
 	fmt.Printf("Total Tokens:   %d\n", summary.TotalTokens)
-	fmt.Printf("Total Cost:     $%.4f\n", summary.TotalCost)
+	fmt.Printf("Total Cost:     %s\n", display.Format(summary.TotalCost, 4))
 	fmt.Printf("Total Sessions: %d\n", summary.TotalSessions)
 	fmt.Printf("Avg Daily Tokens: %.2f\n", summary.AvgDailyTokens)
-	fmt.Printf("Avg Daily Cost:   $%.4f\n", summary.AvgDailyCost)
+	fmt.Printf("Avg Daily Cost:   %s\n", display.Format(summary.AvgDailyCost, 4))
 }
 
 func printP95Latency(latencies map[string]int64) {
@@ -377,7 +527,7 @@ func printP95Latency(latencies map[string]int64) {
 	}
 }
 
-func printProfileBreakdown(statsByProfile []stats.ProfileStats) {
+func printProfileBreakdown(statsByProfile []stats.ProfileStats, display currency.Display) {
 	if len(statsByProfile) == 0 {
 		fmt.Println()
 		fmt.Println("By Profile: (no data)")
@@ -387,10 +537,10 @@ func printProfileBreakdown(statsByProfile []stats.ProfileStats) {
 	fmt.Println("By Profile:")
 	fmt.Println("-----------")
 	for _, ps := range statsByProfile {
-		fmt.Printf("- %s: tokens=%d cost=$%.4f sessions=%d avg_latency=%.0fms usage=%.1f%% cost=%.1f%%\n",
+		fmt.Printf("- %s: tokens=%d cost=%s sessions=%d avg_latency=%.0fms usage=%.1f%% cost=%.1f%%\n",
 			ps.ProfileName,
 			ps.TotalTokens,
-			ps.TotalCost,
+			display.Format(ps.TotalCost, 4),
 			ps.SessionCount,
 			ps.AvgLatencyMS,
 			ps.UsagePercent,
@@ -432,16 +582,36 @@ func runEdit(home string, args []string) error {
 }
 
 func runBudget(home string, args []string) error {
+	if len(args) > 0 && args[0] == "history" {
+		return runBudgetHistory(home, args[1:])
+	}
+	if len(args) > 0 && args[0] == "allow" {
+		return runBudgetAllow(home, args[1:])
+	}
+
 	flags := flag.NewFlagSet("budget", flag.ContinueOnError)
 	status := flags.Bool("status", true, "show budget status summary")
-	daily := flags.Float64("daily", 0, "set daily budget limit (USD)")
-	cap := flags.Float64("cap", 0, "set hard cap (USD)")
-	scopeFlag := flags.String("scope", "auto", "scope: auto|global|project|profile")
-	targetFlag := flags.String("target", "", "scope target (profile or project name)")
+	daily := flags.Float64("daily", 0, "set daily budget limit, in the budget's currency")
+	cap := flags.Float64("cap", 0, "set hard cap, in the budget's currency")
+	currencyFlag := flags.String("currency", "", "currency of the limits, e.g. CNY; needs a rate from 'boba currency set'")
+	periodFlag := flags.String("period", "", "period of the cap: daily|weekly|monthly|<N>d|<from>..<to>")
+	forecastFlag := flags.Bool("forecast", false, "project spending to the end of each budget's period")
+	onCap := flags.String("on-cap", "", "what happens to requests over the cap: block|override|downgrade|warn")
+	downgradeTo := flags.String("downgrade-to", "", "model the downgrade action switches requests to")
+	scopeFlag := flags.String("scope", "auto", "scope: auto|global|project|profile|tool|provider|binding|model, combined with +")
+	targetFlag := flags.String("target", "", "scope target, e.g. a project name, or claude-code+claude-opus-* for tool+model")
 	flags.SetOutput(io.Discard)
 	if err := flags.Parse(args); err != nil {
 		return err
 	}
+	var period *budget.PeriodSpec
+	if *periodFlag != "" {
+		spec, err := budget.ParsePeriod(*periodFlag)
+		if err != nil {
+			return err
+		}
+		period = &spec
+	}
 
 	dbPath := filepath.Join(home, "usage.db")
 	db, err := sqlite.Open(dbPath)
@@ -459,20 +629,45 @@ func runBudget(home string, args []string) error {
 		}
 	}
 	if *daily > 0 || *cap > 0 {
-		if err := applyBudgetLimits(tracker, scope, target, *daily, *cap); err != nil {
+		if err := applyBudgetLimits(tracker, scope, target, period, *daily, *cap, *currencyFlag); err != nil {
 			return err
 		}
 		fmt.Println("Budget limits updated.")
+	} else if *currencyFlag != "" {
+		if err := applyBudgetCurrency(tracker, scope, target, period, *currencyFlag); err != nil {
+			return err
+		}
+		fmt.Println("Budget currency updated; limits converted at today's rate.")
+	} else if period != nil && *onCap == "" {
+		return errors.New("--period needs --cap, --daily, --currency or --on-cap")
+	}
+	if *onCap != "" {
+		if err := applyBudgetAction(home, tracker, scope, target, period, *onCap, *downgradeTo); err != nil {
+			return err
+		}
+		fmt.Println("Over-cap action updated.")
+	} else if *downgradeTo != "" {
+		return errors.New("--downgrade-to needs --on-cap downgrade")
 	}
 	if !*status {
 		return nil
 	}
-	statusInfo, err := tracker.GetStatus(scope, target)
+	statuses, err := tracker.Statuses(scope, target)
 	if err != nil {
 		return err
 	}
-	printBudgetStatus(scope, target, statusInfo)
-	alerts := budget.NewAlertManager(tracker, nil).CheckBudgetAlerts(scope, target)
+	if len(statuses) == 0 {
+		return fmt.Errorf("no active budget for %s; set one with --cap", budgetScopeName(scope, target))
+	}
+	if err := printBudgetStatus(tracker, scope, target, statuses); err != nil {
+		return err
+	}
+	if *forecastFlag {
+		if err := printBudgetForecast(tracker, statuses); err != nil {
+			return err
+		}
+	}
+	alerts := budget.NewAlertManager(tracker, loadAlertConfig(home)).CheckBudgetAlerts(scope, target)
 	if len(alerts) > 0 {
 		fmt.Println()
 		fmt.Println("Alerts:")
@@ -516,7 +711,11 @@ func resolveBudgetScope(scopeOpt, target string) (string, string, *config.Projec
 		}
 		return "profile", target, nil, nil
 	default:
-		return "", "", nil, fmt.Errorf("unknown scope %s", scopeOpt)
+		// tool, provider, binding, model and combinations such as tool+model
+		if err := budget.ValidateScope(scopeOpt, target); err != nil {
+			return "", "", nil, err
+		}
+		return scopeOpt, target, nil, nil
 	}
 }
 
@@ -526,36 +725,253 @@ func ensureBudget(tracker *budget.Tracker, scope, target string, cfg *config.Bud
 	}
 	entry, err := tracker.GetBudget(scope, target)
 	if err != nil {
-		_, createErr := tracker.CreateBudget(scope, target, cfg.DailyUSD, cfg.HardCap)
-		return createErr
+		entry, err = tracker.CreateBudget(scope, target, cfg.DailyUSD, cfg.HardCap)
+		if err != nil || cfg.Currency == "" {
+			return err
+		}
+	}
+	if cfg.Currency != "" {
+		if err := tracker.SetCurrency(entry, cfg.Currency); err != nil {
+			return err
+		}
 	}
 	return tracker.UpdateLimits(entry.ID, cfg.DailyUSD, cfg.HardCap)
 }
 
-func applyBudgetLimits(tracker *budget.Tracker, scope, target string, daily, cap float64) error {
-	budgetEntry, err := tracker.GetBudget(scope, target)
+// loadAlertConfig returns the budget thresholds from settings.yaml, or the
+// defaults when it cannot be read
+func loadAlertConfig(home string) *budget.AlertConfig {
+	userSettings, err := settings.Load(context.Background(), home)
 	if err != nil {
-		_, err = tracker.CreateBudget(scope, target, daily, cap)
-		return err
+		return budget.DefaultAlertConfig()
+	}
+	return userSettings.Budget.AlertConfig()
+}
+
+// applyBudgetAction sets what happens to requests over the cap of the scope's
+// budget for the period, or its first budget without one
+func applyBudgetAction(home string, tracker *budget.Tracker, scope, target string, period *budget.PeriodSpec, action, model string) error {
+	var (
+		budgetEntry *budget.Budget
+		err         error
+	)
+	if period != nil {
+		budgetEntry, err = tracker.FindBudget(scope, target, *period)
+	} else {
+		budgetEntry, err = tracker.GetBudget(scope, target)
+	}
+	if err != nil {
+		return fmt.Errorf("no budget for %s; set one with --cap first", budgetScopeName(scope, target))
+	}
+	if model == "" {
+		model = budgetEntry.DowngradeModel
+	}
+	if action == budget.ActionDowngrade && model != "" {
+		if err := checkDowngradeModel(home, scope, target, model); err != nil {
+			return err
+		}
+	}
+	return tracker.SetAction(budgetEntry.ID, action, model)
+}
+
+// checkDowngradeModel refuses a downgrade model that the provider of a
+// provider or binding scope does not offer, since every request downgraded
+// to it would fail upstream. Other scopes span providers and are not checked.
+func checkDowngradeModel(home, scope, target, model string) error {
+	providerID := budget.ScopeProvider(scope, target)
+	if providerID == "" {
+		return nil
+	}
+	providers, err := core.LoadProviders(home)
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+	provider, err := providers.FindProvider(providerID)
+	if err != nil {
+		return fmt.Errorf("provider not found: %s\nRun 'boba providers' to list available providers", providerID)
+	}
+	secrets, err := core.LoadSecrets(home)
+	if err != nil {
+		secrets = &core.SecretsConfig{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+	listing, err := catalog.New(home, 0).List(ctx, provider, secrets, false)
+	if err != nil {
+		fmt.Printf("%s Could not list models for %s, so %s is unchecked: %v\n", statusWarning, provider.ID, model, err)
+		return nil
+	}
+	if !listing.Has(model) {
+		return fmt.Errorf("%s does not offer %q; run 'boba providers models %s' to see its models", provider.ID, model, provider.ID)
+	}
+	return nil
+}
+
+// applyBudgetCurrency moves the scope's budget for the period, or its first
+// budget without one, to another currency
+func applyBudgetCurrency(tracker *budget.Tracker, scope, target string, period *budget.PeriodSpec, code string) error {
+	var (
+		budgetEntry *budget.Budget
+		err         error
+	)
+	if period != nil {
+		budgetEntry, err = tracker.FindBudget(scope, target, *period)
+	} else {
+		budgetEntry, err = tracker.GetBudget(scope, target)
+	}
+	if err != nil {
+		return fmt.Errorf("no budget for %s; set one with --cap first", budgetScopeName(scope, target))
+	}
+	return tracker.SetCurrency(budgetEntry, code)
+}
+
+// applyBudgetLimits updates the scope's budget for the period, creating it if
+// missing. Without a period the scope's first budget is updated, or a monthly
+// one created. With a currency the limits are in it; an existing budget's
+// other limit is converted to it first.
+func applyBudgetLimits(tracker *budget.Tracker, scope, target string, period *budget.PeriodSpec, daily, cap float64, code string) error {
+	var (
+		budgetEntry *budget.Budget
+		err         error
+	)
+	if period != nil {
+		budgetEntry, err = tracker.FindBudget(scope, target, *period)
+		if err != nil {
+			budgetEntry, err = tracker.CreateBudgetWithPeriod(scope, target, *period, daily, cap)
+			if err != nil || code == "" {
+				return err
+			}
+		}
+	} else {
+		budgetEntry, err = tracker.GetBudget(scope, target)
+		if err != nil {
+			budgetEntry, err = tracker.CreateBudget(scope, target, daily, cap)
+			if err != nil || code == "" {
+				return err
+			}
+		}
+	}
+	if code != "" {
+		if err := tracker.SetCurrency(budgetEntry, code); err != nil {
+			return err
+		}
 	}
 	if daily == 0 {
-		daily = budgetEntry.DailyUSD
+		daily = budgetEntry.Daily
 	}
 	if cap == 0 {
-		cap = budgetEntry.HardCapUSD
+		cap = budgetEntry.HardCap
 	}
 	return tracker.UpdateLimits(budgetEntry.ID, daily, cap)
 }
 
-func printBudgetStatus(scope, target string, status *budget.Status) {
+func printBudgetStatus(tracker *budget.Tracker, scope, target string, statuses []*budget.Status) error {
 	fmt.Printf("Budget Scope: %s (%s)\n", scope, target)
 	fmt.Println(strings.Repeat("=", 40))
-	fmt.Printf("Today:  $%.4f of $%.2f (%.1f%%)\n", status.CurrentSpent, status.DailyLimit, status.DailyProgress)
-	fmt.Printf("Period: $%.4f of $%.2f (%.1f%%)\n", status.Budget.SpentUSD, status.HardCap, status.TotalProgress)
-	fmt.Printf("Days Remaining: %d\n", status.DaysRemaining)
-	if level := status.GetWarningLevel(); level != "none" {
+	level := "none"
+	for _, status := range statuses {
+		if status.DailyLimit > 0 {
+			code := status.Budget.Currency
+			fmt.Printf("%-16s %s of %s (%.1f%%)\n", "Today:",
+				currency.Format(status.CurrentSpent, code, 4), currency.Format(status.DailyLimit, code, 2), status.DailyProgress)
+			break
+		}
+	}
+	for _, status := range statuses {
+		spec, code := status.Budget.Spec(), status.Budget.Currency
+		fmt.Printf("%-16s %s of %s (%.1f%%)", capitalize(spec.Label())+":",
+			currency.Format(status.Budget.Spent, code, 4), currency.Format(status.HardCap, code, 2), status.TotalProgress)
+		if spec.Period == budget.PeriodRolling {
+			fmt.Println()
+		} else {
+			fmt.Printf(", %d days remaining\n", status.DaysRemaining)
+		}
+		if action, err := describeBudgetAction(tracker, status.Budget); err != nil {
+			return err
+		} else if action != "" {
+			fmt.Printf("%-16s over cap: %s\n", "", action)
+		}
+		switch status.GetWarningLevel() {
+		case "critical":
+			level = "critical"
+		case "warning":
+			if level == "none" {
+				level = "warning"
+			}
+		}
+	}
+	if level != "none" {
 		fmt.Printf("Warning Level: %s\n", strings.ToUpper(level))
 	}
+	return nil
+}
+
+// describeBudgetAction describes a budget's over-cap action, or returns ""
+// for the default of blocking
+func describeBudgetAction(tracker *budget.Tracker, b *budget.Budget) (string, error) {
+	switch b.Action() {
+	case budget.ActionDowngrade:
+		return "downgrade to " + b.DowngradeModel, nil
+	case budget.ActionWarn:
+		return "warn", nil
+	case budget.ActionOverride:
+		until, err := tracker.OverrideUntil(b)
+		if err != nil {
+			return "", err
+		}
+		if until.IsZero() {
+			return "block until 'boba budget allow'", nil
+		}
+		return "override active until " + until.Format("15:04"), nil
+	default:
+		return "", nil
+	}
+}
+
+// printBudgetForecast projects each budget to the end of its period with an
+// 80% confidence band
+func printBudgetForecast(tracker *budget.Tracker, statuses []*budget.Status) error {
+	fmt.Println()
+	fmt.Println("Forecast:")
+	for _, status := range statuses {
+		projection, err := tracker.Project(status, time.Now())
+		if err != nil {
+			return err
+		}
+		label, code := capitalize(status.Budget.Spec().Label())+":", status.Budget.Currency
+		if projection.Method == forecast.MethodNone {
+			fmt.Printf("  %-16s no spending history yet\n", label)
+			continue
+		}
+		fmt.Printf("  %-16s %s by %s (80%%: %s-%s)", label,
+			currency.Format(projection.Expected, code, 2), projection.At.Format("Jan 2"),
+			currency.Format(projection.Low, code, 2), currency.Format(projection.High, code, 2))
+		if status.HardCap > 0 {
+			fmt.Printf(", %.0f%% of cap", projection.Expected/status.HardCap*100)
+		}
+		fmt.Println()
+		fmt.Printf("  %-16s %s over %d day(s) of history\n", "", projection.Method, projection.HistoryDays)
+		if projection.Overrun() {
+			fmt.Printf("  %s projected to exceed the %s cap\n", statusWarning, currency.Format(status.HardCap, code, 2))
+		}
+	}
+	return nil
+}
+
+// budgetScopeName describes a budget scope, e.g. "project myapp" or "global"
+func budgetScopeName(scope, target string) string {
+	if target == "" {
+		return scope
+	}
+	return scope + " " + target
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
 }
 
 func runHooks(home string, args []string) error {
@@ -849,12 +1265,18 @@ func findRepoRoot(start string) (string, error) {
 
 func runRoute(home string, args []string) error {
 	if len(args) == 0 {
-		return errors.New("route subcommand required (test)")
+		return errors.New("route subcommand required (test, lint, stats, replay)")
 	}
 
 	switch args[0] {
 	case "test":
 		return runRouteTest(home, args[1:])
+	case "lint":
+		return runRouteLint(home, args[1:])
+	case "stats":
+		return runRouteStats(home, args[1:])
+	case "replay":
+		return runRouteReplay(home, args[1:])
 	default:
 		return fmt.Errorf("unknown route subcommand: %s", args[0])
 	}
@@ -863,17 +1285,22 @@ func runRoute(home string, args []string) error {
 //nolint:gocyclo // Complex route testing with multiple output formats and conditions
 func runRouteTest(home string, args []string) error {
 	flags := flag.NewFlagSet("route test", flag.ContinueOnError)
+	suite := flags.Bool("suite", false, "run the golden cases in routes.test.yaml (or the given file)")
+	routesPath := flags.String("routes", filepath.Join(home, "routes.yaml"), "routes file to test")
 	flags.SetOutput(io.Discard)
 	if err := flags.Parse(args); err != nil {
 		return err
 	}
 
+	if *suite {
+		return runRouteSuite(*routesPath, flags.Args())
+	}
 	if flags.NArg() == 0 {
-		return errors.New("route test requires text or @file argument")
+		return errors.New("route test requires text or @file argument, or --suite")
 	}
 
 	// Load configurations
-	routes, err := config.LoadRoutes(home)
+	routes, err := config.LoadRoutesFile(*routesPath)
 	if err != nil {
 		return fmt.Errorf("load routes: %w", err)
 	}
@@ -920,32 +1347,45 @@ func runRouteTest(home string, args []string) error {
 		}
 	}
 
-	// Determine time of day
-	hour := time.Now().Hour()
-	timeOfDay := "day"
-	if hour < 6 || hour >= 22 {
-		timeOfDay = "night"
-	} else if hour >= 18 {
-		timeOfDay = "evening"
-	}
-
 	// Build routing context
+	now := time.Now()
 	ctx := routing.Context{
 		Text:        text,
 		CtxChars:    len(text),
 		Project:     project,
 		Branch:      branch,
 		ProjectType: projectTypes,
-		TimeOfDay:   timeOfDay,
+		TimeOfDay:   routing.TimeOfDayLabel(now),
+		Now:         now,
+	}
+
+	// Grade spending so budget conditions see what the proxy would
+	if db, err := sqlite.Open(filepath.Join(home, "usage.db")); err == nil {
+		ctx.Budget, ctx.BudgetUsed = budget.NewTracker(db).GetHint("global", "", loadAlertConfig(home))
 	}
 
-	// Route decision
+	// Invalid sub-agents and rules never match; say so rather than routing silently around them
+	if _, err := routing.CompileRoutes(routes); err != nil {
+		fmt.Printf("%s %v (run 'boba route lint')\n\n", statusWarning, err)
+	}
+
+	// Route decision: sub-agents first, then rules
 	router := routing.NewRouter(routes)
 	decision := router.Route(ctx, activeProfile)
 
+	// Size the context with the tokenizer of the model the decision lands on
+	model := ""
+	if profs, err := config.LoadProfiles(home); err == nil {
+		if prof, ok := profs[decision.ProfileKey]; ok {
+			model = prof.Model
+		}
+	}
+	tokens := tokenizer.CountText(model, text)
+
 	// Display results
 	fmt.Println("=== Route Test Results ===")
 	fmt.Printf("Text length: %d chars\n", ctx.CtxChars)
+	fmt.Printf("Tokens: %d (%s, %s confidence)\n", tokens.Tokens, tokenizer.ForModel(model).Name(), tokens.Confidence)
 	if ctx.Project != "" {
 		fmt.Printf("Project: %s (types: %v)\n", ctx.Project, ctx.ProjectType)
 	}
@@ -953,10 +1393,14 @@ func runRouteTest(home string, args []string) error {
 		fmt.Printf("Branch: %s\n", ctx.Branch)
 	}
 	fmt.Printf("Time of day: %s\n", ctx.TimeOfDay)
+	fmt.Printf("Budget: %s (%.0f%% used)\n", ctx.Budget, ctx.BudgetUsed)
 	fmt.Println()
 
 	fmt.Println("=== Routing Decision ===")
 	fmt.Printf("Profile: %s\n", decision.ProfileKey)
+	if decision.SubAgent != "" {
+		fmt.Printf("Sub-agent: %s\n", decision.SubAgent)
+	}
 	if decision.RuleID != "" {
 		fmt.Printf("Rule ID: %s\n", decision.RuleID)
 	}
@@ -977,16 +1421,20 @@ func runCall(home string, args []string) error {
 	flags := flag.NewFlagSet("call", flag.ContinueOnError)
 	profileFlag := flags.String("profile", "", "profile to use")
 	dataFlag := flags.String("data", "", "data file (use @file.json syntax)")
+	autoFlag := flags.Bool("auto", false, "route the payload through routes.yaml and learn from the outcome")
 	flags.SetOutput(io.Discard)
 	if err := flags.Parse(args); err != nil {
 		return err
 	}
+	if *autoFlag && *profileFlag != "" {
+		return errors.New("--auto and --profile cannot be combined")
+	}
 
-	// Load active profile if not specified
+	// Load active profile if not specified; with --auto it only serves unmatched payloads
 	profileKey := *profileFlag
 	if profileKey == "" {
 		active, err := config.LoadActiveProfile(home)
-		if err != nil {
+		if err != nil && !*autoFlag {
 			return fmt.Errorf("no active profile, use --profile or run 'boba use <profile>'")
 		}
 		profileKey = active
@@ -1029,11 +1477,13 @@ func runCall(home string, args []string) error {
 	cwd, _ := os.Getwd() //nolint:errcheck
 	project := ""
 	branch := ""
+	projectTypes := []string{}
 
 	if repoRoot, err := findRepoRoot(cwd); err == nil {
 		projectCfg, _, _ := config.FindProjectConfig(repoRoot) //nolint:errcheck
 		if projectCfg != nil {
 			project = projectCfg.Project.Name
+			projectTypes = projectCfg.Project.Type
 		}
 
 		// Get git branch
@@ -1053,12 +1503,66 @@ func runCall(home string, args []string) error {
 		TaskType:   "api-call",
 	}
 
-	fmt.Printf("Calling %s...\n", profileKey)
+	// Route through sub-agents and rules, letting the bandit explore among profiles
+	arms := bandit.NewStore(db)
+	var features *routing.Features
+	var routedProfile string
+	if *autoFlag {
+		routes, err := config.LoadRoutes(home)
+		if err != nil {
+			return fmt.Errorf("load routes: %w", err)
+		}
+		now := time.Now()
+		features = &routing.Features{
+			TextSample:   string(payload),
+			CtxChars:     len(payload),
+			Project:      project,
+			Branch:       branch,
+			ProjectTypes: projectTypes,
+			TimeOfDay:    routing.TimeOfDayLabel(now),
+			Now:          now,
+		}
+		features.BudgetHint, features.BudgetUsed = budget.NewTracker(db).GetHint("global", "", loadAlertConfig(home))
+
+		router := routing.NewRouter(routes)
+		router.SetPolicy(bandit.New(arms))
+		decision := router.Route(features.Context(), profileKey)
+		if decision.ProfileKey == "" {
+			return fmt.Errorf("no route matched and no active profile, run 'boba use <profile>'")
+		}
+		req.ProfileKey = decision.ProfileKey
+		req.RouteKey = decision.Key
+		req.Explore = decision.Explore
+		routedProfile = decision.ProfileKey
+		if decision.Explore {
+			routedProfile = decision.Fallback
+		}
+		if decision.Explore {
+			fmt.Printf("Routed to %s by %s, exploring (configured: %s)\n", decision.ProfileKey, decision.Key, decision.Fallback)
+		} else {
+			fmt.Printf("Routed to %s by %s\n", decision.ProfileKey, decision.Key)
+		}
+	}
+
+	fmt.Printf("Calling %s...\n", req.ProfileKey)
 	result, err := executor.Execute(context.Background(), req)
 	if err != nil {
 		return fmt.Errorf("execute: %w", err)
 	}
 
+	// Fold the outcome into the bandit straight away, and keep what routing saw
+	// for 'boba route replay' when routing.record_features is set
+	if req.RouteKey != "" {
+		if _, err := arms.Learn(bandit.DefaultWeights); err != nil {
+			fmt.Printf("%s bandit not updated: %v\n", statusWarning, err)
+		}
+		if userSettings, err := settings.Load(context.Background(), home); err == nil && userSettings.Routing.RecordFeatures {
+			if err := routing.RecordFeatures(db, result.SessionID, *features, profileKey, routedProfile); err != nil {
+				fmt.Printf("%s routing features not recorded: %v\n", statusWarning, err)
+			}
+		}
+	}
+
 	// Display result
 	fmt.Println()
 	if !result.Success {
@@ -1136,7 +1640,7 @@ _boba_completion() {
     cur="${COMP_WORDS[COMP_CWORD]}"
     prev="${COMP_WORDS[COMP_CWORD-1]}"
 
-    opts="ls use call stats edit doctor budget hooks action report route completions suggest version"
+    opts="ls use call stats feedback notify reconcile currency edit doctor budget hooks action report route completions suggest version"
 
     if [[ ${COMP_CWORD} -eq 1 ]]; then
         COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
@@ -1150,6 +1654,15 @@ _boba_completion() {
         hooks)
             COMPREPLY=( $(compgen -W "install remove track" -- ${cur}) )
             ;;
+        notify)
+            COMPREPLY=( $(compgen -W "run test" -- ${cur}) )
+            ;;
+        reconcile)
+            COMPREPLY=( $(compgen -W "import" -- ${cur}) )
+            ;;
+        currency)
+            COMPREPLY=( $(compgen -W "list set remove display" -- ${cur}) )
+            ;;
         completions)
             COMPREPLY=( $(compgen -W "install uninstall" -- ${cur}) )
             ;;
@@ -1178,6 +1691,10 @@ _boba() {
         'action:View/apply suggestions'
         'report:Generate usage report'
         'route:Test routing rules'
+        'feedback:Rate a session good or bad'
+        'notify:Deliver alerts to notification sinks'
+        'reconcile:Compare spending with billing exports'
+        'currency:Manage exchange rates and the display currency'
         'completions:Manage shell completions'
         'suggest:Get profile suggestions'
         'version:Show version info'
@@ -1197,6 +1714,15 @@ _boba() {
                 hooks)
                     compadd install remove track
                     ;;
+                notify)
+                    compadd run test
+                    ;;
+                reconcile)
+                    compadd import
+                    ;;
+                currency)
+                    compadd list set remove display
+                    ;;
                 completions)
                     compadd install uninstall
                     ;;
@@ -1226,6 +1752,10 @@ complete -c boba -n "__fish_use_subcommand" -a "hooks" -d "Manage git hooks"
 complete -c boba -n "__fish_use_subcommand" -a "action" -d "View/apply suggestions"
 complete -c boba -n "__fish_use_subcommand" -a "report" -d "Generate usage report"
 complete -c boba -n "__fish_use_subcommand" -a "route" -d "Test routing rules"
+complete -c boba -n "__fish_use_subcommand" -a "feedback" -d "Rate a session good or bad"
+complete -c boba -n "__fish_use_subcommand" -a "notify" -d "Deliver alerts to notification sinks"
+complete -c boba -n "__fish_use_subcommand" -a "reconcile" -d "Compare spending with billing exports"
+complete -c boba -n "__fish_use_subcommand" -a "currency" -d "Manage exchange rates and the display currency"
 complete -c boba -n "__fish_use_subcommand" -a "completions" -d "Manage shell completions"
 complete -c boba -n "__fish_use_subcommand" -a "suggest" -d "Get profile suggestions"
 complete -c boba -n "__fish_use_subcommand" -a "version" -d "Show version info"
@@ -1233,6 +1763,9 @@ complete -c boba -n "__fish_use_subcommand" -a "version" -d "Show version info"
 # Subcommands
 complete -c boba -n "__fish_seen_subcommand_from edit" -a "profiles routes pricing secrets"
 complete -c boba -n "__fish_seen_subcommand_from hooks" -a "install remove track"
+complete -c boba -n "__fish_seen_subcommand_from notify" -a "run test"
+complete -c boba -n "__fish_seen_subcommand_from reconcile" -a "import"
+complete -c boba -n "__fish_seen_subcommand_from currency" -a "list set remove display"
 complete -c boba -n "__fish_seen_subcommand_from completions" -a "install uninstall"
 `
 	default:
@@ -61,6 +61,113 @@ func TestRunStatsWindowByProfile(t *testing.T) {
 	}
 }
 
+func TestRunStatsShowsCacheSavings(t *testing.T) {
+	home := t.TempDir()
+	db := openUsageDB(t, home)
+	seedUsageRecord(t, db, "s-miss", "alpha", 100, 50, 0.03, 0, 150)
+	ts := time.Now().Unix()
+	if err := db.Exec(fmt.Sprintf(`INSERT INTO sessions (id, started_at, ended_at, success, latency_ms) VALUES ('s-hit', %d, %d, 1, 2);
+        INSERT INTO usage_records (id, session_id, ts, input_tokens, output_tokens, model, estimate_level, cache_hit, saved_cost)
+        VALUES ('usage-s-hit', 's-hit', %d, 100, 50, 'model', 'exact', 1, 0.03);`, ts, ts, ts)); err != nil {
+		t.Fatalf("insert cache hit: %v", err)
+	}
+
+	output := captureStdout(t, func() {
+		if err := runStats(home, []string{"--7d"}); err != nil {
+			t.Fatalf("runStats 7d: %v", err)
+		}
+	})
+
+	if !strings.Contains(output, "Response Cache:") {
+		t.Fatalf("expected cache section, got %q", output)
+	}
+	if !strings.Contains(output, "Hits:         1") || !strings.Contains(output, "Cost Saved:   $0.0300") {
+		t.Fatalf("expected cache hit and savings, got %q", output)
+	}
+}
+
+func TestReconcileImportAndBilledStats(t *testing.T) {
+	home := t.TempDir()
+	db := openUsageDB(t, home)
+	seedUsageRecord(t, db, "s-tracked", "alpha", 100, 50, 1.00, 0, 150)
+	seedUsageRecord(t, db, "s-other", "alpha", 100, 50, 2.00, 0, 150)
+
+	// Without --provider only the providers Anthropic bills for count
+	providers := `version: 1
+providers:
+  - id: claude
+    kind: anthropic
+    display_name: Claude
+    base_url: https://api.anthropic.com
+    api_key: {source: env, env_var: ANTHROPIC_API_KEY}
+    default_model: claude-sonnet-4-5
+    enabled: true
+  - id: zai
+    kind: anthropic-compatible
+    display_name: Z.ai
+    base_url: https://api.z.ai/api/anthropic
+    api_key: {source: env, env_var: ZAI_API_KEY}
+    default_model: glm-4.6
+    enabled: true
+`
+	if err := os.WriteFile(filepath.Join(home, "providers.yaml"), []byte(providers), 0o600); err != nil {
+		t.Fatalf("write providers: %v", err)
+	}
+	if err := db.Exec(`UPDATE usage_records SET provider = 'claude' WHERE session_id = 's-tracked';
+		UPDATE usage_records SET provider = 'zai' WHERE session_id = 's-other';`); err != nil {
+		t.Fatalf("set providers: %v", err)
+	}
+
+	day := time.Now().UTC().Format("2006-01-02")
+	export := filepath.Join(home, "cost.csv")
+	csv := "usage_date_utc,model,workspace,api_key,usage_type,token_type,cost_usd\n" +
+		day + ",model,Default,key,message,input,1.20\n" +
+		day + ",claude-haiku-4-5,Default,key,message,input,0.30\n"
+	if err := os.WriteFile(export, []byte(csv), 0o600); err != nil {
+		t.Fatalf("write export: %v", err)
+	}
+
+	output := captureStdout(t, func() {
+		if err := runReconcile(home, []string{"import", export, "--store"}); err != nil {
+			t.Fatalf("reconcile import: %v", err)
+		}
+	})
+	for _, want := range []string{"anthropic cost export", "provider anthropic, claude", "under", "untracked", "tracked $1.0000, billed $1.5000", "Stored 2 billed line(s)"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in report, got %q", want, output)
+		}
+	}
+
+	output = captureStdout(t, func() {
+		if err := runStats(home, []string{"--today", "--source", "billed"}); err != nil {
+			t.Fatalf("runStats billed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "Today's Billed Usage") || !strings.Contains(output, "Cost:     $1.5000") {
+		t.Fatalf("expected billed totals, got %q", output)
+	}
+
+	// Seven days are today and the six before it, averaged over seven
+	week := time.Now().UTC().AddDate(0, 0, -6).Format("2006-01-02")
+	if err := db.Exec(fmt.Sprintf(`INSERT INTO billed_usage (source, day, model, input_tokens, output_tokens, cost, imported_at)
+		VALUES ('openai', '%s', 'gpt-5', 0, 0, 5.5, 0), ('openai', '%s', 'gpt-5', 0, 0, 100, 0);`,
+		week, time.Now().UTC().AddDate(0, 0, -7).Format("2006-01-02"))); err != nil {
+		t.Fatalf("seed billed: %v", err)
+	}
+	output = captureStdout(t, func() {
+		if err := runStats(home, []string{"--7d", "--source", "billed"}); err != nil {
+			t.Fatalf("runStats billed 7d: %v", err)
+		}
+	})
+	if !strings.Contains(output, "Cost:     $7.0000") || !strings.Contains(output, "Avg Daily Cost: $1.0000") {
+		t.Fatalf("expected 7 days of billed totals, got %q", output)
+	}
+
+	if err := runStats(home, []string{"--source", "invoice"}); err == nil {
+		t.Error("unknown --source should fail")
+	}
+}
+
 func captureStdout(t *testing.T, fn func()) string {
 	t.Helper()
 	orig := os.Stdout
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/royisme/bobamixer/internal/domain/routing"
+	"github.com/royisme/bobamixer/internal/store/config"
+)
+
+// runRouteLint checks routes.yaml (or the given file) for invalid conditions,
+// invalid sub-agents, unknown profiles and rules that can never match
+func runRouteLint(home string, args []string) error {
+	flags := flag.NewFlagSet("route lint", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	path := filepath.Join(home, "routes.yaml")
+	if flags.NArg() > 0 {
+		path = flags.Arg(0)
+	}
+	name := filepath.Base(path)
+
+	routes, err := config.LoadRoutesFile(path)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", name, err)
+	}
+
+	// Without profiles there is nothing to check targets against
+	var known map[string]bool
+	profiles, err := config.LoadProfiles(home)
+	switch {
+	case err != nil:
+		fmt.Printf("%s profiles.yaml not loaded, skipping profile checks: %v\n", statusWarning, err)
+	case len(profiles) > 0:
+		known = make(map[string]bool, len(profiles))
+		for key := range profiles {
+			known[key] = true
+		}
+	}
+
+	issues := routing.Lint(routes, known)
+	if len(issues) == 0 {
+		fmt.Printf("%s %s: %d sub-agents, %d rules OK\n", statusOK, name, len(routes.SubAgents), len(routes.Rules))
+		return nil
+	}
+
+	errorCount := 0
+	for _, issue := range issues {
+		symbol := statusWarning
+		if issue.Severity == routing.SeverityError {
+			symbol = statusError
+			errorCount++
+		}
+		where := "rule " + issue.RuleID
+		switch {
+		case issue.SubAgent != "":
+			where = "sub-agent " + issue.SubAgent
+		case issue.RuleID == "":
+			where = fmt.Sprintf("rule #%d", issue.Index+1)
+		}
+		if issue.Col > 0 {
+			fmt.Printf("%s %s: col %d: %s\n", symbol, where, issue.Col, issue.Msg)
+			fmt.Printf("    %s\n", issue.Condition)
+			fmt.Printf("    %s^\n", strings.Repeat(" ", caretOffset(issue.Condition, issue.Col)))
+		} else {
+			fmt.Printf("%s %s: %s\n", symbol, where, issue.Msg)
+		}
+	}
+
+	fmt.Printf("\n%s: %d error(s), %d warning(s)\n", name, errorCount, len(issues)-errorCount)
+	if errorCount > 0 {
+		return fmt.Errorf("%s has %d error(s)", name, errorCount)
+	}
+	return nil
+}
+
+// caretOffset returns the number of spaces that put a caret under the 1-based
+// rune column col of s
+func caretOffset(s string, col int) int {
+	if n := utf8.RuneCountInString(s); col > n+1 {
+		col = n + 1
+	}
+	if col < 1 {
+		return 0
+	}
+	return col - 1
+}
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+
+	"github.com/royisme/bobamixer/internal/domain/currency"
+	"github.com/royisme/bobamixer/internal/domain/pricing"
+	"github.com/royisme/bobamixer/internal/domain/routing"
+	"github.com/royisme/bobamixer/internal/store/config"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+// runRouteReplay re-routes recorded requests through a routes file and reports
+// how many would move between profiles and what that would cost
+func runRouteReplay(home string, args []string) error {
+	flags := flag.NewFlagSet("route replay", flag.ContinueOnError)
+	routesFlag := flags.String("routes", filepath.Join(home, "routes.yaml"), "routes file to replay")
+	sinceFlag := flags.String("since", "30d", "how far back to replay, e.g. 7d or 12h")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	window, err := parseSince(*sinceFlag)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(*routesFlag)
+
+	routes, err := config.LoadRoutesFile(*routesFlag)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", name, err)
+	}
+	profiles, err := config.LoadProfiles(home)
+	if err != nil {
+		fmt.Printf("%s profiles.yaml not loaded, pricing at recorded models: %v\n", statusWarning, err)
+	}
+	prices, err := pricing.Load(home)
+	if err != nil {
+		fmt.Printf("%s pricing not loaded, falling back to profile costs: %v\n", statusWarning, err)
+	}
+
+	db, err := sqlite.Open(filepath.Join(home, "usage.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	if prices != nil {
+		prices.SetConverter(currency.NewRates(db))
+	}
+	samples, err := routing.LoadSamples(db, time.Now().Add(-window))
+	if err != nil {
+		return err
+	}
+	report, err := routing.Replay(routes, samples, profiles, prices)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	fmt.Printf("Routing Replay: %s over the last %s\n", name, *sinceFlag)
+	fmt.Println("==================================")
+	if report.Requests == 0 {
+		fmt.Println("No routed requests recorded in this window. With routing.record_features set in settings.yaml, 'boba call --auto' and the proxy record each routing decision for 30 days.")
+		return nil
+	}
+
+	fmt.Printf("Requests: %d\n", report.Requests)
+	fmt.Printf("Moved:    %d (%.1f%%)\n", report.Moved, float64(report.Moved)/float64(report.Requests)*100)
+	if report.Moved == 0 {
+		fmt.Printf("%s %s routes every request to the same profile\n", statusOK, name)
+		return nil
+	}
+
+	var (
+		headerStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("99")).
+				Padding(0, 1)
+
+		cellStyle = lipgloss.NewStyle().
+				Padding(0, 1)
+	)
+
+	rows := make([][]string, 0, len(report.Moves))
+	for _, move := range report.Moves {
+		rows = append(rows, []string{
+			replayProfile(move.From),
+			replayProfile(move.To),
+			fmt.Sprintf("%d", move.Requests),
+			signedCost(move.CostDelta),
+		})
+	}
+
+	t := table.New().
+		Border(lipgloss.HiddenBorder()).
+		Headers("FROM", "TO", "REQUESTS", "COST DELTA").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return headerStyle
+			}
+			return cellStyle
+		})
+
+	fmt.Println()
+	fmt.Println(t)
+	fmt.Println()
+	fmt.Printf("Cost:     $%.4f -> $%.4f (%s", report.CostBefore, report.CostAfter, signedCost(report.CostDelta()))
+	if report.CostBefore > 0 {
+		fmt.Printf(", %+.1f%%", report.CostDelta()/report.CostBefore*100)
+	}
+	fmt.Println(")")
+	if report.Estimated > 0 {
+		fmt.Printf("%s %d request(s) priced from estimated token counts\n", statusWarning, report.Estimated)
+	}
+	if report.Unpriced > 0 {
+		fmt.Printf("%s %d request(s) have no recorded usage and are not priced\n", statusWarning, report.Unpriced)
+	}
+	if report.Unconverted > 0 {
+		fmt.Printf("%s %d request(s) are priced in a currency without an exchange rate and are not counted; set one with 'boba currency set'\n",
+			statusWarning, report.Unconverted)
+	}
+	return nil
+}
+
+// parseSince reads a look-back window: whole days as "30d", otherwise a Go duration
+func parseSince(s string) (time.Duration, error) {
+	var window time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid --since %q: want days like 30d or a duration like 12h", s)
+		}
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid --since %q: want days like 30d or a duration like 12h", s)
+		}
+		window = d
+	}
+	if window <= 0 {
+		return 0, fmt.Errorf("--since must be positive, got %s", s)
+	}
+	return window, nil
+}
+
+// replayProfile names a replayed profile. Requests no rule matched and that had
+// no active profile have none: the proxy forwards them as sent.
+func replayProfile(profile string) string {
+	if profile == "" {
+		return "(none)"
+	}
+	return profile
+}
+
+func signedCost(cost float64) string {
+	if cost < 0 {
+		return fmt.Sprintf("-$%.4f", -cost)
+	}
+	return fmt.Sprintf("+$%.4f", cost)
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+
+	"github.com/royisme/bobamixer/internal/domain/bandit"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+// runRouteStats folds finished routed sessions into the bandit and shows each
+// arm's estimated reward and confidence, per routing context
+func runRouteStats(home string, args []string) error {
+	flags := flag.NewFlagSet("route stats", flag.ContinueOnError)
+	contextFlag := flags.String("context", "", "only show one context, e.g. rule:large-context")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := sqlite.Open(filepath.Join(home, "usage.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	store := bandit.NewStore(db)
+	learned, err := store.Learn(bandit.DefaultWeights)
+	if err != nil {
+		return err
+	}
+	arms, err := store.Arms(*contextFlag)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Routing Bandit")
+	fmt.Println("==============")
+	if learned > 0 {
+		fmt.Printf("Learned from %d new session(s)\n", learned)
+	}
+	if len(arms) == 0 {
+		fmt.Println("No routed outcomes yet. Run 'boba call --auto', or send requests through the proxy with routes.yaml, to learn from them.")
+		return nil
+	}
+
+	var (
+		headerStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("99")).
+				Padding(0, 1)
+
+		cellStyle = lipgloss.NewStyle().
+				Padding(0, 1)
+	)
+
+	rows := make([][]string, 0, len(arms))
+	for i, arm := range arms {
+		context := arm.Context
+		if i > 0 && arms[i-1].Context == arm.Context {
+			context = ""
+		}
+		rows = append(rows, []string{
+			context,
+			arm.Profile,
+			fmt.Sprintf("%d", arm.Pulls),
+			fmt.Sprintf("%.2f", arm.Mean()),
+			fmt.Sprintf("±%.2f", arm.Confidence()),
+			arm.UpdatedAt.Format("2006-01-02 15:04"),
+		})
+	}
+
+	t := table.New().
+		Border(lipgloss.HiddenBorder()).
+		Headers("CONTEXT", "PROFILE", "OUTCOMES", "REWARD", "95% CI", "UPDATED").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return headerStyle
+			}
+			return cellStyle
+		})
+
+	fmt.Println(t)
+	fmt.Println()
+	fmt.Println("Reward is 0-1 from success, latency, cost per token and feedback. Profiles are sampled by their chance of being best.")
+	return nil
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/royisme/bobamixer/internal/domain/routing"
+	"github.com/royisme/bobamixer/internal/store/config"
+)
+
+// runRouteSuite runs the golden routing cases against a routes file and fails
+// when any case routes differently than expected. The suite defaults to
+// routes.test.yaml beside the routes file.
+func runRouteSuite(routesPath string, args []string) error {
+	suitePath := filepath.Join(filepath.Dir(routesPath), "routes.test.yaml")
+	switch len(args) {
+	case 0:
+	case 1:
+		suitePath = args[0]
+	default:
+		return fmt.Errorf("usage: boba route test --suite [--routes routes.yaml] [routes.test.yaml]")
+	}
+
+	routes, err := config.LoadRoutesFile(routesPath)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", filepath.Base(routesPath), err)
+	}
+	suite, err := routing.LoadSuite(suitePath)
+	if err != nil {
+		return fmt.Errorf("load suite: %w", err)
+	}
+	results, err := routing.RunSuite(routes, suite)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(suitePath), err)
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Passed() {
+			fmt.Printf("%s %s -> %s\n", statusOK, result.Case.Name, describeDecision(result.Decision))
+			continue
+		}
+		failed++
+		fmt.Printf("%s %s -> %s\n", statusError, result.Case.Name, describeDecision(result.Decision))
+		for _, failure := range result.Failures {
+			fmt.Printf("    %s\n", failure)
+		}
+	}
+
+	fmt.Printf("\n%s: %d passed, %d failed\n", filepath.Base(suitePath), len(results)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d routing case(s) failed", failed, len(results))
+	}
+	return nil
+}
+
+// describeDecision names the profile a decision chose and what chose it
+func describeDecision(decision *routing.Decision) string {
+	switch {
+	case decision.ProfileKey == "":
+		return "no profile"
+	case decision.SubAgent != "":
+		return fmt.Sprintf("%s (sub-agent %s)", decision.ProfileKey, decision.SubAgent)
+	case decision.RuleID != "":
+		return fmt.Sprintf("%s (rule %s)", decision.ProfileKey, decision.RuleID)
+	default:
+		return fmt.Sprintf("%s (no match)", decision.ProfileKey)
+	}
+}
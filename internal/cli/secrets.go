@@ -1,7 +1,9 @@
 package cli
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"text/tabwriter"
 
@@ -22,8 +24,10 @@ func runSecrets(home string, args []string) error {
 		return runSecretsSet(home, args[1:])
 	case "remove", "rm", "delete":
 		return runSecretsRemove(home, args[1:])
+	case "add-key":
+		return runSecretsAddKey(home, args[1:])
 	default:
-		return fmt.Errorf("unknown secrets subcommand: %s\n\nUsage:\n  boba secrets list          List configured secrets\n  boba secrets set <provider>   Set API key for a provider\n  boba secrets remove <provider> Remove API key for a provider", args[0])
+		return fmt.Errorf("unknown secrets subcommand: %s\n\nUsage:\n  boba secrets list          List configured secrets\n  boba secrets set <provider>   Set API key for a provider\n  boba secrets add-key <provider> Add a key to a provider's key pool\n  boba secrets remove <provider> Remove API key for a provider", args[0])
 	}
 }
 
@@ -73,9 +77,12 @@ func runSecretsList(home string, _ []string) error {
 		}
 
 		// Check if key exists in secrets.yaml
-		if _, ok := secrets.Secrets[provider.ID]; ok {
+		if secret, ok := secrets.Secrets[provider.ID]; ok {
 			status = "✓ Set"
 			source = "secrets.yaml"
+			if len(secret.Keys) > 0 {
+				source = fmt.Sprintf("secrets.yaml (pool: %d extra keys)", len(secret.Keys))
+			}
 		}
 
 		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", provider.ID, status, source); err != nil {
@@ -195,17 +202,99 @@ func saveAPIKey(home, providerID, apiKey string) error {
 		secrets.Secrets = make(map[string]core.Secret)
 	}
 
-	secrets.Secrets[providerID] = core.Secret{
-		APIKey: apiKey,
+	// Preserve any pooled keys and metadata when replacing the primary key
+	secret := secrets.Secrets[providerID]
+	secret.APIKey = apiKey
+	secrets.Secrets[providerID] = secret
+
+	if err := core.SaveSecrets(home, secrets); err != nil {
+		return fmt.Errorf("failed to save secrets: %w", err)
+	}
+
+	return nil
+}
+
+// runSecretsAddKey appends a key to a provider's key pool for proxy load balancing
+func runSecretsAddKey(home string, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: boba secrets add-key <provider-id> [--key <key>] [--label <name>] [--weight <n>] [--rpm <n>]")
+	}
+
+	providerID := args[0]
+	flags := flag.NewFlagSet("secrets add-key", flag.ContinueOnError)
+	keyFlag := flags.String("key", "", "API key to add (prompted when omitted)")
+	label := flags.String("label", "", "human-readable key name")
+	weight := flags.Int("weight", 1, "relative share of traffic")
+	rpm := flags.Int("rpm", 0, "requests per minute allowed on this key (0 = unlimited)")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	providers, err := core.LoadProviders(home)
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+	provider, err := findProvider(providers, providerID)
+	if err != nil {
+		return err
+	}
+
+	apiKey := *keyFlag
+	if apiKey == "" {
+		apiKey, err = promptForAPIKey(provider.DisplayName)
+		if err != nil {
+			return err
+		}
+	}
+	if apiKey == "" {
+		return fmt.Errorf("API key cannot be empty")
+	}
+
+	secrets, err := core.LoadSecrets(home)
+	if err != nil {
+		return fmt.Errorf("failed to load secrets: %w", err)
+	}
+	if secrets.Secrets == nil {
+		secrets.Secrets = make(map[string]core.Secret)
+	}
+
+	secret := secrets.Secrets[providerID]
+	fingerprint := core.KeyFingerprint(apiKey)
+	if core.KeyFingerprint(secret.APIKey) == fingerprint {
+		return fmt.Errorf("key %s is already the primary key for %s", fingerprint, providerID)
+	}
+	for _, existing := range secret.Keys {
+		if core.KeyFingerprint(existing.APIKey) == fingerprint {
+			return fmt.Errorf("key %s is already in the pool for %s", fingerprint, providerID)
+		}
 	}
+	secret.Keys = append(secret.Keys, core.PoolKey{
+		APIKey:       apiKey,
+		Label:        *label,
+		Weight:       *weight,
+		RateLimitRPM: *rpm,
+	})
+	secrets.Secrets[providerID] = secret
 
 	if err := core.SaveSecrets(home, secrets); err != nil {
 		return fmt.Errorf("failed to save secrets: %w", err)
 	}
 
+	fmt.Println("✓ Key added to pool")
+	fmt.Printf("  Provider:    %s\n", provider.DisplayName)
+	fmt.Printf("  Fingerprint: %s\n", fingerprint)
+	fmt.Printf("  Pool size:   %d\n", len(secret.Keys)+boolToInt(secret.APIKey != ""))
 	return nil
 }
 
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 // printSuccessMessage prints a success message after saving an API key
 func printSuccessMessage(provider *core.Provider) {
 	fmt.Println("✓ API key saved")
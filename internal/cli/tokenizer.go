@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+
+	"github.com/royisme/bobamixer/internal/domain/stats"
+	"github.com/royisme/bobamixer/internal/domain/tokenizer"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+// runTokenizer handles token estimator commands
+func runTokenizer(home string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("tokenizer subcommand required: calibrate")
+	}
+
+	switch args[0] {
+	case "calibrate":
+		return runTokenizerCalibrate(home, args[1:])
+	default:
+		return fmt.Errorf("unknown tokenizer subcommand: %s", args[0])
+	}
+}
+
+// runTokenizerCalibrate fits per-model estimation coefficients from the exact counts
+// the proxy logged and saves them for the estimator
+func runTokenizerCalibrate(home string, args []string) error {
+	flags := flag.NewFlagSet("tokenizer calibrate", flag.ContinueOnError)
+	days := flags.Int("days", 90, "number of days of usage to fit")
+	minSamples := flags.Int("min-samples", tokenizer.MinCalibrationSamples, "fewest exact counts needed to fit a model")
+	dryRun := flags.Bool("dry-run", false, "report the fit without saving it")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *days < 1 {
+		return fmt.Errorf("--days must be at least 1")
+	}
+
+	db, err := sqlite.Open(filepath.Join(home, "usage.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	samples, err := stats.TokenizerSamples(context.Background(), db, time.Now().AddDate(0, 0, -*days))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Tokenizer Calibration (last %d days)\n", *days)
+	fmt.Println("===================================")
+	if len(samples) == 0 {
+		fmt.Println("No exact counts with prompt sizes recorded yet. Route traffic through boba proxy serve first.")
+		return nil
+	}
+
+	path := filepath.Join(home, tokenizer.CalibrationFile)
+	cal, err := tokenizer.LoadCalibration(path)
+	if err != nil {
+		return err
+	}
+
+	models := make([]string, 0, len(samples))
+	for model := range samples {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	var (
+		headerStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("99")).
+				Padding(0, 1)
+
+		cellStyle = lipgloss.NewStyle().
+				Padding(0, 1)
+	)
+
+	fitted := 0
+	rows := make([][]string, 0, len(models))
+	for _, model := range models {
+		modelSamples := samples[model]
+		before := 0.0
+		for _, s := range modelSamples {
+			before += tokenizer.RelativeError(s.Estimated, s.Exact)
+		}
+		before /= float64(len(modelSamples))
+
+		row := []string{model, fmt.Sprintf("%d", len(modelSamples)), "-", "-", fmt.Sprintf("%.1f%%", before*100), "-", ""}
+		switch {
+		case !tokenizer.Calibratable(model):
+			row[6] = "published tokenizer"
+		case len(modelSamples) < *minSamples:
+			row[6] = fmt.Sprintf("needs %d samples", *minSamples)
+		default:
+			c, ok := tokenizer.Fit(modelSamples)
+			if !ok {
+				row[6] = "too few usable samples"
+				break
+			}
+			cal.Models[model] = c
+			fitted++
+			row[2] = fmt.Sprintf("%.2f", c.CharsPerToken)
+			row[3] = fmt.Sprintf("%.2f", c.CodeRatio)
+			row[5] = fmt.Sprintf("%.1f%%", c.Error*100)
+			row[6] = "fitted"
+		}
+		rows = append(rows, row)
+	}
+
+	t := table.New().
+		Border(lipgloss.HiddenBorder()).
+		Headers("MODEL", "SAMPLES", "CHARS/TOKEN", "CODE RATIO", "ERROR BEFORE", "ERROR AFTER", "STATUS").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return headerStyle
+			}
+			return cellStyle
+		})
+
+	fmt.Println(t)
+	fmt.Println()
+	fmt.Println("Error is the mean absolute difference from the provider's count, as a share of it.")
+
+	if fitted == 0 {
+		fmt.Printf("%s No models had enough samples to fit\n", statusWarning)
+		return nil
+	}
+	if *dryRun {
+		fmt.Printf("%s Dry run: %d model(s) fitted, nothing saved\n", statusOK, fitted)
+		return nil
+	}
+	cal.UpdatedAt = time.Now().UTC()
+	if err := tokenizer.SaveCalibration(path, cal); err != nil {
+		return err
+	}
+	fmt.Printf("%s Saved coefficients for %d model(s) to %s\n", statusOK, fitted, path)
+	return nil
+}
@@ -0,0 +1,137 @@
+// Package bandit learns which profile serves each routing context best.
+//
+// Every routing context (a sub-agent, a rule or an intent; see routing.ContextKey)
+// has one arm per profile. An arm's posterior is a Beta distribution over the
+// reward of sending that context to that profile, where a reward in [0, 1]
+// combines success, latency and cost per token, and optionally explicit feedback.
+// Routing draws from each arm's posterior and takes the highest draw (Thompson
+// sampling), so profiles are tried in proportion to the chance they are best.
+package bandit
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DefaultPriorWeight is how many successful outcomes the profile picked by the
+// sub-agents and rules is credited with before any are observed. Configured
+// routing is trusted until the data says otherwise: with no data another
+// profile outdraws it about 1 time in PriorWeight+2.
+const DefaultPriorWeight = 20
+
+// Arm is the posterior of one profile in one routing context
+type Arm struct {
+	Context   string
+	Profile   string
+	Alpha     float64 // 1 + summed reward
+	Beta      float64 // 1 + summed shortfall (1 - reward)
+	Pulls     int     // Outcomes observed
+	UpdatedAt time.Time
+}
+
+// Mean is the expected reward under the posterior
+func (a Arm) Mean() float64 {
+	return a.Alpha / (a.Alpha + a.Beta)
+}
+
+// Confidence is the half-width of the 95% interval around Mean
+func (a Arm) Confidence() float64 {
+	n := a.Alpha + a.Beta
+	return 1.96 * math.Sqrt(a.Alpha*a.Beta/(n*n*(n+1)))
+}
+
+// Bandit is a Thompson sampler over the arms persisted in a Store. It
+// implements routing.Policy.
+type Bandit struct {
+	store       *Store
+	priorWeight float64
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// New creates a bandit over the store's arms
+func New(store *Store) *Bandit {
+	return &Bandit{
+		store:       store,
+		priorWeight: DefaultPriorWeight,
+		// #nosec G404 -- weak RNG acceptable for Thompson sampling
+		rng: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetSeed makes sampling reproducible
+func (b *Bandit) SetSeed(seed int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rng = rand.New(rand.NewSource(seed)) // #nosec G404 -- reproducible sampling for tests
+}
+
+// Choose draws a reward for every candidate profile in the context and returns
+// the profile with the highest draw. The chosen profile carries the prior weight.
+// When the arms cannot be loaded the chosen profile is kept.
+func (b *Bandit) Choose(key, chosen string, candidates []string) string {
+	if len(candidates) < 2 {
+		return chosen
+	}
+	arms, err := b.store.Arms(key)
+	if err != nil {
+		return chosen
+	}
+	byProfile := make(map[string]Arm, len(arms))
+	for _, arm := range arms {
+		byProfile[arm.Profile] = arm
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	best, bestDraw := chosen, -1.0
+	for _, profile := range candidates {
+		arm, ok := byProfile[profile]
+		if !ok {
+			arm = Arm{Alpha: 1, Beta: 1}
+		}
+		alpha := arm.Alpha
+		if profile == chosen {
+			alpha += b.priorWeight
+		}
+		if draw := sampleBeta(b.rng, alpha, arm.Beta); draw > bestDraw {
+			best, bestDraw = profile, draw
+		}
+	}
+	return best
+}
+
+// sampleBeta draws from Beta(a, b) as the ratio of two gamma draws
+func sampleBeta(rng *rand.Rand, a, b float64) float64 {
+	x := sampleGamma(rng, a)
+	y := sampleGamma(rng, b)
+	if x+y == 0 {
+		return 0.5
+	}
+	return x / (x + y)
+}
+
+// sampleGamma draws from Gamma(shape, 1) with the Marsaglia-Tsang method
+func sampleGamma(rng *rand.Rand, shape float64) float64 {
+	if shape < 1 {
+		// Boost the shape above 1 and scale the draw back down
+		return sampleGamma(rng, shape+1) * math.Pow(rng.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
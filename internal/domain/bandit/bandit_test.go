@@ -0,0 +1,154 @@
+package bandit
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+func newTestStore(t *testing.T) (*Store, *sqlite.DB) {
+	t.Helper()
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return NewStore(db), db
+}
+
+func TestReward(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome Outcome
+		want    float64
+	}{
+		{"failure", Outcome{Success: false, LatencyMS: 10}, 0},
+		{"instant and free", Outcome{Success: true}, 1},
+		{"reference latency", Outcome{Success: true, LatencyMS: 10000}, 0.9},
+		{"reference cost", Outcome{Success: true, Tokens: 1000, Cost: 0.01}, 0.9},
+		{"good feedback", Outcome{Success: true, LatencyMS: 10000, Tokens: 1000, Cost: 0.01, Feedback: 1}, 0.9},
+		{"bad feedback", Outcome{Success: true, Feedback: -1}, 0.5},
+		{"good feedback on failure", Outcome{Feedback: 1}, 0.5},
+	}
+	for _, tt := range tests {
+		if got := Reward(tt.outcome, DefaultWeights); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: Reward = %.3f, want %.3f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestArmConfidenceNarrowsWithOutcomes(t *testing.T) {
+	fresh := Arm{Alpha: 1, Beta: 1}
+	seasoned := Arm{Alpha: 81, Beta: 21}
+	if fresh.Mean() != 0.5 {
+		t.Errorf("fresh mean = %.2f, want 0.5", fresh.Mean())
+	}
+	if seasoned.Confidence() >= fresh.Confidence() {
+		t.Errorf("confidence %.3f after 100 outcomes is not narrower than %.3f", seasoned.Confidence(), fresh.Confidence())
+	}
+}
+
+func TestLearnFoldsRoutedSessionsOnce(t *testing.T) {
+	store, db := newTestStore(t)
+	now := time.Now().Unix()
+	seed := fmt.Sprintf(`INSERT INTO sessions (id, started_at, ended_at, profile, success, latency_ms, route_key) VALUES
+			('ok', %[1]d, %[1]d, 'quick', 1, 0, 'rule:format'),
+			('failed', %[1]d, %[1]d, 'heavy', 0, 0, 'rule:format'),
+			('unrouted', %[1]d, %[1]d, 'quick', 1, 0, ''),
+			('running', %[1]d, NULL, 'quick', 0, 0, 'rule:format');
+		INSERT INTO usage_records (id, session_id, ts, input_tokens, output_tokens, input_cost, output_cost)
+			VALUES ('u1', 'ok', %[1]d, 500, 500, 0.01, 0);`, now)
+	if err := db.Exec(seed); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	learned, err := store.Learn(DefaultWeights)
+	if err != nil || learned != 2 {
+		t.Fatalf("Learn = %d, %v; want 2 sessions", learned, err)
+	}
+	if learned, _ := store.Learn(DefaultWeights); learned != 0 { //nolint:errcheck // checked above
+		t.Errorf("second Learn folded %d sessions again", learned)
+	}
+
+	arms, err := store.Arms("rule:format")
+	if err != nil || len(arms) != 2 {
+		t.Fatalf("Arms = %v, %v; want quick and heavy", arms, err)
+	}
+	quick, heavy := arms[0], arms[1]
+	if quick.Profile != "quick" || heavy.Profile != "heavy" {
+		t.Fatalf("arms not ordered by reward: %+v", arms)
+	}
+	// quick: success at reference cost, reward 0.9 on top of Beta(1, 1)
+	if math.Abs(quick.Alpha-1.9) > 1e-6 || math.Abs(quick.Beta-1.1) > 1e-6 || quick.Pulls != 1 {
+		t.Errorf("quick arm = %+v, want Beta(1.9, 1.1) after one outcome", quick)
+	}
+	if heavy.Alpha != 1 || heavy.Beta != 2 {
+		t.Errorf("heavy arm = %+v, want Beta(1, 2) after one failure", heavy)
+	}
+}
+
+func TestChooseFollowsEvidence(t *testing.T) {
+	store, _ := newTestStore(t)
+	for i := 0; i < 50; i++ {
+		if err := store.Observe("rule:review", "heavy", 0.1); err != nil {
+			t.Fatalf("Observe: %v", err)
+		}
+		if err := store.Observe("rule:review", "balanced", 0.9); err != nil {
+			t.Fatalf("Observe: %v", err)
+		}
+	}
+
+	b := New(store)
+	b.SetSeed(1)
+	candidates := []string{"balanced", "heavy"}
+	picks := map[string]int{}
+	for i := 0; i < 100; i++ {
+		picks[b.Choose("rule:review", "heavy", candidates)]++
+	}
+	if picks["balanced"] < 90 {
+		t.Errorf("balanced picked %d/100 times despite far better outcomes", picks["balanced"])
+	}
+
+	// Without evidence the configured profile keeps nearly every request
+	picks = map[string]int{}
+	for i := 0; i < 1000; i++ {
+		picks[b.Choose("rule:other", "heavy", candidates)]++
+	}
+	if picks["heavy"] < 900 || picks["balanced"] == 0 {
+		t.Errorf("picks without evidence = %v, want mostly heavy with some exploration", picks)
+	}
+}
+
+func TestLearnCorrectsArmWhenRatedLater(t *testing.T) {
+	store, db := newTestStore(t)
+	now := time.Now().Unix()
+	if err := db.Exec(fmt.Sprintf(`INSERT INTO sessions (id, started_at, ended_at, profile, success, latency_ms, route_key)
+		VALUES ('s1', %[1]d, %[1]d, 'quick', 1, 0, 'intent:format');`, now)); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if _, err := store.Learn(DefaultWeights); err != nil {
+		t.Fatalf("Learn: %v", err)
+	}
+
+	// Rated bad after the fact: the reward of 1 becomes 0.5
+	if err := db.Exec(fmt.Sprintf(`INSERT INTO feedback (session_id, ts, rating) VALUES ('s1', %d, -1);`, now)); err != nil {
+		t.Fatalf("rate: %v", err)
+	}
+	if learned, err := store.Learn(DefaultWeights); err != nil || learned != 1 {
+		t.Fatalf("Learn after rating = %d, %v; want 1 rescored", learned, err)
+	}
+	if learned, _ := store.Learn(DefaultWeights); learned != 0 { //nolint:errcheck // checked above
+		t.Errorf("unchanged rating rescored %d sessions", learned)
+	}
+
+	arms, err := store.Arms("intent:format")
+	if err != nil || len(arms) != 1 {
+		t.Fatalf("Arms = %v, %v", arms, err)
+	}
+	if arm := arms[0]; math.Abs(arm.Alpha-1.5) > 1e-6 || math.Abs(arm.Beta-1.5) > 1e-6 || arm.Pulls != 1 {
+		t.Errorf("arm = %+v, want Beta(1.5, 1.5) from one outcome", arm)
+	}
+}
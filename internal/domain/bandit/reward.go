@@ -0,0 +1,63 @@
+package bandit
+
+// Outcome is what a routed session produced
+type Outcome struct {
+	Success   bool
+	LatencyMS int64
+	Tokens    int     // Input plus output tokens
+	Cost      float64 // USD
+	Feedback  int     // +1 good, -1 bad, 0 none
+}
+
+// Weights shape the reward of an outcome. Latency and cost score 1 when free
+// and 0.5 at their reference values, falling off smoothly beyond.
+type Weights struct {
+	Success         float64
+	Latency         float64
+	Cost            float64
+	LatencyRefMS    float64 // Latency that scores 0.5
+	CostRefPer1KTok float64 // USD per 1K tokens that scores 0.5
+}
+
+// DefaultWeights favor getting the job done, then speed and price equally
+var DefaultWeights = Weights{
+	Success:         0.6,
+	Latency:         0.2,
+	Cost:            0.2,
+	LatencyRefMS:    10000,
+	CostRefPer1KTok: 0.01,
+}
+
+// Reward scores an outcome in [0, 1]. A failure scores 0 however fast or cheap
+// it was. Explicit feedback, when given, counts as much as everything else:
+// good lifts the reward halfway to 1, bad halves it.
+func Reward(o Outcome, w Weights) float64 {
+	reward := 0.0
+	if o.Success {
+		total := w.Success + w.Latency + w.Cost
+		if total <= 0 {
+			return 0
+		}
+		latency := reference(float64(o.LatencyMS), w.LatencyRefMS)
+		cost := 1.0
+		if o.Tokens > 0 {
+			cost = reference(o.Cost*1000/float64(o.Tokens), w.CostRefPer1KTok)
+		}
+		reward = (w.Success + w.Latency*latency + w.Cost*cost) / total
+	}
+	switch {
+	case o.Feedback > 0:
+		reward = (reward + 1) / 2
+	case o.Feedback < 0:
+		reward /= 2
+	}
+	return reward
+}
+
+// reference scores a non-negative quantity: 1 at zero, 0.5 at ref
+func reference(value, ref float64) float64 {
+	if value <= 0 || ref <= 0 {
+		return 1
+	}
+	return ref / (ref + value)
+}
@@ -0,0 +1,151 @@
+package bandit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+// Store persists arms in the bandit_arms table
+type Store struct {
+	db *sqlite.DB
+}
+
+// NewStore creates an arm store
+func NewStore(db *sqlite.DB) *Store {
+	return &Store{db: db}
+}
+
+// Arms returns the arms of a routing context, or of every context when key is
+// empty, ordered by context and then by estimated reward
+func (s *Store) Arms(key string) ([]Arm, error) {
+	where := ""
+	if key != "" {
+		where = fmt.Sprintf("WHERE context = '%s'", sqlEscape(key))
+	}
+	rows, err := s.db.QueryRows(fmt.Sprintf(`SELECT context, profile, alpha, beta, pulls, updated_at
+		FROM bandit_arms %s
+		ORDER BY context, alpha / (alpha + beta) DESC, profile;`, where))
+	if err != nil {
+		return nil, fmt.Errorf("query arms: %w", err)
+	}
+
+	arms := make([]Arm, 0, len(rows))
+	for _, row := range rows {
+		parts := strings.Split(row, "|")
+		if len(parts) < 6 {
+			continue
+		}
+		arm := Arm{
+			Context:   parts[0],
+			Profile:   parts[1],
+			Alpha:     parseFloat(parts[2]),
+			Beta:      parseFloat(parts[3]),
+			Pulls:     int(parseFloat(parts[4])),
+			UpdatedAt: time.Unix(int64(parseFloat(parts[5])), 0),
+		}
+		if arm.Alpha <= 0 || arm.Beta <= 0 {
+			continue
+		}
+		arms = append(arms, arm)
+	}
+	return arms, nil
+}
+
+// Observe folds one reward into an arm
+func (s *Store) Observe(key, profile string, reward float64) error {
+	return s.db.Exec(observeStatement(key, profile, reward, time.Now()))
+}
+
+// Learn folds every finished, routed session not yet rewarded into its arm and
+// returns how many sessions it folded or rescored. Sessions are routed when they
+// carry a route_key and a profile, which boba call --auto sets and the proxy
+// sets when a request is served by the profile routing chose. Their tokens and
+// cost come from their usage records and their feedback from the feedback
+// table. A session rated after it was folded has its arm corrected by the
+// difference, since the posterior is a running sum of rewards.
+func (s *Store) Learn(w Weights) (int, error) {
+	rows, err := s.db.QueryRows(`SELECT s.id, s.route_key, s.profile, COALESCE(s.success, 0), COALESCE(s.latency_ms, 0),
+			COALESCE(SUM(u.input_tokens + u.output_tokens), 0), COALESCE(SUM(u.input_cost + u.output_cost), 0),
+			COALESCE(f.rating, 0), s.rewarded, s.reward
+		FROM sessions s
+		LEFT JOIN usage_records u ON u.session_id = s.id
+		LEFT JOIN feedback f ON f.session_id = s.id
+		WHERE s.route_key != '' AND COALESCE(s.profile, '') != '' AND s.ended_at IS NOT NULL
+		  AND (s.rewarded = 0 OR (f.session_id IS NOT NULL AND s.reward >= 0))
+		GROUP BY s.id;`)
+	if err != nil {
+		return 0, fmt.Errorf("query routed sessions: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	folded := 0
+	statements := []string{"BEGIN;"}
+	for _, row := range rows {
+		parts := strings.Split(row, "|")
+		if len(parts) < 10 {
+			continue
+		}
+		key, profile := parts[1], parts[2]
+		reward := Reward(Outcome{
+			Success:   parseFloat(parts[3]) == 1,
+			LatencyMS: int64(parseFloat(parts[4])),
+			Tokens:    int(parseFloat(parts[5])),
+			Cost:      parseFloat(parts[6]),
+			Feedback:  int(parseFloat(parts[7])),
+		}, w)
+
+		if parseFloat(parts[8]) == 0 {
+			statements = append(statements, observeStatement(key, profile, reward, now))
+		} else {
+			delta := reward - parseFloat(parts[9])
+			if math.Abs(delta) < 1e-6 {
+				continue
+			}
+			statements = append(statements, fmt.Sprintf(`UPDATE bandit_arms
+				SET alpha = MAX(alpha + %[1]f, 0.001), beta = MAX(beta - %[1]f, 0.001), updated_at = %[2]d
+				WHERE context = '%[3]s' AND profile = '%[4]s';`,
+				delta, now.Unix(), sqlEscape(key), sqlEscape(profile)))
+		}
+		statements = append(statements, fmt.Sprintf("UPDATE sessions SET rewarded = 1, reward = %f WHERE id = '%s';",
+			reward, sqlEscape(parts[0])))
+		folded++
+	}
+	if folded == 0 {
+		return 0, nil
+	}
+	statements = append(statements, "COMMIT;")
+	if err := s.db.Exec(strings.Join(statements, "\n")); err != nil {
+		return 0, fmt.Errorf("update arms: %w", err)
+	}
+	return folded, nil
+}
+
+// observeStatement upserts an arm, starting new ones from the uniform Beta(1, 1)
+func observeStatement(key, profile string, reward float64, now time.Time) string {
+	reward = min(max(reward, 0), 1)
+	return fmt.Sprintf(`INSERT INTO bandit_arms (context, profile, alpha, beta, pulls, updated_at)
+		VALUES ('%[1]s', '%[2]s', 1 + %[3]f, 1 + %[4]f, 1, %[5]d)
+		ON CONFLICT(context, profile) DO UPDATE SET
+			alpha = alpha + %[3]f, beta = beta + %[4]f, pulls = pulls + 1, updated_at = %[5]d;`,
+		sqlEscape(key), sqlEscape(profile), reward, 1-reward, now.Unix())
+}
+
+func parseFloat(raw string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func sqlEscape(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
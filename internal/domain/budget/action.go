@@ -0,0 +1,129 @@
+package budget
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Over-cap actions decide what happens to a request that would exceed a
+// budget, from the strictest to the most lenient
+const (
+	ActionBlock     = "block"     // Refuse the request
+	ActionOverride  = "override"  // Refuse it unless `boba budget allow` granted an override
+	ActionDowngrade = "downgrade" // Send it to the budget's downgrade model instead
+	ActionWarn      = "warn"      // Let it through with a warning
+)
+
+var actions = []string{ActionBlock, ActionOverride, ActionDowngrade, ActionWarn}
+
+// ParseAction validates an over-cap action. Downgrades need the model to
+// downgrade to.
+func ParseAction(action, model string) (string, error) {
+	action = strings.ToLower(action)
+	known := false
+	for _, a := range actions {
+		known = known || a == action
+	}
+	if !known {
+		return "", fmt.Errorf("unknown over-cap action %q: want %s", action, strings.Join(actions, ", "))
+	}
+	if action == ActionDowngrade && model == "" {
+		return "", fmt.Errorf("the downgrade action needs a model to downgrade to")
+	}
+	return action, nil
+}
+
+// severity orders actions so the strictest of several denials wins. Block
+// outranks override, whose denial suggests 'boba budget allow', which cannot
+// lift a blocking budget.
+func severity(action string) int {
+	switch action {
+	case ActionWarn:
+		return 0
+	case ActionDowngrade:
+		return 1
+	case ActionOverride:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Action is what happens to a request the budget denies
+func (b *Budget) Action() string {
+	if b.OnCap == "" {
+		return ActionBlock
+	}
+	return b.OnCap
+}
+
+// SetAction changes what happens to requests over a budget's limits
+func (t *Tracker) SetAction(budgetID, action, model string) error {
+	action, err := ParseAction(action, model)
+	if err != nil {
+		return err
+	}
+	if action != ActionDowngrade {
+		model = ""
+	}
+	return t.db.Exec(fmt.Sprintf("UPDATE budgets SET on_cap='%s', downgrade_model='%s' WHERE id='%s';",
+		action, escape(model), escape(budgetID)))
+}
+
+// Allow grants an override on a budget until now+d, letting requests over its
+// limits through when its action is override
+func (t *Tracker) Allow(b *Budget, d time.Duration) (time.Time, error) {
+	now := time.Now()
+	until := now.Add(d)
+	if err := t.db.Exec(fmt.Sprintf(
+		"INSERT INTO budget_overrides (budget_id, granted_at, expires_at) VALUES ('%s', %d, %d);",
+		escape(b.ID), now.Unix(), until.Unix())); err != nil {
+		return time.Time{}, err
+	}
+	return until, nil
+}
+
+// OverrideUntil returns when the budget's latest active override expires, or
+// the zero time when none is active
+func (t *Tracker) OverrideUntil(b *Budget) (time.Time, error) {
+	expires, err := t.db.QueryInt(fmt.Sprintf(
+		"SELECT COALESCE(MAX(expires_at), 0) FROM budget_overrides WHERE budget_id='%s' AND expires_at > %d;",
+		escape(b.ID), time.Now().Unix()))
+	if err != nil || expires == 0 {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(expires), 0), nil
+}
+
+// Action is what happens to the denied request, see Action*
+func (d *Denial) Action() string {
+	return d.Status.Budget.Action()
+}
+
+// Model is the model a downgrade sends the request to
+func (d *Denial) Model() string {
+	return d.Status.Budget.DowngradeModel
+}
+
+// Hint tells the user how to get going again, for error messages tools show
+func (d *Denial) Hint() string {
+	b := d.Status.Budget
+	if d.Action() == ActionOverride {
+		return "Run 'boba budget allow --for 30m' to continue past the cap."
+	}
+	flags := fmt.Sprintf("--scope %s", b.Scope)
+	if b.Target != "" {
+		flags += fmt.Sprintf(" --target '%s'", b.Target)
+	}
+	// Limits are in the budget's currency, e.g. --cap <cny>
+	unit := strings.ToLower(b.Currency)
+	if unit == "" {
+		unit = "usd"
+	}
+	if b.Spec().Period == PeriodRolling {
+		return fmt.Sprintf("Raise it with 'boba budget %s --period %s --cap <%s>'.", flags, b.Spec(), unit)
+	}
+	return fmt.Sprintf("Raise it with 'boba budget %s --period %s --cap <%s>' or wait for the period to end on %s.",
+		flags, b.Spec(), unit, time.Unix(b.PeriodEnd, 0).Format("Jan 2"))
+}
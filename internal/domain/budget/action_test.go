@@ -0,0 +1,125 @@
+package budget
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+func TestParseAction(t *testing.T) {
+	for _, action := range []string{"block", "Override", "warn"} {
+		if _, err := ParseAction(action, ""); err != nil {
+			t.Errorf("ParseAction(%q): %v", action, err)
+		}
+	}
+	if got, err := ParseAction("downgrade", "claude-haiku-4-5"); err != nil || got != ActionDowngrade {
+		t.Errorf("ParseAction(downgrade) = %q, %v", got, err)
+	}
+	if _, err := ParseAction("downgrade", ""); err == nil {
+		t.Error("downgrade without a model should fail")
+	}
+	if _, err := ParseAction("throttle", ""); err == nil || !strings.Contains(err.Error(), "unknown over-cap action") {
+		t.Errorf("ParseAction(throttle) = %v", err)
+	}
+}
+
+func TestCheckRequestActions(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	tracker := NewTracker(db)
+	if err := db.Exec(fmt.Sprintf(`INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost, tool, provider)
+		VALUES ('u1', 'u1', %d, 3, 0, 'claude-code', 'zai');`, time.Now().Unix())); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	req := Request{Tool: "claude-code", Provider: "zai"}
+
+	tool, err := tracker.CreateBudgetWithPeriod("tool", "claude-code", PeriodSpec{Period: PeriodDaily}, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, err := tracker.CreateBudgetWithPeriod("provider", "zai", PeriodSpec{Period: PeriodDaily}, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tool.Action() != ActionBlock {
+		t.Errorf("new budgets should block, got %q", tool.Action())
+	}
+
+	// The strictest action wins when several budgets are over
+	if err := tracker.SetAction(tool.ID, ActionWarn, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.SetAction(provider.ID, ActionDowngrade, "glm-4.5-air"); err != nil {
+		t.Fatal(err)
+	}
+	denial, err := tracker.CheckRequest(req, 0)
+	if err != nil {
+		t.Fatalf("CheckRequest: %v", err)
+	}
+	if denial == nil || denial.Action() != ActionDowngrade || denial.Model() != "glm-4.5-air" {
+		t.Fatalf("denial = %+v, want the provider downgrade", denial)
+	}
+
+	// Override budgets deny until an override is granted
+	if err := tracker.SetAction(provider.ID, ActionOverride, "ignored"); err != nil {
+		t.Fatal(err)
+	}
+	provider, err = tracker.GetBudget("provider", "zai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.DowngradeModel != "" {
+		t.Errorf("only downgrades keep a model, got %q", provider.DowngradeModel)
+	}
+	denial, err = tracker.CheckRequest(req, 0)
+	if err != nil {
+		t.Fatalf("CheckRequest: %v", err)
+	}
+	if denial == nil || denial.Action() != ActionOverride || !strings.Contains(denial.Hint(), "boba budget allow") {
+		t.Fatalf("denial = %+v, want the provider override", denial)
+	}
+	if until, _ := tracker.OverrideUntil(provider); !until.IsZero() {
+		t.Errorf("no override granted yet, got %v", until)
+	}
+
+	until, err := tracker.Allow(provider, 30*time.Minute)
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if got, _ := tracker.OverrideUntil(provider); got.Unix() != until.Unix() {
+		t.Errorf("OverrideUntil = %v, want %v", got, until)
+	}
+	denial, err = tracker.CheckRequest(req, 0)
+	if err != nil {
+		t.Fatalf("CheckRequest: %v", err)
+	}
+	if denial == nil || denial.Status.Budget.ID != tool.ID || denial.Action() != ActionWarn {
+		t.Fatalf("denial = %+v, want only the tool warning left", denial)
+	}
+
+	// A blocking budget outranks an override one checked before it, whose
+	// hint would not help
+	global, err := tracker.CreateBudgetWithPeriod("global", "", PeriodSpec{Period: PeriodDaily}, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.SetAction(global.ID, ActionOverride, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.SetAction(tool.ID, ActionBlock, ""); err != nil {
+		t.Fatal(err)
+	}
+	denial, err = tracker.CheckRequest(req, 0)
+	if err != nil {
+		t.Fatalf("CheckRequest: %v", err)
+	}
+	if denial == nil || denial.Status.Budget.ID != tool.ID || denial.Action() != ActionBlock {
+		t.Fatalf("denial = %+v, want the tool block over the global override", denial)
+	}
+}
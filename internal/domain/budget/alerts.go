@@ -4,6 +4,8 @@ package budget
 import (
 	"fmt"
 	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/currency"
 )
 
 // AlertLevel represents the severity of a budget alert
@@ -11,35 +13,33 @@ type AlertLevel int
 
 // Budget alert severity levels
 const (
-	AlertLevelNone     AlertLevel = iota // No alert
-	AlertLevelInfo                       // Informational alert
-	AlertLevelWarning                    // Warning alert
-	AlertLevelCritical                   // Critical alert
-)
-
-const (
-	scopeGlobal  = "global"
-	scopeProfile = "profile"
-	scopeProject = "project"
+	AlertLevelNone      AlertLevel = iota // No alert
+	AlertLevelInfo                        // Informational alert
+	AlertLevelProjected                   // Spending is on course to exceed the cap by the end of the period
+	AlertLevelWarning                     // Warning alert
+	AlertLevelCritical                    // Critical alert
 )
 
 // Alert represents a budget alert/notification
 type Alert struct {
-	Timestamp  time.Time
-	CurrentUSD float64 // current spending
-	LimitUSD   float64 // budget limit that was exceeded
-	Percent    float64 // percentage of budget used
-	Title      string
-	Message    string
-	Scope      string // "global", "project", "profile"
-	Target     string // project name or profile name
-	Level      AlertLevel
+	Timestamp time.Time
+	Current   float64 // current spending
+	Limit     float64 // budget limit that was exceeded
+	Currency  string  // of Current and Limit; empty is USD
+	Percent   float64 // percentage of budget used
+	Title     string
+	Message   string
+	Scope     string // budget scope, see Budget.Scope
+	Target    string // budget target, see Budget.Target
+	Period    string // budget period the alert belongs to, e.g. monthly:2026-10-01
+	Level     AlertLevel
 }
 
 // AlertConfig represents alert configuration
 type AlertConfig struct {
 	EnableDaily     bool
 	EnableCap       bool
+	EnableForecast  bool    // Alert when the projected end-of-period spend exceeds the cap
 	WarningPercent  float64 // Percentage to trigger warning (e.g., 80)
 	CriticalPercent float64 // Percentage to trigger critical (e.g., 100)
 }
@@ -49,6 +49,7 @@ func DefaultAlertConfig() *AlertConfig {
 	return &AlertConfig{
 		EnableDaily:     true,
 		EnableCap:       true,
+		EnableForecast:  true,
 		WarningPercent:  80.0,
 		CriticalPercent: 100.0,
 	}
@@ -74,9 +75,10 @@ func NewAlertManager(tracker *Tracker, config *AlertConfig) *AlertManager {
 	}
 }
 
-// CheckBudgetAlerts checks budget status and generates alerts if needed
+// CheckBudgetAlerts checks the status of every active budget of a scope and
+// generates alerts if needed
 func (am *AlertManager) CheckBudgetAlerts(scope, target string) []Alert {
-	status, err := am.tracker.GetStatus(scope, target)
+	statuses, err := am.tracker.Statuses(scope, target)
 	if err != nil {
 		// No budget configured, no alerts
 		return nil
@@ -84,34 +86,55 @@ func (am *AlertManager) CheckBudgetAlerts(scope, target string) []Alert {
 
 	var alerts []Alert
 
-	// Check daily limit
-	if am.config.EnableDaily && status.DailyLimit > 0 {
+	// Check daily limit; today's spending is the same for every period, so
+	// only the lowest daily limit can raise an alert
+	var daily *Status
+	for _, status := range statuses {
+		if status.DailyLimit > 0 && (daily == nil || status.DailyLimit < daily.DailyLimit) {
+			daily = status
+		}
+	}
+	if am.config.EnableDaily && daily != nil {
 		dailyAlert := am.checkThreshold(
-			status.DailyProgress,
-			status.CurrentSpent,
-			status.DailyLimit,
+			daily.DailyProgress,
+			daily.CurrentSpent,
+			daily.DailyLimit,
+			daily.Budget.Currency,
 			scope,
 			target,
 			"daily",
 		)
 		if dailyAlert != nil {
+			dailyAlert.Period = "daily:" + time.Now().Format(dateLayout)
 			alerts = append(alerts, *dailyAlert)
 		}
 	}
 
-	// Check hard cap
-	if am.config.EnableCap && status.HardCap > 0 {
-		totalSpent := status.Budget.SpentUSD
-		capAlert := am.checkThreshold(
-			status.TotalProgress,
-			totalSpent,
-			status.HardCap,
-			scope,
-			target,
-			"cap",
-		)
-		if capAlert != nil {
-			alerts = append(alerts, *capAlert)
+	for _, status := range statuses {
+		// Check hard cap of the budget's period
+		if am.config.EnableCap && status.HardCap > 0 {
+			totalSpent := status.Budget.Spent
+			capAlert := am.checkThreshold(
+				status.TotalProgress,
+				totalSpent,
+				status.HardCap,
+				status.Budget.Currency,
+				scope,
+				target,
+				status.Budget.Spec().Label(),
+			)
+			if capAlert != nil {
+				capAlert.Period = status.Budget.periodKey()
+				alerts = append(alerts, *capAlert)
+			}
+		}
+
+		// Warn ahead of the cap when spending is on course to cross it
+		if am.config.EnableForecast && status.HardCap > 0 && status.TotalProgress < am.config.CriticalPercent {
+			if alert := am.checkForecast(status, scope, target); alert != nil {
+				alert.Period = status.Budget.periodKey()
+				alerts = append(alerts, *alert)
+			}
 		}
 	}
 
@@ -121,63 +144,68 @@ func (am *AlertManager) CheckBudgetAlerts(scope, target string) []Alert {
 	return alerts
 }
 
-// checkThreshold checks if a threshold has been exceeded
+// checkThreshold checks if a threshold has been exceeded. limitType is "daily"
+// or the label of the period a hard cap applies to; amounts are in code.
 func (am *AlertManager) checkThreshold(
 	percent float64,
 	current float64,
 	limit float64,
+	code string,
 	scope string,
 	target string,
 	limitType string,
 ) *Alert {
 	var alert *Alert
+	spent, capped := currency.Format(current, code, 2), currency.Format(limit, code, 2)
 
 	if percent >= am.config.CriticalPercent {
 		alert = &Alert{
-			Level:      AlertLevelCritical,
-			Timestamp:  time.Now(),
-			Scope:      scope,
-			Target:     target,
-			CurrentUSD: current,
-			LimitUSD:   limit,
-			Percent:    percent,
+			Level:     AlertLevelCritical,
+			Timestamp: time.Now(),
+			Scope:     scope,
+			Target:    target,
+			Current:   current,
+			Limit:     limit,
+			Currency:  code,
+			Percent:   percent,
 		}
 
 		if limitType == "daily" {
 			alert.Title = "Daily Budget Exceeded"
 			alert.Message = fmt.Sprintf(
-				"Daily spending ($%.2f) has exceeded the limit ($%.2f) by %.1f%%",
-				current, limit, percent-100,
+				"Daily spending (%s) has exceeded the limit (%s) by %.1f%%",
+				spent, capped, percent-100,
 			)
 		} else {
 			alert.Title = "Budget Cap Exceeded"
 			alert.Message = fmt.Sprintf(
-				"Total spending ($%.2f) has exceeded the hard cap ($%.2f)",
-				current, limit,
+				"Total spending (%s) has exceeded the %s hard cap (%s)",
+				spent, limitType, capped,
 			)
 		}
 	} else if percent >= am.config.WarningPercent {
 		alert = &Alert{
-			Level:      AlertLevelWarning,
-			Timestamp:  time.Now(),
-			Scope:      scope,
-			Target:     target,
-			CurrentUSD: current,
-			LimitUSD:   limit,
-			Percent:    percent,
+			Level:     AlertLevelWarning,
+			Timestamp: time.Now(),
+			Scope:     scope,
+			Target:    target,
+			Current:   current,
+			Limit:     limit,
+			Currency:  code,
+			Percent:   percent,
 		}
 
 		if limitType == "daily" {
 			alert.Title = "Approaching Daily Budget Limit"
 			alert.Message = fmt.Sprintf(
-				"Daily spending is at %.0f%% of the limit ($%.2f / $%.2f)",
-				percent, current, limit,
+				"Daily spending is at %.0f%% of the limit (%s / %s)",
+				percent, spent, capped,
 			)
 		} else {
 			alert.Title = "Approaching Budget Cap"
 			alert.Message = fmt.Sprintf(
-				"Total spending is at %.0f%% of the hard cap ($%.2f / $%.2f)",
-				percent, current, limit,
+				"Total spending is at %.0f%% of the %s hard cap (%s / %s)",
+				percent, limitType, spent, capped,
 			)
 		}
 	}
@@ -185,6 +213,34 @@ func (am *AlertManager) checkThreshold(
 	return alert
 }
 
+// checkForecast raises a projected alert when the budget's end-of-period
+// projection exceeds its hard cap. Projections fitted on less than a few days
+// of history are too noisy to alert on.
+func (am *AlertManager) checkForecast(status *Status, scope, target string) *Alert {
+	projection, err := am.tracker.Project(status, time.Now())
+	if err != nil || projection.HistoryDays < minForecastDays || !projection.Overrun() {
+		return nil
+	}
+	code := status.Budget.Currency
+	return &Alert{
+		Level:     AlertLevelProjected,
+		Timestamp: time.Now(),
+		Scope:     scope,
+		Target:    target,
+		Current:   status.Budget.Spent,
+		Limit:     status.HardCap,
+		Currency:  code,
+		Percent:   status.TotalProgress,
+		Title:     "Projected Budget Overrun",
+		Message: fmt.Sprintf(
+			"At the current pace spending will reach %s (%s-%s) by %s, over the %s hard cap (%s)",
+			currency.Format(projection.Expected, code, 2), currency.Format(projection.Low, code, 2),
+			currency.Format(projection.High, code, 2), projection.At.Format("Jan 2"),
+			status.Budget.Spec().Label(), currency.Format(status.HardCap, code, 2),
+		),
+	}
+}
+
 // GetRecentAlerts returns recent alerts (last N)
 func (am *AlertManager) GetRecentAlerts(count int) []Alert {
 	if count <= 0 || len(am.history) == 0 {
@@ -223,6 +279,8 @@ func (alert *Alert) FormatAlert() string {
 		level = "🔴 CRITICAL"
 	case AlertLevelWarning:
 		level = "🟡 WARNING"
+	case AlertLevelProjected:
+		level = "🟠 PROJECTED"
 	case AlertLevelInfo:
 		level = "🔵 INFO"
 	default:
@@ -235,19 +293,21 @@ func (alert *Alert) FormatAlert() string {
 		scopeInfo = fmt.Sprintf("Profile: %s", alert.Target)
 	case scopeProject:
 		scopeInfo = fmt.Sprintf("Project: %s", alert.Target)
-	default:
+	case scopeGlobal, "":
 		scopeInfo = "Global Budget"
+	default:
+		scopeInfo = fmt.Sprintf("Scope: %s", scopeName(alert.Scope, alert.Target))
 	}
 
 	return fmt.Sprintf(
-		"%s - %s\n%s\n%s\n%.1f%% of budget used ($%.2f / $%.2f)\nTime: %s",
+		"%s - %s\n%s\n%s\n%.1f%% of budget used (%s / %s)\nTime: %s",
 		level,
 		alert.Title,
 		scopeInfo,
 		alert.Message,
 		alert.Percent,
-		alert.CurrentUSD,
-		alert.LimitUSD,
+		currency.Format(alert.Current, alert.Currency, 2),
+		currency.Format(alert.Limit, alert.Currency, 2),
 		alert.Timestamp.Format("2006-01-02 15:04:05"),
 	)
 }
@@ -270,6 +330,9 @@ func (alert *Alert) GetSuggestion() string {
 	case AlertLevelWarning:
 		return "You're approaching your budget limit. Monitor your usage closely."
 
+	case AlertLevelProjected:
+		return "At this pace you'll run past the cap before the period ends. Consider cheaper profiles or raising the cap."
+
 	default:
 		return "Keep track of your spending to stay within budget."
 	}
@@ -282,9 +345,86 @@ func (level AlertLevel) String() string {
 		return "critical"
 	case AlertLevelWarning:
 		return "warning"
+	case AlertLevelProjected:
+		return "projected"
 	case AlertLevelInfo:
 		return "info"
 	default:
 		return "none"
 	}
 }
+
+// Budget hints grade spending for routing decisions
+const (
+	HintNormal  = "normal"   // Below the warning threshold, or no budget configured
+	HintNearCap = "near_cap" // At or above the warning threshold
+	HintOverCap = "over_cap" // At or above the critical threshold
+)
+
+// UsedPercent returns the larger of daily and hard cap progress, counting only
+// the limits the alert configuration enables
+func (s *Status) UsedPercent(config *AlertConfig) float64 {
+	if config == nil {
+		config = DefaultAlertConfig()
+	}
+	used := 0.0
+	if config.EnableDaily && s.DailyLimit > 0 && s.DailyProgress > used {
+		used = s.DailyProgress
+	}
+	if config.EnableCap && s.HardCap > 0 && s.TotalProgress > used {
+		used = s.TotalProgress
+	}
+	return used
+}
+
+// Hint grades the status against the alert thresholds: over_cap from the
+// critical percentage, near_cap from the warning percentage, normal below
+func (s *Status) Hint(config *AlertConfig) string {
+	if config == nil {
+		config = DefaultAlertConfig()
+	}
+	switch used := s.UsedPercent(config); {
+	case used >= config.CriticalPercent:
+		return HintOverCap
+	case used >= config.WarningPercent:
+		return HintNearCap
+	default:
+		return HintNormal
+	}
+}
+
+// GetHint returns the budget hint and percentage used for a scope.
+// Without a budget the hint is normal.
+func (t *Tracker) GetHint(scope, target string, config *AlertConfig) (string, float64) {
+	status, err := t.GetStatus(scope, target)
+	if err != nil {
+		return HintNormal, 0
+	}
+	return status.Hint(config), status.UsedPercent(config)
+}
+
+// RequestHint returns the budget hint and percentage used of the tightest
+// budget a request matches. Without a matching budget the hint is normal.
+func (t *Tracker) RequestHint(req Request, config *AlertConfig) (string, float64) {
+	statuses, err := t.RequestStatuses(req)
+	if err != nil {
+		return HintNormal, 0
+	}
+	return TightestHint(statuses, config)
+}
+
+// TightestHint returns the budget hint and percentage used of the tightest of
+// statuses, such as a request's from RequestStatuses. Without statuses the
+// hint is normal.
+func TightestHint(statuses []*Status, config *AlertConfig) (string, float64) {
+	if len(statuses) == 0 {
+		return HintNormal, 0
+	}
+	tightest := statuses[0]
+	for _, status := range statuses[1:] {
+		if status.UsedPercent(config) > tightest.UsedPercent(config) {
+			tightest = status
+		}
+	}
+	return tightest.Hint(config), tightest.UsedPercent(config)
+}
@@ -126,7 +126,7 @@ func TestCheckThreshold(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			alert := am.checkThreshold(tt.percent, tt.current, tt.limit, "project", "test", "daily")
+			alert := am.checkThreshold(tt.percent, tt.current, tt.limit, "", "project", "test", "daily")
 
 			if tt.shouldAlert {
 				if alert == nil {
@@ -138,12 +138,12 @@ func TestCheckThreshold(t *testing.T) {
 					t.Errorf("Level = %v, want %v", alert.Level, tt.expectedLevel)
 				}
 
-				if alert.CurrentUSD != tt.current {
-					t.Errorf("CurrentUSD = %f, want %f", alert.CurrentUSD, tt.current)
+				if alert.Current != tt.current {
+					t.Errorf("Current = %f, want %f", alert.Current, tt.current)
 				}
 
-				if alert.LimitUSD != tt.limit {
-					t.Errorf("LimitUSD = %f, want %f", alert.LimitUSD, tt.limit)
+				if alert.Limit != tt.limit {
+					t.Errorf("Limit = %f, want %f", alert.Limit, tt.limit)
 				}
 			} else {
 				if alert != nil {
@@ -168,14 +168,14 @@ func TestGetRecentAlerts(t *testing.T) {
 	// Add some alerts to history
 	for i := 0; i < 5; i++ {
 		alert := Alert{
-			Level:      AlertLevelWarning,
-			Title:      "Test Alert",
-			Timestamp:  time.Now(),
-			Scope:      "project",
-			Target:     "test",
-			CurrentUSD: float64(i),
-			LimitUSD:   10.0,
-			Percent:    float64(i * 10),
+			Level:     AlertLevelWarning,
+			Title:     "Test Alert",
+			Timestamp: time.Now(),
+			Scope:     "project",
+			Target:    "test",
+			Current:   float64(i),
+			Limit:     10.0,
+			Percent:   float64(i * 10),
 		}
 		am.history = append(am.history, alert)
 	}
@@ -263,15 +263,15 @@ func TestClearHistory(t *testing.T) {
 
 func TestAlertFormatAlert(t *testing.T) {
 	alert := Alert{
-		Level:      AlertLevelCritical,
-		Title:      "Test Alert",
-		Message:    "Test message",
-		Timestamp:  time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
-		Scope:      "profile",
-		Target:     "gpt-4",
-		CurrentUSD: 15.0,
-		LimitUSD:   10.0,
-		Percent:    150.0,
+		Level:     AlertLevelCritical,
+		Title:     "Test Alert",
+		Message:   "Test message",
+		Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Scope:     "profile",
+		Target:    "gpt-4",
+		Current:   15.0,
+		Limit:     10.0,
+		Percent:   150.0,
 	}
 
 	formatted := alert.FormatAlert()
@@ -387,3 +387,26 @@ func TestAlertLevelString(t *testing.T) {
 		})
 	}
 }
+
+func TestStatusHint(t *testing.T) {
+	tests := []struct {
+		name   string
+		status *Status
+		config *AlertConfig
+		want   string
+	}{
+		{"below warning", &Status{DailyLimit: 10, DailyProgress: 79}, nil, HintNormal},
+		{"daily at warning", &Status{DailyLimit: 10, DailyProgress: 80}, nil, HintNearCap},
+		{"cap over critical", &Status{DailyLimit: 10, DailyProgress: 20, HardCap: 100, TotalProgress: 101}, nil, HintOverCap},
+		{"daily disabled", &Status{DailyLimit: 10, DailyProgress: 150}, &AlertConfig{EnableCap: true, WarningPercent: 80, CriticalPercent: 100}, HintNormal},
+		{"custom thresholds", &Status{DailyLimit: 10, DailyProgress: 60}, &AlertConfig{EnableDaily: true, WarningPercent: 50, CriticalPercent: 90}, HintNearCap},
+		{"no limits", &Status{DailyProgress: 500}, nil, HintNormal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.status.Hint(tt.config); got != tt.want {
+				t.Errorf("Hint() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package budget
+
+import (
+	"math"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/forecast"
+)
+
+const (
+	// forecastHistoryDays is how far back daily spending is fitted
+	forecastHistoryDays = 56
+
+	// forecastZ widens the projection to an 80% confidence band
+	forecastZ = 1.2816
+
+	// minForecastDays is the history a projection needs before it raises alerts
+	minForecastDays = 3
+)
+
+// Projection is the spending a budget is expected to reach by the end of its
+// period if the recent daily pattern continues, in the budget's currency
+type Projection struct {
+	Status      *Status
+	Method      string    // forecast method, see forecast.Method*
+	HistoryDays int       // days of spending history the forecast was fitted on
+	Expected    float64   // projected spending at the end of the period
+	Low         float64   // lower end of the 80% confidence band
+	High        float64   // upper end of the 80% confidence band
+	At          time.Time // when the projected period ends
+}
+
+// Overrun reports whether the projection exceeds the hard cap
+func (p *Projection) Overrun() bool {
+	return p.Status.HardCap > 0 && p.Expected > p.Status.HardCap
+}
+
+// Forecasts projects every active budget of a scope to the end of its period
+func (t *Tracker) Forecasts(scope, target string) ([]*Projection, error) {
+	statuses, err := t.Statuses(scope, target)
+	if err != nil {
+		return nil, err
+	}
+	projections := make([]*Projection, 0, len(statuses))
+	for _, status := range statuses {
+		projection, err := t.Project(status, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		projections = append(projections, projection)
+	}
+	return projections, nil
+}
+
+// Project forecasts a budget's spending at the end of its current period. Daily
+// spending in the budget's scope, the scoped counterpart of v_daily_summary,
+// is fitted from the first day with spending up to yesterday; the rest of today
+// and the days left in the period are then added to what is already spent.
+// Rolling budgets project the next full window.
+func (t *Tracker) Project(status *Status, now time.Time) (*Projection, error) {
+	b := status.Budget
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	days, err := t.dailySpending(b.Scope, b.Target, today.AddDate(0, 0, -forecastHistoryDays).Unix(), today.Unix()-1, b.Currency)
+	if err != nil {
+		return nil, err
+	}
+	var series []float64
+	var first time.Time
+	for i := forecastHistoryDays; i >= 1; i-- {
+		day := today.AddDate(0, 0, -i)
+		spent, ok := days[day.Format(dateLayout)]
+		if len(series) == 0 {
+			if !ok {
+				continue
+			}
+			first = day
+		}
+		series = append(series, spent)
+	}
+	model := forecast.Fit(series, first.Weekday())
+
+	projection := &Projection{
+		Status:      status,
+		Method:      model.Method,
+		HistoryDays: model.Days,
+	}
+
+	// Whole days still to come after today, and the share of today left
+	var spent float64
+	var ahead int
+	rest := today.AddDate(0, 0, 1).Sub(now).Hours() / 24
+	if b.Spec().Period == PeriodRolling {
+		// The next window starts now and spends nothing yet
+		ahead = b.RollingDays - 1
+		rest = 1
+		projection.At = today.AddDate(0, 0, b.RollingDays)
+	} else {
+		spent = b.Spent
+		end := time.Unix(b.PeriodEnd, 0)
+		endDay := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, now.Location())
+		ahead = int(math.Round(endDay.Sub(today).Hours() / 24))
+		projection.At = end
+	}
+
+	expected := spent + rest*model.Predict(1)
+	for h := 2; h <= ahead+1; h++ {
+		expected += model.Predict(h)
+	}
+	// Daily errors are taken as independent, so the band grows with the root of the days left
+	band := forecastZ * model.Sigma() * math.Sqrt(rest+float64(ahead))
+
+	projection.Expected = expected
+	projection.Low = math.Max(spent, expected-band)
+	projection.High = expected + band
+	return projection, nil
+}
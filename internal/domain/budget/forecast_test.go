@@ -0,0 +1,95 @@
+package budget
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/forecast"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+func TestProjectAndForecastAlert(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	tracker := NewTracker(db)
+
+	// A rolling 7-day cap of $40 against three weeks of $5 a day
+	if _, err := tracker.CreateBudgetWithPeriod("global", "", PeriodSpec{Period: PeriodRolling, Days: 7}, 0, 40); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without history there is nothing to project
+	status, err := tracker.GetStatus("global", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	projection, err := tracker.Project(status, time.Now())
+	if err != nil {
+		t.Fatalf("Project failed: %v", err)
+	}
+	if projection.Method != forecast.MethodNone || projection.Expected != 0 || projection.Overrun() {
+		t.Errorf("projection without history = %+v", projection)
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, time.Local)
+	for d := 1; d <= 21; d++ {
+		stmt := fmt.Sprintf("INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost) VALUES ('u%d', 's', %d, 5.0, 0);",
+			d, today.AddDate(0, 0, -d).Unix())
+		if err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	projections, err := tracker.Forecasts("global", "")
+	if err != nil {
+		t.Fatalf("Forecasts failed: %v", err)
+	}
+	if len(projections) != 1 {
+		t.Fatalf("got %d projections, want 1", len(projections))
+	}
+	p := projections[0]
+	if p.Method != forecast.MethodHoltWinters || p.HistoryDays != 21 {
+		t.Errorf("method = %s over %d days, want holt-winters over 21", p.Method, p.HistoryDays)
+	}
+	// The next 7 days at $5 a day
+	if p.Expected < 33 || p.Expected > 37 {
+		t.Errorf("expected = $%.2f, want about $35", p.Expected)
+	}
+	if p.Low > p.Expected || p.High < p.Expected {
+		t.Errorf("band $%.2f-$%.2f does not contain $%.2f", p.Low, p.High, p.Expected)
+	}
+	if p.Overrun() {
+		t.Error("$35 of a $40 cap is not an overrun")
+	}
+
+	// A custom period starting today has spent nothing yet, but the same pace
+	// over its 14 days overruns a $60 cap, so a projected alert is raised
+	// long before the cap itself is reached
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	custom := PeriodSpec{Period: PeriodCustom, Start: start, End: start.AddDate(0, 0, 13)}
+	if _, err := tracker.CreateBudgetWithPeriod("global", "", custom, 0, 60); err != nil {
+		t.Fatal(err)
+	}
+	alerts := NewAlertManager(tracker, nil).CheckBudgetAlerts("global", "")
+	var projected []Alert
+	for _, alert := range alerts {
+		if alert.Level == AlertLevelProjected {
+			projected = append(projected, alert)
+		}
+	}
+	if len(projected) != 1 {
+		t.Fatalf("got %d projected alerts, want 1 for the custom budget: %+v", len(projected), alerts)
+	}
+	if !strings.Contains(projected[0].Message, custom.Label()+" hard cap ($60.00)") {
+		t.Errorf("message = %q", projected[0].Message)
+	}
+	if projected[0].Level.String() != "projected" || projected[0].ShouldBlock() {
+		t.Errorf("projected alerts must not block: %+v", projected[0])
+	}
+}
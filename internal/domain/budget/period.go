@@ -0,0 +1,116 @@
+package budget
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Period is how a budget measures its window
+type Period string
+
+// Budget periods
+const (
+	PeriodDaily   Period = "daily"   // Calendar day
+	PeriodWeekly  Period = "weekly"  // Monday to Sunday
+	PeriodMonthly Period = "monthly" // Calendar month
+	PeriodCustom  Period = "custom"  // Fixed date range; ends instead of rolling over
+	PeriodRolling Period = "rolling" // Trailing N days ending now
+)
+
+const dateLayout = "2006-01-02"
+
+// PeriodSpec describes a budget's period
+type PeriodSpec struct {
+	Period Period
+	Days   int       // Window length of rolling periods
+	Start  time.Time // First day of custom periods
+	End    time.Time // Last day of custom periods, inclusive
+}
+
+// ParsePeriod reads daily, weekly, monthly, a rolling window such as 7d, or a
+// custom range such as 2026-10-01..2026-12-31. Dates are local.
+func ParsePeriod(s string) (PeriodSpec, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	switch Period(s) {
+	case PeriodDaily, PeriodWeekly, PeriodMonthly:
+		return PeriodSpec{Period: Period(s)}, nil
+	}
+
+	if from, to, ok := strings.Cut(s, ".."); ok {
+		start, err := time.ParseInLocation(dateLayout, from, time.Local)
+		if err != nil {
+			return PeriodSpec{}, fmt.Errorf("invalid period start %q: want YYYY-MM-DD", from)
+		}
+		end, err := time.ParseInLocation(dateLayout, to, time.Local)
+		if err != nil {
+			return PeriodSpec{}, fmt.Errorf("invalid period end %q: want YYYY-MM-DD", to)
+		}
+		if end.Before(start) {
+			return PeriodSpec{}, fmt.Errorf("period ends (%s) before it starts (%s)", to, from)
+		}
+		return PeriodSpec{Period: PeriodCustom, Start: start, End: end}, nil
+	}
+
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n > 0 {
+			return PeriodSpec{Period: PeriodRolling, Days: n}, nil
+		}
+	}
+	return PeriodSpec{}, fmt.Errorf("invalid period %q: want daily, weekly, monthly, Nd or YYYY-MM-DD..YYYY-MM-DD", s)
+}
+
+// String formats the spec the way ParsePeriod reads it
+func (p PeriodSpec) String() string {
+	switch p.Period {
+	case PeriodRolling:
+		return fmt.Sprintf("%dd", p.Days)
+	case PeriodCustom:
+		return p.Start.Format(dateLayout) + ".." + p.End.Format(dateLayout)
+	default:
+		return string(p.Period)
+	}
+}
+
+// Label describes the period for people, e.g. "rolling 7 days"
+func (p PeriodSpec) Label() string {
+	switch p.Period {
+	case PeriodRolling:
+		return fmt.Sprintf("rolling %d days", p.Days)
+	case PeriodCustom:
+		return p.Start.Format(dateLayout) + " to " + p.End.Format(dateLayout)
+	default:
+		return string(p.Period)
+	}
+}
+
+// Bounds returns the window that applies at t, both ends inclusive to the
+// second. Calendar periods return the one containing t, rolling periods the N
+// days ending at t, and custom periods their fixed range.
+func (p PeriodSpec) Bounds(t time.Time) (start, end time.Time) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch p.Period {
+	case PeriodDaily:
+		return day, day.AddDate(0, 0, 1).Add(-time.Second)
+	case PeriodWeekly:
+		// Weeks start on Monday
+		start = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7).Add(-time.Second)
+	case PeriodRolling:
+		return t.AddDate(0, 0, -p.Days), t
+	case PeriodCustom:
+		start = time.Date(p.Start.Year(), p.Start.Month(), p.Start.Day(), 0, 0, 0, 0, p.Start.Location())
+		end = time.Date(p.End.Year(), p.End.Month(), p.End.Day(), 0, 0, 0, 0, p.End.Location())
+		return start, end.AddDate(0, 0, 1).Add(-time.Second)
+	default: // PeriodMonthly
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 1, 0).Add(-time.Second)
+	}
+}
+
+// RollsOver reports whether the period starts again when it ends
+func (p PeriodSpec) RollsOver() bool {
+	return p.Period == PeriodDaily || p.Period == PeriodWeekly || p.Period == PeriodMonthly
+}
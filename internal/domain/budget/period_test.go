@@ -0,0 +1,202 @@
+package budget
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  string
+		label string
+	}{
+		{"monthly", "monthly", "monthly"},
+		{"Weekly", "weekly", "weekly"},
+		{"7d", "7d", "rolling 7 days"},
+		{"2026-10-01..2026-12-31", "2026-10-01..2026-12-31", "2026-10-01 to 2026-12-31"},
+	}
+	for _, tt := range tests {
+		spec, err := ParsePeriod(tt.in)
+		if err != nil {
+			t.Fatalf("ParsePeriod(%q): %v", tt.in, err)
+		}
+		if spec.String() != tt.want || spec.Label() != tt.label {
+			t.Errorf("ParsePeriod(%q) = %q (%q), want %q (%q)", tt.in, spec.String(), spec.Label(), tt.want, tt.label)
+		}
+	}
+
+	for _, bad := range []string{"", "yearly", "0d", "-3d", "2026-12-31..2026-10-01", "2026-13-01..2026-12-31"} {
+		if _, err := ParsePeriod(bad); err == nil {
+			t.Errorf("ParsePeriod(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestPeriodBounds(t *testing.T) {
+	// A Thursday
+	now := time.Date(2026, 10, 15, 13, 30, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		period     string
+		start, end time.Time
+	}{
+		{"daily", day(15), day(16).Add(-time.Second)},
+		{"weekly", day(12), day(19).Add(-time.Second)},
+		{"monthly", day(1), time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC).Add(-time.Second)},
+		{"7d", day(8).Add(13*time.Hour + 30*time.Minute), now},
+	}
+	for _, tt := range tests {
+		spec, err := ParsePeriod(tt.period)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start, end := spec.Bounds(now)
+		if !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("%s bounds = %v..%v, want %v..%v", tt.period, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestRolloverClosesElapsedPeriods(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	tracker := NewTracker(db)
+
+	b, err := tracker.CreateBudgetWithPeriod("global", "", PeriodSpec{Period: PeriodDaily}, 0, 5)
+	if err != nil {
+		t.Fatalf("CreateBudgetWithPeriod failed: %v", err)
+	}
+
+	// Pretend the budget was last read three days ago, with spending on two
+	// of the days since
+	today := time.Now()
+	threeDaysAgo := time.Date(today.Year(), today.Month(), today.Day()-3, 0, 0, 0, 0, time.Local)
+	stmt := fmt.Sprintf(`UPDATE budgets SET period_start=%d, period_end=%d WHERE id='%s';
+		INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost) VALUES ('u1', 's1', %d, 1.0, 0.5);
+		INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost) VALUES ('u2', 's2', %d, 2.0, 0.0);`,
+		threeDaysAgo.Unix(), threeDaysAgo.AddDate(0, 0, 1).Unix()-1, b.ID,
+		threeDaysAgo.Add(time.Hour).Unix(), threeDaysAgo.AddDate(0, 0, 2).Add(time.Hour).Unix())
+	if err := db.Exec(stmt); err != nil {
+		t.Fatalf("seed failed: %v", err)
+	}
+
+	budgets, err := tracker.Budgets("global", "")
+	if err != nil {
+		t.Fatalf("Budgets failed: %v", err)
+	}
+	if len(budgets) != 1 {
+		t.Fatalf("got %d budgets, want 1", len(budgets))
+	}
+	if start, _ := (PeriodSpec{Period: PeriodDaily}).Bounds(today); budgets[0].PeriodStart != start.Unix() {
+		t.Errorf("period start = %v, want today", time.Unix(budgets[0].PeriodStart, 0))
+	}
+
+	records, err := tracker.History(budgets[0], 10)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("got %d periods, want today and three closed days", len(records))
+	}
+	if !records[0].Current || records[1].Current {
+		t.Error("only the first period should be current")
+	}
+	want := []float64{0, 2.0, 0, 1.5}
+	for i, record := range records {
+		if record.Spent != want[i] {
+			t.Errorf("period %d (%s) spent $%.2f, want $%.2f", i, record.Start.Format(dateLayout), record.Spent, want[i])
+		}
+		if record.HardCap != 5 {
+			t.Errorf("period %d cap = %.2f, want 5", i, record.HardCap)
+		}
+	}
+
+	// Reading again must not close the same periods twice
+	if _, err := tracker.Budgets("global", ""); err != nil {
+		t.Fatalf("Budgets failed: %v", err)
+	}
+	if again, _ := tracker.History(budgets[0], 10); len(again) != 4 {
+		t.Errorf("got %d periods after a second read, want 4", len(again))
+	}
+}
+
+func TestBudgetsWithDifferentPeriodsOnOneScope(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	tracker := NewTracker(db)
+
+	if _, err := tracker.CreateBudgetWithPeriod("global", "", PeriodSpec{Period: PeriodMonthly}, 0, 200); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracker.CreateBudgetWithPeriod("global", "", PeriodSpec{Period: PeriodRolling, Days: 7}, 0, 50); err != nil {
+		t.Fatal(err)
+	}
+	stmt := fmt.Sprintf("INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost) VALUES ('u1', 's1', %d, 45.0, 0);", time.Now().Unix())
+	if err := db.Exec(stmt); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := tracker.Statuses("global", "")
+	if err != nil {
+		t.Fatalf("Statuses failed: %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("got %d statuses, want 2", len(statuses))
+	}
+
+	// The rolling cap is the tighter one
+	status, err := tracker.GetStatus("global", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Budget.Period != PeriodRolling {
+		t.Errorf("GetStatus returned the %s budget, want the rolling one", status.Budget.Spec().Label())
+	}
+
+	allowed, msg, err := tracker.CheckBudget("global", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed || !strings.Contains(msg, "rolling 7 days") {
+		t.Errorf("CheckBudget = %v, %q; want it blocked by the rolling 7 days cap", allowed, msg)
+	}
+
+	if _, err := tracker.FindBudget("global", "", PeriodSpec{Period: PeriodWeekly}); err == nil {
+		t.Error("FindBudget found a weekly budget that was never created")
+	}
+}
+
+func TestRollingPeriodKeyHoldsForTheWindow(t *testing.T) {
+	key := func(b *Budget, at time.Time) string {
+		b.PeriodStart = at.AddDate(0, 0, -b.RollingDays).Unix()
+		return b.periodKey()
+	}
+	week := &Budget{Period: PeriodRolling, RollingDays: 7}
+	start := time.Unix(20006*86400, 0).UTC() // a multiple of 7 epoch days
+	first := key(week, start.Add(time.Hour))
+	for day := 1; day < 7; day++ {
+		if got := key(week, start.AddDate(0, 0, day)); got != first {
+			t.Errorf("day %d key = %s, want %s for the whole window", day, got, first)
+		}
+	}
+	if got := key(week, start.AddDate(0, 0, 7)); got == first {
+		t.Errorf("key after a full window = %s, want a new one", got)
+	}
+
+	// Monthly budgets keep one key per calendar month
+	month := &Budget{Period: PeriodMonthly, PeriodStart: time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local).Unix()}
+	if got := month.periodKey(); got != "monthly:2026-10-01" {
+		t.Errorf("monthly key = %s, want monthly:2026-10-01", got)
+	}
+}
@@ -0,0 +1,219 @@
+package budget
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Budget scopes name whose spending a budget counts: everything (global), or
+// one project, profile, tool, provider, binding or model. Scopes combine with
+// "+" and their targets follow in the same order, so scope tool+model with
+// target claude-code+claude-opus-* caps Claude Code on Opus models.
+const (
+	scopeGlobal   = "global"
+	scopeProfile  = "profile"
+	scopeProject  = "project"
+	scopeTool     = "tool"     // Tool ID, e.g. claude-code
+	scopeProvider = "provider" // Provider ID, e.g. zai
+	scopeBinding  = "binding"  // Tool bound to a provider, e.g. claude-code/zai
+	scopeModel    = "model"    // Model name or glob, e.g. claude-opus-*
+)
+
+var scopeDimensions = []string{scopeProject, scopeProfile, scopeTool, scopeProvider, scopeBinding, scopeModel}
+
+// Request describes a call for matching it against budget scopes. Empty
+// fields are unknown and match no scope that needs them.
+type Request struct {
+	Project  string
+	Profile  string
+	Tool     string
+	Provider string
+	Binding  string // see BindingName
+	Model    string
+}
+
+// BindingName names a binding the way binding scopes target it
+func BindingName(tool, provider string) string {
+	return tool + "/" + provider
+}
+
+// scopePart is one dimension of a scope and the target it must match
+type scopePart struct {
+	dimension string
+	target    string
+}
+
+// ScopeProvider returns the provider a scope's requests all go to, from its
+// provider or binding target, or "" when the scope spans providers
+func ScopeProvider(scope, target string) string {
+	parts, err := parseScope(scope, target)
+	if err != nil {
+		return ""
+	}
+	for _, part := range parts {
+		switch part.dimension {
+		case scopeProvider:
+			return part.target
+		case scopeBinding:
+			if i := strings.Index(part.target, "/"); i >= 0 {
+				return part.target[i+1:]
+			}
+		}
+	}
+	return ""
+}
+
+// parseScope splits a scope and its target into dimensions. The global scope
+// has none.
+func parseScope(scope, target string) ([]scopePart, error) {
+	if scope == scopeGlobal {
+		return nil, nil
+	}
+	dimensions := strings.Split(scope, "+")
+	targets := strings.Split(target, "+")
+	if len(dimensions) > 1 && len(targets) != len(dimensions) {
+		return nil, fmt.Errorf("scope %s needs %d targets joined with +, got %q", scope, len(dimensions), target)
+	}
+	if len(dimensions) == 1 {
+		// A lone target may itself contain +
+		targets = []string{target}
+	}
+
+	parts := make([]scopePart, 0, len(dimensions))
+	seen := make(map[string]bool, len(dimensions))
+	for i, dimension := range dimensions {
+		known := false
+		for _, d := range scopeDimensions {
+			known = known || d == dimension
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown scope %q: want global or a combination of %s", dimension, strings.Join(scopeDimensions, ", "))
+		}
+		if seen[dimension] {
+			return nil, fmt.Errorf("scope %s repeats %s", scope, dimension)
+		}
+		seen[dimension] = true
+		parts = append(parts, scopePart{dimension: dimension, target: targets[i]})
+	}
+	return parts, nil
+}
+
+// ValidateScope checks that a scope is known and that every dimension of it
+// has a target
+func ValidateScope(scope, target string) error {
+	parts, err := parseScope(scope, target)
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		if part.target == "" {
+			return fmt.Errorf("%s scope needs a target", part.dimension)
+		}
+		if part.dimension == scopeBinding && !strings.Contains(part.target, "/") {
+			return fmt.Errorf("binding target %q: want <tool>/<provider>", part.target)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a request's spending counts toward the budget
+func (b *Budget) Matches(req Request) bool {
+	parts, err := parseScope(b.Scope, b.Target)
+	if err != nil {
+		return false
+	}
+	for _, part := range parts {
+		var value string
+		switch part.dimension {
+		case scopeProject:
+			value = req.Project
+		case scopeProfile:
+			value = req.Profile
+		case scopeTool:
+			value = req.Tool
+		case scopeProvider:
+			value = req.Provider
+		case scopeBinding:
+			value = req.Binding
+		case scopeModel:
+			if req.Model == "" || !globMatch(part.target, req.Model) {
+				return false
+			}
+			continue
+		}
+		if value == "" || value != part.target {
+			return false
+		}
+	}
+	return true
+}
+
+// spendingFilter narrows usage_records to a scope. Projects and profiles are
+// recorded on the session, everything else on the usage record; model targets
+// are globs.
+func spendingFilter(scope, target string) string {
+	parts, err := parseScope(scope, target)
+	if err != nil {
+		// Count nothing rather than everything for a scope that cannot match
+		return " AND 0"
+	}
+	var filter strings.Builder
+	for _, part := range parts {
+		switch part.dimension {
+		case scopeProject, scopeProfile:
+			fmt.Fprintf(&filter, " AND session_id IN (SELECT id FROM sessions WHERE %s='%s')", part.dimension, escape(part.target))
+		case scopeModel:
+			fmt.Fprintf(&filter, ` AND model LIKE '%s' ESCAPE '\'`, escape(likePattern(part.target)))
+		default:
+			fmt.Fprintf(&filter, " AND %s='%s'", part.dimension, escape(part.target))
+		}
+	}
+	return filter.String()
+}
+
+// globMatch matches s against a pattern where * is any run of characters and
+// ? any single one, ignoring case like SQLite's LIKE
+func globMatch(pattern, s string) bool {
+	p, str := []rune(strings.ToLower(pattern)), []rune(strings.ToLower(s))
+	star, mark := -1, 0
+	i, j := 0, 0
+	for j < len(str) {
+		switch {
+		case i < len(p) && (p[i] == '?' || p[i] == str[j]):
+			i++
+			j++
+		case i < len(p) && p[i] == '*':
+			star, mark = i, j
+			i++
+		case star >= 0:
+			i = star + 1
+			mark++
+			j = mark
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
+
+// likePattern turns a glob into a LIKE pattern escaped with backslash
+func likePattern(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteByte('%')
+		case '?':
+			b.WriteByte('_')
+		case '%', '_', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
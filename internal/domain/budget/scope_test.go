@@ -0,0 +1,158 @@
+package budget
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+func TestValidateScope(t *testing.T) {
+	valid := [][2]string{
+		{"global", ""},
+		{"tool", "claude-code"},
+		{"provider", "zai"},
+		{"model", "claude-opus-*"},
+		{"binding", "claude-code/zai"},
+		{"tool+model", "claude-code+claude-opus-*"},
+	}
+	for _, v := range valid {
+		if err := ValidateScope(v[0], v[1]); err != nil {
+			t.Errorf("ValidateScope(%q, %q): %v", v[0], v[1], err)
+		}
+	}
+
+	invalid := []struct{ scope, target, want string }{
+		{"team", "x", "unknown scope"},
+		{"tool", "", "needs a target"},
+		{"binding", "claude-code", "<tool>/<provider>"},
+		{"tool+model", "claude-code", "2 targets"},
+		{"tool+tool", "a+b", "repeats"},
+	}
+	for _, tt := range invalid {
+		if err := ValidateScope(tt.scope, tt.target); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ValidateScope(%q, %q) = %v, want it to mention %q", tt.scope, tt.target, err, tt.want)
+		}
+	}
+}
+
+func TestBudgetMatches(t *testing.T) {
+	req := Request{Tool: "claude-code", Provider: "zai", Binding: "claude-code/zai", Model: "claude-opus-4-1"}
+	tests := []struct {
+		scope, target string
+		want          bool
+	}{
+		{"global", "", true},
+		{"tool", "claude-code", true},
+		{"tool", "codex", false},
+		{"provider", "zai", true},
+		{"binding", "claude-code/zai", true},
+		{"model", "Claude-Opus-*", true},
+		{"model", "claude-?onnet-*", false},
+		{"tool+model", "claude-code+claude-opus-*", true},
+		{"tool+model", "claude-code+gpt-*", false},
+		{"project", "app", false},
+	}
+	for _, tt := range tests {
+		b := &Budget{Scope: tt.scope, Target: tt.target}
+		if got := b.Matches(req); got != tt.want {
+			t.Errorf("%s %s matches = %v, want %v", tt.scope, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestScopeProvider(t *testing.T) {
+	tests := []struct {
+		scope, target, want string
+	}{
+		{"global", "", ""},
+		{"tool", "claude-code", ""},
+		{"provider", "zai", "zai"},
+		{"binding", "claude-code/zai", "zai"},
+		{"tool+provider", "codex+oai", "oai"},
+		{"binding+model", "claude-code/zai+glm-*", "zai"},
+	}
+	for _, tt := range tests {
+		if got := ScopeProvider(tt.scope, tt.target); got != tt.want {
+			t.Errorf("ScopeProvider(%s, %s) = %q, want %q", tt.scope, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestScopedSpending(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	tracker := NewTracker(db)
+
+	now := time.Now().Unix()
+	seed := []struct {
+		id, project, tool, model, provider string
+		cost                               float64
+	}{
+		{"u1", "app", "claude-code", "claude-opus-4-1", "zai", 4},
+		{"u2", "app", "claude-code", "claude-sonnet-4-5", "zai", 2},
+		{"u3", "web", "codex", "gpt_5", "openai", 1},
+	}
+	for _, s := range seed {
+		stmt := fmt.Sprintf(`INSERT INTO sessions (id, started_at, project) VALUES ('%s', %d, '%s');
+			INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost, tool, model, provider)
+			VALUES ('%s', '%s', %d, %f, 0, '%s', '%s', '%s');`,
+			s.id, now, s.project, s.id, s.id, now, s.cost, s.tool, s.model, s.provider)
+		if err := db.Exec(stmt); err != nil {
+			t.Fatalf("seed %s: %v", s.id, err)
+		}
+	}
+
+	tests := []struct {
+		scope, target string
+		want          float64
+	}{
+		{"global", "", 7},
+		{"project", "app", 6},
+		{"tool", "claude-code", 6},
+		{"provider", "openai", 1},
+		{"model", "claude-*", 6},
+		{"model", "gpt_5", 1},
+		{"model", "gpt?5", 1},
+		{"tool+model", "claude-code+claude-opus-*", 4},
+	}
+	for _, tt := range tests {
+		spent, err := tracker.getPeriodSpending(tt.scope, tt.target, now-60, now+60, "USD")
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.scope, tt.target, err)
+		}
+		if spent != tt.want {
+			t.Errorf("%s %s spent $%.2f, want $%.2f", tt.scope, tt.target, spent, tt.want)
+		}
+	}
+
+	// Every matching budget is enforced; the denial names the one that blocks
+	if _, err := tracker.CreateBudget("provider", "zai", 0, 100); err != nil {
+		t.Fatal(err)
+	}
+	opus, err := tracker.CreateBudgetWithPeriod("tool+model", "claude-code+claude-opus-*", PeriodSpec{Period: PeriodDaily}, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := Request{Tool: "claude-code", Provider: "zai", Model: "claude-opus-4-1"}
+	denial, err := tracker.CheckRequest(req, 2)
+	if err != nil {
+		t.Fatalf("CheckRequest failed: %v", err)
+	}
+	if denial == nil || denial.Status.Budget.ID != opus.ID {
+		t.Fatalf("denial = %v, want the tool+model budget", denial)
+	}
+	if !strings.HasPrefix(denial.Error(), "tool+model claude-code+claude-opus-* (daily) budget: Would exceed daily hard cap") {
+		t.Errorf("denial = %q", denial.Error())
+	}
+
+	req.Model = "claude-sonnet-4-5"
+	if denial, err := tracker.CheckRequest(req, 2); err != nil || denial != nil {
+		t.Errorf("CheckRequest for sonnet = %v, %v; want it allowed", denial, err)
+	}
+}
@@ -1,25 +1,69 @@
 package budget
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
 
+	"github.com/royisme/bobamixer/internal/domain/currency"
 	"github.com/royisme/bobamixer/internal/store/sqlite"
 )
 
-// Budget represents a budget configuration
+// Budget represents a budget configuration. A scope may hold one budget per
+// period, e.g. a monthly cap and a rolling 7-day cap.
 type Budget struct {
-	ID          string
-	Scope       string  // "global", "project", "profile"
-	Target      string  // project name or profile name
-	DailyUSD    float64 // daily spending limit
-	HardCapUSD  float64 // absolute maximum
-	PeriodStart int64   // unix timestamp
-	PeriodEnd   int64   // unix timestamp
-	SpentUSD    float64 // current spending
+	ID             string
+	Scope          string  // global, project, profile, tool, provider, binding or model; combined with "+"
+	Target         string  // what the scope matches, one target per scope joined with "+"
+	Daily          float64 // daily spending limit, in Currency (US dollars unless set)
+	HardCap        float64 // maximum per period, in Currency
+	PeriodStart    int64   // unix timestamp
+	PeriodEnd      int64   // unix timestamp, inclusive
+	Spent          float64 // spending in the current period, in Currency
+	Period         Period  // daily, weekly, monthly, custom or rolling
+	RollingDays    int     // window length of rolling budgets
+	OnCap          string  // over-cap action, see Action*
+	DowngradeModel string  // model the downgrade action switches to
+	Currency       string  // ISO 4217 code of the limits and spending
+}
+
+// Spec returns the budget's period specification
+func (b *Budget) Spec() PeriodSpec {
+	spec := PeriodSpec{Period: b.Period, Days: b.RollingDays}
+	if spec.Period == "" {
+		spec.Period = PeriodMonthly
+	}
+	if spec.Period == PeriodCustom {
+		spec.Start = time.Unix(b.PeriodStart, 0)
+		spec.End = time.Unix(b.PeriodEnd, 0)
+	}
+	return spec
+}
+
+// periodKey names the budget's current period. Rolling windows move every
+// day, so their start is rounded down to a fixed span of the window's length;
+// an alert that keeps holding then repeats once per window, not daily.
+func (b *Budget) periodKey() string {
+	start := time.Unix(b.PeriodStart, 0)
+	if spec := b.Spec(); spec.Period == PeriodRolling && spec.Days > 1 {
+		day := b.PeriodStart / 86400
+		start = time.Unix((day-day%int64(spec.Days))*86400, 0).UTC()
+	}
+	return fmt.Sprintf("%s:%s", b.Spec(), start.Format(dateLayout))
+}
+
+// PeriodRecord is one period of a budget and what was spent in it
+type PeriodRecord struct {
+	Start    time.Time
+	End      time.Time
+	Daily    float64 // limits in effect when the period closed, in Currency
+	HardCap  float64
+	Spent    float64
+	Currency string
+	Current  bool // the period still running
 }
 
 // Status represents budget status
@@ -32,43 +76,73 @@ type Status struct {
 	TotalProgress float64 // percentage of hard cap used
 	IsOverDaily   bool
 	IsOverCap     bool
-	DaysRemaining int
+	DaysRemaining int // whole days left in the period; 0 for rolling budgets
+
+	rate   float64 // units of the budget currency per US dollar today
+	noRate error   // set when the budget currency has no exchange rate at all
+}
+
+// inBudgetCurrency converts a US dollar amount, such as a request's estimated
+// cost, to the budget currency at today's rate
+func (s *Status) inBudgetCurrency(usd float64) float64 {
+	if s.rate == 0 {
+		return usd
+	}
+	return usd * s.rate
 }
 
 // Tracker manages budget tracking
 type Tracker struct {
-	db *sqlite.DB
+	db    *sqlite.DB
+	rates *currency.Rates
 }
 
 var idCounter uint64
 
 // NewTracker creates a new budget tracker
 func NewTracker(db *sqlite.DB) *Tracker {
-	return &Tracker{db: db}
+	return &Tracker{db: db, rates: currency.NewRates(db)}
 }
 
-// CreateBudget creates a new budget
+// budgetColumns are the budgets columns parseBudget reads, in order
+const budgetColumns = "id, scope, target, daily_usd, hard_cap, period_start, period_end, spent_usd, period, rolling_days, on_cap, downgrade_model, currency"
+
+// CreateBudget creates a new monthly budget in US dollars
 func (t *Tracker) CreateBudget(scope, target string, dailyUSD, hardCapUSD float64) (*Budget, error) {
-	now := time.Now()
-	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	endOfMonth := time.Date(now.Year(), now.Month()+1, 0, 23, 59, 59, 0, now.Location())
+	return t.CreateBudgetWithPeriod(scope, target, PeriodSpec{Period: PeriodMonthly}, dailyUSD, hardCapUSD)
+}
+
+// CreateBudgetWithPeriod creates a budget in US dollars whose hard cap applies
+// per period; SetCurrency moves it to another currency
+func (t *Tracker) CreateBudgetWithPeriod(scope, target string, spec PeriodSpec, dailyUSD, hardCapUSD float64) (*Budget, error) {
+	if err := ValidateScope(scope, target); err != nil {
+		return nil, err
+	}
+	if spec.Period == PeriodRolling && spec.Days < 1 {
+		return nil, fmt.Errorf("rolling budgets need a window of at least 1 day")
+	}
+	start, end := spec.Bounds(time.Now())
 
 	budget := &Budget{
 		ID:          generateID(),
 		Scope:       scope,
 		Target:      target,
-		DailyUSD:    dailyUSD,
-		HardCapUSD:  hardCapUSD,
-		PeriodStart: startOfDay.Unix(),
-		PeriodEnd:   endOfMonth.Unix(),
-		SpentUSD:    0,
+		Daily:       dailyUSD,
+		HardCap:     hardCapUSD,
+		PeriodStart: start.Unix(),
+		PeriodEnd:   end.Unix(),
+		Spent:       0,
+		Period:      spec.Period,
+		RollingDays: spec.Days,
+		OnCap:       ActionBlock,
+		Currency:    currency.USD,
 	}
 
 	query := fmt.Sprintf(`
-		INSERT INTO budgets (id, scope, target, daily_usd, hard_cap, period_start, period_end, spent_usd)
-		VALUES ('%s', '%s', '%s', %f, %f, %d, %d, %f);
-	`, budget.ID, budget.Scope, escape(budget.Target), budget.DailyUSD, budget.HardCapUSD,
-		budget.PeriodStart, budget.PeriodEnd, budget.SpentUSD)
+		INSERT INTO budgets (%s)
+		VALUES ('%s', '%s', '%s', %f, %f, %d, %d, %f, '%s', %d, '%s', '', '%s');
+	`, budgetColumns, budget.ID, budget.Scope, escape(budget.Target), budget.Daily, budget.HardCap,
+		budget.PeriodStart, budget.PeriodEnd, budget.Spent, budget.Period, budget.RollingDays, budget.OnCap, budget.Currency)
 
 	if err := t.db.Exec(query); err != nil {
 		return nil, err
@@ -77,56 +151,219 @@ func (t *Tracker) CreateBudget(scope, target string, dailyUSD, hardCapUSD float6
 	return budget, nil
 }
 
-// GetBudget retrieves a budget by scope and target
+// GetBudget retrieves the first budget created for a scope and target
 func (t *Tracker) GetBudget(scope, target string) (*Budget, error) {
-	query := fmt.Sprintf(`
-                SELECT id, scope, target, daily_usd, hard_cap, period_start, period_end, spent_usd
-                FROM budgets WHERE scope='%s' AND target='%s' LIMIT 1;
-        `, scope, escape(target))
-
-	row, err := t.db.QueryRow(query)
+	budgets, err := t.Budgets(scope, target)
 	if err != nil {
 		return nil, err
 	}
-	if row == "" {
+	if len(budgets) == 0 {
 		return nil, fmt.Errorf("budget not found")
 	}
+	return budgets[0], nil
+}
+
+// FindBudget retrieves the budget of a scope and target with the given period
+func (t *Tracker) FindBudget(scope, target string, spec PeriodSpec) (*Budget, error) {
+	budgets, err := t.Budgets(scope, target)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range budgets {
+		if b.Spec().String() == spec.String() {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("no %s budget for %s", spec.Label(), scopeName(scope, target))
+}
+
+// Budgets retrieves every budget of a scope and target in creation order,
+// rolling calendar periods over first
+func (t *Tracker) Budgets(scope, target string) ([]*Budget, error) {
+	rows, err := t.db.QueryRows(fmt.Sprintf(`
+		SELECT %s FROM budgets WHERE scope='%s' AND target='%s' ORDER BY rowid;
+	`, budgetColumns, escape(scope), escape(target)))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	budgets := make([]*Budget, 0, len(rows))
+	for _, row := range rows {
+		budget, err := parseBudget(row)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.rollover(budget, now); err != nil {
+			return nil, fmt.Errorf("roll over budget %s: %w", budget.ID, err)
+		}
+		budgets = append(budgets, budget)
+	}
+	return budgets, nil
+}
+
+// parseBudget reads a row of budgetColumns
+func parseBudget(row string) (*Budget, error) {
 	parts := strings.Split(row, "|")
-	if len(parts) < 8 {
+	if len(parts) < 13 {
 		return nil, fmt.Errorf("invalid budget row: %s", row)
 	}
 	daily, err := strconv.ParseFloat(parts[3], 64)
 	if err != nil {
-		return nil, fmt.Errorf("parse daily: %w", err)
+		return nil, fmt.Errorf("parse daily limit for budget %s: %w", parts[0], err)
 	}
 	hard, err := strconv.ParseFloat(parts[4], 64)
 	if err != nil {
-		return nil, fmt.Errorf("parse hard cap: %w", err)
+		return nil, fmt.Errorf("parse hard cap for budget %s: %w", parts[0], err)
 	}
 	periodStart, err := strconv.ParseInt(parts[5], 10, 64)
 	if err != nil {
-		return nil, fmt.Errorf("parse period start: %w", err)
+		return nil, fmt.Errorf("parse period start for budget %s: %w", parts[0], err)
 	}
 	periodEnd, err := strconv.ParseInt(parts[6], 10, 64)
 	if err != nil {
-		return nil, fmt.Errorf("parse period end: %w", err)
+		return nil, fmt.Errorf("parse period end for budget %s: %w", parts[0], err)
 	}
 	spent, err := strconv.ParseFloat(parts[7], 64)
 	if err != nil {
-		return nil, fmt.Errorf("parse spent: %w", err)
+		return nil, fmt.Errorf("parse spent for budget %s: %w", parts[0], err)
 	}
-	budget := &Budget{
-		ID:          parts[0],
-		Scope:       parts[1],
-		Target:      parts[2],
-		DailyUSD:    daily,
-		HardCapUSD:  hard,
-		PeriodStart: periodStart,
-		PeriodEnd:   periodEnd,
-		SpentUSD:    spent,
+	rollingDays, err := strconv.Atoi(parts[9])
+	if err != nil {
+		return nil, fmt.Errorf("parse rolling days for budget %s: %w", parts[0], err)
+	}
+	return &Budget{
+		ID:             parts[0],
+		Scope:          parts[1],
+		Target:         parts[2],
+		Daily:          daily,
+		HardCap:        hard,
+		PeriodStart:    periodStart,
+		PeriodEnd:      periodEnd,
+		Spent:          spent,
+		Period:         Period(parts[8]),
+		RollingDays:    rollingDays,
+		OnCap:          parts[10],
+		DowngradeModel: parts[11],
+		Currency:       parts[12],
+	}, nil
+}
+
+// rollover moves a budget to the period that applies now. Calendar periods
+// that ended are closed into budget_periods with what was spent in each, and a
+// custom period is closed once when it ends. Rolling windows just slide.
+func (t *Tracker) rollover(b *Budget, now time.Time) error {
+	spec := b.Spec()
+	switch {
+	case spec.Period == PeriodRolling:
+		start, end := spec.Bounds(now)
+		b.PeriodStart, b.PeriodEnd = start.Unix(), end.Unix()
+		return nil
+	case now.Unix() <= b.PeriodEnd:
+		return nil
+	case !spec.RollsOver():
+		closed, err := t.db.QueryInt(fmt.Sprintf(
+			"SELECT COUNT(*) FROM budget_periods WHERE budget_id='%s' AND period_start=%d;", escape(b.ID), b.PeriodStart))
+		if err != nil || closed > 0 {
+			return err
+		}
+		spent, err := t.getPeriodSpending(b.Scope, b.Target, b.PeriodStart, b.PeriodEnd, b.Currency)
+		if err != nil {
+			return err
+		}
+		b.Spent = spent
+		return t.db.Exec(closePeriodStatement(b, b.PeriodStart, b.PeriodEnd, b.Spent, now))
 	}
 
-	return budget, nil
+	// Spending per local day covers every elapsed calendar period at once
+	days, err := t.dailySpending(b.Scope, b.Target, b.PeriodStart, now.Unix(), b.Currency)
+	if err != nil {
+		return err
+	}
+	statements := []string{"BEGIN;"}
+	start, end := time.Unix(b.PeriodStart, 0), time.Unix(b.PeriodEnd, 0)
+	for end.Before(now) {
+		spent := 0.0
+		for day, amount := range days {
+			if day >= start.Format(dateLayout) && day <= end.Format(dateLayout) {
+				spent += amount
+			}
+		}
+		statements = append(statements, closePeriodStatement(b, start.Unix(), end.Unix(), spent, now))
+		start, end = spec.Bounds(end.Add(time.Second))
+	}
+	b.PeriodStart, b.PeriodEnd, b.Spent = start.Unix(), end.Unix(), 0
+	statements = append(statements,
+		fmt.Sprintf("UPDATE budgets SET period_start=%d, period_end=%d, spent_usd=0 WHERE id='%s';",
+			b.PeriodStart, b.PeriodEnd, escape(b.ID)),
+		"COMMIT;")
+	return t.db.Exec(strings.Join(statements, "\n"))
+}
+
+// closePeriodStatement records a finished period; repeating it is harmless
+func closePeriodStatement(b *Budget, start, end int64, spent float64, now time.Time) string {
+	return fmt.Sprintf(`INSERT OR IGNORE INTO budget_periods (budget_id, period_start, period_end, daily_usd, hard_cap, spent_usd, currency, closed_at)
+		VALUES ('%s', %d, %d, %f, %f, %f, '%s', %d);`,
+		escape(b.ID), start, end, b.Daily, b.HardCap, spent, escape(b.Currency), now.Unix())
+}
+
+// History returns up to limit periods of a budget, the current one first.
+// Rolling budgets have no closed periods; their history is the consecutive
+// windows before the current one, at today's limits.
+func (t *Tracker) History(b *Budget, limit int) ([]PeriodRecord, error) {
+	if limit < 1 {
+		return nil, nil
+	}
+	records := make([]PeriodRecord, 0, limit)
+	start, end := b.PeriodStart, b.PeriodEnd
+	if b.Spec().Period == PeriodRolling {
+		window := end - start
+		for i := 0; i < limit; i++ {
+			spent, err := t.getPeriodSpending(b.Scope, b.Target, start, end, b.Currency)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, PeriodRecord{
+				Start: time.Unix(start, 0), End: time.Unix(end, 0),
+				Daily: b.Daily, HardCap: b.HardCap, Spent: spent, Currency: b.Currency, Current: i == 0,
+			})
+			start, end = start-window, start-1
+		}
+		return records, nil
+	}
+
+	// A custom period that ended is already in the closed periods
+	if time.Now().Unix() <= b.PeriodEnd {
+		spent, err := t.getPeriodSpending(b.Scope, b.Target, start, end, b.Currency)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, PeriodRecord{
+			Start: time.Unix(start, 0), End: time.Unix(end, 0),
+			Daily: b.Daily, HardCap: b.HardCap, Spent: spent, Currency: b.Currency, Current: true,
+		})
+	}
+	rows, err := t.db.QueryRows(fmt.Sprintf(`SELECT period_start, period_end, daily_usd, hard_cap, spent_usd, currency
+		FROM budget_periods WHERE budget_id='%s' ORDER BY period_start DESC LIMIT %d;`, escape(b.ID), limit-len(records)))
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		parts := strings.Split(row, "|")
+		if len(parts) < 6 {
+			continue
+		}
+		periodStart, _ := strconv.ParseInt(parts[0], 10, 64) //nolint:errcheck // written by closePeriodStatement
+		periodEnd, _ := strconv.ParseInt(parts[1], 10, 64)   //nolint:errcheck // written by closePeriodStatement
+		daily, _ := strconv.ParseFloat(parts[2], 64)         //nolint:errcheck // written by closePeriodStatement
+		hard, _ := strconv.ParseFloat(parts[3], 64)          //nolint:errcheck // written by closePeriodStatement
+		spent, _ := strconv.ParseFloat(parts[4], 64)         //nolint:errcheck // written by closePeriodStatement
+		records = append(records, PeriodRecord{
+			Start: time.Unix(periodStart, 0), End: time.Unix(periodEnd, 0),
+			Daily: daily, HardCap: hard, Spent: spent, Currency: parts[5],
+		})
+	}
+	return records, nil
 }
 
 // GetGlobalBudget retrieves the global budget
@@ -143,7 +380,34 @@ func (t *Tracker) UpdateSpending(budgetID string, amount float64) error {
 	return t.db.Exec(query)
 }
 
-// UpdateLimits updates the daily and hard cap limits for a budget id.
+// SetCurrency moves a budget to another currency, converting its limits at
+// today's exchange rate. Spending, recorded in US dollars, is converted per
+// usage record when the budget's status is calculated.
+func (t *Tracker) SetCurrency(b *Budget, code string) error {
+	code, err := currency.Normalize(code)
+	if err != nil {
+		return err
+	}
+	if code == b.Currency {
+		return nil
+	}
+	now := time.Now()
+	from, err := t.rates.PerUSD(b.Currency, now)
+	if err != nil {
+		return err
+	}
+	to, err := t.rates.PerUSD(code, now)
+	if err != nil {
+		return err
+	}
+	b.Daily, b.HardCap = b.Daily/from*to, b.HardCap/from*to
+	b.Currency = code
+	return t.db.Exec(fmt.Sprintf("UPDATE budgets SET currency='%s', daily_usd=%f, hard_cap=%f WHERE id='%s';",
+		code, b.Daily, b.HardCap, escape(b.ID)))
+}
+
+// UpdateLimits updates the daily and hard cap limits, in the budget's
+// currency, for a budget id.
 func (t *Tracker) UpdateLimits(budgetID string, daily, hard float64) error {
 	query := fmt.Sprintf(`
             UPDATE budgets SET daily_usd = %f, hard_cap = %f WHERE id='%s';
@@ -151,69 +415,133 @@ func (t *Tracker) UpdateLimits(budgetID string, daily, hard float64) error {
 	return t.db.Exec(query)
 }
 
-// GetStatus calculates the current budget status
+// GetStatus calculates the status of the scope's tightest active budget, the
+// one closest to a limit
 func (t *Tracker) GetStatus(scope, target string) (*Status, error) {
-	budget, err := t.GetBudget(scope, target)
+	statuses, err := t.Statuses(scope, target)
+	if err != nil {
+		return nil, err
+	}
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("budget not found")
+	}
+	tightest := statuses[0]
+	for _, status := range statuses[1:] {
+		if status.UsedPercent(nil) > tightest.UsedPercent(nil) {
+			tightest = status
+		}
+	}
+	return tightest, nil
+}
+
+// Statuses calculates the status of every active budget of a scope in creation
+// order. Custom budgets are active only within their date range.
+func (t *Tracker) Statuses(scope, target string) ([]*Status, error) {
+	budgets, err := t.Budgets(scope, target)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	var statuses []*Status
+	for _, budget := range budgets {
+		if now < budget.PeriodStart || now > budget.PeriodEnd {
+			continue
+		}
+		status, err := t.budgetStatus(budget)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// budgetStatus calculates a budget's status in its current period
+func (t *Tracker) budgetStatus(budget *Budget) (*Status, error) {
+	scope, target := budget.Scope, budget.Target
+
+	// A currency without any exchange rate cannot be checked, so the budget
+	// fails closed rather than letting every request through
+	rate, err := t.rates.PerUSD(budget.Currency, time.Now())
+	if errors.Is(err, currency.ErrNoRate) {
+		return &Status{
+			Budget:     budget,
+			DailyLimit: budget.Daily,
+			HardCap:    budget.HardCap,
+			IsOverCap:  true,
+			noRate:     fmt.Errorf("budget %s: %w", budget.ID, err),
+		}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	// Calculate today's spending
-	todaySpent, err := t.getTodaySpending(scope, target)
+	todaySpent, err := t.getTodaySpending(scope, target, budget.Currency)
 	if err != nil {
 		return nil, err
 	}
 
 	// Calculate total period spending
-	totalSpent, err := t.getPeriodSpending(scope, target, budget.PeriodStart, budget.PeriodEnd)
+	totalSpent, err := t.getPeriodSpending(scope, target, budget.PeriodStart, budget.PeriodEnd, budget.Currency)
 	if err != nil {
 		return nil, err
 	}
-	budget.SpentUSD = totalSpent
+	budget.Spent = totalSpent
 
-	now := time.Now()
-	periodEnd := time.Unix(budget.PeriodEnd, 0)
-	daysRemaining := int(periodEnd.Sub(now).Hours() / 24)
+	daysRemaining := 0
+	if budget.Spec().Period != PeriodRolling {
+		daysRemaining = int(time.Until(time.Unix(budget.PeriodEnd, 0)).Hours() / 24)
+	}
 
 	status := &Status{
 		Budget:        budget,
 		CurrentSpent:  todaySpent,
-		DailyLimit:    budget.DailyUSD,
-		HardCap:       budget.HardCapUSD,
+		DailyLimit:    budget.Daily,
+		HardCap:       budget.HardCap,
 		DaysRemaining: daysRemaining,
+		rate:          rate,
 	}
 
 	// Calculate progress percentages
-	if budget.DailyUSD > 0 {
-		status.DailyProgress = (todaySpent / budget.DailyUSD) * 100
-		status.IsOverDaily = todaySpent > budget.DailyUSD
+	if budget.Daily > 0 {
+		status.DailyProgress = (todaySpent / budget.Daily) * 100
+		status.IsOverDaily = todaySpent > budget.Daily
 	}
 
-	if budget.HardCapUSD > 0 {
-		status.TotalProgress = (totalSpent / budget.HardCapUSD) * 100
-		status.IsOverCap = totalSpent > budget.HardCapUSD
+	if budget.HardCap > 0 {
+		status.TotalProgress = (totalSpent / budget.HardCap) * 100
+		status.IsOverCap = totalSpent > budget.HardCap
 	}
 
 	return status, nil
 }
 
-// getTodaySpending calculates spending for today
-func (t *Tracker) getTodaySpending(scope, target string) (float64, error) {
-	var whereClause string
-	switch scope {
-	case scopeGlobal:
-		whereClause = ""
-	case scopeProfile:
-		whereClause = fmt.Sprintf(" AND profile='%s'", escape(target))
-	case scopeProject:
-		whereClause = fmt.Sprintf(" AND project='%s'", escape(target))
+// spendingSum totals usage cost in a currency. Costs are recorded in US
+// dollars and each record converts at the rate in effect on its own day, so
+// past spending does not move when a new rate is set.
+func spendingSum(code string) (string, error) {
+	rate, err := currency.RateSQL(code, "ts")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("SUM((input_cost + output_cost) * %s)", rate), nil
+}
+
+// getTodaySpending calculates spending for today, in a currency
+func (t *Tracker) getTodaySpending(scope, target, code string) (float64, error) {
+	whereClause := spendingFilter(scope, target)
+	sum, err := spendingSum(code)
+	if err != nil {
+		return 0, err
 	}
 
 	query := fmt.Sprintf(`
-		SELECT COALESCE(SUM(input_cost + output_cost), 0)
+		SELECT COALESCE(%s, 0)
 		FROM usage_records
 		WHERE date(ts, 'unixepoch') = date('now')%s;
-	`, whereClause)
+	`, sum, whereClause)
 
 	row, err := t.db.QueryRow(query)
 	if err != nil {
@@ -227,23 +555,19 @@ func (t *Tracker) getTodaySpending(scope, target string) (float64, error) {
 	return spent, nil
 }
 
-// getPeriodSpending calculates spending for a time period
-func (t *Tracker) getPeriodSpending(scope, target string, start, end int64) (float64, error) {
-	var whereClause string
-	switch scope {
-	case scopeGlobal:
-		whereClause = ""
-	case scopeProfile:
-		whereClause = fmt.Sprintf(" AND profile='%s'", escape(target))
-	case scopeProject:
-		whereClause = fmt.Sprintf(" AND project='%s'", escape(target))
+// getPeriodSpending calculates spending for a time period, in a currency
+func (t *Tracker) getPeriodSpending(scope, target string, start, end int64, code string) (float64, error) {
+	whereClause := spendingFilter(scope, target)
+	sum, err := spendingSum(code)
+	if err != nil {
+		return 0, err
 	}
 
 	query := fmt.Sprintf(`
-		SELECT COALESCE(SUM(input_cost + output_cost), 0)
+		SELECT COALESCE(%s, 0)
 		FROM usage_records
 		WHERE ts >= %d AND ts <= %d%s;
-	`, start, end, whereClause)
+	`, sum, start, end, whereClause)
 
 	row, err := t.db.QueryRow(query)
 	if err != nil {
@@ -257,39 +581,168 @@ func (t *Tracker) getPeriodSpending(scope, target string, start, end int64) (flo
 	return spent, nil
 }
 
-// CheckBudget checks if a planned spending would exceed budget
+// dailySpending totals spending per local calendar day (YYYY-MM-DD) in a time
+// range, in a currency
+func (t *Tracker) dailySpending(scope, target string, start, end int64, code string) (map[string]float64, error) {
+	sum, err := spendingSum(code)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := t.db.QueryRows(fmt.Sprintf(`
+		SELECT date(ts, 'unixepoch', 'localtime'), %s
+		FROM usage_records
+		WHERE ts >= %d AND ts <= %d%s
+		GROUP BY 1;
+	`, sum, start, end, spendingFilter(scope, target)))
+	if err != nil {
+		return nil, err
+	}
+	days := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		day, amount, ok := strings.Cut(row, "|")
+		if !ok {
+			continue
+		}
+		spent, err := strconv.ParseFloat(amount, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse spending for %s: %w", day, err)
+		}
+		days[day] = spent
+	}
+	return days, nil
+}
+
+// CheckBudget checks if a planned spending in US dollars would exceed any
+// active budget of the scope; the message names the period whose limit would
+// be exceeded
 func (t *Tracker) CheckBudget(scope, target string, plannedAmount float64) (bool, string, error) {
-	status, err := t.GetStatus(scope, target)
+	statuses, err := t.Statuses(scope, target)
 	if err != nil {
 		// If budget not found, allow spending
 		return true, "", nil
 	}
 
-	// Check daily limit
-	if status.DailyLimit > 0 {
-		projectedDaily := status.CurrentSpent + plannedAmount
-		if projectedDaily > status.DailyLimit {
-			msg := fmt.Sprintf("Would exceed daily budget: $%.4f / $%.2f (%.1f%%)",
-				projectedDaily, status.DailyLimit, (projectedDaily/status.DailyLimit)*100)
+	for _, status := range statuses {
+		if msg := status.exceededBy(plannedAmount); msg != "" {
 			return false, msg, nil
 		}
 	}
 
-	// Check hard cap
-	if status.HardCap > 0 {
-		totalSpent, err := t.getPeriodSpending(scope, target, status.Budget.PeriodStart, status.Budget.PeriodEnd)
+	return true, "", nil
+}
+
+// Denial names the budget that keeps a request from going ahead
+type Denial struct {
+	Status  *Status
+	Message string // which limit would be exceeded and by how much
+}
+
+// Error describes the blocking budget, e.g. "tool claude-code (monthly)
+// budget: Would exceed monthly hard cap: ..."
+func (d *Denial) Error() string {
+	b := d.Status.Budget
+	return fmt.Sprintf("%s (%s) budget: %s", scopeName(b.Scope, b.Target), b.Spec().Label(), d.Message)
+}
+
+// CheckRequest checks a planned spending in US dollars against every active
+// budget the request matches, whatever its scope, and returns the denial with
+// the strictest over-cap action, or nil when the request may go ahead. Budgets
+// whose override action has an active override let the request through.
+func (t *Tracker) CheckRequest(req Request, plannedAmount float64) (*Denial, error) {
+	statuses, err := t.RequestStatuses(req)
+	if err != nil {
+		return nil, err
+	}
+	return t.CheckStatuses(statuses, plannedAmount)
+}
+
+// CheckStatuses is CheckRequest over statuses already calculated with
+// RequestStatuses, for callers that also grade the request's budget hint
+func (t *Tracker) CheckStatuses(statuses []*Status, plannedAmount float64) (*Denial, error) {
+	return t.check(statuses, func(s *Status) string { return s.exceededBy(plannedAmount) })
+}
+
+// CheckUnpriced is CheckStatuses for a request whose cost cannot be converted
+// to US dollars. Like a budget without an exchange rate it fails closed: every
+// budget the request matches treats it as over its limit.
+func (t *Tracker) CheckUnpriced(statuses []*Status, cause error) (*Denial, error) {
+	return t.check(statuses, func(*Status) string { return "Cannot price the request: " + cause.Error() })
+}
+
+// check returns the denial of the strictest budget exceeded reports a limit
+// for, skipping override budgets with an active override
+func (t *Tracker) check(statuses []*Status, exceeded func(*Status) string) (*Denial, error) {
+	var denial *Denial
+	for _, status := range statuses {
+		msg := exceeded(status)
+		if msg == "" {
+			continue
+		}
+		if status.Budget.Action() == ActionOverride {
+			until, err := t.OverrideUntil(status.Budget)
+			if err != nil {
+				return nil, err
+			}
+			if !until.IsZero() {
+				continue
+			}
+		}
+		if denial == nil || severity(status.Budget.Action()) > severity(denial.Action()) {
+			denial = &Denial{Status: status, Message: msg}
+		}
+	}
+	return denial, nil
+}
+
+// RequestStatuses calculates the status of every active budget a request
+// matches
+func (t *Tracker) RequestStatuses(req Request) ([]*Status, error) {
+	budgets, err := t.GetAllBudgets()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	var statuses []*Status
+	for _, budget := range budgets {
+		if now < budget.PeriodStart || now > budget.PeriodEnd || !budget.Matches(req) {
+			continue
+		}
+		status, err := t.budgetStatus(budget)
 		if err != nil {
-			return false, "", err
+			return nil, err
 		}
-		projectedTotal := totalSpent + plannedAmount
-		if projectedTotal > status.HardCap {
-			msg := fmt.Sprintf("Would exceed hard cap: $%.4f / $%.2f",
-				projectedTotal, status.HardCap)
-			return false, msg, nil
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// exceededBy describes the limit a planned spending in US dollars would
+// exceed, or returns "" when it fits
+func (s *Status) exceededBy(plannedAmount float64) string {
+	if s.noRate != nil {
+		return s.noRate.Error()
+	}
+	planned, code := s.inBudgetCurrency(plannedAmount), s.Budget.Currency
+
+	// Check daily limit
+	if s.DailyLimit > 0 {
+		projectedDaily := s.CurrentSpent + planned
+		if projectedDaily > s.DailyLimit {
+			return fmt.Sprintf("Would exceed daily budget: %s / %s (%.1f%%)",
+				currency.Format(projectedDaily, code, 4), currency.Format(s.DailyLimit, code, 2), (projectedDaily/s.DailyLimit)*100)
 		}
 	}
 
-	return true, "", nil
+	// Check hard cap; the status already holds the period's spending
+	if s.HardCap > 0 {
+		projectedTotal := s.Budget.Spent + planned
+		if projectedTotal > s.HardCap {
+			return fmt.Sprintf("Would exceed %s hard cap: %s / %s",
+				s.Budget.Spec().Label(), currency.Format(projectedTotal, code, 4), currency.Format(s.HardCap, code, 2))
+		}
+	}
+	return ""
 }
 
 // GetWarningLevel returns the warning level (none, warning, critical)
@@ -307,10 +760,11 @@ func (s *Status) GetWarningLevel() string {
 
 // FormatStatus returns a human-readable status string
 func (s *Status) FormatStatus() string {
+	code := s.Budget.Currency
 	return fmt.Sprintf(
-		"Daily: $%.4f / $%.2f (%.1f%%) | Total: $%.4f / $%.2f (%.1f%%) | %d days remaining",
-		s.CurrentSpent, s.DailyLimit, s.DailyProgress,
-		s.Budget.SpentUSD, s.HardCap, s.TotalProgress,
+		"Daily: %s / %s (%.1f%%) | Total: %s / %s (%.1f%%) | %d days remaining",
+		currency.Format(s.CurrentSpent, code, 4), currency.Format(s.DailyLimit, code, 2), s.DailyProgress,
+		currency.Format(s.Budget.Spent, code, 4), currency.Format(s.HardCap, code, 2), s.TotalProgress,
 		s.DaysRemaining,
 	)
 }
@@ -321,8 +775,15 @@ func generateID() string {
 }
 
 func escape(s string) string {
-	// Simple SQL escape - in production use parameterized queries
-	return s
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+// scopeName describes a scope and target, e.g. "project myapp" or "global"
+func scopeName(scope, target string) string {
+	if target == "" {
+		return scope
+	}
+	return scope + " " + target
 }
 
 // GetMergedStatus returns budget status with project overriding global settings
@@ -345,53 +806,23 @@ func (t *Tracker) GetMergedStatus(project string) (*Status, error) {
 	return nil, fmt.Errorf("no budget configured (project or global)")
 }
 
-// GetAllBudgets returns all configured budgets for display
+// GetAllBudgets returns all configured budgets for display, rolled over to
+// their current periods
 func (t *Tracker) GetAllBudgets() ([]*Budget, error) {
-	query := `SELECT id, scope, target, daily_usd, hard_cap, period_start, period_end, spent_usd
-		FROM budgets ORDER BY scope, target;`
-
-	rows, err := t.db.QueryRows(query)
+	rows, err := t.db.QueryRows(fmt.Sprintf("SELECT %s FROM budgets ORDER BY scope, target, rowid;", budgetColumns))
 	if err != nil {
 		return nil, err
 	}
 
-	var budgets []*Budget
+	now := time.Now()
+	budgets := make([]*Budget, 0, len(rows))
 	for _, row := range rows {
-		parts := strings.Split(row, "|")
-		if len(parts) < 8 {
-			continue
-		}
-
-		daily, err := strconv.ParseFloat(parts[3], 64)
-		if err != nil {
-			return nil, fmt.Errorf("parse daily limit for budget %s: %w", parts[0], err)
-		}
-		hard, err := strconv.ParseFloat(parts[4], 64)
-		if err != nil {
-			return nil, fmt.Errorf("parse hard cap for budget %s: %w", parts[0], err)
-		}
-		periodStart, err := strconv.ParseInt(parts[5], 10, 64)
+		budget, err := parseBudget(row)
 		if err != nil {
-			return nil, fmt.Errorf("parse period start for budget %s: %w", parts[0], err)
+			return nil, err
 		}
-		periodEnd, err := strconv.ParseInt(parts[6], 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("parse period end for budget %s: %w", parts[0], err)
-		}
-		spent, err := strconv.ParseFloat(parts[7], 64)
-		if err != nil {
-			return nil, fmt.Errorf("parse spent for budget %s: %w", parts[0], err)
-		}
-
-		budget := &Budget{
-			ID:          parts[0],
-			Scope:       parts[1],
-			Target:      parts[2],
-			DailyUSD:    daily,
-			HardCapUSD:  hard,
-			PeriodStart: periodStart,
-			PeriodEnd:   periodEnd,
-			SpentUSD:    spent,
+		if err := t.rollover(budget, now); err != nil {
+			return nil, fmt.Errorf("roll over budget %s: %w", budget.ID, err)
 		}
 		budgets = append(budgets, budget)
 	}
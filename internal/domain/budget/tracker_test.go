@@ -1,8 +1,12 @@
 package budget
 
 import (
+	"fmt"
+	"math"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/royisme/bobamixer/internal/store/sqlite"
 )
@@ -83,12 +87,12 @@ func TestCreateBudget(t *testing.T) {
 				t.Errorf("Target = %s, want %s", budget.Target, tt.target)
 			}
 
-			if budget.DailyUSD != tt.dailyUSD {
-				t.Errorf("DailyUSD = %f, want %f", budget.DailyUSD, tt.dailyUSD)
+			if budget.Daily != tt.dailyUSD {
+				t.Errorf("Daily = %f, want %f", budget.Daily, tt.dailyUSD)
 			}
 
-			if budget.HardCapUSD != tt.hardCapUSD {
-				t.Errorf("HardCapUSD = %f, want %f", budget.HardCapUSD, tt.hardCapUSD)
+			if budget.HardCap != tt.hardCapUSD {
+				t.Errorf("HardCap = %f, want %f", budget.HardCap, tt.hardCapUSD)
 			}
 
 			if budget.ID == "" {
@@ -181,7 +185,7 @@ func TestUpdateLimits(t *testing.T) {
 	if err != nil {
 		t.Fatalf("GetGlobalBudget: %v", err)
 	}
-	if updated.DailyUSD != 20 || updated.HardCapUSD != 200 {
+	if updated.Daily != 20 || updated.HardCap != 200 {
 		t.Fatalf("limits not updated: %+v", updated)
 	}
 }
@@ -291,9 +295,9 @@ func TestStatusGetWarningLevel(t *testing.T) {
 
 func TestStatusFormatStatus(t *testing.T) {
 	budget := &Budget{
-		DailyUSD:   10.00,
-		HardCapUSD: 100.00,
-		SpentUSD:   50.00,
+		Daily:   10.00,
+		HardCap: 100.00,
+		Spent:   50.00,
 	}
 
 	status := &Status{
@@ -366,3 +370,119 @@ func containsMiddle(s, substr string) bool {
 	}
 	return false
 }
+
+func TestBudgetInCNY(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	tracker := NewTracker(db)
+
+	b, err := tracker.CreateBudgetWithPeriod("global", "", PeriodSpec{Period: PeriodMonthly}, 10, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.SetCurrency(b, "CNY"); err == nil {
+		t.Fatal("SetCurrency without a CNY rate succeeded")
+	}
+	if err := tracker.rates.Set("CNY", time.Now(), 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.SetCurrency(b, "cny"); err != nil {
+		t.Fatalf("SetCurrency: %v", err)
+	}
+	if b.Currency != "CNY" || b.Daily != 70 || b.HardCap != 700 {
+		t.Errorf("converted budget = %s %v/%v, want CNY 70/700", b.Currency, b.Daily, b.HardCap)
+	}
+
+	// Spending is recorded in US dollars and shown in yuan
+	if err := db.Exec(fmt.Sprintf(`INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost)
+		VALUES ('u1', 's1', %d, 2, 1);`, time.Now().Unix())); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	status, err := tracker.GetStatus("global", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Budget.Currency != "CNY" || math.Abs(status.CurrentSpent-21) > 1e-6 || status.DailyLimit != 70 {
+		t.Errorf("status = %s %v of %v, want CNY 21 of 70", status.Budget.Currency, status.CurrentSpent, status.DailyLimit)
+	}
+
+	allowed, msg, err := tracker.CheckBudget("global", "", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed || !strings.Contains(msg, "¥77.0000 / ¥70.00") {
+		t.Errorf("CheckBudget(8 USD) = %v, %q; want denied at ¥77.0000 / ¥70.00", allowed, msg)
+	}
+}
+
+func TestSpendingConvertsPerRecord(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	tracker := NewTracker(db)
+
+	march := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.Local)
+	april := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.Local)
+	if err := tracker.rates.Set("CNY", march, 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.rates.Set("CNY", april, 8); err != nil {
+		t.Fatal(err)
+	}
+	// One dollar spent on each side of the April rate change
+	for i, ts := range []time.Time{march.Add(36 * time.Hour), april.Add(36 * time.Hour)} {
+		if err := db.Exec(fmt.Sprintf(`INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost)
+			VALUES ('u%d', 's1', %d, 0.5, 0.5);`, i, ts.Unix())); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	start, end := march.Unix(), april.AddDate(0, 1, 0).Unix()
+	spent, err := tracker.getPeriodSpending("global", "", start, end, "CNY")
+	if err != nil || math.Abs(spent-15) > 1e-9 {
+		t.Fatalf("spending = %v, %v; want ¥15 at each record's rate", spent, err)
+	}
+
+	// A later rate leaves past spending where it was
+	if err := tracker.rates.Set("CNY", april.AddDate(0, 1, 0), 9); err != nil {
+		t.Fatal(err)
+	}
+	if spent, err := tracker.getPeriodSpending("global", "", start, end, "CNY"); err != nil || math.Abs(spent-15) > 1e-9 {
+		t.Errorf("spending after a new rate = %v, %v; want ¥15", spent, err)
+	}
+
+	days, err := tracker.dailySpending("global", "", start, end, "CNY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if days["2026-03-02"] != 7 || days["2026-04-02"] != 8 {
+		t.Errorf("daily spending = %v, want ¥7 on March 2 and ¥8 on April 2", days)
+	}
+}
+
+func TestBudgetWithoutRateFailsClosed(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	tracker := NewTracker(db)
+
+	if _, err := tracker.CreateBudgetWithPeriod("global", "", PeriodSpec{Period: PeriodMonthly}, 70, 700); err != nil {
+		t.Fatal(err)
+	}
+	// A budget whose currency lost its rates, e.g. edited in the database
+	if err := db.Exec("UPDATE budgets SET currency = 'CNY';"); err != nil {
+		t.Fatal(err)
+	}
+
+	allowed, msg, err := tracker.CheckBudget("global", "", 0.01)
+	if err != nil {
+		t.Fatalf("CheckBudget: %v", err)
+	}
+	if allowed || !strings.Contains(msg, "no exchange rate") {
+		t.Errorf("CheckBudget = %v, %q; want denied for the missing rate", allowed, msg)
+	}
+}
@@ -0,0 +1,310 @@
+// Package catalog discovers the models each provider offers and caches the lists on disk.
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/core"
+	"github.com/royisme/bobamixer/internal/logging"
+)
+
+const (
+	// DefaultTTL is how long a provider's model list is served from cache
+	DefaultTTL = 24 * time.Hour
+
+	cacheFile        = "models.cache.json"
+	anthropicVersion = "2023-06-01"
+	maxPages         = 20
+)
+
+// Model is one model offered by a provider
+type Model struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name,omitempty"`
+	Created     int64  `json:"created,omitempty"` // Unix seconds, when the provider reports it
+}
+
+// Listing is the model list of one provider
+type Listing struct {
+	ProviderID string    `json:"provider_id"`
+	BaseURL    string    `json:"base_url"`
+	Models     []Model   `json:"models"`
+	FetchedAt  time.Time `json:"fetched_at"`
+	Stale      bool      `json:"-"` // Served from an expired cache because the refresh failed
+}
+
+// Has reports whether the listing contains the given model ID
+func (l *Listing) Has(modelID string) bool {
+	for _, m := range l.Models {
+		if m.ID == modelID {
+			return true
+		}
+	}
+	return false
+}
+
+// Catalog fetches model lists from providers and caches them in ~/.boba/models.cache.json
+type Catalog struct {
+	path     string
+	ttl      time.Duration
+	client   *http.Client
+	mu       sync.Mutex
+	listings map[string]*Listing // provider ID -> listing, loaded lazily
+}
+
+// New creates a catalog backed by the cache file in home (ttl <= 0 uses DefaultTTL)
+func New(home string, ttl time.Duration) *Catalog {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Catalog{
+		path:   filepath.Join(home, cacheFile),
+		ttl:    ttl,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// List returns the provider's models, from cache when fresh unless refresh is set.
+// When fetching fails and an older list is cached, the stale list is returned instead.
+func (c *Catalog) List(ctx context.Context, provider *core.Provider, secrets *core.SecretsConfig, refresh bool) (*Listing, error) {
+	c.mu.Lock()
+	c.load()
+	cached := c.listings[provider.ID]
+	c.mu.Unlock()
+
+	if cached != nil && cached.BaseURL != provider.BaseURL {
+		cached = nil
+	}
+	if cached != nil && !refresh && time.Since(cached.FetchedAt) < c.ttl {
+		return cached, nil
+	}
+
+	apiKey, err := core.ResolveAPIKey(provider, secrets)
+	if err == nil {
+		var models []Model
+		models, err = fetch(ctx, c.client, provider, apiKey)
+		if err == nil {
+			listing := &Listing{ProviderID: provider.ID, BaseURL: provider.BaseURL, Models: models, FetchedAt: time.Now()}
+			c.store(listing)
+			return listing, nil
+		}
+	}
+
+	if cached != nil {
+		logging.Warn("Model discovery failed, using cached list",
+			logging.String("provider", provider.ID), logging.Err(err))
+		stale := *cached
+		stale.Stale = true
+		return &stale, nil
+	}
+	return nil, err
+}
+
+// load reads the cache file once; a missing or corrupt file starts an empty cache
+func (c *Catalog) load() {
+	if c.listings != nil {
+		return
+	}
+	c.listings = make(map[string]*Listing)
+
+	// #nosec G304 -- path is from safe home directory structure
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return
+	}
+	var listings []*Listing
+	if err := json.Unmarshal(data, &listings); err != nil {
+		logging.Warn("Ignoring unreadable model cache", logging.String("path", c.path), logging.Err(err))
+		return
+	}
+	for _, l := range listings {
+		c.listings[l.ProviderID] = l
+	}
+}
+
+// store caches a fresh listing and rewrites the cache file
+func (c *Catalog) store(listing *Listing) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listings[listing.ProviderID] = listing
+
+	all := make([]*Listing, 0, len(c.listings))
+	for _, l := range c.listings {
+		all = append(all, l)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ProviderID < all[j].ProviderID })
+
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err == nil {
+		err = os.WriteFile(c.path, data, 0600)
+	}
+	if err != nil {
+		logging.Warn("Failed to write model cache", logging.String("path", c.path), logging.Err(err))
+	}
+}
+
+// fetch lists models using the provider's native API
+func fetch(ctx context.Context, client *http.Client, provider *core.Provider, apiKey string) ([]Model, error) {
+	base := strings.TrimSuffix(provider.BaseURL, "/")
+	if base == "" {
+		return nil, fmt.Errorf("provider %s has no base_url", provider.ID)
+	}
+
+	var models []Model
+	var err error
+	switch provider.Kind {
+	case core.ProviderKindOpenAI, core.ProviderKindOpenAICompatible:
+		models, err = fetchOpenAI(ctx, client, base, apiKey)
+	case core.ProviderKindAnthropic, core.ProviderKindAnthropicCompatible:
+		models, err = fetchAnthropic(ctx, client, base, apiKey)
+	case core.ProviderKindGemini:
+		models, err = fetchGemini(ctx, client, base, apiKey)
+	default:
+		return nil, fmt.Errorf("model discovery is not supported for provider kind %q", provider.Kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list models for %s: %w", provider.ID, err)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models, nil
+}
+
+// fetchOpenAI calls GET {base}/models, where base already carries the API version
+func fetchOpenAI(ctx context.Context, client *http.Client, base, apiKey string) ([]Model, error) {
+	var page struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Created int64  `json:"created"`
+		} `json:"data"`
+	}
+	header := http.Header{"Authorization": {"Bearer " + apiKey}}
+	if err := getJSON(ctx, client, base+"/models", header, &page); err != nil {
+		return nil, err
+	}
+
+	models := make([]Model, 0, len(page.Data))
+	for _, m := range page.Data {
+		models = append(models, Model{ID: m.ID, Created: m.Created})
+	}
+	return models, nil
+}
+
+// fetchAnthropic pages through GET /v1/models
+func fetchAnthropic(ctx context.Context, client *http.Client, base, apiKey string) ([]Model, error) {
+	base = strings.TrimSuffix(base, "/v1")
+	header := http.Header{"X-Api-Key": {apiKey}, "Anthropic-Version": {anthropicVersion}}
+
+	var models []Model
+	afterID := ""
+	for i := 0; i < maxPages; i++ {
+		query := url.Values{"limit": {"1000"}}
+		if afterID != "" {
+			query.Set("after_id", afterID)
+		}
+		var page struct {
+			Data []struct {
+				ID          string    `json:"id"`
+				DisplayName string    `json:"display_name"`
+				CreatedAt   time.Time `json:"created_at"`
+			} `json:"data"`
+			HasMore bool   `json:"has_more"`
+			LastID  string `json:"last_id"`
+		}
+		if err := getJSON(ctx, client, base+"/v1/models?"+query.Encode(), header, &page); err != nil {
+			return nil, err
+		}
+		for _, m := range page.Data {
+			model := Model{ID: m.ID, DisplayName: m.DisplayName}
+			if !m.CreatedAt.IsZero() {
+				model.Created = m.CreatedAt.Unix()
+			}
+			models = append(models, model)
+		}
+		if !page.HasMore || page.LastID == "" {
+			break
+		}
+		afterID = page.LastID
+	}
+	return models, nil
+}
+
+// fetchGemini pages through models.list, using v1beta when the base URL has no version
+func fetchGemini(ctx context.Context, client *http.Client, base, apiKey string) ([]Model, error) {
+	if !strings.HasSuffix(base, "/v1") && !strings.HasSuffix(base, "/v1beta") {
+		base += "/v1beta"
+	}
+	header := http.Header{"X-Goog-Api-Key": {apiKey}}
+
+	var models []Model
+	pageToken := ""
+	for i := 0; i < maxPages; i++ {
+		query := url.Values{"pageSize": {"1000"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		var page struct {
+			Models []struct {
+				Name        string `json:"name"`
+				DisplayName string `json:"displayName"`
+			} `json:"models"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := getJSON(ctx, client, base+"/models?"+query.Encode(), header, &page); err != nil {
+			return nil, err
+		}
+		for _, m := range page.Models {
+			models = append(models, Model{ID: strings.TrimPrefix(m.Name, "models/"), DisplayName: m.DisplayName})
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+	return models, nil
+}
+
+// getJSON performs an authenticated GET and decodes the JSON body
+func getJSON(ctx context.Context, client *http.Client, endpoint string, header http.Header, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close() //nolint:errcheck // best effort cleanup
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg := strings.TrimSpace(string(body))
+		if len(msg) > 200 {
+			msg = msg[:200] + "..."
+		}
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, msg)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("unexpected model list response: %w", err)
+	}
+	return nil
+}
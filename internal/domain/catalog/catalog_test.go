@@ -0,0 +1,127 @@
+package catalog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/core"
+)
+
+func secretsFor(ids ...string) *core.SecretsConfig {
+	secrets := &core.SecretsConfig{Secrets: map[string]core.Secret{}}
+	for _, id := range ids {
+		secrets.Secrets[id] = core.Secret{APIKey: "key-" + id}
+	}
+	return secrets
+}
+
+func provider(id string, kind core.ProviderKind, baseURL string) *core.Provider {
+	return &core.Provider{ID: id, Kind: kind, BaseURL: baseURL, APIKey: core.APIKeyConfig{Source: core.APIKeySourceSecrets}, Enabled: true}
+}
+
+func TestListFetchesEachProtocol(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/openai/v1/models":
+			if r.Header.Get("Authorization") != "Bearer key-oai" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"object":"list","data":[{"id":"gpt-4o","created":1700000000},{"id":"gpt-4o-mini"}]}`)) //nolint:errcheck // test server
+		case r.URL.Path == "/v1/models":
+			if r.Header.Get("X-Api-Key") != "key-ant" || r.Header.Get("Anthropic-Version") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("after_id") == "" {
+				w.Write([]byte(`{"data":[{"id":"claude-opus-4","display_name":"Claude Opus 4","created_at":"2025-05-22T00:00:00Z"}],"has_more":true,"last_id":"claude-opus-4"}`)) //nolint:errcheck // test server
+				return
+			}
+			w.Write([]byte(`{"data":[{"id":"claude-haiku-4","display_name":"Claude Haiku 4"}],"has_more":false}`)) //nolint:errcheck // test server
+		case r.URL.Path == "/v1beta/models":
+			if r.Header.Get("X-Goog-Api-Key") != "key-gem" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"models":[{"name":"models/gemini-2.5-pro","displayName":"Gemini 2.5 Pro"}]}`)) //nolint:errcheck // test server
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := New(t.TempDir(), time.Hour)
+	secrets := secretsFor("oai", "ant", "gem")
+	ctx := context.Background()
+
+	tests := []struct {
+		provider *core.Provider
+		want     []string
+	}{
+		{provider("oai", core.ProviderKindOpenAICompatible, server.URL+"/openai/v1"), []string{"gpt-4o", "gpt-4o-mini"}},
+		{provider("ant", core.ProviderKindAnthropic, server.URL), []string{"claude-haiku-4", "claude-opus-4"}},
+		{provider("gem", core.ProviderKindGemini, server.URL), []string{"gemini-2.5-pro"}},
+	}
+	for _, tt := range tests {
+		listing, err := c.List(ctx, tt.provider, secrets, false)
+		if err != nil {
+			t.Fatalf("%s: List: %v", tt.provider.ID, err)
+		}
+		if len(listing.Models) != len(tt.want) {
+			t.Fatalf("%s: got %d models, want %v", tt.provider.ID, len(listing.Models), tt.want)
+		}
+		for i, id := range tt.want {
+			if listing.Models[i].ID != id {
+				t.Errorf("%s: model[%d] = %q, want %q", tt.provider.ID, i, listing.Models[i].ID, id)
+			}
+		}
+	}
+}
+
+func TestListCachesAndFallsBackToStale(t *testing.T) {
+	requests := 0
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"data":[{"id":"glm-4.6"}]}`)) //nolint:errcheck // test server
+	}))
+	defer server.Close()
+
+	home := t.TempDir()
+	p := provider("zai", core.ProviderKindOpenAICompatible, server.URL)
+	secrets := secretsFor("zai")
+	ctx := context.Background()
+
+	if _, err := New(home, time.Hour).List(ctx, p, secrets, false); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	// A new catalog reads the cache file instead of calling the provider again
+	listing, err := New(home, time.Hour).List(ctx, p, secrets, false)
+	if err != nil || !listing.Has("glm-4.6") {
+		t.Fatalf("cached List = %+v, %v", listing, err)
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+
+	fail = true
+	listing, err = New(home, time.Hour).List(ctx, p, secrets, true)
+	if err != nil {
+		t.Fatalf("refresh with cached fallback: %v", err)
+	}
+	if !listing.Stale || !listing.Has("glm-4.6") {
+		t.Errorf("expected stale cached listing, got %+v", listing)
+	}
+
+	moved := provider("zai", core.ProviderKindOpenAICompatible, server.URL+"/other")
+	if _, err := New(home, time.Hour).List(ctx, moved, secrets, false); err == nil {
+		t.Error("expected error when the base URL changed and the provider is failing")
+	}
+}
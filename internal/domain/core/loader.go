@@ -195,9 +195,8 @@ func ResolveAPIKey(provider *Provider, secrets *SecretsConfig) (string, error) {
 
 		// Fall back to secrets.yaml if env var is not set
 		if secrets != nil && secrets.Secrets != nil {
-			secret, ok := secrets.Secrets[provider.ID]
-			if ok && secret.APIKey != "" {
-				return secret.APIKey, nil
+			if key := secrets.Secrets[provider.ID].PrimaryKey(); key != "" {
+				return key, nil
 			}
 		}
 
@@ -211,12 +210,12 @@ func ResolveAPIKey(provider *Provider, secrets *SecretsConfig) (string, error) {
 			return "", fmt.Errorf("%w: no secret found for provider %s",
 				ErrMissingAPIKey, provider.ID)
 		}
-		secret, ok := secrets.Secrets[provider.ID]
-		if !ok || secret.APIKey == "" {
+		key := secrets.Secrets[provider.ID].PrimaryKey()
+		if key == "" {
 			return "", fmt.Errorf("%w: no secret found for provider %s",
 				ErrMissingAPIKey, provider.ID)
 		}
-		return secret.APIKey, nil
+		return key, nil
 
 	case APIKeySourceBrowser:
 		// Browser login / Subscription means no API key is needed/managed by us
@@ -228,6 +227,38 @@ func ResolveAPIKey(provider *Provider, secrets *SecretsConfig) (string, error) {
 	}
 }
 
+// ResolveAPIKeys returns every key available to a provider, in configuration order.
+// The primary key (environment or secrets.yaml api_key) comes first, followed by
+// any pooled keys from secrets.yaml. Providers without a pool yield a single key.
+func ResolveAPIKeys(provider *Provider, secrets *SecretsConfig) ([]PoolKey, error) {
+	var keys []PoolKey
+	seen := make(map[string]bool)
+
+	primary, primaryErr := ResolveAPIKey(provider, secrets)
+	if primaryErr == nil && primary != "" {
+		keys = append(keys, PoolKey{APIKey: primary, Label: "primary", Weight: 1})
+		seen[primary] = true
+	}
+
+	if secrets != nil && secrets.Secrets != nil {
+		for _, key := range secrets.Secrets[provider.ID].Keys {
+			if key.APIKey == "" || seen[key.APIKey] {
+				continue
+			}
+			seen[key.APIKey] = true
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		if primaryErr != nil {
+			return nil, primaryErr
+		}
+		return nil, fmt.Errorf("%w: no keys found for provider %s", ErrMissingAPIKey, provider.ID)
+	}
+	return keys, nil
+}
+
 // LoadAll loads all configuration files and returns them
 func LoadAll(home string) (*ProvidersConfig, *ToolsConfig, *BindingsConfig, *SecretsConfig, error) {
 	providers, err := LoadProviders(home)
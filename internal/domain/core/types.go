@@ -3,6 +3,8 @@
 package core
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 )
@@ -35,16 +37,26 @@ type APIKeyConfig struct {
 	EnvVar string       `yaml:"env_var,omitempty"` // Environment variable name (if source=env)
 }
 
+// KeyStrategy selects how the proxy rotates through a provider's key pool
+type KeyStrategy string
+
+// Key pool rotation strategies
+const (
+	KeyStrategyRoundRobin           KeyStrategy = "round_robin"            // Weighted round-robin (default)
+	KeyStrategyLeastRecentlyLimited KeyStrategy = "least_recently_limited" // Prefer keys that were rate limited longest ago
+)
+
 // Provider represents an AI service provider (e.g., OpenAI, Anthropic, Z.AI)
 type Provider struct {
-	ID           string         `yaml:"id"`                 // Unique identifier (e.g., "claude-anthropic-official")
-	Kind         ProviderKind   `yaml:"kind"`               // Provider type
-	DisplayName  string         `yaml:"display_name"`       // Human-readable name
-	BaseURL      string         `yaml:"base_url"`           // API endpoint
-	APIKey       APIKeyConfig   `yaml:"api_key"`            // How to get the API key
-	DefaultModel string         `yaml:"default_model"`      // Default model to use
-	Enabled      bool           `yaml:"enabled"`            // Whether this provider is active
-	Metadata     map[string]any `yaml:"metadata,omitempty"` // Additional provider-specific metadata
+	ID           string         `yaml:"id"`                     // Unique identifier (e.g., "claude-anthropic-official")
+	Kind         ProviderKind   `yaml:"kind"`                   // Provider type
+	DisplayName  string         `yaml:"display_name"`           // Human-readable name
+	BaseURL      string         `yaml:"base_url"`               // API endpoint
+	APIKey       APIKeyConfig   `yaml:"api_key"`                // How to get the API key
+	KeyStrategy  KeyStrategy    `yaml:"key_strategy,omitempty"` // Rotation strategy when secrets.yaml holds a key pool
+	DefaultModel string         `yaml:"default_model"`          // Default model to use
+	Enabled      bool           `yaml:"enabled"`                // Whether this provider is active
+	Metadata     map[string]any `yaml:"metadata,omitempty"`     // Additional provider-specific metadata
 }
 
 // ToolKind represents the type of CLI tool
@@ -86,6 +98,10 @@ type BindingOptions struct {
 	// Explicit model override
 	Model string `yaml:"model,omitempty"`
 
+	// Downgrade ladder, most capable model first (e.g. opus -> sonnet -> haiku).
+	// The proxy steps one rung down near the budget cap and to the last rung over it.
+	Downgrade []string `yaml:"downgrade,omitempty"`
+
 	// Additional custom options
 	Custom map[string]any `yaml:"custom,omitempty"`
 }
@@ -102,9 +118,18 @@ type Binding struct {
 type Secret struct {
 	ProviderID string            `yaml:"-"`                  // Provider this secret belongs to (not in YAML)
 	APIKey     string            `yaml:"api_key"`            // The actual API key
+	Keys       []PoolKey         `yaml:"keys,omitempty"`     // Additional keys for load balancing in the proxy
 	Metadata   map[string]string `yaml:"metadata,omitempty"` // Additional metadata
 }
 
+// PoolKey is one API key in a provider's key pool
+type PoolKey struct {
+	APIKey       string `yaml:"api_key"`                  // The actual API key
+	Label        string `yaml:"label,omitempty"`          // Optional human-readable name (e.g., "org-research")
+	Weight       int    `yaml:"weight,omitempty"`         // Relative share of traffic (defaults to 1)
+	RateLimitRPM int    `yaml:"rate_limit_rpm,omitempty"` // Requests per minute allowed on this key (0 = unlimited)
+}
+
 // ProvidersConfig is the root structure for providers.yaml
 type ProvidersConfig struct {
 	Version   int        `yaml:"version"`
@@ -141,6 +166,37 @@ var (
 
 // Helper methods
 
+// KeyFingerprint returns a stable, non-reversible identifier for an API key.
+// It is safe to log and store; the key itself must never be persisted outside secrets.yaml.
+func KeyFingerprint(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return "fp_" + hex.EncodeToString(sum[:6])
+}
+
+// PrimaryKey returns the secret's api_key, or the first pooled key when only a pool is configured
+func (s Secret) PrimaryKey() string {
+	if s.APIKey != "" {
+		return s.APIKey
+	}
+	for _, key := range s.Keys {
+		if key.APIKey != "" {
+			return key.APIKey
+		}
+	}
+	return ""
+}
+
+// EffectiveWeight returns the key's weight, treating unset or negative weights as 1
+func (k PoolKey) EffectiveWeight() int {
+	if k.Weight <= 0 {
+		return 1
+	}
+	return k.Weight
+}
+
 // IsValid checks if a Provider has all required fields
 func (p *Provider) IsValid() error {
 	if p.ID == "" {
@@ -158,6 +214,11 @@ func (p *Provider) IsValid() error {
 	if p.APIKey.Source == APIKeySourceEnv && p.APIKey.EnvVar == "" {
 		return fmt.Errorf("api_key.env_var is required when source=env for provider %s", p.ID)
 	}
+	switch p.KeyStrategy {
+	case "", KeyStrategyRoundRobin, KeyStrategyLeastRecentlyLimited:
+	default:
+		return fmt.Errorf("unknown key_strategy %q for provider %s", p.KeyStrategy, p.ID)
+	}
 	return nil
 }
 
@@ -186,6 +247,18 @@ func (b *Binding) IsValid() error {
 	if b.ProviderID == "" {
 		return fmt.Errorf("provider_id is required")
 	}
+	if n := len(b.Options.Downgrade); n > 0 {
+		if n < 2 {
+			return fmt.Errorf("options.downgrade needs at least two models")
+		}
+		seen := make(map[string]bool, n)
+		for _, model := range b.Options.Downgrade {
+			if model == "" || seen[model] {
+				return fmt.Errorf("options.downgrade has an empty or repeated model %q", model)
+			}
+			seen[model] = true
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,87 @@
+// Package currency converts between US dollars, the currency usage costs are
+// recorded in, and the currencies prices, budgets and reports are kept in,
+// using a locally stored table of exchange rates.
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// USD is the currency costs are recorded in
+const USD = "USD"
+
+// symbols are the prefixes of currencies common among providers and users;
+// other currencies are prefixed with their code
+var symbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"CNY": "¥",
+	"JPY": "JP¥",
+}
+
+// ErrInvalidCode is returned for codes that are not three letters
+var ErrInvalidCode = errors.New("currency must be a three-letter ISO 4217 code such as USD or CNY")
+
+// Normalize returns a currency code in upper case. An empty code is USD.
+func Normalize(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return USD, nil
+	}
+	if len(code) != 3 {
+		return "", fmt.Errorf("%q: %w", code, ErrInvalidCode)
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", fmt.Errorf("%q: %w", code, ErrInvalidCode)
+		}
+	}
+	return code, nil
+}
+
+// IsUSD reports whether a code names US dollars; an empty code does too
+func IsUSD(code string) bool {
+	return code == "" || strings.EqualFold(code, USD)
+}
+
+// Format writes an amount with its currency symbol, e.g. "$1.2345" or
+// "¥8.50", and "CHF 3.00" for currencies without one
+func Format(amount float64, code string, decimals int) string {
+	if IsUSD(code) {
+		code = USD
+	}
+	code = strings.ToUpper(code)
+	symbol, ok := symbols[code]
+	if !ok {
+		symbol = code + " "
+	}
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%s%.*f", sign, symbol, decimals, amount)
+}
+
+// Display shows US dollar amounts in another currency at a fixed rate. The
+// zero Display shows them in US dollars.
+type Display struct {
+	Currency string
+	PerUSD   float64
+}
+
+// Convert returns a US dollar amount in the display currency
+func (d Display) Convert(usd float64) float64 {
+	if d.PerUSD == 0 {
+		return usd
+	}
+	return usd * d.PerUSD
+}
+
+// Format converts a US dollar amount and writes it with the display
+// currency's symbol
+func (d Display) Format(usd float64, decimals int) string {
+	return Format(d.Convert(usd), d.Currency, decimals)
+}
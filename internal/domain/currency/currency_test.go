@@ -0,0 +1,135 @@
+package currency
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		code    string
+		want    string
+		wantErr bool
+	}{
+		{code: "", want: "USD"},
+		{code: " cny ", want: "CNY"},
+		{code: "EUR", want: "EUR"},
+		{code: "RMB1", wantErr: true},
+		{code: "C$", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.code)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidCode) {
+				t.Errorf("Normalize(%q) error = %v, want ErrInvalidCode", tt.code, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", tt.code, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		code     string
+		decimals int
+		want     string
+	}{
+		{amount: 1.23456, code: "", decimals: 4, want: "$1.2346"},
+		{amount: 8.5, code: "cny", decimals: 2, want: "¥8.50"},
+		{amount: 3, code: "CHF", decimals: 2, want: "CHF 3.00"},
+		{amount: -2, code: "EUR", decimals: 2, want: "-€2.00"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.amount, tt.code, tt.decimals); got != tt.want {
+			t.Errorf("Format(%v, %q, %d) = %q, want %q", tt.amount, tt.code, tt.decimals, got, tt.want)
+		}
+	}
+
+	if got := (Display{}).Format(2, 2); got != "$2.00" {
+		t.Errorf("zero Display formats %q, want $2.00", got)
+	}
+	if got := (Display{Currency: "CNY", PerUSD: 7}).Format(2, 2); got != "¥14.00" {
+		t.Errorf("CNY Display formats %q, want ¥14.00", got)
+	}
+}
+
+func TestRatesEffectiveDates(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	rates := NewRates(db)
+
+	march := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.Local)
+	june := time.Date(2026, time.June, 1, 0, 0, 0, 0, time.Local)
+	if err := rates.Set("cny", march, 7.2); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := rates.Set("CNY", june, 7.0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if _, err := rates.PerUSD("EUR", march); !errors.Is(err, ErrNoRate) {
+		t.Errorf("rate of a currency without rates: err = %v, want ErrNoRate", err)
+	}
+	// Days before the first rate use the earliest one
+	if got, err := rates.PerUSD("CNY", march.AddDate(0, 0, -1)); err != nil || got != 7.2 {
+		t.Errorf("rate before the first effective date = %v, %v; want 7.2", got, err)
+	}
+	if got, err := rates.PerUSD("CNY", june.Add(-time.Hour)); err != nil || got != 7.2 {
+		t.Errorf("rate the day before June = %v, %v; want 7.2", got, err)
+	}
+	if got, err := rates.PerUSD("CNY", june.Add(13*time.Hour)); err != nil || got != 7.0 {
+		t.Errorf("rate on June 1 = %v, %v; want 7.0", got, err)
+	}
+	if got, err := rates.PerUSD("usd", june); err != nil || got != 1 {
+		t.Errorf("USD rate = %v, %v; want 1", got, err)
+	}
+
+	usd, err := rates.ToUSD(70, "CNY", june)
+	if err != nil || math.Abs(usd-10) > 1e-9 {
+		t.Errorf("ToUSD(70 CNY) = %v, %v; want 10", usd, err)
+	}
+
+	// Setting a rate for the same day replaces it, and the cache is dropped
+	if err := rates.Set("CNY", june, 6.9); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, err := rates.PerUSD("CNY", june); err != nil || got != 6.9 {
+		t.Errorf("rate after update = %v, %v; want 6.9", got, err)
+	}
+
+	list, err := rates.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 || !list[0].EffectiveFrom.Equal(june) || list[1].PerUSD != 7.2 {
+		t.Errorf("List = %+v, want June then March", list)
+	}
+
+	if err := rates.Remove("CNY", june); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if got, err := rates.PerUSD("CNY", june); err != nil || got != 7.2 {
+		t.Errorf("rate after removing June = %v, %v; want 7.2", got, err)
+	}
+	if err := rates.Remove("CNY", june); err == nil {
+		t.Error("removing a missing rate succeeded")
+	}
+
+	if err := rates.Set("USD", june, 1); err == nil {
+		t.Error("setting a USD rate succeeded")
+	}
+	if err := rates.Set("EUR", june, 0); err == nil {
+		t.Error("setting a zero rate succeeded")
+	}
+}
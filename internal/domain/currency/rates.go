@@ -0,0 +1,251 @@
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+// dateLayout is how effective dates are stored, in local time
+const dateLayout = "2006-01-02"
+
+// cacheTTL is how long a looked-up rate is reused; the proxy converts on
+// every request and rates are set from another process by `boba currency set`
+const cacheTTL = time.Minute
+
+// ErrNoRate is returned when no exchange rate is in effect for a currency
+var ErrNoRate = errors.New("no exchange rate")
+
+// Rate is how many units of a currency one US dollar buys from a day on
+type Rate struct {
+	Currency      string
+	EffectiveFrom time.Time // local midnight of the first day the rate applies
+	PerUSD        float64
+	UpdatedAt     time.Time
+}
+
+// Rates is the exchange-rate table kept in exchange_rates. A rate applies
+// from its effective date until the next rate of the same currency.
+type Rates struct {
+	db *sqlite.DB
+
+	mu    sync.Mutex
+	cache map[string]cachedRate
+}
+
+type cachedRate struct {
+	perUSD  float64
+	fetched time.Time
+}
+
+// NewRates creates a rate table backed by the database
+func NewRates(db *sqlite.DB) *Rates {
+	return &Rates{db: db, cache: make(map[string]cachedRate)}
+}
+
+// Set stores the rate of a currency from a day on, replacing one set for the
+// same day
+func (r *Rates) Set(code string, from time.Time, perUSD float64) error {
+	code, err := Normalize(code)
+	if err != nil {
+		return err
+	}
+	if code == USD {
+		return errors.New("USD is the base currency; its rate is always 1")
+	}
+	if perUSD <= 0 {
+		return fmt.Errorf("rate must be positive, got %g", perUSD)
+	}
+	query := fmt.Sprintf(`INSERT INTO exchange_rates (currency, effective_from, per_usd, updated_at)
+		VALUES ('%s', '%s', %f, %d)
+		ON CONFLICT(currency, effective_from) DO UPDATE SET per_usd = excluded.per_usd, updated_at = excluded.updated_at;`,
+		code, from.Format(dateLayout), perUSD, time.Now().Unix())
+	if err := r.db.Exec(query); err != nil {
+		return fmt.Errorf("set %s rate: %w", code, err)
+	}
+	r.forget()
+	return nil
+}
+
+// Remove deletes the rate of a currency that took effect on a day
+func (r *Rates) Remove(code string, from time.Time) error {
+	code, err := Normalize(code)
+	if err != nil {
+		return err
+	}
+	where := fmt.Sprintf("currency = '%s' AND effective_from = '%s'", code, from.Format(dateLayout))
+	n, err := r.db.QueryInt(fmt.Sprintf("SELECT COUNT(*) FROM exchange_rates WHERE %s;", where))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("no %s rate takes effect on %s", code, from.Format(dateLayout))
+	}
+	if err := r.db.Exec(fmt.Sprintf("DELETE FROM exchange_rates WHERE %s;", where)); err != nil {
+		return fmt.Errorf("remove %s rate: %w", code, err)
+	}
+	r.forget()
+	return nil
+}
+
+// List returns every stored rate by currency, the latest first
+func (r *Rates) List() ([]Rate, error) {
+	rows, err := r.db.QueryRows(`SELECT currency, effective_from, per_usd, updated_at
+		FROM exchange_rates ORDER BY currency, effective_from DESC;`)
+	if err != nil {
+		return nil, err
+	}
+	rates := make([]Rate, 0, len(rows))
+	for _, row := range rows {
+		parts := strings.Split(row, "|")
+		if len(parts) < 4 {
+			continue
+		}
+		from, err := time.ParseInLocation(dateLayout, parts[1], time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("parse effective date of %s rate: %w", parts[0], err)
+		}
+		perUSD, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s rate: %w", parts[0], err)
+		}
+		updated, _ := strconv.ParseInt(parts[3], 10, 64) //nolint:errcheck // written by Set
+		rates = append(rates, Rate{
+			Currency:      parts[0],
+			EffectiveFrom: from,
+			PerUSD:        perUSD,
+			UpdatedAt:     time.Unix(updated, 0),
+		})
+	}
+	return rates, nil
+}
+
+// PerUSD returns how many units of a currency one US dollar bought at a time:
+// the rate with the latest effective date on or before its local day, or the
+// earliest rate for days before the first one takes effect. ErrNoRate is
+// returned only when the currency has no rates at all.
+func (r *Rates) PerUSD(code string, at time.Time) (float64, error) {
+	code, err := Normalize(code)
+	if err != nil {
+		return 0, err
+	}
+	if code == USD {
+		return 1, nil
+	}
+	day := at.Local().Format(dateLayout)
+	key := code + "@" + day
+
+	r.mu.Lock()
+	cached, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && time.Since(cached.fetched) < cacheTTL {
+		return cached.perUSD, nil
+	}
+
+	row, err := r.db.QueryRow(fmt.Sprintf("SELECT %s;", rateExpr(code, "'"+day+"'")))
+	if err != nil {
+		return 0, fmt.Errorf("look up %s rate: %w", code, err)
+	}
+	if row == "" {
+		return 0, fmt.Errorf("%w for %s on %s; set one with 'boba currency set %s <per-usd>'", ErrNoRate, code, day, code)
+	}
+	perUSD, err := strconv.ParseFloat(row, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s rate: %w", code, err)
+	}
+
+	r.mu.Lock()
+	r.cache[key] = cachedRate{perUSD: perUSD, fetched: time.Now()}
+	r.mu.Unlock()
+	return perUSD, nil
+}
+
+// RateSQL returns an SQL expression for the rate of a currency on the local day
+// of the unix timestamp in column, chosen as PerUSD chooses it; it is NULL when
+// the currency has no rates. Usage costs multiplied by it are in that currency
+// at the rate of the day each was recorded.
+func RateSQL(code, column string) (string, error) {
+	code, err := Normalize(code)
+	if err != nil {
+		return "", err
+	}
+	if code == USD {
+		return "1", nil
+	}
+	return rateExpr(code, fmt.Sprintf("date(%s, 'unixepoch', 'localtime')", column)), nil
+}
+
+// rateExpr selects the rate of a normalized currency on an SQL day expression
+func rateExpr(code, day string) string {
+	return fmt.Sprintf(`COALESCE(
+		(SELECT per_usd FROM exchange_rates WHERE currency = '%[1]s' AND effective_from <= %[2]s ORDER BY effective_from DESC LIMIT 1),
+		(SELECT per_usd FROM exchange_rates WHERE currency = '%[1]s' ORDER BY effective_from LIMIT 1))`, code, day)
+}
+
+// UnpricedUsage counts, by currency, the usage records kept at no cost because
+// their price was in a currency without any exchange rate
+func (r *Rates) UnpricedUsage() (map[string]int, error) {
+	rows, err := r.db.QueryRows(`SELECT UPPER(unpriced_currency), COUNT(*) FROM usage_records
+		WHERE unpriced_currency != '' GROUP BY UPPER(unpriced_currency);`)
+	if err != nil {
+		return nil, fmt.Errorf("count unpriced usage: %w", err)
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		parts := strings.Split(row, "|")
+		if len(parts) < 2 {
+			continue
+		}
+		n, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("parse unpriced usage of %s: %w", parts[0], err)
+		}
+		counts[parts[0]] = n
+	}
+	return counts, nil
+}
+
+// ToUSD converts an amount in a currency to US dollars at the rate in effect
+// at a time
+func (r *Rates) ToUSD(amount float64, code string, at time.Time) (float64, error) {
+	perUSD, err := r.PerUSD(code, at)
+	if err != nil {
+		return 0, err
+	}
+	return amount / perUSD, nil
+}
+
+// FromUSD converts an amount in US dollars to a currency at the rate in
+// effect at a time
+func (r *Rates) FromUSD(amount float64, code string, at time.Time) (float64, error) {
+	perUSD, err := r.PerUSD(code, at)
+	if err != nil {
+		return 0, err
+	}
+	return amount * perUSD, nil
+}
+
+// forget drops cached rates after the table changed
+func (r *Rates) forget() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = make(map[string]cachedRate)
+}
+
+// Display returns a Display for a currency at the rate in effect at a time
+func (r *Rates) Display(code string, at time.Time) (Display, error) {
+	code, err := Normalize(code)
+	if err != nil {
+		return Display{}, err
+	}
+	perUSD, err := r.PerUSD(code, at)
+	if err != nil {
+		return Display{}, err
+	}
+	return Display{Currency: code, PerUSD: perUSD}, nil
+}
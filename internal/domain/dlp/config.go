@@ -0,0 +1,187 @@
+// Package dlp scans outbound prompts for secrets and personal data before the proxy forwards them.
+package dlp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Action is what happens when a rule matches
+type Action string
+
+// Rule actions
+const (
+	ActionMask  Action = "mask"  // Replace the match with [REDACTED:<rule>] and forward
+	ActionBlock Action = "block" // Reject the request with a 4xx error
+	ActionWarn  Action = "warn"  // Forward unchanged, only record the finding
+)
+
+// Kind groups rules for reporting
+type Kind string
+
+// Rule kinds
+const (
+	KindSecret Kind = "secret"
+	KindPII    Kind = "pii"
+)
+
+// Config represents dlp.yaml
+type Config struct {
+	Version int           `yaml:"version"`
+	Enabled *bool         `yaml:"enabled,omitempty"` // Defaults to true
+	Entropy EntropyConfig `yaml:"entropy,omitempty"`
+	Rules   []RuleConfig  `yaml:"rules,omitempty"` // Overrides for built-in rules, or custom rules
+}
+
+// EntropyConfig tunes detection of high-entropy strings (random tokens and keys)
+type EntropyConfig struct {
+	Enabled   *bool   `yaml:"enabled,omitempty"`    // Defaults to true
+	Action    Action  `yaml:"action,omitempty"`     // Defaults to warn
+	MinLength int     `yaml:"min_length,omitempty"` // Defaults to 32
+	Threshold float64 `yaml:"threshold,omitempty"`  // Shannon bits per char, defaults to 4.5
+}
+
+// RuleConfig overrides a built-in rule by ID or defines a custom one (Pattern set)
+type RuleConfig struct {
+	ID      string `yaml:"id"`
+	Kind    Kind   `yaml:"kind,omitempty"`
+	Pattern string `yaml:"pattern,omitempty"`
+	Action  Action `yaml:"action,omitempty"`
+	Enabled *bool  `yaml:"enabled,omitempty"`
+}
+
+// Rule is a compiled detection rule
+type Rule struct {
+	ID      string
+	Kind    Kind
+	Pattern *regexp.Regexp
+	Action  Action
+	Enabled bool
+}
+
+const (
+	defaultEntropyMinLength = 32
+	defaultEntropyThreshold = 4.5
+
+	// EntropyRuleID identifies findings from the high-entropy detector
+	EntropyRuleID = "high_entropy"
+)
+
+// builtinRules are the rules available without configuration.
+// Secret rules are on by default; PII rules must be enabled in dlp.yaml.
+var builtinRules = []Rule{
+	{ID: "aws_access_key", Kind: KindSecret, Action: ActionMask, Enabled: true,
+		Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{ID: "aws_secret_key", Kind: KindSecret, Action: ActionMask, Enabled: true,
+		Pattern: regexp.MustCompile(`(?i)aws_secret_access_key\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}`)},
+	{ID: "gcp_api_key", Kind: KindSecret, Action: ActionMask, Enabled: true,
+		Pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`)},
+	{ID: "gcp_service_account", Kind: KindSecret, Action: ActionBlock, Enabled: true,
+		Pattern: regexp.MustCompile(`"type"\s*:\s*"service_account"`)},
+	{ID: "github_token", Kind: KindSecret, Action: ActionMask, Enabled: true,
+		Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`)},
+	{ID: "ai_api_key", Kind: KindSecret, Action: ActionMask, Enabled: true,
+		Pattern: regexp.MustCompile(`\bsk-(?:ant-|proj-|or-)?[A-Za-z0-9_\-]{20,}`)},
+	{ID: "private_key", Kind: KindSecret, Action: ActionBlock, Enabled: true,
+		Pattern: regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY-----(?s:.*?)(?:-----END (?:[A-Z]+ )*PRIVATE KEY-----|$)`)},
+	{ID: "email", Kind: KindPII, Action: ActionMask,
+		Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}\b`)},
+	{ID: "us_ssn", Kind: KindPII, Action: ActionMask,
+		Pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	{ID: "credit_card", Kind: KindPII, Action: ActionMask,
+		Pattern: regexp.MustCompile(`\b(?:\d[ -]?){13,19}\b`)},
+	{ID: "phone", Kind: KindPII, Action: ActionMask,
+		Pattern: regexp.MustCompile(`\+?\b\d{1,3}[ .\-]?\(?\d{3}\)?[ .\-]\d{3}[ .\-]\d{4}\b`)},
+}
+
+// Load reads dlp.yaml from the BobaMixer home directory.
+// A missing file yields the default configuration (built-in secret rules enabled).
+func Load(home string) (*Config, error) {
+	path := filepath.Join(home, "dlp.yaml")
+
+	//nolint:gosec // Reading from trusted config directory
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{Version: 1}, nil
+		}
+		return nil, fmt.Errorf("failed to read dlp.yaml: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse dlp.yaml: %w", err)
+	}
+	return &config, nil
+}
+
+// IsEnabled reports whether scanning is on
+func (c *Config) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// Compile merges the configuration over the built-in rules
+func (c *Config) Compile() ([]Rule, error) {
+	rules := make([]Rule, len(builtinRules))
+	copy(rules, builtinRules)
+	index := make(map[string]int, len(rules))
+	for i, r := range rules {
+		index[r.ID] = i
+	}
+
+	for _, rc := range c.Rules {
+		if rc.ID == "" {
+			return nil, fmt.Errorf("dlp rule: id is required")
+		}
+		if rc.Action != "" && !validAction(rc.Action) {
+			return nil, fmt.Errorf("dlp rule %s: invalid action %q (use mask, block or warn)", rc.ID, rc.Action)
+		}
+
+		i, exists := index[rc.ID]
+		if !exists {
+			if rc.Pattern == "" {
+				return nil, fmt.Errorf("dlp rule %s: pattern is required for custom rules", rc.ID)
+			}
+			rules = append(rules, Rule{ID: rc.ID, Kind: KindPII, Action: ActionMask, Enabled: true})
+			i = len(rules) - 1
+			index[rc.ID] = i
+		}
+
+		rule := &rules[i]
+		if rc.Pattern != "" {
+			re, err := regexp.Compile(rc.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("dlp rule %s: invalid pattern: %w", rc.ID, err)
+			}
+			rule.Pattern = re
+		}
+		if rc.Kind != "" {
+			rule.Kind = rc.Kind
+		}
+		if rc.Action != "" {
+			rule.Action = rc.Action
+		}
+		if rc.Enabled != nil {
+			rule.Enabled = *rc.Enabled
+		} else if exists {
+			// Mentioning a built-in rule turns it on (e.g. opting into a PII rule)
+			rule.Enabled = true
+		}
+	}
+
+	enabled := rules[:0]
+	for _, r := range rules {
+		if r.Enabled {
+			enabled = append(enabled, r)
+		}
+	}
+	return enabled, nil
+}
+
+func validAction(a Action) bool {
+	return a == ActionMask || a == ActionBlock || a == ActionWarn
+}
@@ -0,0 +1,289 @@
+package dlp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Finding is one rule match in a request
+type Finding struct {
+	RuleID   string
+	Kind     Kind
+	Action   Action
+	Location string // JSON path of the matched field, e.g. messages[1].content
+	Excerpt  string // Redacted preview, never the full match
+}
+
+// Result is the outcome of scanning a request body
+type Result struct {
+	Findings []Finding
+	Blocked  *Finding // First finding whose rule blocks, if any
+	Body     []byte   // Body with masked matches applied
+	Masked   bool     // Whether Body differs from the input
+}
+
+// Scanner applies DLP rules to request bodies
+type Scanner struct {
+	rules   []Rule
+	entropy EntropyConfig
+}
+
+// contentKeys are the top-level request fields that carry prompt text across
+// OpenAI, Anthropic and Gemini request shapes
+var contentKeys = []string{"messages", "system", "prompt", "input", "instructions", "contents", "systemInstruction"}
+
+// skipKeys hold opaque data (images, signatures, IDs) that would trip the entropy detector
+var skipKeys = map[string]bool{
+	"data": true, "source": true, "image_url": true, "inline_data": true, "inlineData": true,
+	"signature": true, "id": true, "tool_use_id": true, "tool_call_id": true, "role": true, "type": true,
+}
+
+var entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/=_\-]+`)
+
+// NewScanner compiles the configuration into a scanner
+func NewScanner(config *Config) (*Scanner, error) {
+	if config == nil {
+		config = &Config{}
+	}
+	rules, err := config.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	entropy := config.Entropy
+	if entropy.Enabled == nil {
+		on := true
+		entropy.Enabled = &on
+	}
+	if entropy.Action == "" {
+		entropy.Action = ActionWarn
+	}
+	if !validAction(entropy.Action) {
+		return nil, fmt.Errorf("dlp entropy: invalid action %q (use mask, block or warn)", entropy.Action)
+	}
+	if entropy.MinLength <= 0 {
+		entropy.MinLength = defaultEntropyMinLength
+	}
+	if entropy.Threshold <= 0 {
+		entropy.Threshold = defaultEntropyThreshold
+	}
+
+	return &Scanner{rules: rules, entropy: entropy}, nil
+}
+
+// Rules returns the enabled rules
+func (s *Scanner) Rules() []Rule {
+	return s.rules
+}
+
+// ScanBody scans the prompt-bearing fields of a JSON request body.
+// Non-JSON bodies are scanned as plain text.
+func (s *Scanner) ScanBody(body []byte) Result {
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		text, findings := s.ScanText(string(body), "body")
+		return s.result(findings, []byte(text), text != string(body))
+	}
+
+	var findings []Finding
+	masked := false
+	for _, key := range contentKeys {
+		value, ok := req[key]
+		if !ok {
+			continue
+		}
+		newValue, changed := s.walk(value, key, &findings)
+		if changed {
+			req[key] = newValue
+			masked = true
+		}
+	}
+
+	if !masked {
+		return s.result(findings, body, false)
+	}
+	out, err := json.Marshal(req)
+	if err != nil {
+		return s.result(findings, body, false)
+	}
+	return s.result(findings, out, true)
+}
+
+func (s *Scanner) result(findings []Finding, body []byte, masked bool) Result {
+	res := Result{Findings: findings, Body: body, Masked: masked}
+	for i := range findings {
+		if findings[i].Action == ActionBlock {
+			res.Blocked = &findings[i]
+			break
+		}
+	}
+	return res
+}
+
+// walk scans every string under value, returning the masked value and whether it changed
+func (s *Scanner) walk(value interface{}, location string, findings *[]Finding) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		text, found := s.ScanText(v, location)
+		*findings = append(*findings, found...)
+		return text, text != v
+	case []interface{}:
+		changed := false
+		for i, item := range v {
+			newItem, itemChanged := s.walk(item, fmt.Sprintf("%s[%d]", location, i), findings)
+			if itemChanged {
+				v[i] = newItem
+				changed = true
+			}
+		}
+		return v, changed
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		changed := false
+		for _, k := range keys {
+			if skipKeys[k] {
+				continue
+			}
+			newItem, itemChanged := s.walk(v[k], location+"."+k, findings)
+			if itemChanged {
+				v[k] = newItem
+				changed = true
+			}
+		}
+		return v, changed
+	default:
+		return value, false
+	}
+}
+
+// ScanText applies every rule to text, masking matches for rules whose action is mask
+func (s *Scanner) ScanText(text, location string) (string, []Finding) {
+	var findings []Finding
+	matched := make(map[string]bool)
+	for _, rule := range s.rules {
+		if rule.Pattern == nil {
+			continue
+		}
+		text = rule.Pattern.ReplaceAllStringFunc(text, func(m string) string {
+			// Card-number-shaped digits are only reported when the checksum holds
+			if rule.ID == "credit_card" && !luhnValid(m) {
+				return m
+			}
+			matched[m] = true
+			findings = append(findings, newFinding(rule.ID, rule.Kind, rule.Action, location, m))
+			return maskFor(rule.ID, rule.Action, m)
+		})
+	}
+
+	if s.entropy.Enabled != nil && *s.entropy.Enabled {
+		text = entropyCandidate.ReplaceAllStringFunc(text, func(m string) string {
+			if len(m) < s.entropy.MinLength || matched[m] || ShannonEntropy(m) < s.entropy.Threshold {
+				return m
+			}
+			findings = append(findings, newFinding(EntropyRuleID, KindSecret, s.entropy.Action, location, m))
+			return maskFor(EntropyRuleID, s.entropy.Action, m)
+		})
+	}
+
+	return text, findings
+}
+
+// atRest masks every built-in rule, PII included, and high-entropy strings
+var atRest = func() *Scanner {
+	rules := make([]Rule, len(builtinRules))
+	for i, rule := range builtinRules {
+		rule.Enabled, rule.Action = true, ActionMask
+		rules[i] = rule
+	}
+	on := true
+	return &Scanner{rules: rules, entropy: EntropyConfig{
+		Enabled: &on, Action: ActionMask, MinLength: defaultEntropyMinLength, Threshold: defaultEntropyThreshold,
+	}}
+}()
+
+// RedactText masks every secret and PII match in text that is kept at rest,
+// such as prompt samples stored for later analysis, whatever actions dlp.yaml
+// gives the rules for outbound requests
+func RedactText(text string) string {
+	masked, _ := atRest.ScanText(text, "")
+	return masked
+}
+
+func maskFor(ruleID string, action Action, match string) string {
+	if action != ActionMask {
+		return match
+	}
+	return "[REDACTED:" + ruleID + "]"
+}
+
+func newFinding(ruleID string, kind Kind, action Action, location, match string) Finding {
+	return Finding{RuleID: ruleID, Kind: kind, Action: action, Location: location, Excerpt: Redact(match)}
+}
+
+// Redact keeps just enough of a match to recognise it in an audit log. It
+// counts characters rather than bytes, so names and addresses in any script
+// are cut on character boundaries.
+func Redact(match string) string {
+	match = strings.TrimSpace(match)
+	if strings.Contains(match, "\n") {
+		match = strings.SplitN(match, "\n", 2)[0]
+	}
+	runes := []rune(match)
+	if len(runes) <= 8 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:4]) + "…" + string(runes[len(runes)-2:])
+}
+
+// ShannonEntropy returns the entropy of s in bits per character
+func ShannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	n := float64(len([]rune(s)))
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// luhnValid filters credit card candidates down to numbers with a valid checksum
+func luhnValid(candidate string) bool {
+	var digits []int
+	for _, r := range candidate {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,152 @@
+// Package forecast projects a daily series, such as spending per day, forward
+// with exponential smoothing.
+package forecast
+
+import (
+	"math"
+	"time"
+)
+
+// Forecasting methods, from least to most history needed
+const (
+	MethodNone        = "none"         // No history; everything forecasts to zero
+	MethodEWMA        = "ewma"         // Exponentially weighted moving average of the level
+	MethodHoltWinters = "holt-winters" // Level, damped trend and day-of-week seasonality
+)
+
+// season is the seasonal cycle in days; spending follows the working week
+const season = 7
+
+// damping flattens the trend over the horizon so a busy week is not
+// extrapolated linearly to the end of the month
+const damping = 0.9
+
+// Smoothing parameters tried when fitting, picked by one-step-ahead error
+var (
+	alphas = []float64{0.1, 0.2, 0.3, 0.5, 0.7}
+	betas  = []float64{0.01, 0.05, 0.1}
+	gammas = []float64{0.05, 0.1, 0.2, 0.3}
+)
+
+// Model is a forecast fitted to a daily series
+type Model struct {
+	Method string
+	Days   int // observations fitted
+
+	level    float64
+	trend    float64
+	seasonal [season]float64 // additive offset per time.Weekday
+	sigma    float64         // RMS one-step-ahead error
+	next     time.Weekday    // weekday of the first forecast day
+}
+
+// Fit fits a model to a daily series, oldest first, whose first value falls on
+// the given weekday. Two weeks of history enable Holt-Winters with weekly
+// seasonality; less falls back to an EWMA of the level.
+func Fit(series []float64, first time.Weekday) *Model {
+	m := &Model{
+		Method: MethodNone,
+		Days:   len(series),
+		next:   time.Weekday((int(first) + len(series)) % season),
+	}
+	switch {
+	case len(series) >= 2*season:
+		m.Method = MethodHoltWinters
+		var best *Model
+		for _, a := range alphas {
+			for _, b := range betas {
+				for _, g := range gammas {
+					if fit := fitHoltWinters(series, first, a, b, g); best == nil || fit.sigma < best.sigma {
+						best = fit
+					}
+				}
+			}
+		}
+		m.level, m.trend, m.seasonal, m.sigma = best.level, best.trend, best.seasonal, best.sigma
+	case len(series) > 0:
+		m.Method = MethodEWMA
+		best := math.Inf(1)
+		for _, a := range alphas {
+			level, sigma := fitEWMA(series, a)
+			if sigma < best {
+				best = sigma
+				m.level, m.sigma = level, sigma
+			}
+		}
+	}
+	return m
+}
+
+// fitEWMA smooths the level and returns it with the RMS one-step error. A
+// single observation has no error to measure, so its uncertainty is taken to
+// be as large as the value itself.
+func fitEWMA(series []float64, alpha float64) (level, sigma float64) {
+	level = series[0]
+	if len(series) == 1 {
+		return level, math.Abs(level)
+	}
+	sse := 0.0
+	for _, x := range series[1:] {
+		err := x - level
+		sse += err * err
+		level += alpha * err
+	}
+	return level, math.Sqrt(sse / float64(len(series)-1))
+}
+
+// fitHoltWinters runs additive Holt-Winters with a damped trend, initialized
+// from the first two weeks
+func fitHoltWinters(series []float64, first time.Weekday, alpha, beta, gamma float64) *Model {
+	weekday := func(i int) int { return (int(first) + i) % season }
+
+	week1, week2 := mean(series[:season]), mean(series[season:2*season])
+	m := &Model{level: week1, trend: (week2 - week1) / season}
+	for i := 0; i < season; i++ {
+		m.seasonal[weekday(i)] = series[i] - week1
+	}
+
+	sse := 0.0
+	for t := season; t < len(series); t++ {
+		x, s := series[t], m.seasonal[weekday(t)]
+		err := x - (m.level + damping*m.trend + s)
+		sse += err * err
+
+		level := alpha*(x-s) + (1-alpha)*(m.level+damping*m.trend)
+		m.trend = beta*(level-m.level) + (1-beta)*damping*m.trend
+		m.level = level
+		m.seasonal[weekday(t)] = gamma*(x-level) + (1-gamma)*s
+	}
+	m.sigma = math.Sqrt(sse / float64(len(series)-season))
+	return m
+}
+
+// Predict forecasts day h after the series, h = 1 being the next day.
+// Forecasts never go below zero.
+func (m *Model) Predict(h int) float64 {
+	if m.Method == MethodNone || h < 1 {
+		return 0
+	}
+	value := m.level
+	if m.Method == MethodHoltWinters {
+		phi, damped := damping, 0.0
+		for i := 0; i < h; i++ {
+			damped += phi
+			phi *= damping
+		}
+		value += damped*m.trend + m.seasonal[(int(m.next)+h-1)%season]
+	}
+	return math.Max(value, 0)
+}
+
+// Sigma is the standard deviation of a single day's forecast error
+func (m *Model) Sigma() float64 {
+	return m.sigma
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
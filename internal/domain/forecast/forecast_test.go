@@ -0,0 +1,73 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFitLearnsWeeklySeasonality(t *testing.T) {
+	// Four weeks starting on a Monday: $10 on weekdays, $2 at weekends
+	var series []float64
+	for i := 0; i < 28; i++ {
+		if i%7 < 5 {
+			series = append(series, 10)
+		} else {
+			series = append(series, 2)
+		}
+	}
+	m := Fit(series, time.Monday)
+	if m.Method != MethodHoltWinters || m.Days != 28 {
+		t.Fatalf("method = %s over %d days, want holt-winters over 28", m.Method, m.Days)
+	}
+
+	// The series ends on a Sunday, so day 1 is a Monday and day 6 a Saturday
+	if got := m.Predict(1); math.Abs(got-10) > 0.5 {
+		t.Errorf("Monday forecast = %.2f, want about 10", got)
+	}
+	if got := m.Predict(6); math.Abs(got-2) > 0.5 {
+		t.Errorf("Saturday forecast = %.2f, want about 2", got)
+	}
+	if got := m.Predict(8); math.Abs(got-10) > 0.5 {
+		t.Errorf("next Monday forecast = %.2f, want about 10", got)
+	}
+	if m.Sigma() > 0.5 {
+		t.Errorf("sigma = %.2f on a clean weekly pattern", m.Sigma())
+	}
+}
+
+func TestFitFallsBack(t *testing.T) {
+	if m := Fit(nil, time.Monday); m.Method != MethodNone || m.Predict(1) != 0 {
+		t.Errorf("empty series: method %s, forecast %.2f", m.Method, m.Predict(1))
+	}
+
+	m := Fit([]float64{4, 6, 5, 5}, time.Monday)
+	if m.Method != MethodEWMA {
+		t.Fatalf("method = %s for 4 days, want ewma", m.Method)
+	}
+	if got := m.Predict(1); got < 4 || got > 6 {
+		t.Errorf("forecast = %.2f, want within the observed range", got)
+	}
+	if m.Predict(1) != m.Predict(10) {
+		t.Error("ewma forecasts should be flat")
+	}
+
+	// A single day's uncertainty is as large as the day itself
+	if m := Fit([]float64{3}, time.Monday); m.Sigma() != 3 {
+		t.Errorf("single-day sigma = %.2f, want 3", m.Sigma())
+	}
+}
+
+func TestPredictNeverNegative(t *testing.T) {
+	// Spending collapsing to nothing must not forecast refunds
+	var series []float64
+	for i := 0; i < 21; i++ {
+		series = append(series, math.Max(0, 20-float64(i)*2))
+	}
+	m := Fit(series, time.Wednesday)
+	for h := 1; h <= 30; h++ {
+		if got := m.Predict(h); got < 0 {
+			t.Fatalf("Predict(%d) = %.2f", h, got)
+		}
+	}
+}
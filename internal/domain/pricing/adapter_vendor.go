@@ -85,7 +85,8 @@ func MergeSchemas(schema1, schema2 *PricingSchema) *PricingSchema {
 	merged.Currency = schema1.Currency
 	merged.FetchedAt = schema1.FetchedAt
 
-	// Build a map of models from schema1
+	// Build a map of models from schema1; models keep the currency of the
+	// schema they came from
 	modelMap := make(map[string]ModelPricing)
 	for _, model := range schema1.Models {
 		modelMap[model.ID] = model
@@ -94,6 +95,9 @@ func MergeSchemas(schema1, schema2 *PricingSchema) *PricingSchema {
 	// Add models from schema2 if not already present
 	for _, model := range schema2.Models {
 		if _, exists := modelMap[model.ID]; !exists {
+			if model.Currency == "" && schema2.Currency != merged.Currency {
+				model.Currency = schema2.Currency
+			}
 			modelMap[model.ID] = model
 		}
 	}
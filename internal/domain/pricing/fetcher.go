@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -20,11 +21,26 @@ import (
 type ModelPrice struct {
 	InputPer1K  float64 `json:"input_per_1k" yaml:"input_per_1k"`
 	OutputPer1K float64 `json:"output_per_1k" yaml:"output_per_1k"`
+	Currency    string  `json:"currency,omitempty" yaml:"currency,omitempty"` // ISO 4217 code; empty is USD
 }
 
 // Table contains pricing information for models
 type Table struct {
 	Models map[string]ModelPrice `json:"models" yaml:"models"`
+
+	converter Converter
+}
+
+// Converter turns an amount in another currency into US dollars at the rate
+// in effect at a time
+type Converter interface {
+	ToUSD(amount float64, currency string, at time.Time) (float64, error)
+}
+
+// SetConverter sets how costs of models priced in other currencies are
+// converted to US dollars. Without one those costs cannot be calculated.
+func (t *Table) SetConverter(c Converter) {
+	t.converter = c
 }
 
 // Load loads pricing table with fallback strategy:
@@ -117,6 +133,7 @@ func loadLegacy(home string) (*Table, error) {
 			table.Models[name] = ModelPrice{
 				InputPer1K:  price.InputPer1K,
 				OutputPer1K: price.OutputPer1K,
+				Currency:    price.Currency,
 			}
 		}
 		return table, nil
@@ -264,15 +281,43 @@ func (t *Table) GetPrice(modelName string, profileCost config.Cost) ModelPrice {
 	return ModelPrice{
 		InputPer1K:  profileCost.Input,
 		OutputPer1K: profileCost.Output,
+		Currency:    profileCost.Currency,
 	}
 }
 
-// CalculateCost calculates the cost for given token usage
-func (t *Table) CalculateCost(modelName string, profileCost config.Cost, inputTokens, outputTokens int) (inputCost, outputCost float64) {
+// ErrUnconverted is returned for a price in another currency that cannot be
+// converted to US dollars, because no converter is set or it has no rate
+var ErrUnconverted = errors.New("price cannot be converted to US dollars")
+
+// CalculateCost calculates the cost for given token usage in US dollars.
+// Prices in other currencies are converted at the rate in effect now, so
+// recorded costs keep the rate of the day they were recorded. A price that
+// cannot be converted returns ErrUnconverted rather than an amount in the
+// wrong currency.
+func (t *Table) CalculateCost(modelName string, profileCost config.Cost, inputTokens, outputTokens int) (inputCost, outputCost float64, err error) {
 	price := t.GetPrice(modelName, profileCost)
 
 	inputCost = float64(inputTokens) / 1000.0 * price.InputPer1K
 	outputCost = float64(outputTokens) / 1000.0 * price.OutputPer1K
 
-	return inputCost, outputCost
+	if isUSD(price.Currency) {
+		return inputCost, outputCost, nil
+	}
+	if t.converter == nil {
+		return 0, 0, fmt.Errorf("%w: %s is priced in %s", ErrUnconverted, modelName, price.Currency)
+	}
+	now := time.Now()
+	in, err := t.converter.ToUSD(inputCost, price.Currency, now)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %s is priced in %s: %w", ErrUnconverted, modelName, price.Currency, err)
+	}
+	out, err := t.converter.ToUSD(outputCost, price.Currency, now)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %s is priced in %s: %w", ErrUnconverted, modelName, price.Currency, err)
+	}
+	return in, out, nil
+}
+
+func isUSD(currency string) bool {
+	return currency == "" || strings.EqualFold(currency, "USD")
 }
@@ -1,12 +1,15 @@
 package pricing
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/royisme/bobamixer/internal/store/config"
 )
@@ -121,12 +124,15 @@ func TestCalculateCost(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			inputCost, outputCost := table.CalculateCost(
+			inputCost, outputCost, err := table.CalculateCost(
 				tt.modelName,
 				profileCost,
 				tt.inputTokens,
 				tt.outputTokens,
 			)
+			if err != nil {
+				t.Fatalf("CalculateCost: %v", err)
+			}
 
 			if inputCost != tt.expectedInputCost {
 				t.Errorf("inputCost: got %f, want %f", inputCost, tt.expectedInputCost)
@@ -240,3 +246,51 @@ refresh:
 		t.Fatalf("expected cache data to be used when remote fails")
 	}
 }
+
+type fixedRate float64
+
+func (r fixedRate) ToUSD(amount float64, _ string, _ time.Time) (float64, error) {
+	return amount / float64(r), nil
+}
+
+// noRates converts nothing, as a rate table without the currency
+type noRates struct{}
+
+func (noRates) ToUSD(float64, string, time.Time) (float64, error) {
+	return 0, errors.New("no exchange rate")
+}
+
+func TestCalculateCostConvertsCurrency(t *testing.T) {
+	table := &Table{
+		Models: map[string]ModelPrice{
+			"glm-4.6": {InputPer1K: 0.004, OutputPer1K: 0.016, Currency: "CNY"},
+		},
+	}
+
+	// Without a converter the price cannot be taken as dollars
+	if _, _, err := table.CalculateCost("glm-4.6", config.Cost{}, 1000, 1000); !errors.Is(err, ErrUnconverted) {
+		t.Errorf("unconverted cost error = %v, want ErrUnconverted", err)
+	}
+
+	table.SetConverter(fixedRate(8))
+	inputCost, outputCost, err := table.CalculateCost("glm-4.6", config.Cost{}, 1000, 1000)
+	if err != nil {
+		t.Fatalf("CalculateCost: %v", err)
+	}
+	if math.Abs(inputCost-0.0005) > 1e-12 || math.Abs(outputCost-0.002) > 1e-12 {
+		t.Errorf("converted cost = %f, %f; want 0.0005, 0.002", inputCost, outputCost)
+	}
+
+	// Profile prices carry their own currency
+	inputCost, _, err = table.CalculateCost("unknown", config.Cost{Input: 0.8, Currency: "CNY"}, 1000, 0)
+	if err != nil || math.Abs(inputCost-0.1) > 1e-12 {
+		t.Errorf("converted profile cost = %f, %v; want 0.1", inputCost, err)
+	}
+
+	// A currency without a rate is an error, never the unconverted amount
+	table.SetConverter(noRates{})
+	inputCost, outputCost, err = table.CalculateCost("glm-4.6", config.Cost{}, 1000, 1000)
+	if !errors.Is(err, ErrUnconverted) || inputCost != 0 || outputCost != 0 {
+		t.Errorf("cost without a rate = %f, %f, %v; want 0, 0 and ErrUnconverted", inputCost, outputCost, err)
+	}
+}
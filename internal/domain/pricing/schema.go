@@ -1,7 +1,10 @@
 // Package pricing provides model pricing information and cost calculation.
 package pricing
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // SchemaVersion represents the version of the pricing schema
 const SchemaVersion = 1
@@ -24,6 +27,7 @@ type ModelPricing struct {
 	ContextTokens int          `json:"context_tokens,omitempty"`
 	Pricing       PricingTiers `json:"pricing"`
 	Source        SourceMeta   `json:"source"`
+	Currency      string       `json:"currency,omitempty"` // overrides the schema currency
 }
 
 // PricingTiers contains all pricing dimensions for a model.
@@ -123,6 +127,7 @@ func (ps *PricingSchema) ToLegacyTable() *Table {
 				table.Models[model.ID] = ModelPrice{
 					InputPer1K:  model.Pricing.Token.Input / 1000.0,
 					OutputPer1K: model.Pricing.Token.Output / 1000.0,
+					Currency:    ps.modelCurrency(model),
 				}
 			}
 		}
@@ -131,6 +136,18 @@ func (ps *PricingSchema) ToLegacyTable() *Table {
 	return table
 }
 
+// modelCurrency returns the currency a model is priced in, or "" for USD
+func (ps *PricingSchema) modelCurrency(model ModelPricing) string {
+	currency := model.Currency
+	if currency == "" {
+		currency = ps.Currency
+	}
+	if isUSD(currency) {
+		return ""
+	}
+	return strings.ToUpper(currency)
+}
+
 // NewPricingSchema creates a new pricing schema with default values
 func NewPricingSchema() *PricingSchema {
 	return &PricingSchema{
@@ -0,0 +1,308 @@
+// Package reconcile compares tracked spending with the usage and cost exports
+// providers bill from, and keeps the billed figures as a separate layer.
+package reconcile
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export sources
+const (
+	SourceOpenAI     = "openai"
+	SourceAnthropic  = "anthropic"
+	SourceOpenRouter = "openrouter"
+)
+
+// Line is what a provider billed for one model on one day
+type Line struct {
+	Day          string // YYYY-MM-DD in UTC, the day providers bill by
+	Model        string // normalized, see NormalizeModel
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
+}
+
+// Tokens is the line's input and output tokens together
+func (l Line) Tokens() int {
+	return l.InputTokens + l.OutputTokens
+}
+
+// Export is a parsed billing export, one line per day and model
+type Export struct {
+	Source    string
+	Format    string // which of the source's exports it was, e.g. "cost" or "usage"
+	HasCost   bool   // usage exports report tokens only
+	HasTokens bool   // and cost exports cost only
+	Lines     []Line // sorted by day, then model
+}
+
+// From returns the first day the export covers
+func (e *Export) From() string {
+	if len(e.Lines) == 0 {
+		return ""
+	}
+	return e.Lines[0].Day
+}
+
+// To returns the last day the export covers
+func (e *Export) To() string {
+	if len(e.Lines) == 0 {
+		return ""
+	}
+	return e.Lines[len(e.Lines)-1].Day
+}
+
+// format describes the columns of one kind of export. Day, model and cost take
+// the first listed column present; input and output tokens add up every listed
+// column present, since some exports split them by cache use.
+type format struct {
+	source string
+	name   string
+	detect []string // columns only this export has
+	day    []string
+	model  []string
+	cost   []string
+	input  []string
+	output []string
+}
+
+var formats = []format{
+	{
+		source: SourceOpenRouter,
+		name:   "activity",
+		detect: []string{"generation_id"},
+		day:    []string{"created_at"},
+		model:  []string{"model_permaslug", "model"},
+		cost:   []string{"cost_total", "usage"},
+		input:  []string{"tokens_prompt"},
+		output: []string{"tokens_completion"},
+	},
+	{
+		// Costs export: one row per line item such as "gpt-4o-2024-08-06, input"
+		source: SourceOpenAI,
+		name:   "cost",
+		detect: []string{"line_item", "amount_value"},
+		day:    []string{"start_time_iso", "start_time"},
+		model:  []string{"line_item"},
+		cost:   []string{"amount_value"},
+	},
+	{
+		source: SourceOpenAI,
+		name:   "usage",
+		detect: []string{"num_model_requests"},
+		day:    []string{"start_time_iso", "start_time"},
+		model:  []string{"model"},
+		input:  []string{"input_tokens"},
+		output: []string{"output_tokens"},
+	},
+	{
+		source: SourceAnthropic,
+		name:   "cost",
+		detect: []string{"usage_date_utc", "cost_usd"},
+		day:    []string{"usage_date_utc"},
+		model:  []string{"model", "model_version"},
+		cost:   []string{"cost_usd"},
+	},
+	{
+		source: SourceAnthropic,
+		name:   "usage",
+		detect: []string{"usage_date_utc"},
+		day:    []string{"usage_date_utc"},
+		model:  []string{"model_version", "model"},
+		input: []string{
+			"usage_input_tokens_no_cache", "usage_input_tokens_cache_write_5m",
+			"usage_input_tokens_cache_write_1h", "usage_input_tokens_cache_read",
+		},
+		output: []string{"usage_output_tokens"},
+	},
+}
+
+// ErrUnknownFormat is returned for CSV files that are not a supported export
+var ErrUnknownFormat = errors.New("not an OpenAI, Anthropic or OpenRouter usage or cost export")
+
+// Parse reads a billing export, recognizing its kind by the header row, and
+// adds its rows up by day and model
+func Parse(r io.Reader) (*Export, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrUnknownFormat
+		}
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+
+	f, ok := detectFormat(columns)
+	if !ok {
+		return nil, ErrUnknownFormat
+	}
+	export := &Export{Source: f.source, Format: f.name, HasCost: len(f.cost) > 0, HasTokens: len(f.input) > 0}
+
+	lines := make(map[[2]string]*Line)
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		field := func(names []string) string {
+			for _, name := range names {
+				if i, ok := columns[name]; ok && i < len(record) {
+					return strings.TrimSpace(record[i])
+				}
+			}
+			return ""
+		}
+
+		day, err := parseDay(field(f.day))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		model := field(f.model)
+		if f.name == "cost" && f.source == SourceOpenAI {
+			model, _, _ = strings.Cut(model, ",")
+		}
+		model = NormalizeModel(model)
+		if model == "" {
+			continue
+		}
+
+		cost, err := parseNumber(field(f.cost))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: cost: %w", row, err)
+		}
+		input, err := sumColumns(columns, record, f.input)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: input tokens: %w", row, err)
+		}
+		output, err := sumColumns(columns, record, f.output)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: output tokens: %w", row, err)
+		}
+
+		key := [2]string{day, model}
+		line, ok := lines[key]
+		if !ok {
+			line = &Line{Day: day, Model: model}
+			lines[key] = line
+		}
+		line.Cost += cost
+		line.InputTokens += int(input)
+		line.OutputTokens += int(output)
+	}
+
+	for _, line := range lines {
+		export.Lines = append(export.Lines, *line)
+	}
+	sortLines(export.Lines)
+	return export, nil
+}
+
+func detectFormat(columns map[string]int) (format, bool) {
+	for _, f := range formats {
+		found := true
+		for _, name := range f.detect {
+			if _, ok := columns[name]; !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			return f, true
+		}
+	}
+	return format{}, false
+}
+
+func sumColumns(columns map[string]int, record []string, names []string) (float64, error) {
+	var total float64
+	for _, name := range names {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			continue
+		}
+		n, err := parseNumber(record[i])
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", name, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// parseNumber reads amounts like "1,234.5" or "$0.42"; empty cells are zero
+func parseNumber(s string) (float64, error) {
+	s = strings.NewReplacer("$", "", ",", "").Replace(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// dayLayouts are the timestamp formats exports use, after Unix seconds
+var dayLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02",
+}
+
+// parseDay returns the UTC day of an export timestamp
+func parseDay(s string) (string, error) {
+	if s == "" {
+		return "", errors.New("missing date")
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC().Format(time.DateOnly), nil
+	}
+	for _, layout := range dayLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC().Format(time.DateOnly), nil
+		}
+	}
+	return "", fmt.Errorf("unrecognized date %q", s)
+}
+
+var snapshotSuffix = regexp.MustCompile(`-(\d{8}|\d{4}-\d{2}-\d{2})$`)
+
+// NormalizeModel reduces the model names exports and requests use to one
+// form, so "anthropic/claude-opus-4.1", "Claude Opus 4.1",
+// "claude-opus-4-1-20250805" and "claude-opus-4-1" all match: no vendor
+// prefix or variant suffix, dots and spaces as dashes, no snapshot date,
+// lower case.
+func NormalizeModel(model string) string {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	model, _, _ = strings.Cut(model, ":")
+	model = strings.NewReplacer(".", "-", " ", "-").Replace(model)
+	return snapshotSuffix.ReplaceAllString(model, "")
+}
+
+func sortLines(lines []Line) {
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Day != lines[j].Day {
+			return lines[i].Day < lines[j].Day
+		}
+		return lines[i].Model < lines[j].Model
+	})
+}
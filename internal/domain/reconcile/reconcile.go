@@ -0,0 +1,130 @@
+package reconcile
+
+import "math"
+
+// Discrepancy statuses, from the tracked side's point of view
+const (
+	StatusMatch     = "match"     // within tolerance
+	StatusUnder     = "under"     // tracked less than was billed, e.g. estimates or missed streams
+	StatusOver      = "over"      // tracked more than was billed
+	StatusUntracked = "untracked" // billed but never tracked: usage outside the proxy
+	StatusUnbilled  = "unbilled"  // tracked but not in the export
+)
+
+// DefaultTolerance is the relative difference still counted as a match
+const DefaultTolerance = 0.05
+
+// Differences below these always match, so rounding in exports is not reported
+const (
+	minCostDiff  = 0.01
+	minTokenDiff = 100
+)
+
+// Item compares one model on one day
+type Item struct {
+	Day     string
+	Model   string
+	Tracked Line
+	Billed  Line
+	Status  string
+}
+
+// Diff is how much more was billed than tracked: dollars for cost exports,
+// tokens for usage exports
+func (i Item) Diff(byCost bool) float64 {
+	if byCost {
+		return i.Billed.Cost - i.Tracked.Cost
+	}
+	return float64(i.Billed.Tokens() - i.Tracked.Tokens())
+}
+
+// Report is the discrepancy report between tracked usage and an export
+type Report struct {
+	Source  string
+	From    string
+	To      string
+	ByCost  bool // compared on cost rather than tokens
+	Items   []Item
+	Tracked Line // totals, without Day and Model
+	Billed  Line
+}
+
+// Counts returns how many items have each status
+func (r *Report) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, item := range r.Items {
+		counts[item.Status]++
+	}
+	return counts
+}
+
+// Compare matches tracked usage to an export's lines by day and model.
+// Tracked lines outside the days the export covers are ignored; tolerance is
+// the relative difference still counted as a match.
+func Compare(export *Export, tracked []Line, tolerance float64) *Report {
+	report := &Report{
+		Source: export.Source,
+		From:   export.From(),
+		To:     export.To(),
+		ByCost: export.HasCost,
+	}
+
+	items := make(map[[2]string]*Item)
+	item := func(day, model string) *Item {
+		key := [2]string{day, model}
+		if items[key] == nil {
+			items[key] = &Item{Day: day, Model: model}
+		}
+		return items[key]
+	}
+	for _, line := range export.Lines {
+		item(line.Day, line.Model).Billed = line
+		addLine(&report.Billed, line)
+	}
+	for _, line := range tracked {
+		if line.Day < report.From || line.Day > report.To {
+			continue
+		}
+		it := item(line.Day, line.Model)
+		addLine(&it.Tracked, line)
+		addLine(&report.Tracked, line)
+	}
+
+	lines := make([]Line, 0, len(items))
+	for _, it := range items {
+		lines = append(lines, Line{Day: it.Day, Model: it.Model})
+	}
+	sortLines(lines)
+	for _, line := range lines {
+		it := items[[2]string{line.Day, line.Model}]
+		it.Status = status(*it, report.ByCost, tolerance)
+		report.Items = append(report.Items, *it)
+	}
+	return report
+}
+
+func addLine(total *Line, line Line) {
+	total.InputTokens += line.InputTokens
+	total.OutputTokens += line.OutputTokens
+	total.Cost += line.Cost
+}
+
+func status(item Item, byCost bool, tolerance float64) string {
+	tracked, billed, floor := float64(item.Tracked.Tokens()), float64(item.Billed.Tokens()), float64(minTokenDiff)
+	if byCost {
+		tracked, billed, floor = item.Tracked.Cost, item.Billed.Cost, minCostDiff
+	}
+	diff := billed - tracked
+	switch {
+	case math.Abs(diff) <= math.Max(tolerance*billed, floor):
+		return StatusMatch
+	case tracked == 0:
+		return StatusUntracked
+	case billed == 0:
+		return StatusUnbilled
+	case diff > 0:
+		return StatusUnder
+	default:
+		return StatusOver
+	}
+}
@@ -0,0 +1,196 @@
+package reconcile
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+func TestParseExports(t *testing.T) {
+	tests := []struct {
+		name, csv      string
+		source, format string
+		want           []Line
+	}{
+		{
+			name: "openai cost",
+			csv: `start_time,end_time,start_time_iso,end_time_iso,project_id,line_item,organization_id,amount_value,amount_currency
+1759276800,1759363200,2025-10-01T00:00:00+00:00,2025-10-02T00:00:00+00:00,proj,"gpt-4o-2024-08-06, input",org,1.25,usd
+1759276800,1759363200,2025-10-01T00:00:00+00:00,2025-10-02T00:00:00+00:00,proj,"gpt-4o-2024-08-06, output",org,2.50,usd
+1759363200,1759449600,2025-10-02T00:00:00+00:00,2025-10-03T00:00:00+00:00,proj,"gpt-5, input",org,0.40,usd`,
+			source: SourceOpenAI, format: "cost",
+			want: []Line{{Day: "2025-10-01", Model: "gpt-4o", Cost: 3.75}, {Day: "2025-10-02", Model: "gpt-5", Cost: 0.40}},
+		},
+		{
+			name: "openai usage",
+			csv: `start_time,end_time,project_id,num_model_requests,model,input_tokens,output_tokens,input_cached_tokens
+1759276800,1759363200,proj,4,gpt-5-2025-08-07,"1,200",300,100`,
+			source: SourceOpenAI, format: "usage",
+			want: []Line{{Day: "2025-10-01", Model: "gpt-5", InputTokens: 1200, OutputTokens: 300}},
+		},
+		{
+			name: "anthropic usage",
+			csv: "\ufeff" + `usage_date_utc,model_version,api_key,workspace,usage_type,usage_input_tokens_no_cache,usage_input_tokens_cache_write_5m,usage_input_tokens_cache_write_1h,usage_input_tokens_cache_read,usage_output_tokens
+2025-10-01,claude-sonnet-4-5-20250929,key,Default,message,100,20,0,80,50`,
+			source: SourceAnthropic, format: "usage",
+			want: []Line{{Day: "2025-10-01", Model: "claude-sonnet-4-5", InputTokens: 200, OutputTokens: 50}},
+		},
+		{
+			name: "anthropic cost",
+			csv: `usage_date_utc,model,workspace,api_key,usage_type,token_type,cost_usd
+2025-10-01,Claude Opus 4.1,Default,key,message,input,$1.50
+2025-10-01,Claude Opus 4.1,Default,key,message,output,3.00`,
+			source: SourceAnthropic, format: "cost",
+			want: []Line{{Day: "2025-10-01", Model: "claude-opus-4-1", Cost: 4.50}},
+		},
+		{
+			name: "openrouter activity",
+			csv: `generation_id,created_at,cost_total,tokens_prompt,tokens_completion,model_permaslug,provider_name
+gen-1,2025-10-01 23:59:59.5,0.02,1000,100,anthropic/claude-4.5-sonnet-20250929,Anthropic
+gen-2,2025-10-02 00:00:01,0.01,10,5,deepseek/deepseek-chat-v3.1:free,DeepSeek`,
+			source: SourceOpenRouter, format: "activity",
+			want: []Line{
+				{Day: "2025-10-01", Model: "claude-4-5-sonnet", InputTokens: 1000, OutputTokens: 100, Cost: 0.02},
+				{Day: "2025-10-02", Model: "deepseek-chat-v3-1", InputTokens: 10, OutputTokens: 5, Cost: 0.01},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			export, err := Parse(strings.NewReader(tt.csv))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if export.Source != tt.source || export.Format != tt.format {
+				t.Errorf("detected %s %s, want %s %s", export.Source, export.Format, tt.source, tt.format)
+			}
+			if len(export.Lines) != len(tt.want) {
+				t.Fatalf("lines = %+v, want %+v", export.Lines, tt.want)
+			}
+			for i, want := range tt.want {
+				got := export.Lines[i]
+				got.Cost = float64(int(got.Cost*100+0.5)) / 100
+				if got != want {
+					t.Errorf("line %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+
+	if _, err := Parse(strings.NewReader("date,amount\n2025-10-01,1\n")); err != ErrUnknownFormat {
+		t.Errorf("unknown CSV: %v", err)
+	}
+	if _, err := Parse(strings.NewReader("usage_date_utc,model,cost_usd\nyesterday,gpt-5,1\n")); err == nil || !strings.Contains(err.Error(), "row 2") {
+		t.Errorf("bad date: %v", err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	export := &Export{Source: SourceAnthropic, HasCost: true, Lines: []Line{
+		{Day: "2025-10-01", Model: "claude-opus-4-1", Cost: 10},
+		{Day: "2025-10-01", Model: "claude-sonnet-4-5", Cost: 4},
+		{Day: "2025-10-02", Model: "claude-haiku-4-5", Cost: 1},
+		{Day: "2025-10-02", Model: "claude-opus-4-1", Cost: 5},
+		{Day: "2025-10-03", Model: "claude-sonnet-4-5", Cost: 0.004},
+	}}
+	tracked := []Line{
+		{Day: "2025-09-30", Model: "claude-opus-4-1", Cost: 7}, // before the export
+		{Day: "2025-10-01", Model: "claude-opus-4-1", Cost: 9.7},
+		{Day: "2025-10-01", Model: "claude-sonnet-4-5", Cost: 3},
+		{Day: "2025-10-02", Model: "claude-opus-4-1", Cost: 6},
+		{Day: "2025-10-02", Model: "gpt-5", Cost: 2},
+	}
+	report := Compare(export, tracked, DefaultTolerance)
+
+	want := map[[2]string]string{
+		{"2025-10-01", "claude-opus-4-1"}:   StatusMatch,
+		{"2025-10-01", "claude-sonnet-4-5"}: StatusUnder,
+		{"2025-10-02", "claude-haiku-4-5"}:  StatusUntracked,
+		{"2025-10-02", "claude-opus-4-1"}:   StatusOver,
+		{"2025-10-02", "gpt-5"}:             StatusUnbilled,
+		{"2025-10-03", "claude-sonnet-4-5"}: StatusMatch, // untracked, but below a cent
+	}
+	if len(report.Items) != len(want) {
+		t.Fatalf("items = %+v", report.Items)
+	}
+	for _, item := range report.Items {
+		if status := want[[2]string{item.Day, item.Model}]; item.Status != status {
+			t.Errorf("%s %s: %s, want %s", item.Day, item.Model, item.Status, status)
+		}
+	}
+	if report.Tracked.Cost != 20.7 || report.Billed.Cost != 20.004 {
+		t.Errorf("totals: tracked %v, billed %v", report.Tracked.Cost, report.Billed.Cost)
+	}
+	if report.Items[0].Day != "2025-10-01" || report.Items[len(report.Items)-1].Day != "2025-10-03" {
+		t.Error("items should be sorted by day")
+	}
+
+	// Usage exports compare tokens
+	usage := &Export{Source: SourceOpenAI, HasTokens: true, Lines: []Line{{Day: "2025-10-01", Model: "gpt-5", InputTokens: 10000, OutputTokens: 2000}}}
+	report = Compare(usage, []Line{{Day: "2025-10-01", Model: "gpt-5", InputTokens: 9000, OutputTokens: 2000, Cost: 99}}, DefaultTolerance)
+	if report.ByCost || report.Items[0].Status != StatusUnder || report.Items[0].Diff(false) != 1000 {
+		t.Errorf("usage compare = %+v", report.Items[0])
+	}
+}
+
+func TestTrackedAndStore(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	ts := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC).Unix()
+	if err := db.Exec(fmt.Sprintf(`INSERT INTO usage_records (id, session_id, ts, input_tokens, output_tokens, input_cost, output_cost, model, provider, cache_hit) VALUES
+		('u1', 's', %[1]d, 100, 10, 1, 0.5, 'claude-opus-4-1-20250805', 'anthropic', 0),
+		('u2', 's', %[1]d, 100, 10, 1, 0.5, 'claude-opus-4-1', 'anthropic', 0),
+		('u3', 's', %[1]d, 100, 10, 1, 0.5, 'claude-opus-4-1', 'anthropic', 1),
+		('u4', 's', %[1]d, 100, 10, 1, 0.5, 'claude-opus-4-1', 'openrouter', 0);`, ts)); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	tracked, err := Tracked(db, "2025-10-01", "2025-10-01", []string{"anthropic"})
+	if err != nil {
+		t.Fatalf("Tracked: %v", err)
+	}
+	want := Line{Day: "2025-10-01", Model: "claude-opus-4-1", InputTokens: 200, OutputTokens: 20, Cost: 3}
+	if len(tracked) != 1 || tracked[0] != want {
+		t.Errorf("tracked = %+v, want snapshots merged and cache hits left out: %+v", tracked, want)
+	}
+
+	// Cost and usage exports of a source fill in the same rows, and a
+	// re-import replaces the days it covers
+	billed := func() string {
+		rows, err := db.QueryRows("SELECT day, model, input_tokens, output_tokens, cost FROM billed_usage ORDER BY day, model;")
+		if err != nil {
+			t.Fatalf("query billed: %v", err)
+		}
+		return strings.Join(rows, "\n")
+	}
+	cost := &Export{Source: SourceAnthropic, HasCost: true, Lines: []Line{
+		{Day: "2025-10-01", Model: "claude-opus-4-1", Cost: 3},
+		{Day: "2025-10-02", Model: "claude-haiku-4-5", Cost: 1},
+	}}
+	usage := &Export{Source: SourceAnthropic, HasTokens: true, Lines: []Line{
+		{Day: "2025-10-01", Model: "claude-opus-4-1", InputTokens: 200, OutputTokens: 20},
+	}}
+	for _, export := range []*Export{cost, usage} {
+		if err := Store(db, export); err != nil {
+			t.Fatalf("Store: %v", err)
+		}
+	}
+	if got := billed(); got != "2025-10-01|claude-opus-4-1|200|20|3.0\n2025-10-02|claude-haiku-4-5|0|0|1.0" {
+		t.Errorf("billed after both imports:\n%s", got)
+	}
+
+	// Billed costs keep every digit rather than rounding to six decimals
+	cost.Lines = []Line{{Day: "2025-10-01", Model: "claude-opus-4-1", Cost: 3.5}, {Day: "2025-10-02", Model: "claude-sonnet-4-5", Cost: 0.0000004}}
+	if err := Store(db, cost); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if got := billed(); got != "2025-10-01|claude-opus-4-1|200|20|3.5\n2025-10-02|claude-sonnet-4-5|0|0|4.0e-07" {
+		t.Errorf("billed after re-import:\n%s", got)
+	}
+}
@@ -0,0 +1,141 @@
+package reconcile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/core"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+// Tracked returns the recorded usage between two days (inclusive, UTC) by
+// day and normalized model. Only records of the given provider IDs count when
+// any are set; response cache hits never reach the provider and are left out.
+func Tracked(db *sqlite.DB, from, to string, providers []string) ([]Line, error) {
+	where := ""
+	if len(providers) > 0 {
+		quoted := make([]string, len(providers))
+		for i, id := range providers {
+			quoted[i] = "'" + escape(id) + "'"
+		}
+		where = fmt.Sprintf(" AND provider IN (%s)", strings.Join(quoted, ", "))
+	}
+	rows, err := db.QueryRows(fmt.Sprintf(`SELECT date(ts, 'unixepoch'), COALESCE(model, ''),
+			SUM(input_tokens), SUM(output_tokens), SUM(input_cost + output_cost)
+		FROM usage_records
+		WHERE cache_hit = 0
+		  AND date(ts, 'unixepoch') >= '%s'
+		  AND date(ts, 'unixepoch') <= '%s'%s
+		GROUP BY 1, 2;`, escape(from), escape(to), where))
+	if err != nil {
+		return nil, fmt.Errorf("query tracked usage: %w", err)
+	}
+
+	lines := make(map[[2]string]*Line)
+	for _, row := range rows {
+		parts := strings.Split(row, "|")
+		if len(parts) < 5 {
+			continue
+		}
+		model := NormalizeModel(parts[1])
+		key := [2]string{parts[0], model}
+		line, ok := lines[key]
+		if !ok {
+			line = &Line{Day: parts[0], Model: model}
+			lines[key] = line
+		}
+		line.InputTokens += atoi(parts[2])
+		line.OutputTokens += atoi(parts[3])
+		line.Cost += atof(parts[4])
+	}
+
+	result := make([]Line, 0, len(lines))
+	for _, line := range lines {
+		result = append(result, *line)
+	}
+	sortLines(result)
+	return result, nil
+}
+
+// SourceProviders returns the IDs of the configured providers an export
+// source bills for: the official API of its kind, or for OpenRouter any
+// provider pointed at openrouter.ai. The source's own name is included, as
+// profiles often use it as their provider.
+func SourceProviders(source string, providers []core.Provider) []string {
+	ids := []string{source}
+	for _, p := range providers {
+		var match bool
+		switch source {
+		case SourceOpenAI:
+			match = p.Kind == core.ProviderKindOpenAI
+		case SourceAnthropic:
+			match = p.Kind == core.ProviderKindAnthropic
+		case SourceOpenRouter:
+			match = strings.Contains(strings.ToLower(p.BaseURL), "openrouter.ai")
+		}
+		if match && p.ID != source {
+			ids = append(ids, p.ID)
+		}
+	}
+	return ids
+}
+
+// Store saves an export as the billed layer, replacing what earlier imports
+// of the same source said about the days it covers. Cost and usage exports of
+// one source fill in different columns of the same rows, so importing both
+// gives billed cost and tokens.
+func Store(db *sqlite.DB, export *Export) error {
+	if len(export.Lines) == 0 {
+		return nil
+	}
+	var columns, update []string
+	if export.HasTokens {
+		columns = append(columns, "input_tokens = 0", "output_tokens = 0")
+		update = append(update, "input_tokens = excluded.input_tokens", "output_tokens = excluded.output_tokens")
+	}
+	if export.HasCost {
+		columns = append(columns, "cost = 0")
+		update = append(update, "cost = excluded.cost")
+	}
+
+	now := time.Now().Unix()
+	statements := []string{"BEGIN;",
+		fmt.Sprintf("UPDATE billed_usage SET %s WHERE source = '%s' AND day >= '%s' AND day <= '%s';",
+			strings.Join(columns, ", "), escape(export.Source), escape(export.From()), escape(export.To()))}
+	for _, line := range export.Lines {
+		statements = append(statements, fmt.Sprintf(
+			`INSERT INTO billed_usage (source, day, model, input_tokens, output_tokens, cost, imported_at)
+			VALUES ('%s', '%s', '%s', %d, %d, %s, %d)
+			ON CONFLICT(source, day, model) DO UPDATE SET %s, imported_at = excluded.imported_at;`,
+			escape(export.Source), escape(line.Day), escape(line.Model), line.InputTokens, line.OutputTokens, strconv.FormatFloat(line.Cost, 'f', -1, 64), now, strings.Join(update, ", ")))
+	}
+	statements = append(statements,
+		"DELETE FROM billed_usage WHERE input_tokens = 0 AND output_tokens = 0 AND cost = 0;",
+		"COMMIT;")
+	if err := db.Exec(strings.Join(statements, "\n")); err != nil {
+		return fmt.Errorf("store billed usage: %w", err)
+	}
+	return nil
+}
+
+func atoi(s string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func atof(s string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
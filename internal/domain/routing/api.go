@@ -4,20 +4,40 @@ package routing
 import (
 	"context"
 	"fmt"
-	"regexp"
+	"sort"
+	"time"
 
 	"github.com/royisme/bobamixer/internal/store/config"
 )
 
 // Features represents the routing context for decision making.
 type Features struct {
-	Intent       string   // Task intent (e.g., "format", "review", "refactor")
-	TextSample   string   // Sample of input text
-	CtxChars     int      // Context size in characters
-	Branch       string   // Git branch name
-	ProjectTypes []string // Project types (e.g., ["go", "web"])
-	TimeOfDay    string   // Time in "HH:MM" format
-	BudgetHint   string   // "near_cap" | "normal" | "over_cap"
+	Intent       string    // Task intent (e.g., "format", "review", "refactor")
+	TextSample   string    // Sample of input text
+	CtxChars     int       // Context size in characters
+	Branch       string    // Git branch name
+	Project      string    // Project name from .boba-project.yaml
+	ProjectTypes []string  // Project types (e.g., ["go", "web"])
+	TimeOfDay    string    // "day", "evening" or "night"; derived from Now when empty
+	BudgetHint   string    // "near_cap" | "normal" | "over_cap"; see budget.Status.Hint
+	BudgetUsed   float64   // Percentage of the tightest budget limit spent
+	Now          time.Time // Evaluation time; zero means time.Now()
+}
+
+// Context converts features to the router's evaluation context
+func (f Features) Context() Context {
+	return Context{
+		Intent:      f.Intent,
+		Text:        f.TextSample,
+		CtxChars:    f.CtxChars,
+		Branch:      f.Branch,
+		Project:     f.Project,
+		ProjectType: f.ProjectTypes,
+		TimeOfDay:   f.TimeOfDay,
+		Budget:      f.BudgetHint,
+		BudgetUsed:  f.BudgetUsed,
+		Now:         f.Now,
+	}
 }
 
 // RoutingDecision represents a routing decision (TDD-spec aligned).
@@ -27,13 +47,15 @@ type RoutingDecision struct {
 	Profile  string // Selected profile key
 	Fallback string // Fallback profile if primary unavailable
 	Explore  bool   // Whether this is an exploration decision
+	Key      string // Context the outcome is learned under; see ContextKey
 }
 
 // Trace contains routing decision explanation.
 type Trace struct {
-	RuleID  string // ID of the rule that matched
-	Explain string // Human-readable explanation
-	Matched bool   // Whether the rule matched
+	RuleID   string // ID of the rule that matched
+	SubAgent string // Name of the sub-agent that matched; takes precedence over rules
+	Explain  string // Human-readable explanation
+	Matched  bool   // Whether a sub-agent or rule matched
 }
 
 // Engine is the routing decision engine.
@@ -45,55 +67,56 @@ type Engine struct {
 // Compile validates and compiles routing rules into an Engine.
 // Returns ErrConfig if rules contain invalid patterns or syntax.
 func Compile(rules []config.RouteRule) (*Engine, error) {
-	// Validate all rules
-	for i, rule := range rules {
-		if err := validateRule(rule); err != nil {
-			return nil, fmt.Errorf("rule %d (%s): %w", i, rule.ID, err)
-		}
-	}
-
-	// Create routes config
-	routesConfig := &config.RoutesConfig{
+	return CompileRoutes(&config.RoutesConfig{
 		Rules: rules,
 		Explore: config.ExploreConfig{
 			Enabled: true,
 			Rate:    0.03,
 		},
+	})
+}
+
+// CompileRoutes validates and compiles a full routes configuration, sub-agents
+// included, into an Engine. Exploration follows the configuration.
+func CompileRoutes(routes *config.RoutesConfig) (*Engine, error) {
+	// Validate all rules
+	for i, rule := range routes.Rules {
+		if err := validateRule(rule); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i, rule.ID, err)
+		}
 	}
 
-	// Create router
-	router := NewRouter(routesConfig)
+	// Validate sub-agents, reporting the first invalid one by name
+	if _, errs := compileSubAgents(routes.SubAgents); len(errs) > 0 {
+		names := make([]string, 0, len(errs))
+		for name := range errs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("sub-agent %s: %w", names[0], errs[names[0]])
+	}
 
 	return &Engine{
-		router: router,
-		rules:  rules,
+		router: NewRouter(routes),
+		rules:  routes.Rules,
 	}, nil
 }
 
 // Match determines the routing decision based on features.
 // Returns the decision and a trace explaining how the decision was made.
 func (e *Engine) Match(ctx context.Context, f Features) (*RoutingDecision, *Trace, error) {
-	// Convert Features to routing.Context
-	routingCtx := Context{
-		Intent:      f.Intent,
-		Text:        f.TextSample,
-		CtxChars:    f.CtxChars,
-		Branch:      f.Branch,
-		ProjectType: f.ProjectTypes,
-		TimeOfDay:   f.TimeOfDay,
-	}
-
 	// Use empty active profile for pure rule-based routing
 	activeProfile := ""
 
 	// Execute routing
-	decision := e.router.Route(routingCtx, activeProfile)
+	decision := e.router.Route(f.Context(), activeProfile)
 
 	// Build trace
 	trace := &Trace{
-		RuleID:  decision.RuleID,
-		Explain: decision.Explain,
-		Matched: decision.RuleID != "",
+		RuleID:   decision.RuleID,
+		SubAgent: decision.SubAgent,
+		Explain:  decision.Explain,
+		Matched:  decision.RuleID != "" || decision.SubAgent != "",
 	}
 
 	// Build decision result
@@ -101,6 +124,7 @@ func (e *Engine) Match(ctx context.Context, f Features) (*RoutingDecision, *Trac
 		Profile:  decision.ProfileKey,
 		Fallback: decision.Fallback,
 		Explore:  decision.Explore,
+		Key:      decision.Key,
 	}
 
 	return result, trace, nil
@@ -120,51 +144,9 @@ func validateRule(rule config.RouteRule) error {
 		return fmt.Errorf("rule target profile (use) is required")
 	}
 
-	// Validate regex patterns in conditions
-	if err := validateConditionPatterns(rule.If); err != nil {
+	if _, err := CompileCondition(rule.If); err != nil {
 		return fmt.Errorf("invalid condition: %w", err)
 	}
 
 	return nil
 }
-
-// validateConditionPatterns validates regex patterns in rule conditions.
-func validateConditionPatterns(condition string) error {
-	// Extract and validate text.matches() patterns
-	textMatchesPattern := regexp.MustCompile(`text\.matches\('([^']+)'\)`)
-	matches := textMatchesPattern.FindAllStringSubmatch(condition, -1)
-	for _, match := range matches {
-		if len(match) > 1 {
-			pattern := match[1]
-			if _, err := regexp.Compile(pattern); err != nil {
-				return fmt.Errorf("invalid regex pattern '%s': %w", pattern, err)
-			}
-		}
-	}
-
-	// Extract and validate branch.matches() patterns
-	branchMatchesPattern := regexp.MustCompile(`branch\.matches\('([^']+)'\)`)
-	matches = branchMatchesPattern.FindAllStringSubmatch(condition, -1)
-	for _, match := range matches {
-		if len(match) > 1 {
-			pattern := match[1]
-			if _, err := regexp.Compile(pattern); err != nil {
-				return fmt.Errorf("invalid regex pattern '%s': %w", pattern, err)
-			}
-		}
-	}
-
-	// Extract and validate task.matches() patterns
-	taskMatchesPattern := regexp.MustCompile(`task\.matches\('([^']+)'\)`)
-	matches = taskMatchesPattern.FindAllStringSubmatch(condition, -1)
-	for _, match := range matches {
-		if len(match) > 1 {
-			pattern := match[1]
-			if _, err := regexp.Compile(pattern); err != nil {
-				return fmt.Errorf("invalid regex pattern '%s': %w", pattern, err)
-			}
-		}
-	}
-
-	return nil
-}
@@ -0,0 +1,533 @@
+package routing
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CompileError reports an invalid condition at a position in its source
+type CompileError struct {
+	Pos int // Byte offset in the condition
+	Col int // 1-based column (in characters), set once the source is known
+	Msg string
+}
+
+func (e *CompileError) Error() string {
+	if e.Col > 0 {
+		return fmt.Sprintf("col %d: %s", e.Col, e.Msg)
+	}
+	return e.Msg
+}
+
+// Condition is a compiled routing condition
+type Condition struct {
+	src  string
+	eval evalFunc
+}
+
+// CompileCondition parses and type-checks a rule condition. Unknown fields and
+// functions, type mismatches and invalid regular expressions are reported with
+// their position rather than silently evaluating to false.
+func CompileCondition(src string) (*Condition, error) {
+	cond, err := compileCondition(src)
+	if err != nil {
+		if ce, ok := err.(*CompileError); ok { //nolint:errorlint // compile errors are never wrapped here
+			ce.Col = utf8.RuneCountInString(src[:min(ce.Pos, len(src))]) + 1
+		}
+		return nil, err
+	}
+	return cond, nil
+}
+
+func compileCondition(src string) (*Condition, error) {
+	tree, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	expr, err := check(tree)
+	if err != nil {
+		return nil, err
+	}
+	if expr.typ != typeBool {
+		return nil, &CompileError{Pos: tree.position(), Msg: fmt.Sprintf("condition must be true or false, not a %s", expr.typ)}
+	}
+	return &Condition{src: src, eval: expr.eval}, nil
+}
+
+// Eval reports whether the condition holds for the context
+func (c *Condition) Eval(ctx Context) bool {
+	env := &evalEnv{ctx: &ctx, now: ctx.Now}
+	if env.now.IsZero() {
+		env.now = time.Now()
+	}
+	v, _ := c.eval(env).(bool) //nolint:errcheck // type-checked as bool at compile time
+	return v
+}
+
+// String returns the condition source
+func (c *Condition) String() string { return c.src }
+
+// valueType is the static type of an expression
+type valueType int
+
+const (
+	typeAny valueType = iota // element type of an empty list
+	typeBool
+	typeString
+	typeNumber
+	typeDuration
+	typeList
+)
+
+func (t valueType) String() string {
+	switch t {
+	case typeBool:
+		return "boolean"
+	case typeString:
+		return "string"
+	case typeNumber:
+		return "number"
+	case typeDuration:
+		return "duration"
+	case typeList:
+		return "list"
+	default:
+		return "value"
+	}
+}
+
+// evalEnv is what compiled expressions evaluate against
+type evalEnv struct {
+	ctx *Context
+	now time.Time
+}
+
+type evalFunc func(env *evalEnv) interface{}
+
+// typedExpr is a type-checked expression ready to evaluate
+type typedExpr struct {
+	typ  valueType
+	elem valueType // element type of lists
+	eval evalFunc
+}
+
+// variable is a field conditions can read
+type variable struct {
+	typ  valueType
+	elem valueType
+	get  evalFunc
+}
+
+// variables lists the fields available to conditions
+var variables = map[string]variable{
+	"intent":  {typ: typeString, get: func(e *evalEnv) interface{} { return e.ctx.Intent }},
+	"task":    {typ: typeString, get: func(e *evalEnv) interface{} { return e.ctx.Intent }},
+	"text":    {typ: typeString, get: func(e *evalEnv) interface{} { return e.ctx.Text }},
+	"branch":  {typ: typeString, get: func(e *evalEnv) interface{} { return e.ctx.Branch }},
+	"project": {typ: typeString, get: func(e *evalEnv) interface{} { return e.ctx.Project }},
+	"project_types": {typ: typeList, elem: typeString, get: func(e *evalEnv) interface{} {
+		list := make([]interface{}, len(e.ctx.ProjectType))
+		for i, pt := range e.ctx.ProjectType {
+			list[i] = pt
+		}
+		return list
+	}},
+	"ctx_chars": {typ: typeNumber, get: func(e *evalEnv) interface{} { return float64(e.ctx.CtxChars) }},
+	"time_of_day": {typ: typeString, get: func(e *evalEnv) interface{} {
+		if e.ctx.TimeOfDay != "" {
+			return e.ctx.TimeOfDay
+		}
+		return TimeOfDayLabel(e.now)
+	}},
+	// budget is normal, near_cap or over_cap; budget_used is the percentage of the tightest limit
+	"budget": {typ: typeString, get: func(e *evalEnv) interface{} {
+		if e.ctx.Budget != "" {
+			return e.ctx.Budget
+		}
+		return "normal"
+	}},
+	"budget_used": {typ: typeNumber, get: func(e *evalEnv) interface{} { return e.ctx.BudgetUsed }},
+	// clock is the local time since midnight, for comparisons such as clock >= 9h
+	"clock": {typ: typeDuration, get: func(e *evalEnv) interface{} {
+		y, m, d := e.now.Date()
+		return e.now.Sub(time.Date(y, m, d, 0, 0, 0, 0, e.now.Location()))
+	}},
+}
+
+// TimeOfDayLabel buckets a time into the time_of_day labels: day, evening or night
+func TimeOfDayLabel(t time.Time) string {
+	switch hour := t.Hour(); {
+	case hour < 6 || hour >= 22:
+		return "night"
+	case hour >= 18:
+		return "evening"
+	default:
+		return "day"
+	}
+}
+
+// check type-checks a syntax tree and compiles it into an evaluator
+func check(n node) (*typedExpr, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		return checkLiteral(n)
+	case *identNode:
+		v, ok := variables[n.name]
+		if !ok {
+			return nil, &CompileError{Pos: n.pos, Msg: fmt.Sprintf("unknown field '%s'%s", n.name, suggest(n.name, variableNames()))}
+		}
+		return &typedExpr{typ: v.typ, elem: v.elem, eval: v.get}, nil
+	case *listNode:
+		return checkList(n)
+	case *notNode:
+		operand, err := check(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ != typeBool {
+			return nil, &CompileError{Pos: n.pos, Msg: fmt.Sprintf("'!' needs a true/false condition, not a %s", operand.typ)}
+		}
+		return &typedExpr{typ: typeBool, eval: func(e *evalEnv) interface{} { return !operand.eval(e).(bool) }}, nil
+	case *binaryNode:
+		return checkBinary(n)
+	case *inNode:
+		return checkIn(n)
+	case *callNode:
+		return checkCall(n)
+	default:
+		return nil, &CompileError{Pos: n.position(), Msg: "unsupported expression"}
+	}
+}
+
+func checkLiteral(n *literalNode) (*typedExpr, error) {
+	constant := func(typ valueType, v interface{}) *typedExpr {
+		return &typedExpr{typ: typ, eval: func(*evalEnv) interface{} { return v }}
+	}
+	switch n.tok.kind {
+	case tokString:
+		return constant(typeString, n.tok.text), nil
+	case tokNumber:
+		return constant(typeNumber, n.tok.num), nil
+	case tokDuration:
+		return constant(typeDuration, n.tok.dur), nil
+	default:
+		return constant(typeBool, n.tok.text == "true"), nil
+	}
+}
+
+func checkList(n *listNode) (*typedExpr, error) {
+	elem := typeAny
+	items := make([]*typedExpr, 0, len(n.items))
+	for _, item := range n.items {
+		expr, err := check(item)
+		if err != nil {
+			return nil, err
+		}
+		if expr.typ == typeList {
+			return nil, &CompileError{Pos: item.position(), Msg: "lists cannot be nested"}
+		}
+		if elem != typeAny && expr.typ != elem {
+			return nil, &CompileError{Pos: item.position(), Msg: fmt.Sprintf("list mixes %s and %s values", elem, expr.typ)}
+		}
+		elem = expr.typ
+		items = append(items, expr)
+	}
+	return &typedExpr{typ: typeList, elem: elem, eval: func(e *evalEnv) interface{} {
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			list[i] = item.eval(e)
+		}
+		return list
+	}}, nil
+}
+
+func checkBinary(n *binaryNode) (*typedExpr, error) {
+	left, err := check(n.left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := check(n.right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "&&", "||":
+		for _, side := range []struct {
+			expr *typedExpr
+			node node
+		}{{left, n.left}, {right, n.right}} {
+			if side.expr.typ != typeBool {
+				return nil, &CompileError{Pos: side.node.position(), Msg: fmt.Sprintf("'%s' needs true/false conditions on both sides, found a %s", n.op, side.expr.typ)}
+			}
+		}
+		if n.op == "&&" {
+			return &typedExpr{typ: typeBool, eval: func(e *evalEnv) interface{} {
+				return left.eval(e).(bool) && right.eval(e).(bool)
+			}}, nil
+		}
+		return &typedExpr{typ: typeBool, eval: func(e *evalEnv) interface{} {
+			return left.eval(e).(bool) || right.eval(e).(bool)
+		}}, nil
+	}
+
+	if left.typ != right.typ {
+		return nil, &CompileError{Pos: n.pos, Msg: fmt.Sprintf("cannot compare %s with %s", left.typ, right.typ)}
+	}
+	switch n.op {
+	case "==", "!=":
+		if left.typ == typeList {
+			return nil, &CompileError{Pos: n.pos, Msg: "lists cannot be compared; use 'in' or contains()"}
+		}
+		negate := n.op == "!="
+		return &typedExpr{typ: typeBool, eval: func(e *evalEnv) interface{} {
+			return (left.eval(e) == right.eval(e)) != negate
+		}}, nil
+	default:
+		if left.typ != typeNumber && left.typ != typeDuration {
+			return nil, &CompileError{Pos: n.pos, Msg: fmt.Sprintf("'%s' compares numbers or durations, not %ss", n.op, left.typ)}
+		}
+		cmp := orderings[n.op]
+		return &typedExpr{typ: typeBool, eval: func(e *evalEnv) interface{} {
+			return cmp(number(left.eval(e)), number(right.eval(e)))
+		}}, nil
+	}
+}
+
+var orderings = map[string]func(a, b float64) bool{
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+}
+
+// number converts a number or duration value for ordering
+func number(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case time.Duration:
+		return float64(v)
+	default:
+		return 0
+	}
+}
+
+func checkIn(n *inNode) (*typedExpr, error) {
+	value, err := check(n.value)
+	if err != nil {
+		return nil, err
+	}
+	list, err := check(n.list)
+	if err != nil {
+		return nil, err
+	}
+	if list.typ != typeList {
+		return nil, &CompileError{Pos: n.list.position(), Msg: fmt.Sprintf("'in' needs a list, e.g. ['a', 'b'], not a %s", list.typ)}
+	}
+	if list.elem != typeAny && list.elem != value.typ {
+		return nil, &CompileError{Pos: n.pos, Msg: fmt.Sprintf("cannot look for a %s in a list of %ss", value.typ, list.elem)}
+	}
+	return &typedExpr{typ: typeBool, eval: func(e *evalEnv) interface{} {
+		return containsValue(list.eval(e), value.eval(e))
+	}}, nil
+}
+
+func containsValue(list, v interface{}) bool {
+	items, _ := list.([]interface{}) //nolint:errcheck // type-checked as a list at compile time
+	for _, item := range items {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// methods lists the functions callable on each type
+var methods = map[valueType][]string{
+	typeString: {"matches", "contains", "equals", "starts_with"},
+	typeList:   {"contains", "includes"},
+}
+
+func checkCall(n *callNode) (*typedExpr, error) {
+	receiver, err := check(n.receiver)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]*typedExpr, 0, len(n.args))
+	for _, arg := range n.args {
+		expr, err := check(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, expr)
+	}
+	name := n.method
+	if ident, ok := n.receiver.(*identNode); ok {
+		name = ident.name + "." + n.method
+	}
+
+	// time_of_day.in('09:00-18:00', ...) tests the clock against time ranges
+	if ident, ok := n.receiver.(*identNode); ok && ident.name == "time_of_day" && n.method == "in" {
+		return checkTimeRanges(n)
+	}
+
+	switch {
+	case receiver.typ == typeString && (n.method == "matches" || n.method == "contains" ||
+		n.method == "equals" || n.method == "starts_with"):
+		if len(args) != 1 || args[0].typ != typeString {
+			return nil, &CompileError{Pos: n.pos, Msg: fmt.Sprintf("%s() takes one string", name)}
+		}
+		arg := args[0]
+		switch n.method {
+		case "matches":
+			lit, ok := n.args[0].(*literalNode)
+			if !ok {
+				return nil, &CompileError{Pos: n.args[0].position(), Msg: "matches() needs a quoted pattern"}
+			}
+			re, err := regexp.Compile(lit.tok.text)
+			if err != nil {
+				return nil, &CompileError{Pos: lit.tok.pos, Msg: fmt.Sprintf("invalid regular expression: %v", err)}
+			}
+			return &typedExpr{typ: typeBool, eval: func(e *evalEnv) interface{} {
+				return re.MatchString(receiver.eval(e).(string))
+			}}, nil
+		case "contains":
+			return &typedExpr{typ: typeBool, eval: func(e *evalEnv) interface{} {
+				return strings.Contains(receiver.eval(e).(string), arg.eval(e).(string))
+			}}, nil
+		case "starts_with":
+			return &typedExpr{typ: typeBool, eval: func(e *evalEnv) interface{} {
+				return strings.HasPrefix(receiver.eval(e).(string), arg.eval(e).(string))
+			}}, nil
+		default:
+			return &typedExpr{typ: typeBool, eval: func(e *evalEnv) interface{} {
+				return receiver.eval(e).(string) == arg.eval(e).(string)
+			}}, nil
+		}
+
+	case receiver.typ == typeList && (n.method == "contains" || n.method == "includes"):
+		if len(args) != 1 {
+			return nil, &CompileError{Pos: n.pos, Msg: fmt.Sprintf("%s() takes one value", name)}
+		}
+		if receiver.elem != typeAny && args[0].typ != receiver.elem {
+			return nil, &CompileError{Pos: n.args[0].position(), Msg: fmt.Sprintf("%s() takes a %s, not a %s", name, receiver.elem, args[0].typ)}
+		}
+		arg := args[0]
+		return &typedExpr{typ: typeBool, eval: func(e *evalEnv) interface{} {
+			return containsValue(receiver.eval(e), arg.eval(e))
+		}}, nil
+	}
+
+	return nil, &CompileError{Pos: n.pos, Msg: fmt.Sprintf("unknown function '%s' for a %s%s", name, receiver.typ, suggest(n.method, methods[receiver.typ]))}
+}
+
+// checkTimeRanges compiles time_of_day.in('HH:MM-HH:MM', ...). Ranges are inclusive
+// and may wrap past midnight ('22:00-06:00').
+func checkTimeRanges(n *callNode) (*typedExpr, error) {
+	if len(n.args) == 0 {
+		return nil, &CompileError{Pos: n.pos, Msg: "time_of_day.in() needs at least one range such as '09:00-18:00'"}
+	}
+	spans := make([]timeSpan, 0, len(n.args))
+	for _, arg := range n.args {
+		lit, ok := arg.(*literalNode)
+		if !ok || lit.tok.kind != tokString {
+			return nil, &CompileError{Pos: arg.position(), Msg: "time ranges are quoted, e.g. '09:00-18:00'"}
+		}
+		start, end, err := parseTimeRange(lit.tok.text)
+		if err != nil {
+			return nil, &CompileError{Pos: lit.tok.pos, Msg: err.Error()}
+		}
+		spans = append(spans, timeSpan{start, end})
+	}
+	return &typedExpr{typ: typeBool, eval: func(e *evalEnv) interface{} {
+		for _, s := range spans {
+			if s.contains(e.now) {
+				return true
+			}
+		}
+		return false
+	}}, nil
+}
+
+// timeSpan is an inclusive range of minutes since midnight; start > end wraps midnight
+type timeSpan struct{ start, end int }
+
+func (s timeSpan) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if s.start <= s.end {
+		return minute >= s.start && minute <= s.end
+	}
+	return minute >= s.start || minute <= s.end
+}
+
+// parseTimeRange parses "HH:MM-HH:MM" into minutes since midnight
+func parseTimeRange(s string) (start, end int, err error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid time range '%s' (want HH:MM-HH:MM)", s)
+	}
+	minutes := make([]int, 2)
+	for i, part := range parts {
+		hm := strings.Split(strings.TrimSpace(part), ":")
+		if len(hm) != 2 {
+			return 0, 0, fmt.Errorf("invalid time '%s' (want HH:MM)", part)
+		}
+		h, herr := strconv.Atoi(hm[0])
+		m, merr := strconv.Atoi(hm[1])
+		if herr != nil || merr != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+			return 0, 0, fmt.Errorf("invalid time '%s' (want HH:MM)", part)
+		}
+		minutes[i] = h*60 + m
+	}
+	return minutes[0], minutes[1], nil
+}
+
+func variableNames() []string {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// suggest returns a "did you mean" hint for a close candidate, or ""
+func suggest(name string, candidates []string) string {
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		if d := editDistance(name, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean '%s'?)", best)
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
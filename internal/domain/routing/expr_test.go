@@ -0,0 +1,130 @@
+package routing
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/store/config"
+)
+
+func TestConditionEval(t *testing.T) {
+	ctx := Context{
+		Intent:      "format",
+		Text:        "please run prettier on this file",
+		Branch:      "feature/login",
+		ProjectType: []string{"go", "web"},
+		CtxChars:    4200,
+		Budget:      "near_cap",
+		BudgetUsed:  84.5,
+		Now:         time.Date(2025, 1, 15, 23, 10, 0, 0, time.Local),
+	}
+	tests := map[string]bool{
+		"true":                        true,
+		"ctx_chars>3000":              true,
+		"ctx_chars >= 4200":           true,
+		"ctx_chars < 1000":            false,
+		"ctx_chars != 4200":           false,
+		"!text.matches('review')":     true,
+		"!(intent == 'format')":       false,
+		"intent in ['format', 'fmt']": true,
+		"intent in []":                false,
+		"'go' in project_types":       true,
+		"'rust' in project_types":     false,
+		"project_types.includes('web') && project_types.contains('go')":   true,
+		"branch.starts_with('feature/') && !branch.equals('main')":        true,
+		"branch=='main' || branch.equals('master')":                       false,
+		"intent=='format' || text.matches('\\bformat\\b|\\bprettier\\b')": true,
+		"task.matches('^form') && text.contains('prettier')":              true,
+		"clock >= 23h && clock < 23h30m":                                  true,
+		"clock < 9h":                                                      false,
+		"time_of_day == 'night'":                                          true,
+		"time_of_day.in('22:00-06:00')":                                   true,
+		"time_of_day.in('09:00-12:00', '13:00-18:00')":                    false,
+		"(ctx_chars > 100 || intent == 'x') && !(branch == 'main')":       true,
+		"budget in ['near_cap', 'over_cap'] && budget_used >= 80":         true,
+		"budget == 'normal'":                                              false,
+	}
+	for src, want := range tests {
+		cond, err := CompileCondition(src)
+		if err != nil {
+			t.Errorf("CompileCondition(%q): %v", src, err)
+			continue
+		}
+		if got := cond.Eval(ctx); got != want {
+			t.Errorf("%q = %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestConditionErrorsHavePositions(t *testing.T) {
+	tests := []struct {
+		src  string
+		col  int
+		want string
+	}{
+		{"ctx_char >= 3000", 1, "unknown field 'ctx_char' (did you mean 'ctx_chars'?)"},
+		{"intent == 'a' && ctx_chars >= 'big'", 28, "cannot compare number with string"},
+		{"text.matches('[x')", 14, "invalid regular expression"},
+		{"text.match('x')", 6, "did you mean 'matches'?"},
+		{"intent = 'review'", 8, "use '=='"},
+		{"ctx_chars >", 12, "expected a field, literal or '('"},
+		{"ctx_chars", 1, "must be true or false"},
+		{"!ctx_chars", 1, "'!' needs a true/false condition"},
+		{"intent in 'review'", 11, "'in' needs a list"},
+		{"ctx_chars in ['a']", 11, "cannot look for a number in a list of strings"},
+		{"clock > 25x", 9, "invalid duration"},
+		{"time_of_day.in('25:00-26:00')", 16, "invalid time"},
+		{"text.contains('a'", 18, "expected ',' or ')'"},
+		{"'ünï' == text &&", 17, "expected a field"},
+	}
+	for _, tt := range tests {
+		_, err := CompileCondition(tt.src)
+		if err == nil {
+			t.Errorf("CompileCondition(%q) succeeded, want error", tt.src)
+			continue
+		}
+		ce, ok := err.(*CompileError) //nolint:errorlint // CompileCondition returns it unwrapped
+		if !ok {
+			t.Errorf("CompileCondition(%q) error %T, want *CompileError", tt.src, err)
+			continue
+		}
+		if ce.Col != tt.col || !strings.Contains(ce.Msg, tt.want) {
+			t.Errorf("CompileCondition(%q) = col %d %q, want col %d containing %q", tt.src, ce.Col, ce.Msg, tt.col, tt.want)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	rules := []config.RouteRule{
+		{ID: "big", If: "ctx_chars > 3000", Use: "heavy"},
+		{ID: "big", If: "ctx_char > 10", Use: "heavy"},
+		{ID: "all", If: "true", Use: "fast", Fallback: "fast"},
+		{ID: "late", If: "intent == 'x'", Use: "missing"},
+	}
+	subAgents := map[string]config.SubAgent{
+		"review": {Profile: "heavy", Triggers: []string{"review"}, Conditions: map[string]interface{}{"min_ctx_char": 3000}},
+		"fix":    {Profile: "gone", Triggers: []string{"fix"}},
+	}
+	issues := Lint(&config.RoutesConfig{SubAgents: subAgents, Rules: rules}, map[string]bool{"heavy": true, "fast": true})
+
+	var got []string
+	for _, issue := range issues {
+		got = append(got, string(issue.Severity)+": "+issue.Msg)
+	}
+	want := []string{
+		"error: unknown profile 'gone'",
+		"error: unknown condition 'min_ctx_char' (did you mean 'min_ctx_chars'?)",
+		"error: duplicate rule ID (also rule 1)",
+		"error: unknown field 'ctx_char' (did you mean 'ctx_chars'?)",
+		"warning: fallback is the same profile as use",
+		"warning: unreachable: rule 3 always matches first",
+		"error: unknown profile 'missing' in use",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Lint() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if issues[0].SubAgent != "fix" || issues[3].Col != 1 || issues[3].Condition != "ctx_char > 10" {
+		t.Errorf("issues = %+v, want sub-agent fix first and rule condition at col 1", issues)
+	}
+}
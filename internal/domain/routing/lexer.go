@@ -0,0 +1,153 @@
+package routing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind classifies a lexical token of the condition language
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDuration
+	tokOp // operators and punctuation
+)
+
+// token is one lexical token; pos is its byte offset in the source
+type token struct {
+	kind tokenKind
+	text string // operator, identifier or decoded string literal
+	num  float64
+	dur  time.Duration
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of condition"
+	case tokString:
+		return fmt.Sprintf("string '%s'", t.text)
+	case tokNumber, tokDuration:
+		return fmt.Sprintf("literal %s", t.text)
+	default:
+		return fmt.Sprintf("'%s'", t.text)
+	}
+}
+
+// operators, longest first so "<=" wins over "<"
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ",", "."}
+
+// lex splits a condition into tokens
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+
+		case r == '\'' || r == '"':
+			text, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, &CompileError{Pos: i, Msg: err.Error()}
+			}
+			tokens = append(tokens, token{kind: tokString, text: text, pos: i})
+			i += n
+
+		case r >= '0' && r <= '9':
+			tok, n, err := lexNumber(src[i:])
+			if err != nil {
+				return nil, &CompileError{Pos: i, Msg: err.Error()}
+			}
+			tok.pos = i
+			tokens = append(tokens, tok)
+			i += n
+
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(src) {
+				r, size := utf8.DecodeRuneInString(src[j:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				j += size
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			switch {
+			case op != "":
+				tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+				i += len(op)
+			case r == '=':
+				return nil, &CompileError{Pos: i, Msg: "use '==' to compare"}
+			case r == '&' || r == '|':
+				return nil, &CompileError{Pos: i, Msg: fmt.Sprintf("use '%c%c' to combine conditions", r, r)}
+			default:
+				return nil, &CompileError{Pos: i, Msg: fmt.Sprintf("unexpected character %q", r)}
+			}
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// lexString reads a quoted string. A backslash only escapes the quote character;
+// other backslashes are kept so regular expressions read as written.
+func lexString(src string) (text string, n int, err error) {
+	quote := src[0]
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch {
+		case src[i] == quote:
+			return b.String(), i + 1, nil
+		case src[i] == '\\' && i+1 < len(src) && src[i+1] == quote:
+			b.WriteByte(quote)
+			i++
+		default:
+			b.WriteByte(src[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// lexNumber reads a number (3000, 0.8) or a duration (90s, 1h30m)
+func lexNumber(src string) (token, int, error) {
+	i := 0
+	for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+		i++
+	}
+	if i < len(src) && unicode.IsLetter(rune(src[i])) {
+		// Duration: digits and unit letters, e.g. 1h30m or 250ms
+		for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.' || unicode.IsLetter(rune(src[i]))) {
+			i++
+		}
+		d, err := time.ParseDuration(src[:i])
+		if err != nil {
+			return token{}, 0, fmt.Errorf("invalid duration %q (use units h, m, s or ms)", src[:i])
+		}
+		return token{kind: tokDuration, text: src[:i], dur: d}, i, nil
+	}
+
+	num, err := strconv.ParseFloat(src[:i], 64)
+	if err != nil {
+		return token{}, 0, fmt.Errorf("invalid number %q", src[:i])
+	}
+	return token{kind: tokNumber, text: src[:i], num: num}, i, nil
+}
@@ -0,0 +1,105 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/royisme/bobamixer/internal/store/config"
+)
+
+// Severity grades a lint issue
+type Severity string
+
+// Lint severities
+const (
+	SeverityError   Severity = "error"   // Compile rejects the rules
+	SeverityWarning Severity = "warning" // Valid, but probably not what was meant
+)
+
+// Issue is a problem found in routing rules or sub-agents
+type Issue struct {
+	Severity  Severity
+	SubAgent  string // Set when the issue is in a sub-agent rather than a rule
+	Index     int    // Position of the rule in routes.yaml (0-based)
+	RuleID    string // Empty when the rule has no id
+	Msg       string
+	Condition string // The rule's condition, when the issue points into it
+	Col       int    // 1-based column in Condition; 0 when not positional
+}
+
+// Lint checks sub-agents and routing rules for the errors CompileRoutes rejects and
+// for likely mistakes. When knownProfiles is non-nil, target profiles must be in it.
+func Lint(routes *config.RoutesConfig, knownProfiles map[string]bool) []Issue {
+	issues := lintSubAgents(routes.SubAgents, knownProfiles)
+	seen := make(map[string]int)
+	catchAll := -1
+
+	for i, rule := range routes.Rules {
+		add := func(severity Severity, format string, args ...interface{}) {
+			issues = append(issues, Issue{Severity: severity, Index: i, RuleID: rule.ID, Msg: fmt.Sprintf(format, args...)})
+		}
+
+		switch first, dup := seen[rule.ID]; {
+		case rule.ID == "":
+			add(SeverityError, "rule ID is required")
+		case dup:
+			add(SeverityError, "duplicate rule ID (also rule %d)", first+1)
+		default:
+			seen[rule.ID] = i
+		}
+
+		if catchAll >= 0 {
+			add(SeverityWarning, "unreachable: rule %d always matches first", catchAll+1)
+		}
+
+		if strings.TrimSpace(rule.If) == "" {
+			add(SeverityError, "rule condition (if) is required")
+		} else if _, err := CompileCondition(rule.If); err != nil {
+			issue := Issue{Severity: SeverityError, Index: i, RuleID: rule.ID, Msg: err.Error(), Condition: rule.If}
+			var ce *CompileError
+			if errors.As(err, &ce) {
+				issue.Msg, issue.Col = ce.Msg, ce.Col
+			}
+			issues = append(issues, issue)
+		} else if strings.TrimSpace(rule.If) == "true" && catchAll < 0 {
+			catchAll = i
+		}
+
+		if rule.Use == "" {
+			add(SeverityError, "rule target profile (use) is required")
+		} else if knownProfiles != nil && !knownProfiles[rule.Use] {
+			add(SeverityError, "unknown profile '%s' in use", rule.Use)
+		}
+		if rule.Fallback != "" {
+			if knownProfiles != nil && !knownProfiles[rule.Fallback] {
+				add(SeverityError, "unknown profile '%s' in fallback", rule.Fallback)
+			}
+			if rule.Fallback == rule.Use {
+				add(SeverityWarning, "fallback is the same profile as use")
+			}
+		}
+	}
+	return issues
+}
+
+// lintSubAgents checks sub-agents in the order the router tries them
+func lintSubAgents(subAgents map[string]config.SubAgent, knownProfiles map[string]bool) []Issue {
+	var issues []Issue
+	_, errs := compileSubAgents(subAgents)
+	names := make([]string, 0, len(subAgents))
+	for name := range subAgents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := errs[name]; err != nil {
+			issues = append(issues, Issue{Severity: SeverityError, SubAgent: name, Msg: err.Error()})
+		}
+		if profile := subAgents[name].Profile; profile != "" && knownProfiles != nil && !knownProfiles[profile] {
+			issues = append(issues, Issue{Severity: SeverityError, SubAgent: name, Msg: fmt.Sprintf("unknown profile '%s'", profile)})
+		}
+	}
+	return issues
+}
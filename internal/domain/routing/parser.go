@@ -0,0 +1,275 @@
+package routing
+
+import "fmt"
+
+// Condition grammar, lowest precedence first:
+//
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | comparison
+//	comparison = postfix [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "in" ) postfix ]
+//	postfix    = primary { "." ident [ "(" [ or { "," or } ] ")" ] }
+//	primary    = ident | string | number | duration | "[" [ or { "," or } ] "]" | "(" or ")"
+
+// node is a parsed condition expression
+type node interface {
+	position() int
+}
+
+type (
+	// binaryNode is a logical (&&, ||) or comparison operator
+	binaryNode struct {
+		op          string
+		left, right node
+		pos         int
+	}
+	// notNode negates a boolean
+	notNode struct {
+		operand node
+		pos     int
+	}
+	// inNode tests list membership: value in list
+	inNode struct {
+		value, list node
+		pos         int
+	}
+	// identNode names a field, possibly dotted (budget.remaining)
+	identNode struct {
+		name string
+		pos  int
+	}
+	// callNode calls a method on a value: text.matches('...')
+	callNode struct {
+		receiver node
+		method   string
+		args     []node
+		pos      int
+	}
+	// literalNode is a string, number, duration or boolean literal
+	literalNode struct {
+		tok token
+	}
+	// listNode is a list literal: ['a', 'b']
+	listNode struct {
+		items []node
+		pos   int
+	}
+)
+
+func (n *binaryNode) position() int  { return n.pos }
+func (n *notNode) position() int     { return n.pos }
+func (n *inNode) position() int      { return n.pos }
+func (n *identNode) position() int   { return n.pos }
+func (n *callNode) position() int    { return n.pos }
+func (n *literalNode) position() int { return n.tok.pos }
+func (n *listNode) position() int    { return n.pos }
+
+// parser is a recursive-descent parser over lexed tokens
+type parser struct {
+	tokens []token
+	i      int
+}
+
+// parse parses a condition into its syntax tree
+func parse(src string) (node, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, &CompileError{Pos: 0, Msg: "empty condition"}
+	}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.unexpected(tok, "'&&', '||' or end of condition")
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.i] }
+
+func (p *parser) next() token {
+	tok := p.tokens[p.i]
+	if tok.kind != tokEOF {
+		p.i++
+	}
+	return tok
+}
+
+// accept consumes the operator if it is next
+func (p *parser) accept(op string) (token, bool) {
+	if tok := p.peek(); tok.kind == tokOp && tok.text == op {
+		return p.next(), true
+	}
+	return token{}, false
+}
+
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		return p.unexpected(p.peek(), fmt.Sprintf("'%s'", op))
+	}
+	return nil
+}
+
+func (p *parser) unexpected(tok token, want string) error {
+	return &CompileError{Pos: tok.pos, Msg: fmt.Sprintf("expected %s, found %s", want, tok)}
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.accept("||")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "||", left: left, right: right, pos: tok.pos}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.accept("&&")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "&&", left: left, right: right, pos: tok.pos}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if tok, ok := p.accept("!"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand, pos: tok.pos}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	switch {
+	case tok.kind == tokOp && (tok.text == "==" || tok.text == "!=" || tok.text == "<" ||
+		tok.text == "<=" || tok.text == ">" || tok.text == ">="):
+		p.next()
+		right, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryNode{op: tok.text, left: left, right: right, pos: tok.pos}, nil
+	case tok.kind == tokIdent && tok.text == "in":
+		p.next()
+		list, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		return &inNode{value: left, list: list, pos: tok.pos}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("."); !ok {
+			return n, nil
+		}
+		name := p.next()
+		if name.kind != tokIdent {
+			return nil, p.unexpected(name, "a field or function name after '.'")
+		}
+		if _, ok := p.accept("("); ok {
+			args, err := p.parseList(")")
+			if err != nil {
+				return nil, err
+			}
+			n = &callNode{receiver: n, method: name.text, args: args, pos: name.pos}
+			continue
+		}
+		ident, ok := n.(*identNode)
+		if !ok {
+			return nil, &CompileError{Pos: name.pos, Msg: fmt.Sprintf("'%s' must be called, e.g. %s(...)", name.text, name.text)}
+		}
+		ident.name += "." + name.text
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokIdent:
+		if tok.text == "true" || tok.text == "false" {
+			return &literalNode{tok: tok}, nil
+		}
+		return &identNode{name: tok.text, pos: tok.pos}, nil
+	case tokString, tokNumber, tokDuration:
+		return &literalNode{tok: tok}, nil
+	case tokOp:
+		switch tok.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		case "[":
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{items: items, pos: tok.pos}, nil
+		}
+	}
+	return nil, p.unexpected(tok, "a field, literal or '('")
+}
+
+// parseList parses comma-separated expressions up to the closing delimiter
+func (p *parser) parseList(closing string) ([]node, error) {
+	var items []node
+	if _, ok := p.accept(closing); ok {
+		return items, nil
+	}
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if _, ok := p.accept(closing); ok {
+			return items, nil
+		}
+		if _, ok := p.accept(","); !ok {
+			return nil, p.unexpected(p.peek(), fmt.Sprintf("',' or '%s'", closing))
+		}
+	}
+}
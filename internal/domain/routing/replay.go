@@ -0,0 +1,249 @@
+package routing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/dlp"
+	"github.com/royisme/bobamixer/internal/domain/pricing"
+	"github.com/royisme/bobamixer/internal/store/config"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+// MaxStoredText caps the text sample kept for replay. Conditions on text past
+// it cannot be replayed exactly.
+const MaxStoredText = 1000
+
+// featureRetention is how long recorded routing features are kept
+const featureRetention = 30 * 24 * time.Hour
+
+// Sample is one past routing decision, with the features it was made on and
+// the tokens the request went on to use
+type Sample struct {
+	SessionID     string
+	Features      Features
+	ActiveProfile string // profile routing fell back to when nothing matched
+	Profile       string // profile routing chose, before exploration
+	Model         string // model that served the request, if usage was recorded
+	InputTokens   int
+	OutputTokens  int
+	Exact         bool // token counts came from the provider rather than estimates
+}
+
+// Move counts requests that a routes file sends to a different profile
+type Move struct {
+	From      string
+	To        string
+	Requests  int
+	CostDelta float64
+}
+
+// ReplayReport summarises how a routes file would have routed past requests
+type ReplayReport struct {
+	Requests    int
+	Moved       int
+	Moves       []Move // by requests, most first
+	CostBefore  float64
+	CostAfter   float64
+	Estimated   int // requests priced from estimated token counts
+	Unpriced    int // requests without recorded usage; they move but cost nothing
+	Unconverted int // requests priced in a currency without an exchange rate; they cost nothing
+}
+
+// CostDelta is the change in cost, negative when the new routes save money
+func (r *ReplayReport) CostDelta() float64 {
+	return r.CostAfter - r.CostBefore
+}
+
+// RecordFeatures keeps the features a routing decision was made on so it can be
+// replayed later. profile is the decision before exploration. Secrets and PII
+// in the text sample are masked before it is stored, and features older than
+// 30 days are dropped. Callers record only when routing.record_features is set.
+func RecordFeatures(db *sqlite.DB, sessionID string, f Features, activeProfile, profile string) error {
+	if f.Now.IsZero() {
+		f.Now = time.Now()
+	}
+	text := f.TextSample
+	if len(text) > MaxStoredText {
+		text = strings.ToValidUTF8(text[:MaxStoredText], "")
+	}
+	text = dlp.RedactText(text)
+	return db.Exec(fmt.Sprintf(`INSERT OR REPLACE INTO route_features
+		(session_id, ts, intent, text_sample, ctx_chars, branch, project, project_types, budget_hint, budget_used, active_profile, profile)
+		VALUES ('%s', %d, '%s', '%s', %d, '%s', '%s', '%s', '%s', %f, '%s', '%s');
+		DELETE FROM route_features WHERE ts < %d;`,
+		sqlEscape(sessionID), f.Now.Unix(), sqlEscape(f.Intent), sqlEscape(text), f.CtxChars,
+		sqlEscape(f.Branch), sqlEscape(f.Project), sqlEscape(strings.Join(f.ProjectTypes, ",")),
+		sqlEscape(f.BudgetHint), f.BudgetUsed, sqlEscape(activeProfile), sqlEscape(profile),
+		time.Now().Add(-featureRetention).Unix()))
+}
+
+// LoadSamples returns the routing decisions recorded since the given time, oldest first
+func LoadSamples(db *sqlite.DB, since time.Time) ([]Sample, error) {
+	// Text samples may hold the column separator and newlines, so they are hex
+	// encoded on the way out
+	rows, err := db.QueryRows(fmt.Sprintf(`SELECT f.session_id, f.ts, f.intent, hex(f.text_sample), f.ctx_chars,
+			f.branch, f.project, f.project_types, f.budget_hint, f.budget_used, f.active_profile, f.profile,
+			COALESCE(MAX(u.model), ''), COALESCE(SUM(u.input_tokens), 0), COALESCE(SUM(u.output_tokens), 0),
+			COALESCE(MIN(u.estimate_level = 'exact'), 0)
+		FROM route_features f
+		LEFT JOIN usage_records u ON u.session_id = f.session_id
+		WHERE f.ts >= %d
+		GROUP BY f.session_id
+		ORDER BY f.ts, f.session_id;`, since.Unix()))
+	if err != nil {
+		return nil, fmt.Errorf("query route features: %w", err)
+	}
+
+	samples := make([]Sample, 0, len(rows))
+	for _, row := range rows {
+		parts := strings.Split(row, "|")
+		if len(parts) < 16 {
+			continue
+		}
+		text, err := hex.DecodeString(parts[3])
+		if err != nil {
+			return nil, fmt.Errorf("decode text sample of %s: %w", parts[0], err)
+		}
+		var projectTypes []string
+		if parts[7] != "" {
+			projectTypes = strings.Split(parts[7], ",")
+		}
+		samples = append(samples, Sample{
+			SessionID: parts[0],
+			Features: Features{
+				Intent:       parts[2],
+				TextSample:   string(text),
+				CtxChars:     atoi(parts[4]),
+				Branch:       parts[5],
+				Project:      parts[6],
+				ProjectTypes: projectTypes,
+				BudgetHint:   parts[8],
+				BudgetUsed:   atof(parts[9]),
+				Now:          time.Unix(int64(atoi(parts[1])), 0),
+			},
+			ActiveProfile: parts[10],
+			Profile:       parts[11],
+			Model:         parts[12],
+			InputTokens:   atoi(parts[13]),
+			OutputTokens:  atoi(parts[14]),
+			Exact:         atoi(parts[15]) == 1,
+		})
+	}
+	return samples, nil
+}
+
+// Replay routes past requests through routes without exploration and compares
+// where they go and what they cost with the profiles they were routed to. Both
+// sides are priced with the current table at each request's token counts. A
+// request costs at the model that served it, and a moved request at its new
+// profile's configured model; requests without a recorded model, or moved to
+// an unconfigured profile, fall back to the other.
+func Replay(routes *config.RoutesConfig, samples []Sample, profiles config.Profiles, table *pricing.Table) (*ReplayReport, error) {
+	if routes == nil {
+		routes = &config.RoutesConfig{}
+	}
+	if _, err := CompileRoutes(routes); err != nil {
+		return nil, err
+	}
+	router := NewRouter(routes)
+	router.SetEnableExplore(false)
+	if table == nil {
+		table = &pricing.Table{}
+	}
+
+	report := &ReplayReport{Requests: len(samples)}
+	moves := make(map[[2]string]*Move)
+	for _, sample := range samples {
+		to := router.Route(sample.Features.Context(), sample.ActiveProfile).ProfileKey
+
+		before, err := servedCost(sample, profiles, table)
+		after := before
+		if err == nil && to != sample.Profile {
+			after, err = sampleCost(sample, to, profiles, table)
+		}
+		if err != nil {
+			// Neither side is counted, rather than one in the wrong currency
+			before, after = 0, 0
+			report.Unconverted++
+		}
+		if to != sample.Profile {
+			report.Moved++
+			move := moves[[2]string{sample.Profile, to}]
+			if move == nil {
+				move = &Move{From: sample.Profile, To: to}
+				moves[[2]string{sample.Profile, to}] = move
+			}
+			move.Requests++
+			move.CostDelta += after - before
+		}
+		report.CostBefore += before
+		report.CostAfter += after
+
+		switch {
+		case sample.InputTokens+sample.OutputTokens == 0:
+			report.Unpriced++
+		case !sample.Exact:
+			report.Estimated++
+		}
+	}
+
+	for _, move := range moves {
+		report.Moves = append(report.Moves, *move)
+	}
+	sort.Slice(report.Moves, func(i, j int) bool {
+		a, b := report.Moves[i], report.Moves[j]
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	return report, nil
+}
+
+// servedCost prices a sample's tokens at the model that served it. The routed
+// profile's prices are the fallback when that is the profile's model too.
+func servedCost(sample Sample, profiles config.Profiles, table *pricing.Table) (float64, error) {
+	if sample.Model == "" {
+		return sampleCost(sample, sample.Profile, profiles, table)
+	}
+	cost := config.Cost{}
+	if p, ok := profiles[sample.Profile]; ok && p.Model == sample.Model {
+		cost = p.CostPer1K
+	}
+	in, out, err := table.CalculateCost(sample.Model, cost, sample.InputTokens, sample.OutputTokens)
+	return in + out, err
+}
+
+// sampleCost prices a sample's tokens as if profile had served it
+func sampleCost(sample Sample, profile string, profiles config.Profiles, table *pricing.Table) (float64, error) {
+	model, cost := sample.Model, config.Cost{}
+	if p, ok := profiles[profile]; ok && p.Model != "" {
+		model, cost = p.Model, p.CostPer1K
+	}
+	in, out, err := table.CalculateCost(model, cost, sample.InputTokens, sample.OutputTokens)
+	return in + out, err
+}
+
+func atoi(s string) int {
+	return int(atof(s))
+}
+
+func atof(s string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func sqlEscape(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
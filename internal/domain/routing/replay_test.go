@@ -0,0 +1,146 @@
+package routing
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/pricing"
+	"github.com/royisme/bobamixer/internal/store/config"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+func TestReplayMovesRecordedRequests(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	now := time.Now()
+	record := func(id, text string, ctxChars int, profile, model string, in, out int) {
+		t.Helper()
+		f := Features{TextSample: text, CtxChars: ctxChars, Branch: "main", Now: now.Add(-time.Hour)}
+		if err := RecordFeatures(db, id, f, "quick", profile); err != nil {
+			t.Fatalf("RecordFeatures: %v", err)
+		}
+		if model == "" {
+			return
+		}
+		if err := db.Exec(fmt.Sprintf(`INSERT INTO usage_records (id, session_id, ts, input_tokens, output_tokens, model, estimate_level)
+			VALUES ('u-%[1]s', '%[1]s', %[2]d, %[3]d, %[4]d, '%[5]s', 'exact');`, id, now.Unix(), in, out, model)); err != nil {
+			t.Fatalf("insert usage: %v", err)
+		}
+	}
+	record("s1", "please review | it's\nmultiline", 100, "heavy", "big", 1000, 1000)
+	record("s2", "small fix", 100, "quick", "small", 1000, 1000)
+	record("s3", "huge context", 9000, "heavy", "big", 2000, 0)
+	record("s4", "no usage yet", 100, "quick", "", 0, 0)
+	if err := RecordFeatures(db, "old", Features{TextSample: "review", Now: now.AddDate(0, 0, -40)}, "quick", "heavy"); err != nil {
+		t.Fatalf("RecordFeatures: %v", err)
+	}
+
+	samples, err := LoadSamples(db, now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("LoadSamples: %v", err)
+	}
+	if len(samples) != 4 {
+		t.Fatalf("LoadSamples returned %d samples, want 4 inside the window", len(samples))
+	}
+	if got := samples[0].Features.TextSample; got != "please review | it's\nmultiline" {
+		t.Errorf("text sample = %q, want it back unchanged", got)
+	}
+	if samples[0].Model != "big" || samples[0].InputTokens != 1000 || !samples[0].Exact {
+		t.Errorf("sample usage = %+v, want model big, 1000 exact input tokens", samples[0])
+	}
+
+	// The new routes drop the review rule, so only large contexts stay heavy
+	routes := &config.RoutesConfig{Rules: []config.RouteRule{
+		{ID: "large", If: "ctx_chars > 5000", Use: "heavy"},
+	}}
+	profiles := config.Profiles{
+		"quick": {Model: "small"},
+		"heavy": {Model: "big"},
+	}
+	table := &pricing.Table{Models: map[string]pricing.ModelPrice{
+		"small": {InputPer1K: 0.001, OutputPer1K: 0.002},
+		"big":   {InputPer1K: 0.01, OutputPer1K: 0.03},
+	}}
+
+	report, err := Replay(routes, samples, profiles, table)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if report.Requests != 4 || report.Moved != 1 || report.Unpriced != 1 {
+		t.Errorf("report = %+v, want 4 requests, 1 moved, 1 unpriced", report)
+	}
+	if len(report.Moves) != 1 || report.Moves[0].From != "heavy" || report.Moves[0].To != "quick" {
+		t.Fatalf("moves = %+v, want one heavy -> quick", report.Moves)
+	}
+	// s1 costs 0.04 on big and 0.003 on small
+	if want := 0.003 - 0.04; math.Abs(report.Moves[0].CostDelta-want) > 1e-9 {
+		t.Errorf("move cost delta = %.4f, want %.4f", report.Moves[0].CostDelta, want)
+	}
+	if want := 0.04 + 0.003 + 0.02; math.Abs(report.CostBefore-want) > 1e-9 {
+		t.Errorf("cost before = %.4f, want %.4f", report.CostBefore, want)
+	}
+	if math.Abs(report.CostDelta()-(0.003-0.04)) > 1e-9 {
+		t.Errorf("cost delta = %.4f, want %.4f", report.CostDelta(), 0.003-0.04)
+	}
+
+	if _, err := Replay(&config.RoutesConfig{Rules: []config.RouteRule{{ID: "bad", If: "ctx_chars >", Use: "heavy"}}},
+		samples, profiles, table); err == nil {
+		t.Error("Replay accepted routes with an invalid condition")
+	}
+}
+
+func TestReplayPricesTheServedModel(t *testing.T) {
+	// Routed to heavy, but the request was served by small
+	samples := []Sample{{Profile: "heavy", Model: "small", InputTokens: 1000, OutputTokens: 1000, Exact: true}}
+	profiles := config.Profiles{
+		"quick": {Model: "small"},
+		"heavy": {Model: "big"},
+	}
+	table := &pricing.Table{Models: map[string]pricing.ModelPrice{
+		"small": {InputPer1K: 0.001, OutputPer1K: 0.002},
+		"big":   {InputPer1K: 0.01, OutputPer1K: 0.03},
+	}}
+	routes := &config.RoutesConfig{Rules: []config.RouteRule{{ID: "all", If: "ctx_chars >= 0", Use: "quick"}}}
+
+	report, err := Replay(routes, samples, profiles, table)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if math.Abs(report.CostBefore-0.003) > 1e-9 || math.Abs(report.CostDelta()) > 1e-9 {
+		t.Errorf("cost before = %.4f, delta %.4f; want 0.003 at the served model and no change", report.CostBefore, report.CostDelta())
+	}
+}
+
+func TestRecordFeaturesRedactsAndPrunes(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	now := time.Now()
+	if err := RecordFeatures(db, "stale", Features{TextSample: "old", Now: now.AddDate(0, 0, -31)}, "quick", "quick"); err != nil {
+		t.Fatalf("RecordFeatures: %v", err)
+	}
+	secret := "use sk-ant-REDACTED and mail jane@example.com"
+	if err := RecordFeatures(db, "s1", Features{TextSample: secret, Now: now}, "quick", "quick"); err != nil {
+		t.Fatalf("RecordFeatures: %v", err)
+	}
+
+	samples, err := LoadSamples(db, now.AddDate(-1, 0, 0))
+	if err != nil {
+		t.Fatalf("LoadSamples: %v", err)
+	}
+	if len(samples) != 1 || samples[0].SessionID != "s1" {
+		t.Fatalf("samples = %+v, want only s1 once older features are pruned", samples)
+	}
+	text := samples[0].Features.TextSample
+	if strings.Contains(text, "sk-ant") || strings.Contains(text, "jane@") {
+		t.Errorf("stored text = %q, want the key and the address masked", text)
+	}
+}
@@ -2,9 +2,9 @@
 package routing
 
 import (
+	"fmt"
 	"math/rand"
-	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
 
@@ -20,20 +20,41 @@ type Context struct {
 	Branch      string
 	TimeOfDay   string
 	CtxChars    int
+	Budget      string    // Budget hint: normal, near_cap or over_cap; empty means normal
+	BudgetUsed  float64   // Percentage of the tightest budget limit spent
+	Now         time.Time // Evaluation time for clock conditions; zero means time.Now()
 }
 
 // Decision represents a routing decision
 type Decision struct {
 	ProfileKey string
 	RuleID     string
+	SubAgent   string // Name of the sub-agent that took the request, if any
 	Explain    string
 	Fallback   string
 	Explore    bool
+	Key        string // Context the outcome is learned under; see ContextKey
 }
 
-// Router handles profile routing based on rules
+// Policy picks the profile that serves a routing context, given the profile the
+// sub-agents and rules chose and every profile routing knows of. Implementations
+// learn from past outcomes per context; see the bandit package.
+type Policy interface {
+	Choose(key, chosen string, candidates []string) string
+}
+
+// Router handles profile routing based on sub-agents and rules.
+//
+// Precedence: sub-agents are checked first, in name order, and the first one
+// whose trigger appears in the text or intent and whose conditions all hold
+// wins. Otherwise the first matching rule wins, then the active profile.
+// Exploration may then swap the chosen profile for another one: the policy
+// decides when one is set, otherwise a fixed epsilon rate does.
 type Router struct {
 	routes        *config.RoutesConfig
+	conditions    []*Condition // compiled rule conditions, nil where invalid
+	subAgents     []*subAgent  // compiled sub-agents in check order, invalid ones left out
+	policy        Policy
 	rng           *rand.Rand
 	epsilonRate   float64
 	enableExplore bool
@@ -50,8 +71,20 @@ func NewRouter(routes *config.RoutesConfig) *Router {
 		enableExplore = routes.Explore.Enabled
 	}
 
+	var conditions []*Condition
+	var subAgents []*subAgent
+	if routes != nil {
+		conditions = make([]*Condition, len(routes.Rules))
+		for i, rule := range routes.Rules {
+			conditions[i], _ = CompileCondition(rule.If) //nolint:errcheck // invalid rules never match
+		}
+		subAgents, _ = compileSubAgents(routes.SubAgents) // invalid sub-agents never trigger
+	}
+
 	return &Router{
 		routes:        routes,
+		conditions:    conditions,
+		subAgents:     subAgents,
 		epsilonRate:   epsilonRate,
 		enableExplore: enableExplore,
 		// #nosec G404 -- weak RNG acceptable for epsilon-greedy exploration
@@ -69,33 +102,55 @@ func (r *Router) SetEnableExplore(enable bool) {
 	r.enableExplore = enable
 }
 
+// SetPolicy replaces epsilon-greedy exploration with a learning policy; nil restores it
+func (r *Router) SetPolicy(policy Policy) {
+	r.policy = policy
+}
+
+// ContextKey names the context a decision's outcome is learned under: the
+// sub-agent or rule that decided, else the intent, else "default"
+func ContextKey(decision *Decision, ctx Context) string {
+	switch {
+	case decision.SubAgent != "":
+		return "subagent:" + decision.SubAgent
+	case decision.RuleID != "":
+		return "rule:" + decision.RuleID
+	case ctx.Intent != "":
+		return "intent:" + strings.ToLower(ctx.Intent)
+	default:
+		return "default"
+	}
+}
+
 // Route determines which profile to use based on context
 func (r *Router) Route(ctx Context, activeProfile string) *Decision {
 	// If no routes configured, use active profile
-	if r.routes == nil || len(r.routes.Rules) == 0 {
+	if r.routes == nil || len(r.routes.Rules)+len(r.subAgents) == 0 {
 		return &Decision{
 			ProfileKey: activeProfile,
 			Explain:    "No routing rules configured",
 		}
 	}
 
-	// First, determine the normal routing decision
-	var normalDecision *Decision
+	// Sub-agents take precedence over rules
+	normalDecision := r.matchSubAgent(ctx)
 
-	// Try each rule in order
-	for _, rule := range r.routes.Rules {
-		if r.matchRule(rule, ctx) {
-			normalDecision = &Decision{
-				ProfileKey: rule.Use,
-				RuleID:     rule.ID,
-				Explain:    rule.Explain,
-				Fallback:   rule.Fallback,
+	// Then try each rule in order
+	if normalDecision == nil {
+		for i, rule := range r.routes.Rules {
+			if r.matchRule(i, ctx) {
+				normalDecision = &Decision{
+					ProfileKey: rule.Use,
+					RuleID:     rule.ID,
+					Explain:    rule.Explain,
+					Fallback:   rule.Fallback,
+				}
+				break
 			}
-			break
 		}
 	}
 
-	// If no rule matched, use active profile
+	// If nothing matched, use active profile
 	if normalDecision == nil {
 		normalDecision = &Decision{
 			ProfileKey: activeProfile,
@@ -103,38 +158,66 @@ func (r *Router) Route(ctx Context, activeProfile string) *Decision {
 		}
 	}
 
-	// Apply epsilon-greedy exploration
-	if r.enableExplore && r.rng.Float64() < r.epsilonRate {
-		// Explore: randomly select a different profile
-		allProfiles := r.collectAllProfiles()
-		if len(allProfiles) > 1 {
-			// Remove the normal choice to ensure exploration is different
-			var explorationOptions []string
-			for _, p := range allProfiles {
-				if p != normalDecision.ProfileKey {
-					explorationOptions = append(explorationOptions, p)
-				}
-			}
+	normalDecision.Key = ContextKey(normalDecision, ctx)
+	if !r.enableExplore {
+		return normalDecision
+	}
 
-			if len(explorationOptions) > 0 {
-				exploredProfile := explorationOptions[r.rng.Intn(len(explorationOptions))]
-				return &Decision{
-					ProfileKey: exploredProfile,
-					RuleID:     normalDecision.RuleID,
-					Explain:    "Exploration: randomly selected for learning",
-					Fallback:   normalDecision.ProfileKey, // Can fallback to normal choice
-					Explore:    true,
-				}
+	// Let the policy pick among all profiles, or explore at the fixed rate
+	if r.policy != nil {
+		chosen := r.policy.Choose(normalDecision.Key, normalDecision.ProfileKey, r.candidates(normalDecision.ProfileKey))
+		if chosen == "" || chosen == normalDecision.ProfileKey {
+			return normalDecision
+		}
+		return r.exploreDecision(normalDecision, chosen,
+			fmt.Sprintf("Exploration: %s sampled above %s for %s", chosen, normalDecision.ProfileKey, normalDecision.Key))
+	}
+	if r.rng.Float64() < r.epsilonRate {
+		// Explore: randomly select a different profile
+		var explorationOptions []string
+		for _, p := range r.candidates(normalDecision.ProfileKey) {
+			if p != normalDecision.ProfileKey {
+				explorationOptions = append(explorationOptions, p)
 			}
 		}
+		if len(explorationOptions) > 0 {
+			exploredProfile := explorationOptions[r.rng.Intn(len(explorationOptions))]
+			return r.exploreDecision(normalDecision, exploredProfile, "Exploration: randomly selected for learning")
+		}
 	}
 
 	return normalDecision
 }
 
-// collectAllProfiles collects all profile names mentioned in rules
+// exploreDecision swaps the profile of a decision, keeping the original as fallback
+func (r *Router) exploreDecision(normal *Decision, profile, explain string) *Decision {
+	return &Decision{
+		ProfileKey: profile,
+		RuleID:     normal.RuleID,
+		SubAgent:   normal.SubAgent,
+		Explain:    explain,
+		Fallback:   normal.ProfileKey, // Can fallback to normal choice
+		Explore:    true,
+		Key:        normal.Key,
+	}
+}
+
+// candidates lists the profiles exploration may pick from, in name order
+func (r *Router) candidates(chosen string) []string {
+	profiles := r.collectAllProfiles()
+	if chosen != "" && !containsString(profiles, chosen) {
+		profiles = append(profiles, chosen)
+	}
+	sort.Strings(profiles)
+	return profiles
+}
+
+// collectAllProfiles collects all profile names mentioned in sub-agents and rules
 func (r *Router) collectAllProfiles() []string {
 	profileSet := make(map[string]bool)
+	for _, agent := range r.subAgents {
+		profileSet[agent.profile] = true
+	}
 	for _, rule := range r.routes.Rules {
 		if rule.Use != "" {
 			profileSet[rule.Use] = true
@@ -151,341 +234,32 @@ func (r *Router) collectAllProfiles() []string {
 	return profiles
 }
 
-// matchRule checks if a rule matches the context
-//
-//nolint:gocyclo // Complex rule matching with multiple conditions and operators
-func (r *Router) matchRule(rule config.RouteRule, ctx Context) bool {
-	if rule.If == "" {
-		return false
-	}
-
-	return r.evaluateBooleanExpression(strings.TrimSpace(rule.If), ctx)
+// matchRule checks if the i-th rule matches the context. Rules whose condition
+// does not compile never match; Compile and `boba route lint` report them.
+func (r *Router) matchRule(i int, ctx Context) bool {
+	return i < len(r.conditions) && r.conditions[i] != nil && r.conditions[i].Eval(ctx)
 }
 
-// evaluateBooleanExpression evaluates complex boolean expressions supporting &&, ||, and parentheses
-func (r *Router) evaluateBooleanExpression(expr string, ctx Context) bool {
-	expr = strings.TrimSpace(expr)
-	if expr == "" {
-		return false
-	}
-
-	expr = trimOuterParentheses(expr)
-
-	// Evaluate OR at the top level first (lowest precedence)
-	if idx := findTopLevelOperator(expr, "||"); idx >= 0 {
-		left := expr[:idx]
-		right := expr[idx+2:]
-		return r.evaluateBooleanExpression(left, ctx) || r.evaluateBooleanExpression(right, ctx)
-	}
-
-	// Then evaluate AND
-	if idx := findTopLevelOperator(expr, "&&"); idx >= 0 {
-		left := expr[:idx]
-		right := expr[idx+2:]
-		return r.evaluateBooleanExpression(left, ctx) && r.evaluateBooleanExpression(right, ctx)
-	}
-
-	return r.evaluateSingleCondition(expr, ctx)
-}
-
-func findTopLevelOperator(expr string, op string) int {
-	depth := 0
-	opLen := len(op)
-	for i := 0; i <= len(expr)-opLen; i++ {
-		switch expr[i] {
-		case '(':
-			depth++
-		case ')':
-			depth--
-		}
-		if depth == 0 && strings.HasPrefix(expr[i:], op) {
-			return i
-		}
-	}
-	return -1
-}
-
-func trimOuterParentheses(expr string) string {
-	for {
-		expr = strings.TrimSpace(expr)
-		if len(expr) < 2 || expr[0] != '(' || expr[len(expr)-1] != ')' {
-			return expr
-		}
-		match := matchingParenIndex(expr, 0)
-		if match != len(expr)-1 {
-			return expr
-		}
-		expr = expr[1 : len(expr)-1]
-	}
-}
-
-func matchingParenIndex(expr string, start int) int {
-	depth := 0
-	for i := start; i < len(expr); i++ {
-		switch expr[i] {
-		case '(':
-			depth++
-		case ')':
-			depth--
-			if depth == 0 {
-				return i
-			}
-		}
-	}
-	return -1
-}
-
-// evaluateSingleCondition evaluates a single condition expression
-func (r *Router) evaluateSingleCondition(expr string, ctx Context) bool {
-	for _, handler := range conditionHandlers {
-		if handler(expr, ctx) {
-			return true
+// matchSubAgent returns the decision of the first sub-agent that takes the request, or nil
+func (r *Router) matchSubAgent(ctx Context) *Decision {
+	for _, agent := range r.subAgents {
+		trigger := agent.trigger(ctx)
+		if trigger == "" || !agent.conditionsMet(ctx) {
+			continue
 		}
-	}
-
-	return false
-}
-
-var conditionHandlers = []func(string, Context) bool{
-	intentEqualsCondition,
-	textMatchesCondition,
-	textContainsCondition,
-	ctxCharsCondition,
-	taskMatchesCondition,
-	branchMatchesCondition,
-	branchEqualsCondition,
-	branchEqualityShortcutCondition,
-	timeOfDayCondition,
-	projectTypeCondition,
-}
-
-var (
-	intentEqualsPattern           = regexp.MustCompile(`intent=='([^']+)'`)
-	textMatchesPattern            = regexp.MustCompile(`text\.matches\('([^']+)'\)`)
-	textContainsPattern           = regexp.MustCompile(`text\.contains\('([^']+)'\)`)
-	ctxCharsPattern               = regexp.MustCompile(`ctx_chars([<>])(\d+)`)
-	taskMatchesPattern            = regexp.MustCompile(`task\.matches\('([^']+)'\)`)
-	branchMatchesPattern          = regexp.MustCompile(`branch\.matches\('([^']+)'\)`)
-	branchEqualsPattern           = regexp.MustCompile(`branch\.equals\('([^']+)'\)`)
-	branchEqualityShortcutPattern = regexp.MustCompile(`branch=='([^']+)'`)
-	timeOfDayPattern              = regexp.MustCompile(`time_of_day\.in\('([^']+)'\)`)
-	projectTypePattern            = regexp.MustCompile(`project_types\.contains\('([^']+)'\)`)
-)
-
-func intentEqualsCondition(expr string, ctx Context) bool {
-	if !strings.Contains(expr, "intent==") {
-		return false
-	}
-	matches := intentEqualsPattern.FindStringSubmatch(expr)
-	return len(matches) > 1 && ctx.Intent == matches[1]
-}
-
-func textMatchesCondition(expr string, ctx Context) bool {
-	if !strings.Contains(expr, "text.matches") {
-		return false
-	}
-	matches := textMatchesPattern.FindStringSubmatch(expr)
-	if len(matches) <= 1 {
-		return false
-	}
-	pattern, err := regexp.Compile(matches[1])
-	if err != nil {
-		return false
-	}
-	return pattern.MatchString(ctx.Text)
-}
-
-func textContainsCondition(expr string, ctx Context) bool {
-	if !strings.Contains(expr, "text.contains") {
-		return false
-	}
-	matches := textContainsPattern.FindStringSubmatch(expr)
-	return len(matches) > 1 && strings.Contains(ctx.Text, matches[1])
-}
-
-func ctxCharsCondition(expr string, ctx Context) bool {
-	if !strings.Contains(expr, "ctx_chars") {
-		return false
-	}
-	matches := ctxCharsPattern.FindStringSubmatch(expr)
-	if len(matches) < 3 {
-		return false
-	}
-	operator := matches[1]
-	threshold, err := strconv.Atoi(matches[2])
-	if err != nil {
-		return false
-	}
-
-	switch operator {
-	case ">":
-		return ctx.CtxChars > threshold
-	case "<":
-		return ctx.CtxChars < threshold
-	default:
-		return false
-	}
-}
-
-func taskMatchesCondition(expr string, ctx Context) bool {
-	if !strings.Contains(expr, "task.matches") {
-		return false
-	}
-	matches := taskMatchesPattern.FindStringSubmatch(expr)
-	if len(matches) <= 1 {
-		return false
-	}
-	pattern, err := regexp.Compile(matches[1])
-	if err != nil {
-		return false
-	}
-	return pattern.MatchString(ctx.Intent)
-}
-
-func branchMatchesCondition(expr string, ctx Context) bool {
-	if !strings.Contains(expr, "branch.matches") {
-		return false
-	}
-	matches := branchMatchesPattern.FindStringSubmatch(expr)
-	if len(matches) <= 1 {
-		return false
-	}
-	pattern, err := regexp.Compile(matches[1])
-	if err != nil {
-		return false
-	}
-	return pattern.MatchString(ctx.Branch)
-}
-
-func branchEqualsCondition(expr string, ctx Context) bool {
-	if !strings.Contains(expr, "branch.equals") {
-		return false
-	}
-	matches := branchEqualsPattern.FindStringSubmatch(expr)
-	return len(matches) > 1 && ctx.Branch == matches[1]
-}
-
-func branchEqualityShortcutCondition(expr string, ctx Context) bool {
-	if !strings.Contains(expr, "branch==") {
-		return false
-	}
-	matches := branchEqualityShortcutPattern.FindStringSubmatch(expr)
-	return len(matches) > 1 && ctx.Branch == matches[1]
-}
-
-func timeOfDayCondition(expr string, ctx Context) bool {
-	if !strings.Contains(expr, "time_of_day.in") {
-		return false
-	}
-	matches := timeOfDayPattern.FindStringSubmatch(expr)
-	return len(matches) > 1 && checkTimeRangeString(matches[1])
-}
-
-func projectTypeCondition(expr string, ctx Context) bool {
-	if !strings.Contains(expr, "project_types.contains") {
-		return false
-	}
-	matches := projectTypePattern.FindStringSubmatch(expr)
-	if len(matches) <= 1 {
-		return false
-	}
-	targetType := matches[1]
-	for _, pt := range ctx.ProjectType {
-		if pt == targetType {
-			return true
+		return &Decision{
+			ProfileKey: agent.profile,
+			SubAgent:   agent.name,
+			Explain:    fmt.Sprintf("Sub-agent %s triggered by '%s'", agent.name, trigger),
 		}
 	}
-	return false
+	return nil
 }
 
-// CheckSubAgent checks if a sub-agent should be triggered
+// CheckSubAgent checks if a sub-agent should be triggered, returning its profile
 func (r *Router) CheckSubAgent(ctx Context) (string, bool) {
-	if r.routes == nil || len(r.routes.SubAgents) == 0 {
-		return "", false
+	if decision := r.matchSubAgent(ctx); decision != nil {
+		return decision.ProfileKey, true
 	}
-
-	for _, agent := range r.routes.SubAgents {
-		// Check triggers
-		triggered := false
-		for _, trigger := range agent.Triggers {
-			if strings.Contains(strings.ToLower(ctx.Text), trigger) ||
-				strings.Contains(strings.ToLower(ctx.Intent), trigger) {
-				triggered = true
-				break
-			}
-		}
-
-		if !triggered {
-			continue
-		}
-
-		// Check conditions
-		if agent.Conditions != nil {
-			if minChars, ok := agent.Conditions["min_ctx_chars"].(int); ok {
-				if ctx.CtxChars < minChars {
-					continue
-				}
-			}
-
-			if maxChars, ok := agent.Conditions["max_ctx_chars"].(int); ok {
-				if ctx.CtxChars > maxChars {
-					continue
-				}
-			}
-
-			if timeRanges, ok := agent.Conditions["time_of_day"].([]interface{}); ok {
-				if !checkTimeRange(timeRanges) {
-					continue
-				}
-			}
-		}
-
-		// All conditions met
-		return agent.Profile, true
-	}
-
 	return "", false
 }
-
-// checkTimeRange checks if current time is within specified ranges
-func checkTimeRange(ranges []interface{}) bool {
-	now := time.Now()
-	currentTime := now.Format("15:04")
-
-	for _, r := range ranges {
-		rangeStr, ok := r.(string)
-		if !ok {
-			continue
-		}
-
-		parts := strings.Split(rangeStr, "-")
-		if len(parts) != 2 {
-			continue
-		}
-
-		start := strings.TrimSpace(parts[0])
-		end := strings.TrimSpace(parts[1])
-
-		if currentTime >= start && currentTime <= end {
-			return true
-		}
-	}
-
-	return false
-}
-
-// checkTimeRangeString checks if current time is within a single time range string
-func checkTimeRangeString(rangeStr string) bool {
-	now := time.Now()
-	currentTime := now.Format("15:04")
-
-	parts := strings.Split(rangeStr, "-")
-	if len(parts) != 2 {
-		return false
-	}
-
-	start := strings.TrimSpace(parts[0])
-	end := strings.TrimSpace(parts[1])
-
-	return currentTime >= start && currentTime <= end
-}
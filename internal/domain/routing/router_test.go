@@ -1,7 +1,9 @@
 package routing
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/royisme/bobamixer/internal/store/config"
 )
@@ -179,3 +181,134 @@ func TestCheckSubAgent(t *testing.T) {
 		})
 	}
 }
+
+func TestSubAgentConditions(t *testing.T) {
+	routes := &config.RoutesConfig{
+		SubAgents: map[string]config.SubAgent{
+			"code_review": {
+				Profile:  "work-heavy",
+				Triggers: []string{"Review"},
+				Conditions: map[string]interface{}{
+					"project_types": []interface{}{"java", "go"},
+					"branch":        []interface{}{"main", "release/*"},
+				},
+			},
+			"night_fix": {
+				Profile:  "quick-tasks",
+				Triggers: []string{"fix"},
+				Conditions: map[string]interface{}{
+					"time_of_day": []interface{}{"22:00-06:00"},
+				},
+			},
+		},
+	}
+	router := NewRouter(routes)
+	night := time.Date(2025, 1, 15, 23, 30, 0, 0, time.Local)
+	noon := time.Date(2025, 1, 15, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name string
+		ctx  Context
+		want string
+	}{
+		{"project type and branch glob", Context{Text: "review this", ProjectType: []string{"Go"}, Branch: "release/1.2"}, "work-heavy"},
+		{"wrong project type", Context{Text: "review this", ProjectType: []string{"python"}, Branch: "main"}, ""},
+		{"wrong branch", Context{Text: "review this", ProjectType: []string{"go"}, Branch: "feature/x"}, ""},
+		{"time range wraps midnight", Context{Text: "fix it", Now: night}, "quick-tasks"},
+		{"outside time range", Context{Text: "fix it", Now: noon}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, _ := router.CheckSubAgent(tt.ctx)
+			if profile != tt.want {
+				t.Errorf("profile: got %q, want %q", profile, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteSubAgentsTakePrecedence(t *testing.T) {
+	routes := &config.RoutesConfig{
+		SubAgents: map[string]config.SubAgent{
+			"b_review": {Profile: "reviewer", Triggers: []string{"review"}},
+			"a_audit":  {Profile: "auditor", Triggers: []string{"audit"}},
+		},
+		Rules: []config.RouteRule{
+			{ID: "all", If: "true", Use: "general"},
+		},
+	}
+	router := NewRouter(routes)
+
+	decision := router.Route(Context{Text: "review and audit this"}, "default")
+	if decision.ProfileKey != "auditor" || decision.SubAgent != "a_audit" || decision.RuleID != "" {
+		t.Errorf("decision = %+v, want sub-agent a_audit (checked in name order) over rule all", decision)
+	}
+
+	decision = router.Route(Context{Text: "summarize"}, "default")
+	if decision.ProfileKey != "general" || decision.SubAgent != "" || decision.RuleID != "all" {
+		t.Errorf("decision = %+v, want rule all when no sub-agent triggers", decision)
+	}
+}
+
+func TestCompileRoutesRejectsInvalidSubAgent(t *testing.T) {
+	_, err := CompileRoutes(&config.RoutesConfig{
+		SubAgents: map[string]config.SubAgent{
+			"quick_fix": {Profile: "fast", Triggers: []string{"fix"}, Conditions: map[string]interface{}{"time_of_day": []interface{}{"9-18"}}},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "sub-agent quick_fix: condition time_of_day") {
+		t.Errorf("CompileRoutes error = %v, want invalid time_of_day in quick_fix", err)
+	}
+}
+
+// fixedPolicy always picks one profile and records what it was offered
+type fixedPolicy struct {
+	pick       string
+	key        string
+	candidates []string
+}
+
+func (p *fixedPolicy) Choose(key, chosen string, candidates []string) string {
+	p.key, p.candidates = key, candidates
+	return p.pick
+}
+
+func TestRoutePolicyReplacesEpsilon(t *testing.T) {
+	routes := &config.RoutesConfig{
+		Rules: []config.RouteRule{
+			{ID: "review", If: "intent=='review'", Use: "work-heavy", Fallback: "balanced"},
+		},
+		Explore: config.ExploreConfig{Enabled: true, Rate: 0},
+	}
+	router := NewRouter(routes)
+	policy := &fixedPolicy{pick: "balanced"}
+	router.SetPolicy(policy)
+
+	decision := router.Route(Context{Intent: "review"}, "quick-tasks")
+	if decision.ProfileKey != "balanced" || !decision.Explore || decision.Fallback != "work-heavy" {
+		t.Errorf("decision = %+v, want explored balanced falling back to work-heavy", decision)
+	}
+	if decision.Key != "rule:review" || policy.key != "rule:review" {
+		t.Errorf("key = %q (policy saw %q), want rule:review", decision.Key, policy.key)
+	}
+	if strings.Join(policy.candidates, ",") != "balanced,work-heavy" {
+		t.Errorf("candidates = %v", policy.candidates)
+	}
+
+	// Unmatched requests learn under their intent and offer the active profile too
+	policy.pick = ""
+	decision = router.Route(Context{Intent: "Format"}, "quick-tasks")
+	if decision.ProfileKey != "quick-tasks" || decision.Explore || decision.Key != "intent:format" {
+		t.Errorf("decision = %+v, want quick-tasks under intent:format", decision)
+	}
+	if strings.Join(policy.candidates, ",") != "balanced,quick-tasks,work-heavy" {
+		t.Errorf("candidates = %v", policy.candidates)
+	}
+
+	// Disabling exploration bypasses the policy
+	policy.pick = "balanced"
+	router.SetEnableExplore(false)
+	if decision := router.Route(Context{Intent: "review"}, "quick-tasks"); decision.ProfileKey != "work-heavy" {
+		t.Errorf("profile = %s with exploration disabled, want work-heavy", decision.ProfileKey)
+	}
+}
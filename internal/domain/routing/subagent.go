@@ -0,0 +1,252 @@
+package routing
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/store/config"
+)
+
+// subAgentConditionKeys are the conditions a sub-agent may set
+var subAgentConditionKeys = []string{"branch", "max_ctx_chars", "min_ctx_chars", "project", "project_types", "time_of_day"}
+
+// subAgent is a compiled sub-agent: triggers plus typed conditions
+type subAgent struct {
+	name         string
+	profile      string
+	triggers     []string // lowercased
+	minChars     int      // 0 means no minimum
+	maxChars     int      // 0 means no maximum
+	timeSpans    []timeSpan
+	timeLabels   []string // day, evening or night
+	projects     []string
+	projectTypes []string // lowercased
+	branches     []string // glob patterns
+}
+
+// compileSubAgent validates a sub-agent and compiles its conditions
+//
+//nolint:gocyclo // One case per condition key
+func compileSubAgent(name string, sa config.SubAgent) (*subAgent, error) {
+	if sa.Profile == "" {
+		return nil, fmt.Errorf("sub-agent profile is required")
+	}
+	agent := &subAgent{name: name, profile: sa.Profile}
+	for _, trigger := range sa.Triggers {
+		if trigger = strings.ToLower(strings.TrimSpace(trigger)); trigger != "" {
+			agent.triggers = append(agent.triggers, trigger)
+		}
+	}
+	if len(agent.triggers) == 0 {
+		return nil, fmt.Errorf("sub-agent needs at least one trigger")
+	}
+
+	keys := make([]string, 0, len(sa.Conditions))
+	for key := range sa.Conditions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := sa.Conditions[key]
+		switch key {
+		case "min_ctx_chars", "max_ctx_chars":
+			n, ok := conditionInt(value)
+			if !ok || n < 0 {
+				return nil, fmt.Errorf("condition %s must be a non-negative number", key)
+			}
+			if key == "min_ctx_chars" {
+				agent.minChars = n
+			} else {
+				agent.maxChars = n
+			}
+		case "time_of_day":
+			values, ok := conditionStrings(value)
+			if !ok {
+				return nil, fmt.Errorf("condition time_of_day must be a list of 'HH:MM-HH:MM' ranges or day/evening/night")
+			}
+			for _, v := range values {
+				switch v {
+				case "day", "evening", "night":
+					agent.timeLabels = append(agent.timeLabels, v)
+					continue
+				}
+				start, end, err := parseTimeRange(v)
+				if err != nil {
+					return nil, fmt.Errorf("condition time_of_day: %w", err)
+				}
+				agent.timeSpans = append(agent.timeSpans, timeSpan{start, end})
+			}
+		case "project":
+			values, ok := conditionStrings(value)
+			if !ok {
+				return nil, fmt.Errorf("condition project must be a name or list of names")
+			}
+			agent.projects = values
+		case "project_types":
+			values, ok := conditionStrings(value)
+			if !ok {
+				return nil, fmt.Errorf("condition project_types must be a list of project types")
+			}
+			for _, v := range values {
+				agent.projectTypes = append(agent.projectTypes, strings.ToLower(v))
+			}
+		case "branch":
+			values, ok := conditionStrings(value)
+			if !ok {
+				return nil, fmt.Errorf("condition branch must be a branch or list of branch patterns")
+			}
+			for _, v := range values {
+				if _, err := path.Match(v, ""); err != nil {
+					return nil, fmt.Errorf("condition branch: invalid pattern '%s'", v)
+				}
+			}
+			agent.branches = values
+		default:
+			return nil, fmt.Errorf("unknown condition '%s'%s", key, suggest(key, subAgentConditionKeys))
+		}
+	}
+	if agent.maxChars > 0 && agent.minChars > agent.maxChars {
+		return nil, fmt.Errorf("min_ctx_chars (%d) is greater than max_ctx_chars (%d)", agent.minChars, agent.maxChars)
+	}
+	return agent, nil
+}
+
+// compileSubAgents compiles sub-agents in name order, the order they are checked in.
+// Sub-agents that do not compile are returned in errs and left out.
+func compileSubAgents(subAgents map[string]config.SubAgent) (agents []*subAgent, errs map[string]error) {
+	names := make([]string, 0, len(subAgents))
+	for name := range subAgents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		agent, err := compileSubAgent(name, subAgents[name])
+		if err != nil {
+			if errs == nil {
+				errs = make(map[string]error)
+			}
+			errs[name] = err
+			continue
+		}
+		agents = append(agents, agent)
+	}
+	return agents, errs
+}
+
+// trigger returns the first trigger found in the text or intent, or ""
+func (a *subAgent) trigger(ctx Context) string {
+	text := strings.ToLower(ctx.Text)
+	intent := strings.ToLower(ctx.Intent)
+	for _, trigger := range a.triggers {
+		if strings.Contains(text, trigger) || strings.Contains(intent, trigger) {
+			return trigger
+		}
+	}
+	return ""
+}
+
+// conditionsMet reports whether every configured condition holds
+func (a *subAgent) conditionsMet(ctx Context) bool {
+	if a.minChars > 0 && ctx.CtxChars < a.minChars {
+		return false
+	}
+	if a.maxChars > 0 && ctx.CtxChars > a.maxChars {
+		return false
+	}
+	if len(a.timeSpans) > 0 || len(a.timeLabels) > 0 {
+		now := ctx.Now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		if !a.inTime(now, ctx.TimeOfDay) {
+			return false
+		}
+	}
+	if len(a.projects) > 0 && !containsString(a.projects, ctx.Project) {
+		return false
+	}
+	if len(a.projectTypes) > 0 {
+		found := false
+		for _, pt := range ctx.ProjectType {
+			if containsString(a.projectTypes, strings.ToLower(pt)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(a.branches) > 0 {
+		found := false
+		for _, pattern := range a.branches {
+			if ok, _ := path.Match(pattern, ctx.Branch); ok { //nolint:errcheck // patterns validated at compile time
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *subAgent) inTime(now time.Time, label string) bool {
+	for _, span := range a.timeSpans {
+		if span.contains(now) {
+			return true
+		}
+	}
+	if label == "" {
+		label = TimeOfDayLabel(now)
+	}
+	return containsString(a.timeLabels, label)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionInt reads a YAML number
+func conditionInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case float64:
+		return int(n), n == float64(int(n))
+	default:
+		return 0, false
+	}
+}
+
+// conditionStrings reads a YAML string or list of strings
+func conditionStrings(v interface{}) ([]string, bool) {
+	switch val := v.(type) {
+	case string:
+		if val == "" {
+			return nil, false
+		}
+		return []string{val}, true
+	case []interface{}:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			s, ok := item.(string)
+			if !ok || s == "" {
+				return nil, false
+			}
+			out = append(out, s)
+		}
+		return out, len(out) > 0
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,182 @@
+package routing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/royisme/bobamixer/internal/store/config"
+)
+
+// DefaultSuiteNow is the clock suite cases run at unless they set their own:
+// a Monday at noon UTC
+var DefaultSuiteNow = time.Date(2025, time.January, 6, 12, 0, 0, 0, time.UTC)
+
+// Suite is a set of golden routing cases, read from routes.test.yaml
+type Suite struct {
+	Now           string     `yaml:"now,omitempty"`            // RFC 3339 clock for every case; defaults to DefaultSuiteNow
+	ActiveProfile string     `yaml:"active_profile,omitempty"` // Profile cases fall back to when nothing matches
+	Cases         []TestCase `yaml:"cases"`
+}
+
+// TestCase is one routing input and the decision it must produce
+type TestCase struct {
+	Name         string   `yaml:"name"`
+	Intent       string   `yaml:"intent,omitempty"`
+	Text         string   `yaml:"text,omitempty"`
+	CtxChars     *int     `yaml:"ctx_chars,omitempty"` // Defaults to the length of text
+	Branch       string   `yaml:"branch,omitempty"`
+	Project      string   `yaml:"project,omitempty"`
+	ProjectTypes []string `yaml:"project_types,omitempty"`
+	TimeOfDay    string   `yaml:"time_of_day,omitempty"` // day, evening or night; defaults to the clock's
+	Now          string   `yaml:"now,omitempty"`         // Overrides the suite clock
+	Budget       string   `yaml:"budget,omitempty"`      // normal, near_cap or over_cap
+	BudgetUsed   float64  `yaml:"budget_used,omitempty"`
+	Expect       Expect   `yaml:"expect"`
+}
+
+// Expect is the decision a case must produce. Unset fields are not checked; an
+// empty rule or sub_agent asserts that none matched.
+type Expect struct {
+	Profile  string  `yaml:"profile,omitempty"`
+	Rule     *string `yaml:"rule,omitempty"`
+	SubAgent *string `yaml:"sub_agent,omitempty"`
+}
+
+// CaseResult is the outcome of one case
+type CaseResult struct {
+	Case     TestCase
+	Decision *Decision
+	Failures []string // One line per expectation the decision missed
+}
+
+// Passed reports whether the decision met every expectation
+func (r CaseResult) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// LoadSuite reads a routes.test.yaml file. Unknown fields are errors so that a
+// misspelled expectation cannot pass silently.
+func LoadSuite(path string) (*Suite, error) {
+	// #nosec G304 -- user-provided suite path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var suite Suite
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&suite); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &suite, nil
+}
+
+// RunSuite routes every case through routes with exploration disabled and a
+// fixed clock, so the same routes and suite always give the same results. It
+// fails only when the routes or a case are invalid; mismatches are reported in
+// the results.
+func RunSuite(routes *config.RoutesConfig, suite *Suite) ([]CaseResult, error) {
+	if routes == nil {
+		routes = &config.RoutesConfig{}
+	}
+	if _, err := CompileRoutes(routes); err != nil {
+		return nil, err
+	}
+	if len(suite.Cases) == 0 {
+		return nil, errors.New("suite has no cases")
+	}
+	now, err := suiteClock(suite.Now, DefaultSuiteNow)
+	if err != nil {
+		return nil, fmt.Errorf("suite now: %w", err)
+	}
+
+	router := NewRouter(routes)
+	router.SetEnableExplore(false)
+
+	results := make([]CaseResult, 0, len(suite.Cases))
+	for i, tc := range suite.Cases {
+		if tc.Name == "" {
+			tc.Name = fmt.Sprintf("case %d", i+1)
+		}
+		ctx, err := tc.context(now)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tc.Name, err)
+		}
+		if tc.Expect.Profile == "" && tc.Expect.Rule == nil && tc.Expect.SubAgent == nil {
+			return nil, fmt.Errorf("%s: expect needs a profile, rule or sub_agent", tc.Name)
+		}
+
+		decision := router.Route(ctx, suite.ActiveProfile)
+		result := CaseResult{Case: tc, Decision: decision}
+		if tc.Expect.Profile != "" && decision.ProfileKey != tc.Expect.Profile {
+			result.Failures = append(result.Failures,
+				fmt.Sprintf("profile: want %s, got %s", tc.Expect.Profile, orNone(decision.ProfileKey)))
+		}
+		if tc.Expect.Rule != nil && decision.RuleID != *tc.Expect.Rule {
+			result.Failures = append(result.Failures,
+				fmt.Sprintf("rule: want %s, got %s", orNone(*tc.Expect.Rule), orNone(decision.RuleID)))
+		}
+		if tc.Expect.SubAgent != nil && decision.SubAgent != *tc.Expect.SubAgent {
+			result.Failures = append(result.Failures,
+				fmt.Sprintf("sub_agent: want %s, got %s", orNone(*tc.Expect.SubAgent), orNone(decision.SubAgent)))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// context builds the routing context of a case at the suite clock
+func (tc TestCase) context(suiteNow time.Time) (Context, error) {
+	now, err := suiteClock(tc.Now, suiteNow)
+	if err != nil {
+		return Context{}, fmt.Errorf("now: %w", err)
+	}
+	switch tc.TimeOfDay {
+	case "", "day", "evening", "night":
+	default:
+		return Context{}, fmt.Errorf("time_of_day must be day, evening or night, got %q", tc.TimeOfDay)
+	}
+	switch tc.Budget {
+	case "", "normal", "near_cap", "over_cap":
+	default:
+		return Context{}, fmt.Errorf("budget must be normal, near_cap or over_cap, got %q", tc.Budget)
+	}
+
+	ctxChars := len(tc.Text)
+	if tc.CtxChars != nil {
+		ctxChars = *tc.CtxChars
+	}
+	return Context{
+		Intent:      tc.Intent,
+		Text:        tc.Text,
+		CtxChars:    ctxChars,
+		Branch:      tc.Branch,
+		Project:     tc.Project,
+		ProjectType: tc.ProjectTypes,
+		TimeOfDay:   tc.TimeOfDay,
+		Budget:      tc.Budget,
+		BudgetUsed:  tc.BudgetUsed,
+		Now:         now,
+	}, nil
+}
+
+// suiteClock parses an RFC 3339 time, or returns def when raw is empty
+func suiteClock(raw string, def time.Time) (time.Time, error) {
+	if raw == "" {
+		return def, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
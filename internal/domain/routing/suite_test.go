@@ -0,0 +1,114 @@
+package routing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/royisme/bobamixer/internal/store/config"
+)
+
+func TestRunSuite(t *testing.T) {
+	routes := &config.RoutesConfig{
+		SubAgents: map[string]config.SubAgent{
+			"reviewer": {Triggers: []string{"review"}, Profile: "heavy"},
+		},
+		Rules: []config.RouteRule{
+			{ID: "big", If: "ctx_chars > 5000", Use: "heavy"},
+			{ID: "late", If: "time_of_day == 'night' && branch.starts_with('hotfix/')", Use: "cheap"},
+			{ID: "office-hours", If: "clock >= 9h && clock < 17h && project_types.contains('go')", Use: "go"},
+			{ID: "tight", If: "budget == 'near_cap'", Use: "cheap"},
+		},
+		// Exploration must not leak into suite results
+		Explore: config.ExploreConfig{Enabled: true, Rate: 1},
+	}
+
+	path := filepath.Join(t.TempDir(), "routes.test.yaml")
+	suiteYAML := `active_profile: quick
+cases:
+  - name: sub-agent first
+    text: please review this
+    ctx_chars: 9000
+    expect: {profile: heavy, sub_agent: reviewer, rule: ""}
+  - name: large context
+    ctx_chars: 9000
+    expect: {profile: heavy, rule: big}
+  - name: hotfix at night
+    branch: hotfix/login
+    time_of_day: night
+    expect: {profile: cheap, rule: late}
+  - name: go in office hours
+    project_types: [go]
+    expect: {profile: go, rule: office-hours}
+  - name: go after hours
+    project_types: [go]
+    now: "2025-01-06T20:00:00Z"
+    expect: {profile: quick, rule: ""}
+  - name: near cap
+    budget: near_cap
+    expect: {profile: cheap}
+  - name: wrong on purpose
+    text: hello
+    expect: {profile: heavy, rule: big}
+`
+	if err := os.WriteFile(path, []byte(suiteYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	suite, err := LoadSuite(path)
+	if err != nil {
+		t.Fatalf("LoadSuite: %v", err)
+	}
+
+	results, err := RunSuite(routes, suite)
+	if err != nil {
+		t.Fatalf("RunSuite: %v", err)
+	}
+	if len(results) != 7 {
+		t.Fatalf("got %d results, want 7", len(results))
+	}
+	for _, result := range results[:6] {
+		if !result.Passed() {
+			t.Errorf("%s failed: %v", result.Case.Name, result.Failures)
+		}
+		if result.Decision.Explore {
+			t.Errorf("%s explored", result.Case.Name)
+		}
+	}
+	last := results[6]
+	if last.Passed() || len(last.Failures) != 2 {
+		t.Fatalf("wrong on purpose: failures = %v, want profile and rule mismatches", last.Failures)
+	}
+	if last.Failures[0] != "profile: want heavy, got quick" || last.Failures[1] != "rule: want big, got (none)" {
+		t.Errorf("failures = %q", last.Failures)
+	}
+}
+
+func TestLoadSuiteRejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.test.yaml")
+	if err := os.WriteFile(path, []byte("cases:\n  - text: hi\n    expect: {profle: quick}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSuite(path); err == nil || !strings.Contains(err.Error(), "profle") {
+		t.Errorf("LoadSuite error = %v, want the misspelled field named", err)
+	}
+}
+
+func TestRunSuiteRejectsInvalidCases(t *testing.T) {
+	routes := &config.RoutesConfig{Rules: []config.RouteRule{{ID: "r", If: "intent == 'x'", Use: "p"}}}
+	tests := []struct {
+		name  string
+		suite Suite
+		want  string
+	}{
+		{"no cases", Suite{}, "no cases"},
+		{"no expectation", Suite{Cases: []TestCase{{Name: "c"}}}, "expect needs"},
+		{"bad budget", Suite{Cases: []TestCase{{Name: "c", Budget: "broke", Expect: Expect{Profile: "p"}}}}, "budget must be"},
+		{"bad clock", Suite{Now: "noon", Cases: []TestCase{{Name: "c", Expect: Expect{Profile: "p"}}}}, "suite now"},
+	}
+	for _, tt := range tests {
+		if _, err := RunSuite(routes, &tt.suite); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
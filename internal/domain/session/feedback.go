@@ -0,0 +1,145 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+// Ratings a session can be given
+const (
+	RatingGood = 1
+	RatingBad  = -1
+)
+
+// Feedback sources
+const (
+	FeedbackSourceCLI   = "cli"
+	FeedbackSourceTUI   = "tui"
+	FeedbackSourceProxy = "proxy"
+)
+
+// LastRef refers to the most recently started session
+const LastRef = "last"
+
+// ErrSessionNotFound is returned when a session reference matches no session
+var ErrSessionNotFound = errors.New("session not found")
+
+// Feedback is an explicit rating of a session's result
+type Feedback struct {
+	SessionID string
+	CreatedAt time.Time
+	Rating    int // RatingGood or RatingBad
+	Note      string
+	Source    string // cli, tui or proxy
+}
+
+// ParseRating reads good or bad (also up/down, +1/-1)
+func ParseRating(s string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "good", "up", "+1", "1":
+		return RatingGood, nil
+	case "bad", "down", "-1":
+		return RatingBad, nil
+	default:
+		return 0, fmt.Errorf("rating must be good or bad, got '%s'", s)
+	}
+}
+
+// RatingLabel names a rating
+func RatingLabel(rating int) string {
+	switch {
+	case rating > 0:
+		return "good"
+	case rating < 0:
+		return "bad"
+	default:
+		return ""
+	}
+}
+
+// ResolveID turns "last", a full session ID or a unique ID prefix into a session ID
+func ResolveID(db *sqlite.DB, ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return "", fmt.Errorf("session reference is required")
+	}
+	if ref == LastRef {
+		id, err := db.QueryRow("SELECT id FROM sessions ORDER BY started_at DESC, rowid DESC LIMIT 1;")
+		if err != nil {
+			return "", fmt.Errorf("query last session: %w", err)
+		}
+		if id == "" {
+			return "", ErrSessionNotFound
+		}
+		return id, nil
+	}
+
+	rows, err := db.QueryRows(fmt.Sprintf(
+		"SELECT id FROM sessions WHERE id = '%[1]s' OR substr(id, 1, %[2]d) = '%[1]s' ORDER BY id = '%[1]s' DESC LIMIT 2;",
+		escape(ref), len(ref)))
+	if err != nil {
+		return "", fmt.Errorf("query session: %w", err)
+	}
+	switch {
+	case len(rows) == 0:
+		return "", fmt.Errorf("%w: %s", ErrSessionNotFound, ref)
+	case rows[0] == ref || len(rows) == 1:
+		return rows[0], nil
+	default:
+		return "", fmt.Errorf("session prefix %s is ambiguous", ref)
+	}
+}
+
+// LastForTool returns the ID of the last session with usage recorded for a
+// tool, so "last" means the tool's own last session when several share a proxy
+func LastForTool(db *sqlite.DB, tool string) (string, error) {
+	id, err := db.QueryRow(fmt.Sprintf(
+		"SELECT session_id FROM usage_records WHERE tool = '%s' ORDER BY ts DESC, rowid DESC LIMIT 1;", escape(tool)))
+	if err != nil {
+		return "", fmt.Errorf("query last session: %w", err)
+	}
+	if id == "" {
+		return "", fmt.Errorf("%w: no session for tool %s", ErrSessionNotFound, tool)
+	}
+	return id, nil
+}
+
+// Rate records feedback for a session, replacing any earlier rating
+func Rate(db *sqlite.DB, fb Feedback) error {
+	if fb.Rating != RatingGood && fb.Rating != RatingBad {
+		return fmt.Errorf("rating must be %d or %d, got %d", RatingGood, RatingBad, fb.Rating)
+	}
+	if fb.CreatedAt.IsZero() {
+		fb.CreatedAt = time.Now()
+	}
+	return db.Exec(fmt.Sprintf(`INSERT OR REPLACE INTO feedback (session_id, ts, rating, note, source)
+		VALUES ('%s', %d, %d, '%s', '%s');`,
+		escape(fb.SessionID), fb.CreatedAt.Unix(), fb.Rating, escape(fb.Note), escape(fb.Source)))
+}
+
+// GetFeedback returns a session's feedback, or nil when it has none
+func GetFeedback(db *sqlite.DB, sessionID string) (*Feedback, error) {
+	row, err := db.QueryRow(fmt.Sprintf(
+		"SELECT ts, rating, source, note FROM feedback WHERE session_id = '%s';", escape(sessionID)))
+	if err != nil {
+		return nil, err
+	}
+	if row == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(row, "|", 4)
+	if len(parts) < 4 {
+		return nil, fmt.Errorf("unexpected feedback row: %s", row)
+	}
+	return &Feedback{
+		SessionID: sessionID,
+		CreatedAt: time.Unix(parseInt64(parts[0]), 0),
+		Rating:    int(parseInt64(parts[1])),
+		Source:    parts[2],
+		Note:      parts[3],
+	}, nil
+}
@@ -25,6 +25,7 @@ type Session struct {
 	TaskType  string
 	Notes     string
 	Success   bool
+	Rating    int // RatingGood, RatingBad or 0 when not rated
 }
 
 // NewSession creates a new session
@@ -98,7 +99,9 @@ func GetSession(db *sqlite.DB, id string) (*Session, error) {
 
 // ListRecentSessions returns recent sessions
 func ListRecentSessions(db *sqlite.DB, limit int) ([]*Session, error) {
-	query := fmt.Sprintf("SELECT id, started_at, ended_at, profile, adapter, success, latency_ms, task_type FROM sessions ORDER BY started_at DESC LIMIT %d;", limit)
+	query := fmt.Sprintf(`SELECT s.id, s.started_at, s.ended_at, s.profile, s.adapter, s.success, s.latency_ms, s.task_type, COALESCE(f.rating, 0)
+		FROM sessions s LEFT JOIN feedback f ON f.session_id = s.id
+		ORDER BY s.started_at DESC LIMIT %d;`, limit)
 	rows, err := db.QueryRows(query)
 	if err != nil {
 		return nil, err
@@ -109,7 +112,7 @@ func ListRecentSessions(db *sqlite.DB, limit int) ([]*Session, error) {
 			continue
 		}
 		parts := strings.Split(row, "|")
-		if len(parts) < 8 {
+		if len(parts) < 9 {
 			continue
 		}
 		sess := &Session{ID: parts[0]}
@@ -120,6 +123,7 @@ func ListRecentSessions(db *sqlite.DB, limit int) ([]*Session, error) {
 		sess.Success = parts[5] == "1"
 		sess.LatencyMS = parseInt64(parts[6])
 		sess.TaskType = parts[7]
+		sess.Rating = int(parseInt64(parts[8]))
 		sessions = append(sessions, sess)
 	}
 	return sessions, nil
@@ -127,7 +131,7 @@ func ListRecentSessions(db *sqlite.DB, limit int) ([]*Session, error) {
 
 func escape(s string) string {
 	// Simple SQL escape - in production use parameterized queries
-	return s
+	return strings.ReplaceAll(s, "'", "''")
 }
 
 func parseInt64(raw string) int64 {
@@ -42,3 +42,52 @@ func TestListRecentSessions(t *testing.T) {
 		t.Fatalf("expected distinct sessions")
 	}
 }
+
+func TestRateResolvesSessionReferences(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	now := time.Now().Unix()
+	for i, id := range []string{"abc123", "abd456", "xyz789"} {
+		sess := &Session{ID: id, StartedAt: now + int64(i), Profile: "work"}
+		if err := sess.Save(db); err != nil {
+			t.Fatalf("Save session: %v", err)
+		}
+	}
+
+	tests := []struct {
+		ref, want string
+		wantErr   bool
+	}{
+		{LastRef, "xyz789", false},
+		{"abc123", "abc123", false},
+		{"abd", "abd456", false},
+		{"ab", "", true}, // ambiguous
+		{"nope", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ResolveID(db, tt.ref)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ResolveID(%s) = %q, %v; want %q", tt.ref, got, err, tt.want)
+		}
+	}
+
+	if err := Rate(db, Feedback{SessionID: "abc123", Rating: RatingBad, Note: "didn't compile", Source: FeedbackSourceCLI}); err != nil {
+		t.Fatalf("Rate: %v", err)
+	}
+	fb, err := GetFeedback(db, "abc123")
+	if err != nil || fb == nil || fb.Rating != RatingBad || fb.Note != "didn't compile" {
+		t.Fatalf("GetFeedback = %+v, %v", fb, err)
+	}
+	sessions, err := ListRecentSessions(db, 3)
+	if err != nil {
+		t.Fatalf("ListRecentSessions: %v", err)
+	}
+	if last := sessions[2]; last.ID != "abc123" || last.Rating != RatingBad {
+		t.Errorf("listed session = %+v, want abc123 rated bad", last)
+	}
+	if err := Rate(db, Feedback{SessionID: "abc123", Rating: 0}); err == nil {
+		t.Error("expected an error for a zero rating")
+	}
+}
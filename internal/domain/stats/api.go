@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/royisme/bobamixer/internal/domain/tokenizer"
 	"github.com/royisme/bobamixer/internal/store/sqlite"
 )
 
@@ -78,6 +79,100 @@ func Window(ctx context.Context, db *sqlite.DB, from, to time.Time) (Summary, er
 	}, nil
 }
 
+// BilledSummary is usage as providers billed it, from exports imported with
+// `boba reconcile import --store`. It has no sessions.
+type BilledSummary struct {
+	Summary
+	Sources []string // export sources with billed usage in the window
+	Through string   // last billed day on record, whatever the window
+}
+
+// Billed returns billed usage between from and to (inclusive dates). Billed
+// days are UTC, the days providers bill by, so the bounds are taken in UTC.
+func Billed(ctx context.Context, db *sqlite.DB, from, to time.Time) (BilledSummary, error) {
+	if err := requireSchemaVersion(db, 17); err != nil {
+		return BilledSummary{}, err
+	}
+	from, to = from.UTC(), to.UTC()
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(toDay.Sub(fromDay).Hours()/24) + 1
+	if days <= 0 {
+		days = 1
+	}
+
+	rows, err := db.QueryRows(fmt.Sprintf(`
+		SELECT source, SUM(input_tokens + output_tokens), SUM(cost)
+		FROM billed_usage
+		WHERE day >= '%s'
+		  AND day <= '%s'
+		GROUP BY source
+		ORDER BY source;
+	`, from.Format("2006-01-02"), to.Format("2006-01-02")))
+	if err != nil {
+		return BilledSummary{}, fmt.Errorf("query billed usage: %w", err)
+	}
+
+	var billed BilledSummary
+	for _, row := range rows {
+		parts := strings.Split(row, "|")
+		if len(parts) < 3 {
+			continue
+		}
+		billed.Sources = append(billed.Sources, parts[0])
+		billed.TotalTokens += parseInt(parts[1])
+		billed.TotalCost += parseFloat(parts[2])
+	}
+	billed.AvgDailyTokens = float64(billed.TotalTokens) / float64(days)
+	billed.AvgDailyCost = billed.TotalCost / float64(days)
+
+	billed.Through, err = db.QueryRow("SELECT COALESCE(MAX(day), '') FROM billed_usage;")
+	if err != nil {
+		return BilledSummary{}, fmt.Errorf("query billed usage: %w", err)
+	}
+	return billed, nil
+}
+
+// CacheSavings summarizes requests answered by the proxy response cache.
+type CacheSavings struct {
+	Hits        int
+	TokensSaved int
+	CostSaved   float64
+}
+
+// CacheSavingsWindow returns response cache hits and avoided cost between from and to (inclusive dates).
+func CacheSavingsWindow(ctx context.Context, db *sqlite.DB, from, to time.Time) (CacheSavings, error) {
+	if err := requireSchemaVersion(db, 5); err != nil {
+		return CacheSavings{}, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(input_tokens + output_tokens), 0),
+			COALESCE(SUM(saved_cost), 0)
+		FROM usage_records
+		WHERE cache_hit = 1
+		  AND date(ts, 'unixepoch') >= '%s'
+		  AND date(ts, 'unixepoch') <= '%s';
+	`, from.Format("2006-01-02"), to.Format("2006-01-02"))
+
+	row, err := db.QueryRow(query)
+	if err != nil {
+		return CacheSavings{}, fmt.Errorf("query cache savings: %w", err)
+	}
+	parts := strings.Split(row, "|")
+	if len(parts) < 3 {
+		return CacheSavings{}, nil
+	}
+
+	return CacheSavings{
+		Hits:        parseInt(parts[0]),
+		TokensSaved: parseInt(parts[1]),
+		CostSaved:   parseFloat(parts[2]),
+	}, nil
+}
+
 // ErrSchemaTooOld indicates the SQLite schema version is below the minimum supported level.
 var ErrSchemaTooOld = errors.New("stats schema version too old")
 
@@ -245,3 +340,197 @@ func requireSchemaVersion(db *sqlite.DB, minVersion int) error {
 	}
 	return nil
 }
+
+// ShadowComparison compares a primary model with one shadow provider/model pair.
+type ShadowComparison struct {
+	PrimaryModel     string
+	ShadowProvider   string
+	ShadowModel      string
+	Requests         int
+	Errors           int
+	PrimaryLatencyMS float64 // average
+	ShadowLatencyMS  float64 // average, successful shadows only
+	PrimaryCost      float64 // total over successful shadows
+	ShadowCost       float64 // total over successful shadows
+	Similarity       float64 // average output similarity (0-1), successful shadows only
+}
+
+// CostDeltaPercent returns how much more (positive) or less (negative) the shadow cost.
+func (c ShadowComparison) CostDeltaPercent() float64 {
+	if c.PrimaryCost == 0 {
+		return 0
+	}
+	return (c.ShadowCost - c.PrimaryCost) / c.PrimaryCost * 100
+}
+
+// ShadowComparisons aggregates mirrored requests between from and to (inclusive dates),
+// one row per primary model and shadow target, busiest first.
+func ShadowComparisons(ctx context.Context, db *sqlite.DB, from, to time.Time) ([]ShadowComparison, error) {
+	if err := requireSchemaVersion(db, 8); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			COALESCE(primary_model, ''),
+			shadow_provider,
+			COALESCE(shadow_model, ''),
+			COUNT(*),
+			SUM(CASE WHEN status_code = 200 THEN 0 ELSE 1 END),
+			COALESCE(AVG(primary_latency_ms), 0),
+			COALESCE(AVG(CASE WHEN status_code = 200 THEN latency_ms END), 0),
+			COALESCE(SUM(CASE WHEN status_code = 200 THEN primary_cost ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status_code = 200 THEN cost ELSE 0 END), 0),
+			COALESCE(AVG(CASE WHEN status_code = 200 THEN similarity END), 0)
+		FROM shadow_results
+		WHERE date(ts, 'unixepoch') >= '%s'
+		  AND date(ts, 'unixepoch') <= '%s'
+		GROUP BY 1, 2, 3
+		ORDER BY 4 DESC, 1, 2, 3;
+	`, from.Format("2006-01-02"), to.Format("2006-01-02"))
+
+	rows, err := db.QueryRows(query)
+	if err != nil {
+		return nil, fmt.Errorf("query shadow results: %w", err)
+	}
+
+	comparisons := make([]ShadowComparison, 0, len(rows))
+	for _, row := range rows {
+		parts := strings.Split(row, "|")
+		if len(parts) < 10 {
+			continue
+		}
+		comparisons = append(comparisons, ShadowComparison{
+			PrimaryModel:     parts[0],
+			ShadowProvider:   parts[1],
+			ShadowModel:      parts[2],
+			Requests:         parseInt(parts[3]),
+			Errors:           parseInt(parts[4]),
+			PrimaryLatencyMS: parseFloat(parts[5]),
+			ShadowLatencyMS:  parseFloat(parts[6]),
+			PrimaryCost:      parseFloat(parts[7]),
+			ShadowCost:       parseFloat(parts[8]),
+			Similarity:       parseFloat(parts[9]),
+		})
+	}
+	return comparisons, nil
+}
+
+// TokenizerSamples returns, per model, the prompts the proxy counted locally and
+// the provider counted exactly since the given time, for tokenizer calibration.
+func TokenizerSamples(ctx context.Context, db *sqlite.DB, since time.Time) (map[string][]tokenizer.Sample, error) {
+	if err := requireSchemaVersion(db, 9); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT model, prompt_chars, prompt_code_chars, prompt_overhead, estimated_input_tokens, input_tokens
+		FROM usage_records
+		WHERE estimate_level = 'exact'
+		  AND cache_hit = 0
+		  AND prompt_chars > 0
+		  AND input_tokens > 0
+		  AND COALESCE(model, '') != ''
+		  AND ts >= %d
+		ORDER BY ts;
+	`, since.Unix())
+
+	rows, err := db.QueryRows(query)
+	if err != nil {
+		return nil, fmt.Errorf("query tokenizer samples: %w", err)
+	}
+
+	samples := make(map[string][]tokenizer.Sample)
+	for _, row := range rows {
+		parts := strings.Split(row, "|")
+		if len(parts) < 6 {
+			continue
+		}
+		samples[parts[0]] = append(samples[parts[0]], tokenizer.Sample{
+			Chars:     parseInt(parts[1]),
+			CodeChars: parseInt(parts[2]),
+			Overhead:  parseInt(parts[3]),
+			Estimated: parseInt(parts[4]),
+			Exact:     parseInt(parts[5]),
+		})
+	}
+	return samples, nil
+}
+
+// Satisfaction dimensions
+const (
+	SatisfactionByProfile = "profile"
+	SatisfactionByModel   = "model"
+	SatisfactionByRoute   = "route" // routing context, e.g. rule:large-context
+)
+
+// Satisfaction aggregates explicit session ratings for one profile, model or routing context.
+type Satisfaction struct {
+	Key   string
+	Rated int
+	Good  int
+}
+
+// Bad returns how many rated sessions were rated bad.
+func (s Satisfaction) Bad() int {
+	return s.Rated - s.Good
+}
+
+// Rate returns the share of rated sessions rated good, from 0 to 1.
+func (s Satisfaction) Rate() float64 {
+	if s.Rated == 0 {
+		return 0
+	}
+	return float64(s.Good) / float64(s.Rated)
+}
+
+// SatisfactionWindow aggregates ratings of sessions started between from and to
+// (inclusive dates) by profile, model or routing context, most rated first.
+// Sessions without a value for the dimension are left out.
+func SatisfactionWindow(ctx context.Context, db *sqlite.DB, from, to time.Time, by string) ([]Satisfaction, error) {
+	if err := requireSchemaVersion(db, 11); err != nil {
+		return nil, err
+	}
+
+	var key string
+	switch by {
+	case SatisfactionByProfile:
+		key = "COALESCE(s.profile, '')"
+	case SatisfactionByModel:
+		key = "COALESCE((SELECT MAX(u.model) FROM usage_records u WHERE u.session_id = s.id), '')"
+	case SatisfactionByRoute:
+		key = "s.route_key"
+	default:
+		return nil, fmt.Errorf("unknown satisfaction dimension: %s", by)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s AS k, COUNT(*), SUM(CASE WHEN f.rating > 0 THEN 1 ELSE 0 END)
+		FROM feedback f
+		JOIN sessions s ON s.id = f.session_id
+		WHERE date(s.started_at, 'unixepoch') >= '%s'
+		  AND date(s.started_at, 'unixepoch') <= '%s'
+		GROUP BY k
+		HAVING k != ''
+		ORDER BY 2 DESC, 1;
+	`, key, from.Format("2006-01-02"), to.Format("2006-01-02"))
+
+	rows, err := db.QueryRows(query)
+	if err != nil {
+		return nil, fmt.Errorf("query satisfaction: %w", err)
+	}
+
+	result := make([]Satisfaction, 0, len(rows))
+	for _, row := range rows {
+		parts := strings.Split(row, "|")
+		if len(parts) < 3 {
+			continue
+		}
+		result = append(result, Satisfaction{
+			Key:   parts[0],
+			Rated: parseInt(parts[1]),
+			Good:  parseInt(parts[2]),
+		})
+	}
+	return result, nil
+}
@@ -238,3 +238,81 @@ func insertLatencySession(t *testing.T, db *sqlite.DB, sessionID, profile string
 		t.Fatalf("insert latency session: %v", err)
 	}
 }
+
+func TestShadowComparisons(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	now := time.Now().Unix()
+
+	insert := func(id, shadowModel string, status int, latency int64, primaryCost, cost, similarity float64) {
+		query := fmt.Sprintf(`INSERT INTO shadow_results (id, session_id, ts, primary_provider, primary_model, primary_latency_ms, primary_cost,
+			shadow_provider, shadow_model, status_code, latency_ms, cost, similarity)
+			VALUES ('%s', 's1', %d, 'anthropic', 'claude-sonnet-4', 1000, %f, 'zai', '%s', %d, %d, %f, %f);`,
+			id, now, primaryCost, shadowModel, status, latency, cost, similarity)
+		if err := db.Exec(query); err != nil {
+			t.Fatalf("insert shadow result: %v", err)
+		}
+	}
+	insert("a", "glm-4.6", 200, 600, 0.02, 0.005, 0.8)
+	insert("b", "glm-4.6", 200, 800, 0.02, 0.005, 0.6)
+	insert("c", "glm-4.6", 529, 50, 0.02, 0, 0)
+	insert("d", "glm-4.5-air", 200, 300, 0.01, 0.001, 0.4)
+
+	comparisons, err := stats.ShadowComparisons(ctx, db, time.Now(), time.Now())
+	if err != nil {
+		t.Fatalf("ShadowComparisons: %v", err)
+	}
+	if len(comparisons) != 2 {
+		t.Fatalf("pairs = %d, want 2", len(comparisons))
+	}
+	glm := comparisons[0]
+	if glm.ShadowModel != "glm-4.6" || glm.Requests != 3 || glm.Errors != 1 {
+		t.Fatalf("unexpected first pair %+v", glm)
+	}
+	if glm.ShadowLatencyMS != 700 || glm.PrimaryCost != 0.04 || glm.ShadowCost != 0.01 || glm.Similarity != 0.7 {
+		t.Errorf("successful-shadow aggregates wrong: %+v", glm)
+	}
+	if delta := glm.CostDeltaPercent(); delta != -75 {
+		t.Errorf("cost delta = %v, want -75", delta)
+	}
+}
+
+func TestSatisfactionWindow(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	insertTestUsage(t, db, "s1", 10, 10, 0.01, 0)
+	insertTestUsage(t, db, "s2", 10, 10, 0.01, 0)
+	insertTestUsage(t, db, "s3", 10, 10, 0.01, 0)
+	insertTestUsage(t, db, "old", 10, 10, 0.01, 30)
+	now := time.Now().Unix()
+	seed := fmt.Sprintf(`UPDATE sessions SET route_key = 'rule:review' WHERE id IN ('s1', 's2');
+		INSERT INTO feedback (session_id, ts, rating) VALUES ('s1', %[1]d, 1), ('s2', %[1]d, -1), ('s3', %[1]d, 1), ('old', %[1]d, -1);`, now)
+	if err := db.Exec(seed); err != nil {
+		t.Fatalf("seed feedback: %v", err)
+	}
+
+	from := time.Now().AddDate(0, 0, -7)
+	to := time.Now()
+	tests := []struct {
+		by   string
+		want []stats.Satisfaction
+	}{
+		{stats.SatisfactionByProfile, []stats.Satisfaction{{Key: "test-profile", Rated: 3, Good: 2}}},
+		{stats.SatisfactionByModel, []stats.Satisfaction{{Key: "test-model", Rated: 3, Good: 2}}},
+		{stats.SatisfactionByRoute, []stats.Satisfaction{{Key: "rule:review", Rated: 2, Good: 1}}},
+	}
+	for _, tt := range tests {
+		got, err := stats.SatisfactionWindow(ctx, db, from, to, tt.by)
+		if err != nil {
+			t.Fatalf("SatisfactionWindow(%s): %v", tt.by, err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("SatisfactionWindow(%s) = %v, want %v", tt.by, got, tt.want)
+		}
+	}
+
+	if _, err := stats.SatisfactionWindow(ctx, db, from, to, "branch"); err == nil {
+		t.Error("expected an error for an unknown dimension")
+	}
+}
@@ -1,8 +1,10 @@
 package suggestions
 
 import (
+	"context"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/royisme/bobamixer/internal/domain/stats"
 	"github.com/royisme/bobamixer/internal/store/sqlite"
@@ -31,6 +33,10 @@ type Suggestion struct {
 	Type        SuggestionType
 }
 
+// minRatedSessions is how many rated sessions a profile needs before its
+// satisfaction is acted on
+const minRatedSessions = 5
+
 // SuggestionData contains supporting data for suggestions
 type SuggestionData struct {
 	CurrentCost      float64
@@ -89,6 +95,12 @@ func (e *Engine) GenerateSuggestions(days int) ([]Suggestion, error) {
 		suggestions = append(suggestions, *anomalySugg)
 	}
 
+	// Act on explicit session feedback
+	satisfactionSugg := e.analyzeSatisfaction(days)
+	if satisfactionSugg != nil {
+		suggestions = append(suggestions, *satisfactionSugg)
+	}
+
 	// Budget optimization
 	budgetSugg := e.suggestBudgetAdjustment(trend)
 	if budgetSugg != nil {
@@ -196,6 +208,60 @@ func (e *Engine) analyzeProfileUsage(profiles []stats.ProfileStats, trend *stats
 	return nil
 }
 
+// analyzeSatisfaction flags the least liked profile when most of its rated
+// sessions were rated bad, pointing at the best liked one
+func (e *Engine) analyzeSatisfaction(days int) *Suggestion {
+	to := time.Now()
+	from := to.AddDate(0, 0, -days+1)
+	profiles, err := stats.SatisfactionWindow(context.Background(), e.db, from, to, stats.SatisfactionByProfile)
+	if err != nil {
+		return nil
+	}
+
+	var worst, best *stats.Satisfaction
+	for i := range profiles {
+		p := &profiles[i]
+		if p.Rated < minRatedSessions {
+			continue
+		}
+		if worst == nil || p.Rate() < worst.Rate() {
+			worst = p
+		}
+		if best == nil || p.Rate() > best.Rate() {
+			best = p
+		}
+	}
+	if worst == nil || worst.Rate() >= 0.5 {
+		return nil
+	}
+
+	suggested := ""
+	actions := []string{
+		fmt.Sprintf("Review the notes left on sessions rated bad on '%s'", worst.Key),
+		"Check which rules route to it with 'boba route stats'",
+	}
+	if best != nil && best.Key != worst.Key && best.Rate() > worst.Rate() {
+		suggested = best.Key
+		actions = append([]string{
+			fmt.Sprintf("Route that work to '%s' instead (%.0f%% rated good)", best.Key, best.Rate()*100),
+		}, actions...)
+	}
+
+	return &Suggestion{
+		Type:        SuggestionProfileSwitch,
+		Title:       "Low Satisfaction with Profile",
+		Description: fmt.Sprintf("Only %d of %d rated sessions on '%s' were rated good (%.0f%%).", worst.Good, worst.Rated, worst.Key, worst.Rate()*100),
+		Impact:      "Better results for the same work",
+		Priority:    4,
+		ActionItems: actions,
+		Data: SuggestionData{
+			CurrentProfile:   worst.Key,
+			SuggestedProfile: suggested,
+			AffectedDays:     days,
+		},
+	}
+}
+
 // detectAnomalies detects unusual spending patterns
 func (e *Engine) detectAnomalies(trend *stats.Trend) *Suggestion {
 	if trend == nil || len(trend.DataPoints) < 7 {
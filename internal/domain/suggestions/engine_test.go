@@ -460,3 +460,47 @@ func containsMiddle(s, substr string) bool {
 	}
 	return false
 }
+
+func TestEngineAnalyzeSatisfaction(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "suggestions.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	engine := NewEngine(db)
+
+	seeded := 0
+	rate := func(profile string, good, bad int) {
+		for i := 0; i < good+bad; i++ {
+			seeded++
+			rating := 1
+			if i >= good {
+				rating = -1
+			}
+			id := fmt.Sprintf("%s-%d", profile, seeded)
+			stmt := fmt.Sprintf(`INSERT INTO sessions (id, started_at, profile, success) VALUES ('%[1]s', %[2]d, '%[3]s', 1);
+				INSERT INTO feedback (session_id, ts, rating) VALUES ('%[1]s', %[2]d, %[4]d);`, id, time.Now().Unix(), profile, rating)
+			if err := db.Exec(stmt); err != nil {
+				t.Fatalf("seed: %v", err)
+			}
+		}
+	}
+
+	// Too few ratings to act on
+	rate("quick", 1, 3)
+	if sugg := engine.analyzeSatisfaction(7); sugg != nil {
+		t.Fatalf("suggestion from 4 ratings: %+v", sugg)
+	}
+
+	rate("quick", 0, 2)
+	rate("heavy", 5, 1)
+	sugg := engine.analyzeSatisfaction(7)
+	if sugg == nil {
+		t.Fatal("expected a suggestion for a profile rated good 1 in 6")
+	}
+	if sugg.Data.CurrentProfile != "quick" || sugg.Data.SuggestedProfile != "heavy" {
+		t.Errorf("suggestion moves %s -> %s, want quick -> heavy", sugg.Data.CurrentProfile, sugg.Data.SuggestedProfile)
+	}
+	if !contains(sugg.Description, "1 of 6") {
+		t.Errorf("description = %q", sugg.Description)
+	}
+}
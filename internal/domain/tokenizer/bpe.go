@@ -0,0 +1,184 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/royisme/bobamixer/internal/logging"
+)
+
+// BPE vocabularies in tiktoken format ("<base64 token> <rank>" per line)
+const (
+	VocabCL100K = "cl100k_base"
+	VocabO200K  = "o200k_base"
+)
+
+// vocabFS holds the vocabularies compiled into the binary, cl100k_base.tiktoken
+// and o200k_base.tiktoken (see vocab/README.md)
+//
+//go:embed vocab
+var vocabFS embed.FS
+
+var (
+	vocabDirMu sync.RWMutex
+	vocabDir   string
+
+	vocabMu    sync.Mutex
+	vocabCache = make(map[string]*bpe) // nil entries record vocabularies that are unavailable
+)
+
+// SetVocabDir sets a directory searched for <name>.tiktoken files when a vocabulary
+// is not embedded in the binary (e.g. ~/.boba/tokenizers)
+func SetVocabDir(dir string) {
+	vocabDirMu.Lock()
+	defer vocabDirMu.Unlock()
+	vocabDir = dir
+}
+
+// loadVocab returns the named BPE tokenizer, or nil when its vocabulary is unavailable
+func loadVocab(name string) *bpe {
+	vocabMu.Lock()
+	defer vocabMu.Unlock()
+	if tok, ok := vocabCache[name]; ok {
+		return tok
+	}
+
+	var tok *bpe
+	data, err := vocabFS.ReadFile("vocab/" + name + ".tiktoken")
+	if err != nil {
+		vocabDirMu.RLock()
+		dir := vocabDir
+		vocabDirMu.RUnlock()
+		if dir != "" {
+			// #nosec G304 -- path is from safe home directory structure
+			data, err = os.ReadFile(filepath.Join(dir, name+".tiktoken"))
+		}
+	}
+	if err == nil {
+		ranks, perr := parseTiktoken(bytes.NewReader(data))
+		if perr != nil {
+			logging.Warn("Ignoring invalid tokenizer vocabulary", logging.String("vocab", name), logging.Err(perr))
+		} else {
+			split := splitCL100K
+			if name == VocabO200K {
+				split = splitO200K
+			}
+			tok = &bpe{name: name, ranks: ranks, split: split}
+		}
+	}
+	vocabCache[name] = tok
+	return tok
+}
+
+// parseTiktoken reads a tiktoken rank file
+func parseTiktoken(r io.Reader) (map[string]int, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := bytes.Fields(scanner.Bytes())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected <token> <rank>", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(string(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rank, err := strconv.Atoi(string(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("empty vocabulary")
+	}
+	return ranks, nil
+}
+
+// bpe is a byte-level byte-pair-encoding tokenizer, compatible with tiktoken
+type bpe struct {
+	name  string
+	ranks map[string]int
+	split func(text string, fn func(piece string))
+}
+
+func (b *bpe) Name() string           { return b.name }
+func (b *bpe) Vocabulary() string     { return b.name }
+func (b *bpe) Confidence() Confidence { return ConfidenceHigh }
+
+// Count implements Tokenizer
+func (b *bpe) Count(text string) int {
+	total := 0
+	b.split(text, func(piece string) {
+		if _, ok := b.ranks[piece]; ok {
+			total++
+			return
+		}
+		total += bytePairMerge(piece, b.ranks)
+	})
+	return total
+}
+
+// bytePairMerge repeatedly merges the adjacent pair with the lowest rank and returns
+// how many tokens remain
+func bytePairMerge(piece string, ranks map[string]int) int {
+	if len(piece) <= 1 {
+		return len(piece)
+	}
+
+	// bounds[i] is the start of the i-th part; the last entry is len(piece)
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	rankOf := func(i int) int {
+		if i+2 >= len(bounds) {
+			return math.MaxInt
+		}
+		if rank, ok := ranks[piece[bounds[i]:bounds[i+2]]]; ok {
+			return rank
+		}
+		return math.MaxInt
+	}
+	pairRanks := make([]int, len(bounds)-1)
+	for i := range pairRanks {
+		pairRanks[i] = rankOf(i)
+	}
+
+	for len(bounds) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i < len(pairRanks)-1; i++ {
+			if pairRanks[i] < bestRank {
+				best, bestRank = i, pairRanks[i]
+			}
+		}
+		if best < 0 {
+			break
+		}
+		// Merge parts best and best+1 by dropping the boundary between them
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+		pairRanks = append(pairRanks[:best+1], pairRanks[best+2:]...)
+		pairRanks[best] = rankOf(best)
+		if best > 0 {
+			pairRanks[best-1] = rankOf(best - 1)
+		}
+	}
+	return len(bounds) - 1
+}
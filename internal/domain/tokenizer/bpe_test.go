@@ -0,0 +1,187 @@
+package tokenizer
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testVocab returns ranks for every single byte plus the given merges
+func testVocab(merges ...string) map[string]int {
+	ranks := make(map[string]int, 256+len(merges))
+	for i := 0; i < 256; i++ {
+		ranks[string([]byte{byte(i)})] = i
+	}
+	for i, m := range merges {
+		ranks[m] = 256 + i
+	}
+	return ranks
+}
+
+func collect(split func(string, func(string)), text string) []string {
+	var pieces []string
+	split(text, func(p string) { pieces = append(pieces, p) })
+	return pieces
+}
+
+func TestSplitMatchesTiktokenPatterns(t *testing.T) {
+	tests := []struct {
+		split func(string, func(string))
+		text  string
+		want  []string
+	}{
+		{splitCL100K, "Hello world, this is a test.", []string{"Hello", " world", ",", " this", " is", " a", " test", "."}},
+		{splitCL100K, "I'm  here\n\n  ok", []string{"I", "'m", " ", " here", "\n\n", " ", " ok"}},
+		{splitCL100K, "12345 x", []string{"123", "45", " x"}},
+		{splitCL100K, "HelloWorld's", []string{"HelloWorld", "'s"}},
+		{splitCL100K, "if (a) {\n\treturn\n}", []string{"if", " (", "a", ")", " {\n", "\treturn", "\n", "}"}},
+		{splitO200K, "HelloWorld's", []string{"Hello", "World's"}},
+		{splitO200K, "path/to\n", []string{"path", "/to", "\n"}},
+		{splitO200K, "HTTPServer", []string{"HTTPServer"}},
+	}
+	for _, tt := range tests {
+		if got := collect(tt.split, tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("split(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestBytePairMerge(t *testing.T) {
+	tok := &bpe{name: VocabCL100K, ranks: testVocab("ab", "bc", "abc", " ab"), split: splitCL100K}
+	tests := map[string]int{
+		"abc":     1, // ab, then abc
+		"abd":     2, // ab + d
+		"abc abc": 3, // "abc" + " ab" + "c"
+		"xyz":     3,
+	}
+	for text, want := range tests {
+		if got := tok.Count(text); got != want {
+			t.Errorf("Count(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestEmbeddedVocabularies(t *testing.T) {
+	// The hashes tiktoken verifies its downloads against
+	checksums := map[string]string{
+		VocabCL100K: "223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcffe865b2a7",
+		VocabO200K:  "446a9538cb6c348e3516120d7c08b09f57c36495e2acfffe59a5bf8b0cfb1a2d",
+	}
+	for name, want := range checksums {
+		data, err := vocabFS.ReadFile("vocab/" + name + ".tiktoken")
+		if err != nil {
+			t.Fatalf("%s is not embedded: %v", name, err)
+		}
+		if got := fmt.Sprintf("%x", sha256.Sum256(data)); got != want {
+			t.Errorf("%s sha256 = %s, want %s", name, got, want)
+		}
+	}
+
+	if got := ForModel("gpt-4o").Vocabulary(); got != VocabO200K {
+		t.Errorf("ForModel(gpt-4o).Vocabulary() = %q, want %s", got, VocabO200K)
+	}
+	if got := ForModel("gpt-4").Vocabulary(); got != VocabCL100K {
+		t.Errorf("ForModel(gpt-4).Vocabulary() = %q, want %s", got, VocabCL100K)
+	}
+	claude := ForModel("claude-sonnet-4-5")
+	if claude.Vocabulary() != VocabCL100K || claude.Confidence() != ConfidenceMedium {
+		t.Errorf("claude tokenizer = %s (%s)", claude.Name(), claude.Vocabulary())
+	}
+	if EstimateLevel("gpt-4o") != "mapped" {
+		t.Error("BPE-backed counts should be mapped")
+	}
+
+	// Reference counts from tiktoken
+	tests := []struct {
+		model string
+		text  string
+		want  int
+	}{
+		{"gpt-4", "hello world", 2},
+		{"gpt-4", "tiktoken is great!", 6},
+		{"gpt-4", "Hello, world! I'm here.", 8},
+		{"gpt-4", "你好，世界！这是一个测试。日本語のテキストです。한국어 텍스트", 29},
+		{"gpt-4o", "hello world", 2},
+		{"gpt-4o", "Hello, world! I'm here.", 7},
+		{"gpt-4o", "HelloWorld's HTTPServer camelCaseIdentifier don't WON'T they'll", 12},
+		{"gpt-4o", "你好，世界！这是一个测试。日本語のテキストです。한국어 텍스트", 21},
+	}
+	for _, tt := range tests {
+		if got := ForModel(tt.model).Count(tt.text); got != tt.want {
+			t.Errorf("%s Count(%q) = %d, want %d", tt.model, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseTiktokenRejectsMalformedLines(t *testing.T) {
+	if _, err := parseTiktoken(strings.NewReader("aGVsbG8= notanumber\n")); err == nil {
+		t.Error("expected error for a bad rank")
+	}
+	if _, err := parseTiktoken(strings.NewReader("")); err == nil {
+		t.Error("expected error for an empty vocabulary")
+	}
+}
+
+// benchmarkRequest builds a request shaped like a coding agent turn: a long system
+// prompt, a dozen tool definitions and a conversation with code and tool results
+func benchmarkRequest(model string) []byte {
+	code := strings.Repeat("func handle(w http.ResponseWriter, r *http.Request) {\n\tif err := validate(r); err != nil {\n\t\treturn\n\t}\n}\n", 20)
+	var tools, messages []map[string]interface{}
+	for i := 0; i < 12; i++ {
+		tools = append(tools, map[string]interface{}{
+			"name":         fmt.Sprintf("tool_%d", i),
+			"description":  strings.Repeat("Runs an operation against the workspace and returns the result. ", 4),
+			"input_schema": map[string]interface{}{"type": "object", "properties": map[string]interface{}{"path": map[string]string{"type": "string"}}},
+		})
+	}
+	for i := 0; i < 15; i++ {
+		messages = append(messages,
+			map[string]interface{}{"role": "user", "content": "Please refactor the handler below and explain the change.\n" + code},
+			map[string]interface{}{"role": "assistant", "content": []map[string]interface{}{
+				{"type": "text", "text": "Here is the refactored handler with early returns."},
+				{"type": "tool_use", "id": fmt.Sprintf("t%d", i), "name": "tool_1", "input": map[string]string{"path": "main.go"}},
+			}},
+		)
+	}
+	body, _ := json.Marshal(map[string]interface{}{ //nolint:errcheck // static input
+		"model":    model,
+		"system":   strings.Repeat("You are a careful software engineer working in a Go repository. ", 60),
+		"tools":    tools,
+		"messages": messages,
+	})
+	return body
+}
+
+// BenchmarkCountRequest measures the per-request cost the proxy pays to count a
+// Claude prompt, BPE-counted with cl100k and scaled
+func BenchmarkCountRequest(b *testing.B) {
+	body := benchmarkRequest("claude-sonnet-4-5")
+	b.SetBytes(int64(len(body)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := CountRequest(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCountRequestBPE measures the same request for a model with a published
+// vocabulary, counted by the o200k merge path alone
+func BenchmarkCountRequestBPE(b *testing.B) {
+	if tok := ForModel("gpt-4o"); tok.Vocabulary() != VocabO200K {
+		b.Fatalf("gpt-4o counts with %s, not the o200k vocabulary", tok.Name())
+	}
+	loadVocab(VocabO200K) // parse the vocabulary outside the timed loop
+
+	body := benchmarkRequest("gpt-4o")
+	b.SetBytes(int64(len(body)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := CountRequest(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// CalibrationFile holds fitted coefficients, relative to the BobaMixer home
+	CalibrationFile = "tokenizers/calibration.json"
+
+	// MinCalibrationSamples is the fewest exact counts a model needs before it is fitted
+	MinCalibrationSamples = 5
+
+	defaultCodeRatio = 1.3 // code spends more tokens per character than prose
+
+	calibrationMinTokens = 50 // smaller requests are dominated by fixed overheads
+)
+
+// Coefficients are per-model estimation parameters fitted from exact token counts
+type Coefficients struct {
+	CharsPerToken float64 `json:"chars_per_token"` // Prose characters per token
+	CodeRatio     float64 `json:"code_ratio"`      // Tokens per code character relative to prose
+	Samples       int     `json:"samples"`         // Exact counts the fit used
+	Error         float64 `json:"error"`           // Mean absolute relative error on those samples
+}
+
+// Calibration is the on-disk set of fitted coefficients, keyed by model
+type Calibration struct {
+	UpdatedAt time.Time               `json:"updated_at"`
+	Models    map[string]Coefficients `json:"models"`
+}
+
+var coefficients = struct {
+	sync.RWMutex
+	models map[string]Coefficients
+}{}
+
+// LoadCalibration reads fitted coefficients and makes ForModel use them. A missing
+// file is not an error.
+func LoadCalibration(path string) (*Calibration, error) {
+	// #nosec G304 -- path is from safe home directory structure
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Calibration{Models: map[string]Coefficients{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read calibration: %w", err)
+	}
+	var cal Calibration
+	if err := json.Unmarshal(data, &cal); err != nil {
+		return nil, fmt.Errorf("parse calibration: %w", err)
+	}
+	if cal.Models == nil {
+		cal.Models = map[string]Coefficients{}
+	}
+	SetCoefficients(cal.Models)
+	return &cal, nil
+}
+
+// SaveCalibration writes fitted coefficients and makes ForModel use them
+func SaveCalibration(path string, cal *Calibration) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create calibration dir: %w", err)
+	}
+	data, err := json.MarshalIndent(cal, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode calibration: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("write calibration: %w", err)
+	}
+	SetCoefficients(cal.Models)
+	return nil
+}
+
+// SetCoefficients replaces the fitted coefficients used by ForModel
+func SetCoefficients(models map[string]Coefficients) {
+	normalized := make(map[string]Coefficients, len(models))
+	for model, c := range models {
+		if c.CharsPerToken > 0 && c.CodeRatio > 0 {
+			normalized[modelKey(model)] = c
+		}
+	}
+	coefficients.Lock()
+	defer coefficients.Unlock()
+	coefficients.models = normalized
+}
+
+// calibratedFor returns a tokenizer using the model's fitted coefficients, or nil
+func calibratedFor(model string) Tokenizer {
+	coefficients.RLock()
+	defer coefficients.RUnlock()
+	c, ok := coefficients.models[modelKey(model)]
+	if !ok {
+		return nil
+	}
+	return &calibrated{coefficients: c}
+}
+
+// calibrated estimates tokens from character counts with fitted coefficients
+type calibrated struct {
+	coefficients Coefficients
+}
+
+func (c *calibrated) Name() string           { return "calibrated" }
+func (c *calibrated) Vocabulary() string     { return "" }
+func (c *calibrated) Confidence() Confidence { return ConfidenceMedium }
+
+// Count implements Tokenizer
+func (c *calibrated) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	tokens := float64(utf8.RuneCountInString(text)) / c.coefficients.CharsPerToken
+	if looksLikeCode(text) {
+		tokens *= c.coefficients.CodeRatio
+	}
+	return max(1, int(math.Round(tokens)))
+}
+
+// Sample is one prompt the proxy counted locally and the provider counted exactly
+type Sample struct {
+	Chars     int // See RequestCount
+	CodeChars int
+	Overhead  int
+	Estimated int // Local estimate at request time
+	Exact     int // Provider-reported input tokens
+}
+
+// Predict estimates a sample's input tokens with the coefficients
+func (c Coefficients) Predict(s Sample) int {
+	prose := float64(s.Chars - s.CodeChars)
+	code := float64(s.CodeChars) * c.CodeRatio
+	return s.Overhead + int(math.Round((prose+code)/c.CharsPerToken))
+}
+
+// Fit fits characters-per-token for prose and the code/prose ratio to the text
+// tokens of each sample (exact count minus structural overhead). Each sample is
+// weighted by its size so the fit minimizes relative rather than absolute error.
+// ok is false when there are too few usable samples.
+func Fit(samples []Sample) (c Coefficients, ok bool) {
+	type point struct{ prose, code, tokens, weight float64 }
+	var points []point
+	for _, s := range samples {
+		text := s.Exact - s.Overhead
+		if s.Chars <= 0 || s.Exact < calibrationMinTokens || text <= 0 {
+			continue
+		}
+		points = append(points, point{
+			prose:  float64(s.Chars - s.CodeChars),
+			code:   float64(s.CodeChars),
+			tokens: float64(text),
+			weight: 1 / (float64(text) * float64(text)),
+		})
+	}
+	if len(points) < MinCalibrationSamples {
+		return Coefficients{}, false
+	}
+
+	// Weighted least squares for tokens = a*prose + b*code
+	var spp, spc, scc, spt, sct float64
+	for _, p := range points {
+		spp += p.weight * p.prose * p.prose
+		spc += p.weight * p.prose * p.code
+		scc += p.weight * p.code * p.code
+		spt += p.weight * p.prose * p.tokens
+		sct += p.weight * p.code * p.tokens
+	}
+	var a, b float64
+	if det := spp*scc - spc*spc; det > 1e-9*spp*scc && spp > 0 && scc > 0 {
+		a = (spt*scc - sct*spc) / det
+		b = (sct*spp - spt*spc) / det
+	}
+	if a <= 0 || b <= 0 {
+		// Too little variety between code and prose to separate them; fit a single
+		// rate and keep the default code ratio
+		var num, den float64
+		for _, p := range points {
+			x := p.prose + defaultCodeRatio*p.code
+			num += p.weight * x * p.tokens
+			den += p.weight * x * x
+		}
+		if den == 0 || num <= 0 {
+			return Coefficients{}, false
+		}
+		a = num / den
+		b = a * defaultCodeRatio
+	}
+
+	c = Coefficients{CharsPerToken: 1 / a, CodeRatio: b / a}
+	for _, s := range samples {
+		if s.Chars > 0 && s.Exact >= calibrationMinTokens && s.Exact > s.Overhead {
+			c.Samples++
+			c.Error += RelativeError(c.Predict(s), s.Exact)
+		}
+	}
+	c.Error /= float64(c.Samples)
+	return c, true
+}
+
+// RelativeError is |estimate - exact| / exact
+func RelativeError(estimate, exact int) float64 {
+	if exact == 0 {
+		return 0
+	}
+	return math.Abs(float64(estimate-exact)) / float64(exact)
+}
@@ -0,0 +1,82 @@
+package tokenizer
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestFitRecoversCoefficients(t *testing.T) {
+	// Prose at 3.5 chars/token, code costing 1.4x as much, 40 tokens of framing
+	var samples []Sample
+	for i := 1; i <= 12; i++ {
+		chars := 2000 * i
+		code := chars * (i % 4) / 5
+		exact := 40 + int(math.Round((float64(chars-code)+1.4*float64(code))/3.5))
+		samples = append(samples, Sample{Chars: chars, CodeChars: code, Overhead: 40, Exact: exact})
+	}
+
+	c, ok := Fit(samples)
+	if !ok {
+		t.Fatal("Fit() rejected usable samples")
+	}
+	if math.Abs(c.CharsPerToken-3.5) > 0.05 || math.Abs(c.CodeRatio-1.4) > 0.02 {
+		t.Errorf("Fit() = %.3f chars/token, %.3f code ratio; want 3.5, 1.4", c.CharsPerToken, c.CodeRatio)
+	}
+	if c.Samples != 12 || c.Error > 0.01 {
+		t.Errorf("samples = %d, error = %.4f", c.Samples, c.Error)
+	}
+
+	if _, ok := Fit(samples[:MinCalibrationSamples-1]); ok {
+		t.Error("Fit() should need at least MinCalibrationSamples samples")
+	}
+}
+
+func TestFitWithoutCodeKeepsDefaultRatio(t *testing.T) {
+	var samples []Sample
+	for i := 1; i <= 6; i++ {
+		samples = append(samples, Sample{Chars: 1000 * i, Exact: 250 * i})
+	}
+	c, ok := Fit(samples)
+	if !ok || math.Abs(c.CharsPerToken-4) > 0.01 || c.CodeRatio != defaultCodeRatio {
+		t.Errorf("Fit() = %+v, %v; want 4 chars/token with the default code ratio", c, ok)
+	}
+}
+
+func TestCalibrationAppliesToUnpublishedTokenizers(t *testing.T) {
+	t.Cleanup(func() { SetCoefficients(nil) })
+
+	path := filepath.Join(t.TempDir(), CalibrationFile)
+	cal := &Calibration{Models: map[string]Coefficients{
+		"GLM-4.6":           {CharsPerToken: 2, CodeRatio: 1.5, Samples: 10},
+		"claude-sonnet-4-5": {CharsPerToken: 3, CodeRatio: 1.2, Samples: 10},
+		"gpt-4o":            {CharsPerToken: 2, CodeRatio: 1.5, Samples: 10},
+	}}
+	if err := SaveCalibration(path, cal); err != nil {
+		t.Fatalf("SaveCalibration: %v", err)
+	}
+	SetCoefficients(nil)
+	if _, err := LoadCalibration(path); err != nil {
+		t.Fatalf("LoadCalibration: %v", err)
+	}
+
+	if got := ForModel("zhipu/glm-4.6").Name(); got != "calibrated" {
+		t.Errorf("glm tokenizer = %s, want calibrated", got)
+	}
+	if got := ForModel("anthropic/claude-sonnet-4-5").Name(); got != "calibrated" {
+		t.Errorf("claude tokenizer = %s, want calibrated", got)
+	}
+	if got := ForModel("claude-opus-4").Name(); got != "claude-approx" {
+		t.Errorf("uncalibrated claude tokenizer = %s, want claude-approx", got)
+	}
+	if got := NewEstimator("glm-4.6").Estimate("twelve chars"); got != 6 {
+		t.Errorf("calibrated estimate = %d, want 6", got)
+	}
+	if got := ForModel("gpt-4o").Name(); got == "calibrated" {
+		t.Error("published tokenizers should not be replaced by calibration")
+	}
+
+	if _, err := LoadCalibration(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("missing calibration should not be an error: %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+package tokenizer
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens a model's tokenizer produces for text
+type Tokenizer interface {
+	// Name identifies the tokenizer (e.g., "cl100k_base", "approx-claude")
+	Name() string
+	// Count returns the number of tokens in text
+	Count(text string) int
+	// Vocabulary names the BPE vocabulary behind the counts, or "" for the approximation
+	Vocabulary() string
+	// Confidence reports how closely counts track the provider's own tokenizer
+	Confidence() Confidence
+}
+
+// defaultClaudeRatio is Claude tokens per cl100k token until Claude models are
+// calibrated with fitted coefficients (see Fit)
+const defaultClaudeRatio = 1.1
+
+// ForModel returns the tokenizer that best matches the model's vocabulary. The BPE
+// vocabularies are used when available (see SetVocabDir); otherwise counts come
+// from the pre-tokenizer approximation. Models whose tokenizer is not published
+// use coefficients fitted from their own exact counts once calibrated (see Fit).
+func ForModel(model string) Tokenizer {
+	if Calibratable(model) {
+		if tok := calibratedFor(model); tok != nil {
+			return tok
+		}
+	}
+
+	switch family(model) {
+	case familyO200K:
+		if tok := loadVocab(VocabO200K); tok != nil {
+			return tok
+		}
+		return &pretokenizer{name: "approx-o200k", ratio: 0.95, cjkRatio: 0.7}
+	case familyClaude:
+		// Claude's tokenizer is not published; scale cl100k until calibrated
+		if tok := loadVocab(VocabCL100K); tok != nil {
+			return &scaled{name: "claude-approx", base: tok, ratio: defaultClaudeRatio}
+		}
+		return &pretokenizer{name: "approx-claude", ratio: defaultClaudeRatio, cjkRatio: 1.2}
+	case familyOther:
+		// GLM, DeepSeek, Kimi and others publish no tokenizer; cl100k stands in until calibrated
+		if tok := loadVocab(VocabCL100K); tok != nil {
+			return &scaled{name: "cl100k-approx", base: tok, ratio: 1}
+		}
+		return &pretokenizer{name: "approx-cl100k", ratio: 1, cjkRatio: 1}
+	default:
+		if tok := loadVocab(VocabCL100K); tok != nil {
+			return tok
+		}
+		return &pretokenizer{name: "approx-cl100k", ratio: 1, cjkRatio: 1}
+	}
+}
+
+// Calibratable reports whether the model's tokenizer is unpublished, so fitted
+// coefficients replace the default approximation
+func Calibratable(model string) bool {
+	fam := family(model)
+	return fam == familyClaude || fam == familyOther
+}
+
+// CountText counts the tokens in text with the model's tokenizer
+func CountText(model, text string) Estimation {
+	tok := ForModel(model)
+	return Estimation{Tokens: tok.Count(text), Confidence: tok.Confidence()}
+}
+
+// EstimateLevel is the usage_records estimate level for counts from the model's
+// tokenizer: "mapped" when a BPE vocabulary backs them, "heuristic" otherwise
+func EstimateLevel(model string) string {
+	if ForModel(model).Vocabulary() != "" {
+		return "mapped"
+	}
+	return "heuristic"
+}
+
+// Tokenizer families, keyed off the model name
+const (
+	familyCL100K = "cl100k"
+	familyO200K  = "o200k"
+	familyClaude = "claude"
+	familyOther  = "other" // unpublished tokenizers (GLM, DeepSeek, Kimi, ...), counted as cl100k
+)
+
+// modelKey normalizes a model name for lookups
+func modelKey(model string) string {
+	m := strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(m, "/"); i >= 0 {
+		m = m[i+1:] // strip OpenRouter-style vendor prefixes
+	}
+	return m
+}
+
+// family maps a model name to the vocabulary it uses
+func family(model string) string {
+	m := modelKey(model)
+	switch {
+	case strings.Contains(m, "claude"):
+		return familyClaude
+	case strings.HasPrefix(m, "gpt-4o"), strings.HasPrefix(m, "gpt-4.1"), strings.HasPrefix(m, "gpt-4.5"),
+		strings.HasPrefix(m, "gpt-5"), strings.HasPrefix(m, "o1"), strings.HasPrefix(m, "o3"),
+		strings.HasPrefix(m, "o4"), strings.HasPrefix(m, "chatgpt-4o"), strings.HasPrefix(m, "codex"):
+		return familyO200K
+	case strings.HasPrefix(m, "gpt-4"), strings.HasPrefix(m, "gpt-3.5"), strings.HasPrefix(m, "gpt-35"),
+		strings.HasPrefix(m, "text-embedding"):
+		return familyCL100K
+	default:
+		return familyOther
+	}
+}
+
+// pretokenizer approximates byte-pair encoding by splitting text the way the
+// cl100k/o200k pre-tokenizers do (words, number groups, punctuation runs, whitespace)
+// and charging each piece the tokens BPE typically spends on it. It only counts
+// when a build lacks the vocabularies, so its counts are low confidence.
+type pretokenizer struct {
+	name     string
+	ratio    float64 // scales Latin-script counts relative to cl100k
+	cjkRatio float64 // tokens per CJK character
+}
+
+func (p *pretokenizer) Name() string           { return p.name }
+func (p *pretokenizer) Vocabulary() string     { return "" }
+func (p *pretokenizer) Confidence() Confidence { return ConfidenceLow }
+
+// Count implements Tokenizer
+func (p *pretokenizer) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	latin, cjk := pieceTokens(text)
+	total := int(math.Round(latin*p.ratio + cjk*p.cjkRatio))
+	if total == 0 {
+		total = 1
+	}
+	return total
+}
+
+// runeClass groups runes the way the pre-tokenizer regex does
+type runeClass int
+
+const (
+	classSpace runeClass = iota
+	classNewline
+	classLetter
+	classDigit
+	classPunct
+)
+
+func classify(r rune) runeClass {
+	switch {
+	case r == '\n' || r == '\r':
+		return classNewline
+	case unicode.IsSpace(r):
+		return classSpace
+	case unicode.IsLetter(r) || unicode.IsMark(r):
+		return classLetter
+	case unicode.IsDigit(r):
+		return classDigit
+	default:
+		return classPunct
+	}
+}
+
+// pieceTokens splits text into pre-tokenizer pieces and returns the estimated
+// tokens for Latin-script pieces and for CJK characters separately
+func pieceTokens(text string) (latin, cjk float64) {
+	for i := 0; i < len(text); {
+		r, _ := utf8.DecodeRuneInString(text[i:])
+		class := classify(r)
+
+		// Scan the run of runes in the same class
+		j, runes, wide := i, 0, 0
+		for j < len(text) {
+			next, size := utf8.DecodeRuneInString(text[j:])
+			if classify(next) != class {
+				break
+			}
+			if isCJK(next) {
+				wide++
+			}
+			runes++
+			j += size
+		}
+		nextClass := classSpace
+		if j < len(text) {
+			next, _ := utf8.DecodeRuneInString(text[j:])
+			nextClass = classify(next)
+		}
+
+		switch class {
+		case classLetter:
+			cjk += float64(wide)
+			if n := runes - wide; n > 0 {
+				latin += wordTokens(text[i:j], n)
+			}
+		case classDigit:
+			latin += math.Ceil(float64(runes) / 3) // digits are grouped in threes
+		case classNewline:
+			latin++
+		case classSpace:
+			// A single space before a word or symbol is part of that token
+			if runes > 1 || nextClass == classNewline || j == len(text) {
+				latin++
+			}
+		case classPunct:
+			// One leading symbol (".", "_", "'") joins the following word
+			if nextClass == classLetter {
+				runes--
+			}
+			if runes > 0 {
+				latin += math.Ceil(float64(runes) / 2)
+			}
+		}
+		i = j
+	}
+	return latin, cjk
+}
+
+// wordTokens estimates tokens for a run of letters: common words are a single
+// token, longer or rarer words split into pieces of a few characters
+func wordTokens(word string, runes int) float64 {
+	ascii := true
+	for i := 0; i < len(word); i++ {
+		if word[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if !ascii {
+		// Accented Latin, Cyrillic, Greek and similar scripts split more aggressively
+		return math.Ceil(float64(runes) / 2.5)
+	}
+	if runes <= 8 {
+		return 1
+	}
+	return 1 + math.Ceil(float64(runes-8)/4)
+}
+
+// isCJK reports whether r is a Han, Kana or Hangul character
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// scaled multiplies another tokenizer's counts, for models whose vocabulary is
+// close to a published one
+type scaled struct {
+	name  string
+	base  Tokenizer
+	ratio float64
+}
+
+func (s *scaled) Name() string           { return s.name }
+func (s *scaled) Vocabulary() string     { return s.base.Vocabulary() }
+func (s *scaled) Confidence() Confidence { return ConfidenceMedium }
+
+// Count implements Tokenizer
+func (s *scaled) Count(text string) int {
+	n := s.base.Count(text)
+	if n == 0 {
+		return 0
+	}
+	return max(1, int(math.Round(float64(n)*s.ratio)))
+}
@@ -0,0 +1,112 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestForModelFamilies(t *testing.T) {
+	tests := []struct {
+		model      string
+		name       string
+		confidence Confidence
+	}{
+		{"gpt-4", VocabCL100K, ConfidenceHigh},
+		{"gpt-4o-mini", VocabO200K, ConfidenceHigh},
+		{"openai/o3-mini", VocabO200K, ConfidenceHigh},
+		// Unpublished tokenizers are approximated, not counted exactly
+		{"claude-sonnet-4-5", "claude-approx", ConfidenceMedium},
+		{"anthropic/claude-3.5-sonnet", "claude-approx", ConfidenceMedium},
+		{"glm-4.6", "cl100k-approx", ConfidenceMedium},
+		{"deepseek-chat", "cl100k-approx", ConfidenceMedium},
+	}
+	for _, tt := range tests {
+		tok := ForModel(tt.model)
+		if tok.Name() != tt.name || tok.Confidence() != tt.confidence {
+			t.Errorf("ForModel(%q) = %s (%s), want %s (%s)", tt.model, tok.Name(), tok.Confidence(), tt.name, tt.confidence)
+		}
+	}
+	if got := (&pretokenizer{name: "approx-cl100k", ratio: 1, cjkRatio: 1}).Confidence(); got != ConfidenceLow {
+		t.Errorf("pre-tokenizer approximation confidence = %s, want low", got)
+	}
+}
+
+func TestPretokenizerCount(t *testing.T) {
+	// The fallback for builds without the vocabularies
+	tok := &pretokenizer{name: "approx-cl100k", ratio: 1, cjkRatio: 1}
+	tests := []struct {
+		text string
+		want int // cl100k_base reference count
+	}{
+		{"Hello world, this is a test.", 8},
+		{"The quick brown fox jumps over the lazy dog.", 10},
+		{"1234567", 3},
+		{"func main() {\n\tfmt.Println(\"hi\")\n}", 13},
+	}
+	for _, tt := range tests {
+		got := tok.Count(tt.text)
+		// The approximation should land within 25% of the real tokenizer
+		if diff := float64(got-tt.want) / float64(tt.want); diff < -0.25 || diff > 0.25 {
+			t.Errorf("Count(%q) = %d, want about %d", tt.text, got, tt.want)
+		}
+	}
+	if tok.Count("") != 0 {
+		t.Error("empty text should have no tokens")
+	}
+	if got := tok.Count("你好世界"); got != 4 {
+		t.Errorf("CJK count = %d, want 4", got)
+	}
+}
+
+func TestCountRequestAnthropic(t *testing.T) {
+	body := `{
+		"model": "claude-sonnet-4-5",
+		"system": [{"type": "text", "text": "You are a helpful assistant."}],
+		"tools": [{"name": "get_weather", "description": "Get the weather", "input_schema": {"type": "object"}}],
+		"messages": [
+			{"role": "user", "content": "What is the weather in Paris?"},
+			{"role": "assistant", "content": [{"type": "tool_use", "id": "t1", "name": "get_weather", "input": {"city": "Paris"}}]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "t1", "content": [{"type": "text", "text": "Sunny, 22C"}]},
+				{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "AAAA"}}
+			]}
+		]
+	}`
+	count, err := CountRequest([]byte(body))
+	if err != nil {
+		t.Fatalf("CountRequest: %v", err)
+	}
+	if count.Tokenizer != "claude-approx" || count.Attachments != 1 || count.Confidence != ConfidenceLow {
+		t.Errorf("unexpected count metadata %+v", count)
+	}
+	// Text, tool schema and tool call are small; the image and tool prompt dominate
+	if count.Tokens < imageTokens+toolSystemPrompt+30 || count.Tokens > imageTokens+toolSystemPrompt+120 {
+		t.Errorf("Tokens = %d", count.Tokens)
+	}
+}
+
+func TestCountRequestOpenAI(t *testing.T) {
+	short := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+	long := `{"model":"gpt-4o","messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"` +
+		strings.Repeat("tell me more ", 50) + `"},{"role":"assistant","tool_calls":[{"type":"function","function":{"name":"search","arguments":"{\"q\":\"go\"}"}}]}]}`
+
+	s, err := CountRequest([]byte(short))
+	if err != nil {
+		t.Fatalf("CountRequest: %v", err)
+	}
+	if s.Tokens != requestOverhead+messageOverhead+1 || s.Confidence != ConfidenceHigh {
+		t.Errorf("short request = %+v", s)
+	}
+
+	l, err := CountRequest([]byte(long))
+	if err != nil {
+		t.Fatalf("CountRequest: %v", err)
+	}
+	if l.Tokens < 140 || l.Tokens > 190 {
+		t.Errorf("long request tokens = %d", l.Tokens)
+	}
+
+	if _, err := CountRequest([]byte("not json")); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
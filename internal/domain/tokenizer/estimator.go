@@ -1,10 +1,7 @@
 // Package tokenizer provides token estimation for various language models.
 package tokenizer
 
-import (
-	"strings"
-	"unicode"
-)
+import "strings"
 
 // Estimator provides token estimation for text
 type Estimator struct {
@@ -16,112 +13,12 @@ func NewEstimator(model string) *Estimator {
 	return &Estimator{model: model}
 }
 
-// Estimate estimates the number of tokens in the given text
-// This is a heuristic estimation based on common tokenization patterns
+// Estimate estimates the number of tokens in the given text with the model's tokenizer
 func (e *Estimator) Estimate(text string) int {
 	if len(text) == 0 {
 		return 0
 	}
-
-	// Different models have different tokenization strategies
-	// For now, we use a general approximation
-	switch {
-	case strings.Contains(strings.ToLower(e.model), "gpt"):
-		return e.estimateGPTTokens(text)
-	case strings.Contains(strings.ToLower(e.model), "claude"):
-		return e.estimateClaudeTokens(text)
-	default:
-		return e.estimateGenericTokens(text)
-	}
-}
-
-// estimateGPTTokens estimates tokens for GPT models
-// Rule of thumb: ~4 characters per token for English text
-func (e *Estimator) estimateGPTTokens(text string) int {
-	// Count words and special characters
-	words := countWords(text)
-	specialChars := countSpecialChars(text)
-
-	// GPT typically: ~0.75 tokens per word + special char overhead
-	tokens := int(float64(words)*0.85) + (specialChars / 3)
-
-	// Add overhead for code (more special characters)
-	if looksLikeCode(text) {
-		tokens = int(float64(tokens) * 1.3)
-	}
-
-	// Minimum of 1 token for non-empty text
-	if tokens == 0 && len(text) > 0 {
-		tokens = 1
-	}
-
-	return tokens
-}
-
-// estimateClaudeTokens estimates tokens for Claude models
-// Similar to GPT but slightly different ratios
-func (e *Estimator) estimateClaudeTokens(text string) int {
-	words := countWords(text)
-	specialChars := countSpecialChars(text)
-
-	// Claude: ~0.75 tokens per word
-	tokens := int(float64(words)*0.85) + (specialChars / 3)
-
-	if looksLikeCode(text) {
-		tokens = int(float64(tokens) * 1.3)
-	}
-
-	// Minimum of 1 token for non-empty text
-	if tokens == 0 && len(text) > 0 {
-		tokens = 1
-	}
-
-	return tokens
-}
-
-// estimateGenericTokens provides a generic estimation
-func (e *Estimator) estimateGenericTokens(text string) int {
-	// Generic: ~4 characters per token
-	charCount := len([]rune(text))
-	tokens := charCount / 4
-	if tokens == 0 && len(text) > 0 {
-		tokens = 1
-	}
-	return tokens
-}
-
-// countWords counts the number of words in text
-func countWords(text string) int {
-	words := 0
-	inWord := false
-
-	for _, r := range text {
-		if unicode.IsSpace(r) || unicode.IsPunct(r) {
-			if inWord {
-				words++
-				inWord = false
-			}
-		} else {
-			inWord = true
-		}
-	}
-
-	if inWord {
-		words++
-	}
-
-	return words
-}
-
-// countSpecialChars counts special characters (punctuation, brackets, etc.)
-func countSpecialChars(text string) int {
-	count := 0
-	for _, r := range text {
-		if unicode.IsPunct(r) || unicode.IsSymbol(r) {
-			count++
-		}
-	}
-	return count
+	return ForModel(e.model).Count(text)
 }
 
 // looksLikeCode detects if text looks like code
@@ -174,21 +71,21 @@ type Estimation struct {
 
 // EstimateWithConfidence provides estimation with confidence level
 func (e *Estimator) EstimateWithConfidence(text string) Estimation {
-	tokens := e.Estimate(text)
-
-	// Confidence depends on text characteristics
-	confidence := ConfidenceMedium
-
-	if looksLikeCode(text) {
-		// Code is harder to estimate accurately
-		confidence = ConfidenceLow
-	} else if len(text) < 30 {
-		// Short text is easier
-		confidence = ConfidenceHigh
+	tok := ForModel(e.model)
+	confidence := tok.Confidence()
+
+	// Without the model's exact vocabulary, code is harder to estimate and short
+	// text leaves little room for error
+	if confidence != ConfidenceHigh {
+		if looksLikeCode(text) {
+			confidence = ConfidenceLow
+		} else if len(text) < 30 {
+			confidence = ConfidenceHigh
+		}
 	}
 
 	return Estimation{
-		Tokens:     tokens,
+		Tokens:     tok.Count(text),
 		Confidence: confidence,
 	}
 }
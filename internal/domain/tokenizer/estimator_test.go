@@ -105,49 +105,6 @@ func TestLooksLikeCode(t *testing.T) {
 	}
 }
 
-func TestCountWords(t *testing.T) {
-	tests := []struct {
-		name     string
-		text     string
-		expected int
-	}{
-		{
-			name:     "simple sentence",
-			text:     "hello world",
-			expected: 2,
-		},
-		{
-			name:     "with punctuation",
-			text:     "Hello, world!",
-			expected: 2,
-		},
-		{
-			name:     "empty string",
-			text:     "",
-			expected: 0,
-		},
-		{
-			name:     "multiple spaces",
-			text:     "hello    world",
-			expected: 2,
-		},
-		{
-			name:     "with numbers",
-			text:     "test 123 456",
-			expected: 3,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := countWords(tt.text)
-			if result != tt.expected {
-				t.Errorf("countWords() = %d, want %d", result, tt.expected)
-			}
-		})
-	}
-}
-
 func TestEstimatePair(t *testing.T) {
 	estimator := NewEstimator("gpt-4")
 
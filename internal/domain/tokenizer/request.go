@@ -0,0 +1,206 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Fixed costs of the chat formats around the text itself
+const (
+	requestOverhead  = 3    // reply priming
+	messageOverhead  = 4    // role and message delimiters
+	toolOverhead     = 8    // per tool definition
+	toolSystemPrompt = 346  // tool-use system prompt Anthropic adds when tools are defined
+	imageTokens      = 1600 // typical image after provider resizing
+	documentTokens   = 1600 // per attached document, page count unknown
+)
+
+// RequestCount is the input token count of a chat request
+type RequestCount struct {
+	Estimation
+	Tokenizer   string // Tokenizer used (see Tokenizer.Name)
+	Attachments int    // Images and documents, counted at a flat rate
+
+	// Prompt size, recorded beside exact counts for calibration (see Fit)
+	Chars     int // Characters of text counted
+	CodeChars int // Of which in blocks that look like code
+	Overhead  int // Tokens for message framing, tool prompts and attachments rather than text
+}
+
+// CountRequest counts the input tokens of an Anthropic Messages, OpenAI Chat
+// Completions or OpenAI Responses request body, including system prompts, tool
+// definitions, tool calls and tool results
+func CountRequest(body []byte) (RequestCount, error) {
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return RequestCount{}, fmt.Errorf("parse request: %w", err)
+	}
+	model, _ := req["model"].(string) //nolint:errcheck // missing model uses the default tokenizer
+	c := &requestCounter{tok: ForModel(model)}
+
+	total := requestOverhead
+	total += c.content(req["system"])
+	total += c.content(req["instructions"])
+	total += c.messages(req["messages"])
+	if input, ok := req["input"].(string); ok {
+		total += messageOverhead + c.count(input)
+	} else {
+		total += c.messages(req["input"])
+	}
+
+	if tools, ok := req["tools"].([]interface{}); ok && len(tools) > 0 {
+		for _, tool := range tools {
+			total += toolOverhead + c.json(tool)
+		}
+		if family(model) == familyClaude {
+			total += toolSystemPrompt
+		}
+	}
+
+	confidence := c.tok.Confidence()
+	if c.attachments > 0 {
+		confidence = ConfidenceLow
+	}
+	return RequestCount{
+		Estimation:  Estimation{Tokens: total, Confidence: confidence},
+		Tokenizer:   c.tok.Name(),
+		Attachments: c.attachments,
+		Chars:       c.chars,
+		CodeChars:   c.codeChars,
+		Overhead:    total - c.textTokens,
+	}, nil
+}
+
+// requestCounter walks a request body counting tokens
+type requestCounter struct {
+	tok         Tokenizer
+	attachments int
+	chars       int
+	codeChars   int
+	textTokens  int
+}
+
+// count counts one piece of text and tallies its size
+func (c *requestCounter) count(text string) int {
+	n := utf8.RuneCountInString(text)
+	c.chars += n
+	if looksLikeCode(text) {
+		c.codeChars += n
+	}
+	tokens := c.tok.Count(text)
+	c.textTokens += tokens
+	return tokens
+}
+
+// messages counts a message list, charging the per-message overhead
+func (c *requestCounter) messages(v interface{}) int {
+	list, ok := v.([]interface{})
+	if !ok {
+		return 0
+	}
+	total := 0
+	for _, item := range list {
+		msg, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, hasRole := msg["role"]; !hasRole {
+			// Responses API items such as function_call and function_call_output
+			total += c.block(msg)
+			continue
+		}
+		total += messageOverhead
+		total += c.content(msg["content"])
+		if name, ok := msg["name"].(string); ok {
+			total += c.count(name)
+		}
+		if calls, ok := msg["tool_calls"].([]interface{}); ok {
+			for _, call := range calls {
+				total += c.block(call)
+			}
+		}
+	}
+	return total
+}
+
+// content counts a string or a list of content blocks
+func (c *requestCounter) content(v interface{}) int {
+	switch content := v.(type) {
+	case string:
+		return c.count(content)
+	case []interface{}:
+		total := 0
+		for _, block := range content {
+			total += c.block(block)
+		}
+		return total
+	case map[string]interface{}:
+		return c.block(content)
+	default:
+		return 0
+	}
+}
+
+// block counts one content block of any of the supported APIs
+func (c *requestCounter) block(v interface{}) int {
+	block, ok := v.(map[string]interface{})
+	if !ok {
+		if s, ok := v.(string); ok {
+			return c.count(s)
+		}
+		return 0
+	}
+
+	typ, _ := block["type"].(string) //nolint:errcheck // untyped blocks fall through to the default
+	switch typ {
+	case "text", "input_text", "output_text":
+		text, _ := block["text"].(string) //nolint:errcheck // missing text counts as empty
+		return c.count(text)
+	case "thinking":
+		text, _ := block["thinking"].(string) //nolint:errcheck // missing text counts as empty
+		return c.count(text)
+	case "redacted_thinking":
+		return 0
+	case "image", "image_url", "input_image":
+		c.attachments++
+		return imageTokens
+	case "document", "file", "input_file":
+		c.attachments++
+		return documentTokens
+	case "tool_use", "server_tool_use":
+		name, _ := block["name"].(string) //nolint:errcheck // missing name counts as empty
+		return c.count(name) + c.json(block["input"])
+	case "tool_result", "function_call_output":
+		if output, ok := block["output"]; ok {
+			return c.content(output)
+		}
+		return c.content(block["content"])
+	case "function", "function_call":
+		// OpenAI tool calls nest name/arguments under "function"; Responses items do not
+		fn := block
+		if nested, ok := block["function"].(map[string]interface{}); ok {
+			fn = nested
+		}
+		name, _ := fn["name"].(string)      //nolint:errcheck // missing name counts as empty
+		args, _ := fn["arguments"].(string) //nolint:errcheck // missing arguments count as empty
+		return c.count(name) + c.count(args)
+	default:
+		if _, ok := block["role"]; ok {
+			return c.messages([]interface{}{block})
+		}
+		return c.json(block)
+	}
+}
+
+// json counts the compact JSON encoding of a value (tool schemas and inputs)
+func (c *requestCounter) json(v interface{}) int {
+	if v == nil {
+		return 0
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return c.count(string(data))
+}
@@ -0,0 +1,188 @@
+package tokenizer
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// splitCL100K splits text into the pieces the cl100k_base pre-tokenizer produces:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func splitCL100K(text string, fn func(piece string)) {
+	for i := 0; i < len(text); {
+		n := contractionLen(text[i:])
+		if n == 0 {
+			n = prefixedRun(text[i:], isLetter)
+		}
+		if n == 0 {
+			n = digitsLen(text[i:])
+		}
+		if n == 0 {
+			n = punctLen(text[i:], "\r\n")
+		}
+		if n == 0 {
+			n = spaceLen(text[i:])
+		}
+		fn(text[i : i+n])
+		i += n
+	}
+}
+
+// splitO200K splits text into the pieces the o200k_base pre-tokenizer produces.
+// Unlike cl100k it splits camelCase words and attaches contractions to the word:
+//
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?
+//	|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?
+//	|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func splitO200K(text string, fn func(piece string)) {
+	for i := 0; i < len(text); {
+		n := casedWordLen(text[i:])
+		if n > 0 {
+			n += contractionLen(text[i+n:])
+		}
+		if n == 0 {
+			n = digitsLen(text[i:])
+		}
+		if n == 0 {
+			n = punctLen(text[i:], "\r\n/")
+		}
+		if n == 0 {
+			n = spaceLen(text[i:])
+		}
+		fn(text[i : i+n])
+		i += n
+	}
+}
+
+func isLetter(r rune) bool { return unicode.IsLetter(r) }
+func isNumber(r rune) bool { return unicode.IsNumber(r) }
+
+// isUpperish matches [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]
+func isUpperish(r rune) bool {
+	return unicode.In(r, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isLowerish matches [\p{Ll}\p{Lm}\p{Lo}\p{M}]
+func isLowerish(r rune) bool {
+	return unicode.In(r, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isPrefix matches [^\r\n\p{L}\p{N}]
+func isPrefix(r rune) bool {
+	return r != '\r' && r != '\n' && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// contractionLen matches (?i:'s|'t|'re|'ve|'m|'ll|'d)
+func contractionLen(s string) int {
+	if len(s) < 2 || s[0] != '\'' {
+		return 0
+	}
+	lower := strings.ToLower(s[1:min(len(s), 3)])
+	for _, suffix := range []string{"re", "ve", "ll"} {
+		if strings.HasPrefix(lower, suffix) {
+			return 3
+		}
+	}
+	switch lower[0] {
+	case 's', 't', 'm', 'd':
+		return 2
+	}
+	return 0
+}
+
+// runLen returns the byte length of the leading run of runes matching class
+func runLen(s string, class func(rune) bool) int {
+	n := 0
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if !class(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+// prefixedRun matches [^\r\n\p{L}\p{N}]?class+
+func prefixedRun(s string, class func(rune) bool) int {
+	if n := runLen(s, class); n > 0 {
+		return n
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if !isPrefix(r) {
+		return 0
+	}
+	if n := runLen(s[size:], class); n > 0 {
+		return size + n
+	}
+	return 0
+}
+
+// casedWordLen matches the two o200k word alternatives, including the optional prefix
+func casedWordLen(s string) int {
+	start := 0
+	if r, size := utf8.DecodeRuneInString(s); !isUpperish(r) && !isLowerish(r) {
+		if !isPrefix(r) {
+			return 0
+		}
+		start = size
+	}
+	upper := runLen(s[start:], isUpperish)
+	lower := runLen(s[start+upper:], isLowerish)
+	if upper+lower == 0 {
+		return 0
+	}
+	return start + upper + lower
+}
+
+// digitsLen matches \p{N}{1,3}
+func digitsLen(s string) int {
+	n := 0
+	for count := 0; count < 3 && n < len(s); count++ {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if !isNumber(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+// punctLen matches ` ?[^\s\p{L}\p{N}]+[<trailing>]*`
+func punctLen(s, trailing string) int {
+	start := 0
+	if strings.HasPrefix(s, " ") {
+		start = 1
+	}
+	n := runLen(s[start:], func(r rune) bool {
+		return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if n == 0 {
+		return 0
+	}
+	end := start + n
+	end += runLen(s[end:], func(r rune) bool { return strings.ContainsRune(trailing, r) })
+	return end
+}
+
+// spaceLen matches `\s*[\r\n]+|\s+(?!\S)|\s+`, or a single rune when nothing else matches
+func spaceLen(s string) int {
+	n := runLen(s, unicode.IsSpace)
+	if n == 0 {
+		_, size := utf8.DecodeRuneInString(s)
+		return size
+	}
+	// \s*[\r\n]+ ends at the last newline of the whitespace run
+	if last := strings.LastIndexAny(s[:n], "\r\n"); last >= 0 {
+		return last + 1
+	}
+	// \s+(?!\S) leaves the final space to prefix the next word
+	if n < len(s) {
+		_, size := utf8.DecodeLastRuneInString(s[:n])
+		if n > size {
+			return n - size
+		}
+	}
+	return n
+}
@@ -26,9 +26,13 @@ type Record struct {
 	OutputTokens int
 }
 
-// NewRecord creates a new usage record
-func NewRecord(sessionID, tool, model string, result adapters.Result, pricingTable *pricing.Table, profileCost config.Cost) *Record {
-	inputCost, outputCost := pricingTable.CalculateCost(model, profileCost, result.Usage.InputTokens, result.Usage.OutputTokens)
+// NewRecord creates a new usage record. It fails when the model's price
+// cannot be converted to US dollars.
+func NewRecord(sessionID, tool, model string, result adapters.Result, pricingTable *pricing.Table, profileCost config.Cost) (*Record, error) {
+	inputCost, outputCost, err := pricingTable.CalculateCost(model, profileCost, result.Usage.InputTokens, result.Usage.OutputTokens)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Record{
 		ID:           generateID(),
@@ -40,7 +44,7 @@ func NewRecord(sessionID, tool, model string, result adapters.Result, pricingTab
 		OutputCost:   outputCost,
 		Tool:         tool,
 		Model:        model,
-	}
+	}, nil
 }
 
 // generateID generates a random record ID
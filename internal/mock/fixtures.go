@@ -0,0 +1,171 @@
+// Package mock implements a local stand-in for the OpenAI, Anthropic and Gemini APIs,
+// answering from scripted fixtures or recorded proxy transcripts with optional fault injection.
+package mock
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Protocol identifies the API shape of a request
+type Protocol string
+
+// Supported protocols
+const (
+	ProtocolChat      Protocol = "chat"      // OpenAI /v1/chat/completions
+	ProtocolResponses Protocol = "responses" // OpenAI /v1/responses
+	ProtocolMessages  Protocol = "messages"  // Anthropic /v1/messages
+	ProtocolGemini    Protocol = "gemini"    // Gemini generateContent
+)
+
+// Config represents a fixtures file (default ~/.boba/mock.yaml)
+type Config struct {
+	Version int       `yaml:"version"`
+	Rules   []Rule    `yaml:"rules,omitempty"`   // Checked in order; the first match answers
+	Default *Response `yaml:"default,omitempty"` // Answer when nothing matches
+	Faults  []Fault   `yaml:"faults,omitempty"`  // Errors injected before any rule is consulted
+}
+
+// Rule answers requests whose last user message matches Match
+type Rule struct {
+	Match     string     `yaml:"match"`               // Regular expression over the last user message
+	Protocols []Protocol `yaml:"protocols,omitempty"` // Restrict to these protocols (empty = all)
+	Model     string     `yaml:"model,omitempty"`     // Glob over the requested model
+	Response  Response   `yaml:"response"`
+	Fault     *Fault     `yaml:"fault,omitempty"` // Error to inject for matching requests
+
+	pattern *regexp.Regexp
+}
+
+// Response is a scripted assistant turn
+type Response struct {
+	Text      string     `yaml:"text,omitempty"`
+	ToolCalls []ToolCall `yaml:"tool_calls,omitempty"`
+	DelayMS   int        `yaml:"delay_ms,omitempty"` // Added before the first byte is written
+	Usage     *Usage     `yaml:"usage,omitempty"`    // Overrides the estimated token counts
+}
+
+// ToolCall is a function call the mock asks the client to run
+type ToolCall struct {
+	Name      string         `yaml:"name"`
+	Arguments map[string]any `yaml:"arguments,omitempty"`
+}
+
+// Usage overrides the reported token counts
+type Usage struct {
+	InputTokens  int `yaml:"input_tokens"`
+	OutputTokens int `yaml:"output_tokens"`
+}
+
+// Fault is an error injected with a probability
+type Fault struct {
+	Status      int           `yaml:"status,omitempty"`  // HTTP status, e.g. 429, 500, 529
+	Timeout     time.Duration `yaml:"timeout,omitempty"` // Hang this long, then drop the connection
+	Probability float64       `yaml:"probability"`       // 0..1
+}
+
+// defaultResponse answers when neither rules nor transcripts match
+var defaultResponse = Response{Text: "This is a mock response from BobaMixer."}
+
+// Load reads a fixtures file. A missing file yields an empty configuration.
+func Load(file string) (*Config, error) {
+	data, err := os.ReadFile(file) // #nosec G304 -- fixtures path chosen by the user
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{Version: 1}, nil
+		}
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures: %w", err)
+	}
+	if err := config.Compile(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Compile validates rules and faults and compiles match patterns
+func (c *Config) Compile() error {
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return fmt.Errorf("mock rule %d: invalid match: %w", i+1, err)
+		}
+		rule.pattern = re
+		if rule.Model != "" {
+			if _, err := path.Match(rule.Model, ""); err != nil {
+				return fmt.Errorf("mock rule %d: invalid model glob: %w", i+1, err)
+			}
+		}
+		for _, p := range rule.Protocols {
+			if !validProtocol(p) {
+				return fmt.Errorf("mock rule %d: unknown protocol %q (use chat, responses, messages or gemini)", i+1, p)
+			}
+		}
+		if rule.Fault != nil {
+			if err := rule.Fault.validate(); err != nil {
+				return fmt.Errorf("mock rule %d: %w", i+1, err)
+			}
+		}
+	}
+	for i := range c.Faults {
+		if err := c.Faults[i].validate(); err != nil {
+			return fmt.Errorf("mock fault %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// match returns the first rule answering the request, if any
+func (c *Config) match(req *parsedRequest) *Rule {
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if len(rule.Protocols) > 0 && !containsProtocol(rule.Protocols, req.protocol) {
+			continue
+		}
+		if rule.Model != "" {
+			if ok, _ := path.Match(rule.Model, req.model); !ok { //nolint:errcheck // pattern validated in Compile
+				continue
+			}
+		}
+		if rule.pattern == nil || rule.pattern.MatchString(req.prompt) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (f *Fault) validate() error {
+	if f.Probability < 0 || f.Probability > 1 {
+		return fmt.Errorf("probability must be between 0 and 1, got %g", f.Probability)
+	}
+	if f.Status == 0 && f.Timeout <= 0 {
+		return fmt.Errorf("fault needs a status or a timeout")
+	}
+	if f.Status != 0 && (f.Status < 400 || f.Status > 599) {
+		return fmt.Errorf("fault status must be a 4xx or 5xx code, got %d", f.Status)
+	}
+	return nil
+}
+
+func validProtocol(p Protocol) bool {
+	return p == ProtocolChat || p == ProtocolResponses || p == ProtocolMessages || p == ProtocolGemini
+}
+
+func containsProtocol(list []Protocol, p Protocol) bool {
+	for _, item := range list {
+		if item == p {
+			return true
+		}
+	}
+	return false
+}
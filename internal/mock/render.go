@@ -0,0 +1,516 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// reply is a scripted response resolved for one request
+type reply struct {
+	id      string
+	model   string
+	text    string
+	calls   []ToolCall
+	usage   Usage
+	callID  func(i int) string
+	created int64
+}
+
+// sseWriter writes server-sent events, flushing after each one
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher) //nolint:errcheck // unbuffered writers simply don't flush
+	return &sseWriter{w: w, flusher: flusher}
+}
+
+// event writes one event; an empty name writes a data-only event
+func (s *sseWriter) event(name string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	if name != "" {
+		fmt.Fprintf(s.w, "event: %s\n", name) //nolint:errcheck // client disconnects end the stream
+	}
+	fmt.Fprintf(s.w, "data: %s\n\n", data) //nolint:errcheck // client disconnects end the stream
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+func (s *sseWriter) raw(line string) {
+	fmt.Fprintf(s.w, "data: %s\n\n", line) //nolint:errcheck // client disconnects end the stream
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload) //nolint:errcheck // client disconnects are not actionable
+}
+
+// chunks splits text into word-sized deltas for streaming
+func chunks(text string) []string {
+	if text == "" {
+		return nil
+	}
+	var out []string
+	start := 0
+	for i := 1; i < len(text); i++ {
+		if text[i] == ' ' {
+			out = append(out, text[start:i])
+			start = i
+		}
+	}
+	return append(out, text[start:])
+}
+
+func argumentsJSON(call ToolCall) string {
+	args := call.Arguments
+	if args == nil {
+		args = map[string]any{}
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// render writes the reply in the request's protocol
+func render(w http.ResponseWriter, req *parsedRequest, r *reply) {
+	switch req.protocol {
+	case ProtocolChat:
+		if req.stream {
+			streamChat(w, req, r)
+		} else {
+			writeJSON(w, http.StatusOK, chatCompletion(r))
+		}
+	case ProtocolResponses:
+		if req.stream {
+			streamResponses(w, r)
+		} else {
+			writeJSON(w, http.StatusOK, responseObject(r))
+		}
+	case ProtocolMessages:
+		if req.stream {
+			streamMessages(w, r)
+		} else {
+			writeJSON(w, http.StatusOK, anthropicMessage(r, true))
+		}
+	case ProtocolGemini:
+		if req.stream {
+			streamGemini(w, r)
+		} else {
+			writeJSON(w, http.StatusOK, geminiResponse(r, r.text, r.calls, true))
+		}
+	}
+}
+
+// --- OpenAI chat completions ---
+
+func chatToolCalls(r *reply) []map[string]any {
+	calls := make([]map[string]any, 0, len(r.calls))
+	for i, call := range r.calls {
+		calls = append(calls, map[string]any{
+			"id":   r.callID(i),
+			"type": "function",
+			"function": map[string]any{
+				"name":      call.Name,
+				"arguments": argumentsJSON(call),
+			},
+		})
+	}
+	return calls
+}
+
+func chatFinishReason(r *reply) string {
+	if len(r.calls) > 0 {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+func chatUsage(r *reply) map[string]any {
+	return map[string]any{
+		"prompt_tokens":     r.usage.InputTokens,
+		"completion_tokens": r.usage.OutputTokens,
+		"total_tokens":      r.usage.InputTokens + r.usage.OutputTokens,
+	}
+}
+
+func chatCompletion(r *reply) map[string]any {
+	message := map[string]any{"role": "assistant", "content": nil}
+	if r.text != "" {
+		message["content"] = r.text
+	}
+	if len(r.calls) > 0 {
+		message["tool_calls"] = chatToolCalls(r)
+	}
+	return map[string]any{
+		"id":      r.id,
+		"object":  "chat.completion",
+		"created": r.created,
+		"model":   r.model,
+		"choices": []any{map[string]any{
+			"index":         0,
+			"message":       message,
+			"finish_reason": chatFinishReason(r),
+		}},
+		"usage": chatUsage(r),
+	}
+}
+
+func streamChat(w http.ResponseWriter, req *parsedRequest, r *reply) {
+	sse := newSSEWriter(w)
+	chunk := func(delta map[string]any, finish any) map[string]any {
+		return map[string]any{
+			"id":      r.id,
+			"object":  "chat.completion.chunk",
+			"created": r.created,
+			"model":   r.model,
+			"choices": []any{map[string]any{"index": 0, "delta": delta, "finish_reason": finish}},
+		}
+	}
+
+	sse.event("", chunk(map[string]any{"role": "assistant", "content": ""}, nil))
+	for _, piece := range chunks(r.text) {
+		sse.event("", chunk(map[string]any{"content": piece}, nil))
+	}
+	for i, call := range r.calls {
+		sse.event("", chunk(map[string]any{"tool_calls": []any{map[string]any{
+			"index":    i,
+			"id":       r.callID(i),
+			"type":     "function",
+			"function": map[string]any{"name": call.Name, "arguments": ""},
+		}}}, nil))
+		sse.event("", chunk(map[string]any{"tool_calls": []any{map[string]any{
+			"index":    i,
+			"function": map[string]any{"arguments": argumentsJSON(call)},
+		}}}, nil))
+	}
+	sse.event("", chunk(map[string]any{}, chatFinishReason(r)))
+	if req.includeUsage {
+		final := chunk(nil, nil)
+		final["choices"] = []any{}
+		final["usage"] = chatUsage(r)
+		sse.event("", final)
+	}
+	sse.raw("[DONE]")
+}
+
+// --- OpenAI responses ---
+
+func responseItems(r *reply) []map[string]any {
+	var items []map[string]any
+	if r.text != "" {
+		items = append(items, map[string]any{
+			"type":   "message",
+			"id":     "msg_" + strings.TrimPrefix(r.id, "resp_"),
+			"status": "completed",
+			"role":   "assistant",
+			"content": []any{map[string]any{
+				"type":        "output_text",
+				"text":        r.text,
+				"annotations": []any{},
+			}},
+		})
+	}
+	for i, call := range r.calls {
+		items = append(items, map[string]any{
+			"type":      "function_call",
+			"id":        fmt.Sprintf("fc_%s_%d", strings.TrimPrefix(r.id, "resp_"), i),
+			"call_id":   r.callID(i),
+			"name":      call.Name,
+			"arguments": argumentsJSON(call),
+			"status":    "completed",
+		})
+	}
+	return items
+}
+
+func responseObject(r *reply) map[string]any {
+	return map[string]any{
+		"id":         r.id,
+		"object":     "response",
+		"created_at": r.created,
+		"status":     "completed",
+		"model":      r.model,
+		"output":     responseItems(r),
+		"usage": map[string]any{
+			"input_tokens":  r.usage.InputTokens,
+			"output_tokens": r.usage.OutputTokens,
+			"total_tokens":  r.usage.InputTokens + r.usage.OutputTokens,
+		},
+	}
+}
+
+func streamResponses(w http.ResponseWriter, r *reply) {
+	sse := newSSEWriter(w)
+	seq := 0
+	emit := func(name string, payload map[string]any) {
+		payload["type"] = name
+		payload["sequence_number"] = seq
+		seq++
+		sse.event(name, payload)
+	}
+
+	pending := responseObject(r)
+	pending["status"] = "in_progress"
+	pending["output"] = []any{}
+	emit("response.created", map[string]any{"response": pending})
+
+	for index, item := range responseItems(r) {
+		added := make(map[string]any, len(item))
+		for k, v := range item {
+			added[k] = v
+		}
+		added["status"] = "in_progress"
+		if item["type"] == "message" {
+			added["content"] = []any{}
+		} else {
+			added["arguments"] = ""
+		}
+		emit("response.output_item.added", map[string]any{"output_index": index, "item": added})
+
+		if item["type"] == "message" {
+			for _, piece := range chunks(r.text) {
+				emit("response.output_text.delta", map[string]any{
+					"item_id": item["id"], "output_index": index, "content_index": 0, "delta": piece,
+				})
+			}
+			emit("response.output_text.done", map[string]any{
+				"item_id": item["id"], "output_index": index, "content_index": 0, "text": r.text,
+			})
+		} else {
+			emit("response.function_call_arguments.delta", map[string]any{
+				"item_id": item["id"], "output_index": index, "delta": item["arguments"],
+			})
+			emit("response.function_call_arguments.done", map[string]any{
+				"item_id": item["id"], "output_index": index, "arguments": item["arguments"],
+			})
+		}
+		emit("response.output_item.done", map[string]any{"output_index": index, "item": item})
+	}
+
+	emit("response.completed", map[string]any{"response": responseObject(r)})
+}
+
+// --- Anthropic messages ---
+
+func anthropicStopReason(r *reply) string {
+	if len(r.calls) > 0 {
+		return "tool_use"
+	}
+	return "end_turn"
+}
+
+func anthropicMessage(r *reply, complete bool) map[string]any {
+	content := []any{}
+	if complete {
+		if r.text != "" {
+			content = append(content, map[string]any{"type": "text", "text": r.text})
+		}
+		for i, call := range r.calls {
+			input := call.Arguments
+			if input == nil {
+				input = map[string]any{}
+			}
+			content = append(content, map[string]any{
+				"type": "tool_use", "id": r.callID(i), "name": call.Name, "input": input,
+			})
+		}
+	}
+	message := map[string]any{
+		"id":            r.id,
+		"type":          "message",
+		"role":          "assistant",
+		"model":         r.model,
+		"content":       content,
+		"stop_reason":   nil,
+		"stop_sequence": nil,
+		"usage":         map[string]any{"input_tokens": r.usage.InputTokens, "output_tokens": 1},
+	}
+	if complete {
+		message["stop_reason"] = anthropicStopReason(r)
+		message["usage"] = map[string]any{"input_tokens": r.usage.InputTokens, "output_tokens": r.usage.OutputTokens}
+	}
+	return message
+}
+
+func streamMessages(w http.ResponseWriter, r *reply) {
+	sse := newSSEWriter(w)
+	sse.event("message_start", map[string]any{"type": "message_start", "message": anthropicMessage(r, false)})
+	sse.event("ping", map[string]any{"type": "ping"})
+
+	index := 0
+	if r.text != "" {
+		sse.event("content_block_start", map[string]any{
+			"type": "content_block_start", "index": index,
+			"content_block": map[string]any{"type": "text", "text": ""},
+		})
+		for _, piece := range chunks(r.text) {
+			sse.event("content_block_delta", map[string]any{
+				"type": "content_block_delta", "index": index,
+				"delta": map[string]any{"type": "text_delta", "text": piece},
+			})
+		}
+		sse.event("content_block_stop", map[string]any{"type": "content_block_stop", "index": index})
+		index++
+	}
+	for i, call := range r.calls {
+		sse.event("content_block_start", map[string]any{
+			"type": "content_block_start", "index": index,
+			"content_block": map[string]any{"type": "tool_use", "id": r.callID(i), "name": call.Name, "input": map[string]any{}},
+		})
+		sse.event("content_block_delta", map[string]any{
+			"type": "content_block_delta", "index": index,
+			"delta": map[string]any{"type": "input_json_delta", "partial_json": argumentsJSON(call)},
+		})
+		sse.event("content_block_stop", map[string]any{"type": "content_block_stop", "index": index})
+		index++
+	}
+
+	sse.event("message_delta", map[string]any{
+		"type":  "message_delta",
+		"delta": map[string]any{"stop_reason": anthropicStopReason(r), "stop_sequence": nil},
+		"usage": map[string]any{"output_tokens": r.usage.OutputTokens},
+	})
+	sse.event("message_stop", map[string]any{"type": "message_stop"})
+}
+
+// --- Gemini generateContent ---
+
+func geminiResponse(r *reply, text string, calls []ToolCall, final bool) map[string]any {
+	parts := []any{}
+	if text != "" {
+		parts = append(parts, map[string]any{"text": text})
+	}
+	for _, call := range calls {
+		args := call.Arguments
+		if args == nil {
+			args = map[string]any{}
+		}
+		parts = append(parts, map[string]any{"functionCall": map[string]any{"name": call.Name, "args": args}})
+	}
+	candidate := map[string]any{
+		"content": map[string]any{"role": "model", "parts": parts},
+		"index":   0,
+	}
+	resp := map[string]any{
+		"candidates":   []any{candidate},
+		"modelVersion": r.model,
+		"responseId":   r.id,
+	}
+	if final {
+		candidate["finishReason"] = "STOP"
+		resp["usageMetadata"] = map[string]any{
+			"promptTokenCount":     r.usage.InputTokens,
+			"candidatesTokenCount": r.usage.OutputTokens,
+			"totalTokenCount":      r.usage.InputTokens + r.usage.OutputTokens,
+		}
+	}
+	return resp
+}
+
+func streamGemini(w http.ResponseWriter, r *reply) {
+	sse := newSSEWriter(w)
+	pieces := chunks(r.text)
+	if len(pieces) == 0 {
+		sse.event("", geminiResponse(r, "", r.calls, true))
+		return
+	}
+	for i, piece := range pieces {
+		last := i == len(pieces)-1
+		var calls []ToolCall
+		if last {
+			calls = r.calls
+		}
+		sse.event("", geminiResponse(r, piece, calls, last))
+	}
+}
+
+// --- Errors ---
+
+// writeError writes a provider-native error body for status
+func writeError(w http.ResponseWriter, protocol Protocol, status int, message string) {
+	if status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", "1")
+	}
+	switch protocol {
+	case ProtocolMessages:
+		writeJSON(w, status, map[string]any{
+			"type":  "error",
+			"error": map[string]any{"type": anthropicErrorType(status), "message": message},
+		})
+	case ProtocolGemini:
+		writeJSON(w, status, map[string]any{
+			"error": map[string]any{"code": status, "message": message, "status": geminiErrorStatus(status)},
+		})
+	default:
+		writeJSON(w, status, map[string]any{
+			"error": map[string]any{"message": message, "type": openAIErrorType(status), "param": nil, "code": nil},
+		})
+	}
+}
+
+func anthropicErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
+
+func openAIErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusNotFound:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	default:
+		return "server_error"
+	}
+}
+
+func geminiErrorStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable, 529:
+		return "UNAVAILABLE"
+	default:
+		return "INTERNAL"
+	}
+}
+
+func unixNow() int64 {
+	return time.Now().Unix()
+}
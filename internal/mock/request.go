@@ -0,0 +1,123 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// parsedRequest is the protocol-independent view of an incoming request
+type parsedRequest struct {
+	protocol     Protocol
+	model        string
+	prompt       string // Text of the last user message, used for matching
+	allText      string // Every prompt field, used for input token estimates
+	stream       bool
+	includeUsage bool // OpenAI chat stream_options.include_usage
+}
+
+// parseRequest extracts the fields the mock needs from a request body
+func parseRequest(protocol Protocol, model string, stream bool, body []byte) (*parsedRequest, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+
+	req := &parsedRequest{protocol: protocol, model: model, stream: stream}
+	if m, ok := raw["model"].(string); ok && req.model == "" {
+		req.model = m
+	}
+	if s, ok := raw["stream"].(bool); ok && protocol != ProtocolGemini {
+		req.stream = s
+	}
+	if opts, ok := raw["stream_options"].(map[string]any); ok {
+		req.includeUsage, _ = opts["include_usage"].(bool) //nolint:errcheck // absent means false
+	}
+
+	var all []string
+	switch protocol {
+	case ProtocolChat, ProtocolMessages:
+		if system := textOf(raw["system"]); system != "" {
+			all = append(all, system)
+		}
+		messages, _ := raw["messages"].([]any) //nolint:errcheck // absent means no messages
+		for _, m := range messages {
+			msg, _ := m.(map[string]any) //nolint:errcheck // skip malformed entries
+			text := textOf(msg["content"])
+			all = append(all, text)
+			if msg["role"] == "user" && text != "" {
+				req.prompt = text
+			}
+		}
+	case ProtocolResponses:
+		if instructions := textOf(raw["instructions"]); instructions != "" {
+			all = append(all, instructions)
+		}
+		switch input := raw["input"].(type) {
+		case string:
+			all = append(all, input)
+			req.prompt = input
+		case []any:
+			for _, item := range input {
+				msg, _ := item.(map[string]any) //nolint:errcheck // skip malformed entries
+				text := textOf(msg["content"])
+				all = append(all, text)
+				if (msg["role"] == "user" || msg["role"] == nil) && text != "" {
+					req.prompt = text
+				}
+			}
+		}
+	case ProtocolGemini:
+		if system, ok := raw["systemInstruction"].(map[string]any); ok {
+			all = append(all, textOf(system["parts"]))
+		}
+		contents, _ := raw["contents"].([]any) //nolint:errcheck // absent means no contents
+		for _, c := range contents {
+			content, _ := c.(map[string]any) //nolint:errcheck // skip malformed entries
+			text := textOf(content["parts"])
+			all = append(all, text)
+			if (content["role"] == "user" || content["role"] == nil) && text != "" {
+				req.prompt = text
+			}
+		}
+	}
+	req.allText = strings.Join(all, "\n")
+	return req, nil
+}
+
+// textOf flattens the text in a content value: a string, or a list of parts
+// carrying "text" fields (OpenAI parts, Anthropic blocks, Gemini parts)
+func textOf(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []any:
+		var parts []string
+		for _, item := range v {
+			if part, ok := item.(map[string]any); ok {
+				if text, ok := part["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	default:
+		return ""
+	}
+}
+
+// protocolForPath maps a recorded proxy route and path to a protocol
+func protocolForPath(provider, path string) Protocol {
+	switch {
+	case strings.HasSuffix(path, "/chat/completions"):
+		return ProtocolChat
+	case strings.HasSuffix(path, "/responses"):
+		return ProtocolResponses
+	case strings.HasSuffix(path, "/messages") && provider != "openai":
+		return ProtocolMessages
+	case strings.Contains(path, ":generateContent") || strings.Contains(path, ":streamGenerateContent"):
+		return ProtocolGemini
+	default:
+		return ""
+	}
+}
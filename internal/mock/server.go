@@ -0,0 +1,281 @@
+package mock
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/tokenizer"
+	"github.com/royisme/bobamixer/internal/transcript"
+)
+
+// DefaultAddr is the default mock server address
+const DefaultAddr = "127.0.0.1:7788"
+
+// Options configures a mock server
+type Options struct {
+	Config      *Config            // Scripted fixtures; nil answers every request with the default response
+	Transcripts []transcript.Entry // Recorded exchanges replayed when their prompt matches
+	Seed        int64              // Seeds fault injection; 0 uses the current time
+}
+
+// Server answers OpenAI, Anthropic and Gemini API requests from fixtures
+type Server struct {
+	config      *Config
+	transcripts map[string]transcript.Entry
+	rng         *rand.Rand
+	seq         int
+	mu          sync.Mutex
+}
+
+// NewServer creates a mock server handler
+func NewServer(opts Options) *Server {
+	config := opts.Config
+	if config == nil {
+		config = &Config{Version: 1}
+	}
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	s := &Server{
+		config:      config,
+		transcripts: make(map[string]transcript.Entry),
+		rng:         rand.New(rand.NewSource(seed)), // #nosec G404 -- fault injection does not need a secure source
+	}
+	for _, entry := range opts.Transcripts {
+		protocol := protocolForPath(entry.Provider, entry.Path)
+		if protocol == "" {
+			continue
+		}
+		req, err := parseRequest(protocol, "", false, entry.Request)
+		if err != nil || req.prompt == "" {
+			continue
+		}
+		// Later recordings of the same prompt win
+		s.transcripts[transcriptKey(protocol, req.stream, req.prompt)] = entry
+	}
+	return s
+}
+
+// TranscriptCount returns how many distinct recorded exchanges can be replayed
+func (s *Server) TranscriptCount() int {
+	return len(s.transcripts)
+}
+
+func transcriptKey(protocol Protocol, stream bool, prompt string) string {
+	return fmt.Sprintf("%s|%t|%s", protocol, stream, prompt)
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/health" {
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/v1/models" {
+		// Carries both the OpenAI and the Anthropic fields, so either client can parse it
+		writeJSON(w, http.StatusOK, map[string]any{
+			"object":   "list",
+			"data":     []any{map[string]any{"id": "mock-model", "object": "model", "type": "model", "display_name": "Mock Model", "owned_by": "bobamixer"}},
+			"has_more": false,
+		})
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/v1beta/models" {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"models": []any{map[string]any{"name": "models/mock-model", "displayName": "Mock Model"}},
+		})
+		return
+	}
+
+	protocol, model, stream := route(r)
+	if protocol == "" {
+		writeError(w, ProtocolChat, http.StatusNotFound, "Unknown mock endpoint: "+r.URL.Path)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, protocol, http.StatusMethodNotAllowed, "Use POST")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, protocol, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	req, err := parseRequest(protocol, model, stream, body)
+	if err != nil {
+		writeError(w, protocol, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rule := s.config.match(req)
+	if fault := s.pickFault(rule); fault != nil {
+		s.inject(w, r, req, fault)
+		return
+	}
+
+	if rule == nil {
+		if entry, ok := s.transcripts[transcriptKey(req.protocol, req.stream, req.prompt)]; ok {
+			replay(w, entry)
+			return
+		}
+	}
+
+	response := defaultResponse
+	if rule != nil {
+		response = rule.Response
+	} else if s.config.Default != nil {
+		response = *s.config.Default
+	}
+	if response.DelayMS > 0 {
+		select {
+		case <-time.After(time.Duration(response.DelayMS) * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	render(w, req, s.newReply(req, response))
+}
+
+// route maps a request path to its protocol, plus the model and stream mode for
+// Gemini, which carry them in the URL
+func route(r *http.Request) (protocol Protocol, model string, stream bool) {
+	path := r.URL.Path
+	switch {
+	case strings.HasSuffix(path, "/chat/completions"):
+		return ProtocolChat, "", false
+	case strings.HasSuffix(path, "/responses"):
+		return ProtocolResponses, "", false
+	case strings.HasSuffix(path, "/messages"):
+		return ProtocolMessages, "", false
+	}
+
+	idx := strings.Index(path, "/models/")
+	if idx < 0 {
+		return "", "", false
+	}
+	name, method, ok := strings.Cut(path[idx+len("/models/"):], ":")
+	if !ok {
+		return "", "", false
+	}
+	switch method {
+	case "generateContent":
+		return ProtocolGemini, name, false
+	case "streamGenerateContent":
+		return ProtocolGemini, name, true
+	default:
+		return "", "", false
+	}
+}
+
+// pickFault draws the rule's fault, then each global fault, against its probability
+func (s *Server) pickFault(rule *Rule) *Fault {
+	candidates := make([]*Fault, 0, len(s.config.Faults)+1)
+	if rule != nil && rule.Fault != nil {
+		candidates = append(candidates, rule.Fault)
+	}
+	for i := range s.config.Faults {
+		candidates = append(candidates, &s.config.Faults[i])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, fault := range candidates {
+		if fault.Probability > 0 && s.rng.Float64() < fault.Probability {
+			return fault
+		}
+	}
+	return nil
+}
+
+// inject answers with an error status, or hangs and drops the connection for timeouts
+func (s *Server) inject(w http.ResponseWriter, r *http.Request, req *parsedRequest, fault *Fault) {
+	if fault.Timeout > 0 {
+		select {
+		case <-time.After(fault.Timeout):
+		case <-r.Context().Done():
+		}
+		// Abort without a response so the client sees a dropped connection
+		panic(http.ErrAbortHandler)
+	}
+
+	message := "Mock injected error"
+	switch fault.Status {
+	case http.StatusTooManyRequests:
+		message = "Rate limit exceeded (mock)"
+	case 529:
+		message = "Overloaded (mock)"
+	}
+	writeError(w, req.protocol, fault.Status, message)
+}
+
+// replay writes a recorded response verbatim
+func replay(w http.ResponseWriter, entry transcript.Entry) {
+	contentType := entry.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	status := entry.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Boba-Mock", "transcript")
+	w.WriteHeader(status)
+	io.WriteString(w, entry.Response) //nolint:errcheck // client disconnects are not actionable
+}
+
+// newReply resolves IDs and token usage for a scripted response
+func (s *Server) newReply(req *parsedRequest, response Response) *reply {
+	s.mu.Lock()
+	s.seq++
+	seq := s.seq
+	s.mu.Unlock()
+
+	model := req.model
+	if model == "" {
+		model = "mock-model"
+	}
+
+	var usage Usage
+	if response.Usage != nil {
+		usage = *response.Usage
+	} else {
+		estimator := tokenizer.NewEstimator(model)
+		output := response.Text
+		for _, call := range response.ToolCalls {
+			output += call.Name + argumentsJSON(call)
+		}
+		usage = Usage{InputTokens: estimator.Estimate(req.allText), OutputTokens: estimator.Estimate(output)}
+	}
+
+	prefix := map[Protocol]string{
+		ProtocolChat:      "chatcmpl-mock",
+		ProtocolResponses: "resp_mock",
+		ProtocolMessages:  "msg_mock",
+		ProtocolGemini:    "mock",
+	}[req.protocol]
+	callPrefix := "call_mock"
+	if req.protocol == ProtocolMessages {
+		callPrefix = "toolu_mock"
+	}
+
+	return &reply{
+		id:      fmt.Sprintf("%s_%06d", prefix, seq),
+		model:   model,
+		text:    response.Text,
+		calls:   response.ToolCalls,
+		usage:   usage,
+		created: unixNow(),
+		callID: func(i int) string {
+			return fmt.Sprintf("%s_%06d_%d", callPrefix, seq, i)
+		},
+	}
+}
@@ -0,0 +1,163 @@
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/royisme/bobamixer/internal/transcript"
+)
+
+func newTestServer(t *testing.T, yamlConfig string, transcripts []transcript.Entry) *Server {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "mock.yaml")
+	if err := os.WriteFile(file, []byte(yamlConfig), 0600); err != nil {
+		t.Fatalf("write fixtures: %v", err)
+	}
+	config, err := Load(file)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return NewServer(Options{Config: config, Transcripts: transcripts, Seed: 1})
+}
+
+func post(s *Server, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+const fixtures = `
+version: 1
+rules:
+  - match: "(?i)weather"
+    response:
+      text: "Let me check."
+      tool_calls:
+        - name: get_weather
+          arguments: {city: Paris}
+  - match: "overload me"
+    protocols: [messages]
+    fault: {status: 529, probability: 1}
+default:
+  text: "default answer"
+`
+
+func TestAnthropicMessagesWithToolCall(t *testing.T) {
+	s := newTestServer(t, fixtures, nil)
+	rec := post(s, "/v1/messages", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":[{"type":"text","text":"What's the weather?"}]}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var msg struct {
+		Model      string `json:"model"`
+		StopReason string `json:"stop_reason"`
+		Content    []struct {
+			Type  string         `json:"type"`
+			Text  string         `json:"text"`
+			Name  string         `json:"name"`
+			Input map[string]any `json:"input"`
+		} `json:"content"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &msg); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if msg.StopReason != "tool_use" || len(msg.Content) != 2 || msg.Content[1].Name != "get_weather" || msg.Content[1].Input["city"] != "Paris" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	if msg.Model != "claude-sonnet-4" || msg.Usage.InputTokens == 0 || msg.Usage.OutputTokens == 0 {
+		t.Errorf("model/usage not filled: %+v", msg)
+	}
+}
+
+func TestStreamingProtocols(t *testing.T) {
+	s := newTestServer(t, fixtures, nil)
+
+	chat := post(s, "/v1/chat/completions", `{"model":"gpt-4o","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"weather in Paris"}]}`)
+	body := chat.Body.String()
+	if !strings.Contains(chat.Header().Get("Content-Type"), "text/event-stream") || !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Fatalf("chat stream malformed: %s", body)
+	}
+	if !strings.Contains(body, `"finish_reason":"tool_calls"`) || !strings.Contains(body, `"name":"get_weather"`) || !strings.Contains(body, `"prompt_tokens"`) {
+		t.Errorf("chat stream missing tool call or usage: %s", body)
+	}
+
+	messages := post(s, "/v1/messages", `{"model":"claude","stream":true,"messages":[{"role":"user","content":"hello"}]}`).Body.String()
+	for _, event := range []string{"event: message_start", "event: content_block_delta", `"text":" answer"`, "event: message_stop"} {
+		if !strings.Contains(messages, event) {
+			t.Errorf("anthropic stream missing %q: %s", event, messages)
+		}
+	}
+
+	responses := post(s, "/v1/responses", `{"model":"gpt-5","stream":true,"input":"hello"}`).Body.String()
+	if !strings.Contains(responses, "event: response.output_text.delta") || !strings.Contains(responses, "event: response.completed") {
+		t.Errorf("responses stream malformed: %s", responses)
+	}
+
+	gemini := post(s, "/v1beta/models/gemini-2.5-pro:streamGenerateContent?alt=sse", `{"contents":[{"role":"user","parts":[{"text":"weather?"}]}]}`).Body.String()
+	if !strings.Contains(gemini, `"functionCall":{"args":{"city":"Paris"},"name":"get_weather"}`) || !strings.Contains(gemini, `"finishReason":"STOP"`) {
+		t.Errorf("gemini stream malformed: %s", gemini)
+	}
+}
+
+func TestFaultInjectionUsesNativeErrors(t *testing.T) {
+	s := newTestServer(t, fixtures+"faults:\n  - status: 429\n    probability: 1\n", nil)
+
+	rec := post(s, "/v1/messages", `{"messages":[{"role":"user","content":"overload me"}]}`)
+	if rec.Code != 529 || !strings.Contains(rec.Body.String(), `"type":"overloaded_error"`) {
+		t.Errorf("rule fault = %d %s, want 529 overloaded_error", rec.Code, rec.Body.String())
+	}
+
+	rec = post(s, "/v1beta/models/gemini-2.5-flash:generateContent", `{"contents":[{"parts":[{"text":"hi"}]}]}`)
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "RESOURCE_EXHAUSTED") || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("global fault = %d %s, want 429 RESOURCE_EXHAUSTED", rec.Code, rec.Body.String())
+	}
+
+	if _, err := Load(writeFile(t, "faults:\n  - probability: 2\n    status: 429\n")); err == nil {
+		t.Error("probability above 1 should be rejected")
+	}
+}
+
+func TestTranscriptReplay(t *testing.T) {
+	recorded := transcript.Entry{
+		Provider:    "anthropic",
+		Path:        "/v1/messages",
+		StatusCode:  http.StatusOK,
+		ContentType: "application/json",
+		Request:     json.RawMessage(`{"model":"claude","messages":[{"role":"user","content":"recorded prompt"}]}`),
+		Response:    `{"id":"msg_real","content":[{"type":"text","text":"recorded answer"}]}`,
+	}
+	s := newTestServer(t, "version: 1\n", []transcript.Entry{recorded})
+	if s.TranscriptCount() != 1 {
+		t.Fatalf("TranscriptCount = %d", s.TranscriptCount())
+	}
+
+	rec := post(s, "/v1/messages", `{"model":"claude","messages":[{"role":"user","content":[{"type":"text","text":"recorded prompt"}]}]}`)
+	if rec.Body.String() != recorded.Response || rec.Header().Get("X-Boba-Mock") != "transcript" {
+		t.Errorf("expected verbatim replay, got %s", rec.Body.String())
+	}
+
+	rec = post(s, "/v1/messages", `{"messages":[{"role":"user","content":"something else"}]}`)
+	if !strings.Contains(rec.Body.String(), defaultResponse.Text) {
+		t.Errorf("unmatched prompt should get the default response, got %s", rec.Body.String())
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "mock.yaml")
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return file
+}
@@ -0,0 +1,97 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/logging"
+)
+
+// DefaultInterval is how often the background notifier polls
+const DefaultInterval = 5 * time.Minute
+
+// Dispatch delivers current events to every route that accepts their level
+// and has not received them yet. Routes in their quiet hours are skipped, as
+// are failed deliveries, so both are retried on a later dispatch. It returns
+// the number of deliveries made. Sinks are called without holding the
+// notifier's lock, so a slow webhook does not stall Poll.
+func (n *Notifier) Dispatch(ctx context.Context, routes []*Route, now time.Time) (int, error) {
+	n.dispatchMu.Lock()
+	defer n.dispatchMu.Unlock()
+
+	pending, err := n.pending(routes, now)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	var errs []error
+	for _, p := range pending {
+		if err := p.route.Sink.Send(ctx, p.event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.route.Name, err))
+			continue
+		}
+		n.mu.Lock()
+		err := n.markDelivered(p.event.Key, p.route.Name)
+		n.mu.Unlock()
+		if err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+// delivery is an event a route has yet to receive
+type delivery struct {
+	event Event
+	route *Route
+}
+
+// pending lists the deliveries a dispatch at now should make
+func (n *Notifier) pending(routes []*Route, now time.Time) ([]delivery, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var pending []delivery
+	for _, event := range n.collect() {
+		for _, route := range routes {
+			if !route.Accepts(event.Level) || route.Quiet(now) {
+				continue
+			}
+			delivered, err := n.delivered(event.Key, route.Name)
+			if err != nil {
+				return nil, err
+			}
+			if !delivered {
+				pending = append(pending, delivery{event: event, route: route})
+			}
+		}
+	}
+	return pending, nil
+}
+
+// Run dispatches to the routes every interval until the context is done
+func (n *Notifier) Run(ctx context.Context, routes []*Route, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if err := n.prune(time.Now()); err != nil {
+		logging.Warn("Failed to prune notification deliveries", logging.Err(err))
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := n.Dispatch(ctx, routes, time.Now()); err != nil {
+			logging.Warn("Notification delivery failed", logging.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
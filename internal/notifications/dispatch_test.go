@@ -0,0 +1,250 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/settings"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+// recordingSink keeps what it was sent, or fails while err is set
+type recordingSink struct {
+	events []Event
+	err    error
+}
+
+func (s *recordingSink) Send(_ context.Context, event Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestNewRoutes(t *testing.T) {
+	routes, err := NewRoutes([]settings.NotificationSink{
+		{Name: "desk", Type: settings.SinkDesktop, Levels: []string{"Critical"}, QuietHours: "22:00-07:00"},
+		{Name: "hook", Type: settings.SinkWebhook, URL: "http://localhost/hook"},
+	})
+	if err != nil {
+		t.Fatalf("NewRoutes: %v", err)
+	}
+	desk := routes[0]
+	if !desk.Accepts("critical") || desk.Accepts("warning") {
+		t.Error("desktop route should only accept critical")
+	}
+	if !routes[1].Accepts("info") {
+		t.Error("a route without levels should accept every level")
+	}
+
+	// Quiet hours wrap past midnight
+	day := func(hour, minute int) time.Time { return time.Date(2026, 10, 18, hour, minute, 0, 0, time.Local) }
+	for _, tt := range []struct {
+		at    time.Time
+		quiet bool
+	}{
+		{day(21, 59), false},
+		{day(22, 0), true},
+		{day(3, 0), true},
+		{day(7, 0), false},
+		{day(12, 0), false},
+	} {
+		if got := desk.Quiet(tt.at); got != tt.quiet {
+			t.Errorf("Quiet(%s) = %v, want %v", tt.at.Format("15:04"), got, tt.quiet)
+		}
+	}
+
+	for _, bad := range []settings.NotificationSink{
+		{Name: "a", Type: "pager"},
+		{Name: "b", Type: settings.SinkWebhook},
+		{Name: "c", Type: settings.SinkSlack},
+		{Name: "d", Type: settings.SinkEmail, SMTP: settings.SMTPSettings{Host: "smtp.example.com"}},
+		{Name: "e", Type: settings.SinkCommand},
+		{Name: "f", Type: settings.SinkDesktop, Levels: []string{"urgent"}},
+		{Name: "g", Type: settings.SinkDesktop, QuietHours: "22:00"},
+		{Name: "h", Type: settings.SinkDesktop, QuietHours: "22:00-25:00"},
+		{Type: settings.SinkDesktop},
+	} {
+		if _, err := NewRoutes([]settings.NotificationSink{bad}); err == nil {
+			t.Errorf("NewRoutes(%+v) succeeded, want an error", bad)
+		}
+	}
+
+	// Sink names key delivery records, which the TUI shares
+	for _, bad := range [][]settings.NotificationSink{
+		{{Name: "tui", Type: settings.SinkDesktop}},
+		{{Name: "desk", Type: settings.SinkDesktop}, {Name: "desk", Type: settings.SinkWebhook, URL: "http://localhost/hook"}},
+	} {
+		if _, err := NewRoutes(bad); err == nil {
+			t.Errorf("NewRoutes(%+v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestDispatchDeliversOncePerSink(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "usage.db")
+	db, err := sqlite.Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	// A project budget at 90% of its cap raises a warning
+	tracker := budget.NewTracker(db)
+	if _, err := tracker.CreateBudget("project", "api", 0, 10); err != nil {
+		t.Fatalf("CreateBudget: %v", err)
+	}
+	stmt := fmt.Sprintf(`INSERT INTO sessions (id, started_at, project) VALUES ('s1', %[1]d, 'api');
+		INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost) VALUES ('u1', 's1', %[1]d, 9.0, 0);`,
+		time.Now().Unix())
+	if err := db.Exec(stmt); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	all := &recordingSink{}
+	critical := &recordingSink{}
+	quiet := &recordingSink{}
+	flaky := &recordingSink{err: errors.New("connection refused")}
+	noon := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	routes := []*Route{
+		{Name: "all", Sink: all},
+		{Name: "critical", Sink: critical, levels: map[string]bool{"critical": true}},
+		{Name: "quiet", Sink: quiet, quiet: &quietHours{start: 9 * 60, end: 17 * 60}},
+		{Name: "flaky", Sink: flaky},
+	}
+
+	notifier := NewNotifier(db, nil, nil)
+	sent, err := notifier.Dispatch(context.Background(), routes, noon)
+	if err == nil || !strings.Contains(err.Error(), "flaky: connection refused") {
+		t.Errorf("Dispatch error = %v, want the flaky sink's failure", err)
+	}
+	if sent != 1 || len(all.events) != 1 {
+		t.Fatalf("sent %d, all sink got %d events; want 1", sent, len(all.events))
+	}
+	if event := all.events[0]; event.Level != "warning" || event.Metadata["target"] != "api" {
+		t.Errorf("event = %+v, want a warning for project api", event)
+	}
+	if len(critical.events) != 0 || len(quiet.events) != 0 {
+		t.Error("the critical-only and quiet sinks should not have received the warning")
+	}
+
+	// A restarted notifier remembers what was delivered; the quiet sink gets
+	// the held event after its quiet hours and the flaky sink once it recovers
+	flaky.err = nil
+	db, err = sqlite.Open(dbPath)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	sent, err = NewNotifier(db, nil, nil).Dispatch(context.Background(), routes, noon.Add(6*time.Hour))
+	if err != nil {
+		t.Fatalf("Dispatch after restart: %v", err)
+	}
+	if sent != 2 || len(all.events) != 1 || len(quiet.events) != 1 || len(flaky.events) != 1 {
+		t.Errorf("after restart sent %d (all %d, quiet %d, flaky %d); want 2 (1, 1, 1)",
+			sent, len(all.events), len(quiet.events), len(flaky.events))
+	}
+
+	// The TUI tracks its own deliveries
+	events, err := notifier.Poll()
+	if err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	if len(events) != 1 {
+		t.Errorf("Poll returned %d events, want the warning", len(events))
+	}
+}
+
+// pollingSink polls the notifier while it sends, as the TUI may during a slow delivery
+type pollingSink struct {
+	notifier *Notifier
+	polled   []Event
+}
+
+func (s *pollingSink) Send(context.Context, Event) error {
+	events, err := s.notifier.Poll()
+	s.polled = append(s.polled, events...)
+	return err
+}
+
+func TestDispatchSendsWithoutTheLock(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	tracker := budget.NewTracker(db)
+	if _, err := tracker.CreateBudget("project", "api", 0, 10); err != nil {
+		t.Fatalf("CreateBudget: %v", err)
+	}
+	stmt := fmt.Sprintf(`INSERT INTO sessions (id, started_at, project) VALUES ('s1', %[1]d, 'api');
+		INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost) VALUES ('u1', 's1', %[1]d, 9.0, 0);`,
+		time.Now().Unix())
+	if err := db.Exec(stmt); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	notifier := NewNotifier(db, nil, nil)
+	sink := &pollingSink{notifier: notifier}
+	done := make(chan error, 1)
+	go func() {
+		_, err := notifier.Dispatch(context.Background(), []*Route{{Name: "slow", Sink: sink}}, time.Now())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Dispatch: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Dispatch held the notifier's lock while sending")
+	}
+	if len(sink.polled) != 1 {
+		t.Errorf("Poll during the send returned %d events, want the warning", len(sink.polled))
+	}
+}
+
+func TestWebhookSinks(t *testing.T) {
+	var bodies []map[string]any
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		bodies = append(bodies, body)
+		tokens = append(tokens, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	routes, err := NewRoutes([]settings.NotificationSink{
+		{Name: "hook", Type: settings.SinkWebhook, URL: server.URL, Headers: map[string]string{"Authorization": "Bearer t"}},
+		{Name: "slack", Type: settings.SinkSlack, URL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("NewRoutes: %v", err)
+	}
+	event := Event{Type: "budget_alert", Level: "critical", Title: "Budget Cap Exceeded", Message: "over the cap", Timestamp: time.Now()}
+	for _, route := range routes {
+		if err := route.Sink.Send(context.Background(), event); err != nil {
+			t.Fatalf("%s: %v", route.Name, err)
+		}
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("got %d requests, want 2", len(bodies))
+	}
+	if bodies[0]["level"] != "critical" || bodies[0]["title"] != "Budget Cap Exceeded" || tokens[0] != "Bearer t" {
+		t.Errorf("webhook body = %v, auth %q", bodies[0], tokens[0])
+	}
+	if text, _ := bodies[1]["text"].(string); !strings.Contains(text, "*Budget Cap Exceeded*") || !strings.Contains(text, "over the cap") {
+		t.Errorf("slack text = %q", text)
+	}
+}
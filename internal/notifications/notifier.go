@@ -8,80 +8,118 @@ import (
 
 	"github.com/royisme/bobamixer/internal/domain/budget"
 	"github.com/royisme/bobamixer/internal/domain/suggestions"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
 )
 
 // Event represents a realtime notification that can be surfaced to the user.
 type Event struct {
 	Timestamp time.Time
 	Metadata  map[string]string
+	Key       string // identifies the event for deduplication
 	Type      string
+	Level     string // budget.AlertLevel name; suggestions are info
 	Title     string
 	Message   string
 }
 
-// Notifier polls multiple subsystems to produce realtime events.
+// tuiSink is the delivery record of events returned by Poll
+const tuiSink = "tui"
+
+// Notifier polls multiple subsystems to produce realtime events. Delivered
+// events are remembered per sink in SQLite, so restarts do not repeat them.
 type Notifier struct {
+	db         *sqlite.DB
+	tracker    *budget.Tracker
 	alerts     *budget.AlertManager
 	suggEngine *suggestions.Engine
-	seen       map[string]struct{}
-	mu         sync.Mutex
+	mu         sync.Mutex // guards collecting events and the delivery records
+	dispatchMu sync.Mutex // serializes Dispatch, so sinks are not sent an event twice
 }
 
 // NewNotifier constructs a notifier bound to budget alerts and suggestion engine.
-func NewNotifier(tracker *budget.Tracker, engine *suggestions.Engine, cfg *budget.AlertConfig) *Notifier {
+func NewNotifier(db *sqlite.DB, engine *suggestions.Engine, cfg *budget.AlertConfig) *Notifier {
 	if cfg == nil {
 		cfg = budget.DefaultAlertConfig()
 	}
+	tracker := budget.NewTracker(db)
 	return &Notifier{
+		db:         db,
+		tracker:    tracker,
 		alerts:     budget.NewAlertManager(tracker, cfg),
 		suggEngine: engine,
-		seen:       map[string]struct{}{},
 	}
 }
 
-// Poll inspects subsystems and returns newly detected events.
+// Poll inspects subsystems and returns events the TUI has not shown yet.
 func (n *Notifier) Poll() ([]Event, error) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
+	var events []Event
+	for _, event := range n.collect() {
+		delivered, err := n.delivered(event.Key, tuiSink)
+		if err != nil {
+			return nil, err
+		}
+		if delivered {
+			continue
+		}
+		if err := n.markDelivered(event.Key, tuiSink); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// collect returns every event that currently holds: alerts of each budgeted
+// scope and high-priority suggestions.
+func (n *Notifier) collect() []Event {
 	var events []Event
 
 	if n.alerts != nil {
-		// Currently we only monitor the global scope; per-profile/project can be added later.
-		alerts := n.alerts.CheckBudgetAlerts("global", "")
-		for _, alert := range alerts {
-			key := fmt.Sprintf("alert:%s:%s:%s", alert.Scope, alert.Target, alert.Title)
-			if _, ok := n.seen[key]; ok {
+		budgets, err := n.tracker.GetAllBudgets()
+		if err != nil {
+			budgets = nil
+		}
+		seen := make(map[string]bool)
+		for _, b := range budgets {
+			scope := b.Scope + "|" + b.Target
+			if seen[scope] {
 				continue
 			}
-			n.seen[key] = struct{}{}
-			events = append(events, Event{
-				Type:      "budget_alert",
-				Title:     alert.Title,
-				Message:   alert.FormatAlert(),
-				Timestamp: alert.Timestamp,
-				Metadata: map[string]string{
-					"scope":  alert.Scope,
-					"target": alert.Target,
-					"level":  alert.Level.String(),
-				},
-			})
+			seen[scope] = true
+
+			for _, alert := range n.alerts.CheckBudgetAlerts(b.Scope, b.Target) {
+				events = append(events, Event{
+					Key:       fmt.Sprintf("alert:%s:%s:%s:%s", alert.Scope, alert.Target, alert.Title, alert.Period),
+					Type:      "budget_alert",
+					Level:     alert.Level.String(),
+					Title:     alert.Title,
+					Message:   alert.FormatAlert(),
+					Timestamp: alert.Timestamp,
+					Metadata: map[string]string{
+						"scope":  alert.Scope,
+						"target": alert.Target,
+						"level":  alert.Level.String(),
+					},
+				})
+			}
 		}
 	}
 
 	if n.suggEngine != nil {
 		if suggs, err := n.suggEngine.GenerateSuggestions(3); err == nil {
+			// A suggestion that still holds is repeated once a week
+			year, week := time.Now().ISOWeek()
 			for _, sugg := range suggs {
 				if sugg.Priority < 4 {
 					continue
 				}
-				key := fmt.Sprintf("suggestion:%s:%s", sugg.Type.String(), sugg.Title)
-				if _, ok := n.seen[key]; ok {
-					continue
-				}
-				n.seen[key] = struct{}{}
 				events = append(events, Event{
+					Key:       fmt.Sprintf("suggestion:%s:%s:%d-W%02d", sugg.Type.String(), sugg.Title, year, week),
 					Type:      "suggestion",
+					Level:     budget.AlertLevelInfo.String(),
 					Title:     sugg.Title,
 					Message:   sugg.FormatSuggestion(),
 					Timestamp: time.Now(),
@@ -94,12 +132,12 @@ func (n *Notifier) Poll() ([]Event, error) {
 		}
 	}
 
-	return events, nil
+	return events
 }
 
-// Clear resets the deduplication cache (primarily for tests).
+// Clear forgets the events already returned by Poll (primarily for tests).
 func (n *Notifier) Clear() {
 	n.mu.Lock()
 	defer n.mu.Unlock()
-	n.seen = map[string]struct{}{}
+	_ = n.db.Exec(fmt.Sprintf("DELETE FROM notification_deliveries WHERE sink='%s';", tuiSink))
 }
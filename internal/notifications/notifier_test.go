@@ -35,7 +35,7 @@ func TestNotifierPollBudgetAlert(t *testing.T) {
 		t.Fatalf("insert usage: %v", err)
 	}
 
-	notifier := NewNotifier(tracker, suggestions.NewEngine(db), nil)
+	notifier := NewNotifier(db, suggestions.NewEngine(db), nil)
 	events, err := notifier.Poll()
 	if err != nil {
 		t.Fatalf("Poll: %v", err)
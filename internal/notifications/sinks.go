@@ -0,0 +1,337 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/httpx"
+	"github.com/royisme/bobamixer/internal/settings"
+)
+
+// sinkTimeout bounds a single delivery
+const sinkTimeout = 15 * time.Second
+
+// Sink delivers an event somewhere outside the TUI.
+type Sink interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// Route is a sink with the levels it receives and its quiet hours.
+type Route struct {
+	Name   string
+	Type   string
+	Sink   Sink
+	levels map[string]bool // empty receives every level
+	quiet  *quietHours
+}
+
+// Accepts reports whether the route receives events of a level
+func (r *Route) Accepts(level string) bool {
+	return len(r.levels) == 0 || r.levels[level]
+}
+
+// Quiet reports whether the route holds events back at the given time
+func (r *Route) Quiet(now time.Time) bool {
+	return r.quiet != nil && r.quiet.contains(now)
+}
+
+// levelNames are the levels sinks can route on, see budget.AlertLevel
+var levelNames = []string{"info", "projected", "warning", "critical"}
+
+// NewRoutes builds the routes configured in settings.yaml. Sink names key
+// delivery records, so they must be unique and may not be the TUI's.
+func NewRoutes(configs []settings.NotificationSink) ([]*Route, error) {
+	routes := make([]*Route, 0, len(configs))
+	names := make(map[string]bool, len(configs))
+	for _, cfg := range configs {
+		switch {
+		case cfg.Name == tuiSink:
+			return nil, fmt.Errorf("notification sink %q: the name is reserved for the TUI", cfg.Name)
+		case names[cfg.Name]:
+			return nil, fmt.Errorf("duplicate notification sink: %s", cfg.Name)
+		}
+		names[cfg.Name] = true
+		route, err := newRoute(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("notification sink %q: %w", cfg.Name, err)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+func newRoute(cfg settings.NotificationSink) (*Route, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	route := &Route{Name: cfg.Name, Type: cfg.Type, levels: make(map[string]bool)}
+
+	for _, level := range cfg.Levels {
+		level = strings.ToLower(level)
+		known := false
+		for _, name := range levelNames {
+			known = known || name == level
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown level %q: want %s", level, strings.Join(levelNames, ", "))
+		}
+		route.levels[level] = true
+	}
+
+	if cfg.QuietHours != "" {
+		quiet, err := parseQuietHours(cfg.QuietHours)
+		if err != nil {
+			return nil, err
+		}
+		route.quiet = quiet
+	}
+
+	switch cfg.Type {
+	case settings.SinkDesktop:
+		route.Sink = desktopSink{}
+	case settings.SinkWebhook, settings.SinkSlack:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("%s sinks need a url", cfg.Type)
+		}
+		route.Sink = webhookSink{url: cfg.URL, headers: cfg.Headers, slack: cfg.Type == settings.SinkSlack}
+	case settings.SinkEmail:
+		smtpCfg := cfg.SMTP
+		if smtpCfg.Host == "" || smtpCfg.From == "" || len(smtpCfg.To) == 0 {
+			return nil, fmt.Errorf("email sinks need smtp.host, smtp.from and smtp.to")
+		}
+		if smtpCfg.Port == 0 {
+			smtpCfg.Port = 587
+		}
+		route.Sink = emailSink{cfg: smtpCfg}
+	case settings.SinkCommand:
+		if len(cfg.Command) == 0 {
+			return nil, fmt.Errorf("command sinks need a command")
+		}
+		route.Sink = commandSink{argv: cfg.Command}
+	default:
+		return nil, fmt.Errorf("unknown type %q: want desktop, webhook, slack, email or command", cfg.Type)
+	}
+	return route, nil
+}
+
+// quietHours is a daily window in local time, which may wrap past midnight
+type quietHours struct {
+	start, end int // minutes since midnight
+}
+
+// parseQuietHours reads HH:MM-HH:MM
+func parseQuietHours(s string) (*quietHours, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("quiet_hours %q: want HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return nil, fmt.Errorf("quiet_hours %q: %w", s, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return nil, fmt.Errorf("quiet_hours %q: %w", s, err)
+	}
+	return &quietHours{start: start, end: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (q *quietHours) contains(now time.Time) bool {
+	minute := now.Hour()*60 + now.Minute()
+	if q.start <= q.end {
+		return minute >= q.start && minute < q.end
+	}
+	return minute >= q.start || minute < q.end
+}
+
+// payload is the JSON an event is delivered as to webhooks and commands
+type payload struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Type      string            `json:"type"`
+	Level     string            `json:"level"`
+	Title     string            `json:"title"`
+	Message   string            `json:"message"`
+}
+
+func newPayload(event Event) payload {
+	return payload{
+		Timestamp: event.Timestamp,
+		Metadata:  event.Metadata,
+		Type:      event.Type,
+		Level:     event.Level,
+		Title:     event.Title,
+		Message:   event.Message,
+	}
+}
+
+// levelIcons prefix Slack messages
+var levelIcons = map[string]string{
+	"info":      "ℹ️",
+	"projected": "🟠",
+	"warning":   "⚠️",
+	"critical":  "🚨",
+}
+
+// webhookSink POSTs the event as JSON, or as a Slack message
+type webhookSink struct {
+	url     string
+	headers map[string]string
+	slack   bool
+}
+
+func (s webhookSink) Send(ctx context.Context, event Event) error {
+	var body any = newPayload(event)
+	if s.slack {
+		body = map[string]string{
+			"text": fmt.Sprintf("%s *%s*\n%s", levelIcons[event.Level], event.Title, event.Message),
+		}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encode notification: %w", err)
+	}
+
+	headers := map[string]string{"Content-Type": "application/json"}
+	for k, v := range s.headers {
+		headers[k] = v
+	}
+	result, err := httpx.Execute(ctx, httpx.HTTPRequest{
+		Endpoint: s.url,
+		Headers:  headers,
+		Payload:  data,
+		Timeout:  sinkTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	if !result.Success {
+		if result.StatusCode == 0 {
+			return fmt.Errorf("webhook failed: %s error", result.ErrorClass)
+		}
+		return fmt.Errorf("webhook returned status %d", result.StatusCode)
+	}
+	return nil
+}
+
+// desktopSink shows a desktop notification: freedesktop notifications via
+// notify-send, or gdbus when notify-send is missing, and osascript on macOS
+type desktopSink struct{}
+
+func (desktopSink) Send(ctx context.Context, event Event) error {
+	ctx, cancel := context.WithTimeout(ctx, sinkTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch {
+	case runtime.GOOS == "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", strconv.Quote(event.Message), strconv.Quote("BobaMixer: "+event.Title))
+		cmd = exec.CommandContext(ctx, "osascript", "-e", script)
+	case commandExists("notify-send"):
+		urgency := "normal"
+		switch event.Level {
+		case "critical":
+			urgency = "critical"
+		case "info":
+			urgency = "low"
+		}
+		cmd = exec.CommandContext(ctx, "notify-send", "--app-name=BobaMixer", "--urgency="+urgency, event.Title, event.Message)
+	case commandExists("gdbus"):
+		// Arguments are GVariant text: app name, replaces id, icon, summary,
+		// body, actions, hints and expiry
+		cmd = exec.CommandContext(ctx, "gdbus", "call", "--session",
+			"--dest=org.freedesktop.Notifications",
+			"--object-path=/org/freedesktop/Notifications",
+			"--method=org.freedesktop.Notifications.Notify",
+			`"BobaMixer"`, "uint32 0", `""`, strconv.Quote(event.Title), strconv.Quote(event.Message),
+			"@as []", "@a{sv} {}", "int32 -1")
+	default:
+		return fmt.Errorf("no desktop notifier found (install notify-send)")
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("desktop notification: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func commandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// emailSink sends a plain-text email over SMTP
+type emailSink struct {
+	cfg settings.SMTPSettings
+}
+
+func (s emailSink) Send(_ context.Context, event Event) error {
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		password := ""
+		if s.cfg.PasswordEnv != "" {
+			password = os.Getenv(s.cfg.PasswordEnv)
+		}
+		auth = smtp.PlainAuth("", s.cfg.Username, password, s.cfg.Host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [BobaMixer] %s\r\n", event.Title)
+	fmt.Fprintf(&msg, "Date: %s\r\n", event.Timestamp.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(event.Message, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	if err := smtp.SendMail(addr, auth, s.cfg.From, s.cfg.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("send email: %w", err)
+	}
+	return nil
+}
+
+// commandSink runs a local command with the event as JSON on stdin and its
+// main fields in BOBA_NOTIFY_* environment variables
+type commandSink struct {
+	argv []string
+}
+
+func (s commandSink) Send(ctx context.Context, event Event) error {
+	data, err := json.Marshal(newPayload(event))
+	if err != nil {
+		return fmt.Errorf("encode notification: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sinkTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, s.argv[0], s.argv[1:]...) //nolint:gosec // command comes from the user's settings.yaml
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Env = append(os.Environ(),
+		"BOBA_NOTIFY_TYPE="+event.Type,
+		"BOBA_NOTIFY_LEVEL="+event.Level,
+		"BOBA_NOTIFY_TITLE="+event.Title,
+		"BOBA_NOTIFY_MESSAGE="+event.Message,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notification command: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
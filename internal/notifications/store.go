@@ -0,0 +1,42 @@
+package notifications
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// deliveryRetention is how long delivery records are kept. Event keys carry
+// the budget period or week they belong to, so older records never match again.
+const deliveryRetention = 90 * 24 * time.Hour
+
+// delivered reports whether a sink already received an event
+func (n *Notifier) delivered(key, sink string) (bool, error) {
+	count, err := n.db.QueryInt(fmt.Sprintf(
+		"SELECT COUNT(*) FROM notification_deliveries WHERE event_key='%s' AND sink='%s';",
+		escape(key), escape(sink)))
+	if err != nil {
+		return false, fmt.Errorf("check notification delivery: %w", err)
+	}
+	return count > 0, nil
+}
+
+// markDelivered records that a sink received an event
+func (n *Notifier) markDelivered(key, sink string) error {
+	if err := n.db.Exec(fmt.Sprintf(
+		"INSERT OR IGNORE INTO notification_deliveries (event_key, sink, delivered_at) VALUES ('%s', '%s', %d);",
+		escape(key), escape(sink), time.Now().Unix())); err != nil {
+		return fmt.Errorf("record notification delivery: %w", err)
+	}
+	return nil
+}
+
+// prune drops delivery records past the retention period
+func (n *Notifier) prune(now time.Time) error {
+	return n.db.Exec(fmt.Sprintf("DELETE FROM notification_deliveries WHERE delivered_at < %d;",
+		now.Add(-deliveryRetention).Unix()))
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
@@ -0,0 +1,259 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL is how long cached responses stay valid when no TTL is configured
+	DefaultCacheTTL = 24 * time.Hour
+
+	// DefaultCacheMaxEntries caps the number of cached responses
+	DefaultCacheMaxEntries = 10000
+
+	// DefaultCacheMaxBytes caps the on-disk size of the cache (256 MB)
+	DefaultCacheMaxBytes = 256 << 20
+
+	// cacheHeader reports HIT, MISS or BYPASS to the client
+	cacheHeader = "X-Boba-Cache"
+)
+
+// CacheOptions configures a ResponseCache
+type CacheOptions struct {
+	TTL        time.Duration
+	MaxEntries int
+	MaxBytes   int64
+}
+
+// ResponseCache is an exact-match, on-disk cache of upstream responses.
+// Entries are keyed on provider, model, endpoint and the canonicalized JSON body.
+type ResponseCache struct {
+	dir     string
+	options CacheOptions
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+// cacheEntry is the on-disk representation of a cached response
+type cacheEntry struct {
+	CreatedAt    time.Time `json:"created_at"`
+	StatusCode   int       `json:"status_code"`
+	ContentType  string    `json:"content_type,omitempty"`
+	Body         []byte    `json:"body"`
+	Model        string    `json:"model"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	Cost         float64   `json:"cost"` // what the original request cost, i.e. the saving per hit
+}
+
+// NewResponseCache creates a cache rooted at dir, creating the directory if needed
+func NewResponseCache(dir string, options CacheOptions) (*ResponseCache, error) {
+	if options.TTL <= 0 {
+		options.TTL = DefaultCacheTTL
+	}
+	if options.MaxEntries <= 0 {
+		options.MaxEntries = DefaultCacheMaxEntries
+	}
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = DefaultCacheMaxBytes
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+	return &ResponseCache{dir: dir, options: options, now: time.Now}, nil
+}
+
+// cacheKey builds the cache key for a request, or returns "" when the body is not
+// canonicalizable JSON or the response is not worth replaying: sampled requests
+// (temperature unset or above 0) may answer differently each time, and streamed
+// responses are not stored. Canonicalization re-encodes the body so key order
+// and whitespace differences map to the same entry.
+func cacheKey(provider, path string, body []byte) (key, model string) {
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return "", ""
+	}
+	obj, ok := decoded.(map[string]interface{})
+	if !ok || !deterministic(obj) {
+		return "", ""
+	}
+	model, _ = obj["model"].(string) //nolint:errcheck // missing model just means an empty key component
+	canonical, err := json.Marshal(obj)
+	if err != nil {
+		return "", ""
+	}
+
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(provider), []byte(model), []byte(path), canonical} {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), model
+}
+
+// deterministic reports whether a request asks for a single greedy,
+// non-streamed completion, the only kind the cache replays
+func deterministic(req map[string]interface{}) bool {
+	if stream, _ := req["stream"].(bool); stream { //nolint:errcheck // a non-bool stream is not streaming
+		return false
+	}
+	if n, ok := req["n"].(float64); ok && n > 1 {
+		return false
+	}
+	temperature, ok := req["temperature"].(float64)
+	return ok && temperature == 0
+}
+
+// cacheDirectives reports whether the client asked to skip lookup (no-cache)
+// or storage (no-store) via Cache-Control
+func cacheDirectives(header http.Header) (skipLookup, skipStore bool) {
+	for _, directive := range strings.Split(strings.ToLower(header.Get("Cache-Control")), ",") {
+		switch strings.TrimSpace(directive) {
+		case "no-cache":
+			skipLookup = true
+		case "no-store":
+			skipLookup = true
+			skipStore = true
+		}
+	}
+	return skipLookup, skipStore
+}
+
+// Get returns a fresh entry for key, removing it if it has expired
+func (c *ResponseCache) Get(key string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(key)
+	data, err := os.ReadFile(path) // #nosec G304 -- path is derived from a hex digest under the cache dir
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		_ = os.Remove(path) //nolint:errcheck // corrupt entry, best-effort cleanup
+		return nil, false
+	}
+	if c.now().Sub(entry.CreatedAt) > c.options.TTL {
+		_ = os.Remove(path) //nolint:errcheck // expired entry, best-effort cleanup
+		return nil, false
+	}
+	return &entry, true
+}
+
+// Put stores an entry and evicts the oldest entries beyond the size limits
+func (c *ResponseCache) Put(key string, entry *cacheEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.CreatedAt = c.now()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encode cache entry: %w", err)
+	}
+	if int64(len(data)) > c.options.MaxBytes {
+		return nil
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create cache shard: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("commit cache entry: %w", err)
+	}
+	// Eviction orders by mtime, so keep it in step with the entry's clock
+	if err := os.Chtimes(path, entry.CreatedAt, entry.CreatedAt); err != nil {
+		return fmt.Errorf("stamp cache entry: %w", err)
+	}
+	return c.evict()
+}
+
+// Clear removes every cached response
+func (c *ResponseCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(c.dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// path shards entries by the first two hex characters of the key
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// evict drops expired entries, then the oldest entries until both limits hold.
+// Callers must hold c.mu.
+func (c *ResponseCache) evict() error {
+	type fileInfo struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []fileInfo
+	var total int64
+	now := c.now()
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if now.Sub(info.ModTime()) > c.options.TTL {
+			_ = os.Remove(path) //nolint:errcheck // expired entry, best-effort cleanup
+			return nil
+		}
+		files = append(files, fileInfo{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("scan cache: %w", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	for len(files) > 0 && (len(files) > c.options.MaxEntries || total > c.options.MaxBytes) {
+		if err := os.Remove(files[0].path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("evict cache entry: %w", err)
+		}
+		total -= files[0].size
+		files = files[1:]
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheKeyCanonicalization(t *testing.T) {
+	a, model := cacheKey("openai", "/v1/chat/completions", []byte(`{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"hi"}]}`))
+	b, _ := cacheKey("openai", "/v1/chat/completions", []byte(`{ "messages": [{"content":"hi","role":"user"}], "temperature": 0, "model": "gpt-4o" }`))
+	if a == "" || a != b {
+		t.Fatalf("equivalent bodies produced different keys: %q vs %q", a, b)
+	}
+	if model != "gpt-4o" {
+		t.Errorf("model = %q, want gpt-4o", model)
+	}
+
+	other, _ := cacheKey("openrouter", "/v1/chat/completions", []byte(`{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"hi"}]}`))
+	if other == a {
+		t.Error("different providers should not share a cache key")
+	}
+
+	if key, _ := cacheKey("openai", "/v1/chat/completions", []byte("not json")); key != "" {
+		t.Errorf("non-JSON body should not be cacheable, got key %q", key)
+	}
+}
+
+func TestCacheKeyOnlyDeterministicRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		cacheable bool
+	}{
+		{name: "temperature 0", body: `{"model":"gpt-4o","temperature":0,"messages":[]}`, cacheable: true},
+		{name: "not streamed", body: `{"model":"gpt-4o","temperature":0,"stream":false,"messages":[]}`, cacheable: true},
+		{name: "temperature unset", body: `{"model":"gpt-4o","messages":[]}`},
+		{name: "sampled", body: `{"model":"gpt-4o","temperature":0.7,"messages":[]}`},
+		{name: "streamed", body: `{"model":"gpt-4o","temperature":0,"stream":true,"messages":[]}`},
+		{name: "several choices", body: `{"model":"gpt-4o","temperature":0,"n":3,"messages":[]}`},
+	}
+	for _, tt := range tests {
+		key, _ := cacheKey("openai", "/v1/chat/completions", []byte(tt.body))
+		if (key != "") != tt.cacheable {
+			t.Errorf("%s: cacheable = %v, want %v", tt.name, key != "", tt.cacheable)
+		}
+	}
+}
+
+func TestCacheDirectives(t *testing.T) {
+	tests := []struct {
+		header         string
+		wantSkipLookup bool
+		wantSkipStore  bool
+	}{
+		{"", false, false},
+		{"no-cache", true, false},
+		{"max-age=0, No-Store", true, true},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		h.Set("Cache-Control", tt.header)
+		lookup, store := cacheDirectives(h)
+		if lookup != tt.wantSkipLookup || store != tt.wantSkipStore {
+			t.Errorf("cacheDirectives(%q) = %v, %v; want %v, %v", tt.header, lookup, store, tt.wantSkipLookup, tt.wantSkipStore)
+		}
+	}
+}
+
+func TestResponseCacheTTL(t *testing.T) {
+	cache, err := NewResponseCache(t.TempDir(), CacheOptions{TTL: time.Minute})
+	if err != nil {
+		t.Fatalf("NewResponseCache: %v", err)
+	}
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	key := strings.Repeat("ab", 32)
+	if err := cache.Put(key, &cacheEntry{StatusCode: 200, Body: []byte("ok"), Cost: 0.5}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	entry, ok := cache.Get(key)
+	if !ok || string(entry.Body) != "ok" || entry.Cost != 0.5 {
+		t.Fatalf("Get() = %+v, %v; want cached entry", entry, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := cache.Get(key); ok {
+		t.Fatal("expired entry should not be returned")
+	}
+	if _, err := os.Stat(cache.path(key)); !os.IsNotExist(err) {
+		t.Errorf("expired entry should be removed from disk, stat err = %v", err)
+	}
+}
+
+func TestResponseCacheEvictsOldest(t *testing.T) {
+	cache, err := NewResponseCache(t.TempDir(), CacheOptions{MaxEntries: 2})
+	if err != nil {
+		t.Fatalf("NewResponseCache: %v", err)
+	}
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	keys := []string{strings.Repeat("a1", 32), strings.Repeat("b2", 32), strings.Repeat("c3", 32)}
+	for _, key := range keys {
+		if err := cache.Put(key, &cacheEntry{StatusCode: 200, Body: []byte(key)}); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	if _, ok := cache.Get(keys[0]); ok {
+		t.Error("oldest entry should have been evicted")
+	}
+	for _, key := range keys[1:] {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("entry %s should still be cached", key[:4])
+		}
+	}
+}
+
+func TestHandlerServesCachedResponses(t *testing.T) {
+	var upstreamCalls int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upstreamCalls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","usage":{"input_tokens":100,"output_tokens":20}}`)
+	}))
+	defer upstream.Close()
+
+	home := t.TempDir()
+	handler, err := NewHandler(filepath.Join(home, "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	cache, err := NewResponseCache(filepath.Join(home, "cache"), CacheOptions{})
+	if err != nil {
+		t.Fatalf("NewResponseCache: %v", err)
+	}
+	handler.SetResponseCache(cache)
+
+	deterministic := `{"model":"claude-3-5-haiku","max_tokens":10,"temperature":0,"messages":[{"role":"user","content":"ping"}]}`
+	sendBody := func(body, cacheControl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", strings.NewReader(body))
+		req.Header.Set("X-Proxy-Target", upstream.URL)
+		if cacheControl != "" {
+			req.Header.Set("Cache-Control", cacheControl)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	send := func(cacheControl string) *httptest.ResponseRecorder {
+		return sendBody(deterministic, cacheControl)
+	}
+
+	if rec := send(""); rec.Header().Get(cacheHeader) != "MISS" {
+		t.Fatalf("first request cache header = %q, want MISS", rec.Header().Get(cacheHeader))
+	}
+	rec := send("")
+	if rec.Header().Get(cacheHeader) != "HIT" {
+		t.Fatalf("second request cache header = %q, want HIT", rec.Header().Get(cacheHeader))
+	}
+	respBody, _ := io.ReadAll(rec.Body) //nolint:errcheck // recorder body cannot fail
+	if !strings.Contains(string(respBody), "msg_1") {
+		t.Errorf("cached body = %s, want upstream body", respBody)
+	}
+	if rec := send("no-cache"); rec.Header().Get(cacheHeader) != "BYPASS" {
+		t.Fatalf("no-cache request header = %q, want BYPASS", rec.Header().Get(cacheHeader))
+	}
+
+	// Sampled and streamed requests are neither looked up nor stored
+	for _, body := range []string{
+		`{"model":"claude-3-5-haiku","max_tokens":10,"messages":[{"role":"user","content":"ping"}]}`,
+		`{"model":"claude-3-5-haiku","max_tokens":10,"temperature":0,"stream":true,"messages":[{"role":"user","content":"ping"}]}`,
+	} {
+		for i := 0; i < 2; i++ {
+			if rec := sendBody(body, ""); rec.Header().Get(cacheHeader) != "" {
+				t.Errorf("%s: cache header = %q, want none", body, rec.Header().Get(cacheHeader))
+			}
+		}
+	}
+
+	if calls := atomic.LoadInt32(&upstreamCalls); calls != 6 {
+		t.Errorf("upstream calls = %d, want 6", calls)
+	}
+
+	hits, err := handler.db.QueryInt("SELECT COUNT(*) FROM usage_records WHERE cache_hit = 1 AND input_cost = 0 AND output_cost = 0;")
+	if err != nil {
+		t.Fatalf("query cache hits: %v", err)
+	}
+	if hits != 1 {
+		t.Errorf("cache hit records = %d, want 1", hits)
+	}
+}
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/tokenizer"
+	"github.com/royisme/bobamixer/internal/logging"
+)
+
+// countTokensPath is Anthropic's token counting endpoint, which many
+// anthropic-compatible providers do not implement
+const countTokensPath = "/v1/messages/count_tokens"
+
+// serveCountTokens relays count_tokens to the upstream and answers it locally when
+// the upstream lacks the endpoint. Counting is free, so it skips budgets and usage logging.
+func (h *Handler) serveCountTokens(w http.ResponseWriter, r *http.Request, body []byte, targetURL string, preq *proxyRequest) error {
+	h.mu.RLock()
+	missing := h.countTokensMissing[targetURL]
+	h.mu.RUnlock()
+
+	if !missing {
+		resp, respBody, err := h.relayCountTokens(r, body, targetURL, preq)
+		switch {
+		case err != nil:
+			logging.Warn("count_tokens upstream failed, counting locally", logging.String("target", targetURL), logging.Err(err))
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented:
+			logging.Info("Upstream does not implement count_tokens, counting locally", logging.String("target", targetURL))
+			h.mu.Lock()
+			if h.countTokensMissing == nil {
+				h.countTokensMissing = make(map[string]bool)
+			}
+			h.countTokensMissing[targetURL] = true
+			h.mu.Unlock()
+		default:
+			for key, values := range resp.Header {
+				for _, value := range values {
+					w.Header().Add(key, value)
+				}
+			}
+			w.WriteHeader(resp.StatusCode)
+			if _, err := w.Write(respBody); err != nil {
+				return fmt.Errorf("write response: %w", err)
+			}
+			return nil
+		}
+	}
+
+	count, err := tokenizer.CountRequest(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to count tokens: %s", err.Error()), http.StatusBadRequest)
+		return fmt.Errorf("count tokens: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Boba-Token-Count", count.Tokenizer)
+	w.Header().Set("X-Boba-Token-Confidence", string(count.Confidence))
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]int{"input_tokens": count.Tokens}); err != nil {
+		return fmt.Errorf("write response: %w", err)
+	}
+	return nil
+}
+
+// relayCountTokens sends the count_tokens request upstream with the tool's credentials
+func (h *Handler) relayCountTokens(r *http.Request, body []byte, targetURL string, preq *proxyRequest) (*http.Response, []byte, error) {
+	upstreamURL := targetURL + preq.targetPath
+	if r.URL.RawQuery != "" {
+		upstreamURL += "?" + r.URL.RawQuery
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, upstreamURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	h.copyHeaders(req.Header, r.Header)
+	stripProxyHeaders(req.Header)
+	if err := h.applyPooledKey(req.Header, preq); err != nil {
+		return nil, nil, err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second, Transport: h.transport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck // read-only
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, respBody, nil
+}
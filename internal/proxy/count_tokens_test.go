@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandlerCountTokens(t *testing.T) {
+	upstreamCalls := 0
+	supported := true
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		if r.URL.Path != countTokensPath || !supported {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"input_tokens":42}`)) //nolint:errcheck // test server
+	}))
+	defer upstream.Close()
+
+	handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+
+	count := func() (int, http.Header) {
+		body := `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"Hello world, this is a test."}]}`
+		req := httptest.NewRequest(http.MethodPost, "/anthropic"+countTokensPath, strings.NewReader(body))
+		req.Header.Set("X-Proxy-Target", upstream.URL)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			InputTokens int `json:"input_tokens"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode %q: %v", rec.Body.String(), err)
+		}
+		return resp.InputTokens, rec.Header()
+	}
+
+	if tokens, _ := count(); tokens != 42 {
+		t.Errorf("supported upstream: tokens = %d, want relayed 42", tokens)
+	}
+
+	supported = false
+	tokens, header := count()
+	if tokens < 10 || tokens > 25 || header.Get("X-Boba-Token-Count") != "claude-approx" || header.Get("X-Boba-Token-Confidence") != "medium" {
+		t.Errorf("local count = %d, headers %v", tokens, header)
+	}
+	// The missing endpoint is remembered, so later requests skip the upstream
+	count()
+	if upstreamCalls != 2 {
+		t.Errorf("upstream calls = %d, want 2", upstreamCalls)
+	}
+
+	usage, err := handler.db.QueryInt("SELECT COUNT(*) FROM usage_records;")
+	if err != nil {
+		t.Fatalf("query usage: %v", err)
+	}
+	if usage != 0 {
+		t.Errorf("usage records = %d, token counting should not be logged", usage)
+	}
+}
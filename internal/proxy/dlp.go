@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/royisme/bobamixer/internal/domain/dlp"
+	"github.com/royisme/bobamixer/internal/logging"
+)
+
+// SetDLPScanner installs the outbound secret/PII scanner (nil disables scanning)
+func (h *Handler) SetDLPScanner(scanner *dlp.Scanner) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dlpScanner = scanner
+}
+
+// applyDLP scans the prompt for secrets and PII, masking matches in place.
+// When a blocking rule matches it writes a 400 naming the rule and returns an error.
+func (h *Handler) applyDLP(w http.ResponseWriter, body []byte, preq *proxyRequest) ([]byte, error) {
+	h.mu.RLock()
+	scanner := h.dlpScanner
+	h.mu.RUnlock()
+	if scanner == nil {
+		return body, nil
+	}
+
+	result := scanner.ScanBody(body)
+	preq.dlpFindings = result.Findings
+	for _, f := range result.Findings {
+		logging.Warn("DLP finding",
+			logging.String("session_id", preq.sessionID),
+			logging.String("tool", preq.toolID),
+			logging.String("rule", f.RuleID),
+			logging.String("action", string(f.Action)),
+			logging.String("location", f.Location))
+	}
+
+	if result.Blocked != nil {
+		f := result.Blocked
+		http.Error(w, fmt.Sprintf("Request blocked by DLP rule %s: %s detected in %s (%s). Remove it and retry.",
+			f.RuleID, f.Kind, f.Location, f.Excerpt), http.StatusBadRequest)
+		h.recordAudit(preq)
+		return nil, fmt.Errorf("dlp: blocked by rule %s", f.RuleID)
+	}
+	return result.Body, nil
+}
+
+// recordAudit stores policy rewrites and DLP findings against the request's session
+func (h *Handler) recordAudit(preq *proxyRequest) {
+	h.recordRewrites(preq)
+	h.recordFindings(preq)
+}
+
+// recordFindings stores DLP findings against the request's session
+func (h *Handler) recordFindings(preq *proxyRequest) {
+	if len(preq.dlpFindings) == 0 {
+		return
+	}
+
+	ts := preq.startTime.Unix()
+	statements := []string{fmt.Sprintf(`INSERT OR IGNORE INTO sessions (id, started_at, ended_at, success, latency_ms)
+		VALUES ('%s', %d, %d, 0, 0);`, escapeSQLString(preq.sessionID), ts, ts)}
+	for i, f := range preq.dlpFindings {
+		statements = append(statements, fmt.Sprintf(`INSERT INTO dlp_findings (id, session_id, ts, tool, rule_id, kind, action, location, excerpt)
+		VALUES ('%s_dlp_%d', '%s', %d, '%s', '%s', '%s', '%s', '%s', '%s');`,
+			escapeSQLString(preq.sessionID), i, escapeSQLString(preq.sessionID), ts,
+			escapeSQLString(preq.toolID), escapeSQLString(f.RuleID), escapeSQLString(string(f.Kind)),
+			escapeSQLString(string(f.Action)), escapeSQLString(f.Location), escapeSQLString(f.Excerpt)))
+	}
+
+	if err := h.db.Exec(strings.Join(statements, "\n")); err != nil {
+		logging.Error("Failed to record DLP findings", logging.Err(err))
+	}
+}
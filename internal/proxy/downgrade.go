@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/domain/tokenizer"
+	"github.com/royisme/bobamixer/internal/logging"
+)
+
+const (
+	// budgetPolicyID marks budget downgrades and warnings among the request's rewrites
+	budgetPolicyID = "budget"
+
+	// budgetHeader names the budget that blocked, downgraded or warned about a request
+	budgetHeader = "X-Boba-Budget"
+
+	// budgetWarningHeader carries the denial a warn budget let through
+	budgetWarningHeader = "X-Boba-Budget-Warning"
+)
+
+// budgetStatuses calculates the status of every active budget the request
+// matches, once per request and model: routing, the downgrade ladder and the
+// pre-request check all grade the same statuses
+func (h *Handler) budgetStatuses(preq *proxyRequest, model string) ([]*budget.Status, error) {
+	if !preq.budgetLoaded || preq.budgetModel != model {
+		preq.budgetStatuses, preq.budgetErr = h.budgetTracker.RequestStatuses(preq.budgetRequest(model))
+		preq.budgetModel, preq.budgetLoaded = model, true
+	}
+	return preq.budgetStatuses, preq.budgetErr
+}
+
+// budgetHint grades spending of the tightest budget the request matches
+// against the alert thresholds
+func (h *Handler) budgetHint(preq *proxyRequest, model string) (string, float64) {
+	statuses, err := h.budgetStatuses(preq, model)
+	if err != nil {
+		return budget.HintNormal, 0
+	}
+	h.mu.RLock()
+	config := h.alertConfig
+	h.mu.RUnlock()
+	return budget.TightestHint(statuses, config)
+}
+
+// applyBudgetDowngrade moves the request down its binding's downgrade ladder as
+// spending nears the cap: one rung at near_cap, the last rung at over_cap or
+// once the request would go over a budget at its model. Cheaper models then
+// stretch the remaining budget instead of requests being blocked at it.
+func (h *Handler) applyBudgetDowngrade(body []byte, preq *proxyRequest) []byte {
+	if preq.binding == nil || len(preq.binding.Options.Downgrade) < 2 {
+		return body
+	}
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return body
+	}
+	model, _ := req["model"].(string) //nolint:errcheck // requests without a model are left alone
+	hint, used := h.budgetHint(preq, model)
+	if hint != budget.HintOverCap {
+		// The critical threshold may sit at the cap itself, where the
+		// pre-request check refuses; take the last rung before that
+		if preq.prompt == nil {
+			if count, err := tokenizer.CountRequest(body); err == nil {
+				preq.prompt = &count
+			}
+		}
+		if h.checkBudgetBeforeRequest(body, preq) != nil {
+			hint = budget.HintOverCap
+		}
+	}
+	if hint == budget.HintNormal {
+		return body
+	}
+	target := downgradeModel(preq.binding.Options.Downgrade, model, hint)
+	if target == "" || target == model {
+		return body
+	}
+
+	req["model"] = target
+	rewritten, err := json.Marshal(req)
+	if err != nil {
+		return body
+	}
+	preq.rewrites = append(preq.rewrites, Rewrite{
+		PolicyID: budgetPolicyID,
+		Action:   "downgrade_model",
+		Detail:   fmt.Sprintf("%s -> %s (%s, %.0f%% of budget used)", model, target, hint, used),
+	})
+	logging.Info("Budget downgrade",
+		logging.String("session_id", preq.sessionID),
+		logging.String("tool", preq.toolID),
+		logging.String("from", model),
+		logging.String("to", target),
+		logging.String("budget", hint))
+	return rewritten
+}
+
+// downgradeModel returns the ladder rung a model moves to under the budget hint,
+// or "" when the model is not on the ladder. A model is on a rung when it equals
+// the rung or is a dated snapshot of it (claude-opus-4-1-20250805 for claude-opus-4-1).
+func downgradeModel(ladder []string, model, hint string) string {
+	rung := ladderRung(ladder, model)
+	if rung < 0 {
+		return ""
+	}
+	next := rung
+	switch hint {
+	case budget.HintOverCap:
+		next = len(ladder) - 1
+	case budget.HintNearCap:
+		next = min(rung+1, len(ladder)-1)
+	}
+	if next == rung {
+		return model
+	}
+	return ladder[next]
+}
+
+// ladderRung finds the model's rung, preferring an exact name over a snapshot match
+func ladderRung(ladder []string, model string) int {
+	lower := strings.ToLower(model)
+	for i, name := range ladder {
+		if strings.EqualFold(name, model) {
+			return i
+		}
+	}
+	for i, name := range ladder {
+		if strings.HasPrefix(lower, strings.ToLower(name)+"-") {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyOverCapAction carries out the over-cap action of the budget denying a
+// request. Warn budgets let it through with a warning header and downgrade
+// budgets switch it to their model; otherwise the request is refused with a
+// provider-native error the tool can show. It returns the body to forward, or
+// false once the refusal has been written.
+func (h *Handler) applyOverCapAction(w http.ResponseWriter, body []byte, preq *proxyRequest, denial *budget.Denial) ([]byte, bool) {
+	w.Header().Set(budgetHeader, denial.Status.Budget.ID)
+
+	switch denial.Action() {
+	case budget.ActionWarn:
+		h.warnOverCap(w, preq, denial)
+		return body, true
+
+	case budget.ActionDowngrade:
+		var req map[string]interface{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			break
+		}
+		model, _ := req["model"].(string) //nolint:errcheck // a missing model is replaced too
+		target := denial.Model()
+		if model == target {
+			// Already on the budget's model: there is nothing cheaper to
+			// switch to, so let it through with a warning
+			h.warnOverCap(w, preq, denial)
+			return body, true
+		}
+		req["model"] = target
+		rewritten, err := json.Marshal(req)
+		if err != nil {
+			break
+		}
+
+		// The downgraded request may match budgets the original did not, such
+		// as one on the downgrade model itself
+		if again := h.checkBudgetBeforeRequest(rewritten, preq); again != nil {
+			if again.Action() != budget.ActionWarn && again.Action() != budget.ActionDowngrade {
+				denial = again
+				w.Header().Set(budgetHeader, denial.Status.Budget.ID)
+				break
+			}
+			w.Header().Set(budgetWarningHeader, again.Error())
+		}
+		preq.rewrites = append(preq.rewrites, Rewrite{
+			PolicyID: budgetPolicyID,
+			Action:   "downgrade_model",
+			Detail:   fmt.Sprintf("%s -> %s (over cap: %s)", model, target, denial.Error()),
+		})
+		logging.Info("Budget exceeded, downgrading",
+			logging.String("budget", denial.Status.Budget.ID),
+			logging.String("from", model),
+			logging.String("to", target))
+		return rewritten, true
+	}
+
+	writeProviderError(w, preq.providerType, http.StatusTooManyRequests,
+		fmt.Sprintf("BobaMixer budget exceeded: %s. %s", denial.Error(), denial.Hint()))
+	logging.Warn("Budget check failed",
+		logging.String("budget", denial.Status.Budget.ID),
+		logging.String("error", denial.Error()))
+	return nil, false
+}
+
+// warnOverCap lets a request over a budget through with a warning header
+func (h *Handler) warnOverCap(w http.ResponseWriter, preq *proxyRequest, denial *budget.Denial) {
+	w.Header().Set(budgetWarningHeader, denial.Error())
+	preq.rewrites = append(preq.rewrites, Rewrite{
+		PolicyID: budgetPolicyID,
+		Action:   "warn",
+		Detail:   denial.Error(),
+	})
+	logging.Warn("Budget exceeded, allowing with a warning",
+		logging.String("budget", denial.Status.Budget.ID),
+		logging.String("error", denial.Error()))
+}
@@ -0,0 +1,428 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/domain/core"
+	"github.com/royisme/bobamixer/internal/domain/pricing"
+)
+
+func TestDowngradeModel(t *testing.T) {
+	ladder := []string{"claude-opus-4-1", "claude-sonnet-4-5", "claude-haiku-4-5"}
+	tests := []struct {
+		model, hint, want string
+	}{
+		{"claude-opus-4-1", budget.HintNearCap, "claude-sonnet-4-5"},
+		{"claude-opus-4-1-20250805", budget.HintNearCap, "claude-sonnet-4-5"},
+		{"claude-sonnet-4-5", budget.HintNearCap, "claude-haiku-4-5"},
+		{"claude-haiku-4-5", budget.HintNearCap, "claude-haiku-4-5"},
+		{"claude-opus-4-1", budget.HintOverCap, "claude-haiku-4-5"},
+		{"claude-opus-4-1", budget.HintNormal, "claude-opus-4-1"},
+		{"gpt-4o", budget.HintOverCap, ""},
+	}
+	for _, tt := range tests {
+		if got := downgradeModel(ladder, tt.model, tt.hint); got != tt.want {
+			t.Errorf("downgradeModel(%s, %s) = %q, want %q", tt.model, tt.hint, got, tt.want)
+		}
+	}
+}
+
+func TestHandlerDowngradesNearBudgetCap(t *testing.T) {
+	var upstreamModel string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req) //nolint:errcheck // asserted below
+		upstreamModel, _ = req["model"].(string)
+		w.Write([]byte(`{"usage":{"input_tokens":10,"output_tokens":5}}`)) //nolint:errcheck // test server
+	}))
+	defer upstream.Close()
+
+	handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	handler.SetControlPlane(
+		&core.ProvidersConfig{Providers: []core.Provider{{
+			ID: "anthropic", Kind: core.ProviderKindAnthropic, BaseURL: upstream.URL,
+			APIKey: core.APIKeyConfig{Source: core.APIKeySourceSecrets},
+		}}},
+		&core.BindingsConfig{Bindings: []core.Binding{{
+			ToolID: "claude", ProviderID: "anthropic",
+			Options: core.BindingOptions{Downgrade: []string{"claude-opus-4-1", "claude-sonnet-4-5", "claude-haiku-4-5"}},
+		}}},
+		&core.SecretsConfig{Secrets: map[string]core.Secret{"anthropic": {APIKey: "key"}}},
+	)
+
+	send := func() {
+		req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages",
+			strings.NewReader(`{"model":"claude-opus-4-1","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("X-Tool-ID", "claude")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	// No budget: the requested model goes through untouched
+	send()
+	if upstreamModel != "claude-opus-4-1" {
+		t.Fatalf("upstream model = %s without a budget, want claude-opus-4-1", upstreamModel)
+	}
+
+	// 85% of a $10 daily budget spent: one rung down
+	if _, err := handler.budgetTracker.CreateBudget("global", "", 10, 0); err != nil {
+		t.Fatalf("CreateBudget: %v", err)
+	}
+	now := time.Now().Unix()
+	if err := handler.db.Exec(fmt.Sprintf(`INSERT INTO sessions (id, started_at, ended_at, success, latency_ms) VALUES ('spent', %d, %d, 1, 0);
+		INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost) VALUES ('spent', 'spent', %d, 8.5, 0);`, now, now, now)); err != nil {
+		t.Fatalf("seed spend: %v", err)
+	}
+	send()
+	if upstreamModel != "claude-sonnet-4-5" {
+		t.Errorf("upstream model = %s near the cap, want claude-sonnet-4-5", upstreamModel)
+	}
+
+	detail, err := handler.db.QueryRow("SELECT detail FROM policy_rewrites WHERE policy_id = 'budget' AND action = 'downgrade_model';")
+	if err != nil {
+		t.Fatalf("query rewrites: %v", err)
+	}
+	if !strings.Contains(detail, "claude-opus-4-1 -> claude-sonnet-4-5 (near_cap, 85%") {
+		t.Errorf("recorded downgrade = %q", detail)
+	}
+
+	// A request that would go over the cap at its model takes the last rung
+	// rather than being refused, though the critical threshold is not reached
+	handler.SetPricingTable(&pricing.Table{Models: map[string]pricing.ModelPrice{
+		"claude-opus-4-1": {InputPer1K: 1000, OutputPer1K: 1000},
+	}})
+	send()
+	if upstreamModel != "claude-haiku-4-5" {
+		t.Errorf("upstream model = %s for a request over the cap, want claude-haiku-4-5", upstreamModel)
+	}
+
+	// The configured thresholds apply: 85% is below a 90% warning
+	handler.SetPricingTable(&pricing.Table{})
+	handler.SetAlertConfig(&budget.AlertConfig{EnableDaily: true, EnableCap: true, WarningPercent: 90, CriticalPercent: 95})
+	send()
+	if upstreamModel != "claude-opus-4-1" {
+		t.Errorf("upstream model = %s below the configured warning, want claude-opus-4-1", upstreamModel)
+	}
+}
+
+func TestHandlerBlocksOnMatchingBudget(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"usage":{"input_tokens":10,"output_tokens":5}}`)) //nolint:errcheck // test server
+	}))
+	defer upstream.Close()
+
+	handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	handler.SetControlPlane(
+		&core.ProvidersConfig{Providers: []core.Provider{{
+			ID: "zai", Kind: core.ProviderKindAnthropic, BaseURL: upstream.URL,
+			APIKey: core.APIKeyConfig{Source: core.APIKeySourceSecrets},
+		}}},
+		&core.BindingsConfig{Bindings: []core.Binding{{ToolID: "claude", ProviderID: "zai"}}},
+		&core.SecretsConfig{Secrets: map[string]core.Secret{"zai": {APIKey: "key"}}},
+	)
+
+	send := func(model string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages",
+			strings.NewReader(`{"model":"`+model+`","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("X-Tool-ID", "claude")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Spending through the binding is recorded with its tool, provider and binding
+	if rec := send("claude-opus-4-1"); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	row, err := handler.db.QueryRow("SELECT tool, provider, binding FROM usage_records LIMIT 1;")
+	if err != nil {
+		t.Fatalf("query usage: %v", err)
+	}
+	if row != "claude|zai|claude/zai" {
+		t.Errorf("recorded tool|provider|binding = %q", row)
+	}
+
+	// Claude on Opus is spent out; other models and tools are not
+	opus, err := handler.budgetTracker.CreateBudgetWithPeriod("tool+model", "claude+claude-opus-*", budget.PeriodSpec{Period: budget.PeriodDaily}, 0, 1)
+	if err != nil {
+		t.Fatalf("CreateBudgetWithPeriod: %v", err)
+	}
+	if _, err := handler.budgetTracker.CreateBudget("provider", "zai", 0, 100); err != nil {
+		t.Fatalf("CreateBudget: %v", err)
+	}
+	now := time.Now().Unix()
+	if err := handler.db.Exec(fmt.Sprintf(`INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost, tool, model, provider)
+		VALUES ('spent', 'spent', %d, 1.5, 0, 'claude', 'claude-opus-4-1', 'zai');`, now)); err != nil {
+		t.Fatalf("seed spend: %v", err)
+	}
+
+	rec := send("claude-opus-4-1")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get(budgetHeader); got != opus.ID {
+		t.Errorf("%s = %q, want %q", budgetHeader, got, opus.ID)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "tool+model claude+claude-opus-* (daily) budget") {
+		t.Errorf("429 body = %q, want it to name the blocking budget", body)
+	}
+
+	if rec := send("claude-sonnet-4-5"); rec.Code != http.StatusOK {
+		t.Errorf("sonnet status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandlerOverCapActions(t *testing.T) {
+	var upstreamModel string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req) //nolint:errcheck // test server
+		upstreamModel, _ = req["model"].(string)
+		w.Write([]byte(`{"usage":{"input_tokens":10,"output_tokens":5}}`)) //nolint:errcheck // test server
+	}))
+	defer upstream.Close()
+
+	handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	handler.SetControlPlane(
+		&core.ProvidersConfig{Providers: []core.Provider{
+			{ID: "zai", Kind: core.ProviderKindAnthropic, BaseURL: upstream.URL, APIKey: core.APIKeyConfig{Source: core.APIKeySourceSecrets}},
+			{ID: "oai", Kind: core.ProviderKindOpenAI, BaseURL: upstream.URL, APIKey: core.APIKeyConfig{Source: core.APIKeySourceSecrets}},
+		}},
+		&core.BindingsConfig{Bindings: []core.Binding{{ToolID: "claude", ProviderID: "zai"}, {ToolID: "codex", ProviderID: "oai"}}},
+		&core.SecretsConfig{Secrets: map[string]core.Secret{"zai": {APIKey: "key"}, "oai": {APIKey: "key"}}},
+	)
+
+	// Both tools have spent their $1 a day
+	claude, err := handler.budgetTracker.CreateBudgetWithPeriod("tool", "claude", budget.PeriodSpec{Period: budget.PeriodDaily}, 0, 1)
+	if err != nil {
+		t.Fatalf("CreateBudgetWithPeriod: %v", err)
+	}
+	codex, err := handler.budgetTracker.CreateBudgetWithPeriod("tool", "codex", budget.PeriodSpec{Period: budget.PeriodDaily}, 0, 1)
+	if err != nil {
+		t.Fatalf("CreateBudgetWithPeriod: %v", err)
+	}
+	if err := handler.db.Exec(fmt.Sprintf(`INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost, tool) VALUES
+		('c', 'c', %[1]d, 1.5, 0, 'claude'), ('x', 'x', %[1]d, 1.5, 0, 'codex');`, time.Now().Unix())); err != nil {
+		t.Fatalf("seed spend: %v", err)
+	}
+
+	send := func(tool, path, model string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path,
+			strings.NewReader(`{"model":"`+model+`","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("X-Tool-ID", tool)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	sendClaude := func() *httptest.ResponseRecorder { return send("claude", "/anthropic/v1/messages", "claude-opus-4-1") }
+
+	// Blocked requests get each API's own error schema
+	rec := sendClaude()
+	var anthropicErr struct {
+		Type  string `json:"type"`
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &anthropicErr); err != nil {
+		t.Fatalf("anthropic error body %q: %v", rec.Body.String(), err)
+	}
+	if rec.Code != http.StatusTooManyRequests || anthropicErr.Type != "error" || anthropicErr.Error.Type != "rate_limit_error" {
+		t.Errorf("blocked claude request = %d %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(anthropicErr.Error.Message, "BobaMixer budget exceeded: tool claude (daily) budget") ||
+		!strings.Contains(anthropicErr.Error.Message, "--cap <usd>") {
+		t.Errorf("message = %q", anthropicErr.Error.Message)
+	}
+	if rec.Header().Get("x-should-retry") != "false" {
+		t.Error("blocked requests should tell SDKs not to retry")
+	}
+
+	rec = send("codex", "/openai/v1/chat/completions", "gpt-5")
+	var openAIErr struct {
+		Error struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			Code    string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &openAIErr); err != nil {
+		t.Fatalf("openai error body %q: %v", rec.Body.String(), err)
+	}
+	if openAIErr.Error.Type != "insufficient_quota" || openAIErr.Error.Code != "budget_exceeded" ||
+		!strings.Contains(openAIErr.Error.Message, "tool codex (daily) budget") {
+		t.Errorf("blocked codex request = %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Downgrade sends the request on with the budget's model
+	if err := handler.budgetTracker.SetAction(claude.ID, budget.ActionDowngrade, "claude-haiku-4-5"); err != nil {
+		t.Fatalf("SetAction: %v", err)
+	}
+	if rec := sendClaude(); rec.Code != http.StatusOK || upstreamModel != "claude-haiku-4-5" {
+		t.Errorf("downgrade: status %d, upstream model %q", rec.Code, upstreamModel)
+	}
+
+	// A request already on the budget's model has nowhere cheaper to go, so
+	// it goes through as is with a warning
+	rec = send("claude", "/anthropic/v1/messages", "claude-haiku-4-5")
+	if rec.Code != http.StatusOK || upstreamModel != "claude-haiku-4-5" {
+		t.Errorf("downgrade on the budget's model: status %d, upstream model %q", rec.Code, upstreamModel)
+	}
+	if got := rec.Header().Get(budgetWarningHeader); !strings.Contains(got, "tool claude (daily) budget") {
+		t.Errorf("downgrade on the budget's model: %s = %q", budgetWarningHeader, got)
+	}
+
+	// The downgraded request is checked again, against budgets on its new model
+	haiku, err := handler.budgetTracker.CreateBudgetWithPeriod("model", "claude-haiku-*", budget.PeriodSpec{Period: budget.PeriodDaily}, 0, 1)
+	if err != nil {
+		t.Fatalf("CreateBudgetWithPeriod: %v", err)
+	}
+	if err := handler.db.Exec(fmt.Sprintf(`INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost, tool, model)
+		VALUES ('h', 'h', %d, 1.5, 0, 'other', 'claude-haiku-4-5');`, time.Now().Unix())); err != nil {
+		t.Fatalf("seed spend: %v", err)
+	}
+	rec = sendClaude()
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get(budgetHeader) != haiku.ID {
+		t.Errorf("downgrade over the new model's budget = %d, %s %q", rec.Code, budgetHeader, rec.Header().Get(budgetHeader))
+	}
+	if err := handler.db.Exec(fmt.Sprintf("DELETE FROM budgets WHERE id = '%s'; DELETE FROM usage_records WHERE id = 'h';", haiku.ID)); err != nil {
+		t.Fatalf("delete budget: %v", err)
+	}
+
+	// Warn lets it through as is and says why
+	if err := handler.budgetTracker.SetAction(claude.ID, budget.ActionWarn, ""); err != nil {
+		t.Fatalf("SetAction: %v", err)
+	}
+	rec = sendClaude()
+	if rec.Code != http.StatusOK || upstreamModel != "claude-opus-4-1" {
+		t.Errorf("warn: status %d, upstream model %q", rec.Code, upstreamModel)
+	}
+	if got := rec.Header().Get(budgetWarningHeader); !strings.Contains(got, "tool claude (daily) budget") {
+		t.Errorf("%s = %q", budgetWarningHeader, got)
+	}
+
+	// Override blocks until an override is granted
+	if err := handler.budgetTracker.SetAction(codex.ID, budget.ActionOverride, ""); err != nil {
+		t.Fatalf("SetAction: %v", err)
+	}
+	rec = send("codex", "/openai/v1/chat/completions", "gpt-5")
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "boba budget allow --for 30m") {
+		t.Errorf("override without grant = %d %s", rec.Code, rec.Body.String())
+	}
+	if _, err := handler.budgetTracker.Allow(codex, 30*time.Minute); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if rec := send("codex", "/openai/v1/chat/completions", "gpt-5"); rec.Code != http.StatusOK {
+		t.Errorf("override with grant = %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandlerMatchesEveryBudgetScope(t *testing.T) {
+	var forwarded http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Clone()
+		w.Write([]byte(`{"usage":{"input_tokens":10,"output_tokens":5}}`)) //nolint:errcheck // test server
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		scope  string
+		target string
+	}{
+		{scope: "project", target: "my-app"},
+		{scope: "profile", target: "fast"},
+		{scope: "tool", target: "claude"},
+		{scope: "provider", target: "zai"},
+		{scope: "binding", target: "claude/zai"},
+		{scope: "model", target: "claude-opus-*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+			if err != nil {
+				t.Fatalf("NewHandler: %v", err)
+			}
+			handler.SetControlPlane(
+				&core.ProvidersConfig{Providers: []core.Provider{{
+					ID: "zai", Kind: core.ProviderKindAnthropic, BaseURL: upstream.URL,
+					APIKey: core.APIKeyConfig{Source: core.APIKeySourceSecrets},
+				}}},
+				&core.BindingsConfig{Bindings: []core.Binding{{ToolID: "claude", ProviderID: "zai"}}},
+				&core.SecretsConfig{Secrets: map[string]core.Secret{"zai": {APIKey: "key"}}},
+			)
+			send := func(tool, model string, scoped bool) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages",
+					strings.NewReader(`{"model":"`+model+`","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`))
+				req.Header.Set("X-Tool-ID", tool)
+				req.Header.Set("X-Proxy-Target", upstream.URL)
+				if scoped {
+					req.Header.Set(projectHeader, "my-app")
+					req.Header.Set(profileHeader, "fast")
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				return rec
+			}
+
+			// The request is attributed on its session and the scope headers stay local
+			if rec := send("claude", "claude-opus-4-1", true); rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+			}
+			if forwarded.Get(projectHeader) != "" || forwarded.Get(profileHeader) != "" {
+				t.Errorf("scope headers forwarded upstream: %v", forwarded)
+			}
+			row, err := handler.db.QueryRow("SELECT project, profile FROM sessions;")
+			if err != nil {
+				t.Fatalf("query session: %v", err)
+			}
+			if row != "my-app|fast" {
+				t.Errorf("session project|profile = %q, want my-app|fast", row)
+			}
+
+			b, err := handler.budgetTracker.CreateBudgetWithPeriod(tt.scope, tt.target, budget.PeriodSpec{Period: budget.PeriodDaily}, 0, 1)
+			if err != nil {
+				t.Fatalf("CreateBudgetWithPeriod: %v", err)
+			}
+			now := time.Now().Unix()
+			if err := handler.db.Exec(fmt.Sprintf(`INSERT INTO sessions (id, started_at, project, profile) VALUES ('spent', %d, 'my-app', 'fast');
+				INSERT INTO usage_records (id, session_id, ts, input_cost, output_cost, tool, model, provider, binding)
+				VALUES ('spent', 'spent', %d, 1.5, 0, 'claude', 'claude-opus-4-1', 'zai', 'claude/zai');`, now, now)); err != nil {
+				t.Fatalf("seed spend: %v", err)
+			}
+
+			rec := send("claude", "claude-opus-4-1", true)
+			if rec.Code != http.StatusTooManyRequests {
+				t.Fatalf("status = %d, want 429: %s", rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get(budgetHeader); got != b.ID {
+				t.Errorf("%s = %q, want %q", budgetHeader, got, b.ID)
+			}
+
+			// An unbound tool on another model outside the project matches nothing
+			if rec := send("codex", "gpt-5", false); rec.Code != http.StatusOK {
+				t.Errorf("unmatched status = %d, want 200: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// writeProviderError answers in the error schema of the API the tool speaks,
+// so the tool shows the message instead of failing to parse a plain-text body.
+// The x-should-retry header tells the official SDKs not to retry.
+func writeProviderError(w http.ResponseWriter, providerType string, status int, message string) {
+	var body any
+	if providerType == providerAnthropic {
+		body = map[string]any{
+			"type": "error",
+			"error": map[string]string{
+				"type":    anthropicErrorType(status),
+				"message": message,
+			},
+		}
+	} else {
+		body = map[string]any{
+			"error": map[string]any{
+				"message": message,
+				"type":    openAIErrorType(status),
+				"param":   nil,
+				"code":    openAIErrorCode(status),
+			},
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("x-should-retry", "false")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body) //nolint:errcheck // client may have disconnected
+}
+
+// anthropicErrorType maps a status to Anthropic's error types
+func anthropicErrorType(status int) string {
+	switch status {
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	default:
+		return "invalid_request_error"
+	}
+}
+
+// openAIErrorType maps a status to OpenAI's error types; spent budgets read
+// like an exhausted quota
+func openAIErrorType(status int) string {
+	switch status {
+	case http.StatusTooManyRequests:
+		return "insufficient_quota"
+	default:
+		return "invalid_request_error"
+	}
+}
+
+func openAIErrorCode(status int) string {
+	if status == http.StatusTooManyRequests {
+		return "budget_exceeded"
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/royisme/bobamixer/internal/domain/tokenizer"
+)
+
+// estimateExact marks usage_records rows whose counts the provider reported
+const estimateExact = "exact"
+
+// responseUsage is the token usage reported in a JSON or SSE response
+type responseUsage struct {
+	InputTokens  int
+	OutputTokens int
+	CachedTokens int // Anthropic prompt-cache reads and writes, billed apart from input
+}
+
+// parseResponseUsage collects usage from a JSON body or from the events of a
+// streamed response (Anthropic message_start/message_delta, OpenAI's final chunk)
+func parseResponseUsage(body []byte) responseUsage {
+	var u responseUsage
+	forEachResponseObject(body, func(obj map[string]interface{}) {
+		usage, ok := obj["usage"].(map[string]interface{})
+		if !ok {
+			// Anthropic message_start nests usage in the message
+			msg, _ := obj["message"].(map[string]interface{}) //nolint:errcheck // absent means none
+			usage, ok = msg["usage"].(map[string]interface{})
+			if !ok {
+				return
+			}
+		}
+		get := func(key string) int {
+			v, _ := usage[key].(float64) //nolint:errcheck // absent means zero
+			return int(v)
+		}
+		// Later events carry running totals, so keep the largest value seen
+		u.InputTokens = max(u.InputTokens, get("input_tokens"), get("prompt_tokens"))
+		u.OutputTokens = max(u.OutputTokens, get("output_tokens"), get("completion_tokens"))
+		u.CachedTokens = max(u.CachedTokens, get("cache_read_input_tokens")+get("cache_creation_input_tokens"))
+	})
+	return u
+}
+
+// forEachResponseObject calls fn for a JSON response body, or for every data event of an SSE stream
+func forEachResponseObject(body []byte, fn func(obj map[string]interface{})) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(trimmed, &obj); err == nil {
+			fn(obj)
+			return
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &obj); err != nil {
+			continue
+		}
+		fn(obj)
+	}
+}
+
+// estimateUsage counts tokens locally when the upstream reported none. Input comes
+// from the prompt count taken before forwarding, output from the response text.
+func estimateUsage(model string, prompt *tokenizer.RequestCount, respBody []byte) (inputTokens, outputTokens int, level string) {
+	if prompt != nil {
+		inputTokens = prompt.Tokens
+	}
+	if text := extractOutputText(respBody); text != "" {
+		outputTokens = tokenizer.CountText(model, text).Tokens
+	}
+	return inputTokens, outputTokens, tokenizer.EstimateLevel(model)
+}
+
+// recordPromptSize stores the prompt size beside an exact count on the record, the
+// sample `boba tokenizer calibrate` fits coefficients from. Prompts with attachments
+// are skipped since those are counted at a flat rate.
+func recordPromptSize(prompt *tokenizer.RequestCount, usage responseUsage, record *UsageRecord) {
+	if prompt == nil || prompt.Attachments > 0 || usage.InputTokens == 0 {
+		return
+	}
+
+	// Stored input_tokens exclude prompt-cache tokens, so cached prompts are not samples
+	if usage.CachedTokens == 0 {
+		record.PromptChars = prompt.Chars
+		record.PromptCodeChars = prompt.CodeChars
+		record.PromptOverhead = prompt.Overhead
+		record.EstimatedInputTokens = prompt.Tokens
+	}
+}
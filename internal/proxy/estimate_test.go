@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/currency"
+	"github.com/royisme/bobamixer/internal/domain/pricing"
+)
+
+func TestParseResponseUsageFromStream(t *testing.T) {
+	stream := strings.Join([]string{
+		"event: message_start",
+		`data: {"type":"message_start","message":{"usage":{"input_tokens":120,"cache_read_input_tokens":30,"output_tokens":1}}}`,
+		"event: content_block_delta",
+		`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"Hi"}}`,
+		"event: message_delta",
+		`data: {"type":"message_delta","usage":{"output_tokens":45}}`,
+		"",
+	}, "\n")
+	got := parseResponseUsage([]byte(stream))
+	if got.InputTokens != 120 || got.OutputTokens != 45 || got.CachedTokens != 30 {
+		t.Errorf("anthropic stream usage = %+v", got)
+	}
+
+	openai := "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\ndata: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":3}}\n\ndata: [DONE]\n"
+	if got := parseResponseUsage([]byte(openai)); got.InputTokens != 12 || got.OutputTokens != 3 {
+		t.Errorf("openai stream usage = %+v", got)
+	}
+}
+
+func TestHandlerEstimatesMissingUsage(t *testing.T) {
+	withUsage := true
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"Hello there, how can I help you today?\"}}]}\n\n")) //nolint:errcheck // test server
+		if withUsage {
+			w.Write([]byte("data: {\"choices\":[],\"usage\":{\"prompt_tokens\":21,\"completion_tokens\":10}}\n\n")) //nolint:errcheck // test server
+		}
+		w.Write([]byte("data: [DONE]\n\n")) //nolint:errcheck // test server
+	}))
+	defer upstream.Close()
+
+	handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	send := func() {
+		body := `{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"Hello world, this is a test."}]}`
+		req := httptest.NewRequest(http.MethodPost, "/openai/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("X-Proxy-Target", upstream.URL)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	send()
+	withUsage = false
+	send()
+
+	rows, err := handler.db.QueryRows("SELECT estimate_level, input_tokens, output_tokens, prompt_chars FROM usage_records ORDER BY rowid;")
+	if err != nil {
+		t.Fatalf("query usage: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("usage records = %v, want 2", rows)
+	}
+	// Exact rows keep the prompt size for `boba tokenizer calibrate`
+	if rows[0] != "exact|21|10|28" {
+		t.Errorf("streamed usage row = %q, want exact|21|10|28", rows[0])
+	}
+	fields := strings.Split(rows[1], "|")
+	if fields[0] != "mapped" || fields[1] == "0" || fields[2] == "0" || fields[3] != "0" {
+		t.Errorf("estimated row = %q, want counts mapped from the cl100k vocabulary", rows[1])
+	}
+}
+
+func TestHandlerRecordsUnconvertiblePricesUnpriced(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"usage":{"prompt_tokens":1000,"completion_tokens":1000}}`)) //nolint:errcheck // test server
+	}))
+	defer upstream.Close()
+
+	handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	handler.SetPricingTable(&pricing.Table{Models: map[string]pricing.ModelPrice{
+		"glm-4.6": {InputPer1K: 0.004, OutputPer1K: 0.016, Currency: "CNY"},
+	}})
+	send := func() *httptest.ResponseRecorder {
+		body := `{"model":"glm-4.6","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/openai/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("X-Proxy-Target", upstream.URL)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without a CNY rate the usage is kept at no cost, never as dollars
+	if rec := send(); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	row, err := handler.db.QueryRow("SELECT input_cost, output_cost, unpriced_currency FROM usage_records;")
+	if err != nil {
+		t.Fatalf("query usage: %v", err)
+	}
+	if row != "0.0|0.0|CNY" {
+		t.Errorf("usage row = %q, want no cost and the CNY currency", row)
+	}
+	unpriced, err := currency.NewRates(handler.db).UnpricedUsage()
+	if err != nil || unpriced["CNY"] != 1 {
+		t.Errorf("UnpricedUsage = %v, %v; want 1 CNY record", unpriced, err)
+	}
+
+	// A budget cannot be checked against a cost it cannot price, so it denies
+	if _, err := handler.budgetTracker.CreateBudget("global", "", 0, 100); err != nil {
+		t.Fatalf("CreateBudget: %v", err)
+	}
+	if rec := send(); rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "Cannot price the request") {
+		t.Errorf("unpriced request under a budget = %d %s, want it denied", rec.Code, rec.Body.String())
+	}
+
+	if err := currency.NewRates(handler.db).Set("CNY", time.Now(), 8); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if rec := send(); rec.Code != http.StatusOK {
+		t.Fatalf("status with a rate = %d: %s", rec.Code, rec.Body.String())
+	}
+	row, err = handler.db.QueryRow("SELECT input_cost, output_cost, unpriced_currency FROM usage_records ORDER BY rowid DESC LIMIT 1;")
+	if err != nil {
+		t.Fatalf("query usage: %v", err)
+	}
+	if row != "0.0005|0.002|" {
+		t.Errorf("usage row with a rate = %q, want the dollar cost", row)
+	}
+}
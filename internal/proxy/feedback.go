@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/royisme/bobamixer/internal/domain/bandit"
+	"github.com/royisme/bobamixer/internal/domain/session"
+	"github.com/royisme/bobamixer/internal/logging"
+)
+
+const (
+	// feedbackPath is where editor plugins post session ratings
+	feedbackPath = "/feedback"
+
+	// sessionHeader carries the proxy session ID on responses, so clients can rate them
+	sessionHeader = "X-Boba-Session"
+
+	maxFeedbackBytes = 64 << 10
+)
+
+// feedbackRequest is the body of a rating. SessionID may be "last" or a unique
+// prefix, and defaults to the last session; Rating is good or bad. With an
+// X-Tool-ID header the last session is that tool's.
+type feedbackRequest struct {
+	SessionID string `json:"session_id"`
+	Rating    string `json:"rating"`
+	Note      string `json:"note"`
+}
+
+// handleFeedback records a rating posted by an editor plugin
+func (h *Handler) handleFeedback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req feedbackRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxFeedbackBytes)).Decode(&req); err != nil {
+		http.Error(w, "Invalid feedback body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	rating, err := session.ParseRating(req.Rating)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.SessionID == "" {
+		req.SessionID = session.LastRef
+	}
+	var id string
+	if tool := r.Header.Get("X-Tool-ID"); tool != "" && req.SessionID == session.LastRef {
+		id, err = session.LastForTool(h.db, tool)
+	} else {
+		id, err = session.ResolveID(h.db, req.SessionID)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, session.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if err := session.Rate(h.db, session.Feedback{
+		SessionID: id,
+		Rating:    rating,
+		Note:      req.Note,
+		Source:    session.FeedbackSourceProxy,
+	}); err != nil {
+		logging.Error("Failed to save feedback", logging.String("session_id", id), logging.Err(err))
+		http.Error(w, "Failed to save feedback", http.StatusInternalServerError)
+		return
+	}
+	if _, err := bandit.NewStore(h.db).Learn(bandit.DefaultWeights); err != nil {
+		logging.Warn("Failed to update routing bandit", logging.Err(err))
+	}
+	logging.Info("Session rated",
+		logging.String("session_id", id),
+		logging.String("rating", session.RatingLabel(rating)))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{ //nolint:errcheck // client may have disconnected
+		"session_id": id,
+		"rating":     session.RatingLabel(rating),
+	})
+}
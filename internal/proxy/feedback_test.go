@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/core"
+	"github.com/royisme/bobamixer/internal/domain/routing"
+	"github.com/royisme/bobamixer/internal/domain/session"
+	"github.com/royisme/bobamixer/internal/domain/stats"
+	"github.com/royisme/bobamixer/internal/store/config"
+)
+
+func TestHandlerFeedbackRatesProxiedSession(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"model":"claude-haiku-4-5","usage":{"input_tokens":10,"output_tokens":5}}`)) //nolint:errcheck // test server
+	}))
+	defer upstream.Close()
+
+	handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	handler.SetControlPlane(
+		&core.ProvidersConfig{Providers: []core.Provider{{
+			ID: "anthropic", Kind: core.ProviderKindAnthropic, BaseURL: upstream.URL,
+			APIKey: core.APIKeyConfig{Source: core.APIKeySourceSecrets},
+		}}},
+		&core.BindingsConfig{Bindings: []core.Binding{{ToolID: "claude", ProviderID: "anthropic"}}},
+		&core.SecretsConfig{Secrets: map[string]core.Secret{"anthropic": {APIKey: "key"}}},
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages",
+		strings.NewReader(`{"model":"claude-haiku-4-5","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("X-Tool-ID", "claude")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	sessionID := rec.Header().Get(sessionHeader)
+	if rec.Code != http.StatusOK || sessionID == "" {
+		t.Fatalf("status = %d, session header = %q", rec.Code, sessionID)
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, feedbackPath, strings.NewReader(body)))
+		return rec
+	}
+	postAs := func(tool, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, feedbackPath, strings.NewReader(body))
+		req.Header.Set("X-Tool-ID", tool)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post(`{"session_id":"` + sessionID + `","rating":"bad","note":"wrong file"}`); rec.Code != http.StatusOK {
+		t.Fatalf("feedback status = %d: %s", rec.Code, rec.Body.String())
+	}
+	fb, err := session.GetFeedback(handler.db, sessionID)
+	if err != nil || fb == nil {
+		t.Fatalf("GetFeedback = %v, %v", fb, err)
+	}
+	if fb.Rating != session.RatingBad || fb.Note != "wrong file" || fb.Source != session.FeedbackSourceProxy {
+		t.Errorf("feedback = %+v", fb)
+	}
+
+	// Without a session ID the last session is rated, replacing the earlier rating
+	if rec := post(`{"rating":"good"}`); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), sessionID) {
+		t.Fatalf("feedback on last session = %d: %s", rec.Code, rec.Body.String())
+	}
+	if fb, _ := session.GetFeedback(handler.db, sessionID); fb == nil || fb.Rating != session.RatingGood { //nolint:errcheck // checked above
+		t.Errorf("feedback after re-rating = %+v, want good", fb)
+	}
+
+	// A tool's "last" is its own last session, not another tool's newer one
+	if err := handler.db.Exec(`INSERT INTO sessions (id, started_at) VALUES ('codex-later', 4102444800);
+		INSERT INTO usage_records (id, session_id, ts, tool) VALUES ('codex-later', 'codex-later', 4102444800, 'codex');`); err != nil {
+		t.Fatalf("seed session: %v", err)
+	}
+	if rec := postAs("claude", `{"rating":"bad"}`); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), sessionID) {
+		t.Errorf("claude feedback on last session = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := postAs("gemini", `{"rating":"bad"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("feedback for a tool without sessions = %d, want 404", rec.Code)
+	}
+
+	if rec := post(`{"session_id":"missing","rating":"good"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown session status = %d, want 404", rec.Code)
+	}
+	if rec := post(`{"rating":"meh"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid rating status = %d, want 400", rec.Code)
+	}
+	getRec := httptest.NewRecorder()
+	handler.ServeHTTP(getRec, httptest.NewRequest(http.MethodGet, feedbackPath, nil))
+	if getRec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want 405", getRec.Code)
+	}
+}
+
+func TestFeedbackOnRoutedProxiedSessionReachesBanditAndStats(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"usage":{"prompt_tokens":10,"completion_tokens":5}}`)) //nolint:errcheck // test server
+	}))
+	defer upstream.Close()
+
+	handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	engine, err := routing.CompileRoutes(&config.RoutesConfig{
+		Rules: []config.RouteRule{{ID: "everything", If: "true", Use: "quick"}},
+	})
+	if err != nil {
+		t.Fatalf("CompileRoutes: %v", err)
+	}
+	handler.SetRoutingEngine(engine)
+	handler.SetProfiles(config.Profiles{"quick": {Key: "quick", Model: "gpt-4o-mini"}})
+
+	req := httptest.NewRequest(http.MethodPost, "/openai/v1/chat/completions",
+		strings.NewReader(`{"model":"gpt-4o-mini","messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("X-Proxy-Target", upstream.URL)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	sessionID := rec.Header().Get(sessionHeader)
+	if rec.Code != http.StatusOK || sessionID == "" {
+		t.Fatalf("status = %d, session header = %q", rec.Code, sessionID)
+	}
+
+	beta := func() float64 {
+		row, err := handler.db.QueryRow("SELECT beta FROM bandit_arms WHERE context = 'rule:everything' AND profile = 'quick';")
+		if err != nil || row == "" {
+			t.Fatalf("bandit arm = %q, %v", row, err)
+		}
+		v, err := strconv.ParseFloat(row, 64)
+		if err != nil {
+			t.Fatalf("parse beta %q: %v", row, err)
+		}
+		return v
+	}
+	before := beta()
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, feedbackPath,
+		strings.NewReader(`{"session_id":"`+sessionID+`","rating":"bad"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("feedback status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if after := beta(); after <= before {
+		t.Errorf("bandit beta = %v after a bad rating, want above %v", after, before)
+	}
+
+	today := time.Now()
+	rules, err := stats.SatisfactionWindow(context.Background(), handler.db, today.AddDate(0, 0, -1), today.AddDate(0, 0, 1), stats.SatisfactionByRoute)
+	if err != nil {
+		t.Fatalf("SatisfactionWindow: %v", err)
+	}
+	if len(rules) != 1 || rules[0].Key != "rule:everything" || rules[0].Rated != 1 || rules[0].Good != 0 {
+		t.Errorf("satisfaction by route = %+v, want one bad rating for rule:everything", rules)
+	}
+}
@@ -0,0 +1,320 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/core"
+	"github.com/royisme/bobamixer/internal/logging"
+)
+
+const (
+	// tunnelDialTimeout bounds how long a CONNECT waits for the upstream host
+	tunnelDialTimeout = 10 * time.Second
+
+	// interceptIdleTimeout closes intercepted keep-alive connections left idle
+	interceptIdleTimeout = 120 * time.Second
+)
+
+// defaultInterceptHosts are the AI API hosts whose HTTPS traffic is decrypted and accounted
+var defaultInterceptHosts = map[string]string{
+	"api.openai.com":    providerOpenAI,
+	"api.anthropic.com": providerAnthropic,
+}
+
+// SetCertificateAuthority enables HTTPS interception of known AI hosts in forward-proxy
+// mode (HTTPS_PROXY). Without a CA every CONNECT is tunnelled untouched.
+func (h *Handler) SetCertificateAuthority(ca *CertificateAuthority) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ca = ca
+}
+
+// SetInterceptHost marks host (without port) as an AI API endpoint speaking providerType's
+// protocol, so CONNECT requests to it are intercepted
+func (h *Handler) SetInterceptHost(host, providerType string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.interceptHosts == nil {
+		h.interceptHosts = make(map[string]string)
+	}
+	h.interceptHosts[strings.ToLower(host)] = providerType
+}
+
+// interceptHostsFor derives the intercepted host table from the defaults and the
+// base URLs of configured OpenAI- and Anthropic-style providers
+func interceptHostsFor(providers *core.ProvidersConfig) map[string]string {
+	hosts := make(map[string]string, len(defaultInterceptHosts))
+	for host, providerType := range defaultInterceptHosts {
+		hosts[host] = providerType
+	}
+	if providers == nil {
+		return hosts
+	}
+	for _, p := range providers.Providers {
+		providerType := providerTypeForKind(p.Kind)
+		if providerType == "" {
+			continue
+		}
+		u, err := url.Parse(p.BaseURL)
+		if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+			continue
+		}
+		hosts[strings.ToLower(u.Hostname())] = providerType
+	}
+	return hosts
+}
+
+// interceptTarget returns the provider type for an intercepted host, or "" to tunnel
+func (h *Handler) interceptTarget(hostport string) (string, *CertificateAuthority) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.ca == nil {
+		return "", nil
+	}
+	host := hostport
+	if hostname, _, err := net.SplitHostPort(hostport); err == nil {
+		host = hostname
+	}
+	return h.interceptHosts[strings.ToLower(host)], h.ca
+}
+
+// handleConnect serves an HTTPS_PROXY CONNECT request. Known AI hosts are terminated
+// with a certificate from the local CA and fed through the forwarding pipeline;
+// everything else is tunnelled byte-for-byte.
+func (h *Handler) handleConnect(w http.ResponseWriter, r *http.Request) {
+	providerType, ca := h.interceptTarget(r.Host)
+
+	var upstream net.Conn
+	if providerType == "" {
+		dialer := &net.Dialer{Timeout: tunnelDialTimeout}
+		conn, err := dialer.DialContext(r.Context(), "tcp", r.Host)
+		if err != nil {
+			http.Error(w, "Failed to reach "+r.Host, http.StatusBadGateway)
+			h.incrementErrorCount()
+			return
+		}
+		upstream = conn
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "CONNECT not supported", http.StatusInternalServerError)
+		closeQuietly(upstream)
+		return
+	}
+	client, _, err := hijacker.Hijack()
+	if err != nil {
+		logging.Error("Failed to hijack CONNECT", logging.Err(err))
+		closeQuietly(upstream)
+		return
+	}
+	// The server's read/write timeouts would otherwise cut long-lived tunnels
+	if err := client.SetDeadline(time.Time{}); err != nil {
+		closeQuietly(client)
+		closeQuietly(upstream)
+		return
+	}
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		closeQuietly(client)
+		closeQuietly(upstream)
+		return
+	}
+
+	if providerType == "" {
+		tunnel(client, upstream)
+		return
+	}
+	h.intercept(client, r, providerType, ca)
+}
+
+// tunnel copies bytes in both directions until either side closes
+func tunnel(client, upstream net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+		_, _ = io.Copy(dst, src) //nolint:errcheck // a closed peer ends the tunnel
+		if tcp, ok := dst.(*net.TCPConn); ok {
+			_ = tcp.CloseWrite() //nolint:errcheck // best-effort half close
+		} else {
+			closeQuietly(dst)
+		}
+	}
+	go pipe(upstream, client)
+	go pipe(client, upstream)
+	wg.Wait()
+	closeQuietly(client)
+	closeQuietly(upstream)
+}
+
+// intercept terminates TLS on the client connection and serves the decrypted requests
+func (h *Handler) intercept(client net.Conn, connect *http.Request, providerType string, ca *CertificateAuthority) {
+	host := connect.Host
+	hostname := host
+	if name, port, err := net.SplitHostPort(host); err == nil {
+		hostname = name
+		if port == "443" {
+			host = name
+		}
+	}
+
+	tlsConn := tls.Server(client, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"http/1.1"},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			name := hello.ServerName
+			if name == "" {
+				name = hostname
+			}
+			return ca.LeafFor(name)
+		},
+	})
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.serveIntercepted(w, r, "https://"+host, providerType, connect.Header)
+		}),
+		ReadHeaderTimeout: ReadTimeout,
+		IdleTimeout:       interceptIdleTimeout,
+	}
+	if err := server.Serve(newSingleConnListener(tlsConn)); err != nil && !errors.Is(err, io.EOF) {
+		logging.Warn("Intercepted connection ended", logging.String("host", host), logging.Err(err))
+	}
+}
+
+// serveIntercepted runs a decrypted request through the same pipeline as the
+// reverse proxy. The tool, project and profile headers may come on the request
+// or on the CONNECT that opened the tunnel.
+func (h *Handler) serveIntercepted(w http.ResponseWriter, r *http.Request, targetURL, providerType string, connect http.Header) {
+	startTime := time.Now()
+
+	h.stats.mu.Lock()
+	h.stats.TotalRequests++
+	h.stats.LastRequest = startTime
+	h.stats.mu.Unlock()
+	h.updateProviderStats(providerType)
+
+	header := func(name string) string {
+		if value := r.Header.Get(name); value != "" {
+			return value
+		}
+		return connect.Get(name)
+	}
+	preq := &proxyRequest{
+		sessionID:    generateSessionID(),
+		toolID:       header("X-Tool-ID"),
+		project:      header(projectHeader),
+		profile:      header(profileHeader),
+		providerType: providerType,
+		targetPath:   r.URL.Path,
+		startTime:    startTime,
+	}
+	preq.binding, preq.provider, preq.keyPool = h.resolveProvider(preq.toolID)
+	// The tool chose the destination; only rotate keys that belong to that host
+	if preq.provider != nil && !sameHost(preq.provider.BaseURL, targetURL) {
+		preq.keyPool = nil
+	}
+
+	if err := h.forwardRequest(w, r, targetURL, preq); err != nil {
+		logging.Error("Failed to forward intercepted request",
+			logging.String("error", err.Error()),
+			logging.String("target", targetURL),
+			logging.String("path", preq.targetPath))
+		h.incrementErrorCount()
+	}
+}
+
+// sameHost reports whether two URLs point at the same host
+func sameHost(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	return errA == nil && errB == nil && strings.EqualFold(ua.Hostname(), ub.Hostname())
+}
+
+// serveForward handles plain-HTTP forward-proxy requests (absolute request URIs).
+// Known AI hosts go through the pipeline; anything else is relayed as-is.
+func (h *Handler) serveForward(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	providerType := h.interceptHosts[strings.ToLower(r.URL.Hostname())]
+	h.mu.RUnlock()
+
+	if providerType != "" {
+		target := r.URL.Scheme + "://" + r.URL.Host
+		h.serveIntercepted(w, r, target, providerType, nil)
+		return
+	}
+
+	relay := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = pr.In.URL
+			pr.Out.Host = pr.In.Host
+		},
+		Transport: h.transport(),
+	}
+	relay.ServeHTTP(w, r)
+}
+
+// transport returns the round tripper used for upstream requests
+func (h *Handler) transport() http.RoundTripper {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.upstreamTransport != nil {
+		return h.upstreamTransport
+	}
+	return http.DefaultTransport
+}
+
+// singleConnListener hands one connection to http.Server.Serve, then reports EOF
+type singleConnListener struct {
+	conn net.Conn
+	once sync.Once
+	done chan struct{}
+}
+
+func newSingleConnListener(conn net.Conn) *singleConnListener {
+	return &singleConnListener{conn: conn, done: make(chan struct{})}
+}
+
+func (l *singleConnListener) Accept() (net.Conn, error) {
+	var conn net.Conn
+	l.once.Do(func() { conn = l.conn })
+	if conn != nil {
+		return &notifyCloseConn{Conn: conn, done: l.done}, nil
+	}
+	// Block until the served connection closes so Serve returns with it
+	<-l.done
+	return nil, io.EOF
+}
+
+func (l *singleConnListener) Close() error { return nil }
+
+func (l *singleConnListener) Addr() net.Addr { return l.conn.LocalAddr() }
+
+// notifyCloseConn signals the listener when the server closes the connection
+type notifyCloseConn struct {
+	net.Conn
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func (c *notifyCloseConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() { close(c.done) })
+	return err
+}
+
+func closeQuietly(c io.Closer) {
+	if c == nil {
+		return
+	}
+	_ = c.Close() //nolint:errcheck // the peer may already have closed
+}
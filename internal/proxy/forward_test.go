@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/royisme/bobamixer/internal/domain/core"
+)
+
+func TestLoadOrCreateCA(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ca")
+	ca, err := LoadOrCreateCA(dir)
+	if err != nil {
+		t.Fatalf("LoadOrCreateCA: %v", err)
+	}
+	if !ca.Certificate().IsCA {
+		t.Fatal("generated certificate is not a CA")
+	}
+
+	reloaded, err := LoadOrCreateCA(dir)
+	if err != nil {
+		t.Fatalf("reload CA: %v", err)
+	}
+	if string(reloaded.CertPEM()) != string(ca.CertPEM()) {
+		t.Error("reloading should reuse the existing CA")
+	}
+
+	leaf, err := reloaded.LeafFor("api.openai.com")
+	if err != nil {
+		t.Fatalf("LeafFor: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	if _, err := leaf.Leaf.Verify(x509.VerifyOptions{DNSName: "api.openai.com", Roots: roots}); err != nil {
+		t.Errorf("leaf does not chain to the CA: %v", err)
+	}
+}
+
+func TestInterceptHostsFor(t *testing.T) {
+	hosts := interceptHostsFor(&core.ProvidersConfig{Providers: []core.Provider{
+		{ID: "zai", Kind: core.ProviderKindAnthropicCompatible, BaseURL: "https://api.z.ai/api/anthropic"},
+		{ID: "gemini", Kind: core.ProviderKindGemini, BaseURL: "https://generativelanguage.googleapis.com"},
+		{ID: "local", Kind: core.ProviderKindOpenAICompatible, BaseURL: "http://localhost:11434/v1"},
+	}})
+	if hosts["api.z.ai"] != providerAnthropic || hosts["api.openai.com"] != providerOpenAI {
+		t.Errorf("unexpected intercept hosts %v", hosts)
+	}
+	if _, ok := hosts["generativelanguage.googleapis.com"]; ok {
+		t.Error("gemini hosts are not intercepted")
+	}
+	if _, ok := hosts["localhost"]; ok {
+		t.Error("plain-HTTP providers are not intercepted")
+	}
+}
+
+// newForwardProxy starts the handler as an HTTP proxy with a fresh CA
+func newForwardProxy(t *testing.T) (*Handler, *CertificateAuthority, *url.URL) {
+	t.Helper()
+	handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	ca, err := LoadOrCreateCA(filepath.Join(t.TempDir(), "ca"))
+	if err != nil {
+		t.Fatalf("LoadOrCreateCA: %v", err)
+	}
+	handler.SetCertificateAuthority(ca)
+
+	proxyServer := httptest.NewServer(handler)
+	t.Cleanup(proxyServer.Close)
+	proxyURL, err := url.Parse(proxyServer.URL)
+	if err != nil {
+		t.Fatalf("parse proxy URL: %v", err)
+	}
+	return handler, ca, proxyURL
+}
+
+func TestConnectTunnelsUnknownHosts(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("tunnelled")) //nolint:errcheck // test server
+	}))
+	defer upstream.Close()
+
+	handler, _, proxyURL := newForwardProxy(t)
+
+	// The client trusts only the real upstream certificate, so success proves the
+	// proxy did not terminate TLS
+	transport := upstream.Client().Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("GET through tunnel: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body) //nolint:errcheck // checked below
+	resp.Body.Close()                //nolint:errcheck // test cleanup
+	if string(body) != "tunnelled" {
+		t.Errorf("body = %q", body)
+	}
+
+	count, err := handler.db.QueryInt("SELECT COUNT(*) FROM usage_records;")
+	if err != nil {
+		t.Fatalf("query usage: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("tunnelled traffic recorded %d usage rows, want 0", count)
+	}
+}
+
+func TestConnectInterceptsAIHosts(t *testing.T) {
+	var gotPath string
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"model":"gpt-4o","usage":{"prompt_tokens":12,"completion_tokens":3}}`)) //nolint:errcheck // test server
+	}))
+	defer upstream.Close()
+
+	handler, ca, proxyURL := newForwardProxy(t)
+	handler.SetInterceptHost("127.0.0.1", providerOpenAI)
+	handler.upstreamTransport = upstream.Client().Transport
+
+	// The client trusts only the BobaMixer CA, as it would after `boba proxy ca install`
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	transport := &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+	transport.TLSClientConfig = upstream.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	transport.TLSClientConfig.RootCAs = roots
+	client := &http.Client{Transport: transport}
+
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+	resp, err := client.Post(upstream.URL+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST through interception: %v", err)
+	}
+	resp.Body.Close() //nolint:errcheck // test cleanup
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 || resp.TLS.PeerCertificates[0].Issuer.CommonName != ca.Certificate().Subject.CommonName {
+		t.Error("response was not served with a certificate from the local CA")
+	}
+	if gotPath != "/v1/chat/completions" {
+		t.Errorf("upstream path = %q", gotPath)
+	}
+
+	tokens, err := handler.db.QueryInt("SELECT COALESCE(SUM(input_tokens + output_tokens), 0) FROM usage_records WHERE model = 'gpt-4o';")
+	if err != nil {
+		t.Fatalf("query usage: %v", err)
+	}
+	if tokens != 15 {
+		t.Errorf("recorded tokens = %d, want 15", tokens)
+	}
+	if stats := handler.Stats(); stats.OpenAIRequests != 1 {
+		t.Errorf("openai requests = %d, want 1", stats.OpenAIRequests)
+	}
+}
@@ -12,12 +12,19 @@ import (
 	"sync"
 	"time"
 
+	"github.com/royisme/bobamixer/internal/domain/bandit"
 	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/domain/catalog"
+	"github.com/royisme/bobamixer/internal/domain/core"
+	"github.com/royisme/bobamixer/internal/domain/currency"
+	"github.com/royisme/bobamixer/internal/domain/dlp"
 	"github.com/royisme/bobamixer/internal/domain/pricing"
 	"github.com/royisme/bobamixer/internal/domain/routing"
+	"github.com/royisme/bobamixer/internal/domain/tokenizer"
 	"github.com/royisme/bobamixer/internal/logging"
 	"github.com/royisme/bobamixer/internal/store/config"
 	"github.com/royisme/bobamixer/internal/store/sqlite"
+	"github.com/royisme/bobamixer/internal/transcript"
 )
 
 const (
@@ -27,12 +34,110 @@ const (
 
 // Handler handles HTTP proxy requests
 type Handler struct {
-	db            *sqlite.DB
-	stats         *Stats
-	pricingTable  *pricing.Table
-	budgetTracker *budget.Tracker
-	routingEngine *routing.Engine
-	mu            sync.RWMutex
+	db                 *sqlite.DB
+	stats              *Stats
+	pricingTable       *pricing.Table
+	budgetTracker      *budget.Tracker
+	alertConfig        *budget.AlertConfig // thresholds budget hints are graded at; nil is the default
+	routingEngine      *routing.Engine
+	recordRouting      bool            // keep routing features for boba route replay
+	profiles           config.Profiles // profiles routing chooses between, to tell when it is followed
+	providers          *core.ProvidersConfig
+	bindings           *core.BindingsConfig
+	keyPools           map[string]*KeyPool // provider ID -> key pool
+	cache              *ResponseCache      // nil when response caching is disabled
+	policies           []Policy
+	dlpScanner         *dlp.Scanner          // nil when outbound scanning is disabled
+	ca                 *CertificateAuthority // nil disables HTTPS interception in forward-proxy mode
+	interceptHosts     map[string]string     // host -> provider type for intercepted CONNECTs
+	upstreamTransport  http.RoundTripper     // nil uses http.DefaultTransport
+	transcripts        *transcript.Recorder  // nil when transcript recording is disabled
+	shadow             *ShadowConfig         // nil when traffic mirroring is disabled
+	models             *catalog.Catalog      // nil forwards /v1/models upstream
+	secrets            *core.SecretsConfig
+	countTokensMissing map[string]bool // upstream base URLs without count_tokens
+	shadowSample       func() float64
+	shadowWG           sync.WaitGroup
+	mu                 sync.RWMutex
+}
+
+const (
+	// projectHeader and profileHeader attribute a request to a project and a
+	// profile for budgets and stats; boba run sets the project of the working
+	// directory's .boba-project.yaml
+	projectHeader = "X-Boba-Project"
+	profileHeader = "X-Boba-Profile"
+)
+
+// proxyOnlyHeaders are read by the proxy and never forwarded upstream
+var proxyOnlyHeaders = []string{"X-Proxy-Target", "X-Tool-ID", projectHeader, profileHeader}
+
+// stripProxyHeaders removes the proxy's own headers from an upstream request
+func stripProxyHeaders(header http.Header) {
+	for _, name := range proxyOnlyHeaders {
+		header.Del(name)
+	}
+}
+
+// proxyRequest carries per-request state through the forwarding pipeline
+type proxyRequest struct {
+	sessionID      string
+	toolID         string
+	project        string // from projectHeader, empty when not sent
+	profile        string // from profileHeader, empty when not sent
+	routeKey       string // routing context, set when the request is served by the profile routing chose
+	providerType   string
+	targetPath     string
+	binding        *core.Binding
+	provider       *core.Provider
+	keyPool        *KeyPool
+	keyFingerprint string
+	cacheKey       string // empty when the request is not eligible for caching
+	cacheStore     bool
+	rewrites       []Rewrite               // policy rewrites applied, recorded on the session
+	dlpFindings    []dlp.Finding           // secrets/PII detected in the prompt, recorded on the session
+	prompt         *tokenizer.RequestCount // local prompt count, nil when the body is not a chat request
+	budgetLoaded   bool                    // budgetStatuses were calculated, for budgetModel
+	budgetModel    string                  // model budgetStatuses were matched for
+	budgetStatuses []*budget.Status        // active budgets the request matches
+	budgetErr      error                   // why budgetStatuses could not be calculated
+	startTime      time.Time
+}
+
+// tool names the calling tool as usage records it
+func (p *proxyRequest) tool() string {
+	if p.toolID == "" {
+		return "unknown"
+	}
+	return p.toolID
+}
+
+// providerID names the bound provider, or the API type when the tool is unbound
+func (p *proxyRequest) providerID() string {
+	if p.provider != nil {
+		return p.provider.ID
+	}
+	return p.providerType
+}
+
+// bindingName names the tool's binding, or "" when the tool is unbound
+func (p *proxyRequest) bindingName() string {
+	if p.binding == nil {
+		return ""
+	}
+	return budget.BindingName(p.binding.ToolID, p.binding.ProviderID)
+}
+
+// budgetRequest describes the request for matching it against budget scopes
+func (p *proxyRequest) budgetRequest(model string) budget.Request {
+	return budget.Request{
+		Project:  p.project,
+		Profile:  p.profile,
+		Tool:     p.tool(),
+		Provider: p.providerID(),
+		Binding:  p.bindingName(),
+		Model:    model,
+	}
 }
 
 // Stats tracks proxy statistics
@@ -52,12 +157,29 @@ type UsageRecord struct {
 	Timestamp    int64
 	Tool         string
 	Model        string
-	Provider     string
+	Provider     string // Provider ID, or the API type when the tool is unbound
+	Binding      string // Binding the request went through (tool/provider), empty when unbound
+	Project      string // recorded on the session, empty when the request named none
+	Profile      string // recorded on the session, empty when the request named none
+	RouteKey     string // routing context recorded on the session, empty unless routing chose Profile
 	InputTokens  int
 	OutputTokens int
 	InputCost    float64
 	OutputCost   float64
 	LatencyMS    int64
+
+	EstimateLevel  string  // exact, or mapped/heuristic when counted locally (empty means exact)
+	KeyFingerprint string  // Fingerprint of the pooled API key used (never the key itself)
+	CacheHit       bool    // Served from the response cache
+	SavedCost      float64 // Cost avoided by a cache hit
+
+	// Prompt size beside an exact count, for tokenizer calibration (zero when not a sample)
+	PromptChars          int
+	PromptCodeChars      int
+	PromptOverhead       int
+	EstimatedInputTokens int
+
+	UnpricedCurrency string // currency of a price that could not be converted; the costs are then zero
 }
 
 // NewHandler creates a new proxy handler
@@ -77,23 +199,25 @@ func NewHandler(dbPath string) (*Handler, error) {
 	// Initialize budget tracker
 	budgetTracker := budget.NewTracker(db)
 
-	// Initialize routing engine (optional, for future use)
-	// This allows for dynamic routing based on request content
+	// Routing engine is set from routes.yaml by SetRoutingEngine; until then
+	// requests use URL-based routing only
 	var routingEngine *routing.Engine
-	// Note: Routing engine initialization would load routes.yaml here
-	// For now, we keep it nil and use URL-based routing
 
 	return &Handler{
-		db:            db,
-		stats:         &Stats{},
-		pricingTable:  pricingTable,
-		budgetTracker: budgetTracker,
-		routingEngine: routingEngine,
+		db:             db,
+		stats:          &Stats{},
+		pricingTable:   pricingTable,
+		budgetTracker:  budgetTracker,
+		routingEngine:  routingEngine,
+		interceptHosts: interceptHostsFor(nil),
 	}, nil
 }
 
-// SetPricingTable updates the pricing table
+// SetPricingTable updates the pricing table. Costs are recorded in US
+// dollars; models priced in other currencies convert at the stored exchange
+// rates.
 func (h *Handler) SetPricingTable(table *pricing.Table) {
+	table.SetConverter(currency.NewRates(h.db))
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	h.pricingTable = table
@@ -106,13 +230,113 @@ func (h *Handler) SetRoutingEngine(engine *routing.Engine) {
 	h.routingEngine = engine
 }
 
-// evaluateRouting evaluates routing decision for logging purposes
-// This is currently used for debugging and future unified endpoint support
-func (h *Handler) evaluateRouting(reqBody []byte) *routing.RoutingDecision {
+// SetProfiles sets the profiles routing chooses between. A request is learned
+// from by the routing bandit when it is served by the profile routing chose.
+func (h *Handler) SetProfiles(profiles config.Profiles) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.profiles = profiles
+}
+
+// SetAlertConfig sets the thresholds budget hints are graded at, for routing
+// and the downgrade ladder
+func (h *Handler) SetAlertConfig(config *budget.AlertConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.alertConfig = config
+}
+
+// SetRouteRecording keeps the features of each routing decision, with the
+// prompt sample DLP-masked, for boba route replay
+func (h *Handler) SetRouteRecording(enabled bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.recordRouting = enabled
+}
+
+// SetResponseCache enables the exact-match response cache (nil disables it)
+func (h *Handler) SetResponseCache(cache *ResponseCache) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cache = cache
+}
+
+// SetPolicies installs the request rewrite policies (nil clears them)
+func (h *Handler) SetPolicies(config *PoliciesConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if config == nil {
+		h.policies = nil
+		return
+	}
+	h.policies = config.Policies
+}
+
+// SetControlPlane installs providers, bindings and secrets so the proxy can resolve the
+// provider behind each tool and load-balance requests across its key pool
+func (h *Handler) SetControlPlane(providers *core.ProvidersConfig, bindings *core.BindingsConfig, secrets *core.SecretsConfig) {
+	pools := make(map[string]*KeyPool)
+	if providers != nil {
+		for i := range providers.Providers {
+			provider := &providers.Providers[i]
+			keys, err := core.ResolveAPIKeys(provider, secrets)
+			if err != nil || len(keys) == 0 {
+				continue
+			}
+			pools[provider.ID] = NewKeyPool(provider.ID, provider.KeyStrategy, keys)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.providers = providers
+	h.bindings = bindings
+	h.secrets = secrets
+	h.keyPools = pools
+	h.interceptHosts = interceptHostsFor(providers)
+}
+
+// KeyPoolStatus returns the health of every key in the given provider's pool
+func (h *Handler) KeyPoolStatus(providerID string) []KeyStatus {
+	h.mu.RLock()
+	pool := h.keyPools[providerID]
+	h.mu.RUnlock()
+	if pool == nil {
+		return nil
+	}
+	return pool.Status()
+}
+
+// resolveProvider finds the binding and provider for a tool, along with the provider's key pool
+func (h *Handler) resolveProvider(toolID string) (*core.Binding, *core.Provider, *KeyPool) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	if h.routingEngine == nil {
+	if toolID == "" || h.bindings == nil || h.providers == nil {
+		return nil, nil, nil
+	}
+	binding, err := h.bindings.FindBinding(toolID)
+	if err != nil {
+		return nil, nil, nil
+	}
+	provider, err := h.providers.FindProvider(binding.ProviderID)
+	if err != nil {
+		return binding, nil, nil
+	}
+	return binding, provider, h.keyPools[provider.ID]
+}
+
+// evaluateRouting evaluates routing decision for logging purposes
+// This is currently used for debugging and future unified endpoint support.
+// The request still goes to the model the tool asked for; when that is the
+// profile routing chose, the session records the decision's context so the
+// bandit learns from its outcome.
+func (h *Handler) evaluateRouting(reqBody []byte, preq *proxyRequest) *routing.RoutingDecision {
+	h.mu.RLock()
+	engine, record := h.routingEngine, h.recordRouting
+	h.mu.RUnlock()
+
+	if engine == nil {
 		return nil
 	}
 
@@ -128,18 +352,38 @@ func (h *Handler) evaluateRouting(reqBody []byte) *routing.RoutingDecision {
 		TextSample: extractTextSample(req),
 		CtxChars:   len(reqBody),
 	}
+	model, _ := req["model"].(string) //nolint:errcheck // requests without a model match no model budget
+	features.BudgetHint, features.BudgetUsed = h.budgetHint(preq, model)
 
 	// Execute routing
-	decision, trace, err := h.routingEngine.Match(context.Background(), features)
+	decision, trace, err := engine.Match(context.Background(), features)
 	if err != nil {
 		logging.Warn("Routing evaluation failed", logging.Err(err))
 		return nil
 	}
 
+	// Only a request served by the chosen profile says anything about it
+	if decision.Key != "" && h.servedBy(preq, model, decision.Profile) {
+		preq.routeKey = decision.Key
+		preq.profile = decision.Profile
+	}
+
+	// Keep what routing saw so routes.yaml changes can be replayed against it
+	if record && h.db != nil {
+		profile := decision.Profile
+		if decision.Explore {
+			profile = decision.Fallback
+		}
+		if err := routing.RecordFeatures(h.db, preq.sessionID, features, "", profile); err != nil {
+			logging.Warn("Failed to record routing features", logging.Err(err))
+		}
+	}
+
 	// Log routing decision for debugging
 	if trace.Matched {
 		logging.Info("Routing decision",
 			logging.String("profile", decision.Profile),
+			logging.String("sub_agent", trace.SubAgent),
 			logging.String("rule_id", trace.RuleID),
 			logging.String("explain", trace.Explain),
 			logging.Bool("explore", decision.Explore))
@@ -148,12 +392,32 @@ func (h *Handler) evaluateRouting(reqBody []byte) *routing.RoutingDecision {
 	return decision
 }
 
-// extractTextSample extracts a text sample from the request for routing
+// servedBy reports whether a request goes to a profile: the one its profile
+// header names, else the profile whose model it asks for
+func (h *Handler) servedBy(preq *proxyRequest, model, profile string) bool {
+	if profile == "" {
+		return false
+	}
+	if preq.profile != "" {
+		return preq.profile == profile
+	}
+	h.mu.RLock()
+	p, ok := h.profiles[profile]
+	h.mu.RUnlock()
+	return ok && p.Model != "" && p.Model == model
+}
+
+// extractTextSample extracts a text sample from the request for routing,
+// preferring the latest user message since that is where sub-agent triggers appear
 func extractTextSample(req map[string]interface{}) string {
 	// Try to extract from common fields
 	if messages, ok := req["messages"].([]interface{}); ok && len(messages) > 0 {
-		if msg, ok := messages[0].(map[string]interface{}); ok {
-			if content, ok := msg["content"].(string); ok {
+		for i := len(messages) - 1; i >= 0; i-- {
+			msg, ok := messages[i].(map[string]interface{})
+			if !ok || msg["role"] != "user" {
+				continue
+			}
+			if content := messageText(msg["content"]); content != "" {
 				if len(content) > 200 {
 					return content[:200]
 				}
@@ -172,16 +436,57 @@ func extractTextSample(req map[string]interface{}) string {
 	return ""
 }
 
+// messageText returns the text of a message content that is either a string
+// or a list of content blocks
+func messageText(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []interface{}:
+		var parts []string
+		for _, block := range c {
+			if b, ok := block.(map[string]interface{}); ok && b["type"] == "text" {
+				if text, ok := b["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
 // ServeHTTP implements http.Handler
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 
+	// Forward-proxy mode: tools configured with HTTPS_PROXY / HTTP_PROXY
+	if r.Method == http.MethodConnect {
+		h.handleConnect(w, r)
+		return
+	}
+	if r.URL.IsAbs() {
+		h.serveForward(w, r)
+		return
+	}
+
 	// Handle health check endpoint
 	if r.URL.Path == "/health" {
 		h.handleHealth(w, r)
 		return
 	}
 
+	// Session ratings from editor plugins
+	if r.URL.Path == feedbackPath {
+		h.handleFeedback(w, r)
+		return
+	}
+
+	// Answer model listings from provider discovery
+	if h.serveModels(w, r) {
+		return
+	}
+
 	// Update stats
 	h.stats.mu.Lock()
 	h.stats.TotalRequests++
@@ -199,8 +504,20 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Update provider-specific stats
 	h.updateProviderStats(providerType)
 
+	preq := &proxyRequest{
+		sessionID:    generateSessionID(),
+		toolID:       r.Header.Get("X-Tool-ID"),
+		project:      r.Header.Get(projectHeader),
+		profile:      r.Header.Get(profileHeader),
+		providerType: providerType,
+		targetPath:   targetPath,
+		startTime:    startTime,
+	}
+	preq.binding, preq.provider, preq.keyPool = h.resolveProvider(preq.toolID)
+	w.Header().Set(sessionHeader, preq.sessionID)
+
 	// Get target base URL from request headers or configuration
-	targetURL := h.getTargetURL(r, providerType)
+	targetURL := h.getTargetURL(r, preq)
 	if targetURL == "" {
 		http.Error(w, "No target URL configured", http.StatusBadRequest)
 		h.incrementErrorCount()
@@ -208,7 +525,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Forward the request
-	if err := h.forwardRequest(w, r, targetURL, targetPath, providerType, startTime); err != nil {
+	if err := h.forwardRequest(w, r, targetURL, preq); err != nil {
 		logging.Error("Failed to forward request",
 			logging.String("error", err.Error()),
 			logging.String("provider", providerType),
@@ -237,14 +554,19 @@ func (h *Handler) parseRoute(path string) (providerType, targetPath string) {
 }
 
 // getTargetURL determines the upstream API URL
-func (h *Handler) getTargetURL(r *http.Request, providerType string) string {
+func (h *Handler) getTargetURL(r *http.Request, preq *proxyRequest) string {
 	// Check for custom header first
 	if target := r.Header.Get("X-Proxy-Target"); target != "" {
 		return target
 	}
 
+	// Use the bound provider's endpoint when the tool is known
+	if preq.provider != nil && preq.provider.BaseURL != "" {
+		return upstreamBaseURL(preq.provider.BaseURL)
+	}
+
 	// Default upstream URLs
-	switch providerType {
+	switch preq.providerType {
 	case providerOpenAI:
 		return "https://api.openai.com"
 	case providerAnthropic:
@@ -254,8 +576,40 @@ func (h *Handler) getTargetURL(r *http.Request, providerType string) string {
 	}
 }
 
+// upstreamBaseURL strips a trailing /v1 from a provider base URL, since proxied
+// paths already carry the API version (e.g. /v1/messages)
+func upstreamBaseURL(baseURL string) string {
+	base := strings.TrimSuffix(baseURL, "/")
+	return strings.TrimSuffix(base, "/v1")
+}
+
+// applyPooledKey replaces the client's credentials with the next key from the provider's pool
+func (h *Handler) applyPooledKey(header http.Header, preq *proxyRequest) error {
+	if preq.keyPool == nil || preq.keyPool.Size() == 0 {
+		return nil
+	}
+	apiKey, fingerprint, err := preq.keyPool.Pick()
+	if err != nil {
+		return err
+	}
+	preq.keyFingerprint = fingerprint
+
+	switch preq.providerType {
+	case providerAnthropic:
+		// Anthropic-compatible providers (e.g. Z.AI) authenticate with a bearer token
+		if strings.HasPrefix(header.Get("Authorization"), "Bearer ") {
+			header.Set("Authorization", "Bearer "+apiKey)
+		} else {
+			header.Set("x-api-key", apiKey)
+		}
+	default:
+		header.Set("Authorization", "Bearer "+apiKey)
+	}
+	return nil
+}
+
 // forwardRequest forwards the request to the upstream provider
-func (h *Handler) forwardRequest(w http.ResponseWriter, r *http.Request, targetURL, targetPath, providerType string, startTime time.Time) error {
+func (h *Handler) forwardRequest(w http.ResponseWriter, r *http.Request, targetURL string, preq *proxyRequest) error {
 	// Read request body
 	bodyBytes, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -268,19 +622,52 @@ func (h *Handler) forwardRequest(w http.ResponseWriter, r *http.Request, targetU
 		}
 	}()
 
-	// Check budget before forwarding request
-	if err := h.checkBudgetBeforeRequest(bodyBytes); err != nil {
-		http.Error(w, fmt.Sprintf("Budget check failed: %s", err.Error()), http.StatusTooManyRequests)
-		logging.Warn("Budget check failed", logging.String("error", err.Error()))
-		return fmt.Errorf("budget check: %w", err)
+	// Apply rewrite policies before anything keys off the body
+	bodyBytes, err = h.applyRequestPolicies(w, r, bodyBytes, preq)
+	if err != nil {
+		return err
+	}
+
+	// Scan for secrets and PII before the prompt leaves the machine (or reaches the cache)
+	bodyBytes, err = h.applyDLP(w, bodyBytes, preq)
+	if err != nil {
+		return err
+	}
+
+	// Token counting never reaches the model; answer it locally if the upstream cannot
+	if preq.providerType == providerAnthropic && preq.targetPath == countTokensPath {
+		return h.serveCountTokens(w, r, bodyBytes, targetURL, preq)
+	}
+
+	// Step down the binding's downgrade ladder as spend nears the cap, before the
+	// cache key and budget estimate are taken from the body
+	bodyBytes = h.applyBudgetDowngrade(bodyBytes, preq)
+
+	// Serve repeated requests from the cache; hits cost nothing so they skip the budget check
+	if h.serveFromCache(w, r, bodyBytes, preq) {
+		return nil
+	}
+
+	// Count the prompt once; the budget pre-check and usage estimates reuse it
+	if count, err := tokenizer.CountRequest(bodyBytes); err == nil {
+		preq.prompt = &count
+	}
+
+	// Check budget before forwarding request; over the cap the budget's action
+	// blocks, downgrades or warns
+	if denial := h.checkBudgetBeforeRequest(bodyBytes, preq); denial != nil {
+		var ok bool
+		if bodyBytes, ok = h.applyOverCapAction(w, bodyBytes, preq, denial); !ok {
+			return fmt.Errorf("budget check: %w", denial)
+		}
 	}
 
 	// Evaluate routing (for debugging and future use)
 	// Currently logs routing decisions but doesn't change behavior
-	h.evaluateRouting(bodyBytes)
+	h.evaluateRouting(bodyBytes, preq)
 
 	// Build upstream URL
-	upstreamURL := targetURL + targetPath
+	upstreamURL := targetURL + preq.targetPath
 	if r.URL.RawQuery != "" {
 		upstreamURL += "?" + r.URL.RawQuery
 	}
@@ -296,12 +683,18 @@ func (h *Handler) forwardRequest(w http.ResponseWriter, r *http.Request, targetU
 	h.copyHeaders(upstreamReq.Header, r.Header)
 
 	// Remove proxy-specific headers
-	upstreamReq.Header.Del("X-Proxy-Target")
-	upstreamReq.Header.Del("X-Tool-ID")
+	stripProxyHeaders(upstreamReq.Header)
+
+	// Swap in a pooled key when the provider has one configured
+	if err := h.applyPooledKey(upstreamReq.Header, preq); err != nil {
+		http.Error(w, fmt.Sprintf("No API key available for provider %s", preq.provider.ID), http.StatusServiceUnavailable)
+		return fmt.Errorf("pick key: %w", err)
+	}
 
 	// Send request
 	client := &http.Client{
-		Timeout: 60 * time.Second,
+		Timeout:   60 * time.Second,
+		Transport: h.transport(),
 	}
 	resp, err := client.Do(upstreamReq)
 	if err != nil {
@@ -321,8 +714,17 @@ func (h *Handler) forwardRequest(w http.ResponseWriter, r *http.Request, targetU
 		return fmt.Errorf("read response: %w", err)
 	}
 
+	// Take failing keys out of rotation
+	if preq.keyPool != nil && preq.keyFingerprint != "" {
+		preq.keyPool.Report(preq.keyFingerprint, resp.StatusCode, resp.Header, respBodyBytes)
+	}
+
 	// Log request/response
-	h.logRequest(r, providerType, targetPath, bodyBytes, respBodyBytes, resp.StatusCode, startTime)
+	record := h.logRequest(preq, bodyBytes, respBodyBytes, resp.StatusCode)
+	h.storeInCache(preq, resp, respBodyBytes, record)
+	h.recordAudit(preq)
+	h.recordTranscript(preq, bodyBytes, resp.StatusCode, resp.Header.Get("Content-Type"), respBodyBytes)
+	h.mirrorRequest(r, preq, bodyBytes, resp.StatusCode, respBodyBytes, record)
 
 	// Update bytes proxied
 	h.stats.mu.Lock()
@@ -368,24 +770,43 @@ func (h *Handler) copyHeaders(dst, src http.Header) {
 	}
 }
 
-// logRequest logs the proxied request to the database
-func (h *Handler) logRequest(r *http.Request, providerType, path string, reqBody, respBody []byte, statusCode int, startTime time.Time) {
-	toolID := r.Header.Get("X-Tool-ID")
-	if toolID == "" {
-		toolID = "unknown"
-	}
+// logRequest logs the proxied request to the database and returns the saved usage record, if any
+func (h *Handler) logRequest(preq *proxyRequest, reqBody, respBody []byte, statusCode int) *UsageRecord {
+	toolID := preq.tool()
+	providerType := preq.providerType
+	path := preq.targetPath
+	startTime := preq.startTime
 
 	latencyMS := time.Since(startTime).Milliseconds()
 
 	// Parse token usage from response
 	model, inputTokens, outputTokens := h.parseTokenUsage(providerType, reqBody, respBody)
+	estimateLevel := estimateExact
+	var usage responseUsage
+	if model != "" && statusCode >= 200 && statusCode < 300 {
+		usage = parseResponseUsage(respBody)
+		if inputTokens == 0 && outputTokens == 0 {
+			// Streamed responses report usage in events rather than the body
+			inputTokens, outputTokens = usage.InputTokens, usage.OutputTokens
+		}
+		if inputTokens == 0 && outputTokens == 0 {
+			inputTokens, outputTokens, estimateLevel = estimateUsage(model, preq.prompt, respBody)
+		}
+	}
 
-	// Calculate cost
+	// Calculate cost; a price that cannot be converted to US dollars is
+	// recorded at no cost, naming its currency
 	inputCost, outputCost := float64(0), float64(0)
+	unpriced := ""
 	if model != "" && (inputTokens > 0 || outputTokens > 0) {
 		h.mu.RLock()
 		profileCost := config.Cost{Input: 0, Output: 0} // Default zero cost
-		inputCost, outputCost = h.pricingTable.CalculateCost(model, profileCost, inputTokens, outputTokens)
+		var err error
+		inputCost, outputCost, err = h.pricingTable.CalculateCost(model, profileCost, inputTokens, outputTokens)
+		if err != nil {
+			unpriced = h.pricingTable.GetPrice(model, profileCost).Currency
+			logging.Warn("Usage recorded unpriced", logging.String("model", model), logging.Err(err))
+		}
 		h.mu.RUnlock()
 	}
 
@@ -398,31 +819,51 @@ func (h *Handler) logRequest(r *http.Request, providerType, path string, reqBody
 		logging.Int("status", statusCode),
 		logging.Int("input_tokens", inputTokens),
 		logging.Int("output_tokens", outputTokens),
+		logging.String("estimate_level", estimateLevel),
 		logging.String("input_cost", fmt.Sprintf("%.6f", inputCost)),
 		logging.String("output_cost", fmt.Sprintf("%.6f", outputCost)),
 		logging.Int("req_bytes", len(reqBody)),
 		logging.Int("resp_bytes", len(respBody)),
+		logging.String("key_fingerprint", preq.keyFingerprint),
 		logging.Int64("latency_ms", latencyMS))
 
 	// Save to database if we have token information
 	if model != "" && (inputTokens > 0 || outputTokens > 0) {
 		record := &UsageRecord{
-			SessionID:    generateSessionID(),
+			SessionID:    preq.sessionID,
 			Timestamp:    startTime.Unix(),
 			Tool:         toolID,
 			Model:        model,
-			Provider:     providerType,
+			Provider:     preq.providerID(),
+			Binding:      preq.bindingName(),
+			Project:      preq.project,
+			Profile:      preq.profile,
+			RouteKey:     preq.routeKey,
 			InputTokens:  inputTokens,
 			OutputTokens: outputTokens,
 			InputCost:    inputCost,
 			OutputCost:   outputCost,
 			LatencyMS:    latencyMS,
+
+			EstimateLevel:    estimateLevel,
+			KeyFingerprint:   preq.keyFingerprint,
+			UnpricedCurrency: unpriced,
+		}
+		if estimateLevel == estimateExact {
+			recordPromptSize(preq.prompt, usage, record)
 		}
 
 		if err := h.saveUsageRecord(record); err != nil {
 			logging.Error("Failed to save usage record", logging.Err(err))
+		} else if record.RouteKey != "" {
+			// Fold the routed outcome into the bandit straight away, as boba call --auto does
+			if _, err := bandit.NewStore(h.db).Learn(bandit.DefaultWeights); err != nil {
+				logging.Warn("Failed to update routing bandit", logging.Err(err))
+			}
 		}
+		return record
 	}
+	return nil
 }
 
 // parseTokenUsage extracts model and token usage from request/response
@@ -500,15 +941,34 @@ func (h *Handler) saveUsageRecord(record *UsageRecord) error {
 	if err := h.db.Exec(sessionQuery); err != nil {
 		return fmt.Errorf("insert session: %w", err)
 	}
+	// Project and profile budgets count spending through the session, and the
+	// routing bandit learns from sessions with a route key
+	if record.Project != "" || record.Profile != "" || record.RouteKey != "" {
+		if err := h.db.Exec(fmt.Sprintf(`UPDATE sessions SET project = NULLIF('%s', ''), profile = NULLIF('%s', ''), route_key = '%s' WHERE id = '%s';`,
+			escapeSQLString(record.Project), escapeSQLString(record.Profile), escapeSQLString(record.RouteKey),
+			escapeSQLString(record.SessionID))); err != nil {
+			return fmt.Errorf("attribute session: %w", err)
+		}
+	}
+
+	estimateLevel := record.EstimateLevel
+	if estimateLevel == "" {
+		estimateLevel = estimateExact
+	}
 
 	// Insert usage record
 	usageQuery := fmt.Sprintf(`
-		INSERT INTO usage_records (id, session_id, ts, input_tokens, output_tokens, input_cost, output_cost, tool, model, estimate_level)
-		VALUES ('%s', '%s', %d, %d, %d, %.6f, %.6f, '%s', '%s', 'exact');
+		INSERT INTO usage_records (id, session_id, ts, input_tokens, output_tokens, input_cost, output_cost, tool, model, provider, binding, estimate_level, key_fingerprint, cache_hit, saved_cost,
+			prompt_chars, prompt_code_chars, prompt_overhead, estimated_input_tokens, unpriced_currency)
+		VALUES ('%s', '%s', %d, %d, %d, %.6f, %.6f, '%s', '%s', '%s', '%s', '%s', '%s', %d, %.6f, %d, %d, %d, %d, '%s');
 	`, generateRecordID(), record.SessionID, record.Timestamp,
 		record.InputTokens, record.OutputTokens,
 		record.InputCost, record.OutputCost,
-		escapeSQLString(record.Tool), escapeSQLString(record.Model))
+		escapeSQLString(record.Tool), escapeSQLString(record.Model),
+		escapeSQLString(record.Provider), escapeSQLString(record.Binding), estimateLevel,
+		escapeSQLString(record.KeyFingerprint), boolToInt(record.CacheHit), record.SavedCost,
+		record.PromptChars, record.PromptCodeChars, record.PromptOverhead, record.EstimatedInputTokens,
+		escapeSQLString(record.UnpricedCurrency))
 
 	if err := h.db.Exec(usageQuery); err != nil {
 		return fmt.Errorf("insert usage record: %w", err)
@@ -517,6 +977,112 @@ func (h *Handler) saveUsageRecord(record *UsageRecord) error {
 	return nil
 }
 
+// serveFromCache answers the request from the response cache when possible.
+// It also decides whether the upstream response may be stored afterwards.
+func (h *Handler) serveFromCache(w http.ResponseWriter, r *http.Request, body []byte, preq *proxyRequest) bool {
+	h.mu.RLock()
+	cache := h.cache
+	h.mu.RUnlock()
+	if cache == nil || r.Method != http.MethodPost {
+		return false
+	}
+
+	providerKey := preq.providerType
+	if preq.provider != nil {
+		providerKey = preq.provider.ID
+	}
+	key, _ := cacheKey(providerKey, preq.targetPath, body)
+	if key == "" {
+		return false
+	}
+
+	skipLookup, skipStore := cacheDirectives(r.Header)
+	preq.cacheKey = key
+	preq.cacheStore = !skipStore
+	if skipLookup {
+		w.Header().Set(cacheHeader, "BYPASS")
+		return false
+	}
+
+	entry, ok := cache.Get(key)
+	if !ok {
+		w.Header().Set(cacheHeader, "MISS")
+		return false
+	}
+
+	if entry.ContentType != "" {
+		w.Header().Set("Content-Type", entry.ContentType)
+	}
+	w.Header().Set(cacheHeader, "HIT")
+	w.WriteHeader(entry.StatusCode)
+	if _, err := w.Write(entry.Body); err != nil {
+		logging.Warn("Failed to write cached response", logging.Err(err))
+	}
+
+	toolID := preq.tool()
+	latencyMS := time.Since(preq.startTime).Milliseconds()
+	logging.Info("Served cached response",
+		logging.String("tool", toolID),
+		logging.String("provider", preq.providerType),
+		logging.String("path", preq.targetPath),
+		logging.String("model", entry.Model),
+		logging.String("saved_cost", fmt.Sprintf("%.6f", entry.Cost)),
+		logging.Int64("latency_ms", latencyMS))
+
+	record := &UsageRecord{
+		SessionID:    preq.sessionID,
+		Timestamp:    preq.startTime.Unix(),
+		Tool:         toolID,
+		Model:        entry.Model,
+		Provider:     preq.providerID(),
+		Binding:      preq.bindingName(),
+		Project:      preq.project,
+		Profile:      preq.profile,
+		InputTokens:  entry.InputTokens,
+		OutputTokens: entry.OutputTokens,
+		LatencyMS:    latencyMS,
+		CacheHit:     true,
+		SavedCost:    entry.Cost,
+	}
+	if err := h.saveUsageRecord(record); err != nil {
+		logging.Error("Failed to save usage record", logging.Err(err))
+	}
+	h.recordAudit(preq)
+	return true
+}
+
+// storeInCache saves a successful upstream response for later identical requests
+func (h *Handler) storeInCache(preq *proxyRequest, resp *http.Response, body []byte, record *UsageRecord) {
+	h.mu.RLock()
+	cache := h.cache
+	h.mu.RUnlock()
+	if cache == nil || preq.cacheKey == "" || !preq.cacheStore || resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	entry := &cacheEntry{
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        body,
+	}
+	if record != nil {
+		entry.Model = record.Model
+		entry.InputTokens = record.InputTokens
+		entry.OutputTokens = record.OutputTokens
+		entry.Cost = record.InputCost + record.OutputCost
+	}
+	if err := cache.Put(preq.cacheKey, entry); err != nil {
+		logging.Warn("Failed to store response in cache", logging.Err(err))
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 // generateSessionID generates a unique session ID
 func generateSessionID() string {
 	return fmt.Sprintf("proxy_%d", time.Now().UnixNano())
@@ -534,8 +1100,10 @@ func escapeSQLString(s string) string {
 	return strings.ReplaceAll(s, "'", "''")
 }
 
-// checkBudgetBeforeRequest checks if the request would exceed budget limits
-func (h *Handler) checkBudgetBeforeRequest(reqBody []byte) error {
+// checkBudgetBeforeRequest checks the request's estimated cost against every
+// budget it matches, by tool, provider, binding and model as well as global,
+// and returns the denial of the budget that blocks it
+func (h *Handler) checkBudgetBeforeRequest(reqBody []byte, preq *proxyRequest) *budget.Denial {
 	// Parse request to extract model
 	var req map[string]interface{}
 	if err := json.Unmarshal(reqBody, &req); err != nil {
@@ -550,35 +1118,46 @@ func (h *Handler) checkBudgetBeforeRequest(reqBody []byte) error {
 		return nil
 	}
 
-	// Estimate token usage based on average request
-	// For budget checking, we use conservative estimates:
-	// - Average input: 1000 tokens
-	// - Average output: 500 tokens
+	// Count the prompt with the model's tokenizer; output is unknown until the
+	// response arrives, so assume 500 tokens unless the request caps it lower
 	estimatedInputTokens := 1000
+	if preq.prompt != nil {
+		estimatedInputTokens = preq.prompt.Tokens
+	}
 	estimatedOutputTokens := 500
+	for _, field := range []string{"max_tokens", "max_completion_tokens", "max_output_tokens"} {
+		if limit, ok := req[field].(float64); ok && limit > 0 && int(limit) < estimatedOutputTokens {
+			estimatedOutputTokens = int(limit)
+		}
+	}
 
 	// Calculate estimated cost
 	h.mu.RLock()
 	profileCost := config.Cost{Input: 0, Output: 0}
-	inputCost, outputCost := h.pricingTable.CalculateCost(model, profileCost, estimatedInputTokens, estimatedOutputTokens)
+	inputCost, outputCost, priceErr := h.pricingTable.CalculateCost(model, profileCost, estimatedInputTokens, estimatedOutputTokens)
 	h.mu.RUnlock()
 
 	estimatedTotalCost := inputCost + outputCost
 
-	// Check budget (global scope for now)
-	// In a real implementation, we might extract project/profile from headers
-	allowed, message, err := h.budgetTracker.CheckBudget("global", "", estimatedTotalCost)
+	statuses, err := h.budgetStatuses(preq, model)
 	if err != nil {
-		// If budget check fails (e.g., no budget configured), allow the request
 		logging.Info("Budget check error (allowing request)", logging.Err(err))
 		return nil
 	}
-
-	if !allowed {
-		return fmt.Errorf("%s", message)
+	var denial *budget.Denial
+	if priceErr != nil {
+		// A cost that cannot be converted fails closed, as a budget without
+		// an exchange rate does
+		denial, err = h.budgetTracker.CheckUnpriced(statuses, priceErr)
+	} else {
+		denial, err = h.budgetTracker.CheckStatuses(statuses, estimatedTotalCost)
 	}
-
-	return nil
+	if err != nil {
+		// If budget check fails, allow the request
+		logging.Info("Budget check error (allowing request)", logging.Err(err))
+		return nil
+	}
+	return denial
 }
 
 // updateProviderStats updates provider-specific counters
@@ -0,0 +1,240 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/core"
+)
+
+const (
+	// rateLimitCooldown is how long a key sits out after a 429 without Retry-After
+	rateLimitCooldown = 60 * time.Second
+
+	// quotaCooldown is how long a key sits out after the provider reports exhausted quota
+	quotaCooldown = time.Hour
+
+	// rateLimitWindow is the window used to enforce per-key rate_limit_rpm
+	rateLimitWindow = time.Minute
+)
+
+// ErrNoKeyAvailable is returned when every key in a pool is revoked, cooling down or at its rate limit
+var ErrNoKeyAvailable = errors.New("no API key available in pool")
+
+// KeyPool load-balances requests across the API keys configured for one provider
+type KeyPool struct {
+	providerID string
+	strategy   core.KeyStrategy
+	keys       []*pooledKey
+	now        func() time.Time
+	mu         sync.Mutex
+}
+
+// pooledKey tracks rotation and health state for a single key
+type pooledKey struct {
+	key           core.PoolKey
+	fingerprint   string
+	current       int // smooth weighted round-robin counter
+	revoked       bool
+	cooldownUntil time.Time
+	lastLimited   time.Time
+	windowStart   time.Time
+	windowCount   int
+}
+
+// KeyStatus is a point-in-time view of a pooled key, safe to display
+type KeyStatus struct {
+	Fingerprint   string
+	Label         string
+	Weight        int
+	RateLimitRPM  int
+	Revoked       bool
+	CooldownUntil time.Time
+	LastLimited   time.Time
+}
+
+// NewKeyPool creates a pool for the given provider keys
+func NewKeyPool(providerID string, strategy core.KeyStrategy, keys []core.PoolKey) *KeyPool {
+	if strategy == "" {
+		strategy = core.KeyStrategyRoundRobin
+	}
+	pool := &KeyPool{
+		providerID: providerID,
+		strategy:   strategy,
+		now:        time.Now,
+	}
+	for _, key := range keys {
+		if key.APIKey == "" {
+			continue
+		}
+		pool.keys = append(pool.keys, &pooledKey{
+			key:         key,
+			fingerprint: core.KeyFingerprint(key.APIKey),
+		})
+	}
+	return pool
+}
+
+// Size returns the number of keys in the pool
+func (p *KeyPool) Size() int {
+	return len(p.keys)
+}
+
+// Pick selects the next key to use and counts the request against its rate limit.
+// It returns the key and its fingerprint.
+func (p *KeyPool) Pick() (apiKey, fingerprint string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	var candidates []*pooledKey
+	for _, k := range p.keys {
+		if k.available(now) {
+			candidates = append(candidates, k)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", "", ErrNoKeyAvailable
+	}
+
+	var chosen *pooledKey
+	switch p.strategy {
+	case core.KeyStrategyLeastRecentlyLimited:
+		chosen = pickLeastRecentlyLimited(candidates)
+	default:
+		chosen = pickWeightedRoundRobin(candidates)
+	}
+
+	if now.Sub(chosen.windowStart) >= rateLimitWindow {
+		chosen.windowStart = now
+		chosen.windowCount = 0
+	}
+	chosen.windowCount++
+
+	return chosen.key.APIKey, chosen.fingerprint, nil
+}
+
+// Report feeds an upstream response back into the pool so failing keys leave rotation.
+// 401 revokes the key until the proxy restarts, 429 cools it down (honouring Retry-After),
+// and quota errors cool it down for quotaCooldown.
+func (p *KeyPool) Report(fingerprint string, statusCode int, header http.Header, body []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var target *pooledKey
+	for _, k := range p.keys {
+		if k.fingerprint == fingerprint {
+			target = k
+			break
+		}
+	}
+	if target == nil {
+		return
+	}
+
+	now := p.now()
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		target.revoked = true
+	case isQuotaError(statusCode, body):
+		target.lastLimited = now
+		target.cooldownUntil = now.Add(quotaCooldown)
+	case statusCode == http.StatusTooManyRequests:
+		target.lastLimited = now
+		target.cooldownUntil = now.Add(retryAfter(header, rateLimitCooldown))
+	}
+}
+
+// Status returns a snapshot of every key in the pool
+func (p *KeyPool) Status() []KeyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]KeyStatus, 0, len(p.keys))
+	for _, k := range p.keys {
+		statuses = append(statuses, KeyStatus{
+			Fingerprint:   k.fingerprint,
+			Label:         k.key.Label,
+			Weight:        k.key.EffectiveWeight(),
+			RateLimitRPM:  k.key.RateLimitRPM,
+			Revoked:       k.revoked,
+			CooldownUntil: k.cooldownUntil,
+			LastLimited:   k.lastLimited,
+		})
+	}
+	return statuses
+}
+
+// available reports whether the key can take another request right now
+func (k *pooledKey) available(now time.Time) bool {
+	if k.revoked || now.Before(k.cooldownUntil) {
+		return false
+	}
+	if k.key.RateLimitRPM > 0 && now.Sub(k.windowStart) < rateLimitWindow && k.windowCount >= k.key.RateLimitRPM {
+		return false
+	}
+	return true
+}
+
+// pickWeightedRoundRobin implements smooth weighted round-robin over the candidates
+func pickWeightedRoundRobin(candidates []*pooledKey) *pooledKey {
+	total := 0
+	var best *pooledKey
+	for _, k := range candidates {
+		weight := k.key.EffectiveWeight()
+		k.current += weight
+		total += weight
+		if best == nil || k.current > best.current {
+			best = k
+		}
+	}
+	best.current -= total
+	return best
+}
+
+// pickLeastRecentlyLimited prefers keys that have never been limited, then the oldest limit,
+// using weighted round-robin to break ties between equally healthy keys
+func pickLeastRecentlyLimited(candidates []*pooledKey) *pooledKey {
+	oldest := candidates[0].lastLimited
+	for _, k := range candidates[1:] {
+		if k.lastLimited.Before(oldest) {
+			oldest = k.lastLimited
+		}
+	}
+	var tied []*pooledKey
+	for _, k := range candidates {
+		if k.lastLimited.Equal(oldest) {
+			tied = append(tied, k)
+		}
+	}
+	return pickWeightedRoundRobin(tied)
+}
+
+// isQuotaError detects billing/quota exhaustion responses from OpenAI- and Anthropic-style APIs
+func isQuotaError(statusCode int, body []byte) bool {
+	if statusCode == http.StatusPaymentRequired {
+		return true
+	}
+	if statusCode != http.StatusTooManyRequests && statusCode != http.StatusForbidden {
+		return false
+	}
+	lower := bytes.ToLower(body)
+	return bytes.Contains(lower, []byte("insufficient_quota")) ||
+		bytes.Contains(lower, []byte("quota exceeded")) ||
+		bytes.Contains(lower, []byte("credit balance is too low"))
+}
+
+// retryAfter parses a Retry-After header given in seconds, falling back to def
+func retryAfter(header http.Header, def time.Duration) time.Duration {
+	if header == nil {
+		return def
+	}
+	if secs, err := strconv.Atoi(header.Get("Retry-After")); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return def
+}
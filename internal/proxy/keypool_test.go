@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/core"
+)
+
+func newTestPool(strategy core.KeyStrategy, keys ...core.PoolKey) (*KeyPool, *time.Time) {
+	pool := NewKeyPool("test-provider", strategy, keys)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+	return pool, &now
+}
+
+func TestKeyPoolWeightedRoundRobin(t *testing.T) {
+	pool, _ := newTestPool(core.KeyStrategyRoundRobin,
+		core.PoolKey{APIKey: "key-a", Weight: 2},
+		core.PoolKey{APIKey: "key-b"},
+	)
+
+	counts := map[string]int{}
+	for i := 0; i < 30; i++ {
+		key, _, err := pool.Pick()
+		if err != nil {
+			t.Fatalf("Pick() error = %v", err)
+		}
+		counts[key]++
+	}
+
+	if counts["key-a"] != 20 || counts["key-b"] != 10 {
+		t.Errorf("distribution = %v, want key-a=20 key-b=10", counts)
+	}
+}
+
+func TestKeyPoolReport(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		header       http.Header
+		body         string
+		wantBackIn   time.Duration // 0 means never returns
+		wantRevoked  bool
+		wantCooldown bool
+	}{
+		{name: "unauthorized revokes", status: http.StatusUnauthorized, wantRevoked: true},
+		{name: "rate limit default cooldown", status: http.StatusTooManyRequests, wantBackIn: rateLimitCooldown, wantCooldown: true},
+		{name: "rate limit retry-after", status: http.StatusTooManyRequests, header: http.Header{"Retry-After": []string{"5"}}, wantBackIn: 5 * time.Second, wantCooldown: true},
+		{name: "quota exhausted", status: http.StatusTooManyRequests, body: `{"error":{"code":"insufficient_quota"}}`, wantBackIn: quotaCooldown, wantCooldown: true},
+		{name: "success keeps key", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, now := newTestPool(core.KeyStrategyRoundRobin, core.PoolKey{APIKey: "only"})
+			_, fp, err := pool.Pick()
+			if err != nil {
+				t.Fatalf("Pick() error = %v", err)
+			}
+
+			pool.Report(fp, tt.status, tt.header, []byte(tt.body))
+
+			_, _, err = pool.Pick()
+			removed := tt.wantRevoked || tt.wantCooldown
+			if removed && !errors.Is(err, ErrNoKeyAvailable) {
+				t.Fatalf("Pick() after %d error = %v, want ErrNoKeyAvailable", tt.status, err)
+			}
+			if !removed && err != nil {
+				t.Fatalf("Pick() after %d error = %v, want nil", tt.status, err)
+			}
+
+			if tt.wantCooldown {
+				*now = now.Add(tt.wantBackIn - time.Second)
+				if _, _, err := pool.Pick(); err == nil {
+					t.Fatalf("key returned to rotation before cooldown ended")
+				}
+				*now = now.Add(time.Second)
+				if _, _, err := pool.Pick(); err != nil {
+					t.Fatalf("key still out of rotation after cooldown: %v", err)
+				}
+			}
+
+			status := pool.Status()
+			if status[0].Revoked != tt.wantRevoked {
+				t.Errorf("Revoked = %v, want %v", status[0].Revoked, tt.wantRevoked)
+			}
+		})
+	}
+}
+
+func TestKeyPoolRateLimitRPM(t *testing.T) {
+	pool, now := newTestPool(core.KeyStrategyRoundRobin,
+		core.PoolKey{APIKey: "limited", RateLimitRPM: 2},
+	)
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := pool.Pick(); err != nil {
+			t.Fatalf("Pick() #%d error = %v", i+1, err)
+		}
+	}
+	if _, _, err := pool.Pick(); !errors.Is(err, ErrNoKeyAvailable) {
+		t.Fatalf("Pick() over limit error = %v, want ErrNoKeyAvailable", err)
+	}
+
+	*now = now.Add(rateLimitWindow)
+	if _, _, err := pool.Pick(); err != nil {
+		t.Fatalf("Pick() after window reset error = %v", err)
+	}
+}
+
+func TestKeyPoolLeastRecentlyLimited(t *testing.T) {
+	pool, now := newTestPool(core.KeyStrategyLeastRecentlyLimited,
+		core.PoolKey{APIKey: "key-a"},
+		core.PoolKey{APIKey: "key-b"},
+	)
+
+	fpA := core.KeyFingerprint("key-a")
+	fpB := core.KeyFingerprint("key-b")
+
+	pool.Report(fpA, http.StatusTooManyRequests, http.Header{"Retry-After": []string{"1"}}, nil)
+	*now = now.Add(10 * time.Second)
+	pool.Report(fpB, http.StatusTooManyRequests, http.Header{"Retry-After": []string{"1"}}, nil)
+	*now = now.Add(10 * time.Second)
+
+	// Both keys are healthy again; key-a was limited longer ago so it wins every time
+	for i := 0; i < 3; i++ {
+		_, fp, err := pool.Pick()
+		if err != nil {
+			t.Fatalf("Pick() error = %v", err)
+		}
+		if fp != fpA {
+			t.Errorf("Pick() #%d = %s, want %s", i+1, fp, fpA)
+		}
+	}
+}
+
+func TestKeyPoolSkipsEmptyKeys(t *testing.T) {
+	pool := NewKeyPool("p", "", []core.PoolKey{{APIKey: ""}, {APIKey: "real"}})
+	if pool.Size() != 1 {
+		t.Fatalf("Size() = %d, want 1", pool.Size())
+	}
+	if pool.strategy != core.KeyStrategyRoundRobin {
+		t.Errorf("strategy = %q, want round_robin default", pool.strategy)
+	}
+}
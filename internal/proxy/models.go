@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/domain/catalog"
+	"github.com/royisme/bobamixer/internal/domain/core"
+	"github.com/royisme/bobamixer/internal/logging"
+)
+
+// modelListTimeout bounds how long a /v1/models request waits on provider discovery
+const modelListTimeout = 20 * time.Second
+
+// SetModelCatalog enables the aggregated /v1/models endpoint (nil forwards listing requests upstream)
+func (h *Handler) SetModelCatalog(c *catalog.Catalog) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.models = c
+}
+
+// serveModels answers model listing requests from the catalog. It returns false when
+// the request is not a listing request or should be forwarded upstream unchanged.
+func (h *Handler) serveModels(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet || r.Header.Get("X-Proxy-Target") != "" {
+		return false
+	}
+
+	var protocol string
+	switch r.URL.Path {
+	case "/v1/models":
+		protocol = providerOpenAI
+		if r.Header.Get("Anthropic-Version") != "" {
+			protocol = providerAnthropic
+		}
+	case "/openai/v1/models":
+		protocol = providerOpenAI
+	case "/anthropic/v1/models":
+		protocol = providerAnthropic
+	default:
+		return false
+	}
+
+	h.mu.RLock()
+	models := h.models
+	h.mu.RUnlock()
+	if models == nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), modelListTimeout)
+	defer cancel()
+	entries := h.listModels(ctx, models, protocol, r.Header.Get("X-Tool-ID"))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(modelListBody(protocol, entries)); err != nil {
+		logging.Warn("Failed to write model list", logging.Err(err))
+	}
+	return true
+}
+
+// modelEntry is one model in an aggregated listing
+type modelEntry struct {
+	catalog.Model
+	ProviderID string
+}
+
+// listModels aggregates the models a tool can use. A tool with a binding sees its bound
+// provider's models, led by the binding's model override and mapping targets; otherwise
+// every enabled provider speaking the requested protocol contributes.
+func (h *Handler) listModels(ctx context.Context, models *catalog.Catalog, protocol, toolID string) []modelEntry {
+	binding, provider, _ := h.resolveProvider(toolID)
+
+	h.mu.RLock()
+	secrets := h.secrets
+	var candidates []*core.Provider
+	if provider != nil {
+		candidates = append(candidates, provider)
+	} else if h.providers != nil {
+		for i := range h.providers.Providers {
+			p := &h.providers.Providers[i]
+			if p.Enabled && providerTypeForKind(p.Kind) == protocol {
+				candidates = append(candidates, p)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var entries []modelEntry
+	add := func(m catalog.Model, providerID string) {
+		if m.ID == "" || seen[m.ID] {
+			return
+		}
+		seen[m.ID] = true
+		entries = append(entries, modelEntry{Model: m, ProviderID: providerID})
+	}
+
+	if binding != nil && provider != nil {
+		add(catalog.Model{ID: binding.Options.Model}, provider.ID)
+		tiers := make([]string, 0, len(binding.Options.ModelMapping))
+		for tier := range binding.Options.ModelMapping {
+			tiers = append(tiers, tier)
+		}
+		sort.Strings(tiers)
+		for _, tier := range tiers {
+			add(catalog.Model{ID: binding.Options.ModelMapping[tier]}, provider.ID)
+		}
+	}
+
+	for _, p := range candidates {
+		listing, err := models.List(ctx, p, secrets, false)
+		if err != nil {
+			logging.Warn("Model discovery failed", logging.String("provider", p.ID), logging.Err(err))
+			add(catalog.Model{ID: p.DefaultModel}, p.ID)
+			continue
+		}
+		for _, m := range listing.Models {
+			add(m, p.ID)
+		}
+	}
+	return entries
+}
+
+// modelListBody renders entries in the listing shape the client's API expects
+func modelListBody(protocol string, entries []modelEntry) map[string]interface{} {
+	data := make([]map[string]interface{}, 0, len(entries))
+	for _, e := range entries {
+		if protocol == providerAnthropic {
+			item := map[string]interface{}{"type": "model", "id": e.ID, "display_name": e.ID}
+			if e.DisplayName != "" {
+				item["display_name"] = e.DisplayName
+			}
+			if e.Created > 0 {
+				item["created_at"] = time.Unix(e.Created, 0).UTC().Format(time.RFC3339)
+			}
+			data = append(data, item)
+			continue
+		}
+		data = append(data, map[string]interface{}{"id": e.ID, "object": "model", "created": e.Created, "owned_by": e.ProviderID})
+	}
+
+	if protocol == providerAnthropic {
+		body := map[string]interface{}{"data": data, "has_more": false, "first_id": nil, "last_id": nil}
+		if len(entries) > 0 {
+			body["first_id"] = entries[0].ID
+			body["last_id"] = entries[len(entries)-1].ID
+		}
+		return body
+	}
+	return map[string]interface{}{"object": "list", "data": data}
+}
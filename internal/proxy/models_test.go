@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/royisme/bobamixer/internal/domain/catalog"
+	"github.com/royisme/bobamixer/internal/domain/core"
+)
+
+func TestHandlerServesAggregatedModels(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a/models":
+			w.Write([]byte(`{"data":[{"id":"gpt-4o"},{"id":"shared"}]}`)) //nolint:errcheck // test server
+		case "/b/models":
+			w.Write([]byte(`{"data":[{"id":"deepseek-chat"},{"id":"shared"}]}`)) //nolint:errcheck // test server
+		case "/v1/models":
+			w.Write([]byte(`{"data":[{"id":"glm-4.6","display_name":"GLM 4.6"}],"has_more":false}`)) //nolint:errcheck // test server
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	home := t.TempDir()
+	handler, err := NewHandler(filepath.Join(home, "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	secretKey := core.APIKeyConfig{Source: core.APIKeySourceSecrets}
+	handler.SetControlPlane(
+		&core.ProvidersConfig{Providers: []core.Provider{
+			{ID: "a", Kind: core.ProviderKindOpenAI, BaseURL: upstream.URL + "/a", APIKey: secretKey, Enabled: true},
+			{ID: "b", Kind: core.ProviderKindOpenAICompatible, BaseURL: upstream.URL + "/b", APIKey: secretKey, Enabled: true},
+			{ID: "zai", Kind: core.ProviderKindAnthropicCompatible, BaseURL: upstream.URL, APIKey: secretKey, Enabled: true},
+			{ID: "off", Kind: core.ProviderKindOpenAI, BaseURL: upstream.URL + "/off", APIKey: secretKey},
+		}},
+		&core.BindingsConfig{Bindings: []core.Binding{{
+			ToolID: "claude", ProviderID: "zai",
+			Options: core.BindingOptions{Model: "glm-4.6", ModelMapping: map[string]string{"HAIKU": "glm-4.5-air"}},
+		}}},
+		&core.SecretsConfig{Secrets: map[string]core.Secret{"a": {APIKey: "ka"}, "b": {APIKey: "kb"}, "zai": {APIKey: "kz"}, "off": {APIKey: "ko"}}},
+	)
+	handler.SetModelCatalog(catalog.New(home, 0))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openai/v1/models", nil))
+	var openai struct {
+		Object string `json:"object"`
+		Data   []struct {
+			ID      string `json:"id"`
+			OwnedBy string `json:"owned_by"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &openai); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	var ids []string
+	for _, m := range openai.Data {
+		ids = append(ids, m.OwnedBy+"/"+m.ID)
+	}
+	want := []string{"a/gpt-4o", "a/shared", "b/deepseek-chat"}
+	if openai.Object != "list" || len(ids) != len(want) {
+		t.Fatalf("aggregated models = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("model[%d] = %s, want %s", i, ids[i], want[i])
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/anthropic/v1/models", nil)
+	req.Header.Set("X-Tool-ID", "claude")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var anthropic struct {
+		Data []struct {
+			Type        string `json:"type"`
+			ID          string `json:"id"`
+			DisplayName string `json:"display_name"`
+		} `json:"data"`
+		LastID string `json:"last_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &anthropic); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	if len(anthropic.Data) != 2 || anthropic.Data[0].ID != "glm-4.6" || anthropic.Data[1].ID != "glm-4.5-air" {
+		t.Fatalf("bound models = %+v", anthropic.Data)
+	}
+	if anthropic.Data[0].Type != "model" || anthropic.LastID != "glm-4.5-air" {
+		t.Errorf("unexpected anthropic listing shape: %s", rec.Body.String())
+	}
+}
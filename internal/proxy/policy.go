@@ -0,0 +1,413 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/royisme/bobamixer/internal/domain/core"
+	"github.com/royisme/bobamixer/internal/logging"
+)
+
+// PoliciesConfig represents policies.yaml, the proxy's request rewrite policies
+type PoliciesConfig struct {
+	Version  int      `yaml:"version"`
+	Policies []Policy `yaml:"policies"`
+}
+
+// Policy rewrites or rejects requests passing through the proxy.
+// A policy applies when both Tools and Providers match (empty lists match everything).
+type Policy struct {
+	ID        string   `yaml:"id"`
+	Tools     []string `yaml:"tools,omitempty"`     // Tool IDs (from X-Tool-ID)
+	Providers []string `yaml:"providers,omitempty"` // Provider IDs or proxy routes (openai, anthropic)
+
+	MaxTokens         int      `yaml:"max_tokens,omitempty"`          // Clamp max_tokens / max_completion_tokens / max_output_tokens
+	ForceModel        string   `yaml:"force_model,omitempty"`         // Replace the requested model
+	AllowModels       []string `yaml:"allow_models,omitempty"`        // Glob patterns; anything else is denied
+	DenyModels        []string `yaml:"deny_models,omitempty"`         // Glob patterns that are always denied
+	ApplyModelMapping bool     `yaml:"apply_model_mapping,omitempty"` // Rewrite aliases using the binding's model_mapping
+	SystemPrompt      string   `yaml:"system_prompt,omitempty"`       // Prepended to the request's system prompt
+	MaxThinkingBudget int      `yaml:"max_thinking_budget,omitempty"` // Cap thinking.budget_tokens
+
+	Headers HeaderPolicy `yaml:"headers,omitempty"`
+}
+
+// HeaderPolicy adds or strips upstream request headers
+type HeaderPolicy struct {
+	Set    map[string]string `yaml:"set,omitempty"`
+	Remove []string          `yaml:"remove,omitempty"`
+}
+
+// Rewrite is one change (or denial) applied by a policy, kept for the audit log
+type Rewrite struct {
+	PolicyID string
+	Action   string
+	Detail   string
+}
+
+// PolicyDeniedError is returned when a policy rejects a request
+type PolicyDeniedError struct {
+	PolicyID string
+	Model    string
+}
+
+func (e *PolicyDeniedError) Error() string {
+	return fmt.Sprintf("model %q is not allowed by policy %q", e.Model, e.PolicyID)
+}
+
+// LoadPolicies loads and validates policies.yaml from the BobaMixer home directory
+func LoadPolicies(home string) (*PoliciesConfig, error) {
+	path := filepath.Join(home, "policies.yaml")
+
+	//nolint:gosec // Reading from trusted config directory
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Return empty config if file doesn't exist
+			return &PoliciesConfig{Version: 1, Policies: []Policy{}}, nil
+		}
+		return nil, fmt.Errorf("failed to read policies.yaml: %w", err)
+	}
+
+	var config PoliciesConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse policies.yaml: %w", err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// Validate checks that every policy has an ID and valid limits and patterns
+func (c *PoliciesConfig) Validate() error {
+	seen := make(map[string]bool)
+	for i, p := range c.Policies {
+		if p.ID == "" {
+			return fmt.Errorf("policy %d: id is required", i)
+		}
+		if seen[p.ID] {
+			return fmt.Errorf("policy %s: duplicate id", p.ID)
+		}
+		seen[p.ID] = true
+		if p.MaxTokens < 0 || p.MaxThinkingBudget < 0 {
+			return fmt.Errorf("policy %s: limits must not be negative", p.ID)
+		}
+		for _, pattern := range append(append([]string{}, p.AllowModels...), p.DenyModels...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("policy %s: invalid model pattern %q: %w", p.ID, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// matches reports whether the policy applies to the request
+func (p *Policy) matches(preq *proxyRequest) bool {
+	if len(p.Tools) > 0 && !containsString(p.Tools, preq.toolID) {
+		return false
+	}
+	if len(p.Providers) == 0 {
+		return true
+	}
+	if containsString(p.Providers, preq.providerType) {
+		return true
+	}
+	return preq.provider != nil && containsString(p.Providers, preq.provider.ID)
+}
+
+// applyPolicies runs every matching policy over the request body and upstream headers.
+// It returns the (possibly rewritten) body, the rewrites applied, and a
+// *PolicyDeniedError when a policy rejects the request.
+func applyPolicies(policies []Policy, preq *proxyRequest, binding *core.Binding, body []byte, header http.Header) ([]byte, []Rewrite, error) {
+	var rewrites []Rewrite
+
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		req = nil // non-JSON bodies only get header policies
+	}
+	bodyChanged := false
+
+	for i := range policies {
+		p := &policies[i]
+		if !p.matches(preq) {
+			continue
+		}
+		record := func(action, format string, args ...interface{}) {
+			rewrites = append(rewrites, Rewrite{PolicyID: p.ID, Action: action, Detail: fmt.Sprintf(format, args...)})
+		}
+
+		if req != nil {
+			changed, err := p.rewriteBody(req, preq.providerType, binding, record)
+			if changed {
+				bodyChanged = true
+			}
+			if err != nil {
+				return body, rewrites, err
+			}
+		}
+
+		for _, name := range p.Headers.Remove {
+			if header.Get(name) != "" {
+				header.Del(name)
+				record("remove_header", "%s", name)
+			}
+		}
+		for name, value := range p.Headers.Set {
+			header.Set(name, value)
+			record("set_header", "%s", name)
+		}
+	}
+
+	if !bodyChanged {
+		return body, rewrites, nil
+	}
+	rewritten, err := json.Marshal(req)
+	if err != nil {
+		return body, rewrites, fmt.Errorf("encode rewritten body: %w", err)
+	}
+	return rewritten, rewrites, nil
+}
+
+// rewriteBody applies the body-level rules of one policy
+//
+//nolint:gocyclo // Each rule is a short, independent step
+func (p *Policy) rewriteBody(req map[string]interface{}, providerType string, binding *core.Binding, record func(action, format string, args ...interface{})) (bool, error) {
+	changed := false
+	model, _ := req["model"].(string) //nolint:errcheck // requests without a model skip model rules
+
+	if p.ApplyModelMapping && binding != nil && model != "" {
+		if mapped := mapModelAlias(model, binding.Options.ModelMapping); mapped != "" && mapped != model {
+			record("map_model", "%s -> %s", model, mapped)
+			model = mapped
+			req["model"] = model
+			changed = true
+		}
+	}
+
+	if p.ForceModel != "" && model != p.ForceModel {
+		record("force_model", "%s -> %s", model, p.ForceModel)
+		model = p.ForceModel
+		req["model"] = model
+		changed = true
+	}
+
+	if model != "" && !p.modelAllowed(model) {
+		record("deny_model", "%s", model)
+		return changed, &PolicyDeniedError{PolicyID: p.ID, Model: model}
+	}
+
+	if p.MaxTokens > 0 {
+		for _, field := range []string{"max_tokens", "max_completion_tokens", "max_output_tokens"} {
+			if v, ok := req[field].(float64); ok && int(v) > p.MaxTokens {
+				record("clamp_max_tokens", "%s %d -> %d", field, int(v), p.MaxTokens)
+				req[field] = p.MaxTokens
+				changed = true
+			}
+		}
+	}
+
+	if p.MaxThinkingBudget > 0 {
+		if thinking, ok := req["thinking"].(map[string]interface{}); ok {
+			if v, ok := thinking["budget_tokens"].(float64); ok && int(v) > p.MaxThinkingBudget {
+				record("cap_thinking_budget", "%d -> %d", int(v), p.MaxThinkingBudget)
+				thinking["budget_tokens"] = p.MaxThinkingBudget
+				changed = true
+			}
+		}
+	}
+
+	if p.SystemPrompt != "" && injectSystemPrompt(req, providerType, p.SystemPrompt) {
+		record("inject_system_prompt", "%d chars", len(p.SystemPrompt))
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// modelAllowed checks the model against the deny and allow lists
+func (p *Policy) modelAllowed(model string) bool {
+	for _, pattern := range p.DenyModels {
+		if ok, _ := path.Match(pattern, model); ok { //nolint:errcheck // patterns are validated on load
+			return false
+		}
+	}
+	if len(p.AllowModels) == 0 {
+		return true
+	}
+	for _, pattern := range p.AllowModels {
+		if ok, _ := path.Match(pattern, model); ok { //nolint:errcheck // patterns are validated on load
+			return true
+		}
+	}
+	return false
+}
+
+// mapModelAlias resolves a model through a binding's model_mapping.
+// Keys match the whole model name or whole segments of it (e.g. "opus" or
+// "opus-4" in "claude-opus-4-1"). When several keys match, the one with the
+// most segments wins, then the longest, then the first by name, so overlapping
+// keys resolve the same way every time.
+func mapModelAlias(model string, mapping map[string]string) string {
+	aliases := make([]string, 0, len(mapping))
+	for alias := range mapping {
+		aliases = append(aliases, alias)
+	}
+	sort.Slice(aliases, func(i, j int) bool {
+		if si, sj := len(modelSegments(aliases[i])), len(modelSegments(aliases[j])); si != sj {
+			return si > sj
+		}
+		if len(aliases[i]) != len(aliases[j]) {
+			return len(aliases[i]) > len(aliases[j])
+		}
+		return aliases[i] < aliases[j]
+	})
+
+	for _, alias := range aliases {
+		if strings.EqualFold(alias, model) {
+			return mapping[alias]
+		}
+	}
+	segments := modelSegments(model)
+	for _, alias := range aliases {
+		if containsRun(segments, modelSegments(alias)) {
+			return mapping[alias]
+		}
+	}
+	return ""
+}
+
+// modelSegments splits a model name into lowercase segments at - . and _
+func modelSegments(model string) []string {
+	return strings.FieldsFunc(strings.ToLower(model), func(r rune) bool { return r == '-' || r == '.' || r == '_' })
+}
+
+// containsRun reports whether run appears in list as consecutive items
+func containsRun(list, run []string) bool {
+	if len(run) == 0 {
+		return false
+	}
+	for i := 0; i+len(run) <= len(list); i++ {
+		match := true
+		for j, item := range run {
+			if list[i+j] != item {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// injectSystemPrompt prepends the team prompt in the shape each API expects
+func injectSystemPrompt(req map[string]interface{}, providerType, prompt string) bool {
+	if providerType == providerAnthropic {
+		switch system := req["system"].(type) {
+		case nil:
+			req["system"] = prompt
+		case string:
+			req["system"] = prompt + "\n\n" + system
+		case []interface{}:
+			block := map[string]interface{}{"type": "text", "text": prompt}
+			req["system"] = append([]interface{}{block}, system...)
+		default:
+			return false
+		}
+		return true
+	}
+
+	// OpenAI Responses API carries the system prompt in instructions
+	if _, hasInput := req["input"]; hasInput {
+		if instructions, ok := req["instructions"].(string); ok && instructions != "" {
+			req["instructions"] = prompt + "\n\n" + instructions
+		} else {
+			req["instructions"] = prompt
+		}
+		return true
+	}
+
+	messages, ok := req["messages"].([]interface{})
+	if !ok {
+		return false
+	}
+	system := map[string]interface{}{"role": "system", "content": prompt}
+	req["messages"] = append([]interface{}{system}, messages...)
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRequestPolicies runs the configured policies, writing a 403 when one denies the request
+func (h *Handler) applyRequestPolicies(w http.ResponseWriter, r *http.Request, body []byte, preq *proxyRequest) ([]byte, error) {
+	h.mu.RLock()
+	policies := h.policies
+	h.mu.RUnlock()
+	if len(policies) == 0 {
+		return body, nil
+	}
+
+	rewritten, rewrites, err := applyPolicies(policies, preq, preq.binding, body, r.Header)
+	preq.rewrites = rewrites
+	for _, rw := range rewrites {
+		logging.Info("Policy rewrite",
+			logging.String("session_id", preq.sessionID),
+			logging.String("tool", preq.toolID),
+			logging.String("policy", rw.PolicyID),
+			logging.String("action", rw.Action),
+			logging.String("detail", rw.Detail))
+	}
+	if err != nil {
+		var denied *PolicyDeniedError
+		if errors.As(err, &denied) {
+			http.Error(w, fmt.Sprintf("Request denied by proxy policy: %s", denied.Error()), http.StatusForbidden)
+		} else {
+			http.Error(w, "Failed to apply proxy policies", http.StatusInternalServerError)
+		}
+		h.recordRewrites(preq)
+		return nil, fmt.Errorf("apply policies: %w", err)
+	}
+	return rewritten, nil
+}
+
+// recordRewrites stores the policy rewrites applied to a request against its session
+func (h *Handler) recordRewrites(preq *proxyRequest) {
+	if len(preq.rewrites) == 0 {
+		return
+	}
+
+	ts := preq.startTime.Unix()
+	statements := []string{fmt.Sprintf(`INSERT OR IGNORE INTO sessions (id, started_at, ended_at, success, latency_ms)
+		VALUES ('%s', %d, %d, 0, 0);`, escapeSQLString(preq.sessionID), ts, ts)}
+	for i, rw := range preq.rewrites {
+		statements = append(statements, fmt.Sprintf(`INSERT INTO policy_rewrites (id, session_id, ts, tool, policy_id, action, detail)
+		VALUES ('%s_%d', '%s', %d, '%s', '%s', '%s', '%s');`,
+			escapeSQLString(preq.sessionID), i, escapeSQLString(preq.sessionID), ts,
+			escapeSQLString(preq.toolID), escapeSQLString(rw.PolicyID),
+			escapeSQLString(rw.Action), escapeSQLString(rw.Detail)))
+	}
+
+	if err := h.db.Exec(strings.Join(statements, "\n")); err != nil {
+		logging.Error("Failed to record policy rewrites", logging.Err(err))
+	}
+}
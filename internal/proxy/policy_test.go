@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/royisme/bobamixer/internal/domain/core"
+)
+
+func decodeBody(t *testing.T, body []byte) map[string]interface{} {
+	t.Helper()
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	return req
+}
+
+func TestApplyPoliciesRewritesAnthropicRequest(t *testing.T) {
+	policies := []Policy{{
+		ID:                "team",
+		Tools:             []string{"claude"},
+		MaxTokens:         1024,
+		ApplyModelMapping: true,
+		SystemPrompt:      "Follow team guidelines.",
+		MaxThinkingBudget: 512,
+		Headers: HeaderPolicy{
+			Set:    map[string]string{"X-Team": "platform"},
+			Remove: []string{"anthropic-beta"},
+		},
+	}}
+	binding := &core.Binding{Options: core.BindingOptions{ModelMapping: map[string]string{"opus": "glm-4.6"}}}
+	preq := &proxyRequest{toolID: "claude", providerType: providerAnthropic}
+	header := http.Header{"Anthropic-Beta": []string{"x"}}
+	body := []byte(`{"model":"claude-opus-4-1","max_tokens":8192,"system":"Be brief.","thinking":{"type":"enabled","budget_tokens":4000},"messages":[]}`)
+
+	out, rewrites, err := applyPolicies(policies, preq, binding, body, header)
+	if err != nil {
+		t.Fatalf("applyPolicies: %v", err)
+	}
+
+	req := decodeBody(t, out)
+	if req["model"] != "glm-4.6" {
+		t.Errorf("model = %v, want glm-4.6", req["model"])
+	}
+	if req["max_tokens"] != float64(1024) {
+		t.Errorf("max_tokens = %v, want 1024", req["max_tokens"])
+	}
+	if req["system"] != "Follow team guidelines.\n\nBe brief." {
+		t.Errorf("system = %q", req["system"])
+	}
+	if budget := req["thinking"].(map[string]interface{})["budget_tokens"]; budget != float64(512) {
+		t.Errorf("thinking.budget_tokens = %v, want 512", budget)
+	}
+	if header.Get("X-Team") != "platform" || header.Get("anthropic-beta") != "" {
+		t.Errorf("headers not rewritten: %v", header)
+	}
+
+	actions := make([]string, 0, len(rewrites))
+	for _, rw := range rewrites {
+		actions = append(actions, rw.Action)
+	}
+	want := "map_model,clamp_max_tokens,cap_thinking_budget,inject_system_prompt,remove_header,set_header"
+	if got := strings.Join(actions, ","); got != want {
+		t.Errorf("rewrites = %s, want %s", got, want)
+	}
+}
+
+func TestMapModelAliasOverlapping(t *testing.T) {
+	mapping := map[string]string{
+		"opus":            "glm-4.5",
+		"opus-4":          "glm-4.6",
+		"sonnet-4-5":      "glm-4.6-air",
+		"sonnet":          "glm-4.5-air",
+		"4":               "glm-4",
+		"5":               "glm-5",
+		"Claude-Opus-4-1": "glm-4.6-exact",
+	}
+	tests := []struct{ model, want string }{
+		{"claude-opus-4-1", "glm-4.6-exact"},  // the whole name beats any segments
+		{"claude-opus-4-20250514", "glm-4.6"}, // opus-4 beats opus and 4
+		{"claude-sonnet-4-5", "glm-4.6-air"},  // more segments win
+		{"claude-sonnet-3-7", "glm-4.5-air"},  // only sonnet matches
+		{"claude-haiku-4-5", "glm-4"},         // a tie goes to the first by name
+		{"gpt-4o", ""},                        // 4o is not the segment 4
+	}
+	for _, tt := range tests {
+		// Map order is random; resolve repeatedly to catch order dependence
+		for i := 0; i < 20; i++ {
+			if got := mapModelAlias(tt.model, mapping); got != tt.want {
+				t.Fatalf("mapModelAlias(%s) = %q, want %q", tt.model, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestApplyPoliciesOpenAISystemPrompt(t *testing.T) {
+	policies := []Policy{{ID: "prompt", SystemPrompt: "Team prompt"}}
+	preq := &proxyRequest{providerType: providerOpenAI}
+	body := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+
+	out, _, err := applyPolicies(policies, preq, nil, body, http.Header{})
+	if err != nil {
+		t.Fatalf("applyPolicies: %v", err)
+	}
+	messages := decodeBody(t, out)["messages"].([]interface{})
+	first := messages[0].(map[string]interface{})
+	if len(messages) != 2 || first["role"] != "system" || first["content"] != "Team prompt" {
+		t.Errorf("messages = %v, want system prompt first", messages)
+	}
+}
+
+func TestApplyPoliciesDeniesModels(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		model  string
+		denied bool
+		toolID string
+	}{
+		{name: "deny glob", policy: Policy{ID: "p", DenyModels: []string{"claude-opus-*"}}, model: "claude-opus-4-1", denied: true},
+		{name: "allow list miss", policy: Policy{ID: "p", AllowModels: []string{"*haiku*"}}, model: "claude-sonnet-4", denied: true},
+		{name: "allow list hit", policy: Policy{ID: "p", AllowModels: []string{"*haiku*"}}, model: "claude-3-5-haiku"},
+		{name: "force model avoids deny", policy: Policy{ID: "p", ForceModel: "claude-3-5-haiku", DenyModels: []string{"*opus*"}}, model: "claude-opus-4-1"},
+		{name: "other tool unaffected", policy: Policy{ID: "p", Tools: []string{"codex"}, DenyModels: []string{"*"}}, model: "gpt-4o", toolID: "claude"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preq := &proxyRequest{toolID: tt.toolID, providerType: providerAnthropic}
+			body := []byte(`{"model":"` + tt.model + `"}`)
+			_, _, err := applyPolicies([]Policy{tt.policy}, preq, nil, body, http.Header{})
+			var denied *PolicyDeniedError
+			if got := errors.As(err, &denied); got != tt.denied {
+				t.Errorf("denied = %v (err %v), want %v", got, err, tt.denied)
+			}
+		})
+	}
+}
+
+func TestLoadPoliciesValidates(t *testing.T) {
+	home := t.TempDir()
+	cfg, err := LoadPolicies(home)
+	if err != nil || len(cfg.Policies) != 0 {
+		t.Fatalf("missing file should yield empty config, got %v, %v", cfg, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(home, "policies.yaml"), []byte("version: 1\npolicies:\n  - id: bad\n    deny_models: [\"[\"]\n"), 0600); err != nil {
+		t.Fatalf("write policies: %v", err)
+	}
+	if _, err := LoadPolicies(home); err == nil {
+		t.Fatal("expected invalid pattern error")
+	}
+}
+
+func TestHandlerRecordsPolicyRewrites(t *testing.T) {
+	var upstreamBody map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&upstreamBody); err != nil {
+			t.Errorf("decode upstream body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"usage":{"input_tokens":10,"output_tokens":5}}`)) //nolint:errcheck // test server
+	}))
+	defer upstream.Close()
+
+	handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	handler.SetPolicies(&PoliciesConfig{Policies: []Policy{
+		{ID: "clamp", MaxTokens: 100},
+		{ID: "no-opus", DenyModels: []string{"*opus*"}},
+	}})
+
+	send := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", strings.NewReader(body))
+		req.Header.Set("X-Proxy-Target", upstream.URL)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := send(`{"model":"claude-3-5-haiku","max_tokens":4096}`); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if upstreamBody["max_tokens"] != float64(100) {
+		t.Errorf("upstream max_tokens = %v, want 100", upstreamBody["max_tokens"])
+	}
+	if code := send(`{"model":"claude-opus-4-1","max_tokens":10}`); code != http.StatusForbidden {
+		t.Fatalf("denied status = %d, want 403", code)
+	}
+
+	count, err := handler.db.QueryInt("SELECT COUNT(*) FROM policy_rewrites r JOIN sessions s ON s.id = r.session_id;")
+	if err != nil {
+		t.Fatalf("query rewrites: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("recorded rewrites = %d, want 2 (clamp + deny)", count)
+	}
+}
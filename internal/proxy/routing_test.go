@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/royisme/bobamixer/internal/domain/routing"
+	"github.com/royisme/bobamixer/internal/store/config"
+)
+
+func TestEvaluateRoutingMatchesSubAgentOnLatestUserMessage(t *testing.T) {
+	handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	engine, err := routing.CompileRoutes(&config.RoutesConfig{
+		SubAgents: map[string]config.SubAgent{
+			"code_review": {Profile: "work-heavy", Triggers: []string{"review"}},
+		},
+		Rules: []config.RouteRule{{ID: "default", If: "true", Use: "quick-tasks"}},
+	})
+	if err != nil {
+		t.Fatalf("CompileRoutes: %v", err)
+	}
+	handler.SetRoutingEngine(engine)
+
+	body := `{"model":"gpt-4o","messages":[
+		{"role":"system","content":"You are a helpful assistant."},
+		{"role":"user","content":[{"type":"text","text":"Please review this diff"}]}]}`
+	decision := handler.evaluateRouting([]byte(body), &proxyRequest{sessionID: "s1"})
+	if decision == nil {
+		t.Fatal("evaluateRouting returned nil")
+	}
+	if decision.Profile != "work-heavy" {
+		t.Errorf("profile = %q, want work-heavy from sub-agent code_review", decision.Profile)
+	}
+
+	// Features are only kept once recording is turned on
+	recorded := func() int {
+		n, err := handler.db.QueryInt("SELECT COUNT(*) FROM route_features;")
+		if err != nil {
+			t.Fatalf("count route features: %v", err)
+		}
+		return n
+	}
+	if n := recorded(); n != 0 {
+		t.Errorf("route features = %d without recording, want 0", n)
+	}
+	handler.SetRouteRecording(true)
+	handler.evaluateRouting([]byte(body), &proxyRequest{sessionID: "s2"})
+	if n := recorded(); n != 1 {
+		t.Errorf("route features = %d with recording, want 1", n)
+	}
+}
+
+func TestProxiedRequestsServedByTheRoutedProfileTeachTheBandit(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"usage":{"prompt_tokens":10,"completion_tokens":5}}`)) //nolint:errcheck // test server
+	}))
+	defer upstream.Close()
+
+	handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	engine, err := routing.CompileRoutes(&config.RoutesConfig{
+		Rules: []config.RouteRule{{ID: "everything", If: "true", Use: "quick"}},
+	})
+	if err != nil {
+		t.Fatalf("CompileRoutes: %v", err)
+	}
+	handler.SetRoutingEngine(engine)
+	handler.SetProfiles(config.Profiles{
+		"quick": {Key: "quick", Model: "gpt-4o-mini"},
+		"heavy": {Key: "heavy", Model: "gpt-4o"},
+	})
+	send := func(model, profile string) {
+		body := `{"model":"` + model + `","messages":[{"role":"user","content":"hi"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/openai/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("X-Proxy-Target", upstream.URL)
+		if profile != "" {
+			req.Header.Set(profileHeader, profile)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	send("gpt-4o-mini", "") // the routed profile's model
+	send("gpt-4o", "")      // another profile's model
+	send("gpt-4o", "quick") // the routed profile, named by the header
+	send("gpt-4o-mini", "heavy")
+
+	rows, err := handler.db.QueryRows("SELECT route_key, COALESCE(profile, '') FROM sessions ORDER BY started_at, rowid;")
+	if err != nil {
+		t.Fatalf("query sessions: %v", err)
+	}
+	want := []string{"rule:everything|quick", "|", "rule:everything|quick", "|heavy"}
+	if strings.Join(rows, ",") != strings.Join(want, ",") {
+		t.Errorf("sessions = %v, want %v", rows, want)
+	}
+
+	pulls, err := handler.db.QueryInt("SELECT pulls FROM bandit_arms WHERE context = 'rule:everything' AND profile = 'quick';")
+	if err != nil || pulls != 2 {
+		t.Errorf("bandit pulls of quick = %d, %v; want the 2 routed sessions", pulls, err)
+	}
+}
@@ -128,6 +128,11 @@ func (s *Server) Addr() string {
 	return s.addr
 }
 
+// Handler returns the underlying request handler for configuration
+func (s *Server) Handler() *Handler {
+	return s.handler
+}
+
 // Stats returns current proxy statistics
 func (s *Server) Stats() *Stats {
 	return s.handler.Stats()
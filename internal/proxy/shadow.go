@@ -0,0 +1,434 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/royisme/bobamixer/internal/domain/core"
+	"github.com/royisme/bobamixer/internal/logging"
+	"github.com/royisme/bobamixer/internal/store/config"
+)
+
+const (
+	// shadowTimeout bounds a mirrored request; shadows never hold up the primary
+	shadowTimeout = 60 * time.Second
+
+	// maxShadowOutput caps the shadow output text stored for review
+	maxShadowOutput = 16 * 1024
+)
+
+// ShadowTarget is an alternate provider that receives mirrored requests
+type ShadowTarget struct {
+	ProviderID string   // Provider from providers.yaml
+	Model      string   // Model sent to the shadow; defaults to the provider's default model
+	Tools      []string // Only mirror requests from these tools (empty = all)
+}
+
+// ShadowConfig controls traffic mirroring
+type ShadowConfig struct {
+	SampleRate float64 // Fraction of successful requests mirrored, 0..1
+	Targets    []ShadowTarget
+}
+
+// SetShadow enables mirroring of a sample of requests to alternate providers; nil disables it
+func (h *Handler) SetShadow(config *ShadowConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if config == nil || config.SampleRate <= 0 || len(config.Targets) == 0 {
+		h.shadow = nil
+		return
+	}
+	h.shadow = config
+	if h.shadowSample == nil {
+		rng := rand.New(rand.NewSource(time.Now().UnixNano())) // #nosec G404 -- sampling does not need a secure source
+		h.shadowSample = rng.Float64
+	}
+}
+
+// shadowJob is one mirrored request to run in the background
+type shadowJob struct {
+	id           string
+	sessionID    string
+	toolID       string
+	providerType string
+	targetPath   string
+	provider     *core.Provider
+	model        string
+	pool         *KeyPool
+	header       http.Header
+	body         []byte
+
+	primaryProvider  string
+	primaryModel     string
+	primaryLatencyMS int64
+	primaryCost      float64
+	primaryText      string
+}
+
+// mirrorRequest samples a successful primary exchange and replays it against each shadow target.
+// The primary response has already been decided; shadows only record results.
+func (h *Handler) mirrorRequest(r *http.Request, preq *proxyRequest, reqBody []byte, statusCode int, respBody []byte, record *UsageRecord) {
+	if statusCode != http.StatusOK {
+		return
+	}
+
+	h.mu.Lock()
+	shadow := h.shadow
+	sampled := shadow != nil && h.shadowSample() < shadow.SampleRate
+	providers := h.providers
+	pools := h.keyPools
+	h.mu.Unlock()
+	if !sampled || providers == nil {
+		return
+	}
+
+	primaryProvider := preq.providerType
+	if preq.provider != nil {
+		primaryProvider = preq.provider.ID
+	}
+	primaryModel, primaryLatency, primaryCost := "", time.Since(preq.startTime).Milliseconds(), 0.0
+	if record != nil {
+		primaryModel = record.Model
+		primaryLatency = record.LatencyMS
+		primaryCost = record.InputCost + record.OutputCost
+	} else {
+		primaryModel, _, _ = h.parseTokenUsage(preq.providerType, reqBody, respBody)
+	}
+	primaryText := extractOutputText(respBody)
+
+	for i, target := range shadow.Targets {
+		if len(target.Tools) > 0 && !containsString(target.Tools, preq.toolID) {
+			continue
+		}
+		provider, err := providers.FindProvider(target.ProviderID)
+		if err != nil {
+			logging.Warn("Shadow provider not found", logging.String("provider", target.ProviderID))
+			continue
+		}
+		// Requests are mirrored verbatim, so the shadow must speak the same protocol
+		if providerTypeForKind(provider.Kind) != preq.providerType || provider.ID == primaryProvider {
+			continue
+		}
+
+		model := target.Model
+		if model == "" {
+			model = provider.DefaultModel
+		}
+		header := make(http.Header)
+		h.copyHeaders(header, r.Header)
+		stripProxyHeaders(header)
+		for _, name := range []string{"Authorization", "X-Api-Key", "Accept-Encoding", "Content-Length"} {
+			header.Del(name)
+		}
+
+		job := &shadowJob{
+			id:               fmt.Sprintf("%s_shadow_%d", preq.sessionID, i),
+			sessionID:        preq.sessionID,
+			toolID:           preq.toolID,
+			providerType:     preq.providerType,
+			targetPath:       preq.targetPath,
+			provider:         provider,
+			model:            model,
+			pool:             pools[provider.ID],
+			header:           header,
+			body:             shadowBody(reqBody, model),
+			primaryProvider:  primaryProvider,
+			primaryModel:     primaryModel,
+			primaryLatencyMS: primaryLatency,
+			primaryCost:      primaryCost,
+			primaryText:      primaryText,
+		}
+
+		h.shadowWG.Add(1)
+		go func() {
+			defer h.shadowWG.Done()
+			h.runShadow(job)
+		}()
+	}
+}
+
+// runShadow sends a mirrored request and stores the outcome next to the primary's
+func (h *Handler) runShadow(job *shadowJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+	defer cancel()
+
+	var result shadowResult
+	start := time.Now()
+	defer func() {
+		result.latencyMS = time.Since(start).Milliseconds()
+		h.saveShadowResult(job, &result)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, upstreamBaseURL(job.provider.BaseURL)+job.targetPath, bytes.NewReader(job.body))
+	if err != nil {
+		result.err = err.Error()
+		return
+	}
+	req.Header = job.header
+	shadowReq := &proxyRequest{providerType: job.providerType, provider: job.provider, keyPool: job.pool}
+	if err := h.applyPooledKey(req.Header, shadowReq); err != nil {
+		result.err = err.Error()
+		return
+	}
+
+	client := &http.Client{Transport: h.transport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		result.err = err.Error()
+		return
+	}
+	defer resp.Body.Close() //nolint:errcheck // read-only
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		result.err = err.Error()
+		return
+	}
+	if job.pool != nil && shadowReq.keyFingerprint != "" {
+		job.pool.Report(shadowReq.keyFingerprint, resp.StatusCode, resp.Header, body)
+	}
+
+	result.statusCode = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		result.err = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		return
+	}
+
+	model, inputTokens, outputTokens := h.parseTokenUsage(job.providerType, job.body, body)
+	if model != "" {
+		job.model = model
+	}
+	result.inputTokens, result.outputTokens = inputTokens, outputTokens
+	if job.model != "" && (inputTokens > 0 || outputTokens > 0) {
+		h.mu.RLock()
+		inputCost, outputCost, err := h.pricingTable.CalculateCost(job.model, config.Cost{}, inputTokens, outputTokens)
+		h.mu.RUnlock()
+		if err != nil {
+			logging.Warn("Shadow cost not recorded", logging.String("model", job.model), logging.Err(err))
+		}
+		result.cost = inputCost + outputCost
+	}
+	result.output = extractOutputText(body)
+	similarity := TextSimilarity(job.primaryText, result.output)
+	result.similarity = &similarity
+}
+
+// shadowResult is the measured outcome of a shadow request
+type shadowResult struct {
+	statusCode   int
+	latencyMS    int64
+	inputTokens  int
+	outputTokens int
+	cost         float64
+	similarity   *float64
+	output       string
+	err          string
+}
+
+func (h *Handler) saveShadowResult(job *shadowJob, result *shadowResult) {
+	logging.Info("Shadow request",
+		logging.String("session", job.sessionID),
+		logging.String("provider", job.provider.ID),
+		logging.String("model", job.model),
+		logging.Int("status", result.statusCode),
+		logging.Int64("latency_ms", result.latencyMS),
+		logging.String("error", result.err))
+
+	similarity := "NULL"
+	if result.similarity != nil {
+		similarity = fmt.Sprintf("%.4f", *result.similarity)
+	}
+	output := truncateUTF8(result.output, maxShadowOutput)
+
+	ts := time.Now().Unix()
+	query := fmt.Sprintf(`INSERT OR IGNORE INTO sessions (id, started_at, ended_at, success, latency_ms)
+		VALUES ('%s', %d, %d, 0, 0);
+		INSERT OR REPLACE INTO shadow_results (id, session_id, ts, tool, primary_provider, primary_model, primary_latency_ms, primary_cost,
+			shadow_provider, shadow_model, status_code, latency_ms, input_tokens, output_tokens, cost, similarity, output, error)
+		VALUES ('%s', '%s', %d, '%s', '%s', '%s', %d, %.6f, '%s', '%s', %d, %d, %d, %d, %.6f, %s, '%s', '%s');`,
+		escapeSQLString(job.sessionID), ts, ts,
+		escapeSQLString(job.id), escapeSQLString(job.sessionID), ts, escapeSQLString(job.toolID),
+		escapeSQLString(job.primaryProvider), escapeSQLString(job.primaryModel), job.primaryLatencyMS, job.primaryCost,
+		escapeSQLString(job.provider.ID), escapeSQLString(job.model), result.statusCode, result.latencyMS,
+		result.inputTokens, result.outputTokens, result.cost, similarity,
+		escapeSQLString(output), escapeSQLString(result.err))
+	if err := h.db.Exec(query); err != nil {
+		logging.Error("Failed to record shadow result", logging.Err(err))
+	}
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// waitForShadows blocks until in-flight shadow requests finish
+func (h *Handler) waitForShadows() {
+	h.shadowWG.Wait()
+}
+
+// shadowBody retargets a request at the shadow model and disables streaming so the
+// complete response (and its usage) arrives as one JSON document
+func shadowBody(body []byte, model string) []byte {
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return body
+	}
+	if model != "" {
+		req["model"] = model
+	}
+	if _, ok := req["stream"]; ok {
+		req["stream"] = false
+	}
+	delete(req, "stream_options")
+	out, err := json.Marshal(req)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// providerTypeForKind maps a provider kind to the proxy route (wire protocol) it speaks
+func providerTypeForKind(kind core.ProviderKind) string {
+	switch kind {
+	case core.ProviderKindOpenAI, core.ProviderKindOpenAICompatible:
+		return providerOpenAI
+	case core.ProviderKindAnthropic, core.ProviderKindAnthropicCompatible:
+		return providerAnthropic
+	default:
+		return ""
+	}
+}
+
+// extractOutputText collects the assistant text and tool calls from a JSON or SSE response
+func extractOutputText(body []byte) string {
+	var b strings.Builder
+	forEachResponseObject(body, func(obj map[string]interface{}) {
+		collectOutput(obj, &b)
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// collectOutput appends output text from one response object or stream event
+func collectOutput(obj map[string]interface{}, b *strings.Builder) {
+	write := func(v interface{}) {
+		if s, ok := v.(string); ok {
+			b.WriteString(s)
+		}
+	}
+
+	// OpenAI chat: choices[].message or choices[].delta
+	if choices, ok := obj["choices"].([]interface{}); ok {
+		for _, c := range choices {
+			choice, _ := c.(map[string]interface{}) //nolint:errcheck // skip malformed entries
+			for _, key := range []string{"message", "delta"} {
+				msg, ok := choice[key].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				write(msg["content"])
+				calls, _ := msg["tool_calls"].([]interface{}) //nolint:errcheck // absent means none
+				for _, tc := range calls {
+					call, _ := tc.(map[string]interface{}) //nolint:errcheck // skip malformed entries
+					if fn, ok := call["function"].(map[string]interface{}); ok {
+						write(fn["name"])
+						write(fn["arguments"])
+					}
+				}
+			}
+		}
+	}
+
+	// Anthropic content blocks and OpenAI responses output items
+	for _, key := range []string{"content", "output"} {
+		items, _ := obj[key].([]interface{}) //nolint:errcheck // absent means none
+		for _, it := range items {
+			item, _ := it.(map[string]interface{}) //nolint:errcheck // skip malformed entries
+			collectItem(item, b)
+		}
+	}
+
+	// Stream events
+	switch obj["type"] {
+	case "content_block_start":
+		if block, ok := obj["content_block"].(map[string]interface{}); ok {
+			write(block["name"])
+		}
+	case "content_block_delta":
+		if delta, ok := obj["delta"].(map[string]interface{}); ok {
+			write(delta["text"])
+			write(delta["partial_json"])
+		}
+	case "response.output_text.delta", "response.function_call_arguments.delta":
+		write(obj["delta"])
+	}
+}
+
+func collectItem(item map[string]interface{}, b *strings.Builder) {
+	switch item["type"] {
+	case "text", "output_text":
+		if s, ok := item["text"].(string); ok {
+			b.WriteString(s)
+		}
+	case "tool_use":
+		if s, ok := item["name"].(string); ok {
+			b.WriteString(s)
+		}
+		if input, err := json.Marshal(item["input"]); err == nil {
+			b.Write(input)
+		}
+	case "function_call":
+		for _, key := range []string{"name", "arguments"} {
+			if s, ok := item[key].(string); ok {
+				b.WriteString(s)
+			}
+		}
+	case "message":
+		parts, _ := item["content"].([]interface{}) //nolint:errcheck // absent means none
+		for _, p := range parts {
+			part, _ := p.(map[string]interface{}) //nolint:errcheck // skip malformed entries
+			collectItem(part, b)
+		}
+	}
+}
+
+// TextSimilarity scores two outputs from 0 (disjoint) to 1 (same words), using
+// Jaccard similarity over lower-cased word sets
+func TextSimilarity(a, b string) float64 {
+	wordsA, wordsB := wordSet(a), wordSet(b)
+	if len(wordsA) == 0 && len(wordsB) == 0 {
+		return 1
+	}
+	shared := 0
+	for w := range wordsA {
+		if wordsB[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wordsA)+len(wordsB)-shared)
+}
+
+func wordSet(s string) map[string]bool {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/royisme/bobamixer/internal/domain/core"
+)
+
+func TestHandlerMirrorsSampledRequests(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"model":"claude-sonnet-4","content":[{"type":"text","text":"the answer is forty two"}],"usage":{"input_tokens":10,"output_tokens":5}}`)) //nolint:errcheck // test server
+	}))
+	defer primary.Close()
+
+	var shadowReq map[string]interface{}
+	var shadowKey string
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		shadowKey = r.Header.Get("x-api-key")
+		data, _ := io.ReadAll(r.Body)    //nolint:errcheck // test server
+		json.Unmarshal(data, &shadowReq) //nolint:errcheck // asserted below
+
+		w.Write([]byte(`{"model":"glm-4.6","content":[{"type":"text","text":"the answer is 42"}],"usage":{"input_tokens":9,"output_tokens":4}}`)) //nolint:errcheck // test server
+	}))
+	defer shadow.Close()
+
+	handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	handler.SetControlPlane(
+		&core.ProvidersConfig{Providers: []core.Provider{{
+			ID: "zai", Kind: core.ProviderKindAnthropicCompatible, BaseURL: shadow.URL,
+			APIKey: core.APIKeyConfig{Source: core.APIKeySourceSecrets}, DefaultModel: "glm-4.6",
+		}}},
+		&core.BindingsConfig{},
+		&core.SecretsConfig{Secrets: map[string]core.Secret{"zai": {APIKey: "shadow-key"}}},
+	)
+	handler.SetShadow(&ShadowConfig{SampleRate: 1, Targets: []ShadowTarget{{ProviderID: "zai"}}})
+
+	body := `{"model":"claude-sonnet-4","stream":true,"messages":[{"role":"user","content":"what is six times seven"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", strings.NewReader(body))
+	req.Header.Set("X-Proxy-Target", primary.URL)
+	req.Header.Set("x-api-key", "primary-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	handler.waitForShadows()
+
+	if !strings.Contains(rec.Body.String(), "forty two") {
+		t.Fatalf("primary response changed: %s", rec.Body.String())
+	}
+	if shadowKey != "shadow-key" {
+		t.Errorf("shadow used key %q, want the shadow provider's key", shadowKey)
+	}
+	if shadowReq["model"] != "glm-4.6" || shadowReq["stream"] != false {
+		t.Errorf("shadow body not retargeted: %v", shadowReq)
+	}
+
+	row, err := handler.db.QueryRow(`SELECT primary_model, shadow_provider, shadow_model, status_code, input_tokens + output_tokens, similarity, output
+		FROM shadow_results;`)
+	if err != nil {
+		t.Fatalf("query shadow results: %v", err)
+	}
+	parts := strings.Split(row, "|")
+	if len(parts) != 7 || parts[0] != "claude-sonnet-4" || parts[1] != "zai" || parts[2] != "glm-4.6" || parts[3] != "200" || parts[4] != "13" {
+		t.Fatalf("unexpected shadow row %q", row)
+	}
+	if parts[5] != "0.5" || parts[6] != "the answer is 42" {
+		t.Errorf("similarity/output = %s / %s", parts[5], parts[6])
+	}
+
+	usage, err := handler.db.QueryInt("SELECT COUNT(*) FROM usage_records;")
+	if err != nil {
+		t.Fatalf("query usage: %v", err)
+	}
+	if usage != 1 {
+		t.Errorf("usage records = %d, want only the primary", usage)
+	}
+}
+
+func TestExtractOutputTextAndSimilarity(t *testing.T) {
+	sse := "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello \"}}\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"world\"}}]}\n\ndata: [DONE]\n\n"
+	if got := extractOutputText([]byte(sse)); got != "Hello world" {
+		t.Errorf("SSE text = %q", got)
+	}
+
+	responses := `{"output":[{"type":"message","content":[{"type":"output_text","text":"ok"}]},{"type":"function_call","name":"run","arguments":"{}"}]}`
+	if got := extractOutputText([]byte(responses)); got != "okrun{}" {
+		t.Errorf("responses text = %q", got)
+	}
+
+	if s := TextSimilarity("The cat sat", "the cat sat"); s != 1 {
+		t.Errorf("identical words similarity = %v", s)
+	}
+	if s := TextSimilarity("alpha beta", "gamma delta"); s != 0 {
+		t.Errorf("disjoint similarity = %v", s)
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 3, "hel"},
+		{"答案是四十二", 7, "答案"}, // 3-byte characters; the third would be cut
+		{"答案是四十二", 6, "答案"},
+		{"答案", 2, ""},
+	}
+	for _, tt := range tests {
+		if got := truncateUTF8(tt.s, tt.n); got != tt.want {
+			t.Errorf("truncateUTF8(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"encoding/json"
+
+	"github.com/royisme/bobamixer/internal/logging"
+	"github.com/royisme/bobamixer/internal/transcript"
+)
+
+// SetTranscriptRecorder enables recording of every forwarded exchange; nil disables it
+func (h *Handler) SetTranscriptRecorder(recorder *transcript.Recorder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.transcripts = recorder
+}
+
+// recordTranscript stores a forwarded exchange when recording is enabled
+func (h *Handler) recordTranscript(preq *proxyRequest, reqBody []byte, statusCode int, contentType string, respBody []byte) {
+	h.mu.RLock()
+	recorder := h.transcripts
+	h.mu.RUnlock()
+	if recorder == nil {
+		return
+	}
+
+	request := json.RawMessage(reqBody)
+	if !json.Valid(reqBody) {
+		encoded, _ := json.Marshal(string(reqBody)) //nolint:errcheck // marshalling a string cannot fail
+		request = encoded
+	}
+	entry := transcript.Entry{
+		Timestamp:   preq.startTime,
+		SessionID:   preq.sessionID,
+		Tool:        preq.toolID,
+		Provider:    preq.providerType,
+		Path:        preq.targetPath,
+		StatusCode:  statusCode,
+		ContentType: contentType,
+		Request:     request,
+		Response:    string(respBody),
+	}
+	if err := recorder.Record(entry); err != nil {
+		logging.Warn("Failed to record transcript", logging.Err(err))
+	}
+}
@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/royisme/bobamixer/internal/transcript"
+)
+
+func TestHandlerRecordsTranscripts(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"usage":{"input_tokens":3,"output_tokens":2}}`)) //nolint:errcheck // test server
+	}))
+	defer upstream.Close()
+
+	handler, err := NewHandler(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	dir := filepath.Join(t.TempDir(), "transcripts")
+	recorder, err := transcript.NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	handler.SetTranscriptRecorder(recorder)
+
+	body := `{"model":"claude","messages":[{"role":"user","content":"hello"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", strings.NewReader(body))
+	req.Header.Set("X-Proxy-Target", upstream.URL)
+	req.Header.Set("X-Tool-ID", "claude")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries, err := transcript.Read(dir)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("entries = %d, want 1", len(entries))
+	}
+	got := entries[0]
+	if got.Provider != providerAnthropic || got.Path != "/v1/messages" || got.Tool != "claude" || got.StatusCode != http.StatusOK {
+		t.Errorf("unexpected entry %+v", got)
+	}
+	if string(got.Request) != body || !strings.Contains(got.Response, `"output_tokens":2`) {
+		t.Errorf("bodies not recorded verbatim: %s / %s", got.Request, got.Response)
+	}
+}
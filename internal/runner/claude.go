@@ -2,6 +2,7 @@ package runner
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/royisme/bobamixer/internal/domain/core"
 )
@@ -70,6 +71,9 @@ func (c *ClaudeRunner) Prepare(ctx *RunContext) error {
 	if ctx.Binding.UseProxy {
 		// Route requests through local proxy
 		ctx.Env["ANTHROPIC_BASE_URL"] = "http://127.0.0.1:7777/anthropic/v1"
+		// Identify the tool and project so the proxy can resolve its binding,
+		// key pool and budgets; Claude Code takes one header per line
+		ctx.Env["ANTHROPIC_CUSTOM_HEADERS"] = strings.Join(headerLines(ctx.ProxyHeaders()), "\n")
 		// Preserve the API key for proxy authentication
 		// The proxy will forward it to the actual provider
 	}
@@ -2,6 +2,7 @@ package runner
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/royisme/bobamixer/internal/domain/core"
 )
@@ -65,6 +66,9 @@ func (g *GeminiRunner) Prepare(ctx *RunContext) error {
 	// This is a best-effort implementation
 	if ctx.Binding.UseProxy {
 		ctx.Env["GEMINI_BASE_URL"] = "http://127.0.0.1:7777/gemini/v1"
+		// Identify the tool and project to the proxy; Gemini CLI takes
+		// comma-separated headers
+		ctx.Env["GEMINI_CLI_CUSTOM_HEADERS"] = strings.Join(headerLines(ctx.ProxyHeaders()), ", ")
 		// Preserve the API keys for proxy authentication
 	}
 
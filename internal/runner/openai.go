@@ -2,6 +2,8 @@ package runner
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/royisme/bobamixer/internal/domain/core"
 )
@@ -65,7 +67,11 @@ func (o *OpenAIRunner) Prepare(ctx *RunContext) error {
 	// Handle proxy mode
 	if ctx.Binding.UseProxy {
 		// Route requests through local proxy
-		ctx.Env["OPENAI_BASE_URL"] = "http://127.0.0.1:7777/openai/v1"
+		ctx.Env["OPENAI_BASE_URL"] = codexProxyURL
+		// Identify the tool and project to the proxy. Codex only sends custom
+		// headers for providers defined in its config, so define one that
+		// carries them for this run
+		ctx.Args = append(codexProxyArgs(ctx.ProxyHeaders()), ctx.Args...)
 		// Preserve the API key for proxy authentication
 		// The proxy will forward it to the actual provider
 	}
@@ -73,6 +79,26 @@ func (o *OpenAIRunner) Prepare(ctx *RunContext) error {
 	return nil
 }
 
+// codexProxyURL is the proxy's OpenAI endpoint
+const codexProxyURL = "http://127.0.0.1:7777/openai/v1"
+
+// codexProxyArgs returns config overrides that point Codex at a provider for
+// the proxy sending headers
+func codexProxyArgs(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%q = %q", name, headers[name]))
+	}
+	provider := fmt.Sprintf(`{ name = "BobaMixer proxy", base_url = %q, env_key = "OPENAI_API_KEY", wire_api = "responses", http_headers = { %s } }`,
+		codexProxyURL, strings.Join(pairs, ", "))
+	return []string{"-c", "model_providers.boba=" + provider, "-c", `model_provider="boba"`}
+}
+
 func init() {
 	// Register OpenAI/Codex runner
 	Register(core.ToolKindCodex, &OpenAIRunner{})
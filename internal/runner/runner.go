@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"sort"
 
 	"github.com/royisme/bobamixer/internal/domain/core"
 )
@@ -17,10 +18,32 @@ type RunContext struct {
 	Binding  *core.Binding
 	Provider *core.Provider
 	Secrets  *core.SecretsConfig
+	Project  string            // Project of the working directory, empty outside one
 	Env      map[string]string // Environment variables to inject
 	Args     []string          // Arguments to pass to the tool
 }
 
+// ProxyHeaders returns the headers that tell the proxy which tool, and which
+// project if any, a request comes from, so it can resolve the binding and
+// match tool and project budgets
+func (ctx *RunContext) ProxyHeaders() map[string]string {
+	headers := map[string]string{"X-Tool-ID": ctx.Tool.ID}
+	if ctx.Project != "" {
+		headers["X-Boba-Project"] = ctx.Project
+	}
+	return headers
+}
+
+// headerLines formats headers as sorted "Name: value" lines
+func headerLines(headers map[string]string) []string {
+	lines := make([]string, 0, len(headers))
+	for name, value := range headers {
+		lines = append(lines, name+": "+value)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
 // Runner is the interface for tool-specific runners
 type Runner interface {
 	// Prepare prepares the environment and configuration for running the tool
@@ -11,6 +11,9 @@ import (
 	"path/filepath"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/domain/currency"
 )
 
 //go:embed templates/profiles.yaml.tmpl
@@ -43,11 +46,130 @@ type ExploreSettings struct {
 	Rate    float64 `yaml:"rate"` // epsilon for epsilon-greedy exploration
 }
 
+// ProxySettings configures the local AI proxy.
+type ProxySettings struct {
+	Cache       CacheSettings      `yaml:"cache,omitempty"`
+	Transcripts TranscriptSettings `yaml:"transcripts,omitempty"`
+	Shadow      ShadowSettings     `yaml:"shadow,omitempty"`
+}
+
+// CacheSettings configures the proxy's exact-match response cache.
+// Zero limits fall back to the proxy defaults.
+type CacheSettings struct {
+	Enabled    bool `yaml:"enabled"`
+	TTLSeconds int  `yaml:"ttl_seconds,omitempty"`
+	MaxEntries int  `yaml:"max_entries,omitempty"`
+	MaxSizeMB  int  `yaml:"max_size_mb,omitempty"`
+}
+
+// TranscriptSettings configures recording of proxied exchanges to ~/.boba/transcripts,
+// which `boba mock serve` can replay.
+type TranscriptSettings struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// ShadowSettings configures mirroring of sampled proxy requests to alternate providers.
+type ShadowSettings struct {
+	Enabled    bool           `yaml:"enabled"`
+	SampleRate float64        `yaml:"sample_rate,omitempty"` // fraction of successful requests, 0..1
+	Targets    []ShadowTarget `yaml:"targets,omitempty"`
+}
+
+// ShadowTarget is a provider that receives mirrored requests.
+type ShadowTarget struct {
+	Provider string   `yaml:"provider"`
+	Model    string   `yaml:"model,omitempty"`
+	Tools    []string `yaml:"tools,omitempty"`
+}
+
+// RoutingSettings configures what routing keeps for `boba route replay`.
+type RoutingSettings struct {
+	// RecordFeatures keeps the features of each routing decision, including a
+	// DLP-masked sample of the prompt, for 30 days. Off by default.
+	RecordFeatures bool `yaml:"record_features"`
+}
+
+// BudgetSettings configures the thresholds budget spending is graded at.
+type BudgetSettings struct {
+	// Percentages of a budget used at which alerts turn warning and critical,
+	// routing sees near_cap and over_cap, and the proxy steps down a
+	// binding's downgrade ladder. Zero keeps the defaults of 80 and 100.
+	WarningPercent  float64 `yaml:"warning_percent,omitempty"`
+	CriticalPercent float64 `yaml:"critical_percent,omitempty"`
+}
+
+// AlertConfig returns the budget alert configuration with the thresholds
+// applied. Thresholds that leave warning at or above critical are ignored.
+func (b BudgetSettings) AlertConfig() *budget.AlertConfig {
+	cfg := budget.DefaultAlertConfig()
+	warning, critical := cfg.WarningPercent, cfg.CriticalPercent
+	if b.WarningPercent > 0 {
+		warning = b.WarningPercent
+	}
+	if b.CriticalPercent > 0 {
+		critical = b.CriticalPercent
+	}
+	if warning < critical {
+		cfg.WarningPercent, cfg.CriticalPercent = warning, critical
+	}
+	return cfg
+}
+
+// NotificationSettings configures delivery of budget alerts and suggestions
+// to sinks outside the TUI, polled in the background by `boba proxy serve`
+// and `boba notify run`.
+type NotificationSettings struct {
+	Enabled         bool               `yaml:"enabled"`
+	IntervalSeconds int                `yaml:"interval_seconds,omitempty"` // poll interval, default 300
+	Sinks           []NotificationSink `yaml:"sinks,omitempty"`
+}
+
+// Notification sink types
+const (
+	SinkDesktop = "desktop" // freedesktop notifications via notify-send or gdbus, osascript on macOS
+	SinkWebhook = "webhook" // JSON POST of the event
+	SinkSlack   = "slack"   // Slack-compatible incoming webhook
+	SinkEmail   = "email"   // SMTP
+	SinkCommand = "command" // local command, event JSON on stdin
+)
+
+// NotificationSink is one place notifications are delivered to.
+type NotificationSink struct {
+	Name       string            `yaml:"name"`
+	Type       string            `yaml:"type"`
+	Levels     []string          `yaml:"levels,omitempty"`      // info|projected|warning|critical; empty is all
+	QuietHours string            `yaml:"quiet_hours,omitempty"` // local HH:MM-HH:MM; held events are sent afterwards
+	URL        string            `yaml:"url,omitempty"`         // webhook and slack
+	Headers    map[string]string `yaml:"headers,omitempty"`     // webhook
+	Command    []string          `yaml:"command,omitempty"`     // command and its arguments
+	SMTP       SMTPSettings      `yaml:"smtp,omitempty"`
+}
+
+// SMTPSettings configures an email sink. The password is read from the
+// environment variable named by PasswordEnv rather than stored here.
+type SMTPSettings struct {
+	Host        string   `yaml:"host,omitempty"`
+	Port        int      `yaml:"port,omitempty"` // default 587, STARTTLS when offered
+	Username    string   `yaml:"username,omitempty"`
+	PasswordEnv string   `yaml:"password_env,omitempty"`
+	From        string   `yaml:"from,omitempty"`
+	To          []string `yaml:"to,omitempty"`
+}
+
 // Settings represents the user's configuration.
 type Settings struct {
-	Mode    Mode            `yaml:"mode"`
-	Theme   string          `yaml:"theme,omitempty"`
-	Explore ExploreSettings `yaml:"explore"`
+	Mode          Mode                 `yaml:"mode"`
+	Theme         string               `yaml:"theme,omitempty"`
+	Explore       ExploreSettings      `yaml:"explore"`
+	Proxy         ProxySettings        `yaml:"proxy,omitempty"`
+	Routing       RoutingSettings      `yaml:"routing,omitempty"`
+	Budget        BudgetSettings       `yaml:"budget,omitempty"`
+	Notifications NotificationSettings `yaml:"notifications,omitempty"`
+
+	// DisplayCurrency is the ISO 4217 code stats and the dashboard show costs
+	// in, converted from USD at the stored exchange rates; empty is USD.
+	// Reports and exports stay in USD.
+	DisplayCurrency string `yaml:"display_currency,omitempty"`
 }
 
 const (
@@ -117,6 +239,33 @@ func Save(ctx context.Context, home string, s Settings) error {
 	if s.Explore.Rate < 0 || s.Explore.Rate > 1 {
 		return fmt.Errorf("explore rate must be between 0 and 1, got %f", s.Explore.Rate)
 	}
+	if c := s.Proxy.Cache; c.TTLSeconds < 0 || c.MaxEntries < 0 || c.MaxSizeMB < 0 {
+		return fmt.Errorf("proxy cache limits must not be negative")
+	}
+	if r := s.Proxy.Shadow.SampleRate; r < 0 || r > 1 {
+		return fmt.Errorf("proxy shadow sample rate must be between 0 and 1, got %f", r)
+	}
+	if s.Notifications.IntervalSeconds < 0 {
+		return fmt.Errorf("notification interval must not be negative")
+	}
+	code, err := currency.Normalize(s.DisplayCurrency)
+	if err != nil {
+		return fmt.Errorf("display currency: %w", err)
+	}
+	s.DisplayCurrency = code
+	if code == currency.USD {
+		s.DisplayCurrency = ""
+	}
+	names := make(map[string]bool, len(s.Notifications.Sinks))
+	for _, sink := range s.Notifications.Sinks {
+		if sink.Name == "" {
+			return fmt.Errorf("notification sinks need a name")
+		}
+		if names[sink.Name] {
+			return fmt.Errorf("duplicate notification sink: %s", sink.Name)
+		}
+		names[sink.Name] = true
+	}
 
 	// Set defaults
 	if s.Theme == "" {
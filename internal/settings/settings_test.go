@@ -171,6 +171,68 @@ func TestSaveAndLoad(t *testing.T) {
 		}
 	})
 
+	t.Run("saves notification sinks and rejects duplicate names", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		home := filepath.Join(tmpDir, ".boba")
+		if err := settings.InitHome(home); err != nil {
+			t.Fatalf("InitHome failed: %v", err)
+		}
+		ctx := context.Background()
+
+		s := settings.Settings{
+			Mode: settings.ModeObserver,
+			Notifications: settings.NotificationSettings{
+				Enabled: true,
+				Sinks: []settings.NotificationSink{
+					{Name: "desk", Type: settings.SinkDesktop, Levels: []string{"critical"}, QuietHours: "22:00-07:00"},
+					{Name: "mail", Type: settings.SinkEmail, SMTP: settings.SMTPSettings{Host: "smtp.example.com", From: "boba@example.com", To: []string{"me@example.com"}}},
+				},
+			},
+		}
+		if err := settings.Save(ctx, home, s); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		loaded, err := settings.Load(ctx, home)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if !loaded.Notifications.Enabled || len(loaded.Notifications.Sinks) != 2 {
+			t.Fatalf("Notifications = %+v", loaded.Notifications)
+		}
+		if sink := loaded.Notifications.Sinks[1]; sink.SMTP.Host != "smtp.example.com" || sink.SMTP.To[0] != "me@example.com" {
+			t.Errorf("email sink = %+v", sink)
+		}
+
+		s.Notifications.Sinks[1].Name = "desk"
+		if err := settings.Save(ctx, home, s); err == nil {
+			t.Error("expected error for duplicate sink names")
+		}
+	})
+
+	t.Run("normalizes the display currency and rejects invalid codes", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		home := filepath.Join(tmpDir, ".boba")
+		if err := settings.InitHome(home); err != nil {
+			t.Fatalf("InitHome failed: %v", err)
+		}
+		ctx := context.Background()
+
+		if err := settings.Save(ctx, home, settings.Settings{Mode: settings.ModeObserver, DisplayCurrency: "cny"}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		loaded, err := settings.Load(ctx, home)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if loaded.DisplayCurrency != "CNY" {
+			t.Errorf("DisplayCurrency = %q, want CNY", loaded.DisplayCurrency)
+		}
+
+		if err := settings.Save(ctx, home, settings.Settings{Mode: settings.ModeObserver, DisplayCurrency: "yuan"}); err == nil {
+			t.Error("expected error for invalid display currency")
+		}
+	})
+
 	t.Run("supports all three modes", func(t *testing.T) {
 		tmpDir := t.TempDir()
 		home := filepath.Join(tmpDir, ".boba")
@@ -203,6 +265,25 @@ func TestSaveAndLoad(t *testing.T) {
 	})
 }
 
+func TestBudgetAlertConfig(t *testing.T) {
+	tests := []struct {
+		name              string
+		budget            settings.BudgetSettings
+		warning, critical float64
+	}{
+		{"defaults", settings.BudgetSettings{}, 80, 100},
+		{"both", settings.BudgetSettings{WarningPercent: 60, CriticalPercent: 90}, 60, 90},
+		{"warning only", settings.BudgetSettings{WarningPercent: 70}, 70, 100},
+		{"warning above critical", settings.BudgetSettings{WarningPercent: 95, CriticalPercent: 90}, 80, 100},
+	}
+	for _, tt := range tests {
+		cfg := tt.budget.AlertConfig()
+		if cfg.WarningPercent != tt.warning || cfg.CriticalPercent != tt.critical {
+			t.Errorf("%s: thresholds = %v/%v, want %v/%v", tt.name, cfg.WarningPercent, cfg.CriticalPercent, tt.warning, tt.critical)
+		}
+	}
+}
+
 func TestSettingsPermissions(t *testing.T) {
 	t.Run("settings file has secure permissions", func(t *testing.T) {
 		// Given: initialized home
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 )
 
 // Profile represents a complete AI provider profile configuration.
@@ -27,8 +28,9 @@ type Profile struct {
 
 // Cost represents input and output pricing per 1K tokens.
 type Cost struct {
-	Input  float64
-	Output float64
+	Input    float64
+	Output   float64
+	Currency string // ISO 4217 code; empty is USD
 }
 
 // Profiles is a map of profile names to Profile configurations.
@@ -77,6 +79,7 @@ type PricingTable struct {
 type ModelPrice struct {
 	InputPer1K  float64
 	OutputPer1K float64
+	Currency    string // ISO 4217 code; empty is USD
 }
 
 // PricingSource defines an external source for pricing data.
@@ -129,8 +132,9 @@ func LoadProfiles(home string) (Profiles, error) {
 		prof.Tags = stringSlice(node["tags"])
 		if cost := toMap(node["cost_per_1k"]); cost != nil {
 			prof.CostPer1K = Cost{
-				Input:  floatValue(cost["input"]),
-				Output: floatValue(cost["output"]),
+				Input:    floatValue(cost["input"]),
+				Output:   floatValue(cost["output"]),
+				Currency: strings.ToUpper(stringValue(cost["currency"])),
 			}
 		}
 		if env := toMap(node["env"]); env != nil {
@@ -174,7 +178,13 @@ func LoadSecrets(home string) (Secrets, error) {
 
 // LoadRoutes reads and parses the routes.yaml configuration file.
 func LoadRoutes(home string) (*RoutesConfig, error) {
-	data, err := readFileIfExists(filepath.Join(home, "routes.yaml"))
+	return LoadRoutesFile(filepath.Join(home, "routes.yaml"))
+}
+
+// LoadRoutesFile reads and parses a routes configuration file. A missing file
+// yields an empty configuration.
+func LoadRoutesFile(path string) (*RoutesConfig, error) {
+	data, err := readFileIfExists(path)
 	if err != nil {
 		return nil, err
 	}
@@ -249,6 +259,7 @@ func LoadPricing(home string) (*PricingTable, error) {
 			table.Models[name] = ModelPrice{
 				InputPer1K:  floatValue(entry["input_per_1k"]),
 				OutputPer1K: floatValue(entry["output_per_1k"]),
+				Currency:    strings.ToUpper(stringValue(entry["currency"])),
 			}
 		}
 	}
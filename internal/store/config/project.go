@@ -23,6 +23,7 @@ type ProjectInfo struct {
 type BudgetSettings struct {
 	DailyUSD float64 `yaml:"daily_usd"`
 	HardCap  float64 `yaml:"hard_cap"`
+	Currency string  `yaml:"currency"` // of daily_usd and hard_cap; empty keeps the budget's
 }
 
 // FindProjectConfig searches upward from start dir for .boba-project.yaml.
@@ -68,6 +69,7 @@ func parseProjectConfig(data []byte) (*ProjectConfig, error) {
 		cfg.Budget = &BudgetSettings{
 			DailyUSD: floatValue(budgetNode["daily_usd"]),
 			HardCap:  floatValue(budgetNode["hard_cap"]),
+			Currency: stringValue(budgetNode["currency"]),
 		}
 	}
 	return cfg, nil
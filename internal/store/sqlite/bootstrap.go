@@ -9,7 +9,7 @@ import (
 	"strings"
 )
 
-const schemaVersion = 3
+const schemaVersion = 19
 
 // DB represents a SQLite database connection using the sqlite3 CLI.
 type DB struct {
@@ -116,7 +116,135 @@ func (db *DB) bootstrap() error {
 		if err := db.migrateToV3(); err != nil {
 			return fmt.Errorf("migrate to v3: %w", err)
 		}
-		// version = 3 (final version, no further checks needed)
+		version = 3
+	}
+
+	// Version 3 -> 4: Add key_fingerprint to usage_records for key pool accounting
+	if version == 3 {
+		if err := db.migrateToV4(); err != nil {
+			return fmt.Errorf("migrate to v4: %w", err)
+		}
+		version = 4
+	}
+
+	// Version 4 -> 5: Add response cache accounting to usage_records
+	if version == 4 {
+		if err := db.migrateToV5(); err != nil {
+			return fmt.Errorf("migrate to v5: %w", err)
+		}
+		version = 5
+	}
+
+	// Version 5 -> 6: Add policy_rewrites audit table
+	if version == 5 {
+		if err := db.migrateToV6(); err != nil {
+			return fmt.Errorf("migrate to v6: %w", err)
+		}
+		version = 6
+	}
+
+	// Version 6 -> 7: Add dlp_findings table
+	if version == 6 {
+		if err := db.migrateToV7(); err != nil {
+			return fmt.Errorf("migrate to v7: %w", err)
+		}
+		version = 7
+	}
+
+	// Version 7 -> 8: Add shadow_results table for mirrored requests
+	if version == 7 {
+		if err := db.migrateToV8(); err != nil {
+			return fmt.Errorf("migrate to v8: %w", err)
+		}
+		version = 8
+	}
+
+	// Version 8 -> 9: Record prompt sizes on usage_records for tokenizer calibration
+	if version == 8 {
+		if err := db.migrateToV9(); err != nil {
+			return fmt.Errorf("migrate to v9: %w", err)
+		}
+		version = 9
+	}
+
+	// Version 9 -> 10: Persist the routing bandit's posterior
+	if version == 9 {
+		if err := db.migrateToV10(); err != nil {
+			return fmt.Errorf("migrate to v10: %w", err)
+		}
+		version = 10
+	}
+
+	// Version 10 -> 11: Add session feedback
+	if version == 10 {
+		if err := db.migrateToV11(); err != nil {
+			return fmt.Errorf("migrate to v11: %w", err)
+		}
+		version = 11
+	}
+
+	// Version 11 -> 12: Keep the features each routing decision was made on
+	if version == 11 {
+		if err := db.migrateToV12(); err != nil {
+			return fmt.Errorf("migrate to v12: %w", err)
+		}
+		version = 12
+	}
+
+	// Version 12 -> 13: Budget periods, with history of closed periods
+	if version == 12 {
+		if err := db.migrateToV13(); err != nil {
+			return fmt.Errorf("migrate to v13: %w", err)
+		}
+		version = 13
+	}
+
+	// Version 13 -> 14: Provider and binding on usage records, for budget scopes
+	if version == 13 {
+		if err := db.migrateToV14(); err != nil {
+			return fmt.Errorf("migrate to v14: %w", err)
+		}
+		version = 14
+	}
+
+	// Version 14 -> 15: Remember delivered notifications across restarts
+	if version == 14 {
+		if err := db.migrateToV15(); err != nil {
+			return fmt.Errorf("migrate to v15: %w", err)
+		}
+		version = 15
+	}
+
+	// Version 15 -> 16: Over-cap actions and overrides on budgets
+	if version == 15 {
+		if err := db.migrateToV16(); err != nil {
+			return fmt.Errorf("migrate to v16: %w", err)
+		}
+		version = 16
+	}
+
+	// Version 16 -> 17: Provider-billed usage imported from billing exports
+	if version == 16 {
+		if err := db.migrateToV17(); err != nil {
+			return fmt.Errorf("migrate to v17: %w", err)
+		}
+		version = 17
+	}
+
+	// Version 17 -> 18: Exchange rates and budget currencies
+	if version == 17 {
+		if err := db.migrateToV18(); err != nil {
+			return fmt.Errorf("migrate to v18: %w", err)
+		}
+		version = 18
+	}
+
+	// Version 18 -> 19: Mark usage that could not be priced in US dollars
+	if version == 18 {
+		if err := db.migrateToV19(); err != nil {
+			return fmt.Errorf("migrate to v19: %w", err)
+		}
+		// version = 19 (final version, no further checks needed)
 	}
 
 	return nil
@@ -213,3 +341,373 @@ func (db *DB) migrateToV3() error {
 	}
 	return nil
 }
+
+func (db *DB) migrateToV4() error {
+	// Record which pooled API key served each request (fingerprint only, never the key)
+	statements := []string{
+		`ALTER TABLE usage_records ADD COLUMN key_fingerprint TEXT;`,
+		`CREATE INDEX IF NOT EXISTS idx_usage_records_key_fingerprint ON usage_records(key_fingerprint);`,
+		"PRAGMA user_version = 4;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV5() error {
+	// Mark requests answered by the proxy response cache and the cost they avoided
+	statements := []string{
+		`ALTER TABLE usage_records ADD COLUMN cache_hit INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE usage_records ADD COLUMN saved_cost REAL NOT NULL DEFAULT 0;`,
+		"PRAGMA user_version = 5;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV6() error {
+	// Audit log of proxy policy rewrites, one row per applied change
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS policy_rewrites (
+            id TEXT PRIMARY KEY,
+            session_id TEXT NOT NULL,
+            ts INTEGER NOT NULL,
+            tool TEXT,
+            policy_id TEXT NOT NULL,
+            action TEXT NOT NULL,
+            detail TEXT,
+            FOREIGN KEY(session_id) REFERENCES sessions(id) ON DELETE CASCADE
+        );`,
+		`CREATE INDEX IF NOT EXISTS idx_policy_rewrites_session ON policy_rewrites(session_id);`,
+		"PRAGMA user_version = 6;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV7() error {
+	// Secrets and PII detected in proxied prompts; excerpt is redacted, never the raw match
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS dlp_findings (
+            id TEXT PRIMARY KEY,
+            session_id TEXT NOT NULL,
+            ts INTEGER NOT NULL,
+            tool TEXT,
+            rule_id TEXT NOT NULL,
+            kind TEXT NOT NULL,
+            action TEXT NOT NULL CHECK(action IN ('mask','block','warn')),
+            location TEXT,
+            excerpt TEXT,
+            FOREIGN KEY(session_id) REFERENCES sessions(id) ON DELETE CASCADE
+        );`,
+		`CREATE INDEX IF NOT EXISTS idx_dlp_findings_session ON dlp_findings(session_id);`,
+		"PRAGMA user_version = 7;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV8() error {
+	// Shadow requests mirrored to alternate providers, stored beside the primary's
+	// model, cost and latency so they can be compared without a join
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS shadow_results (
+            id TEXT PRIMARY KEY,
+            session_id TEXT NOT NULL,
+            ts INTEGER NOT NULL,
+            tool TEXT,
+            primary_provider TEXT,
+            primary_model TEXT,
+            primary_latency_ms INTEGER DEFAULT 0,
+            primary_cost REAL DEFAULT 0,
+            shadow_provider TEXT NOT NULL,
+            shadow_model TEXT,
+            status_code INTEGER DEFAULT 0,
+            latency_ms INTEGER DEFAULT 0,
+            input_tokens INTEGER DEFAULT 0,
+            output_tokens INTEGER DEFAULT 0,
+            cost REAL DEFAULT 0,
+            similarity REAL,
+            output TEXT,
+            error TEXT,
+            FOREIGN KEY(session_id) REFERENCES sessions(id) ON DELETE CASCADE
+        );`,
+		`CREATE INDEX IF NOT EXISTS idx_shadow_results_ts ON shadow_results(ts);`,
+		"PRAGMA user_version = 8;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV9() error {
+	// Characters of prompt text (and how many looked like code), the structural
+	// tokens around them and the local estimate, kept beside exact counts so
+	// per-model tokenizer coefficients can be fitted. Zero when not recorded.
+	statements := []string{
+		`ALTER TABLE usage_records ADD COLUMN prompt_chars INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE usage_records ADD COLUMN prompt_code_chars INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE usage_records ADD COLUMN prompt_overhead INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE usage_records ADD COLUMN estimated_input_tokens INTEGER NOT NULL DEFAULT 0;`,
+		"PRAGMA user_version = 9;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV10() error {
+	// Sessions remember the routing context they were decided under (route_key)
+	// and whether their reward has been folded into that context's arms yet.
+	// bandit_arms holds one Beta posterior per context and profile.
+	statements := []string{
+		`ALTER TABLE sessions ADD COLUMN route_key TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE sessions ADD COLUMN rewarded INTEGER NOT NULL DEFAULT 0;`,
+		`CREATE TABLE IF NOT EXISTS bandit_arms (
+            context TEXT NOT NULL,
+            profile TEXT NOT NULL,
+            alpha REAL NOT NULL DEFAULT 1,
+            beta REAL NOT NULL DEFAULT 1,
+            pulls INTEGER NOT NULL DEFAULT 0,
+            updated_at INTEGER NOT NULL DEFAULT 0,
+            PRIMARY KEY(context, profile)
+        );`,
+		"PRAGMA user_version = 10;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV11() error {
+	// One good (1) or bad (-1) rating per session, replaced when rated again.
+	// sessions.reward is the reward last folded into the routing bandit, so a
+	// later rating can correct it; -1 where it was folded before it was kept.
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS feedback (
+            session_id TEXT PRIMARY KEY,
+            ts INTEGER NOT NULL,
+            rating INTEGER NOT NULL CHECK(rating IN (-1, 1)),
+            note TEXT NOT NULL DEFAULT '',
+            source TEXT NOT NULL DEFAULT '',
+            FOREIGN KEY(session_id) REFERENCES sessions(id) ON DELETE CASCADE
+        );`,
+		`ALTER TABLE sessions ADD COLUMN reward REAL NOT NULL DEFAULT -1;`,
+		"PRAGMA user_version = 11;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV12() error {
+	// What routing saw for each decision, so routes.yaml changes can be replayed
+	// over past traffic. profile is the decision before exploration; written
+	// when routing runs, which for the proxy is before the session row exists.
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS route_features (
+            session_id TEXT PRIMARY KEY,
+            ts INTEGER NOT NULL,
+            intent TEXT NOT NULL DEFAULT '',
+            text_sample TEXT NOT NULL DEFAULT '',
+            ctx_chars INTEGER NOT NULL DEFAULT 0,
+            branch TEXT NOT NULL DEFAULT '',
+            project TEXT NOT NULL DEFAULT '',
+            project_types TEXT NOT NULL DEFAULT '',
+            budget_hint TEXT NOT NULL DEFAULT '',
+            budget_used REAL NOT NULL DEFAULT 0,
+            active_profile TEXT NOT NULL DEFAULT '',
+            profile TEXT NOT NULL DEFAULT ''
+        );`,
+		`CREATE INDEX IF NOT EXISTS idx_route_features_ts ON route_features(ts);`,
+		"PRAGMA user_version = 12;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV13() error {
+	// Budgets measure their cap per period: daily, weekly, monthly, custom or a
+	// rolling window of rolling_days. Existing budgets are monthly and align to
+	// calendar months from their first rollover. budget_periods keeps each
+	// closed period with the limits and spending it ended with.
+	statements := []string{
+		`ALTER TABLE budgets ADD COLUMN period TEXT NOT NULL DEFAULT 'monthly';`,
+		`ALTER TABLE budgets ADD COLUMN rolling_days INTEGER NOT NULL DEFAULT 0;`,
+		`CREATE TABLE IF NOT EXISTS budget_periods (
+            budget_id TEXT NOT NULL,
+            period_start INTEGER NOT NULL,
+            period_end INTEGER NOT NULL,
+            daily_usd REAL NOT NULL DEFAULT 0,
+            hard_cap REAL NOT NULL DEFAULT 0,
+            spent_usd REAL NOT NULL DEFAULT 0,
+            closed_at INTEGER NOT NULL,
+            PRIMARY KEY(budget_id, period_start),
+            FOREIGN KEY(budget_id) REFERENCES budgets(id) ON DELETE CASCADE
+        );`,
+		"PRAGMA user_version = 13;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV14() error {
+	// Budgets can be scoped to a provider or a binding (tool/provider), so
+	// usage records keep both beside the tool and model they already had
+	statements := []string{
+		`ALTER TABLE usage_records ADD COLUMN provider TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE usage_records ADD COLUMN binding TEXT NOT NULL DEFAULT '';`,
+		"PRAGMA user_version = 14;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV15() error {
+	// Each notification sink delivers an event once; the key identifies the
+	// event, including the budget period it belongs to
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS notification_deliveries (
+            event_key TEXT NOT NULL,
+            sink TEXT NOT NULL,
+            delivered_at INTEGER NOT NULL,
+            PRIMARY KEY(event_key, sink)
+        );`,
+		`CREATE INDEX IF NOT EXISTS idx_notification_deliveries_ts ON notification_deliveries(delivered_at);`,
+		"PRAGMA user_version = 15;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV16() error {
+	// on_cap decides what happens to requests over a budget: block, override,
+	// downgrade to downgrade_model, or warn. budget_overrides holds the
+	// time-limited overrides granted by `boba budget allow`.
+	statements := []string{
+		`ALTER TABLE budgets ADD COLUMN on_cap TEXT NOT NULL DEFAULT 'block';`,
+		`ALTER TABLE budgets ADD COLUMN downgrade_model TEXT NOT NULL DEFAULT '';`,
+		`CREATE TABLE IF NOT EXISTS budget_overrides (
+            budget_id TEXT NOT NULL,
+            granted_at INTEGER NOT NULL,
+            expires_at INTEGER NOT NULL,
+            FOREIGN KEY(budget_id) REFERENCES budgets(id) ON DELETE CASCADE
+        );`,
+		`CREATE INDEX IF NOT EXISTS idx_budget_overrides_budget ON budget_overrides(budget_id, expires_at);`,
+		"PRAGMA user_version = 16;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV17() error {
+	// billed_usage holds what providers billed per day and model, imported from
+	// their usage and cost exports by `boba reconcile import --store`
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS billed_usage (
+            source TEXT NOT NULL,
+            day TEXT NOT NULL,
+            model TEXT NOT NULL,
+            input_tokens INTEGER NOT NULL DEFAULT 0,
+            output_tokens INTEGER NOT NULL DEFAULT 0,
+            cost REAL NOT NULL DEFAULT 0,
+            imported_at INTEGER NOT NULL,
+            PRIMARY KEY(source, day, model)
+        );`,
+		`CREATE INDEX IF NOT EXISTS idx_billed_usage_day ON billed_usage(day);`,
+		"PRAGMA user_version = 17;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV18() error {
+	// exchange_rates holds how many units of a currency one US dollar buys
+	// from effective_from (a local YYYY-MM-DD) on, set by `boba currency set`.
+	// Usage costs stay in USD; budgets and their closed periods keep limits and
+	// spending in their own currency.
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS exchange_rates (
+            currency TEXT NOT NULL,
+            effective_from TEXT NOT NULL,
+            per_usd REAL NOT NULL,
+            updated_at INTEGER NOT NULL,
+            PRIMARY KEY(currency, effective_from)
+        );`,
+		`ALTER TABLE budgets ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';`,
+		`ALTER TABLE budget_periods ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';`,
+		"PRAGMA user_version = 18;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrateToV19() error {
+	// Usage priced in a currency without an exchange rate is recorded at no
+	// cost, naming the currency, rather than with an amount that is not USD
+	statements := []string{
+		`ALTER TABLE usage_records ADD COLUMN unpriced_currency TEXT NOT NULL DEFAULT '';`,
+		"PRAGMA user_version = 19;",
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package svc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -10,16 +11,25 @@ import (
 	"github.com/royisme/bobamixer/internal/adapters"
 	httpadapter "github.com/royisme/bobamixer/internal/adapters/http"
 	tooladapter "github.com/royisme/bobamixer/internal/adapters/tool"
+	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/domain/currency"
+	"github.com/royisme/bobamixer/internal/domain/pricing"
+	"github.com/royisme/bobamixer/internal/domain/tokenizer"
 	"github.com/royisme/bobamixer/internal/logging"
 	"github.com/royisme/bobamixer/internal/store/config"
 	"github.com/royisme/bobamixer/internal/store/sqlite"
 )
 
+// callTool names boba call as the calling tool in usage records and tool
+// budget scopes; calls made through profiles have no binding
+const callTool = "boba-call"
+
 // Executor handles the execution of AI calls with session and usage tracking
 type Executor struct {
 	db      *sqlite.DB
 	home    string
 	secrets config.Secrets
+	prices  *pricing.Table // loaded on first use, see priceTable
 }
 
 // NewExecutor creates a new executor
@@ -49,6 +59,8 @@ type ExecuteRequest struct {
 	Project    string
 	Branch     string
 	TaskType   string
+	RouteKey   string // Routing context the profile was chosen under; see routing.ContextKey
+	Explore    bool   // Whether routing explored away from its configured choice
 }
 
 // ExecuteResult represents the result of an AI call execution
@@ -81,6 +93,16 @@ func (e *Executor) Execute(ctx context.Context, req ExecuteRequest) (*ExecuteRes
 		return nil, fmt.Errorf("profile %s not found", req.ProfileKey)
 	}
 
+	// Refuse calls that would exceed a budget before anything is spent; a
+	// downgrade budget switches the profile to its model instead
+	profile, err = e.checkBudget(&req, profile)
+	if err != nil {
+		logging.Warn("Budget check failed",
+			logging.String("profile", req.ProfileKey),
+			logging.Err(err))
+		return nil, fmt.Errorf("budget check: %w", err)
+	}
+
 	// Create adapter
 	adapter, err := e.createAdapter(profile)
 	if err != nil {
@@ -153,6 +175,129 @@ func (e *Executor) Execute(ctx context.Context, req ExecuteRequest) (*ExecuteRes
 	}, nil
 }
 
+// checkBudget checks the call against every budget it matches. Over the cap
+// the budget's action applies: warn lets the call through, downgrade returns
+// the profile switched to the budget's model, with the payload's model
+// rewritten, and anything else returns the *budget.Denial. A downgraded call
+// is checked again at its new model and price, and is refused if a stricter
+// budget still denies it.
+func (e *Executor) checkBudget(req *ExecuteRequest, profile config.Profile) (config.Profile, error) {
+	denial := e.budgetDenial(req, profile)
+	if denial == nil {
+		return profile, nil
+	}
+
+	switch denial.Action() {
+	case budget.ActionWarn:
+		logging.Warn("Budget exceeded, allowing call", logging.String("budget", denial.Error()))
+		return profile, nil
+	case budget.ActionDowngrade:
+		downgraded, ok := e.downgrade(req, profile, denial.Model())
+		if !ok {
+			break
+		}
+		logging.Info("Budget exceeded, downgrading call",
+			logging.String("budget", denial.Error()),
+			logging.String("model", downgraded.Model))
+		again := e.budgetDenial(req, downgraded)
+		if again == nil {
+			return downgraded, nil
+		}
+		if again.Action() == budget.ActionWarn || again.Action() == budget.ActionDowngrade {
+			// Already on the cheapest model the budgets offer
+			logging.Warn("Budget exceeded after downgrading, allowing call", logging.String("budget", again.Error()))
+			return downgraded, nil
+		}
+		return profile, again
+	}
+	return profile, denial
+}
+
+// budgetDenial estimates the call's cost in US dollars at the profile's prices
+// and returns the denial of the strictest budget it would exceed, or nil
+func (e *Executor) budgetDenial(req *ExecuteRequest, profile config.Profile) *budget.Denial {
+	inputTokens := tokenizer.CountText(profile.Model, string(req.Payload)).Tokens
+	if count, err := tokenizer.CountRequest(req.Payload); err == nil {
+		inputTokens = count.Tokens
+	}
+	// Output is unknown until the call returns; assume 500 tokens unless the
+	// profile caps it lower, as the proxy does
+	outputTokens := 500
+	if profile.MaxTokens > 0 && profile.MaxTokens < outputTokens {
+		outputTokens = profile.MaxTokens
+	}
+	plannedIn, plannedOut, priceErr := e.cost(profile, inputTokens, outputTokens)
+
+	tracker := budget.NewTracker(e.db)
+	statuses, err := tracker.RequestStatuses(budget.Request{
+		Project:  req.Project,
+		Profile:  profile.Key,
+		Tool:     callTool,
+		Provider: profile.Provider,
+		Model:    profile.Model,
+	})
+	var denial *budget.Denial
+	if err == nil {
+		if priceErr != nil {
+			// A cost that cannot be converted fails closed, as in the proxy
+			denial, err = tracker.CheckUnpriced(statuses, priceErr)
+		} else {
+			denial, err = tracker.CheckStatuses(statuses, plannedIn+plannedOut)
+		}
+	}
+	if err != nil {
+		// Budget checks are best-effort, as in the proxy
+		logging.Info("Budget check error (allowing call)", logging.Err(err))
+		return nil
+	}
+	return denial
+}
+
+// downgrade switches a profile to another model, priced from the pricing
+// table, and rewrites the model a JSON payload names. Tool profiles pass the
+// profile's model to the tool instead. Models the table does not price keep
+// the profile's prices, which overstates rather than hides their cost. It
+// returns false when an HTTP payload names no model that can be switched.
+func (e *Executor) downgrade(req *ExecuteRequest, profile config.Profile, model string) (config.Profile, bool) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(req.Payload, &payload); err == nil {
+		payload["model"] = model
+		if rewritten, err := json.Marshal(payload); err == nil {
+			req.Payload = rewritten
+		} else if profile.Adapter == "http" {
+			return profile, false
+		}
+	} else if profile.Adapter == "http" {
+		return profile, false
+	}
+	if model == profile.Model {
+		return profile, true
+	}
+
+	if price, ok := e.priceTable().Models[model]; ok {
+		profile.CostPer1K = config.Cost{Input: price.InputPer1K, Output: price.OutputPer1K, Currency: price.Currency}
+	} else {
+		logging.Warn("Downgrade model not in the pricing table, recording it at the profile's prices",
+			logging.String("model", model))
+	}
+	profile.Model = model
+	return profile, true
+}
+
+// priceTable loads the pricing table the first time a call needs a model the
+// profile does not price
+func (e *Executor) priceTable() *pricing.Table {
+	if e.prices == nil {
+		table, err := pricing.Load(e.home)
+		if err != nil {
+			logging.Warn("Failed to load pricing", logging.Err(err))
+			table = &pricing.Table{}
+		}
+		e.prices = table
+	}
+	return e.prices
+}
+
 func (e *Executor) createAdapter(profile config.Profile) (adapters.Adapter, error) {
 	switch profile.Adapter {
 	case "http":
@@ -201,9 +346,13 @@ func (e *Executor) createAdapter(profile config.Profile) (adapters.Adapter, erro
 }
 
 func (e *Executor) beginSession(sessionID string, req ExecuteRequest, profile config.Profile) error {
+	explore := 0
+	if req.Explore {
+		explore = 1
+	}
 	query := fmt.Sprintf(
-		`INSERT INTO sessions (id, started_at, project, branch, profile, adapter, task_type)
-		 VALUES ('%s', %d, '%s', '%s', '%s', '%s', '%s');`,
+		`INSERT INTO sessions (id, started_at, project, branch, profile, adapter, task_type, route_key, explore)
+		 VALUES ('%s', %d, '%s', '%s', '%s', '%s', '%s', '%s', %d);`,
 		sessionID,
 		time.Now().Unix(),
 		sqlEscape(req.Project),
@@ -211,6 +360,8 @@ func (e *Executor) beginSession(sessionID string, req ExecuteRequest, profile co
 		sqlEscape(profile.Key),
 		sqlEscape(profile.Adapter),
 		sqlEscape(req.TaskType),
+		sqlEscape(req.RouteKey),
+		explore,
 	)
 	return e.db.Exec(query)
 }
@@ -231,12 +382,26 @@ func (e *Executor) endSession(sessionID string, success bool, latencyMS int64, n
 	return e.db.Exec(query)
 }
 
+// cost prices tokens at the profile's cost_per_1k in US dollars, as the proxy
+// records them; prices in another currency convert at the stored exchange
+// rates, and fail without one
+func (e *Executor) cost(profile config.Profile, inputTokens, outputTokens int) (inputCost, outputCost float64, err error) {
+	table := &pricing.Table{}
+	table.SetConverter(currency.NewRates(e.db))
+	return table.CalculateCost(profile.Model, profile.CostPer1K, inputTokens, outputTokens)
+}
+
 func (e *Executor) persistUsage(sessionID string, profile config.Profile, usage adapters.Usage) error {
 	usageID := uuid.New().String()
 
-	// Calculate costs
-	inputCost := float64(usage.InputTokens) * profile.CostPer1K.Input / 1000.0
-	outputCost := float64(usage.OutputTokens) * profile.CostPer1K.Output / 1000.0
+	// Calculate costs; a price that cannot be converted to US dollars is
+	// recorded at no cost, naming its currency, as the proxy does
+	inputCost, outputCost, err := e.cost(profile, usage.InputTokens, usage.OutputTokens)
+	unpriced := ""
+	if err != nil {
+		unpriced = profile.CostPer1K.Currency
+		logging.Warn("Usage recorded unpriced", logging.String("model", profile.Model), logging.Err(err))
+	}
 
 	// Map estimate level to string
 	estimateLevel := "heuristic"
@@ -251,8 +416,8 @@ func (e *Executor) persistUsage(sessionID string, profile config.Profile, usage
 
 	query := fmt.Sprintf(
 		`INSERT INTO usage_records
-		 (id, session_id, ts, input_tokens, output_tokens, input_cost, output_cost, tool, model, estimate_level)
-		 VALUES ('%s', '%s', %d, %d, %d, %f, %f, '%s', '%s', '%s');`,
+		 (id, session_id, ts, input_tokens, output_tokens, input_cost, output_cost, tool, model, provider, estimate_level, unpriced_currency)
+		 VALUES ('%s', '%s', %d, %d, %d, %f, %f, '%s', '%s', '%s', '%s', '%s');`,
 		usageID,
 		sessionID,
 		time.Now().Unix(),
@@ -260,9 +425,11 @@ func (e *Executor) persistUsage(sessionID string, profile config.Profile, usage
 		usage.OutputTokens,
 		inputCost,
 		outputCost,
-		sqlEscape(profile.Adapter),
+		callTool,
 		sqlEscape(profile.Model),
+		sqlEscape(profile.Provider),
 		estimateLevel,
+		sqlEscape(unpriced),
 	)
 	return e.db.Exec(query)
 }
@@ -0,0 +1,121 @@
+package svc
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royisme/bobamixer/internal/adapters"
+	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/domain/currency"
+	"github.com/royisme/bobamixer/internal/domain/pricing"
+	"github.com/royisme/bobamixer/internal/domain/tokenizer"
+	"github.com/royisme/bobamixer/internal/store/config"
+	"github.com/royisme/bobamixer/internal/store/sqlite"
+)
+
+func TestCostsInOtherCurrenciesRecordUSD(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	if err := currency.NewRates(db).Set("CNY", time.Now(), 7); err != nil {
+		t.Fatal(err)
+	}
+	e := &Executor{db: db}
+
+	profile := config.Profile{
+		Key: "qwen", Adapter: "http", Provider: "dashscope", Model: "qwen-max",
+		CostPer1K: config.Cost{Input: 7, Output: 14, Currency: "CNY"},
+	}
+	if err := e.persistUsage("s1", profile, adapters.Usage{InputTokens: 1000, OutputTokens: 500}); err != nil {
+		t.Fatalf("persistUsage: %v", err)
+	}
+	row, err := db.QueryRow("SELECT input_cost || '|' || output_cost FROM usage_records;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var in, out float64
+	if _, err := fmt.Sscanf(strings.Replace(row, "|", " ", 1), "%f %f", &in, &out); err != nil {
+		t.Fatalf("parse %q: %v", row, err)
+	}
+	if math.Abs(in-1) > 1e-6 || math.Abs(out-1) > 1e-6 {
+		t.Errorf("recorded costs = %v/%v, want 1/1 US dollars for ¥7/¥7", in, out)
+	}
+	if tool, err := db.QueryRow("SELECT tool FROM usage_records;"); err != nil || tool != callTool {
+		t.Errorf("recorded tool = %q, %v; want %s", tool, err, callTool)
+	}
+
+	// The planned cost is checked in US dollars too: ¥7 per token in yuan
+	// would exceed a daily budget that one US dollar per token fits
+	payload := []byte(`{"model":"qwen-max","messages":[{"role":"user","content":"hello"}]}`)
+	count, err := tokenizer.CountRequest(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile.CostPer1K = config.Cost{Input: 7000, Currency: "CNY"}
+	profile.MaxTokens = 1
+	if _, err := budget.NewTracker(db).CreateBudget("global", "", float64(2*count.Tokens)+2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.checkBudget(&ExecuteRequest{Payload: payload}, profile); err != nil {
+		t.Errorf("checkBudget = %v, want the call allowed in US dollars", err)
+	}
+}
+
+func TestDowngradeRecordsTheDowngradedModel(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	e := &Executor{db: db, prices: &pricing.Table{Models: map[string]pricing.ModelPrice{
+		"cheap": {InputPer1K: 0.001, OutputPer1K: 0.002},
+	}}}
+	tracker := budget.NewTracker(db)
+	b, err := tracker.CreateBudget("global", "", 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.SetAction(b.ID, budget.ActionDowngrade, "cheap"); err != nil {
+		t.Fatal(err)
+	}
+
+	profile := config.Profile{
+		Key: "opus", Adapter: "http", Provider: "anthropic", Model: "opus",
+		CostPer1K: config.Cost{Input: 1000, Output: 1000},
+	}
+	req := &ExecuteRequest{Payload: []byte(`{"model":"opus","messages":[{"role":"user","content":"hello"}]}`)}
+	downgraded, err := e.checkBudget(req, profile)
+	if err != nil {
+		t.Fatalf("checkBudget = %v, want the call downgraded", err)
+	}
+	if downgraded.Model != "cheap" || downgraded.CostPer1K.Input != 0.001 || downgraded.CostPer1K.Output != 0.002 {
+		t.Errorf("downgraded profile = %s at %+v, want cheap at its table prices", downgraded.Model, downgraded.CostPer1K)
+	}
+	if !strings.Contains(string(req.Payload), `"model":"cheap"`) {
+		t.Errorf("payload = %s, want the model rewritten", req.Payload)
+	}
+
+	if err := e.persistUsage("s1", downgraded, adapters.Usage{InputTokens: 1000, OutputTokens: 1000}); err != nil {
+		t.Fatalf("persistUsage: %v", err)
+	}
+	row, err := db.QueryRow("SELECT model || '|' || (input_cost + output_cost) FROM usage_records;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row != "cheap|0.003" {
+		t.Errorf("recorded usage = %q, want cheap|0.003", row)
+	}
+
+	// A budget on the downgrade model itself is checked too
+	if _, err := tracker.CreateBudget("model", "cheap", 0.0005, 0); err != nil {
+		t.Fatal(err)
+	}
+	req.Payload = []byte(`{"model":"opus","messages":[{"role":"user","content":"hello"}]}`)
+	if _, err := e.checkBudget(req, profile); err == nil {
+		t.Error("checkBudget allowed a downgrade over the downgrade model's budget")
+	}
+}
@@ -0,0 +1,128 @@
+// Package transcript records proxied request/response exchanges as JSONL and
+// reads them back, for replay by the mock provider.
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLine bounds a single recorded exchange when reading transcripts back
+const maxLine = 32 << 20
+
+// Entry is one recorded request/response exchange
+type Entry struct {
+	Timestamp   time.Time       `json:"ts"`
+	SessionID   string          `json:"session_id"`
+	Tool        string          `json:"tool,omitempty"`
+	Provider    string          `json:"provider"` // Proxy route: openai or anthropic
+	Path        string          `json:"path"`
+	StatusCode  int             `json:"status_code"`
+	ContentType string          `json:"content_type,omitempty"`
+	Request     json.RawMessage `json:"request"`  // Body as forwarded, after policies and DLP masking
+	Response    string          `json:"response"` // Raw upstream body (JSON or SSE)
+}
+
+// Recorder appends exchanges to daily JSONL files
+type Recorder struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewRecorder creates a recorder writing to dir
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create transcript dir: %w", err)
+	}
+	return &Recorder{dir: dir}, nil
+}
+
+// Dir returns the directory transcripts are written to
+func (t *Recorder) Dir() string {
+	return t.dir
+}
+
+// Record appends an exchange to the file for its day
+func (t *Recorder) Record(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encode transcript: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	path := filepath.Join(t.dir, entry.Timestamp.Format("2006-01-02")+".jsonl")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600) // #nosec G304 -- path is inside the BobaMixer home directory
+	if err != nil {
+		return fmt.Errorf("open transcript: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close() //nolint:errcheck // already failing
+		return fmt.Errorf("write transcript: %w", err)
+	}
+	return f.Close()
+}
+
+// Read loads entries from a JSONL file or every *.jsonl file in a directory
+func Read(path string) ([]Entry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("read transcripts: %w", err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.jsonl"))
+		if err != nil {
+			return nil, fmt.Errorf("list transcripts: %w", err)
+		}
+		sort.Strings(files)
+	}
+
+	var entries []Entry
+	for _, file := range files {
+		fileEntries, err := readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+	return entries, nil
+}
+
+func readFile(path string) ([]Entry, error) {
+	f, err := os.Open(path) // #nosec G304 -- user-selected transcript file
+	if err != nil {
+		return nil, fmt.Errorf("open transcript: %w", err)
+	}
+	defer f.Close() //nolint:errcheck // read-only
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid transcript entry: %w", path, lineNo, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return entries, nil
+}
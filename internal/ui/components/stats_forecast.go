@@ -0,0 +1,70 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/royisme/bobamixer/internal/domain/currency"
+	"github.com/royisme/bobamixer/internal/ui/theme"
+)
+
+// StatsForecast represents a budget's projected end-of-period spending.
+type StatsForecast struct {
+	Budget   string // scope and period, e.g. "global (monthly)"
+	Ends     string // when the period ends, e.g. "Oct 31"
+	Expected float64
+	Low      float64
+	High     float64
+	Cap      float64
+	Method   string
+	Overrun  bool
+	Currency string // the budget's; empty is USD
+}
+
+// StatsForecastList renders budget projections.
+type StatsForecastList struct {
+	forecasts []StatsForecast
+	styles    theme.Styles
+}
+
+// NewStatsForecastList constructs the list component.
+func NewStatsForecastList(forecasts []StatsForecast, styles theme.Styles) StatsForecastList {
+	return StatsForecastList{
+		forecasts: forecasts,
+		styles:    styles,
+	}
+}
+
+// Update satisfies the Bubble Tea component interface.
+func (c StatsForecastList) Update(_ tea.Msg) (StatsForecastList, tea.Cmd) {
+	return c, nil
+}
+
+// View renders one line per budget, highlighting projected overruns.
+func (c StatsForecastList) View() string {
+	if len(c.forecasts) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, f := range c.forecasts {
+		style := c.styles.BudgetOK
+		if f.Overrun {
+			style = c.styles.BudgetWarn
+		}
+		line := fmt.Sprintf("• %s: %s by %s (80%%: %s-%s)", f.Budget, currency.Format(f.Expected, f.Currency, 2), f.Ends,
+			currency.Format(f.Low, f.Currency, 2), currency.Format(f.High, f.Currency, 2))
+		if f.Cap > 0 {
+			line += fmt.Sprintf(" of %s cap", currency.Format(f.Cap, f.Currency, 2))
+		}
+		if f.Overrun {
+			line += " ⚠ projected overrun"
+		}
+		line += fmt.Sprintf(" [%s]", f.Method)
+		b.WriteString(style.PaddingLeft(2).Render(line))
+		b.WriteString("\n")
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
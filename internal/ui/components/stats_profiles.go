@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/royisme/bobamixer/internal/domain/currency"
 	"github.com/royisme/bobamixer/internal/ui/theme"
 )
 
@@ -17,6 +18,7 @@ type StatsProfile struct {
 	AvgLatency float64
 	UsagePct   float64
 	CostPct    float64
+	Currency   string // of the cost; empty is USD
 }
 
 // StatsProfilesList renders the list of profile stats.
@@ -49,10 +51,10 @@ func (c StatsProfilesList) View() string {
 	style = style.PaddingLeft(2)
 
 	for _, ps := range c.profiles {
-		line := fmt.Sprintf("• %s: tokens=%d cost=$%.4f sessions=%d latency=%.0fms usage=%.1f%% cost=%.1f%%",
+		line := fmt.Sprintf("• %s: tokens=%d cost=%s sessions=%d latency=%.0fms usage=%.1f%% cost=%.1f%%",
 			ps.Name,
 			ps.Tokens,
-			ps.Cost,
+			currency.Format(ps.Cost, ps.Currency, 4),
 			ps.Sessions,
 			ps.AvgLatency,
 			ps.UsagePct,
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/royisme/bobamixer/internal/domain/currency"
 	"github.com/royisme/bobamixer/internal/ui/theme"
 )
 
@@ -17,6 +18,7 @@ type StatsSummary struct {
 	AvgDailyTokens float64
 	AvgDailyCost   float64
 	ShowAverages   bool
+	Currency       string // of the costs; empty is USD
 }
 
 // StatsSummaryPanel renders usage metrics in a simple block.
@@ -42,14 +44,14 @@ func (c StatsSummaryPanel) Update(_ tea.Msg) (StatsSummaryPanel, tea.Cmd) {
 func (c StatsSummaryPanel) View() string {
 	lines := []string{
 		fmt.Sprintf("Tokens:   %d", c.summary.Tokens),
-		fmt.Sprintf("Cost:     $%.4f", c.summary.Cost),
+		fmt.Sprintf("Cost:     %s", currency.Format(c.summary.Cost, c.summary.Currency, 4)),
 		fmt.Sprintf("Sessions: %d", c.summary.Sessions),
 	}
 
 	if c.summary.ShowAverages {
 		lines = append(lines,
 			fmt.Sprintf("Avg Daily Tokens: %.0f", c.summary.AvgDailyTokens),
-			fmt.Sprintf("Avg Daily Cost:   $%.4f", c.summary.AvgDailyCost),
+			fmt.Sprintf("Avg Daily Cost:   %s", currency.Format(c.summary.AvgDailyCost, c.summary.Currency, 4)),
 		)
 	}
 
@@ -1,15 +1,25 @@
 // Package routing provides the service layer for routing view data and logic.
 package routing
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/royisme/bobamixer/internal/store/config"
+)
+
 // Service manages routing view data and logic.
-type Service struct{}
+type Service struct {
+	home string
+}
 
 // NewService creates a new routing service.
-func NewService() *Service {
-	return &Service{}
+func NewService(home string) *Service {
+	return &Service{home: home}
 }
 
-// ViewData returns all static data for the routing view.
+// ViewData returns all data for the routing view.
 func (s *Service) ViewData() ViewData {
 	return ViewData{
 		Title:           "BobaMixer - Routing Rules Tester",
@@ -17,6 +27,7 @@ func (s *Service) ViewData() ViewData {
 		HowToTitle:      "💡 How to Use",
 		ExampleTitle:    "📋 Example",
 		ContextTitle:    "ℹ️  Context Detection",
+		OrderTitle:      "🧭 Routing Order",
 		TestDescription: "Test how routing rules would apply to different queries.",
 		HowToSteps: []string{
 			"1. Prepare a test query (text or file)",
@@ -24,10 +35,10 @@ func (s *Service) ViewData() ViewData {
 			"3. Or: boba route test @path/to/file.txt",
 		},
 		ExampleLines: []string{
-			"$ boba route test \"Write a Python function\"",
-			"→ Profile: claude-sonnet-3.5",
-			"→ Rule: short-query-fast-model",
-			"→ Reason: Query < 100 chars",
+			"$ boba route test \"Please review this module\"",
+			"→ Profile: work-heavy",
+			"→ Sub-agent: code_review",
+			"→ Reason: Sub-agent code_review triggered by 'review'",
 		},
 		ContextLines: []string{
 			"Query length and complexity",
@@ -35,10 +46,66 @@ func (s *Service) ViewData() ViewData {
 			"Time of day (day/evening/night)",
 			"Project type (go, web, etc.)",
 		},
+		OrderLines:      s.orderLines(),
 		CommandHelpLine: "Use CLI: boba route test <text|@file>",
 	}
 }
 
+// orderLines lists sub-agents and rules in the order the router checks them
+func (s *Service) orderLines() []string {
+	if s.home == "" {
+		return []string{"No routes.yaml loaded"}
+	}
+	routes, err := config.LoadRoutes(s.home)
+	if err != nil {
+		return []string{fmt.Sprintf("Failed to load routes.yaml: %v", err)}
+	}
+	if len(routes.SubAgents)+len(routes.Rules) == 0 {
+		return []string{"No sub-agents or rules configured"}
+	}
+
+	var lines []string
+	names := make([]string, 0, len(routes.SubAgents))
+	for name := range routes.SubAgents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		agent := routes.SubAgents[name]
+		line := fmt.Sprintf("%d. sub-agent %s → %s (triggers: %s)", i+1, name, agent.Profile, strings.Join(agent.Triggers, ", "))
+		if conds := describeConditions(agent.Conditions); conds != "" {
+			line += " if " + conds
+		}
+		lines = append(lines, line)
+	}
+	for i, rule := range routes.Rules {
+		lines = append(lines, fmt.Sprintf("%d. rule %s → %s", len(names)+i+1, rule.ID, rule.Use))
+	}
+	return lines
+}
+
+// describeConditions renders sub-agent conditions as "key=value" pairs in key order
+func describeConditions(conditions map[string]interface{}) string {
+	keys := make([]string, 0, len(conditions))
+	for key := range conditions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := conditions[key]
+		if list, ok := value.([]interface{}); ok {
+			items := make([]string, 0, len(list))
+			for _, item := range list {
+				items = append(items, fmt.Sprint(item))
+			}
+			value = strings.Join(items, "|")
+		}
+		parts = append(parts, fmt.Sprintf("%s=%v", key, value))
+	}
+	return strings.Join(parts, ", ")
+}
+
 // ViewData holds all data needed to render the routing view.
 type ViewData struct {
 	Title           string
@@ -46,9 +113,11 @@ type ViewData struct {
 	HowToTitle      string
 	ExampleTitle    string
 	ContextTitle    string
+	OrderTitle      string
 	TestDescription string
 	HowToSteps      []string
 	ExampleLines    []string
 	ContextLines    []string
+	OrderLines      []string // Sub-agents, then rules, in check order
 	CommandHelpLine string
 }
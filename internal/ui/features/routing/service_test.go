@@ -1,18 +1,21 @@
 package routing
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
 func TestNewService(t *testing.T) {
-	svc := NewService()
+	svc := NewService("")
 	if svc == nil {
 		t.Fatal("expected service to be created")
 	}
 }
 
 func TestViewData(t *testing.T) {
-	svc := NewService()
+	svc := NewService("")
 	data := svc.ViewData()
 
 	// Test title fields
@@ -67,7 +70,7 @@ func TestViewData(t *testing.T) {
 }
 
 func TestViewData_HowToSteps(t *testing.T) {
-	svc := NewService()
+	svc := NewService("")
 	data := svc.ViewData()
 
 	if len(data.HowToSteps) == 0 {
@@ -92,7 +95,7 @@ func TestViewData_HowToSteps(t *testing.T) {
 }
 
 func TestViewData_ExampleLines(t *testing.T) {
-	svc := NewService()
+	svc := NewService("")
 	data := svc.ViewData()
 
 	if len(data.ExampleLines) == 0 {
@@ -100,10 +103,10 @@ func TestViewData_ExampleLines(t *testing.T) {
 	}
 
 	expectedLines := []string{
-		"$ boba route test \"Write a Python function\"",
-		"→ Profile: claude-sonnet-3.5",
-		"→ Rule: short-query-fast-model",
-		"→ Reason: Query < 100 chars",
+		"$ boba route test \"Please review this module\"",
+		"→ Profile: work-heavy",
+		"→ Sub-agent: code_review",
+		"→ Reason: Sub-agent code_review triggered by 'review'",
 	}
 
 	if len(data.ExampleLines) != len(expectedLines) {
@@ -118,7 +121,7 @@ func TestViewData_ExampleLines(t *testing.T) {
 }
 
 func TestViewData_ContextLines(t *testing.T) {
-	svc := NewService()
+	svc := NewService("")
 	data := svc.ViewData()
 
 	if len(data.ContextLines) == 0 {
@@ -144,7 +147,7 @@ func TestViewData_ContextLines(t *testing.T) {
 }
 
 func TestViewData_Consistency(t *testing.T) {
-	svc := NewService()
+	svc := NewService("")
 
 	// Call ViewData multiple times to ensure consistency
 	data1 := svc.ViewData()
@@ -166,3 +169,31 @@ func TestViewData_Consistency(t *testing.T) {
 		t.Error("ViewData should return consistent ContextLines")
 	}
 }
+
+func TestViewData_OrderLinesListSubAgentsFirst(t *testing.T) {
+	home := t.TempDir()
+	routes := `sub_agents:
+  quick_fix:
+    profile: "quick-tasks"
+    triggers: ["fix","typo"]
+    conditions:
+      max_ctx_chars: 1200
+      time_of_day: ["09:00-18:00"]
+rules:
+  - id: "deep-analysis"
+    if: "ctx_chars>3000"
+    use: "work-heavy"
+`
+	if err := os.WriteFile(filepath.Join(home, "routes.yaml"), []byte(routes), 0o600); err != nil {
+		t.Fatalf("write routes: %v", err)
+	}
+
+	data := NewService(home).ViewData()
+	want := []string{
+		"1. sub-agent quick_fix → quick-tasks (triggers: fix, typo) if max_ctx_chars=1200, time_of_day=09:00-18:00",
+		"2. rule deep-analysis → work-heavy",
+	}
+	if strings.Join(data.OrderLines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("OrderLines =\n%s\nwant\n%s", strings.Join(data.OrderLines, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -76,10 +76,11 @@ func (s *Service) SaveValue(home string, value string) {
 
 	provider := s.Providers.Providers[targetIdx]
 	cfg := s.ensureConfig()
-	cfg.Secrets[provider.ID] = core.Secret{
-		ProviderID: provider.ID,
-		APIKey:     trimmed,
-	}
+	// Keep any pooled keys configured for this provider
+	secret := cfg.Secrets[provider.ID]
+	secret.ProviderID = provider.ID
+	secret.APIKey = trimmed
+	cfg.Secrets[provider.ID] = secret
 
 	if err := core.SaveSecrets(home, cfg); err != nil {
 		msg := fmt.Sprintf("Failed to save API key: %v", err)
@@ -7,7 +7,11 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/domain/currency"
+	"github.com/royisme/bobamixer/internal/domain/forecast"
 	"github.com/royisme/bobamixer/internal/domain/stats"
+	"github.com/royisme/bobamixer/internal/settings"
 	"github.com/royisme/bobamixer/internal/store/sqlite"
 	"github.com/royisme/bobamixer/internal/ui/components"
 )
@@ -56,34 +60,100 @@ func (s *Service) LoadData() (StatsData, error) {
 		profileStats = []stats.ProfileStats{}
 	}
 
+	// Project budgets to the end of their periods; without budgets there is nothing to show
+	forecasts, err := loadForecasts(budget.NewTracker(db))
+	if err != nil {
+		forecasts = nil
+	}
+
 	return StatsData{
 		Today:        today,
 		Week:         week,
 		ProfileStats: profileStats,
+		Forecasts:    forecasts,
+		Display:      s.loadDisplay(db),
 	}, nil
 }
 
+// loadDisplay returns the display currency of the settings at today's rate,
+// or US dollars when none is set or it has no rate.
+func (s *Service) loadDisplay(db *sqlite.DB) currency.Display {
+	userSettings, err := settings.Load(context.Background(), s.home)
+	if err != nil || currency.IsUSD(userSettings.DisplayCurrency) {
+		return currency.Display{}
+	}
+	display, err := currency.NewRates(db).Display(userSettings.DisplayCurrency, time.Now())
+	if err != nil {
+		return currency.Display{}
+	}
+	return display
+}
+
+// loadForecasts projects every active budget, one scope at a time.
+func loadForecasts(tracker *budget.Tracker) ([]*budget.Projection, error) {
+	budgets, err := tracker.GetAllBudgets()
+	if err != nil {
+		return nil, err
+	}
+	var forecasts []*budget.Projection
+	seen := make(map[string]bool)
+	for _, b := range budgets {
+		key := b.Scope + "\x00" + b.Target
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		projections, err := tracker.Forecasts(b.Scope, b.Target)
+		if err != nil {
+			return nil, err
+		}
+		forecasts = append(forecasts, projections...)
+	}
+	return forecasts, nil
+}
+
 // StatsData holds the raw domain data loaded from the database.
 type StatsData struct {
 	Today        stats.Summary
 	Week         stats.Summary
 	ProfileStats []stats.ProfileStats
+	Forecasts    []*budget.Projection
+	Display      currency.Display // how costs, recorded in USD, are shown
 }
 
-// ConvertToView converts domain stats data to UI components.
+// ConvertToView converts domain stats data to UI components, costs in the
+// display currency.
 func (s *Service) ConvertToView(data StatsData) ViewData {
-	return ViewData{
-		Today:    s.convertSummary("📅 Today's Usage", data.Today, false),
-		Week:     s.convertSummary("📊 Last 7 Days", data.Week, true),
-		Profiles: s.convertProfiles(data.ProfileStats),
+	view := ViewData{
+		Today:     s.convertSummary("📅 Today's Usage", data.Today, false),
+		Week:      s.convertSummary("📊 Last 7 Days", data.Week, true),
+		Profiles:  s.convertProfiles(data.ProfileStats),
+		Forecasts: s.convertForecasts(data.Forecasts),
 	}
+	view.showIn(data.Display)
+	return view
 }
 
 // ViewData holds the UI-ready data for rendering.
 type ViewData struct {
-	Today    components.StatsSummary
-	Week     components.StatsSummary
-	Profiles []components.StatsProfile
+	Today     components.StatsSummary
+	Week      components.StatsSummary
+	Profiles  []components.StatsProfile
+	Forecasts []components.StatsForecast
+}
+
+// showIn converts the usage costs, recorded in USD, to the display currency.
+// Forecasts stay in their budgets' currencies.
+func (v *ViewData) showIn(display currency.Display) {
+	for _, summary := range []*components.StatsSummary{&v.Today, &v.Week} {
+		summary.Cost = display.Convert(summary.Cost)
+		summary.AvgDailyCost = display.Convert(summary.AvgDailyCost)
+		summary.Currency = display.Currency
+	}
+	for i := range v.Profiles {
+		v.Profiles[i].Cost = display.Convert(v.Profiles[i].Cost)
+		v.Profiles[i].Currency = display.Currency
+	}
 }
 
 // convertSummary converts domain Summary to component StatsSummary.
@@ -119,3 +189,35 @@ func (s *Service) convertProfiles(statsList []stats.ProfileStats) []components.S
 	}
 	return result
 }
+
+// convertForecasts converts budget projections to component StatsForecast,
+// skipping budgets without spending history.
+func (s *Service) convertForecasts(projections []*budget.Projection) []components.StatsForecast {
+	if len(projections) == 0 {
+		return nil
+	}
+
+	result := make([]components.StatsForecast, 0, len(projections))
+	for _, p := range projections {
+		if p.Method == forecast.MethodNone {
+			continue
+		}
+		b := p.Status.Budget
+		name := b.Scope
+		if b.Target != "" {
+			name += " " + b.Target
+		}
+		result = append(result, components.StatsForecast{
+			Budget:   fmt.Sprintf("%s (%s)", name, b.Spec().Label()),
+			Ends:     p.At.Format("Jan 2"),
+			Expected: p.Expected,
+			Low:      p.Low,
+			High:     p.High,
+			Cap:      p.Status.HardCap,
+			Method:   p.Method,
+			Overrun:  p.Overrun(),
+			Currency: b.Currency,
+		})
+	}
+	return result
+}
@@ -2,7 +2,10 @@ package stats
 
 import (
 	"testing"
+	"time"
 
+	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/domain/forecast"
 	"github.com/royisme/bobamixer/internal/domain/stats"
 	"github.com/royisme/bobamixer/internal/ui/components"
 )
@@ -281,3 +284,50 @@ func TestConvertToView(t *testing.T) {
 		t.Errorf("Profiles[0].Name: got %q, want %q", view.Profiles[0].Name, "default")
 	}
 }
+
+func TestConvertForecasts(t *testing.T) {
+	svc := NewService("/test")
+
+	if got := svc.convertForecasts(nil); got != nil {
+		t.Errorf("convertForecasts(nil) = %v, want nil", got)
+	}
+
+	monthly := &budget.Status{
+		Budget:  &budget.Budget{Scope: "project", Target: "api", Period: budget.PeriodMonthly},
+		HardCap: 100,
+	}
+	fresh := &budget.Status{
+		Budget:  &budget.Budget{Scope: "global", Period: budget.PeriodMonthly},
+		HardCap: 50,
+	}
+	projections := []*budget.Projection{
+		{
+			Status:   monthly,
+			Method:   forecast.MethodHoltWinters,
+			Expected: 120,
+			Low:      105,
+			High:     135,
+			At:       time.Date(2026, 10, 31, 23, 59, 59, 0, time.Local),
+		},
+		// No history yet: nothing to show
+		{Status: fresh, Method: forecast.MethodNone},
+	}
+
+	got := svc.convertForecasts(projections)
+	if len(got) != 1 {
+		t.Fatalf("got %d forecasts, want 1", len(got))
+	}
+	want := components.StatsForecast{
+		Budget:   "project api (monthly)",
+		Ends:     "Oct 31",
+		Expected: 120,
+		Low:      105,
+		High:     135,
+		Cap:      100,
+		Method:   forecast.MethodHoltWinters,
+		Overrun:  true,
+	}
+	if got[0] != want {
+		t.Errorf("got %+v, want %+v", got[0], want)
+	}
+}
@@ -32,6 +32,7 @@ const (
 	V = "v"
 	C = "c"
 	B = "b"
+	G = "g"
 	X = "x"
 
 	// Symbols
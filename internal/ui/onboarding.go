@@ -641,9 +641,9 @@ func (m *OnboardingModel) saveConfiguration() (tea.Model, tea.Cmd) {
 		if secrets.Secrets == nil {
 			secrets.Secrets = make(map[string]core.Secret)
 		}
-		secrets.Secrets[m.selectedProvider.ID] = core.Secret{
-			APIKey: m.apiKeyValue,
-		}
+		secret := secrets.Secrets[m.selectedProvider.ID]
+		secret.APIKey = m.apiKeyValue
+		secrets.Secrets[m.selectedProvider.ID] = secret
 		if err := core.SaveSecrets(m.home, secrets); err != nil {
 			m.err = fmt.Errorf("failed to save secrets: %w", err)
 			return m, nil
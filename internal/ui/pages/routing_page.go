@@ -16,10 +16,12 @@ type RoutingPageProps struct {
 	HowToTitle      string
 	ExampleTitle    string
 	ContextTitle    string
+	OrderTitle      string
 	TestDescription string
 	HowToSteps      []string
 	ExampleLines    []string
 	ContextLines    []string
+	OrderLines      []string
 	NavigationHelp  string
 	CommandHelpLine string
 }
@@ -31,12 +33,14 @@ type RoutingPage struct {
 	howTo      components.BulletList
 	example    components.Paragraph
 	context    components.BulletList
+	order      components.BulletList
 	help       components.HelpBar
 	styles     theme.Styles // Added styles field
 	testTitle  string
 	howToTitle string
 	exTitle    string
 	ctxTitle   string
+	orderTitle string
 }
 
 // NewRoutingPage builds the routing page.
@@ -56,12 +60,14 @@ func NewRoutingPage(palette theme.Theme, props RoutingPageProps) RoutingPage {
 		howTo:      components.NewBulletList(props.HowToSteps, styles),
 		example:    components.NewParagraph(strings.Join(props.ExampleLines, "\n"), styles),
 		context:    components.NewBulletList(props.ContextLines, styles),
+		order:      components.NewBulletList(props.OrderLines, styles),
 		help:       components.NewHelpBar(helpText, styles),
 		styles:     styles, // Initialize styles
 		testTitle:  props.TestTitle,
 		howToTitle: props.HowToTitle,
 		exTitle:    props.ExampleTitle,
 		ctxTitle:   props.ContextTitle,
+		orderTitle: props.OrderTitle,
 	}
 }
 
@@ -77,8 +83,9 @@ func (p RoutingPage) Update(msg tea.Msg) (Page, tea.Cmd) {
 	_, cmd3 := p.howTo.Update(msg)
 	_, cmd4 := p.example.Update(msg)
 	_, cmd5 := p.context.Update(msg)
-	_, cmd6 := p.help.Update(msg)
-	return p, tea.Batch(cmd1, cmd2, cmd3, cmd4, cmd5, cmd6)
+	_, cmd6 := p.order.Update(msg)
+	_, cmd7 := p.help.Update(msg)
+	return p, tea.Batch(cmd1, cmd2, cmd3, cmd4, cmd5, cmd6, cmd7)
 }
 
 // View assembles the routing tester view.
@@ -112,6 +119,13 @@ func (p RoutingPage) View() string {
 			p.context.View(),
 		))
 
+	orderCard := components.NewCard(p.styles).
+		WithWidth(122).
+		Render(layouts.Column(
+			p.styles.Header.Render(p.orderTitle),
+			p.order.View(),
+		))
+
 	// Arrange in a grid-like layout if possible, or just better vertical spacing
 	// For now, let's stick to vertical but with cards
 	blocks := []string{
@@ -121,6 +135,8 @@ func (p RoutingPage) View() string {
 		layouts.Gap(1),
 		layouts.Row(exampleCard, contextCard), // Side by side
 		layouts.Gap(1),
+		orderCard,
+		layouts.Gap(1),
 		layouts.Pad(2, p.help.View()),
 	}
 
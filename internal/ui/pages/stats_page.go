@@ -18,6 +18,7 @@ type StatsPageProps struct {
 	Today           components.StatsSummary
 	Week            components.StatsSummary
 	Profiles        []components.StatsProfile
+	Forecasts       []components.StatsForecast
 	NavigationHelp  string
 	LoadingHelp     string
 	ProfileSubtitle string
@@ -29,6 +30,7 @@ type StatsPage struct {
 	today      components.StatsSummaryPanel
 	week       components.StatsSummaryPanel
 	profiles   components.StatsProfilesList
+	forecasts  components.StatsForecastList
 	errorMsg   components.StatusMessage
 	loadingMsg components.InfoMessage
 	help       components.HelpBar
@@ -51,6 +53,7 @@ func NewStatsPage(palette theme.Theme, props StatsPageProps) StatsPage {
 		today:      components.NewStatsSummaryPanel(props.Today, styles),
 		week:       components.NewStatsSummaryPanel(props.Week, styles),
 		profiles:   components.NewStatsProfilesList(props.Profiles, styles),
+		forecasts:  components.NewStatsForecastList(props.Forecasts, styles),
 		errorMsg:   components.NewStatusMessage(strings.TrimSpace(errorText), palette.Danger),
 		loadingMsg: components.NewInfoMessage(props.LoadingMessage, styles),
 		help:       components.NewHelpBar(props.NavigationHelp, styles),
@@ -75,7 +78,8 @@ func (p StatsPage) Update(msg tea.Msg) (Page, tea.Cmd) {
 	_, cmd5 := p.errorMsg.Update(msg)
 	_, cmd6 := p.loadingMsg.Update(msg)
 	_, cmd7 := p.help.Update(msg)
-	return p, tea.Batch(cmd1, cmd2, cmd3, cmd4, cmd5, cmd6, cmd7)
+	_, cmd8 := p.forecasts.Update(msg)
+	return p, tea.Batch(cmd1, cmd2, cmd3, cmd4, cmd5, cmd6, cmd7, cmd8)
 }
 
 // View assembles the stats screen with the layout DSL.
@@ -110,6 +114,10 @@ func (p StatsPage) View() string {
 		blocks = append(blocks, layouts.Section(title, profiles), layouts.Gap(1))
 	}
 
+	if forecasts := p.forecasts.View(); forecasts != "" {
+		blocks = append(blocks, layouts.Section("🔮 Budget Forecast", forecasts), layouts.Gap(1))
+	}
+
 	blocks = append(blocks, layouts.Pad(2, p.help.View()))
 	return layouts.Column(blocks...)
 }
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/royisme/bobamixer/internal/domain/budget"
 	"github.com/royisme/bobamixer/internal/domain/stats"
 	"github.com/royisme/bobamixer/internal/domain/suggestions"
 	"github.com/royisme/bobamixer/internal/proxy"
@@ -22,6 +23,7 @@ type statsLoadedMsg struct {
 	today        stats.Summary
 	week         stats.Summary
 	profileStats []stats.ProfileStats
+	forecasts    []*budget.Projection
 	err          error
 }
 
@@ -67,6 +69,7 @@ func (m *DashboardModel) loadStatsData() tea.Msg {
 		today:        data.Today,
 		week:         data.Week,
 		profileStats: data.ProfileStats,
+		forecasts:    data.Forecasts,
 	}
 }
 
@@ -8,6 +8,7 @@ import (
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/royisme/bobamixer/internal/domain/budget"
 	"github.com/royisme/bobamixer/internal/domain/core"
 	"github.com/royisme/bobamixer/internal/domain/stats"
 	"github.com/royisme/bobamixer/internal/domain/suggestions"
@@ -55,6 +56,7 @@ type DashboardModel struct {
 	todayStats   stats.Summary
 	weekStats    stats.Summary
 	profileStats []stats.ProfileStats
+	forecasts    []*budget.Projection
 	statsLoaded  bool
 	statsError   string
 
@@ -189,7 +191,7 @@ func NewDashboard(home string) (*DashboardModel, error) {
 	m.statsService = statssvc.NewService(home)
 	m.suggestionsService = suggestionssvc.NewService(home)
 	m.dashboardService = dashboardsvc.NewService(m.tools, m.bindings, m.providers, m.secrets)
-	m.routingService = routingsvc.NewService()
+	m.routingService = routingsvc.NewService(home)
 	m.configService = configsvc.NewService()
 	m.hooksService = hookssvc.NewService()
 	m.helpService = helpsvc.NewService()
@@ -48,6 +48,7 @@ func (m DashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.todayStats = msg.today
 			m.weekStats = msg.week
 			m.profileStats = msg.profileStats
+			m.forecasts = msg.forecasts
 			m.statsLoaded = true
 			m.statsError = ""
 		}
@@ -85,6 +85,7 @@ func (m DashboardModel) renderStatsView() string {
 		Today:        m.todayStats,
 		Week:         m.weekStats,
 		ProfileStats: m.profileStats,
+		Forecasts:    m.forecasts,
 	})
 
 	props := pages.StatsPageProps{
@@ -95,6 +96,7 @@ func (m DashboardModel) renderStatsView() string {
 		Today:           viewData.Today,
 		Week:            viewData.Week,
 		Profiles:        viewData.Profiles,
+		Forecasts:       viewData.Forecasts,
 		NavigationHelp:  m.dashboardService.GetNavigationHelp(),
 		LoadingHelp:     "[V] Back to Dashboard  [Q] Quit",
 		ProfileSubtitle: "🎯 By Profile (7d)",
@@ -329,10 +331,12 @@ func (m DashboardModel) renderRoutingView() string {
 		HowToTitle:      data.HowToTitle,
 		ExampleTitle:    data.ExampleTitle,
 		ContextTitle:    data.ContextTitle,
+		OrderTitle:      data.OrderTitle,
 		TestDescription: data.TestDescription,
 		HowToSteps:      data.HowToSteps,
 		ExampleLines:    data.ExampleLines,
 		ContextLines:    data.ContextLines,
+		OrderLines:      data.OrderLines,
 		NavigationHelp:  m.dashboardService.GetNavigationHelp(),
 		CommandHelpLine: data.CommandHelpLine,
 	}
@@ -11,7 +11,10 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/royisme/bobamixer/internal/domain/bandit"
 	"github.com/royisme/bobamixer/internal/domain/budget"
+	"github.com/royisme/bobamixer/internal/domain/currency"
+	"github.com/royisme/bobamixer/internal/domain/forecast"
 	"github.com/royisme/bobamixer/internal/domain/session"
 	"github.com/royisme/bobamixer/internal/domain/stats"
 	"github.com/royisme/bobamixer/internal/domain/suggestions"
@@ -51,6 +54,7 @@ type Model struct {
 	todayStats    *stats.DataPoint
 	trend7d       *stats.Trend
 	budgetStatus  *budget.Status
+	forecast      *budget.Projection
 	notifier      *notifications.Notifier
 	theme         Theme
 	styles        Styles
@@ -60,6 +64,7 @@ type Model struct {
 	flashMessage  string
 	viewMode      ViewMode
 	selectedIdx   int
+	sessionIdx    int
 	width         int
 	height        int
 	err           error
@@ -88,9 +93,17 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.todayStats = msg.todayStats
 		m.trend7d = msg.trend7d
 		m.budgetStatus = msg.budgetStatus
+		m.forecast = msg.forecast
 		m.lastUpdate = time.Now()
 		m.err = msg.err
 		m.sessionList = msg.sessions
+		if m.sessionIdx >= len(m.sessionList) {
+			m.sessionIdx = max(len(m.sessionList)-1, 0)
+		}
+
+	case sessionRatedMsg:
+		m.flashMessage = fmt.Sprintf("Rated session %s %s", msg.id, msg.label)
+		return m, m.loadData
 
 	case notificationMsg:
 		if msg.err != nil {
@@ -123,11 +136,27 @@ func (m Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if m.viewMode == ViewProfiles && m.selectedIdx > 0 {
 			m.selectedIdx--
 		}
+		if m.viewMode == ViewSessions && m.sessionIdx > 0 {
+			m.sessionIdx--
+		}
 
 	case keys.Down, keys.J:
 		if m.viewMode == ViewProfiles && m.selectedIdx < len(m.profileList)-1 {
 			m.selectedIdx++
 		}
+		if m.viewMode == ViewSessions && m.sessionIdx < len(m.sessionList)-1 {
+			m.sessionIdx++
+		}
+
+	case keys.G, keys.B:
+		// Rate the selected session good or bad
+		if m.viewMode == ViewSessions && m.sessionIdx < len(m.sessionList) {
+			rating := session.RatingGood
+			if msg.String() == keys.B {
+				rating = session.RatingBad
+			}
+			return m, m.rateSession(m.sessionList[m.sessionIdx].ID, rating)
+		}
 
 	case keys.Enter:
 		if m.viewMode == ViewProfiles && m.selectedIdx < len(m.profileList) {
@@ -298,8 +327,8 @@ func (m Model) renderBudget() string {
 	}
 
 	lines = append(lines, fmt.Sprintf("Daily Limit: %s / %s",
-		dailyStyle.Render(stats.FormatCurrency(m.budgetStatus.CurrentSpent)),
-		stats.FormatCurrency(m.budgetStatus.DailyLimit),
+		dailyStyle.Render(currency.Format(m.budgetStatus.CurrentSpent, m.budgetStatus.Budget.Currency, 4)),
+		currency.Format(m.budgetStatus.DailyLimit, m.budgetStatus.Budget.Currency, 4),
 	))
 	lines = append(lines, dailyBar)
 	lines = append(lines, "")
@@ -315,14 +344,32 @@ func (m Model) renderBudget() string {
 	}
 
 	// Calculate total spent
-	totalSpent := m.budgetStatus.Budget.SpentUSD
+	totalSpent := m.budgetStatus.Budget.Spent
 	lines = append(lines, fmt.Sprintf("Hard Cap: %s / %s",
-		totalStyle.Render(stats.FormatCurrency(totalSpent)),
-		stats.FormatCurrency(m.budgetStatus.HardCap),
+		totalStyle.Render(currency.Format(totalSpent, m.budgetStatus.Budget.Currency, 4)),
+		currency.Format(m.budgetStatus.HardCap, m.budgetStatus.Budget.Currency, 4),
 	))
 	lines = append(lines, totalBar)
 	lines = append(lines, "")
 
+	// Projected spending at the end of the period
+	if p := m.forecast; p != nil && p.Method != forecast.MethodNone {
+		forecastStyle := m.styles.BudgetOK
+		if p.Overrun() {
+			forecastStyle = m.styles.BudgetWarn
+		}
+		lines = append(lines, fmt.Sprintf("Forecast: %s by %s (80%%: %s-%s)",
+			forecastStyle.Render(currency.Format(p.Expected, m.budgetStatus.Budget.Currency, 4)),
+			p.At.Format("Jan 2"),
+			currency.Format(p.Low, m.budgetStatus.Budget.Currency, 4),
+			currency.Format(p.High, m.budgetStatus.Budget.Currency, 4),
+		))
+		if p.Overrun() {
+			lines = append(lines, m.styles.BudgetWarn.Render("🔮 Projected to exceed the hard cap before the period ends"))
+		}
+		lines = append(lines, "")
+	}
+
 	// Warning level
 	warningLevel := m.budgetStatus.GetWarningLevel()
 	var warningMsg string
@@ -386,19 +433,29 @@ func (m Model) renderSessions() string {
 	}
 
 	lines := []string{m.styles.Header.Render("Recent Sessions"), ""}
-	for _, sess := range m.sessionList {
+	for i, sess := range m.sessionList {
 		started := time.Unix(sess.StartedAt, 0).Format("01-02 15:04")
 		status := m.styles.BudgetOK.Render("✓")
 		if !sess.Success {
 			status = m.styles.BudgetDanger.Render("✗")
 		}
+		rating := ""
+		switch sess.Rating {
+		case session.RatingGood:
+			rating = m.styles.BudgetOK.Render("good")
+		case session.RatingBad:
+			rating = m.styles.BudgetDanger.Render("bad")
+		}
 		dur := fmt.Sprintf("%dms", sess.LatencyMS)
-		lines = append(lines,
-			fmt.Sprintf("%s  %-12s %-10s %-8s %s", started, sess.Profile, sess.Adapter, dur, status),
-		)
+		line := fmt.Sprintf("%s  %-12s %-10s %-8s %s %s", started, sess.Profile, sess.Adapter, dur, status, rating)
+		if i == m.sessionIdx {
+			lines = append(lines, m.styles.Selected.Render("▶ ")+line)
+		} else {
+			lines = append(lines, "  "+line)
+		}
 	}
 	lines = append(lines, "")
-	lines = append(lines, m.styles.Help.Render("Tab: Switch view  r: Refresh"))
+	lines = append(lines, m.styles.Help.Render("↑/↓: Navigate  g/b: Rate good/bad  Tab: Switch view  r: Refresh"))
 	return lipgloss.JoinVertical(lipgloss.Left, lines...)
 }
 
@@ -439,8 +496,8 @@ func (m Model) renderBudgetStatusBox() string {
 		statusStyle.Render(fmt.Sprintf("%s Budget: %.0f%%", statusIcon, dailyPercent)),
 		bar,
 		fmt.Sprintf("%s / %s daily",
-			stats.FormatCurrency(m.budgetStatus.CurrentSpent),
-			stats.FormatCurrency(m.budgetStatus.DailyLimit),
+			currency.Format(m.budgetStatus.CurrentSpent, m.budgetStatus.Budget.Currency, 4),
+			currency.Format(m.budgetStatus.DailyLimit, m.budgetStatus.Budget.Currency, 4),
 		),
 	}
 
@@ -498,6 +555,7 @@ type dataLoadedMsg struct {
 	todayStats   *stats.DataPoint
 	trend7d      *stats.Trend
 	budgetStatus *budget.Status
+	forecast     *budget.Projection
 	err          error
 }
 
@@ -510,6 +568,11 @@ type errMsg struct {
 	err error
 }
 
+type sessionRatedMsg struct {
+	id    string
+	label string
+}
+
 func (m Model) loadData() tea.Msg {
 	var msg dataLoadedMsg
 
@@ -534,6 +597,9 @@ func (m Model) loadData() tea.Msg {
 		status, err := m.budgetTracker.GetStatus("profile", m.activeProfile)
 		if err == nil {
 			msg.budgetStatus = status
+			if projection, err := m.budgetTracker.Project(status, time.Now()); err == nil {
+				msg.forecast = projection
+			}
 		}
 	}
 
@@ -544,6 +610,23 @@ func (m Model) loadData() tea.Msg {
 	return msg
 }
 
+// rateSession records TUI feedback for a session and feeds it to the routing bandit
+func (m Model) rateSession(id string, rating int) tea.Cmd {
+	return func() tea.Msg {
+		if err := session.Rate(m.db, session.Feedback{SessionID: id, Rating: rating, Source: session.FeedbackSourceTUI}); err != nil {
+			return errMsg{err: err}
+		}
+		if _, err := bandit.NewStore(m.db).Learn(bandit.DefaultWeights); err != nil {
+			return errMsg{err: err}
+		}
+		short := id
+		if len(short) > 8 {
+			short = short[:8]
+		}
+		return sessionRatedMsg{id: short, label: session.RatingLabel(rating)}
+	}
+}
+
 func (m Model) saveActiveProfile() tea.Msg {
 	if err := config.SaveActiveProfile(m.home, m.activeProfile); err != nil {
 		return errMsg{err: err}
@@ -657,7 +740,7 @@ func Run(home string) error {
 		db:            db,
 		budgetTracker: tracker,
 		statsAnalyzer: stats.NewAnalyzer(db),
-		notifier:      notifications.NewNotifier(tracker, suggEngine, nil),
+		notifier:      notifications.NewNotifier(db, suggEngine, loadAlertConfig(home)),
 		theme:         theme,
 		styles:        NewStyles(theme),
 		localizer:     localizer,
@@ -669,6 +752,15 @@ func Run(home string) error {
 	return err
 }
 
+// loadAlertConfig returns the budget thresholds from settings, or the defaults
+func loadAlertConfig(home string) *budget.AlertConfig {
+	userSettings, err := settings.Load(context.Background(), home)
+	if err != nil {
+		return budget.DefaultAlertConfig()
+	}
+	return userSettings.Budget.AlertConfig()
+}
+
 func loadTheme(home string) Theme {
 	ctx := context.Background()
 